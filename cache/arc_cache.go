@@ -0,0 +1,277 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+type arcListID int
+
+const (
+	arcT1 arcListID = iota // resident, seen once recently
+	arcT2                  // resident, seen at least twice recently
+	arcB1                  // ghost of entries evicted from t1
+	arcB2                  // ghost of entries evicted from t2
+	arcNumLists
+)
+
+type arcEntry struct {
+	key  Measurable
+	data Measurable // nil for ghost entries
+	size int
+	list arcListID
+}
+
+// arcEvictedCache is an Adaptive Replacement Cache (Megiddo and
+// Modha, FAST '03), generalized to entries of different sizes by
+// keeping all the list lengths and the adaptation target in bytes
+// instead of entries.
+//
+// New entries go into t1, and entries that are hit again while
+// resident move to t2.  Entries evicted from t1 and t2 are
+// remembered (key and size only) in the ghost lists b1 and b2.  A
+// miss that hits b1 means t1 was too small, so the target size p of
+// t1 grows; a miss that hits b2 shrinks it.  So a one-time scan
+// through many entries can only push out the recency side of the
+// cache, while entries used over and over again stay in t2.
+type arcEvictedCache struct {
+	mu        sync.Mutex
+	maxBytes  int
+	p         int // target size of t1, in bytes
+	lists     [arcNumLists]*list.List
+	bytes     [arcNumLists]int
+	entries   map[Measurable]*list.Element
+	onEvicted func(key Measurable, data Measurable)
+}
+
+// NewARCEvictedCache returns a Cache that uses the Adaptive
+// Replacement Cache eviction strategy, which keeps both recently and
+// frequently used entries, and adapts the balance between the two to
+// the workload.  The cache will have a capacity of maxBytes bytes. A
+// zero-byte capacity cache is valid.
+//
+// Like the other caches in this package, the size of an entry is
+// only calculated once, when it's added.
+func NewARCEvictedCache(maxBytes int) Cache {
+	return newARCEvictedCache(maxBytes, nil)
+}
+
+func newARCEvictedCache(maxBytes int,
+	onEvicted func(key Measurable, data Measurable)) *arcEvictedCache {
+	c := &arcEvictedCache{
+		maxBytes:  maxBytes,
+		entries:   make(map[Measurable]*list.Element),
+		onEvicted: onEvicted,
+	}
+	for i := range c.lists {
+		c.lists[i] = list.New()
+	}
+	return c
+}
+
+func (c *arcEvictedCache) residentBytesLocked() int {
+	return c.bytes[arcT1] + c.bytes[arcT2]
+}
+
+func (c *arcEvictedCache) removeLocked(e *list.Element) *arcEntry {
+	entry := e.Value.(*arcEntry)
+	c.lists[entry.list].Remove(e)
+	c.bytes[entry.list] -= entry.size
+	delete(c.entries, entry.key)
+	return entry
+}
+
+func (c *arcEvictedCache) pushFrontLocked(entry *arcEntry, l arcListID) {
+	entry.list = l
+	c.entries[entry.key] = c.lists[l].PushFront(entry)
+	c.bytes[l] += entry.size
+}
+
+// evictFromLocked moves the least recently used entry of the given
+// resident list into its ghost list.
+func (c *arcEvictedCache) evictFromLocked(from, to arcListID) {
+	entry := c.removeLocked(c.lists[from].Back())
+	data := entry.data
+	entry.data = nil
+	c.pushFrontLocked(entry, to)
+	if c.onEvicted != nil {
+		c.onEvicted(entry.key, data)
+	}
+}
+
+// replaceLocked evicts one resident entry, picking t1 or t2
+// depending on how t1 compares to its target size.  hitB2 is true
+// when making room for an entry that was found in b2.
+func (c *arcEvictedCache) replaceLocked(hitB2 bool) bool {
+	t1Bytes := c.bytes[arcT1]
+	switch {
+	case c.lists[arcT1].Len() > 0 &&
+		(t1Bytes > c.p || (hitB2 && t1Bytes == c.p) ||
+			c.lists[arcT2].Len() == 0):
+		c.evictFromLocked(arcT1, arcB1)
+	case c.lists[arcT2].Len() > 0:
+		c.evictFromLocked(arcT2, arcB2)
+	default:
+		return false
+	}
+	return true
+}
+
+// trimGhostsLocked keeps the ghost lists from remembering more than
+// the directory size ARC allows: t1+b1 within the capacity, and all
+// four lists within twice the capacity.
+func (c *arcEvictedCache) trimGhostsLocked() {
+	for c.lists[arcB1].Len() > 0 &&
+		c.bytes[arcT1]+c.bytes[arcB1] > c.maxBytes {
+		c.removeLocked(c.lists[arcB1].Back())
+	}
+	for c.lists[arcB2].Len() > 0 &&
+		c.residentBytesLocked()+c.bytes[arcB1]+c.bytes[arcB2] >
+			2*c.maxBytes {
+		c.removeLocked(c.lists[arcB2].Back())
+	}
+}
+
+// Get implements the Cache interface.
+func (c *arcEvictedCache) Get(key Measurable) (data Measurable, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := e.Value.(*arcEntry)
+	if entry.list != arcT1 && entry.list != arcT2 {
+		return nil, false
+	}
+	c.removeLocked(e)
+	c.pushFrontLocked(entry, arcT2)
+	return entry.data, true
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Add implements the Cache interface.
+func (c *arcEvictedCache) Add(key Measurable, data Measurable) {
+	size := key.Size() + data.Size()
+	if size > c.maxBytes {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &arcEntry{key: key, data: data, size: size}
+	hitB2 := false
+	if e, ok := c.entries[key]; ok {
+		old := c.removeLocked(e)
+		switch old.list {
+		case arcB1:
+			// t1 was too small to keep this entry around; favor
+			// recency.
+			delta := size
+			if b1 := c.bytes[arcB1]; b1 > 0 && c.bytes[arcB2] > b1 {
+				delta = size * c.bytes[arcB2] / b1
+			}
+			c.p = minInt(c.maxBytes, c.p+delta)
+		case arcB2:
+			// t2 was too small to keep this entry around; favor
+			// frequency.
+			delta := size
+			if b2 := c.bytes[arcB2]; b2 > 0 && c.bytes[arcB1] > b2 {
+				delta = size * c.bytes[arcB1] / b2
+			}
+			c.p = maxInt(0, c.p-delta)
+			hitB2 = true
+		}
+		// Anything that was seen before goes into t2.
+		for c.residentBytesLocked()+size > c.maxBytes &&
+			c.replaceLocked(hitB2) {
+		}
+		c.pushFrontLocked(entry, arcT2)
+		c.trimGhostsLocked()
+		return
+	}
+
+	for c.residentBytesLocked()+size > c.maxBytes && c.replaceLocked(false) {
+	}
+	c.pushFrontLocked(entry, arcT1)
+	c.trimGhostsLocked()
+}
+
+// Remove implements the EvictableCache interface.
+func (c *arcEvictedCache) Remove(key Measurable) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return
+	}
+	entry := e.Value.(*arcEntry)
+	if entry.list != arcT1 && entry.list != arcT2 {
+		return
+	}
+	c.removeLocked(e)
+	if c.onEvicted != nil {
+		c.onEvicted(entry.key, entry.data)
+	}
+}
+
+// EvictOne implements the EvictableCache interface.
+func (c *arcEvictedCache) EvictOne() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	evicted := c.replaceLocked(false)
+	c.trimGhostsLocked()
+	return evicted
+}
+
+// Keys implements the KeyLister interface.  Entries that have been
+// used more than once come first, then the ones used only once, each
+// from the most to the least recently used.  Ghost entries aren't
+// included.
+func (c *arcEvictedCache) Keys() []Measurable {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys := make([]Measurable, 0,
+		c.lists[arcT1].Len()+c.lists[arcT2].Len())
+	for _, l := range []arcListID{arcT2, arcT1} {
+		for e := c.lists[l].Front(); e != nil; e = e.Next() {
+			keys = append(keys, e.Value.(*arcEntry).key)
+		}
+	}
+	return keys
+}
+
+// Len implements the EvictableCache interface.
+func (c *arcEvictedCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lists[arcT1].Len() + c.lists[arcT2].Len()
+}
+
+// SetMaxBytes implements the EvictableCache interface.
+func (c *arcEvictedCache) SetMaxBytes(maxBytes int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxBytes = maxBytes
+	c.p = minInt(c.p, maxBytes)
+	for c.residentBytesLocked() > c.maxBytes && c.replaceLocked(false) {
+	}
+	c.trimGhostsLocked()
+}
@@ -0,0 +1,153 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testKey is a Measurable cache key of a fixed size.
+type testKey int
+
+func (testKey) Size() int {
+	return 8
+}
+
+// testValue is a Measurable cache value of a given size.
+type testValue int
+
+func (v testValue) Size() int {
+	return int(v)
+}
+
+const testEntrySize = 8 + 8
+
+func TestARCEvictedCacheGetAdd(t *testing.T) {
+	c := NewARCEvictedCache(4 * testEntrySize)
+	_, ok := c.Get(testKey(1))
+	require.False(t, ok)
+
+	for i := 0; i < 4; i++ {
+		c.Add(testKey(i), testValue(8))
+	}
+	for i := 0; i < 4; i++ {
+		data, ok := c.Get(testKey(i))
+		require.True(t, ok)
+		require.Equal(t, testValue(8), data)
+	}
+
+	t.Log("Entries bigger than the whole cache are dropped.")
+	c.Add(testKey(5), testValue(5*testEntrySize))
+	_, ok = c.Get(testKey(5))
+	require.False(t, ok)
+}
+
+func TestARCEvictedCacheScanResistance(t *testing.T) {
+	const numHot = 4
+	c := NewARCEvictedCache(2 * numHot * testEntrySize)
+
+	t.Log("Use the hot set twice, so it moves to the frequency side.")
+	for round := 0; round < 2; round++ {
+		for i := 0; i < numHot; i++ {
+			if _, ok := c.Get(testKey(i)); !ok {
+				c.Add(testKey(i), testValue(8))
+			}
+		}
+	}
+
+	t.Log("A long scan of new entries only pushes out other scan entries.")
+	for i := 1000; i < 1100; i++ {
+		c.Add(testKey(i), testValue(8))
+	}
+	for i := 0; i < numHot; i++ {
+		_, ok := c.Get(testKey(i))
+		require.True(t, ok, "hot entry %d was evicted", i)
+	}
+
+	t.Log("An LRU cache of the same size loses the hot set.")
+	lru := NewLRUEvictedCache(2 * numHot * testEntrySize)
+	for i := 0; i < numHot; i++ {
+		lru.Add(testKey(i), testValue(8))
+	}
+	for i := 1000; i < 1100; i++ {
+		lru.Add(testKey(i), testValue(8))
+	}
+	_, ok := lru.Get(testKey(0))
+	require.False(t, ok)
+}
+
+func TestEvictableCache(t *testing.T) {
+	for _, policy := range []EvictionPolicy{
+		EvictionPolicyLRU, EvictionPolicyARC} {
+		t.Run(policy.String(), func(t *testing.T) {
+			evicted := make(map[testKey]bool)
+			c, err := NewEvictableCache(policy, 4*testEntrySize,
+				func(key Measurable, data Measurable) {
+					require.Equal(t, testValue(8), data)
+					evicted[key.(testKey)] = true
+				})
+			require.NoError(t, err)
+			for i := 0; i < 4; i++ {
+				c.Add(testKey(i), testValue(8))
+			}
+			require.Equal(t, 4, c.Len())
+
+			c.Remove(testKey(2))
+			require.True(t, evicted[testKey(2)])
+			_, ok := c.Get(testKey(2))
+			require.False(t, ok)
+			require.Equal(t, 3, c.Len())
+
+			require.True(t, c.EvictOne())
+			require.Equal(t, 2, c.Len())
+			require.Len(t, evicted, 2)
+
+			c.SetMaxBytes(testEntrySize)
+			require.Equal(t, 1, c.Len())
+			require.Len(t, evicted, 3)
+
+			require.True(t, c.EvictOne())
+			require.False(t, c.EvictOne())
+			require.Equal(t, 0, c.Len())
+		})
+	}
+
+	_, err := NewEvictableCache(EvictionPolicyRandom, testEntrySize, nil)
+	require.Error(t, err)
+}
+
+func TestParseEvictionPolicy(t *testing.T) {
+	for p := EvictionPolicyLRU; p <= EvictionPolicyARC; p++ {
+		parsed, err := ParseEvictionPolicy(p.String())
+		require.NoError(t, err)
+		require.Equal(t, p, parsed)
+	}
+	_, err := ParseEvictionPolicy("fifo")
+	require.Error(t, err)
+}
+
+func TestARCEvictedCacheKeys(t *testing.T) {
+	c := NewARCEvictedCache(4 * testEntrySize)
+	lister, ok := c.(KeyLister)
+	require.True(t, ok)
+	require.Len(t, lister.Keys(), 0)
+
+	for i := 0; i < 4; i++ {
+		c.Add(testKey(i), testValue(8))
+	}
+	t.Log("Entries that are used again come first.")
+	_, ok = c.Get(testKey(1))
+	require.True(t, ok)
+	require.Equal(t, []Measurable{
+		testKey(1), testKey(3), testKey(2), testKey(0),
+	}, lister.Keys())
+
+	t.Log("Evicted entries are left out.")
+	c.Add(testKey(4), testValue(8))
+	require.Len(t, lister.Keys(), 4)
+	require.NotContains(t, lister.Keys(), Measurable(testKey(0)))
+}
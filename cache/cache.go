@@ -22,6 +22,31 @@ type Cache interface {
 	Add(key Measurable, data Measurable)
 }
 
+// EvictableCache is a Cache that also lets its owner remove entries
+// and trigger evictions itself, for owners that enforce limits of
+// their own on top of the byte capacity of the cache.
+type EvictableCache interface {
+	Cache
+	// Remove removes the data associated with key, if any.
+	Remove(key Measurable)
+	// EvictOne evicts the entry the eviction strategy would evict
+	// next.  It returns false if the cache was empty.
+	EvictOne() bool
+	// Len returns the number of entries in the cache.
+	Len() int
+	// SetMaxBytes changes the capacity of the cache, evicting
+	// entries if needed.
+	SetMaxBytes(maxBytes int)
+}
+
+// KeyLister is implemented by caches that can list the keys of their
+// entries, e.g. to save which entries are hot.
+type KeyLister interface {
+	// Keys returns the keys of all the entries in the cache, starting
+	// with the ones the cache would evict last.
+	Keys() []Measurable
+}
+
 type randomEvictedCache struct {
 	maxBytes int
 
@@ -106,6 +131,7 @@ type lruEvictedCache struct {
 	mu          sync.Mutex
 	cachedBytes int
 	data        *lru.Cache // not goroutine-safe; protected by mu
+	onEvicted   func(key Measurable, data Measurable)
 }
 
 // NewLRUEvictedCache returns a Cache that uses LRU eviction strategy.
@@ -119,17 +145,26 @@ type lruEvictedCache struct {
 // recalculating their size. It's fine if the size changes, but the cache
 // eviction will continue using the old size.
 func NewLRUEvictedCache(maxBytes int) Cache {
+	return newLRUEvictedCache(maxBytes, nil)
+}
+
+func newLRUEvictedCache(maxBytes int,
+	onEvicted func(key Measurable, data Measurable)) *lruEvictedCache {
 	c := &lruEvictedCache{
-		maxBytes: maxBytes,
+		maxBytes:  maxBytes,
+		onEvicted: onEvicted,
 	}
 	c.data = &lru.Cache{
 		OnEvicted: func(key lru.Key, value interface{}) {
 			// No locking is needed in this function because we do them in
-			// public methods Get/Add, and RemoveOldest() is only called in the
-			// Add method.
+			// the public methods, which are the only callers of
+			// Remove() and RemoveOldest().
 			if memoized, ok := value.(memoizedMeasurable); ok {
 				if k, ok := key.(Measurable); ok {
 					c.cachedBytes -= k.Size() + memoized.Size()
+					if c.onEvicted != nil {
+						c.onEvicted(k, memoized.m)
+					}
 				}
 			}
 		},
@@ -172,3 +207,38 @@ func (c *lruEvictedCache) Add(key Measurable, data Measurable) {
 	}
 	c.data.Add(lru.Key(key), memoized)
 }
+
+// Remove implements the EvictableCache interface.
+func (c *lruEvictedCache) Remove(key Measurable) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.data.Remove(lru.Key(key))
+}
+
+// EvictOne implements the EvictableCache interface.
+func (c *lruEvictedCache) EvictOne() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.data.Len() == 0 {
+		return false
+	}
+	c.data.RemoveOldest()
+	return true
+}
+
+// Len implements the EvictableCache interface.
+func (c *lruEvictedCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.data.Len()
+}
+
+// SetMaxBytes implements the EvictableCache interface.
+func (c *lruEvictedCache) SetMaxBytes(maxBytes int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxBytes = maxBytes
+	for c.cachedBytes > c.maxBytes {
+		c.data.RemoveOldest()
+	}
+}
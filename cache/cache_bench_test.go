@@ -0,0 +1,92 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"math/rand"
+	"testing"
+)
+
+const benchCacheEntries = 1000
+
+// benchWorkload returns the key for the i'th access of a workload.
+type benchWorkload func(r *rand.Rand, i int) testKey
+
+// scanWithHotSet models reading a big build tree once, while a small
+// set of config files gets read over and over again: every other
+// access goes to a hot set that fits easily in the cache, and the
+// rest are a scan of keys that are never seen again.
+func scanWithHotSet(r *rand.Rand, i int) testKey {
+	if i%2 == 0 {
+		return testKey(r.Intn(benchCacheEntries / 4))
+	}
+	return testKey(benchCacheEntries + i)
+}
+
+// zipfWorkload returns a workload where key popularity follows a
+// Zipf distribution over ten times as many keys as fit in the cache.
+func zipfWorkload(r *rand.Rand) benchWorkload {
+	z := rand.NewZipf(r, 1.1, 1, 10*benchCacheEntries)
+	return func(_ *rand.Rand, _ int) testKey {
+		return testKey(z.Uint64())
+	}
+}
+
+// loopWorkload repeatedly loops over slightly more keys than fit in
+// the cache, the worst case for LRU.
+func loopWorkload(_ *rand.Rand, i int) testKey {
+	return testKey(i % (benchCacheEntries + benchCacheEntries/10))
+}
+
+func benchmarkCache(
+	b *testing.B, policy EvictionPolicy, workload benchWorkload) {
+	c, err := NewCache(policy, benchCacheEntries*testEntrySize)
+	if err != nil {
+		b.Fatal(err)
+	}
+	r := rand.New(rand.NewSource(1))
+	hits := 0
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		key := workload(r, i)
+		if _, ok := c.Get(key); ok {
+			hits++
+			continue
+		}
+		c.Add(key, testValue(8))
+	}
+	// Log the hit rate rather than reporting it as a custom metric,
+	// since b.ReportMetric isn't available in the Go version CI uses.
+	b.StopTimer()
+	b.Logf("%s: %d/%d hits (%.3f hits/op)",
+		policy, hits, b.N, float64(hits)/float64(b.N))
+}
+
+func benchmarkPolicies(b *testing.B, makeWorkload func(
+	r *rand.Rand) benchWorkload) {
+	for _, policy := range []EvictionPolicy{
+		EvictionPolicyLRU, EvictionPolicyRandom, EvictionPolicyARC} {
+		b.Run(policy.String(), func(b *testing.B) {
+			benchmarkCache(
+				b, policy, makeWorkload(rand.New(rand.NewSource(1))))
+		})
+	}
+}
+
+func BenchmarkCacheScanWithHotSet(b *testing.B) {
+	benchmarkPolicies(b, func(*rand.Rand) benchWorkload {
+		return scanWithHotSet
+	})
+}
+
+func BenchmarkCacheZipf(b *testing.B) {
+	benchmarkPolicies(b, zipfWorkload)
+}
+
+func BenchmarkCacheLoop(b *testing.B) {
+	benchmarkPolicies(b, func(*rand.Rand) benchWorkload {
+		return loopWorkload
+	})
+}
@@ -0,0 +1,78 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package cache
+
+import "fmt"
+
+// EvictionPolicy picks the strategy a Cache uses to decide which
+// entries to evict when it's full.
+type EvictionPolicy int
+
+const (
+	// EvictionPolicyLRU evicts the least recently used entry.
+	EvictionPolicyLRU EvictionPolicy = iota
+	// EvictionPolicyRandom evicts a random entry.
+	EvictionPolicyRandom
+	// EvictionPolicyARC uses the Adaptive Replacement Cache strategy,
+	// which resists being flushed by one-time scans.
+	EvictionPolicyARC
+)
+
+func (p EvictionPolicy) String() string {
+	switch p {
+	case EvictionPolicyLRU:
+		return "lru"
+	case EvictionPolicyRandom:
+		return "random"
+	case EvictionPolicyARC:
+		return "arc"
+	default:
+		return fmt.Sprintf("EvictionPolicy(%d)", int(p))
+	}
+}
+
+// ParseEvictionPolicy parses the string form of an EvictionPolicy,
+// as returned by its String method.
+func ParseEvictionPolicy(s string) (EvictionPolicy, error) {
+	for p := EvictionPolicyLRU; p <= EvictionPolicyARC; p++ {
+		if p.String() == s {
+			return p, nil
+		}
+	}
+	return EvictionPolicyLRU, fmt.Errorf("unknown eviction policy %q", s)
+}
+
+// NewCache returns a Cache with a capacity of maxBytes bytes, using
+// the given eviction policy.
+func NewCache(policy EvictionPolicy, maxBytes int) (Cache, error) {
+	switch policy {
+	case EvictionPolicyLRU:
+		return NewLRUEvictedCache(maxBytes), nil
+	case EvictionPolicyRandom:
+		return NewRandomEvictedCache(maxBytes), nil
+	case EvictionPolicyARC:
+		return NewARCEvictedCache(maxBytes), nil
+	default:
+		return nil, fmt.Errorf("unknown eviction policy %s", policy)
+	}
+}
+
+// NewEvictableCache returns an EvictableCache with a capacity of
+// maxBytes bytes, using the given eviction policy.  If onEvicted is
+// non-nil, it's called (with the cache locked) for every entry that
+// leaves the cache, whether it was evicted or removed.  Random
+// eviction isn't supported.
+func NewEvictableCache(policy EvictionPolicy, maxBytes int,
+	onEvicted func(key Measurable, data Measurable)) (EvictableCache, error) {
+	switch policy {
+	case EvictionPolicyLRU:
+		return newLRUEvictedCache(maxBytes, onEvicted), nil
+	case EvictionPolicyARC:
+		return newARCEvictedCache(maxBytes, onEvicted), nil
+	default:
+		return nil, fmt.Errorf(
+			"eviction policy %s doesn't support explicit eviction", policy)
+	}
+}
@@ -618,6 +618,28 @@ func MakeBlockCryptKey(data [32]byte) BlockCryptKey {
 	return BlockCryptKey{privateByte32Container{data}}
 }
 
+// JournalCryptKey is used to encrypt/decrypt the metadata files of
+// the local journals of a device, which are never sent to the
+// server.
+//
+// Copies of JournalCryptKey objects are deep copies.
+type JournalCryptKey struct {
+	// Should only be used by implementations of Crypto.
+	privateByte32Container
+}
+
+var _ encoding.BinaryMarshaler = JournalCryptKey{}
+var _ encoding.BinaryUnmarshaler = (*JournalCryptKey)(nil)
+
+var _ encoding.TextMarshaler = JournalCryptKey{}
+var _ encoding.TextUnmarshaler = (*JournalCryptKey)(nil)
+
+// MakeJournalCryptKey returns a JournalCryptKey containing the given
+// data.
+func MakeJournalCryptKey(data [32]byte) JournalCryptKey {
+	return JournalCryptKey{privateByte32Container{data}}
+}
+
 func xorKeys(x, y [32]byte) [32]byte {
 	var res [32]byte
 	for i := 0; i < 32; i++ {
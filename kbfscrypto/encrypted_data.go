@@ -189,6 +189,32 @@ func DecryptBlock(encryptedBlock EncryptedBlock, key BlockCryptKey) (
 	return decryptData(encryptedBlock.encryptedData, key.Data())
 }
 
+// EncryptedJournalData is the encrypted contents of a journal
+// metadata file.
+type EncryptedJournalData struct {
+	encryptedData
+}
+
+// EncryptJournalData encrypts the contents of a journal metadata
+// file.
+func EncryptJournalData(data []byte, key JournalCryptKey) (
+	encryptedJournalData EncryptedJournalData, err error) {
+	encryptedData, err := encryptData(data, key.Data())
+	if err != nil {
+		return EncryptedJournalData{}, err
+	}
+
+	return EncryptedJournalData{encryptedData}, nil
+}
+
+// DecryptJournalData decrypts the contents of a journal metadata
+// file.
+func DecryptJournalData(
+	encryptedJournalData EncryptedJournalData, key JournalCryptKey) (
+	[]byte, error) {
+	return decryptData(encryptedJournalData.encryptedData, key.Data())
+}
+
 // EncryptedTLFCryptKeys is an encrypted TLFCryptKey array.
 type EncryptedTLFCryptKeys struct {
 	encryptedData
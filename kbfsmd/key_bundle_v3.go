@@ -12,6 +12,7 @@ import (
 	"github.com/keybase/client/go/protocol/keybase1"
 	"github.com/keybase/go-codec/codec"
 	"github.com/keybase/kbfs/cache"
+	"github.com/keybase/kbfs/ioutil"
 	"github.com/keybase/kbfs/kbfscodec"
 	"github.com/keybase/kbfs/kbfscrypto"
 	"github.com/keybase/kbfs/kbfshash"
@@ -252,15 +253,26 @@ type TLFWriterKeyBundleV3 struct {
 // DeserializeTLFWriterKeyBundleV3 deserializes a TLFWriterKeyBundleV3
 // from the given path and returns it.
 func DeserializeTLFWriterKeyBundleV3(codec kbfscodec.Codec, path string) (
+	TLFWriterKeyBundleV3, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return TLFWriterKeyBundleV3{}, err
+	}
+	return DecodeTLFWriterKeyBundleV3(codec, buf)
+}
+
+// DecodeTLFWriterKeyBundleV3 decodes a TLFWriterKeyBundleV3 from the
+// given buffer and returns it.
+func DecodeTLFWriterKeyBundleV3(codec kbfscodec.Codec, buf []byte) (
 	TLFWriterKeyBundleV3, error) {
 	var wkb TLFWriterKeyBundleV3
-	err := kbfscodec.DeserializeFromFile(codec, path, &wkb)
+	err := codec.Decode(buf, &wkb)
 	if err != nil {
 		return TLFWriterKeyBundleV3{}, err
 	}
 	if len(wkb.Keys) == 0 {
 		return TLFWriterKeyBundleV3{}, errors.New(
-			"Writer key bundle with no keys (DeserializeTLFWriterKeyBundleV3)")
+			"Writer key bundle with no keys (DecodeTLFWriterKeyBundleV3)")
 	}
 	return wkb, nil
 }
@@ -413,9 +425,20 @@ type TLFReaderKeyBundleV3 struct {
 // DeserializeTLFReaderKeyBundleV3 deserializes a TLFReaderKeyBundleV3
 // from the given path and returns it.
 func DeserializeTLFReaderKeyBundleV3(codec kbfscodec.Codec, path string) (
+	TLFReaderKeyBundleV3, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return TLFReaderKeyBundleV3{}, err
+	}
+	return DecodeTLFReaderKeyBundleV3(codec, buf)
+}
+
+// DecodeTLFReaderKeyBundleV3 decodes a TLFReaderKeyBundleV3 from the
+// given buffer and returns it.
+func DecodeTLFReaderKeyBundleV3(codec kbfscodec.Codec, buf []byte) (
 	TLFReaderKeyBundleV3, error) {
 	var rkb TLFReaderKeyBundleV3
-	err := kbfscodec.DeserializeFromFile(codec, path, &rkb)
+	err := codec.Decode(buf, &rkb)
 	if err != nil {
 		return TLFReaderKeyBundleV3{}, err
 	}
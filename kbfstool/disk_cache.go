@@ -0,0 +1,306 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"syscall"
+
+	"github.com/keybase/kbfs/env"
+	"github.com/keybase/kbfs/libfs"
+	"github.com/keybase/kbfs/libkbfs"
+	"github.com/keybase/kbfs/tlf"
+	"golang.org/x/net/context"
+)
+
+const diskCacheUsageStr = `Usage:
+  kbfstool disk-cache [<subcommand>] [<args>]
+
+The possible subcommands are:
+  status	Show the usage of the disk caches of a running KBFS
+  get		Show the per-TLF settings of the disk block cache
+  set		Change the per-TLF settings of the disk block cache
+  serve		Serve the disk block cache to other KBFS processes
+
+Changes made with "set" take effect the next time KBFS starts.
+`
+
+const diskCacheStatusUsageStr = `Usage:
+  kbfstool disk-cache status [-mount dir]
+
+`
+
+const diskCacheGetUsageStr = `Usage:
+  kbfstool disk-cache get [<tlf>...]
+
+With no arguments, shows the settings of all TLFs that have any.
+Each <tlf> can be a TLF ID or a path like /keybase/private/alice.
+`
+
+const diskCacheSetUsageStr = `Usage:
+  kbfstool disk-cache set [-priority p] [-max-bytes n] <tlf>
+
+-priority is one of default, other, favorite or synced.  -max-bytes
+caps the bytes the TLF can take up in each cache; 0 means no cap.
+Setting the defaults for both removes the settings of the TLF.
+
+`
+
+const diskCacheServeUsageStr = `Usage:
+  kbfstool disk-cache serve [-socket-mode mode] [-trust-clients] <socket>
+
+Serves the local disk block cache on the unix socket <socket> until
+interrupted.  Other KBFS processes on this host can use it by running
+with -disk-cache-mode=remote -disk-cache-socket=<socket>.  Only
+processes allowed by -socket-mode (and by the permissions of the
+directory containing the socket) can connect.  If -socket-mode lets
+other users connect, they can only read the cache, unless
+-trust-clients is given.
+
+`
+
+func diskCacheMain(ctx context.Context, kbCtx libkbfs.Context,
+	config libkbfs.Config, args []string) (exitStatus int) {
+	if len(args) < 1 {
+		fmt.Print(diskCacheUsageStr)
+		return 1
+	}
+
+	cmd := args[0]
+	args = args[1:]
+
+	switch cmd {
+	case "status":
+		return diskCacheStatus(args)
+	case "get":
+		return diskCacheGet(ctx, config, args)
+	case "set":
+		return diskCacheSet(ctx, config, args)
+	case "serve":
+		return diskCacheServe(kbCtx, config, args)
+	default:
+		printError("disk-cache", fmt.Errorf("unknown command %q", cmd))
+		return 1
+	}
+}
+
+func printDiskCacheTlfSettings(
+	tlfID tlf.ID, settings libkbfs.DiskBlockCacheTlfSettings) {
+	fmt.Printf("%s: priority=%s max-bytes=%d\n",
+		tlfID, settings.Priority, settings.MaxBytes)
+}
+
+func diskCacheStatus(args []string) (exitStatus int) {
+	flags := flag.NewFlagSet("kbfs disk-cache status", flag.ContinueOnError)
+	mountDir := flags.String("mount", "",
+		"The mount point of the running KBFS; defaults to the configured one")
+	err := flags.Parse(args)
+	if err != nil {
+		printError("disk-cache status", err)
+		return 1
+	}
+	if len(flags.Args()) != 0 {
+		fmt.Print(diskCacheStatusUsageStr)
+		flags.PrintDefaults()
+		return 1
+	}
+
+	if *mountDir == "" {
+		*mountDir, err = env.NewContext().GetMountDir()
+		if err != nil {
+			printError("disk-cache status", err)
+			return 1
+		}
+	}
+
+	// Ask the running KBFS, since this process has its disk cache
+	// turned off.
+	buf, err := ioutil.ReadFile(
+		filepath.Join(*mountDir, libfs.StatusFileName))
+	if err != nil {
+		printError("disk-cache status", err)
+		return 1
+	}
+	var status libkbfs.KBFSStatus
+	err = json.Unmarshal(buf, &status)
+	if err != nil {
+		printError("disk-cache status", err)
+		return 1
+	}
+
+	cacheNames := make([]string, 0, len(status.DiskCacheStatus))
+	for name := range status.DiskCacheStatus {
+		cacheNames = append(cacheNames, name)
+	}
+	sort.Strings(cacheNames)
+	for _, name := range cacheNames {
+		cacheStatus := status.DiskCacheStatus[name]
+		fmt.Printf("%s: %d blocks, %d of %d bytes\n", name,
+			cacheStatus.NumBlocks, cacheStatus.BlockBytes,
+			cacheStatus.CurrByteLimit)
+		tlfIDs := make([]string, 0, len(cacheStatus.TLFs))
+		for tlfID := range cacheStatus.TLFs {
+			tlfIDs = append(tlfIDs, tlfID)
+		}
+		sort.Strings(tlfIDs)
+		for _, tlfID := range tlfIDs {
+			tlfStatus := cacheStatus.TLFs[tlfID]
+			fmt.Printf("  %s: priority=%s, %d blocks, %d bytes",
+				tlfID, tlfStatus.Priority, tlfStatus.NumBlocks,
+				tlfStatus.BlockBytes)
+			if tlfStatus.ByteLimit > 0 {
+				fmt.Printf(" (max %d)", tlfStatus.ByteLimit)
+			}
+			fmt.Printf("\n")
+		}
+	}
+	return 0
+}
+
+func diskCacheGet(ctx context.Context, config libkbfs.Config,
+	args []string) (exitStatus int) {
+	flags := flag.NewFlagSet("kbfs disk-cache get", flag.ContinueOnError)
+	err := flags.Parse(args)
+	if err != nil {
+		printError("disk-cache get", err)
+		return 1
+	}
+
+	allSettings, err := libkbfs.LoadDiskBlockCacheTlfSettings(
+		config.Codec(), config.StorageRoot())
+	if err != nil {
+		printError("disk-cache get", err)
+		return 1
+	}
+
+	if len(flags.Args()) == 0 {
+		tlfIDs := make([]tlf.ID, 0, len(allSettings))
+		for tlfID := range allSettings {
+			tlfIDs = append(tlfIDs, tlfID)
+		}
+		sort.Slice(tlfIDs, func(i, j int) bool {
+			return tlfIDs[i].String() < tlfIDs[j].String()
+		})
+		for _, tlfID := range tlfIDs {
+			printDiskCacheTlfSettings(tlfID, allSettings[tlfID])
+		}
+		return 0
+	}
+
+	for _, tlfStr := range flags.Args() {
+		tlfID, err := getTlfID(ctx, config, tlfStr)
+		if err != nil {
+			printError("disk-cache get", err)
+			return 1
+		}
+		printDiskCacheTlfSettings(tlfID, allSettings[tlfID])
+	}
+	return 0
+}
+
+func diskCacheSet(ctx context.Context, config libkbfs.Config,
+	args []string) (exitStatus int) {
+	flags := flag.NewFlagSet("kbfs disk-cache set", flag.ContinueOnError)
+	priorityStr := flags.String("priority", "default",
+		"The priority class of the TLF")
+	maxBytes := flags.Uint64("max-bytes", 0,
+		"The maximum number of bytes the TLF can use in each cache")
+	err := flags.Parse(args)
+	if err != nil {
+		printError("disk-cache set", err)
+		return 1
+	}
+
+	inputs := flags.Args()
+	if len(inputs) != 1 {
+		fmt.Print(diskCacheSetUsageStr)
+		flags.PrintDefaults()
+		return 1
+	}
+
+	priority, err := libkbfs.ParseDiskBlockCachePriority(*priorityStr)
+	if err != nil {
+		printError("disk-cache set", err)
+		return 1
+	}
+
+	tlfID, err := getTlfID(ctx, config, inputs[0])
+	if err != nil {
+		printError("disk-cache set", err)
+		return 1
+	}
+
+	settings := libkbfs.DiskBlockCacheTlfSettings{
+		Priority: priority,
+		MaxBytes: *maxBytes,
+	}
+	err = libkbfs.StoreDiskBlockCacheTlfSettings(
+		config.Codec(), config.StorageRoot(), tlfID, settings)
+	if err != nil {
+		printError("disk-cache set", err)
+		return 1
+	}
+	printDiskCacheTlfSettings(tlfID, settings)
+	return 0
+}
+
+func diskCacheServe(kbCtx libkbfs.Context, config libkbfs.Config,
+	args []string) (exitStatus int) {
+	flags := flag.NewFlagSet("kbfs disk-cache serve", flag.ContinueOnError)
+	socketMode := flags.String("socket-mode",
+		fmt.Sprintf("%04o", libkbfs.DefaultDiskBlockCacheSocketMode),
+		"The octal file mode of the socket, e.g. 0660 to share the "+
+			"cache with the socket's group")
+	trustClients := flags.Bool("trust-clients", false,
+		"Let clients write to the cache even if the socket is open to "+
+			"other users")
+	err := flags.Parse(args)
+	if err != nil {
+		printError("disk-cache serve", err)
+		return 1
+	}
+
+	inputs := flags.Args()
+	if len(inputs) != 1 {
+		fmt.Print(diskCacheServeUsageStr)
+		flags.PrintDefaults()
+		return 1
+	}
+
+	mode, err := strconv.ParseUint(*socketMode, 8, 32)
+	if err != nil {
+		printError("disk-cache serve", err)
+		return 1
+	}
+
+	if config.DiskBlockCache() == nil {
+		printError("disk-cache serve",
+			fmt.Errorf("the local disk block cache couldn't be initialized"))
+		return 1
+	}
+
+	server, err := libkbfs.NewDiskBlockCacheServer(
+		config, kbCtx.NewRPCLogFactory(), inputs[0], os.FileMode(mode),
+		*trustClients)
+	if err != nil {
+		printError("disk-cache serve", err)
+		return 1
+	}
+	defer server.Shutdown()
+
+	fmt.Printf("Serving the disk block cache on %s\n", server.SocketPath())
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	<-sigCh
+	return 0
+}
@@ -0,0 +1,150 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/keybase/kbfs/libkbfs"
+	"golang.org/x/net/context"
+)
+
+const journalUsageStr = `Usage:
+  kbfstool journal [<subcommand>] [<args>]
+
+The possible subcommands are:
+  export	Write the unflushed changes of a TLF to an archive
+  import	Replay an archive from another device into the journal
+
+KBFS must not be running on this device while using these commands.
+`
+
+const journalExportUsageStr = `Usage:
+  kbfstool journal export <tlf> <file>
+
+Writes the unflushed revisions in the journal of <tlf>, along with the
+blocks they need, to <file> as an archive signed by this device.
+<tlf> can be a TLF ID or a path like /keybase/private/alice.
+
+`
+
+const journalImportUsageStr = `Usage:
+  kbfstool journal import <file>
+
+Replays an archive written by "journal export" on another device of
+the same user into the journal of this device.  The revisions in the
+archive must continue from the current head of the TLF on the server,
+and this device must not have unflushed changes of its own for the
+TLF.  The imported changes are flushed the next time KBFS runs.
+
+`
+
+func journalMain(ctx context.Context, config libkbfs.Config,
+	args []string) (exitStatus int) {
+	if len(args) < 1 {
+		fmt.Print(journalUsageStr)
+		return 1
+	}
+
+	cmd := args[0]
+	args = args[1:]
+
+	switch cmd {
+	case "export":
+		return journalExport(ctx, config, args)
+	case "import":
+		return journalImport(ctx, config, args)
+	default:
+		printError("journal", fmt.Errorf("unknown command %q", cmd))
+		return 1
+	}
+}
+
+func journalExport(ctx context.Context, config libkbfs.Config,
+	args []string) (exitStatus int) {
+	flags := flag.NewFlagSet("kbfs journal export", flag.ContinueOnError)
+	err := flags.Parse(args)
+	if err != nil {
+		printError("journal export", err)
+		return 1
+	}
+	if len(flags.Args()) != 2 {
+		fmt.Print(journalExportUsageStr)
+		return 1
+	}
+
+	jServer, err := libkbfs.GetJournalServer(config)
+	if err != nil {
+		printError("journal export", err)
+		return 1
+	}
+	tlfID, err := getTlfID(ctx, config, flags.Arg(0))
+	if err != nil {
+		printError("journal export", err)
+		return 1
+	}
+
+	f, err := os.Create(flags.Arg(1))
+	if err != nil {
+		printError("journal export", err)
+		return 1
+	}
+	numExported, err := jServer.ExportTLFJournal(ctx, tlfID, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		printError("journal export", err)
+		return 1
+	}
+
+	fmt.Printf("Exported %d revisions of %s to %s\n",
+		numExported, tlfID, flags.Arg(1))
+	return 0
+}
+
+func journalImport(ctx context.Context, config libkbfs.Config,
+	args []string) (exitStatus int) {
+	flags := flag.NewFlagSet("kbfs journal import", flag.ContinueOnError)
+	err := flags.Parse(args)
+	if err != nil {
+		printError("journal import", err)
+		return 1
+	}
+	if len(flags.Args()) != 1 {
+		fmt.Print(journalImportUsageStr)
+		return 1
+	}
+
+	jServer, err := libkbfs.GetJournalServer(config)
+	if err != nil {
+		printError("journal import", err)
+		return 1
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		printError("journal import", err)
+		return 1
+	}
+	defer f.Close()
+	tlfID, numImported, err := jServer.ImportTLFJournal(
+		ctx, f, libkbfs.TLFJournalBackgroundWorkPaused)
+	if err != nil {
+		printError("journal import", err)
+		return 1
+	}
+
+	if numImported == 0 {
+		fmt.Printf("All revisions of %s in %s were already imported\n",
+			tlfID, flags.Arg(0))
+		return 0
+	}
+	fmt.Printf("Imported %d revisions of %s; they will be flushed "+
+		"the next time KBFS runs\n", numImported, tlfID)
+	return 0
+}
@@ -42,6 +42,9 @@ The possible commands are:
   write		Write stdin to file
   md            Operate on metadata objects
   git           Operate on git repositories
+  disk-cache    Inspect and tune the disk block cache
+  journal       Move unflushed changes between devices
+  storage       Show what's using local disk space
 
 `
 
@@ -75,6 +78,20 @@ func realMain() (exitStatus int) {
 	// Turn these off to not interfere with a running kbfs daemon.
 	kbfsParams.EnableJournal = false
 	kbfsParams.DiskCacheMode = libkbfs.DiskCacheModeOff
+	if flag.Arg(0) == "disk-cache" && flag.Arg(1) == "serve" {
+		// The cache daemon owns its local disk cache, but shouldn't
+		// take over the KBFS socket from a running kbfs daemon.
+		kbfsParams.DiskCacheMode = libkbfs.DiskCacheModeLocal
+		kbfsParams.Mode = libkbfs.InitMinimalString
+	}
+	if flag.Arg(0) == "journal" {
+		// The journal commands work on the journal of this device,
+		// so KBFS must not be running.  Don't flush anything here;
+		// that's left to the next run of KBFS.
+		kbfsParams.EnableJournal = true
+		kbfsParams.TLFJournalBackgroundWorkStatus =
+			libkbfs.TLFJournalBackgroundWorkPaused
+	}
 
 	ctx := context.Background()
 	config, err := libkbfs.Init(ctx, kbCtx, *kbfsParams, nil, nil, log)
@@ -107,6 +124,12 @@ func realMain() (exitStatus int) {
 		return mdMain(ctx, config, args)
 	case "git":
 		return gitMain(ctx, config, args)
+	case "disk-cache":
+		return diskCacheMain(ctx, kbCtx, config, args)
+	case "journal":
+		return journalMain(ctx, config, args)
+	case "storage":
+		return storage(args)
 	default:
 		printError("kbfs", fmt.Errorf("unknown command %q", cmd))
 		return 1
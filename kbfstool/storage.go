@@ -0,0 +1,152 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+
+	"github.com/keybase/kbfs/env"
+	"github.com/keybase/kbfs/libfs"
+	"github.com/keybase/kbfs/libkbfs"
+)
+
+const storageUsageStr = `Usage:
+  kbfstool storage [-mount dir] [-json]
+
+Shows what's using local disk space in a running KBFS: the journal of
+each TLF, the disk block caches, and how close they are to the limits
+at which KBFS starts slowing down writes.
+
+`
+
+func printStorageThresholds(
+	name string, thresholds libkbfs.LocalStorageThresholds) {
+	fmt.Printf("  %s: %d of %d (%.1f%%), backpressure from %.0f%% to %.0f%%\n",
+		name, thresholds.Used, thresholds.Limit, 100*thresholds.UsedFrac,
+		100*thresholds.MinThreshold, 100*thresholds.MaxThreshold)
+}
+
+func printStorageStatus(status libkbfs.LocalStorageStatus) {
+	fmt.Printf("Storage root: %s\n", status.StorageRoot)
+	if status.FreeBytes >= 0 {
+		fmt.Printf("Free: %d bytes, %d files\n",
+			status.FreeBytes, status.FreeFiles)
+	}
+	fmt.Printf("Used: %d bytes\n", status.UsedBytes)
+	fmt.Printf("  journals: %d bytes in %d files (%d bytes unflushed)\n",
+		status.JournalBytes, status.JournalFiles,
+		status.JournalUnflushedBytes)
+	fmt.Printf("  disk caches: %d bytes\n", status.CacheBytes)
+
+	cacheNames := make([]string, 0, len(status.DiskCaches))
+	for name := range status.DiskCaches {
+		cacheNames = append(cacheNames, name)
+	}
+	sort.Strings(cacheNames)
+	for _, name := range cacheNames {
+		cacheStatus := status.DiskCaches[name]
+		if cacheStatus.IsStarting {
+			fmt.Printf("    %s: starting\n", name)
+			continue
+		}
+		fmt.Printf("    %s: %d blocks, %d of %d bytes\n", name,
+			cacheStatus.NumBlocks, cacheStatus.BlockBytes,
+			cacheStatus.ByteLimit)
+	}
+
+	if len(status.TLFs) > 0 {
+		fmt.Printf("TLFs:\n")
+	}
+	for _, tlfStatus := range status.TLFs {
+		fmt.Printf("  %s: %d bytes", tlfStatus.TlfID, tlfStatus.TotalBytes)
+		if tlfStatus.JournalBytes > 0 {
+			fmt.Printf(", journal %d (%d unflushed)",
+				tlfStatus.JournalBytes, tlfStatus.JournalUnflushedBytes)
+		}
+		names := make([]string, 0, len(tlfStatus.CacheBytes))
+		for name := range tlfStatus.CacheBytes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf(", %s %d", name, tlfStatus.CacheBytes[name])
+		}
+		fmt.Printf("\n")
+	}
+
+	if status.Limiter != nil {
+		fmt.Printf("Disk limiter: current delay %.3fs\n",
+			status.Limiter.CurrentDelaySec)
+		printStorageThresholds("journal bytes", status.Limiter.JournalBytes)
+		printStorageThresholds("journal files", status.Limiter.JournalFiles)
+		printStorageThresholds(
+			"working set cache bytes", status.Limiter.WorkingSetCacheBytes)
+		printStorageThresholds(
+			"sync cache bytes", status.Limiter.SyncCacheBytes)
+	}
+}
+
+func storage(args []string) (exitStatus int) {
+	flags := flag.NewFlagSet("kbfs storage", flag.ContinueOnError)
+	mountDir := flags.String("mount", "",
+		"The mount point of the running KBFS; defaults to the configured one")
+	printJSON := flags.Bool("json", false, "Print the status as JSON")
+	err := flags.Parse(args)
+	if err != nil {
+		printError("storage", err)
+		return 1
+	}
+	if len(flags.Args()) != 0 {
+		fmt.Print(storageUsageStr)
+		flags.PrintDefaults()
+		return 1
+	}
+
+	if *mountDir == "" {
+		*mountDir, err = env.NewContext().GetMountDir()
+		if err != nil {
+			printError("storage", err)
+			return 1
+		}
+	}
+
+	// Ask the running KBFS, since this process has its journal and
+	// disk cache turned off.
+	buf, err := ioutil.ReadFile(
+		filepath.Join(*mountDir, libfs.StatusFileName))
+	if err != nil {
+		printError("storage", err)
+		return 1
+	}
+	var status libkbfs.KBFSStatus
+	err = json.Unmarshal(buf, &status)
+	if err != nil {
+		printError("storage", err)
+		return 1
+	}
+	if status.LocalStorage == nil {
+		printError("storage",
+			fmt.Errorf("the running KBFS doesn't report its local storage"))
+		return 1
+	}
+
+	if *printJSON {
+		buf, err := json.MarshalIndent(status.LocalStorage, "", "  ")
+		if err != nil {
+			printError("storage", err)
+			return 1
+		}
+		fmt.Printf("%s\n", buf)
+		return 0
+	}
+
+	printStorageStatus(*status.LocalStorage)
+	return 0
+}
@@ -62,6 +62,31 @@ var aclRemoveCmd = cli.Command{
 	},
 }
 
+var aclRemoveGroupCmd = cli.Command{
+	Name:      "remove-group",
+	Usage:     "remove a group from the ACL(s) of the given path(s)",
+	UsageText: "remove-group <group> <path> [path ...]",
+	Action: func(c *cli.Context) {
+		if len(c.Args()) < 2 {
+			fmt.Fprintln(os.Stderr, "need at least 2 args")
+			os.Exit(1)
+		}
+		editor, err := newKBPConfigEditor(c.GlobalString("dir"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr,
+				"creating config editor error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, p := range c.Args()[1:] {
+			editor.removeGroupFromACL(c.Args()[0], p)
+		}
+		if err := editor.confirmAndWrite(); err != nil {
+			fmt.Fprintf(os.Stderr, "writing new config error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
 var aclGetCmd = cli.Command{
 	Name:      "get",
 	Usage:     "get permissions for a user on the given path(s)",
@@ -160,24 +185,60 @@ var aclSetAdditionalCmd = cli.Command{
 	},
 }
 
+var aclSetGroupCmd = cli.Command{
+	Name: "group",
+	Usage: "set additional permission(s) that members of <group> are " +
+		"granted on top of default ones on the given path(s) ",
+	UsageText: "group <group> <read|list|read,list> <path> [path ...]",
+	Action: func(c *cli.Context) {
+		if len(c.Args()) < 3 {
+			fmt.Fprintln(os.Stderr, "need at least 3 args")
+			os.Exit(1)
+		}
+		editor, err := newKBPConfigEditor(c.GlobalString("dir"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr,
+				"creating config editor error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, p := range c.Args()[2:] {
+			err := editor.setGroupAdditionalPermission(
+				c.Args()[0], c.Args()[1], p)
+			if err != nil {
+				fmt.Fprintf(os.Stderr,
+					"setting additional permission(s) %q for group "+
+						"%q on %q error: %v\n",
+					c.Args()[1], c.Args()[0], p, err)
+				os.Exit(1)
+			}
+		}
+		if err := editor.confirmAndWrite(); err != nil {
+			fmt.Fprintf(os.Stderr, "writing new config error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
 var aclSetCmd = cli.Command{
 	Name:      "set",
-	Usage:     "set default or additional permissions on path(s)",
-	UsageText: "set <default|additional> [args]",
+	Usage:     "set default, additional, or group permissions on path(s)",
+	UsageText: "set <default|additional|group> [args]",
 	Subcommands: []cli.Command{
 		aclSetDefaultCmd,
 		aclSetAdditionalCmd,
+		aclSetGroupCmd,
 	},
 }
 
 var aclCmd = cli.Command{
 	Name:      "acl",
 	Usage:     "make changes to the 'acls' section of the config",
-	UsageText: "acl <set|clear|remove|get> [args]",
+	UsageText: "acl <set|clear|remove|remove-group|get> [args]",
 	Subcommands: []cli.Command{
 		aclSetCmd,
 		aclClearCmd,
 		aclRemoveCmd,
+		aclRemoveGroupCmd,
 		aclGetCmd,
 	},
 }
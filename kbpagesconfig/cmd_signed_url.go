@@ -0,0 +1,83 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+const defaultSignedURLExpiresIn = 7 * 24 * time.Hour
+
+var signedURLMintCmd = cli.Command{
+	Name: "mint",
+	Usage: "mint a signed URL that authenticates as <username> for " +
+		"the given path(s)",
+	UsageText: "mint [--expires-in <duration>] <username> <path> [path ...]",
+	Flags: []cli.Flag{
+		cli.DurationFlag{
+			Name:  "expires-in",
+			Value: defaultSignedURLExpiresIn,
+			Usage: "how long the signed URL is valid for",
+		},
+	},
+	Action: func(c *cli.Context) {
+		if len(c.Args()) < 2 {
+			fmt.Fprintln(os.Stderr, "need at least 2 args")
+			os.Exit(1)
+		}
+		editor, err := newKBPConfigEditor(c.GlobalString("dir"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr,
+				"creating config editor error: %v\n", err)
+			os.Exit(1)
+		}
+		signedURLs, err := editor.mintSignedURLs(c.Args()[1:], c.Args()[0],
+			time.Now().Add(c.Duration("expires-in")))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		for _, signedURL := range signedURLs {
+			fmt.Println(signedURL)
+		}
+	},
+}
+
+var signedURLRevokeCmd = cli.Command{
+	Name:      "revoke",
+	Usage:     "revoke all signed URLs by rotating the signing secret",
+	UsageText: "revoke",
+	Action: func(c *cli.Context) {
+		editor, err := newKBPConfigEditor(c.GlobalString("dir"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr,
+				"creating config editor error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := editor.rotateSigningSecret(); err != nil {
+			fmt.Fprintf(os.Stderr,
+				"generating signing secret error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := editor.confirmAndWrite(); err != nil {
+			fmt.Fprintf(os.Stderr, "writing new config error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var signedURLCmd = cli.Command{
+	Name:      "signed-url",
+	Usage:     "mint or revoke time-limited signed URLs",
+	UsageText: "signed-url <mint|revoke> [args]",
+	Subcommands: []cli.Command{
+		signedURLMintCmd,
+		signedURLRevokeCmd,
+	},
+}
@@ -0,0 +1,127 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+var tokenMintCmd = cli.Command{
+	Name:      "mint",
+	Usage:     "mint a new bearer token for a user",
+	UsageText: "mint [--expires-in <duration>] <username>",
+	Flags: []cli.Flag{
+		cli.DurationFlag{
+			Name:  "expires-in",
+			Usage: "how long the token is valid for; 0 means never expires",
+		},
+	},
+	Action: func(c *cli.Context) {
+		if len(c.Args()) != 1 {
+			fmt.Fprintln(os.Stderr, "need exactly 1 arg")
+			os.Exit(1)
+		}
+		editor, err := newKBPConfigEditor(c.GlobalString("dir"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr,
+				"creating config editor error: %v\n", err)
+			os.Exit(1)
+		}
+		var expiresAt time.Time
+		if expiresIn := c.Duration("expires-in"); expiresIn != 0 {
+			expiresAt = time.Now().Add(expiresIn)
+		}
+		id, token, err := editor.mintToken(c.Args()[0], expiresAt)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "minting token error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := editor.confirmAndWrite(); err != nil {
+			fmt.Fprintf(os.Stderr, "writing new config error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Here's the token (ID %s) for %s:\n\n\t%s\n\n"+
+			"This is the only time you'll see it. Use it with the header "+
+			"\"Authorization: Bearer <token>\".\n", id, c.Args()[0], token)
+	},
+}
+
+var tokenRevokeCmd = cli.Command{
+	Name:      "revoke",
+	Usage:     "revoke bearer token(s) by ID",
+	UsageText: "revoke <token ID> [token ID ...]",
+	Action: func(c *cli.Context) {
+		if len(c.Args()) < 1 {
+			fmt.Fprintln(os.Stderr, "need at least 1 arg")
+			os.Exit(1)
+		}
+		editor, err := newKBPConfigEditor(c.GlobalString("dir"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr,
+				"creating config editor error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, id := range c.Args() {
+			if err := editor.revokeToken(id); err != nil {
+				fmt.Fprintf(os.Stderr, "revoking token error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if err := editor.confirmAndWrite(); err != nil {
+			fmt.Fprintf(os.Stderr, "writing new config error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var tokenListCmd = cli.Command{
+	Name:      "list",
+	Usage:     "list bearer tokens in the config",
+	UsageText: "list",
+	Action: func(c *cli.Context) {
+		editor, err := newKBPConfigEditor(c.GlobalString("dir"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr,
+				"creating config editor error: %v\n", err)
+			os.Exit(1)
+		}
+		ids := make([]string, 0, len(editor.kbpConfig.Tokens))
+		for id := range editor.kbpConfig.Tokens {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		writer := tabwriter.NewWriter(os.Stdout, 0, 4, 1, '\t', 0)
+		fmt.Fprintln(writer, "id\tusername\texpires")
+		for _, id := range ids {
+			token := editor.kbpConfig.Tokens[id]
+			expires := "never"
+			if token.ExpiresAt != 0 {
+				expires = time.Unix(token.ExpiresAt, 0).String()
+			}
+			fmt.Fprintf(writer, "%s\t%s\t%s\n", id, token.Username, expires)
+		}
+		if err := writer.Flush(); err != nil {
+			fmt.Fprintf(os.Stderr, "flushing tabwriter error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var tokenCmd = cli.Command{
+	Name:      "token",
+	Usage:     "make changes to the 'tokens' section of the config",
+	UsageText: "token <mint|revoke|list> [args]",
+	Subcommands: []cli.Command{
+		tokenMintCmd,
+		tokenRevokeCmd,
+		tokenListCmd,
+	},
+}
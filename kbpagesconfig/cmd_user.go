@@ -94,13 +94,139 @@ var userRemoveCmd = cli.Command{
 	},
 }
 
+var userGroupAddCmd = cli.Command{
+	Name:      "add",
+	Usage:     "add user(s) to a group",
+	UsageText: "add <group> <username> [username ...]",
+	Action: func(c *cli.Context) {
+		if len(c.Args()) < 2 {
+			fmt.Fprintln(os.Stderr, "need at least 2 args")
+			os.Exit(1)
+		}
+		editor, err := newKBPConfigEditor(c.GlobalString("dir"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr,
+				"creating config editor error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, username := range c.Args()[1:] {
+			if err := editor.addGroupMember(c.Args()[0], username); err != nil {
+				fmt.Fprintf(os.Stderr,
+					"adding user (%s) to group (%s) error: %v\n",
+					username, c.Args()[0], err)
+				os.Exit(1)
+			}
+		}
+		if err := editor.confirmAndWrite(); err != nil {
+			fmt.Fprintf(os.Stderr, "writing new config error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var userGroupRemoveCmd = cli.Command{
+	Name:      "remove",
+	Usage:     "remove user(s) from a group",
+	UsageText: "remove <group> <username> [username ...]",
+	Action: func(c *cli.Context) {
+		if len(c.Args()) < 2 {
+			fmt.Fprintln(os.Stderr, "need at least 2 args")
+			os.Exit(1)
+		}
+		editor, err := newKBPConfigEditor(c.GlobalString("dir"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr,
+				"creating config editor error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, username := range c.Args()[1:] {
+			editor.removeGroupMember(c.Args()[0], username)
+		}
+		if err := editor.confirmAndWrite(); err != nil {
+			fmt.Fprintf(os.Stderr, "writing new config error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var userGroupTeamCmd = cli.Command{
+	Name: "team",
+	Usage: "make members of the team that owns the site with at least " +
+		"the given role members of a group; use \"none\" to unset",
+	UsageText: "team <group> <reader|writer|none>",
+	Action: func(c *cli.Context) {
+		if len(c.Args()) != 2 {
+			fmt.Fprintln(os.Stderr, "need exactly 2 args")
+			os.Exit(1)
+		}
+		editor, err := newKBPConfigEditor(c.GlobalString("dir"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr,
+				"creating config editor error: %v\n", err)
+			os.Exit(1)
+		}
+		teamRole := c.Args()[1]
+		if teamRole == "none" {
+			teamRole = ""
+		}
+		if err := editor.setGroupTeamRole(c.Args()[0], teamRole); err != nil {
+			fmt.Fprintf(os.Stderr,
+				"setting team role for group (%s) error: %v\n",
+				c.Args()[0], err)
+			os.Exit(1)
+		}
+		if err := editor.confirmAndWrite(); err != nil {
+			fmt.Fprintf(os.Stderr, "writing new config error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var userGroupDeleteCmd = cli.Command{
+	Name:      "delete",
+	Usage:     "delete group(s) from config, along with their ACL entries",
+	UsageText: "delete <group> [group ...]",
+	Action: func(c *cli.Context) {
+		if len(c.Args()) < 1 {
+			fmt.Fprintln(os.Stderr, "empty group")
+			os.Exit(1)
+		}
+		editor, err := newKBPConfigEditor(c.GlobalString("dir"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr,
+				"creating config editor error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, group := range c.Args() {
+			editor.deleteGroup(group)
+		}
+		if err := editor.confirmAndWrite(); err != nil {
+			fmt.Fprintf(os.Stderr, "writing new config error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var userGroupCmd = cli.Command{
+	Name:      "group",
+	Usage:     "make changes to 'groups' section of the config",
+	UsageText: "group <add|remove|team|delete> <args>",
+	Subcommands: []cli.Command{
+		userGroupAddCmd,
+		userGroupRemoveCmd,
+		userGroupTeamCmd,
+		userGroupDeleteCmd,
+	},
+}
+
 var userCmd = cli.Command{
 	Name:      "user",
-	Usage:     "make changes to 'users' section of the config",
-	UsageText: "user <add|remove> <args>",
+	Usage:     "make changes to 'users' and 'groups' sections of the config",
+	UsageText: "user <add|change|remove|group> <args>",
 	Subcommands: []cli.Command{
 		userAddCmd,
 		userChangeCmd,
 		userRemoveCmd,
+		userGroupCmd,
 	},
 }
@@ -30,6 +30,9 @@ type kbpConfigEditor struct {
 	kbpConfig         *config.V1
 	originalConfigStr string
 	prompter          prompter
+	// inPublicTLF is true if the config is in a public TLF, where anyone
+	// can read it.
+	inPublicTLF bool
 }
 
 func readConfigAndClose(from io.ReadCloser) (
@@ -53,6 +56,23 @@ func kbpConfigPath(kbpConfigDir string) (path string, err error) {
 	return filepath.Join(kbpConfigDir, config.DefaultConfigFilename), nil
 }
 
+// isInPublicTLF returns true if dir is under a public TLF of a mounted KBFS,
+// i.e. it has a "public" component right under a "keybase" one.
+func isInPublicTLF(dir string) bool {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		abs = dir
+	}
+	components := strings.Split(filepath.ToSlash(abs), "/")
+	for i := 1; i < len(components); i++ {
+		if strings.EqualFold(components[i-1], "keybase") &&
+			strings.EqualFold(components[i], "public") {
+			return true
+		}
+	}
+	return false
+}
+
 func promptConfirm(p prompter, text string, defaultYes bool) (confirmed bool, err error) {
 	prompt := "(y/N)"
 	if defaultYes {
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/keybase/client/go/minterm"
 	"github.com/keybase/kbfs/libpages/config"
@@ -20,7 +21,11 @@ func newKBPConfigEditorWithPrompter(kbpConfigDir string, p prompter) (
 	if err != nil {
 		return nil, err
 	}
-	editor := &kbpConfigEditor{kbpConfigPath: kbpConfigPath, prompter: p}
+	editor := &kbpConfigEditor{
+		kbpConfigPath: kbpConfigPath,
+		prompter:      p,
+		inPublicTLF:   isInPublicTLF(kbpConfigDir),
+	}
 	f, err := os.Open(kbpConfigPath)
 	switch {
 	case err == nil:
@@ -129,6 +134,173 @@ func (e *kbpConfigEditor) setUser(username string, isAdd bool) error {
 
 func (e *kbpConfigEditor) removeUser(username string) {
 	delete(e.kbpConfig.Users, username)
+	for name, group := range e.kbpConfig.Groups {
+		group.Members = removeString(group.Members, username)
+		e.kbpConfig.Groups[name] = group
+	}
+	// Tokens issued for a removed user would make the config invalid, so
+	// remove them too.
+	for id, token := range e.kbpConfig.Tokens {
+		if token.Username == username {
+			delete(e.kbpConfig.Tokens, id)
+		}
+	}
+}
+
+func removeString(strs []string, toRemove string) (ret []string) {
+	for _, str := range strs {
+		if str != toRemove {
+			ret = append(ret, str)
+		}
+	}
+	return ret
+}
+
+func (e *kbpConfigEditor) addGroupMember(group string, username string) error {
+	if _, ok := e.kbpConfig.Users[username]; !ok {
+		return fmt.Errorf("user %s doesn't exist", username)
+	}
+	if e.kbpConfig.Groups == nil {
+		e.kbpConfig.Groups = make(map[string]config.GroupV1)
+	}
+	g := e.kbpConfig.Groups[group]
+	for _, member := range g.Members {
+		if member == username {
+			return fmt.Errorf("user %s is already in group %s", username, group)
+		}
+	}
+	g.Members = append(g.Members, username)
+	e.kbpConfig.Groups[group] = g
+	return nil
+}
+
+func (e *kbpConfigEditor) removeGroupMember(group string, username string) {
+	g, ok := e.kbpConfig.Groups[group]
+	if !ok {
+		return
+	}
+	g.Members = removeString(g.Members, username)
+	e.kbpConfig.Groups[group] = g
+}
+
+func (e *kbpConfigEditor) setGroupTeamRole(group string, teamRole string) error {
+	if e.kbpConfig.Groups == nil {
+		e.kbpConfig.Groups = make(map[string]config.GroupV1)
+	}
+	g := e.kbpConfig.Groups[group]
+	g.TeamRole = teamRole
+	e.kbpConfig.Groups[group] = g
+	return e.kbpConfig.Validate()
+}
+
+// deleteGroup removes group from the config, along with any permissions
+// granted to it in ACLs.
+func (e *kbpConfigEditor) deleteGroup(group string) {
+	delete(e.kbpConfig.Groups, group)
+	for p := range e.kbpConfig.ACLs {
+		e.removeGroupFromACL(group, p)
+	}
+}
+
+func (e *kbpConfigEditor) mintToken(username string, expiresAt time.Time) (
+	id string, token string, err error) {
+	if _, ok := e.kbpConfig.Users[username]; !ok {
+		return "", "", fmt.Errorf("user %s doesn't exist", username)
+	}
+	id, token, hash, err := config.GenerateToken()
+	if err != nil {
+		return "", "", err
+	}
+	if e.kbpConfig.Tokens == nil {
+		e.kbpConfig.Tokens = make(map[string]config.TokenV1)
+	}
+	t := config.TokenV1{Username: username, Hash: hash}
+	if !expiresAt.IsZero() {
+		t.ExpiresAt = expiresAt.Unix()
+	}
+	e.kbpConfig.Tokens[id] = t
+	return id, token, e.kbpConfig.Validate()
+}
+
+func (e *kbpConfigEditor) revokeToken(id string) error {
+	if _, ok := e.kbpConfig.Tokens[id]; !ok {
+		return fmt.Errorf("token %s doesn't exist", id)
+	}
+	delete(e.kbpConfig.Tokens, id)
+	return nil
+}
+
+// ensureSigningSecret generates a signing secret if the config doesn't have
+// one yet. The returned generated is true if a new secret is generated, in
+// which case the config needs to be written for it to take effect.
+func (e *kbpConfigEditor) ensureSigningSecret() (generated bool, err error) {
+	if len(e.kbpConfig.SigningSecret) > 0 {
+		return false, nil
+	}
+	return true, e.rotateSigningSecret()
+}
+
+var errSigningSecretInPublicTLF = errors.New(
+	"signed URLs are not supported for sites in public TLFs, since " +
+		"anyone could read the signing secret from the config")
+
+// rotateSigningSecret replaces the signing secret with a new one, which
+// revokes all previously signed URLs.
+func (e *kbpConfigEditor) rotateSigningSecret() (err error) {
+	if e.inPublicTLF {
+		return errSigningSecretInPublicTLF
+	}
+	e.kbpConfig.SigningSecret, err = config.GenerateSigningSecret()
+	return err
+}
+
+// mintSignedURLs signs a URL for each of paths that authenticates as
+// username until expiresAt. If the config doesn't have a signing secret yet,
+// a new one is generated and written to the config first; if that isn't
+// confirmed, no URL is signed.
+func (e *kbpConfigEditor) mintSignedURLs(paths []string, username string,
+	expiresAt time.Time) (signedURLs []string, err error) {
+	if e.inPublicTLF {
+		return nil, errSigningSecretInPublicTLF
+	}
+	generated, err := e.ensureSigningSecret()
+	if err != nil {
+		return nil, fmt.Errorf("generating signing secret error: %v", err)
+	}
+	if generated {
+		fmt.Println("the config doesn't have a signing secret yet; " +
+			"a new one has been generated")
+		if err = e.confirmAndWrite(); err != nil {
+			return nil, fmt.Errorf("writing new config error: %v", err)
+		}
+	}
+	for _, p := range paths {
+		signedURL, err := e.signURL(p, username, expiresAt)
+		if err != nil {
+			return nil, fmt.Errorf("signing URL for %q error: %v", p, err)
+		}
+		signedURLs = append(signedURLs, signedURL)
+	}
+	return signedURLs, nil
+}
+
+func (e *kbpConfigEditor) signURL(pathStr string, username string,
+	expiresAt time.Time) (signedURL string, err error) {
+	if _, ok := e.kbpConfig.Users[username]; !ok {
+		return "", fmt.Errorf("user %s doesn't exist", username)
+	}
+	// Sign with a fresh config so that any changes made through the editor
+	// are honored.
+	signer := &config.V1{
+		Common:        e.kbpConfig.Common,
+		Users:         e.kbpConfig.Users,
+		SigningSecret: e.kbpConfig.SigningSecret,
+	}
+	query, err := signer.SignURL(pathStr, username, expiresAt)
+	if err != nil {
+		return "", err
+	}
+	return pathStr + "?" + query.Encode(), nil
 }
 
 func (e *kbpConfigEditor) setAnonymousPermission(
@@ -174,6 +346,35 @@ func (e *kbpConfigEditor) removeUserFromACL(username string, pathStr string) {
 	delete(e.kbpConfig.ACLs[pathStr].WhitelistAdditionalPermissions, username)
 }
 
+func (e *kbpConfigEditor) setGroupAdditionalPermission(
+	group string, permsStr string, pathStr string) error {
+	if e.kbpConfig.ACLs == nil {
+		e.kbpConfig.ACLs = make(map[string]config.AccessControlV1)
+	}
+	pathACL := e.kbpConfig.ACLs[pathStr]
+	if pathACL.GroupAdditionalPermissions == nil {
+		pathACL.GroupAdditionalPermissions = make(map[string]string)
+	}
+	pathACL.GroupAdditionalPermissions[group] = permsStr
+	e.kbpConfig.ACLs[pathStr] = pathACL
+	return e.kbpConfig.Validate()
+}
+
+func (e *kbpConfigEditor) removeGroupFromACL(group string, pathStr string) {
+	if e.kbpConfig.ACLs == nil {
+		return
+	}
+	pathACL, ok := e.kbpConfig.ACLs[pathStr]
+	if !ok || pathACL.GroupAdditionalPermissions == nil {
+		return
+	}
+	delete(pathACL.GroupAdditionalPermissions, group)
+	if len(pathACL.GroupAdditionalPermissions) == 0 {
+		pathACL.GroupAdditionalPermissions = nil
+	}
+	e.kbpConfig.ACLs[pathStr] = pathACL
+}
+
 func (e *kbpConfigEditor) getUserOnPath(
 	username string, pathStr string) (read, list bool, err error) {
 	read, list, _, _, _, err = e.kbpConfig.GetPermissions(
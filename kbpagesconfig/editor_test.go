@@ -7,9 +7,11 @@ package main
 import (
 	"context"
 	"io/ioutil"
+	"net/url"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/keybase/kbfs/libpages/config"
 	"github.com/stretchr/testify/require"
@@ -139,3 +141,213 @@ func TestEditor(t *testing.T) {
 	require.True(t, read)
 	require.True(t, list)
 }
+
+func TestEditorTokensAndSignedURLs(t *testing.T) {
+	configDir, err := ioutil.TempDir(".", "kbpagesconfig-editor-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(configDir)
+
+	nextResponse := make(chan string, 4)
+	prompter := &fakePrompterForTest{
+		nextResponse: nextResponse,
+	}
+
+	editor, err := newKBPConfigEditorWithPrompter(configDir, prompter)
+	require.NoError(t, err)
+	nextResponse <- "n"
+	nextResponse <- "12345"
+	err = editor.setUser("alice", true)
+	require.NoError(t, err)
+
+	// Minting a token for an undefined user should fail.
+	_, _, err = editor.mintToken("bob", time.Time{})
+	require.Error(t, err)
+	id, token, err := editor.mintToken("alice", time.Time{})
+	require.NoError(t, err)
+	generated, err := editor.ensureSigningSecret()
+	require.NoError(t, err)
+	require.True(t, generated)
+	nextResponse <- "y"
+	err = editor.confirmAndWrite()
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	editor, err = newKBPConfigEditorWithPrompter(configDir, prompter)
+	require.NoError(t, err)
+	username, ok := editor.kbpConfig.AuthenticateToken(ctx, token)
+	require.True(t, ok)
+	require.Equal(t, "alice", username)
+	generated, err = editor.ensureSigningSecret()
+	require.NoError(t, err)
+	require.False(t, generated)
+	signedURL, err := editor.signURL(
+		"/private", "alice", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	u, err := url.Parse(signedURL)
+	require.NoError(t, err)
+	username, ok = editor.kbpConfig.AuthenticateSignedURL(u.Path, u.Query())
+	require.True(t, ok)
+	require.Equal(t, "alice", username)
+
+	// Revoke the token, and rotate the secret to revoke the signed URL.
+	err = editor.revokeToken(id)
+	require.NoError(t, err)
+	err = editor.revokeToken(id)
+	require.Error(t, err)
+	err = editor.rotateSigningSecret()
+	require.NoError(t, err)
+	nextResponse <- "y"
+	err = editor.confirmAndWrite()
+	require.NoError(t, err)
+
+	editor, err = newKBPConfigEditorWithPrompter(configDir, prompter)
+	require.NoError(t, err)
+	_, ok = editor.kbpConfig.AuthenticateToken(ctx, token)
+	require.False(t, ok)
+	_, ok = editor.kbpConfig.AuthenticateSignedURL(u.Path, u.Query())
+	require.False(t, ok)
+}
+
+func TestEditorMintSignedURLs(t *testing.T) {
+	configDir, err := ioutil.TempDir(".", "kbpagesconfig-editor-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(configDir)
+
+	nextResponse := make(chan string, 4)
+	prompter := &fakePrompterForTest{
+		nextResponse: nextResponse,
+	}
+
+	editor, err := newKBPConfigEditorWithPrompter(configDir, prompter)
+	require.NoError(t, err)
+	nextResponse <- "n"
+	nextResponse <- "12345"
+	err = editor.setUser("alice", true)
+	require.NoError(t, err)
+	nextResponse <- "y"
+	err = editor.confirmAndWrite()
+	require.NoError(t, err)
+
+	// Declining to write the new signing secret shouldn't mint anything.
+	editor, err = newKBPConfigEditorWithPrompter(configDir, prompter)
+	require.NoError(t, err)
+	nextResponse <- "n"
+	signedURLs, err := editor.mintSignedURLs(
+		[]string{"/a", "/b"}, "alice", time.Now().Add(time.Hour))
+	require.Error(t, err)
+	require.Empty(t, signedURLs)
+
+	editor, err = newKBPConfigEditorWithPrompter(configDir, prompter)
+	require.NoError(t, err)
+	require.Empty(t, editor.kbpConfig.SigningSecret)
+	nextResponse <- "y"
+	signedURLs, err = editor.mintSignedURLs(
+		[]string{"/a", "/b"}, "alice", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, signedURLs, 2)
+
+	editor, err = newKBPConfigEditorWithPrompter(configDir, prompter)
+	require.NoError(t, err)
+	for i, p := range []string{"/a", "/b"} {
+		u, err := url.Parse(signedURLs[i])
+		require.NoError(t, err)
+		require.Equal(t, p, u.Path)
+		username, ok := editor.kbpConfig.AuthenticateSignedURL(
+			u.Path, u.Query())
+		require.True(t, ok)
+		require.Equal(t, "alice", username)
+	}
+}
+
+func TestEditorSignedURLsInPublicTLF(t *testing.T) {
+	require.True(t, isInPublicTLF("/keybase/public/alice"))
+	require.True(t, isInPublicTLF("/Volumes/Keybase/public/alice/site"))
+	require.True(t, isInPublicTLF("/keybase/public/alice,bob"))
+	require.False(t, isInPublicTLF("/keybase/private/alice"))
+	require.False(t, isInPublicTLF("/keybase/team/public"))
+	require.False(t, isInPublicTLF("/home/alice/public"))
+
+	tmpDir, err := ioutil.TempDir(".", "kbpagesconfig-editor-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	configDir := filepath.Join(tmpDir, "keybase", "public", "alice")
+	err = os.MkdirAll(configDir, 0700)
+	require.NoError(t, err)
+
+	nextResponse := make(chan string, 4)
+	prompter := &fakePrompterForTest{
+		nextResponse: nextResponse,
+	}
+
+	editor, err := newKBPConfigEditorWithPrompter(configDir, prompter)
+	require.NoError(t, err)
+	nextResponse <- "n"
+	nextResponse <- "12345"
+	err = editor.setUser("alice", true)
+	require.NoError(t, err)
+	_, err = editor.ensureSigningSecret()
+	require.Equal(t, errSigningSecretInPublicTLF, err)
+	err = editor.rotateSigningSecret()
+	require.Equal(t, errSigningSecretInPublicTLF, err)
+	_, err = editor.mintSignedURLs(
+		[]string{"/a"}, "alice", time.Now().Add(time.Hour))
+	require.Equal(t, errSigningSecretInPublicTLF, err)
+	require.Empty(t, editor.kbpConfig.SigningSecret)
+}
+
+func TestEditorGroups(t *testing.T) {
+	configDir, err := ioutil.TempDir(".", "kbpagesconfig-editor-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(configDir)
+
+	nextResponse := make(chan string, 4)
+	prompter := &fakePrompterForTest{
+		nextResponse: nextResponse,
+	}
+
+	editor, err := newKBPConfigEditorWithPrompter(configDir, prompter)
+	require.NoError(t, err)
+	nextResponse <- "n"
+	nextResponse <- "12345"
+	err = editor.setUser("alice", true)
+	require.NoError(t, err)
+	err = editor.setAnonymousPermission("", "/")
+	require.NoError(t, err)
+
+	// Granting permissions to an undefined group should fail.
+	err = editor.setGroupAdditionalPermission("editors", "read", "/")
+	require.Error(t, err)
+	err = editor.addGroupMember("editors", "bob")
+	require.Error(t, err)
+	err = editor.addGroupMember("editors", "alice")
+	require.NoError(t, err)
+	err = editor.addGroupMember("editors", "alice")
+	require.Error(t, err)
+	err = editor.setGroupAdditionalPermission("editors", "read", "/")
+	require.NoError(t, err)
+	err = editor.setGroupTeamRole("editors", "admin")
+	require.Error(t, err)
+	err = editor.setGroupTeamRole("editors", config.TeamRoleWriter)
+	require.NoError(t, err)
+	nextResponse <- "y"
+	err = editor.confirmAndWrite()
+	require.NoError(t, err)
+
+	alice := "alice"
+	editor, err = newKBPConfigEditorWithPrompter(configDir, prompter)
+	require.NoError(t, err)
+	read, list, _, _, _, err := editor.kbpConfig.GetPermissions("/", &alice)
+	require.NoError(t, err)
+	require.True(t, read)
+	require.False(t, list)
+
+	// Removing alice removes her from the group too.
+	editor.removeUser("alice")
+	require.NoError(t, editor.kbpConfig.Validate())
+	require.Len(t, editor.kbpConfig.Groups["editors"].Members, 0)
+
+	// Deleting the group removes it from ACLs.
+	editor.deleteGroup("editors")
+	require.NoError(t, editor.kbpConfig.Validate())
+	require.Nil(t, editor.kbpConfig.ACLs["/"].GroupAdditionalPermissions)
+}
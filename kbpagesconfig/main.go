@@ -24,6 +24,8 @@ func main() {
 	app.Commands = []cli.Command{
 		userCmd,
 		aclCmd,
+		tokenCmd,
+		signedURLCmd,
 		upgradeCmd,
 	}
 
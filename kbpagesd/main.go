@@ -7,6 +7,7 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"os"
 	"strings"
 	"time"
@@ -19,6 +20,7 @@ import (
 	"github.com/keybase/kbfs/stderrutils"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"golang.org/x/time/rate"
 )
 
 var (
@@ -28,6 +30,9 @@ var (
 	fStathatEZKey  string
 	fStathatPrefix string
 	fBlacklist     string
+	fClientRate    float64
+	fClientBurst   int
+	fRootMapping   string
 )
 
 func init() {
@@ -44,6 +49,14 @@ func init() {
 	// whitelist should be dynamically configurable.
 	flag.StringVar(&fBlacklist, "blacklist", "",
 		"a comma-separated list of domains to block")
+	flag.Float64Var(&fClientRate, "client-rate-limit", 0,
+		"max requests per second from each client IP; 0 disables the limit")
+	flag.IntVar(&fClientBurst, "client-burst", 50,
+		"max burst of requests from each client IP; must be at least 1 "+
+			"when -client-rate-limit is set")
+	flag.StringVar(&fRootMapping, "root-mapping", "",
+		"path to a JSON or YAML file, or a directory, mapping domains to "+
+			"site roots; if set, it's used instead of DNS TXT records")
 }
 
 func newLogger(isCLI bool) (*zap.Logger, error) {
@@ -102,6 +115,12 @@ const activityStatsPath = "./kbp-stats"
 func main() {
 	flag.Parse()
 
+	if fClientRate != 0 && fClientBurst < 1 {
+		fmt.Fprintln(os.Stderr,
+			"-client-burst must be at least 1 when -client-rate-limit is set")
+		os.Exit(2)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	// TODO: make logstash forwarding work and use isCLI=false here if logstash
@@ -159,12 +178,25 @@ func main() {
 			logger, fStathatPrefix, fStathatEZKey, enabler)
 	}
 
+	var rootLoader libpages.RootLoader
+	if len(fRootMapping) != 0 {
+		rootLoader, err = libpages.NewFileRootLoader(ctx, logger, fRootMapping,
+			libpages.DefaultFileRootLoaderReloadInterval)
+		if err != nil {
+			logger.Panic("libpages.NewFileRootLoader", zap.Error(err))
+		}
+	}
+
 	serverConfig := &libpages.ServerConfig{
 		DomainBlacklist:  removeEmpty(strings.Split(fBlacklist, ",")),
 		UseStaging:       !fProd,
 		Logger:           logger,
 		UseDiskCertCache: fDiskCertCache,
 		StatsReporter:    statsReporter,
+
+		PerClientRateLimit: rate.Limit(fClientRate),
+		PerClientBurst:     fClientBurst,
+		RootLoader:         rootLoader,
 	}
 
 	libpages.ListenAndServe(ctx, serverConfig, kbConfig)
@@ -0,0 +1,82 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libdokan
+
+import (
+	"sync"
+
+	"github.com/keybase/kbfs/dokan"
+	"github.com/keybase/kbfs/libfs"
+	"github.com/keybase/kbfs/libkbfs"
+	"golang.org/x/net/context"
+)
+
+// BandwidthLimitsFile represents a write-only file where writing a
+// JSON-encoded libkbfs.BandwidthSchedule replaces the bandwidth limits
+// for block transfers.  A new BandwidthLimitsFile is made for every
+// open, and it collects the writes to it, since a schedule may arrive
+// in several chunks.  The schedule takes effect when the file is
+// flushed or closed.
+type BandwidthLimitsFile struct {
+	fs *FS
+	specialWriteFile
+
+	lock  sync.Mutex
+	data  []byte
+	dirty bool
+}
+
+// WriteFile performs writes for dokan.
+func (f *BandwidthLimitsFile) WriteFile(ctx context.Context, fi *dokan.FileInfo, bs []byte, offset int64) (n int, err error) {
+	f.fs.logEnterf(ctx, "BandwidthLimitsFile WriteFile off=%d len=%d",
+		offset, len(bs))
+	defer func() { f.fs.reportErr(ctx, libkbfs.WriteMode, err) }()
+	if offset < 0 {
+		return 0, dokan.ErrAccessDenied
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	end := int(offset) + len(bs)
+	if end > len(f.data) {
+		f.data = append(f.data, make([]byte, end-len(f.data))...)
+	}
+	copy(f.data[offset:], bs)
+	f.dirty = true
+	return len(bs), nil
+}
+
+// applyLocked sets the bandwidth limits from the data written since
+// the last time they were applied.
+func (f *BandwidthLimitsFile) applyLocked(ctx context.Context) error {
+	if !f.dirty {
+		return nil
+	}
+	f.dirty = false
+	if len(f.data) == 0 {
+		return nil
+	}
+	return libfs.SetEncodedBandwidthLimits(ctx, f.fs.config, f.data)
+}
+
+// FlushFileBuffers performs a (f)sync.
+func (f *BandwidthLimitsFile) FlushFileBuffers(ctx context.Context, fi *dokan.FileInfo) (err error) {
+	f.fs.logEnter(ctx, "BandwidthLimitsFile FlushFileBuffers")
+	defer func() { f.fs.reportErr(ctx, libkbfs.WriteMode, err) }()
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.applyLocked(ctx)
+}
+
+// Cleanup is called after the last handle from userspace is closed,
+// and applies any schedule that hasn't been flushed yet.
+func (f *BandwidthLimitsFile) Cleanup(ctx context.Context, fi *dokan.FileInfo) {
+	var err error
+	f.fs.logEnter(ctx, "BandwidthLimitsFile Cleanup")
+	defer func() { f.fs.reportErr(ctx, libkbfs.WriteMode, err) }()
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	err = f.applyLocked(ctx)
+}
@@ -310,6 +310,22 @@ func (d *Dir) open(ctx context.Context, oc *openContext, path []string) (dokan.F
 
 		leaf := len(path) == 1
 
+		// The prefetch status file describes this directory, so it
+		// needs this directory's node.
+		if leaf && path[0] == libfs.PrefetchStatusFileName {
+			if err := oc.ReturningFileAllowed(); err != nil {
+				return nil, 0, err
+			}
+			node := d.node
+			return &SpecialReadFile{
+				read: func(ctx context.Context) ([]byte, time.Time, error) {
+					return libfs.GetEncodedPrefetchStatus(
+						ctx, d.folder.fs.config, node)
+				},
+				fs: d.folder.fs,
+			}, dokan.ExistingFile, nil
+		}
+
 		// Check if this is a per-file metainformation file, if so
 		// return the corresponding SpecialReadFile.
 		if leaf && strings.HasPrefix(path[0], libfs.FileInfoPrefix) {
@@ -350,6 +350,8 @@ func (f *FS) open(ctx context.Context, oc *openContext, ps []string) (dokan.File
 			fs:     f,
 			enable: false,
 		})
+	case libfs.BandwidthLimitsFileName == ps[0]:
+		return oc.returnFileNoCleanup(&BandwidthLimitsFile{fs: f})
 
 	case ".kbfs_unmount" == ps[0]:
 		os.Exit(0)
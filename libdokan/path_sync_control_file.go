@@ -0,0 +1,42 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libdokan
+
+import (
+	"fmt"
+
+	"github.com/keybase/kbfs/dokan"
+	"github.com/keybase/kbfs/libfs"
+	"github.com/keybase/kbfs/libkbfs"
+	"golang.org/x/net/context"
+)
+
+// PathSyncControlFile is a special file used to control the sync
+// settings of a single directory within a TLF. The path of the
+// directory, relative to the root of the TLF, is written to it.
+type PathSyncControlFile struct {
+	specialWriteFile
+	folder *Folder
+	action libfs.SyncAction
+}
+
+// WriteFile implements writes for dokan.
+func (f *PathSyncControlFile) WriteFile(ctx context.Context,
+	fi *dokan.FileInfo, bs []byte, offset int64) (n int, err error) {
+	f.folder.fs.logEnter(ctx,
+		fmt.Sprintf("PathSyncControlFile (f.action=%s) Write", f.action))
+	defer func() { f.folder.reportErr(ctx, libkbfs.WriteMode, err) }()
+	if len(bs) == 0 {
+		return 0, nil
+	}
+
+	err = f.action.ExecuteForPath(ctx, f.folder.fs.config,
+		f.folder.getFolderBranch(), f.folder.h, string(bs))
+	if err != nil {
+		return 0, err
+	}
+
+	return len(bs), nil
+}
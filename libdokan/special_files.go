@@ -23,6 +23,12 @@ func handleTLFSpecialFile(name string, folder *Folder) dokan.File {
 	case libfs.EditHistoryName:
 		return NewTlfEditHistoryFile(folder)
 
+	case libfs.ConflictsFileName:
+		return NewConflictsFile(folder)
+
+	case libfs.ConflictRenamingFileName:
+		return NewConflictRenamingFile(folder)
+
 	case libfs.UnstageFileName:
 		return &UnstageFile{
 			folder: folder,
@@ -66,6 +72,15 @@ func handleTLFSpecialFile(name string, folder *Folder) dokan.File {
 			action: libfs.JournalFlush,
 		}
 
+	case libfs.FlushJournalNextFileName:
+		return &JournalControlFile{
+			folder: folder,
+			action: libfs.JournalFlushNext,
+		}
+
+	case libfs.JournalFlushPriorityFileName:
+		return NewJournalFlushPriorityFile(folder)
+
 	case libfs.PauseJournalBackgroundWorkFileName:
 		return &JournalControlFile{
 			folder: folder,
@@ -95,6 +110,18 @@ func handleTLFSpecialFile(name string, folder *Folder) dokan.File {
 			folder: folder,
 			action: libfs.SyncDisable,
 		}
+
+	case libfs.EnablePathSyncFileName:
+		return &PathSyncControlFile{
+			folder: folder,
+			action: libfs.SyncEnable,
+		}
+
+	case libfs.DisablePathSyncFileName:
+		return &PathSyncControlFile{
+			folder: folder,
+			action: libfs.SyncDisable,
+		}
 	}
 
 	return nil
@@ -0,0 +1,117 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libdokan
+
+import (
+	"time"
+
+	"github.com/keybase/kbfs/dokan"
+	"github.com/keybase/kbfs/libfs"
+	"github.com/keybase/kbfs/libkbfs"
+	"golang.org/x/net/context"
+)
+
+// SpecialReadWriteFile represents a TLF file whose contents are
+// determined by a function, and where a write is handed to another
+// function.
+type SpecialReadWriteFile struct {
+	SpecialReadFile
+	folder *Folder
+	name   string
+	write  func(context.Context, []byte) error
+}
+
+// NewConflictsFile returns a special file that lists the conflict
+// copies made by conflict resolution in the TLF, where a write of a
+// JSON-encoded libfs.ConflictResolution picks the winner of one of
+// them.
+func NewConflictsFile(folder *Folder) *SpecialReadWriteFile {
+	fb := folder.getFolderBranch
+	return &SpecialReadWriteFile{
+		SpecialReadFile: SpecialReadFile{
+			read: func(ctx context.Context) ([]byte, time.Time, error) {
+				return libfs.GetEncodedConflicts(ctx, folder.fs.config, fb())
+			},
+			fs: folder.fs,
+		},
+		folder: folder,
+		name:   libfs.ConflictsFileName,
+		write: func(ctx context.Context, data []byte) error {
+			return libfs.ResolveEncodedConflict(
+				ctx, folder.fs.config, fb(), data)
+		},
+	}
+}
+
+// NewConflictRenamingFile returns a special file containing the
+// JSON-encoded libkbfs.ConflictRenaming of the TLF, where a write of
+// a new one replaces it.
+func NewConflictRenamingFile(folder *Folder) *SpecialReadWriteFile {
+	fb := folder.getFolderBranch
+	return &SpecialReadWriteFile{
+		SpecialReadFile: SpecialReadFile{
+			read: func(ctx context.Context) ([]byte, time.Time, error) {
+				return libfs.GetEncodedConflictRenaming(
+					ctx, folder.fs.config, fb())
+			},
+			fs: folder.fs,
+		},
+		folder: folder,
+		name:   libfs.ConflictRenamingFileName,
+		write: func(ctx context.Context, data []byte) error {
+			return libfs.SetEncodedConflictRenaming(
+				ctx, folder.fs.config, fb(), data)
+		},
+	}
+}
+
+// NewJournalFlushPriorityFile returns a special file containing the
+// flush priority of the journal of the TLF, where a write of a new
+// one replaces it.
+func NewJournalFlushPriorityFile(folder *Folder) *SpecialReadWriteFile {
+	fb := folder.getFolderBranch
+	return &SpecialReadWriteFile{
+		SpecialReadFile: SpecialReadFile{
+			read: func(ctx context.Context) ([]byte, time.Time, error) {
+				return libfs.GetEncodedJournalFlushPriority(
+					ctx, folder.fs.config, fb().Tlf)
+			},
+			fs: folder.fs,
+		},
+		folder: folder,
+		name:   libfs.JournalFlushPriorityFileName,
+		write: func(ctx context.Context, data []byte) error {
+			return libfs.SetEncodedJournalFlushPriority(
+				ctx, folder.fs.config, fb().Tlf, data)
+		},
+	}
+}
+
+// GetFileInformation does stats for dokan.
+func (f *SpecialReadWriteFile) GetFileInformation(ctx context.Context, fi *dokan.FileInfo) (*dokan.Stat, error) {
+	a, err := f.SpecialReadFile.GetFileInformation(ctx, fi)
+	if err != nil {
+		return nil, err
+	}
+	// Unlike a SpecialReadFile, this one is writable.
+	a.FileAttributes &^= dokan.FileAttributeReadonly
+	return a, nil
+}
+
+// WriteFile performs writes for dokan.
+func (f *SpecialReadWriteFile) WriteFile(ctx context.Context, fi *dokan.FileInfo, bs []byte, offset int64) (n int, err error) {
+	f.folder.fs.logEnter(ctx, "SpecialReadWriteFile ("+f.name+") WriteFile")
+	defer func() { f.folder.reportErr(ctx, libkbfs.WriteMode, err) }()
+	if len(bs) == 0 {
+		return 0, nil
+	}
+
+	err = f.write(ctx, bs)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(bs), nil
+}
@@ -0,0 +1,24 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libfs
+
+import (
+	"github.com/keybase/kbfs/libkbfs"
+	"golang.org/x/net/context"
+)
+
+// SetEncodedBandwidthLimits replaces the bandwidth limits of the given
+// config with the given JSON-encoded libkbfs.BandwidthSchedule.
+func SetEncodedBandwidthLimits(
+	ctx context.Context, config libkbfs.Config, data []byte) error {
+	schedule, err := libkbfs.ParseBandwidthSchedule(data)
+	if err != nil {
+		return err
+	}
+	config.MakeLogger("").CDebugf(
+		ctx, "Setting bandwidth limits: %+v", schedule)
+	config.BandwidthLimiter().SetSchedule(schedule)
+	return nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libfs
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/keybase/kbfs/libkbfs"
+	"golang.org/x/net/context"
+)
+
+// ConflictResolution is the JSON-encoded request written to the
+// conflicts file to pick the winner of a conflict.
+type ConflictResolution struct {
+	// ConflictPath is relative to the root of the TLF.
+	ConflictPath string `json:"conflict_path"`
+	// Winner is either "original" or "conflict".
+	Winner string `json:"winner"`
+}
+
+// GetEncodedConflicts returns serialized JSON containing the conflict
+// copies made by conflict resolution in a TLF.
+func GetEncodedConflicts(ctx context.Context, config libkbfs.Config,
+	folderBranch libkbfs.FolderBranch) (
+	data []byte, t time.Time, err error) {
+	conflicts, err := config.KBFSOps().GetConflicts(ctx, folderBranch)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	data, err = PrettyJSON(conflicts)
+	return data, time.Time{}, err
+}
+
+// ResolveEncodedConflict picks the winner of a conflict in a TLF,
+// given a JSON-encoded ConflictResolution.
+func ResolveEncodedConflict(ctx context.Context, config libkbfs.Config,
+	folderBranch libkbfs.FolderBranch, data []byte) error {
+	var res ConflictResolution
+	err := json.Unmarshal(data, &res)
+	if err != nil {
+		return err
+	}
+	winner, err := libkbfs.ParseConflictWinner(res.Winner)
+	if err != nil {
+		return err
+	}
+	return config.KBFSOps().ResolveConflict(
+		ctx, folderBranch, res.ConflictPath, winner)
+}
+
+// GetEncodedConflictRenaming returns serialized JSON containing the
+// scheme for naming the conflict copies in a TLF.
+func GetEncodedConflictRenaming(ctx context.Context, config libkbfs.Config,
+	folderBranch libkbfs.FolderBranch) (
+	data []byte, t time.Time, err error) {
+	renaming, err := config.KBFSOps().GetConflictRenaming(ctx, folderBranch)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	data, err = PrettyJSON(renaming)
+	return data, time.Time{}, err
+}
+
+// SetEncodedConflictRenaming replaces the scheme for naming the
+// conflict copies in a TLF with the given JSON-encoded
+// libkbfs.ConflictRenaming.
+func SetEncodedConflictRenaming(ctx context.Context, config libkbfs.Config,
+	folderBranch libkbfs.FolderBranch, data []byte) error {
+	var renaming libkbfs.ConflictRenaming
+	err := json.Unmarshal(data, &renaming)
+	if err != nil {
+		return err
+	}
+	return config.KBFSOps().SetConflictRenaming(ctx, folderBranch, renaming)
+}
@@ -47,6 +47,19 @@ const EnableJournalFileName = ".kbfs_enable_journal"
 // can be reached anywhere within a top-level folder.
 const FlushJournalFileName = ".kbfs_flush_journal"
 
+// FlushJournalNextFileName is the name of the file that makes the
+// journal of a TLF flush before the journals of all the other TLFs,
+// until it has nothing left to flush. It can be reached anywhere
+// within a top-level folder.
+const FlushJournalNextFileName = ".kbfs_flush_journal_next"
+
+// JournalFlushPriorityFileName is the name of the file containing the
+// priority ("low", "normal" or "high") of the journal of a TLF when it
+// competes with other journals for flush bandwidth.  Writing a new
+// priority to it replaces the old one.  It can be reached anywhere
+// within a top-level folder.
+const JournalFlushPriorityFileName = ".kbfs_journal_flush_priority"
+
 // PauseJournalBackgroundWorkFileName is the name of the file that
 // pauses the background work of a journal. It can be reached anywhere
 // within a top-level folder.
@@ -78,6 +91,11 @@ const EnableBlockPrefetchingFileName = ".kbfs_enable_block_prefetching"
 // prefetching-disabling file.  It's accessible anywhere outside a TLF.
 const DisableBlockPrefetchingFileName = ".kbfs_disable_block_prefetching"
 
+// BandwidthLimitsFileName is the name of the KBFS-wide bandwidth limit
+// control file.  Writing a JSON-encoded libkbfs.BandwidthSchedule to it
+// replaces the current limits.  It's accessible anywhere outside a TLF.
+const BandwidthLimitsFileName = ".kbfs_bandwidth_limits"
+
 // EnableDebugServerFileName is the name of the file to turn on the
 // debug HTTP server. It's accessible anywhere outside a TLF.
 const EnableDebugServerFileName = ".kbfs_enable_debug_server"
@@ -97,6 +115,11 @@ const UpdateHistoryFileName = ".kbfs_update_history"
 // FileInfoPrefix is the prefix of the per-file metadata files.
 const FileInfoPrefix = ".kbfs_fileinfo_"
 
+// PrefetchStatusFileName is the name of the file that reports how far
+// along the prefetch of the directory containing it is.  It can be
+// reached in any directory within a TLF.
+const PrefetchStatusFileName = ".kbfs_prefetch_status"
+
 // EnableSyncFileName is the name of the file to enable the sync cache for a
 // TLF. It can be reached anywhere within a TLF.
 const EnableSyncFileName = ".kbfs_enable_sync"
@@ -104,3 +127,27 @@ const EnableSyncFileName = ".kbfs_enable_sync"
 // DisableSyncFileName is the name of the file to disable the sync cache for a
 // TLF. It can be reached anywhere within a TLF.
 const DisableSyncFileName = ".kbfs_disable_sync"
+
+// EnablePathSyncFileName is the name of the file to enable the sync cache for
+// a single directory within a TLF. The path of the directory, relative to the
+// root of the TLF, is written to the file. It can be reached anywhere within
+// a TLF.
+const EnablePathSyncFileName = ".kbfs_enable_path_sync"
+
+// DisablePathSyncFileName is the name of the file to disable the sync cache
+// for a single directory within a TLF. The path of the directory, relative to
+// the root of the TLF, is written to the file. It can be reached anywhere
+// within a TLF.
+const DisablePathSyncFileName = ".kbfs_disable_path_sync"
+
+// ConflictsFileName is the name of the file that lists the conflict
+// copies made by conflict resolution in a TLF.  Writing a JSON object
+// with a conflict path and a winner to it resolves that conflict.  It
+// can be reached anywhere within a TLF.
+const ConflictsFileName = ".kbfs_conflicts"
+
+// ConflictRenamingFileName is the name of the file containing the
+// JSON-encoded scheme for naming the conflict copies in a TLF.
+// Writing a new scheme to it replaces the old one.  It can be reached
+// anywhere within a TLF.
+const ConflictRenamingFileName = ".kbfs_conflict_renaming"
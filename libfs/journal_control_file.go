@@ -6,6 +6,7 @@ package libfs
 
 import (
 	"fmt"
+	"time"
 
 	"golang.org/x/net/context"
 
@@ -22,6 +23,9 @@ const (
 	JournalEnable JournalAction = iota
 	// JournalFlush is to flush the journal.
 	JournalFlush
+	// JournalFlushNext is to flush the journal before the journals
+	// of all the other TLFs.
+	JournalFlushNext
 	// JournalPauseBackgroundWork is to pause journal background
 	// work.
 	JournalPauseBackgroundWork
@@ -42,6 +46,8 @@ func (a JournalAction) String() string {
 		return "Enable journal"
 	case JournalFlush:
 		return "Flush journal"
+	case JournalFlushNext:
+		return "Flush journal next"
 	case JournalPauseBackgroundWork:
 		return "Pause journal background work"
 	case JournalResumeBackgroundWork:
@@ -88,6 +94,12 @@ func (a JournalAction) Execute(
 			return err
 		}
 
+	case JournalFlushNext:
+		err := jServer.FlushNext(ctx, tlfID)
+		if err != nil {
+			return err
+		}
+
 	case JournalPauseBackgroundWork:
 		jServer.PauseBackgroundWork(ctx, tlfID)
 
@@ -106,3 +118,31 @@ func (a JournalAction) Execute(
 
 	return nil
 }
+
+// GetEncodedJournalFlushPriority returns the flush priority of the
+// journal of the given TLF, followed by a newline.
+func GetEncodedJournalFlushPriority(ctx context.Context,
+	config libkbfs.Config, tlfID tlf.ID) (
+	data []byte, t time.Time, err error) {
+	jServer, err := libkbfs.GetJournalServer(config)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return []byte(jServer.FlushPriority(tlfID).String() + "\n"),
+		time.Time{}, nil
+}
+
+// SetEncodedJournalFlushPriority sets the flush priority of the
+// journal of the given TLF to the one named by the given data.
+func SetEncodedJournalFlushPriority(ctx context.Context,
+	config libkbfs.Config, tlfID tlf.ID, data []byte) error {
+	priority, err := libkbfs.ParseJournalFlushPriority(string(data))
+	if err != nil {
+		return err
+	}
+	jServer, err := libkbfs.GetJournalServer(config)
+	if err != nil {
+		return err
+	}
+	return jServer.SetFlushPriority(ctx, tlfID, priority)
+}
@@ -0,0 +1,25 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libfs
+
+import (
+	"time"
+
+	"github.com/keybase/kbfs/libkbfs"
+	"golang.org/x/net/context"
+)
+
+// GetEncodedPrefetchStatus returns serialized JSON containing the
+// prefetch status of the subtree under the given node.
+func GetEncodedPrefetchStatus(ctx context.Context, config libkbfs.Config,
+	node libkbfs.Node) (data []byte, t time.Time, err error) {
+	status, err := config.KBFSOps().GetPrefetchStatus(ctx, node)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	data, err = PrettyJSON(status)
+	return data, time.Time{}, err
+}
@@ -6,6 +6,8 @@ package libfs
 
 import (
 	"fmt"
+	"path"
+	"strings"
 
 	"github.com/keybase/kbfs/libkbfs"
 	"golang.org/x/net/context"
@@ -58,3 +60,61 @@ func (a SyncAction) Execute(
 	_, _, err = c.KBFSOps().GetRootNode(ctx, h, fb.Branch)
 	return err
 }
+
+// lookupSyncedDir checks that p, relative to rootNode, is a directory.
+func lookupSyncedDir(ctx context.Context, c libkbfs.Config,
+	rootNode libkbfs.Node, p string) error {
+	cleaned := strings.Trim(path.Clean("/"+p), "/")
+	if cleaned == "" {
+		return fmt.Errorf("Can't sync the root of a TLF by path")
+	}
+	n := rootNode
+	for _, name := range strings.Split(cleaned, "/") {
+		child, ei, err := c.KBFSOps().Lookup(ctx, n, name)
+		if err != nil {
+			return err
+		}
+		if ei.Type != libkbfs.Dir {
+			return fmt.Errorf("%s is not a directory", p)
+		}
+		n = child
+	}
+	return nil
+}
+
+// ExecuteForPath performs the action on the directory at path p, relative to
+// the root of the given TLF, rather than on the whole TLF.
+func (a SyncAction) ExecuteForPath(
+	ctx context.Context, c libkbfs.Config, fb libkbfs.FolderBranch,
+	h *libkbfs.TlfHandle, p string) (err error) {
+	if fb == (libkbfs.FolderBranch{}) {
+		panic("zero fb in SyncAction.ExecuteForPath")
+	}
+	p = strings.TrimSpace(p)
+
+	rootNode, _, err := c.KBFSOps().GetRootNode(ctx, h, fb.Branch)
+	if err != nil {
+		return err
+	}
+
+	switch a {
+	case SyncEnable:
+		err = lookupSyncedDir(ctx, c, rootNode, p)
+		if err != nil {
+			return err
+		}
+		err = c.SetTlfPathSyncState(fb.Tlf, p, true)
+
+	case SyncDisable:
+		err = c.SetTlfPathSyncState(fb.Tlf, p, false)
+
+	default:
+		return fmt.Errorf("Unknown action %s", a)
+	}
+	if err != nil {
+		return err
+	}
+	// Re-trigger prefetches, by reading the root directory block again.
+	_, err = c.KBFSOps().GetDirChildren(ctx, rootNode)
+	return err
+}
@@ -0,0 +1,119 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libfuse
+
+import (
+	"sync"
+	"syscall"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+	"github.com/keybase/kbfs/libfs"
+	"github.com/keybase/kbfs/libkbfs"
+	"golang.org/x/net/context"
+)
+
+// BandwidthLimitsFile represents a write-only file where writing a
+// JSON-encoded libkbfs.BandwidthSchedule replaces the bandwidth limits
+// for block transfers.  The schedule takes effect when the file is
+// flushed or closed.
+type BandwidthLimitsFile struct {
+	fs *FS
+}
+
+var _ fs.Node = (*BandwidthLimitsFile)(nil)
+
+// Attr implements the fs.Node interface for BandwidthLimitsFile.
+func (f *BandwidthLimitsFile) Attr(ctx context.Context, a *fuse.Attr) error {
+	a.Size = 0
+	a.Mode = 0222
+	return nil
+}
+
+var _ fs.NodeOpener = (*BandwidthLimitsFile)(nil)
+
+// Open implements the fs.NodeOpener interface for BandwidthLimitsFile.
+func (f *BandwidthLimitsFile) Open(ctx context.Context,
+	req *fuse.OpenRequest, resp *fuse.OpenResponse) (fs.Handle, error) {
+	resp.Flags |= fuse.OpenDirectIO
+	return &bandwidthLimitsFileHandle{fs: f.fs}, nil
+}
+
+// bandwidthLimitsFileHandle collects the writes to an open
+// BandwidthLimitsFile, since a schedule may arrive in several chunks,
+// and applies the schedule once the file is flushed.
+type bandwidthLimitsFileHandle struct {
+	fs *FS
+
+	lock  sync.Mutex
+	data  []byte
+	dirty bool
+}
+
+var _ fs.Handle = (*bandwidthLimitsFileHandle)(nil)
+
+var _ fs.HandleWriter = (*bandwidthLimitsFileHandle)(nil)
+
+// Write implements the fs.HandleWriter interface for
+// bandwidthLimitsFileHandle.
+func (h *bandwidthLimitsFileHandle) Write(ctx context.Context,
+	req *fuse.WriteRequest, resp *fuse.WriteResponse) (err error) {
+	h.fs.log.CDebugf(ctx, "BandwidthLimitsFile Write off=%d len=%d",
+		req.Offset, len(req.Data))
+	defer func() { err = h.fs.processError(ctx, libkbfs.WriteMode, err) }()
+	if req.Offset < 0 {
+		return fuse.Errno(syscall.EINVAL)
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	end := int(req.Offset) + len(req.Data)
+	if end > len(h.data) {
+		h.data = append(h.data, make([]byte, end-len(h.data))...)
+	}
+	copy(h.data[req.Offset:], req.Data)
+	h.dirty = true
+	resp.Size = len(req.Data)
+	return nil
+}
+
+// applyLocked sets the bandwidth limits from the data written since
+// the last time they were applied.
+func (h *bandwidthLimitsFileHandle) applyLocked(ctx context.Context) error {
+	if !h.dirty {
+		return nil
+	}
+	h.dirty = false
+	if len(h.data) == 0 {
+		return nil
+	}
+	return libfs.SetEncodedBandwidthLimits(ctx, h.fs.config, h.data)
+}
+
+var _ fs.HandleFlusher = (*bandwidthLimitsFileHandle)(nil)
+
+// Flush implements the fs.HandleFlusher interface for
+// bandwidthLimitsFileHandle.
+func (h *bandwidthLimitsFileHandle) Flush(
+	ctx context.Context, req *fuse.FlushRequest) (err error) {
+	h.fs.log.CDebugf(ctx, "BandwidthLimitsFile Flush")
+	defer func() { err = h.fs.processError(ctx, libkbfs.WriteMode, err) }()
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.applyLocked(ctx)
+}
+
+var _ fs.HandleReleaser = (*bandwidthLimitsFileHandle)(nil)
+
+// Release implements the fs.HandleReleaser interface for
+// bandwidthLimitsFileHandle.
+func (h *bandwidthLimitsFileHandle) Release(
+	ctx context.Context, req *fuse.ReleaseRequest) (err error) {
+	h.fs.log.CDebugf(ctx, "BandwidthLimitsFile Release")
+	defer func() { err = h.fs.processError(ctx, libkbfs.WriteMode, err) }()
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.applyLocked(ctx)
+}
@@ -526,6 +526,16 @@ func (d *Dir) Lookup(ctx context.Context, req *fuse.LookupRequest, resp *fuse.Lo
 		return specialNode, nil
 	}
 
+	// The prefetch status file describes this directory, so it
+	// needs this directory's node.
+	if req.Name == libfs.PrefetchStatusFileName {
+		return &SpecialReadFile{func(ctx context.Context) (
+			[]byte, time.Time, error) {
+			return libfs.GetEncodedPrefetchStatus(
+				ctx, d.folder.fs.config, d.node)
+		}}, nil
+	}
+
 	// Check if this is a per-file metainformation file, if so
 	// return the corresponding SpecialReadFile.
 	if strings.HasPrefix(req.Name, libfs.FileInfoPrefix) {
@@ -0,0 +1,54 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libfuse
+
+import (
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+	"github.com/keybase/kbfs/libfs"
+	"github.com/keybase/kbfs/libkbfs"
+	"golang.org/x/net/context"
+)
+
+// PathSyncControlFile is a special file used to control the sync
+// settings of a single directory within a TLF. The path of the
+// directory, relative to the root of the TLF, is written to it.
+type PathSyncControlFile struct {
+	folder *Folder
+	action libfs.SyncAction
+}
+
+var _ fs.Node = (*PathSyncControlFile)(nil)
+
+// Attr implements the fs.Node interface for PathSyncControlFile.
+func (f *PathSyncControlFile) Attr(ctx context.Context, a *fuse.Attr) error {
+	a.Size = 0
+	a.Mode = 0222
+	return nil
+}
+
+var _ fs.Handle = (*PathSyncControlFile)(nil)
+
+var _ fs.HandleWriter = (*PathSyncControlFile)(nil)
+
+// Write implements the fs.HandleWriter interface for PathSyncControlFile.
+func (f *PathSyncControlFile) Write(ctx context.Context,
+	req *fuse.WriteRequest, resp *fuse.WriteResponse) (err error) {
+	f.folder.fs.log.CDebugf(ctx, "PathSyncControlFile (f.action=%s) Write",
+		f.action)
+	defer func() { err = f.folder.processError(ctx, libkbfs.WriteMode, err) }()
+	if len(req.Data) == 0 {
+		return nil
+	}
+
+	err = f.action.ExecuteForPath(ctx, f.folder.fs.config,
+		f.folder.getFolderBranch(), f.folder.h, string(req.Data))
+	if err != nil {
+		return err
+	}
+
+	resp.Size = len(req.Data)
+	return nil
+}
@@ -59,6 +59,8 @@ func handleNonTLFSpecialFile(
 		return &PrefetchFile{fs: fs, enable: true}
 	case libfs.DisableBlockPrefetchingFileName:
 		return &PrefetchFile{fs: fs, enable: false}
+	case libfs.BandwidthLimitsFileName:
+		return &BandwidthLimitsFile{fs: fs}
 
 	case libfs.EnableDebugServerFileName:
 		return &DebugServerFile{fs: fs, enable: true}
@@ -87,6 +89,14 @@ func handleTLFSpecialFile(
 	case libfs.EditHistoryName:
 		return NewTlfEditHistoryFile(folder, entryValid)
 
+	case libfs.ConflictsFileName:
+		*entryValid = 0
+		return NewConflictsFile(folder)
+
+	case libfs.ConflictRenamingFileName:
+		*entryValid = 0
+		return NewConflictRenamingFile(folder)
+
 	case libfs.UnstageFileName:
 		return &UnstageFile{
 			folder: folder,
@@ -134,6 +144,16 @@ func handleTLFSpecialFile(
 			action: libfs.JournalFlush,
 		}
 
+	case libfs.FlushJournalNextFileName:
+		return &JournalControlFile{
+			folder: folder,
+			action: libfs.JournalFlushNext,
+		}
+
+	case libfs.JournalFlushPriorityFileName:
+		*entryValid = 0
+		return NewJournalFlushPriorityFile(folder)
+
 	case libfs.PauseJournalBackgroundWorkFileName:
 		return &JournalControlFile{
 			folder: folder,
@@ -163,6 +183,18 @@ func handleTLFSpecialFile(
 			folder: folder,
 			action: libfs.SyncDisable,
 		}
+
+	case libfs.EnablePathSyncFileName:
+		return &PathSyncControlFile{
+			folder: folder,
+			action: libfs.SyncEnable,
+		}
+
+	case libfs.DisablePathSyncFileName:
+		return &PathSyncControlFile{
+			folder: folder,
+			action: libfs.SyncDisable,
+		}
 	}
 
 	return nil
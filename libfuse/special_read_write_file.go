@@ -0,0 +1,139 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libfuse
+
+import (
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+	"github.com/keybase/kbfs/libfs"
+	"github.com/keybase/kbfs/libkbfs"
+	"golang.org/x/net/context"
+)
+
+// SpecialReadWriteFile represents a TLF file whose contents are
+// determined by a function, and where a write is handed to another
+// function.
+type SpecialReadWriteFile struct {
+	folder *Folder
+	name   string
+	read   func(context.Context) ([]byte, error)
+	write  func(context.Context, []byte) error
+}
+
+// NewConflictsFile returns a special file that lists the conflict
+// copies made by conflict resolution in the TLF, where a write of a
+// JSON-encoded libfs.ConflictResolution picks the winner of one of
+// them.
+func NewConflictsFile(folder *Folder) *SpecialReadWriteFile {
+	fb := folder.getFolderBranch
+	return &SpecialReadWriteFile{
+		folder: folder,
+		name:   libfs.ConflictsFileName,
+		read: func(ctx context.Context) ([]byte, error) {
+			data, _, err := libfs.GetEncodedConflicts(
+				ctx, folder.fs.config, fb())
+			return data, err
+		},
+		write: func(ctx context.Context, data []byte) error {
+			return libfs.ResolveEncodedConflict(
+				ctx, folder.fs.config, fb(), data)
+		},
+	}
+}
+
+// NewConflictRenamingFile returns a special file containing the
+// JSON-encoded libkbfs.ConflictRenaming of the TLF, where a write of
+// a new one replaces it.
+func NewConflictRenamingFile(folder *Folder) *SpecialReadWriteFile {
+	fb := folder.getFolderBranch
+	return &SpecialReadWriteFile{
+		folder: folder,
+		name:   libfs.ConflictRenamingFileName,
+		read: func(ctx context.Context) ([]byte, error) {
+			data, _, err := libfs.GetEncodedConflictRenaming(
+				ctx, folder.fs.config, fb())
+			return data, err
+		},
+		write: func(ctx context.Context, data []byte) error {
+			return libfs.SetEncodedConflictRenaming(
+				ctx, folder.fs.config, fb(), data)
+		},
+	}
+}
+
+// NewJournalFlushPriorityFile returns a special file containing the
+// flush priority of the journal of the TLF, where a write of a new
+// one replaces it.
+func NewJournalFlushPriorityFile(folder *Folder) *SpecialReadWriteFile {
+	fb := folder.getFolderBranch
+	return &SpecialReadWriteFile{
+		folder: folder,
+		name:   libfs.JournalFlushPriorityFileName,
+		read: func(ctx context.Context) ([]byte, error) {
+			data, _, err := libfs.GetEncodedJournalFlushPriority(
+				ctx, folder.fs.config, fb().Tlf)
+			return data, err
+		},
+		write: func(ctx context.Context, data []byte) error {
+			return libfs.SetEncodedJournalFlushPriority(
+				ctx, folder.fs.config, fb().Tlf, data)
+		},
+	}
+}
+
+var _ fs.Node = (*SpecialReadWriteFile)(nil)
+
+// Attr implements the fs.Node interface for SpecialReadWriteFile.
+func (f *SpecialReadWriteFile) Attr(ctx context.Context, a *fuse.Attr) error {
+	data, err := f.read(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Like SpecialReadFile, return the actual (but racy) size.
+	a.Size = uint64(len(data))
+	a.Mode = 0644
+	return nil
+}
+
+var _ fs.NodeOpener = (*SpecialReadWriteFile)(nil)
+
+// Open implements the fs.NodeOpener interface for SpecialReadWriteFile.
+func (f *SpecialReadWriteFile) Open(ctx context.Context,
+	req *fuse.OpenRequest, resp *fuse.OpenResponse) (fs.Handle, error) {
+	resp.Flags |= fuse.OpenDirectIO
+	return f, nil
+}
+
+var _ fs.Handle = (*SpecialReadWriteFile)(nil)
+
+var _ fs.HandleReadAller = (*SpecialReadWriteFile)(nil)
+
+// ReadAll implements the fs.HandleReadAller interface for
+// SpecialReadWriteFile.
+func (f *SpecialReadWriteFile) ReadAll(ctx context.Context) ([]byte, error) {
+	return f.read(ctx)
+}
+
+var _ fs.HandleWriter = (*SpecialReadWriteFile)(nil)
+
+// Write implements the fs.HandleWriter interface for
+// SpecialReadWriteFile.
+func (f *SpecialReadWriteFile) Write(ctx context.Context,
+	req *fuse.WriteRequest, resp *fuse.WriteResponse) (err error) {
+	f.folder.fs.log.CDebugf(ctx, "SpecialReadWriteFile (%s) Write", f.name)
+	defer func() { err = f.folder.processError(ctx, libkbfs.WriteMode, err) }()
+	if len(req.Data) == 0 {
+		return nil
+	}
+
+	err = f.write(ctx, req.Data)
+	if err != nil {
+		return err
+	}
+
+	resp.Size = len(req.Data)
+	return nil
+}
@@ -0,0 +1,251 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libkbfs
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+	"golang.org/x/time/rate"
+)
+
+// BandwidthClass is a class of block transfers that can be rate
+// limited separately from the others.
+type BandwidthClass int
+
+const (
+	// BandwidthClassForeground is for blocks that someone is waiting
+	// on, like on-demand reads and writes without a journal.
+	BandwidthClassForeground BandwidthClass = iota
+	// BandwidthClassPrefetch is for blocks fetched by the prefetcher.
+	BandwidthClassPrefetch
+	// BandwidthClassJournalFlush is for blocks flushed from a journal
+	// to the server.
+	BandwidthClassJournalFlush
+
+	numBandwidthClasses
+)
+
+func (c BandwidthClass) String() string {
+	switch c {
+	case BandwidthClassForeground:
+		return "foreground"
+	case BandwidthClassPrefetch:
+		return "prefetch"
+	case BandwidthClassJournalFlush:
+		return "journal_flush"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText implements the encoding.TextMarshaler interface for
+// BandwidthClass, so it can be used as a JSON map key.
+func (c BandwidthClass) MarshalText() ([]byte, error) {
+	if c < 0 || c >= numBandwidthClasses {
+		return nil, errors.Errorf("Unknown bandwidth class %d", int(c))
+	}
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface for
+// BandwidthClass.
+func (c *BandwidthClass) UnmarshalText(text []byte) error {
+	for class := BandwidthClass(0); class < numBandwidthClasses; class++ {
+		if class.String() == string(text) {
+			*c = class
+			return nil
+		}
+	}
+	return errors.Errorf("Unknown bandwidth class %q", text)
+}
+
+// BandwidthLimits maps each class of block transfers to its limit, in
+// bytes per second.  A class without a positive limit is unlimited.
+type BandwidthLimits map[BandwidthClass]int64
+
+// bandwidthScheduleTimeFormat is the format of the times of day in a
+// BandwidthSchedule.
+const bandwidthScheduleTimeFormat = "15:04"
+
+// BandwidthScheduleEntry overrides some bandwidth limits during part
+// of each day, between Start and End (local time, in "15:04"
+// format).  If End is before Start, the entry wraps around midnight;
+// if they're equal, it covers the whole day.
+type BandwidthScheduleEntry struct {
+	Start  string          `json:"start"`
+	End    string          `json:"end"`
+	Limits BandwidthLimits `json:"limits"`
+}
+
+// active returns whether the entry applies at the given time.
+func (e BandwidthScheduleEntry) active(t time.Time) bool {
+	start, err := time.Parse(bandwidthScheduleTimeFormat, e.Start)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse(bandwidthScheduleTimeFormat, e.End)
+	if err != nil {
+		return false
+	}
+	minute := func(t time.Time) int {
+		return t.Hour()*60 + t.Minute()
+	}
+	now, startMin, endMin := minute(t), minute(start), minute(end)
+	switch {
+	case startMin < endMin:
+		return startMin <= now && now < endMin
+	case startMin > endMin:
+		return now >= startMin || now < endMin
+	default:
+		return true
+	}
+}
+
+// BandwidthSchedule describes the bandwidth limits for block
+// transfers.  The first entry of Schedule that is active and has a
+// limit for a class overrides the default in Limits for that class.
+type BandwidthSchedule struct {
+	Limits   BandwidthLimits          `json:"limits,omitempty"`
+	Schedule []BandwidthScheduleEntry `json:"schedule,omitempty"`
+}
+
+// ParseBandwidthSchedule parses and validates a JSON-encoded
+// BandwidthSchedule.
+func ParseBandwidthSchedule(data []byte) (s BandwidthSchedule, err error) {
+	err = json.Unmarshal(data, &s)
+	if err != nil {
+		return BandwidthSchedule{}, errors.WithStack(err)
+	}
+	for _, e := range s.Schedule {
+		for _, t := range []string{e.Start, e.End} {
+			_, err := time.Parse(bandwidthScheduleTimeFormat, t)
+			if err != nil {
+				return BandwidthSchedule{}, errors.Errorf(
+					"Invalid time of day %q in bandwidth schedule", t)
+			}
+		}
+	}
+	return s, nil
+}
+
+// limitAt returns the limit for the given class at the given time,
+// in bytes per second, or 0 if it's unlimited.
+func (s BandwidthSchedule) limitAt(class BandwidthClass, t time.Time) int64 {
+	for _, e := range s.Schedule {
+		if limit, ok := e.Limits[class]; ok && e.active(t) {
+			if limit < 0 {
+				return 0
+			}
+			return limit
+		}
+	}
+	if limit := s.Limits[class]; limit > 0 {
+		return limit
+	}
+	return 0
+}
+
+// BandwidthLimiter rate-limits block transfers according to a
+// BandwidthSchedule.  It's goroutine-safe.
+type BandwidthLimiter struct {
+	config clockGetter
+
+	lock     sync.Mutex
+	schedule BandwidthSchedule
+	// limits and limiters hold the limit currently in effect for
+	// each class, and the corresponding limiter (nil if unlimited).
+	limits   [numBandwidthClasses]int64
+	limiters [numBandwidthClasses]*rate.Limiter
+}
+
+func newBandwidthLimiter(config clockGetter) *BandwidthLimiter {
+	return &BandwidthLimiter{config: config}
+}
+
+// Schedule returns the current bandwidth schedule.
+func (bl *BandwidthLimiter) Schedule() BandwidthSchedule {
+	bl.lock.Lock()
+	defer bl.lock.Unlock()
+	return bl.schedule
+}
+
+// SetSchedule replaces the bandwidth schedule.  It takes effect for
+// the next block transfer.
+func (bl *BandwidthLimiter) SetSchedule(s BandwidthSchedule) {
+	bl.lock.Lock()
+	defer bl.lock.Unlock()
+	bl.schedule = s
+}
+
+// getLimiter returns the limiter currently in effect for the given
+// class, or nil if the class is unlimited.  The burst size of a
+// limiter is one second's worth of transfers.
+func (bl *BandwidthLimiter) getLimiter(class BandwidthClass) *rate.Limiter {
+	bl.lock.Lock()
+	defer bl.lock.Unlock()
+	limit := bl.schedule.limitAt(class, bl.config.Clock().Now())
+	if limit != bl.limits[class] {
+		bl.limits[class] = limit
+		bl.limiters[class] = nil
+		if limit > 0 {
+			bl.limiters[class] = rate.NewLimiter(
+				rate.Limit(limit), int(limit))
+		}
+	}
+	return bl.limiters[class]
+}
+
+// Wait blocks until `n` more bytes of the given class may be
+// transferred, or until the context is canceled.
+func (bl *BandwidthLimiter) Wait(
+	ctx context.Context, class BandwidthClass, n int) error {
+	if bl == nil {
+		return nil
+	}
+	limiter := bl.getLimiter(class)
+	if limiter == nil {
+		return nil
+	}
+	// Transfers bigger than the burst size have to wait in chunks.
+	for n > 0 {
+		chunk := n
+		if chunk > limiter.Burst() {
+			chunk = limiter.Burst()
+		}
+		err := limiter.WaitN(ctx, chunk)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		n -= chunk
+	}
+	return nil
+}
+
+type ctxBandwidthClassKeyType int
+
+const (
+	ctxBandwidthClassKey ctxBandwidthClassKeyType = iota
+)
+
+// withBandwidthClass returns a context that marks block transfers
+// made with it as being of the given class.
+func withBandwidthClass(
+	ctx context.Context, class BandwidthClass) context.Context {
+	return context.WithValue(ctx, ctxBandwidthClassKey, class)
+}
+
+// bandwidthClassFromContext returns the class of block transfers made
+// with the given context, which is BandwidthClassForeground unless
+// marked otherwise.
+func bandwidthClassFromContext(ctx context.Context) BandwidthClass {
+	if class, ok := ctx.Value(ctxBandwidthClassKey).(BandwidthClass); ok {
+		return class
+	}
+	return BandwidthClassForeground
+}
@@ -0,0 +1,135 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libkbfs
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+func TestBandwidthScheduleParse(t *testing.T) {
+	s, err := ParseBandwidthSchedule([]byte(`{
+		"limits": {"prefetch": 1000, "journal_flush": 2000},
+		"schedule": [
+			{"start": "22:00", "end": "06:00",
+			 "limits": {"journal_flush": 0}},
+			{"start": "09:00", "end": "17:00",
+			 "limits": {"journal_flush": 500, "foreground": 100}}
+		]
+	}`))
+	require.NoError(t, err)
+	require.Equal(t, BandwidthLimits{
+		BandwidthClassPrefetch:     1000,
+		BandwidthClassJournalFlush: 2000,
+	}, s.Limits)
+	require.Len(t, s.Schedule, 2)
+
+	t.Log("The schedule survives a round trip through JSON.")
+	data, err := json.Marshal(s)
+	require.NoError(t, err)
+	s2, err := ParseBandwidthSchedule(data)
+	require.NoError(t, err)
+	require.Equal(t, s, s2)
+
+	_, err = ParseBandwidthSchedule([]byte(`{"limits": {"upload": 1}}`))
+	require.Error(t, err)
+	_, err = ParseBandwidthSchedule([]byte(
+		`{"schedule": [{"start": "9am", "end": "17:00"}]}`))
+	require.Error(t, err)
+}
+
+func TestBandwidthScheduleLimitAt(t *testing.T) {
+	s := BandwidthSchedule{
+		Limits: BandwidthLimits{
+			BandwidthClassPrefetch:     1000,
+			BandwidthClassJournalFlush: 2000,
+		},
+		Schedule: []BandwidthScheduleEntry{
+			{"22:00", "06:00", BandwidthLimits{
+				BandwidthClassJournalFlush: 0,
+			}},
+			{"09:00", "17:00", BandwidthLimits{
+				BandwidthClassJournalFlush: 500,
+				BandwidthClassForeground:   100,
+			}},
+		},
+	}
+	at := func(hour, min int) time.Time {
+		return time.Date(2018, 6, 1, hour, min, 0, 0, time.Local)
+	}
+
+	t.Log("Outside of any entry, the defaults apply.")
+	require.Equal(t, int64(0), s.limitAt(BandwidthClassForeground, at(8, 0)))
+	require.Equal(t, int64(1000), s.limitAt(BandwidthClassPrefetch, at(8, 0)))
+	require.Equal(t,
+		int64(2000), s.limitAt(BandwidthClassJournalFlush, at(8, 0)))
+
+	t.Log("During the day, the second entry applies, but only to the " +
+		"classes it lists.")
+	require.Equal(t, int64(100), s.limitAt(BandwidthClassForeground, at(9, 0)))
+	require.Equal(t, int64(1000), s.limitAt(BandwidthClassPrefetch, at(12, 0)))
+	require.Equal(t,
+		int64(500), s.limitAt(BandwidthClassJournalFlush, at(16, 59)))
+	require.Equal(t,
+		int64(2000), s.limitAt(BandwidthClassJournalFlush, at(17, 0)))
+
+	t.Log("The first entry wraps around midnight, and lifts the limit.")
+	require.Equal(t,
+		int64(0), s.limitAt(BandwidthClassJournalFlush, at(23, 30)))
+	require.Equal(t, int64(0), s.limitAt(BandwidthClassJournalFlush, at(3, 0)))
+	require.Equal(t,
+		int64(2000), s.limitAt(BandwidthClassJournalFlush, at(6, 0)))
+}
+
+type testBandwidthClockGetter struct {
+	clock Clock
+}
+
+func (c testBandwidthClockGetter) Clock() Clock {
+	return c.clock
+}
+
+func TestBandwidthLimiterWait(t *testing.T) {
+	clock := &TestClock{}
+	clock.Set(time.Date(2018, 6, 1, 12, 0, 0, 0, time.Local))
+	bl := newBandwidthLimiter(testBandwidthClockGetter{clock})
+	bl.SetSchedule(BandwidthSchedule{
+		Limits: BandwidthLimits{BandwidthClassPrefetch: 1000},
+		Schedule: []BandwidthScheduleEntry{
+			{"18:00", "19:00", BandwidthLimits{BandwidthClassPrefetch: 0}},
+		},
+	})
+	ctx := context.Background()
+
+	t.Log("Unlimited classes never wait.")
+	require.NoError(t, bl.Wait(ctx, BandwidthClassForeground, 1<<30))
+
+	t.Log("A limited class can use up its burst right away, and then " +
+		"has to wait.")
+	require.NoError(t, bl.Wait(ctx, BandwidthClassPrefetch, 1000))
+	shortCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	require.Error(t, bl.Wait(shortCtx, BandwidthClassPrefetch, 1000))
+
+	t.Log("Once the schedule lifts the limit, there's no more waiting.")
+	clock.Set(time.Date(2018, 6, 1, 18, 30, 0, 0, time.Local))
+	require.NoError(t, bl.Wait(shortCtx, BandwidthClassPrefetch, 1<<30))
+
+	t.Log("A nil limiter doesn't limit anything.")
+	var nilLimiter *BandwidthLimiter
+	require.NoError(t, nilLimiter.Wait(ctx, BandwidthClassPrefetch, 1<<30))
+}
+
+func TestBandwidthClassFromContext(t *testing.T) {
+	ctx := context.Background()
+	require.Equal(t, BandwidthClassForeground, bandwidthClassFromContext(ctx))
+	ctx = withBandwidthClass(ctx, BandwidthClassJournalFlush)
+	require.Equal(t,
+		BandwidthClassJournalFlush, bandwidthClassFromContext(ctx))
+}
@@ -10,6 +10,7 @@ import (
 	"sync/atomic"
 
 	lru "github.com/hashicorp/golang-lru"
+	"github.com/keybase/kbfs/cache"
 	"github.com/keybase/kbfs/kbfsblock"
 	"github.com/keybase/kbfs/kbfshash"
 	"github.com/keybase/kbfs/tlf"
@@ -19,6 +20,15 @@ import (
 type blockContainer struct {
 	block          Block
 	prefetchStatus PrefetchStatus
+	// ptr and tlfID are kept to be able to fetch the block again
+	// after a restart; see BlockCacheStandard.snapshot.
+	ptr   BlockPointer
+	tlfID tlf.ID
+}
+
+// Size implements the cache.Measurable interface for blockContainer.
+func (bc blockContainer) Size() int {
+	return int(getCachedBlockSize(bc.block))
 }
 
 type idCacheKey struct {
@@ -35,7 +45,7 @@ type BlockCacheStandard struct {
 
 	ids *lru.Cache
 
-	cleanTransient *lru.Cache
+	cleanTransient transientBlockCache
 
 	cleanLock      sync.RWMutex
 	cleanPermanent map[kbfsblock.ID]Block
@@ -73,6 +83,39 @@ func NewBlockCacheStandard(transientCapacity int,
 	return b
 }
 
+// NewBlockCacheStandardWithPolicy is like NewBlockCacheStandard, but
+// transient entries are evicted using the given policy instead of
+// always evicting the least recently used one.
+func NewBlockCacheStandardWithPolicy(policy cache.EvictionPolicy,
+	transientCapacity int, cleanBytesCapacity uint64) (
+	*BlockCacheStandard, error) {
+	if policy == cache.EvictionPolicyLRU || transientCapacity <= 0 {
+		b := NewBlockCacheStandard(transientCapacity, cleanBytesCapacity)
+		if b == nil {
+			return nil, errors.Errorf(
+				"couldn't make a block cache with capacity %d",
+				transientCapacity)
+		}
+		return b, nil
+	}
+
+	b := &BlockCacheStandard{
+		cleanBytesCapacity: cleanBytesCapacity,
+		cleanPermanent:     make(map[kbfsblock.ID]Block),
+	}
+	var err error
+	b.ids, err = lru.New(transientCapacity)
+	if err != nil {
+		return nil, err
+	}
+	b.cleanTransient, err = newEvictableTransientBlockCache(
+		policy, transientCapacity, cleanBytesCapacity, b.onEvict)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
 // GetWithPrefetch implements the BlockCache interface for BlockCacheStandard.
 func (b *BlockCacheStandard) GetWithPrefetch(ptr BlockPointer) (
 	Block, PrefetchStatus, BlockCacheLifetime, error) {
@@ -172,6 +215,9 @@ func (b *BlockCacheStandard) CheckForKnownPtr(tlf tlf.ID, block *FileBlock) (
 // BlockCacheStandard.
 func (b *BlockCacheStandard) SetCleanBytesCapacity(capacity uint64) {
 	atomic.StoreUint64(&b.cleanBytesCapacity, capacity)
+	if etbc, ok := b.cleanTransient.(*evictableTransientBlockCache); ok {
+		etbc.setMaxBytes(capacity)
+	}
 }
 
 // GetCleanBytesCapacity implements the BlockCache interface for
@@ -262,6 +308,7 @@ func (b *BlockCacheStandard) PutWithPrefetch(
 	}
 
 	var wasInCache bool
+	container := blockContainer{block, prefetchStatus, ptr, tlf}
 
 	switch lifetime {
 	case TransientEntry:
@@ -319,7 +366,8 @@ func (b *BlockCacheStandard) PutWithPrefetch(
 		if !transientCacheHasRoom {
 			return cachePutCacheFullError{ptr.ID}
 		}
-		b.cleanTransient.Add(ptr.ID, blockContainer{block, prefetchStatus})
+		container.prefetchStatus = prefetchStatus
+		b.cleanTransient.Add(ptr.ID, container)
 	}
 
 	return nil
@@ -390,3 +438,112 @@ func (b *BlockCacheStandard) DeleteKnownPtr(tlf tlf.ID, block *FileBlock) error
 	b.ids.Remove(key)
 	return nil
 }
+
+// transientBlockCache is the subset of the *lru.Cache methods that
+// BlockCacheStandard uses for its transient entries, so that other
+// eviction policies can be plugged in.
+type transientBlockCache interface {
+	Get(key interface{}) (value interface{}, ok bool)
+	Add(key, value interface{}) (evicted bool)
+	Remove(key interface{})
+	RemoveOldest()
+	Len() int
+	// Keys returns the keys of the cache, from the first to be
+	// evicted to the last.
+	Keys() []interface{}
+}
+
+var _ transientBlockCache = (*lru.Cache)(nil)
+
+// transientBlockCacheKey makes a kbfsblock.ID measurable.
+type transientBlockCacheKey struct {
+	id kbfsblock.ID
+}
+
+// Size implements the cache.Measurable interface for
+// transientBlockCacheKey.  BlockCacheStandard only counts the bytes
+// of the blocks themselves against its capacity, so keys are free.
+func (transientBlockCacheKey) Size() int {
+	return 0
+}
+
+// evictableTransientBlockCache implements transientBlockCache on top
+// of a cache.EvictableCache.  Like the LRU cache, it holds at most a
+// fixed number of entries.  "Oldest" means whichever entry the
+// eviction policy picks.
+type evictableTransientBlockCache struct {
+	cache    cache.EvictableCache
+	capacity int
+}
+
+var _ transientBlockCache = (*evictableTransientBlockCache)(nil)
+
+// clampCacheBytes converts a byte capacity to the int that the cache
+// package takes.
+func clampCacheBytes(bytes uint64) int {
+	const maxInt = int(^uint(0) >> 1)
+	if bytes > uint64(maxInt) {
+		return maxInt
+	}
+	return int(bytes)
+}
+
+func newEvictableTransientBlockCache(policy cache.EvictionPolicy,
+	capacity int, cleanBytesCapacity uint64,
+	onEvict func(key interface{}, value interface{})) (
+	*evictableTransientBlockCache, error) {
+	c, err := cache.NewEvictableCache(
+		policy, clampCacheBytes(cleanBytesCapacity),
+		func(key cache.Measurable, value cache.Measurable) {
+			onEvict(key.(transientBlockCacheKey).id, value)
+		})
+	if err != nil {
+		return nil, err
+	}
+	return &evictableTransientBlockCache{c, capacity}, nil
+}
+
+func (etbc *evictableTransientBlockCache) Get(key interface{}) (
+	value interface{}, ok bool) {
+	return etbc.cache.Get(transientBlockCacheKey{key.(kbfsblock.ID)})
+}
+
+func (etbc *evictableTransientBlockCache) Add(key, value interface{}) (
+	evicted bool) {
+	etbc.cache.Add(
+		transientBlockCacheKey{key.(kbfsblock.ID)}, value.(blockContainer))
+	for etbc.cache.Len() > etbc.capacity {
+		evicted = etbc.cache.EvictOne() || evicted
+	}
+	return evicted
+}
+
+func (etbc *evictableTransientBlockCache) Remove(key interface{}) {
+	etbc.cache.Remove(transientBlockCacheKey{key.(kbfsblock.ID)})
+}
+
+func (etbc *evictableTransientBlockCache) RemoveOldest() {
+	etbc.cache.EvictOne()
+}
+
+func (etbc *evictableTransientBlockCache) Keys() []interface{} {
+	lister, ok := etbc.cache.(cache.KeyLister)
+	if !ok {
+		return nil
+	}
+	// The lister starts with the last entry to be evicted.
+	cacheKeys := lister.Keys()
+	keys := make([]interface{}, len(cacheKeys))
+	for i, key := range cacheKeys {
+		keys[len(keys)-1-i] = key.(transientBlockCacheKey).id
+	}
+	return keys
+}
+
+func (etbc *evictableTransientBlockCache) Len() int {
+	return etbc.cache.Len()
+}
+
+func (etbc *evictableTransientBlockCache) setMaxBytes(bytes uint64) {
+	etbc.cache.SetMaxBytes(clampCacheBytes(bytes))
+}
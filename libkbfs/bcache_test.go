@@ -5,8 +5,11 @@
 package libkbfs
 
 import (
+	"os"
 	"testing"
 
+	"github.com/keybase/kbfs/cache"
+	"github.com/keybase/kbfs/ioutil"
 	"github.com/keybase/kbfs/kbfsblock"
 	"github.com/keybase/kbfs/kbfshash"
 	"github.com/keybase/kbfs/tlf"
@@ -378,3 +381,146 @@ func TestBlockCacheDoublePut(t *testing.T) {
 	testBcachePutWithBlock(t, id2, cache, TransientEntry, block)
 	require.Equal(t, bytes, cache.cleanTotalBytes)
 }
+
+func TestBlockCacheARC(t *testing.T) {
+	ctx := context.Background()
+	b, err := NewBlockCacheStandardWithPolicy(cache.EvictionPolicyARC, 1000, 8)
+	require.NoError(t, err)
+	config := MakeTestConfigOrBust(t, "test")
+	config.SetBlockCache(b)
+	defer CheckConfigAndShutdown(ctx, t, config)
+
+	tlf := tlf.FakeID(1, tlf.Private)
+	put := func(i byte) {
+		block := &FileBlock{
+			Contents: make([]byte, 1),
+		}
+		err := b.Put(BlockPointer{ID: kbfsblock.FakeID(i)}, tlf, block,
+			TransientEntry)
+		require.NoError(t, err)
+	}
+
+	t.Log("Read a small hot set of blocks twice.")
+	for round := 0; round < 2; round++ {
+		for i := byte(0); i < 4; i++ {
+			if _, err := b.Get(BlockPointer{ID: kbfsblock.FakeID(i)}); err != nil {
+				put(i)
+			}
+		}
+	}
+	require.Equal(t, uint64(4), b.cleanTotalBytes)
+
+	t.Log("A scan through many other blocks doesn't evict the hot set.")
+	for i := byte(100); i < 200; i++ {
+		put(i)
+	}
+	require.Equal(t, uint64(8), b.cleanTotalBytes)
+	for i := byte(0); i < 4; i++ {
+		_, err := b.Get(BlockPointer{ID: kbfsblock.FakeID(i)})
+		require.NoError(t, err)
+	}
+
+	t.Log("Deleting a block frees up its bytes.")
+	err = b.DeleteTransient(BlockPointer{ID: kbfsblock.FakeID(0)}, tlf)
+	require.NoError(t, err)
+	testExpectedMissing(t, kbfsblock.FakeID(0), b)
+	require.Equal(t, uint64(7), b.cleanTotalBytes)
+
+	t.Log("Shrinking the capacity evicts blocks.")
+	b.SetCleanBytesCapacity(2)
+	require.Equal(t, 2, b.cleanTransient.Len())
+	require.Equal(t, uint64(2), b.cleanTotalBytes)
+}
+
+func TestBlockCacheSnapshot(t *testing.T) {
+	ctx := context.Background()
+	config := blockCacheTestInit(t, 100, 1<<30)
+	defer CheckConfigAndShutdown(ctx, t, config)
+	bcache := config.BlockCache().(*BlockCacheStandard)
+
+	tlfID := tlf.FakeID(1, tlf.Private)
+	ptrs := make([]BlockPointer, 3)
+	for i := range ptrs {
+		ptrs[i] = BlockPointer{ID: kbfsblock.FakeID(byte(i + 1))}
+	}
+	err := bcache.Put(ptrs[0], tlfID, NewDirBlock(), TransientEntry)
+	require.NoError(t, err)
+	err = bcache.Put(ptrs[1], tlfID, NewFileBlock(), TransientEntry)
+	require.NoError(t, err)
+	err = bcache.Put(ptrs[2], tlfID, NewFileBlock(), TransientEntry)
+	require.NoError(t, err)
+	err = bcache.Put(BlockPointer{ID: kbfsblock.FakeID(4)}, tlfID,
+		NewFileBlock(), PermanentEntry)
+	require.NoError(t, err)
+
+	t.Log("The snapshot starts with the most recently used transient " +
+		"blocks, and is bounded.")
+	_, err = bcache.Get(ptrs[0])
+	require.NoError(t, err)
+	s := bcache.snapshot(2)
+	require.Equal(t, []blockCacheSnapshotEntry{
+		{TlfID: tlfID, Ptr: ptrs[0], IsDir: true},
+		{TlfID: tlfID, Ptr: ptrs[2]},
+	}, s.Entries)
+
+	t.Log("Taking a snapshot doesn't change the order of the cache.")
+	require.Equal(t, s, bcache.snapshot(2))
+
+	t.Log("A saved snapshot is only loaded once.")
+	tempdir, err := ioutil.TempDir(os.TempDir(), "bcache_snapshot")
+	require.NoError(t, err)
+	defer func() {
+		err := ioutil.RemoveAll(tempdir)
+		require.NoError(t, err)
+	}()
+	numBlocks, err := saveBlockCacheSnapshot(
+		config.Codec(), tempdir, bcache, 10)
+	require.NoError(t, err)
+	require.Equal(t, 3, numBlocks)
+	loaded, err := loadBlockCacheSnapshot(config.Codec(), tempdir)
+	require.NoError(t, err)
+	require.Len(t, loaded.Entries, 3)
+	require.Equal(t, s.Entries, loaded.Entries[:2])
+	loaded, err = loadBlockCacheSnapshot(config.Codec(), tempdir)
+	require.NoError(t, err)
+	require.Len(t, loaded.Entries, 0)
+}
+
+func TestBlockCacheWarmStart(t *testing.T) {
+	config, _, ctx, cancel := kbfsOpsInitNoMocks(t, "test")
+	defer kbfsTestShutdownNoMocks(t, config, ctx, cancel)
+	dbc, _ := initDiskBlockCacheTest(t)
+	config.diskBlockCache = dbc
+
+	t.Log("Write a file, and snapshot the blocks in the cache.")
+	rootNode := GetRootNodeOrBust(ctx, t, config, "test", tlf.Private)
+	kbfsOps := config.KBFSOps()
+	fileNode, _, err := kbfsOps.CreateFile(
+		ctx, rootNode, "a", false, NoExcl)
+	require.NoError(t, err)
+	err = kbfsOps.Write(ctx, fileNode, []byte("hello"), 0)
+	require.NoError(t, err)
+	err = kbfsOps.SyncAll(ctx, rootNode.GetFolderBranch())
+	require.NoError(t, err)
+	s := config.BlockCache().(*BlockCacheStandard).snapshot(100)
+	require.NotEmpty(t, s.Entries)
+
+	t.Log("Only blocks in the disk cache are loaded into a cold cache.")
+	for _, e := range s.Entries[1:] {
+		buf, serverHalf, err := config.BlockServer().Get(
+			ctx, e.TlfID, e.Ptr.ID, e.Ptr.Context)
+		require.NoError(t, err)
+		err = dbc.Put(ctx, e.TlfID, e.Ptr.ID, buf, serverHalf)
+		require.NoError(t, err)
+	}
+	config.SetBlockCache(NewBlockCacheStandard(100, 1<<30))
+	numBlocks, err := warmBlockCache(ctx, config, s)
+	require.NoError(t, err)
+	require.Equal(t, len(s.Entries)-1, numBlocks)
+	_, err = config.BlockCache().Get(s.Entries[0].Ptr)
+	require.Error(t, err)
+	for _, e := range s.Entries[1:] {
+		_, err = config.BlockCache().Get(e.Ptr)
+		require.NoError(t, err)
+	}
+}
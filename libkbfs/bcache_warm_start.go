@@ -0,0 +1,208 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libkbfs
+
+import (
+	"path/filepath"
+
+	"github.com/keybase/go-codec/codec"
+	"github.com/keybase/kbfs/ioutil"
+	"github.com/keybase/kbfs/kbfscodec"
+	"github.com/keybase/kbfs/tlf"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+const blockCacheSnapshotFileName = "kbfs_block_cache_snapshot"
+
+type ctxBlockCacheWarmStartTagKey int
+
+const (
+	// ctxBlockCacheWarmStartIDKey is the type of the tag for the
+	// unique ID of a block cache warm-up.
+	ctxBlockCacheWarmStartIDKey ctxBlockCacheWarmStartTagKey = iota
+)
+
+// ctxBlockCacheWarmStartOpID is the display name for the unique ID
+// of a block cache warm-up.
+const ctxBlockCacheWarmStartOpID = "BCWID"
+
+// blockCacheSnapshotEntry records one block that was in the block
+// cache at shutdown.  The pointer is recorded, rather than the path
+// of the block, so that the block can be found again without asking
+// the servers for directory entries.
+type blockCacheSnapshotEntry struct {
+	TlfID tlf.ID       `codec:"t"`
+	Ptr   BlockPointer `codec:"p"`
+	IsDir bool         `codec:"d,omitempty"`
+
+	codec.UnknownFieldSetHandler
+}
+
+func (e blockCacheSnapshotEntry) newBlock() Block {
+	if e.IsDir {
+		return NewDirBlock()
+	}
+	return NewFileBlock()
+}
+
+// blockCacheSnapshot is the set of hot blocks of a block cache, from
+// the hottest to the coldest.
+type blockCacheSnapshot struct {
+	Entries []blockCacheSnapshotEntry `codec:"e"`
+
+	codec.UnknownFieldSetHandler
+}
+
+// snapshot returns up to `maxBlocks` of the transient blocks in the
+// cache, starting with the ones that would be evicted last.
+func (b *BlockCacheStandard) snapshot(maxBlocks int) blockCacheSnapshot {
+	if b.cleanTransient == nil || maxBlocks <= 0 {
+		return blockCacheSnapshot{}
+	}
+	// Go from the coldest entry to the hottest one, so that the
+	// lookups below leave the entries in the same order.
+	var entries []blockCacheSnapshotEntry
+	for _, key := range b.cleanTransient.Keys() {
+		tmp, ok := b.cleanTransient.Get(key)
+		if !ok {
+			continue
+		}
+		bc, ok := tmp.(blockContainer)
+		if !ok || bc.ptr == (BlockPointer{}) {
+			continue
+		}
+		_, isDir := bc.block.(*DirBlock)
+		entries = append(entries, blockCacheSnapshotEntry{
+			TlfID: bc.tlfID,
+			Ptr:   bc.ptr,
+			IsDir: isDir,
+		})
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	if len(entries) > maxBlocks {
+		entries = entries[:maxBlocks]
+	}
+	return blockCacheSnapshot{Entries: entries}
+}
+
+func blockCacheSnapshotPath(storageRoot string) string {
+	return filepath.Join(storageRoot, blockCacheSnapshotFileName)
+}
+
+// saveBlockCacheSnapshot writes the snapshot of the given block cache
+// under `storageRoot`.
+func saveBlockCacheSnapshot(codec kbfscodec.Codec, storageRoot string,
+	bcache BlockCache, maxBlocks int) (numBlocks int, err error) {
+	b, ok := bcache.(*BlockCacheStandard)
+	if !ok {
+		return 0, errors.Errorf(
+			"Can't snapshot a block cache of type %T", bcache)
+	}
+	s := b.snapshot(maxBlocks)
+	err = ioutil.MkdirAll(storageRoot, 0700)
+	if err != nil {
+		return 0, err
+	}
+	err = kbfscodec.SerializeToFile(
+		codec, s, blockCacheSnapshotPath(storageRoot))
+	if err != nil {
+		return 0, err
+	}
+	return len(s.Entries), nil
+}
+
+// loadBlockCacheSnapshot reads and removes the snapshot saved under
+// `storageRoot`, if any.  It's removed so that a crash doesn't make
+// the next start warm up the same, possibly stale, blocks again.
+func loadBlockCacheSnapshot(codec kbfscodec.Codec, storageRoot string) (
+	s blockCacheSnapshot, err error) {
+	path := blockCacheSnapshotPath(storageRoot)
+	err = kbfscodec.DeserializeFromFile(codec, path, &s)
+	switch {
+	case ioutil.IsNotExist(err):
+		return blockCacheSnapshot{}, nil
+	case err != nil:
+		return blockCacheSnapshot{}, err
+	}
+	err = ioutil.Remove(path)
+	if err != nil && !ioutil.IsNotExist(err) {
+		return blockCacheSnapshot{}, err
+	}
+	return s, nil
+}
+
+type blockCacheWarmStartConfig interface {
+	blockCacher
+	diskBlockCacheGetter
+	codecGetter
+	cryptoPureGetter
+	keyGetterGetter
+	MDOps() MDOps
+}
+
+// warmBlockCache loads the blocks of the given snapshot from the disk
+// block cache into the block cache, until the block cache is full or
+// the context is canceled.  Blocks that aren't in the disk cache are
+// skipped, rather than fetched from the server.  It returns the
+// number of blocks it loaded.
+func warmBlockCache(ctx context.Context, config blockCacheWarmStartConfig,
+	s blockCacheSnapshot) (numBlocks int, err error) {
+	dbc := config.DiskBlockCache()
+	if dbc == nil {
+		return 0, nil
+	}
+	bcache := config.BlockCache()
+	kmds := make(map[tlf.ID]KeyMetadata)
+	for _, e := range s.Entries {
+		select {
+		case <-ctx.Done():
+			return numBlocks, errors.WithStack(ctx.Err())
+		default:
+		}
+
+		if _, err := bcache.Get(e.Ptr); err == nil {
+			continue
+		}
+
+		kmd, ok := kmds[e.TlfID]
+		if !ok {
+			// Remember failures too, so each TLF is only looked up
+			// once.
+			head, err := config.MDOps().GetForTLF(ctx, e.TlfID, nil)
+			if err == nil && head != (ImmutableRootMetadata{}) {
+				kmd = head
+			}
+			kmds[e.TlfID] = kmd
+		}
+		if kmd == nil {
+			continue
+		}
+
+		buf, serverHalf, prefetchStatus, err := dbc.Get(ctx, e.TlfID, e.Ptr.ID)
+		if err != nil {
+			continue
+		}
+		block := e.newBlock()
+		err = assembleBlock(ctx, config.keyGetter(), config.Codec(),
+			config.cryptoPure(), kmd, e.Ptr, block, buf, serverHalf)
+		if err != nil {
+			continue
+		}
+		err = bcache.PutWithPrefetch(
+			e.Ptr, e.TlfID, block, TransientEntry, prefetchStatus)
+		switch err.(type) {
+		case nil:
+			numBlocks++
+		case cachePutCacheFullError:
+			return numBlocks, nil
+		default:
+			return numBlocks, err
+		}
+	}
+	return numBlocks, nil
+}
@@ -175,19 +175,19 @@ func deferredGCBlockJournalDir(dir string) string {
 // makeBlockJournal returns a new blockJournal for the given
 // directory. Any existing journal entries are read.
 func makeBlockJournal(
-	ctx context.Context, codec kbfscodec.Codec, dir string,
-	log logger.Logger) (*blockJournal, error) {
+	ctx context.Context, codec kbfscodec.Codec, crypter *journalCrypter,
+	dir string, log logger.Logger) (*blockJournal, error) {
 	journalPath := blockJournalDir(dir)
 	deferLog := log.CloneWithAddedDepth(1)
 	j, err := makeDiskJournal(
-		codec, journalPath, reflect.TypeOf(blockJournalEntry{}))
+		codec, crypter, journalPath, reflect.TypeOf(blockJournalEntry{}))
 	if err != nil {
 		return nil, err
 	}
 
 	gcJournalPath := deferredGCBlockJournalDir(dir)
 	gcj, err := makeDiskJournal(
-		codec, gcJournalPath, reflect.TypeOf(blockJournalEntry{}))
+		codec, crypter, gcJournalPath, reflect.TypeOf(blockJournalEntry{}))
 	if err != nil {
 		return nil, err
 	}
@@ -214,6 +214,18 @@ func makeBlockJournal(
 	return journal, nil
 }
 
+// migrateToEncrypted encrypts any plaintext entries in the journal
+// in place.  The blocks themselves are already encrypted, and the
+// block store only keeps their IDs, refs and server halves, so it's
+// left alone.
+func (j *blockJournal) migrateToEncrypted() error {
+	err := j.j.migrateToEncrypted()
+	if err != nil {
+		return err
+	}
+	return j.deferredGC.migrateToEncrypted()
+}
+
 func (j *blockJournal) blockJournalFiles() []string {
 	return []string{
 		blockJournalDir(j.dir), deferredGCBlockJournalDir(j.dir),
@@ -346,6 +358,30 @@ func (j *blockJournal) end() (journalOrdinal, error) {
 	return last + 1, nil
 }
 
+// getAllEntries returns all the entries in the journal, in order.
+func (j *blockJournal) getAllEntries() ([]blockJournalEntry, error) {
+	first, err := j.j.readEarliestOrdinal()
+	if ioutil.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	last, err := j.j.readLatestOrdinal()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]blockJournalEntry, 0, last-first+1)
+	for ordinal := first; ordinal <= last; ordinal++ {
+		entry, err := j.readJournalEntry(ordinal)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
 func (j *blockJournal) hasData(id kbfsblock.ID) (bool, error) {
 	return j.s.hasData(id)
 }
@@ -608,6 +644,14 @@ func (be blockEntriesToFlush) revIsLocalSquash(rev kbfsmd.Revision) bool {
 	return false
 }
 
+// putBytes returns the number of bytes of block data to put.
+func (be blockEntriesToFlush) putBytes() (n int64) {
+	for _, bs := range be.puts.blockStates {
+		n += int64(bs.readyBlockData.GetEncodedSize())
+	}
+	return n
+}
+
 func (be blockEntriesToFlush) markFlushingBlockIDs(ids map[kbfsblock.ID]bool) {
 	for _, bs := range be.puts.blockStates {
 		ids[bs.blockPtr.ID] = true
@@ -118,7 +118,7 @@ func setupBlockJournalTest(t *testing.T) (
 		}
 	}()
 
-	j, err = makeBlockJournal(ctx, codec, tempdir, log)
+	j, err = makeBlockJournal(ctx, codec, nil, tempdir, log)
 	require.NoError(t, err)
 	require.Equal(t, uint64(0), j.length())
 
@@ -208,7 +208,7 @@ func TestBlockJournalBasic(t *testing.T) {
 	// Shutdown and restart.
 	err := j.checkInSyncForTest()
 	require.NoError(t, err)
-	j, err = makeBlockJournal(ctx, j.codec, tempdir, j.log)
+	j, err = makeBlockJournal(ctx, j.codec, nil, tempdir, j.log)
 	require.NoError(t, err)
 
 	require.Equal(t, uint64(2), j.length())
@@ -22,6 +22,8 @@ type blockOpsConfig interface {
 	diskBlockCacheGetter
 	syncedTlfGetterSetter
 	initModeGetter
+	clockGetter
+	metricsRegistryGetter
 }
 
 // BlockOpsStandard implements the BlockOps interface by relaying
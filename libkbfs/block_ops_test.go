@@ -18,6 +18,7 @@ import (
 	"github.com/keybase/kbfs/kbfsmd"
 	"github.com/keybase/kbfs/tlf"
 	"github.com/pkg/errors"
+	metrics "github.com/rcrowley/go-metrics"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/net/context"
 )
@@ -27,7 +28,7 @@ import (
 // fakeBlockKeyGetter.
 type fakeKeyMetadata struct {
 	// Embed a KeyMetadata that's always empty, so that all
-	// methods besides TlfID() panic.
+	// methods besides TlfID() and LatestKeyGeneration() panic.
 	KeyMetadata
 	tlfID tlf.ID
 	keys  []kbfscrypto.TLFCryptKey
@@ -53,6 +54,10 @@ func (kmd fakeKeyMetadata) TlfID() tlf.ID {
 	return kmd.tlfID
 }
 
+func (kmd fakeKeyMetadata) LatestKeyGeneration() kbfsmd.KeyGen {
+	return kbfsmd.FirstValidKeyGen + kbfsmd.KeyGen(len(kmd.keys)) - 1
+}
+
 type fakeBlockKeyGetter struct{}
 
 func (kg fakeBlockKeyGetter) GetTLFCryptKeyForEncryption(
@@ -87,6 +92,7 @@ type testBlockOpsConfig struct {
 	diskBlockCacheGetter
 	*testSyncedTlfGetterSetter
 	initModeGetter
+	clock Clock
 }
 
 var _ blockOpsConfig = (*testBlockOpsConfig)(nil)
@@ -107,6 +113,14 @@ func (config testBlockOpsConfig) BlockCache() BlockCache {
 	return config.cache
 }
 
+func (config testBlockOpsConfig) Clock() Clock {
+	return config.clock
+}
+
+func (config testBlockOpsConfig) MetricsRegistry() metrics.Registry {
+	return nil
+}
+
 func (config testBlockOpsConfig) DataVersion() DataVer {
 	return ChildHolesDataVer
 }
@@ -120,7 +134,7 @@ func makeTestBlockOpsConfig(t *testing.T) testBlockOpsConfig {
 	dbcg := newTestDiskBlockCacheGetter(t, nil)
 	stgs := newTestSyncedTlfGetterSetter()
 	return testBlockOpsConfig{codecGetter, lm, bserver, crypto, cache, dbcg,
-		stgs, testInitModeGetter{InitDefault}}
+		stgs, testInitModeGetter{InitDefault}, newTestClockNow()}
 }
 
 // TestBlockOpsReadySuccess checks that BlockOpsStandard.Ready()
@@ -691,3 +705,49 @@ func TestBlockOpsArchiveFail(t *testing.T) {
 	err := bops.Archive(ctx, tlfID, []BlockPointer{b1, b2})
 	require.Equal(t, expectedErr, err)
 }
+
+// TestReadyBlockKnownPtrSameBlockType checks that ReadyBlock() only
+// reuses a known block pointer for a block of the same block type.
+func TestReadyBlockKnownPtrSameBlockType(t *testing.T) {
+	config := makeTestBlockOpsConfig(t)
+	bops := NewBlockOpsStandard(config, testBlockRetrievalWorkerQueueSize,
+		testPrefetchWorkerQueueSize)
+	defer bops.Shutdown()
+
+	tlfID := tlf.FakeID(0, tlf.Private)
+	kmd := makeFakeKeyMetadata(tlfID, 1)
+	uid := keybase1.MakeTestUID(1).AsUserOrTeam()
+
+	ctx := context.Background()
+	block := &FileBlock{
+		Contents: []byte{1, 2, 3, 4, 5},
+	}
+	info, _, _, err := ReadyBlock(ctx, config.BlockCache(), bops,
+		config.cryptoPure(), kmd, block, uid, keybase1.BlockType_DATA)
+	require.NoError(t, err)
+	require.True(t, info.IsFirstRef())
+	err = config.BlockCache().Put(
+		info.BlockPointer, tlfID, block, TransientEntry)
+	require.NoError(t, err)
+
+	// A data block with the same contents reuses the known pointer.
+	dataBlock := &FileBlock{
+		Contents: []byte{1, 2, 3, 4, 5},
+	}
+	dataInfo, _, _, err := ReadyBlock(ctx, config.BlockCache(), bops,
+		config.cryptoPure(), kmd, dataBlock, uid, keybase1.BlockType_DATA)
+	require.NoError(t, err)
+	require.Equal(t, info.ID, dataInfo.ID)
+	require.False(t, dataInfo.IsFirstRef())
+
+	// An MD block with the same contents gets a new block.
+	mdBlock := &FileBlock{
+		Contents: []byte{1, 2, 3, 4, 5},
+	}
+	mdInfo, _, _, err := ReadyBlock(ctx, config.BlockCache(), bops,
+		config.cryptoPure(), kmd, mdBlock, uid, keybase1.BlockType_MD)
+	require.NoError(t, err)
+	require.NotEqual(t, info.ID, mdInfo.ID)
+	require.True(t, mdInfo.IsFirstRef())
+	require.Equal(t, keybase1.BlockType_MD, mdInfo.GetBlockType())
+}
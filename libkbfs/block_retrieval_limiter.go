@@ -0,0 +1,263 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libkbfs
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/keybase/kbfs/kbfsblock"
+	"github.com/pkg/errors"
+	metrics "github.com/rcrowley/go-metrics"
+	"golang.org/x/net/context"
+)
+
+const (
+	// adaptiveWorkersMaxFactor is how many times its configured size
+	// a pool of block retrieval workers can grow to.
+	adaptiveWorkersMaxFactor = 4
+	// adaptiveLatencyTolerance is how many times the baseline latency
+	// a block fetch can take before the concurrency is decreased.
+	adaptiveLatencyTolerance = 2
+	// adaptiveDecreaseFactor is what the concurrency is multiplied by
+	// when block fetches get too slow.
+	adaptiveDecreaseFactor = 0.75
+	// adaptiveBaselineDrift controls how fast the baseline latency
+	// rises to follow slower fetches: each fetch moves it by
+	// 1/adaptiveBaselineDrift of the difference.
+	adaptiveBaselineDrift = 64
+)
+
+// blockFetchOutcome is how a block fetch ended, as far as adapting
+// the number of concurrent fetches is concerned.
+type blockFetchOutcome int
+
+const (
+	// blockFetchSkipped is for fetches that say nothing about the
+	// link to the block server, like ones that were canceled, or
+	// never started.
+	blockFetchSkipped blockFetchOutcome = iota
+	// blockFetchSucceeded is for fetches that got their block.
+	blockFetchSucceeded
+	// blockFetchFailed is for fetches that failed or timed out,
+	// which is taken as a sign that the link is congested.
+	blockFetchFailed
+)
+
+// blockFetchOutcomeFromError returns the outcome of a block fetch
+// that started and returned `err`.  Errors in which the block server
+// answered about the block itself aren't counted as congestion.
+func blockFetchOutcomeFromError(err error) blockFetchOutcome {
+	if err == nil {
+		return blockFetchSucceeded
+	}
+	switch errors.Cause(err).(type) {
+	case kbfsblock.ServerErrorBadRequest, kbfsblock.ServerErrorUnauthorized,
+		kbfsblock.ServerErrorOverQuota, kbfsblock.ServerErrorBlockNonExistent,
+		kbfsblock.ServerErrorBlockArchived, kbfsblock.ServerErrorBlockDeleted,
+		kbfsblock.ServerErrorNoPermission,
+		kbfsblock.ServerErrorNonceNonExistent,
+		kbfsblock.ServerErrorMaxRefExceeded:
+		return blockFetchSkipped
+	}
+	switch errors.Cause(err) {
+	case context.Canceled, io.EOF:
+		return blockFetchSkipped
+	}
+	return blockFetchFailed
+}
+
+type ctxBlockFetchLatencyKeyType int
+
+const (
+	// ctxBlockFetchLatencyKey is the type of the tag for the
+	// latency of the block server RPC of a block fetch.
+	ctxBlockFetchLatencyKey ctxBlockFetchLatencyKeyType = iota
+)
+
+// withBlockFetchLatency returns a context in which the block server
+// can record how long the RPC of a block fetch took, not counting
+// any time spent waiting on the bandwidth limiter afterward.  The
+// latency is stored in `latency`, which is left alone if the block
+// server doesn't record it.
+func withBlockFetchLatency(
+	ctx context.Context, latency *time.Duration) context.Context {
+	return context.WithValue(ctx, ctxBlockFetchLatencyKey, latency)
+}
+
+// recordBlockFetchLatency records the latency of the RPC of a block
+// fetch made with the given context, if anyone is interested.
+func recordBlockFetchLatency(ctx context.Context, latency time.Duration) {
+	if p, ok := ctx.Value(ctxBlockFetchLatencyKey).(*time.Duration); ok {
+		*p = latency
+	}
+}
+
+// blockRetrievalLimiter limits how many block fetches of one pool of
+// workers can be in progress at once.  The limit adapts to the link
+// to the block server, using additive increase/multiplicative
+// decrease (AIMD) on the latency of the fetches: as long as fetches
+// take less than adaptiveLatencyTolerance times the baseline (the
+// lowest latency seen lately), a pool that uses its whole limit can
+// grow by one fetch per round trip; once they take longer, or start
+// failing, the link is assumed to be saturated and the limit is cut
+// by adaptiveDecreaseFactor.
+//
+// A nil *blockRetrievalLimiter doesn't limit anything.
+type blockRetrievalLimiter struct {
+	clock    Clock
+	minLimit float64
+	maxLimit float64
+
+	lock     sync.Mutex
+	limit    float64
+	inFlight int
+	// full is set when the pool uses its whole limit, until it's
+	// idle again.
+	full         bool
+	baseline     time.Duration
+	lastDecrease time.Time
+	// changedCh is closed and replaced whenever a fetch finishes.
+	changedCh chan struct{}
+
+	limitGauge    metrics.Gauge
+	inFlightGauge metrics.Gauge
+	latencyTimer  metrics.Timer
+	blocksMeter   metrics.Meter
+}
+
+// newBlockRetrievalLimiter returns a limiter that starts by allowing
+// `initial` concurrent fetches, and can grow up to `max` of them.
+// Its metrics are registered in `registry` under `name`, unless
+// `registry` is nil.
+func newBlockRetrievalLimiter(name string, initial, max int, clock Clock,
+	registry metrics.Registry) *blockRetrievalLimiter {
+	l := &blockRetrievalLimiter{
+		clock:         clock,
+		minLimit:      1,
+		maxLimit:      float64(max),
+		limit:         float64(initial),
+		changedCh:     make(chan struct{}),
+		limitGauge:    metrics.NewGauge(),
+		inFlightGauge: metrics.NewGauge(),
+		latencyTimer:  metrics.NewTimer(),
+		blocksMeter:   metrics.NewMeter(),
+	}
+	if registry != nil {
+		l.limitGauge = metrics.GetOrRegisterGauge(
+			name+".Limit", registry)
+		l.inFlightGauge = metrics.GetOrRegisterGauge(
+			name+".InFlight", registry)
+		l.latencyTimer = metrics.GetOrRegisterTimer(
+			name+".Latency", registry)
+		l.blocksMeter = metrics.GetOrRegisterMeter(
+			name+".Blocks", registry)
+	}
+	l.limitGauge.Update(int64(initial))
+	return l
+}
+
+// getLimit returns the current number of concurrent fetches allowed.
+func (l *blockRetrievalLimiter) getLimit() int {
+	if l == nil {
+		return 0
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return int(l.limit)
+}
+
+// acquire waits until one more fetch is allowed, and returns the time
+// at which it was allowed.  It returns false if `stopCh` is closed
+// first.  Each successful acquire must be followed by a release.
+func (l *blockRetrievalLimiter) acquire(stopCh <-chan struct{}) (
+	start time.Time, ok bool) {
+	if l == nil {
+		return time.Time{}, true
+	}
+	for {
+		l.lock.Lock()
+		if l.inFlight < int(l.limit) {
+			l.inFlight++
+			if l.inFlight >= int(l.limit) {
+				l.full = true
+			}
+			l.inFlightGauge.Update(int64(l.inFlight))
+			l.lock.Unlock()
+			return l.clock.Now(), true
+		}
+		changedCh := l.changedCh
+		l.lock.Unlock()
+
+		select {
+		case <-changedCh:
+		case <-stopCh:
+			return time.Time{}, false
+		}
+	}
+}
+
+// release marks a fetch that started at `start` as done, with the
+// given outcome.  The latency of a successful fetch is used to adjust
+// the limit; it's `latency` if that's non-zero, and the time since
+// `start` otherwise.
+func (l *blockRetrievalLimiter) release(
+	start time.Time, outcome blockFetchOutcome, latency time.Duration) {
+	if l == nil {
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	wasFull := l.full
+	l.inFlight--
+	if l.inFlight == 0 {
+		l.full = false
+	}
+	l.inFlightGauge.Update(int64(l.inFlight))
+	close(l.changedCh)
+	l.changedCh = make(chan struct{})
+
+	congested := false
+	switch outcome {
+	case blockFetchSkipped:
+		return
+	case blockFetchFailed:
+		congested = true
+	case blockFetchSucceeded:
+		if latency == 0 {
+			latency = l.clock.Now().Sub(start)
+		}
+		l.latencyTimer.Update(latency)
+		l.blocksMeter.Mark(1)
+		if l.baseline == 0 || latency < l.baseline {
+			l.baseline = latency
+		} else {
+			l.baseline += (latency - l.baseline) / adaptiveBaselineDrift
+		}
+		congested = latency > adaptiveLatencyTolerance*l.baseline
+	}
+
+	switch {
+	case congested:
+		// Only decrease once per round of fetches: fetches that
+		// started before the last decrease don't reflect it yet.
+		if start.After(l.lastDecrease) {
+			l.limit *= adaptiveDecreaseFactor
+			if l.limit < l.minLimit {
+				l.limit = l.minLimit
+			}
+			l.lastDecrease = l.clock.Now()
+		}
+	case wasFull:
+		// Grow by about one fetch per round of `limit` fetches,
+		// i.e., per round trip.
+		l.limit += 1 / l.limit
+		if l.limit > l.maxLimit {
+			l.limit = l.maxLimit
+		}
+	}
+	l.limitGauge.Update(int64(l.limit))
+}
@@ -0,0 +1,100 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libkbfs
+
+import (
+	"testing"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/require"
+)
+
+// fetchRound starts `n` fetches at once, and finishes them all after
+// `latency`.
+func fetchRound(t *testing.T, l *blockRetrievalLimiter, clock *TestClock,
+	n int, latency time.Duration) {
+	starts := make([]time.Time, n)
+	for i := range starts {
+		start, ok := l.acquire(nil)
+		require.True(t, ok)
+		starts[i] = start
+	}
+	clock.Add(latency)
+	for _, start := range starts {
+		l.release(start, blockFetchSucceeded, 0)
+	}
+}
+
+func TestBlockRetrievalLimiterAIMD(t *testing.T) {
+	clock := newTestClockNow()
+	registry := metrics.NewRegistry()
+	l := newBlockRetrievalLimiter("Test", 2, 4, clock, registry)
+	require.Equal(t, 2, l.getLimit())
+
+	t.Log("Fetches that don't use the whole limit don't grow it.")
+	fetchRound(t, l, clock, 1, 10*time.Millisecond)
+	require.Equal(t, 2, l.getLimit())
+
+	t.Log("Full rounds of fast fetches grow the limit up to the max.")
+	for i := 0; i < 10; i++ {
+		fetchRound(t, l, clock, l.getLimit(), 10*time.Millisecond)
+	}
+	require.Equal(t, 4, l.getLimit())
+	require.Equal(t, int64(4),
+		registry.Get("Test.Limit").(metrics.Gauge).Value())
+
+	t.Log("A round of slow fetches only cuts the limit once.")
+	fetchRound(t, l, clock, 4, 100*time.Millisecond)
+	require.Equal(t, 3, l.getLimit())
+
+	t.Log("The limit never goes below one fetch.")
+	for i := 0; i < 10; i++ {
+		fetchRound(t, l, clock, 1, time.Duration(i+2)*time.Second)
+	}
+	require.Equal(t, 1, l.getLimit())
+
+	require.Equal(t, int64(0),
+		registry.Get("Test.InFlight").(metrics.Gauge).Value())
+	require.NotZero(t, registry.Get("Test.Blocks").(metrics.Meter).Count())
+}
+
+func TestBlockRetrievalLimiterAcquireBlocks(t *testing.T) {
+	clock := newTestClockNow()
+	l := newBlockRetrievalLimiter("Test", 1, 1, clock, nil)
+	start, ok := l.acquire(nil)
+	require.True(t, ok)
+
+	t.Log("A second fetch waits for the first one to finish.")
+	acquiredCh := make(chan struct{})
+	go func() {
+		start, ok := l.acquire(nil)
+		require.True(t, ok)
+		l.release(start, blockFetchSkipped, 0)
+		close(acquiredCh)
+	}()
+	select {
+	case <-acquiredCh:
+		t.Fatal("Acquired more than the limit")
+	case <-time.After(10 * time.Millisecond):
+	}
+	l.release(start, blockFetchSkipped, 0)
+	<-acquiredCh
+
+	t.Log("A waiting fetch gives up when stopped.")
+	start, ok = l.acquire(nil)
+	require.True(t, ok)
+	stopCh := make(chan struct{})
+	close(stopCh)
+	_, ok = l.acquire(stopCh)
+	require.False(t, ok)
+	l.release(start, blockFetchSkipped, 0)
+
+	t.Log("A nil limiter doesn't limit anything.")
+	var nilLimiter *blockRetrievalLimiter
+	_, ok = nilLimiter.acquire(nil)
+	require.True(t, ok)
+	nilLimiter.release(time.Time{}, blockFetchSucceeded, 0)
+}
@@ -24,7 +24,14 @@ const (
 	testBlockRetrievalWorkerQueueSize    int = 5
 	testPrefetchWorkerQueueSize          int = 1
 	defaultOnDemandRequestPriority       int = 1 << 30
-	lowestTriggerPrefetchPriority        int = 1
+	// lowestOnDemandRequestPriority is the lowest priority that is
+	// served by the on-demand workers rather than the prefetch
+	// workers, and isn't throttled as prefetch traffic.
+	lowestOnDemandRequestPriority int = defaultOnDemandRequestPriority - 1
+	// highestPrefetchPriority is the priority of prefetches for
+	// synced TLFs and paths.
+	highestPrefetchPriority       int = lowestOnDemandRequestPriority - 1
+	lowestTriggerPrefetchPriority int = 1
 	// Channel buffer size can be big because we use the empty struct.
 	workerQueueSize int = 1<<31 - 1
 )
@@ -36,6 +43,8 @@ type blockRetrievalPartialConfig interface {
 	diskBlockCacheGetter
 	syncedTlfGetterSetter
 	initModeGetter
+	clockGetter
+	metricsRegistryGetter
 }
 
 type blockRetrievalConfig interface {
@@ -121,6 +130,10 @@ type blockRetrievalQueue struct {
 	prefetchWorkerCh chan<- struct{}
 	// slices to store the workers so we can terminate them when we're done
 	workers []*blockRetrievalWorker
+	// limiters adapt how many of the on-demand and prefetch workers
+	// can fetch blocks at once; nil if the pools have fixed sizes.
+	limiter         *blockRetrievalLimiter
+	prefetchLimiter *blockRetrievalLimiter
 	// channel to be closed when we're done accepting requests
 	doneCh chan struct{}
 
@@ -134,7 +147,10 @@ var _ BlockRetriever = (*blockRetrievalQueue)(nil)
 
 // newBlockRetrievalQueue creates a new block retrieval queue. The numWorkers
 // parameter determines how many workers can concurrently call Work (more than
-// numWorkers will block).
+// numWorkers will block).  Outside of tests, numWorkers and
+// numPrefetchWorkers are only the initial sizes of the pools, which
+// then adapt to the link to the block server, up to
+// adaptiveWorkersMaxFactor times those sizes.
 func newBlockRetrievalQueue(numWorkers int, numPrefetchWorkers int,
 	config blockRetrievalConfig) *blockRetrievalQueue {
 	workerCh := make(chan struct{}, workerQueueSize)
@@ -151,13 +167,32 @@ func newBlockRetrievalQueue(numWorkers int, numPrefetchWorkers int,
 			numWorkers+numPrefetchWorkers),
 	}
 	q.prefetcher = newBlockPrefetcher(q, config, nil)
+	if !config.IsTestMode() {
+		// Tests rely on the exact number of workers, so only adapt
+		// the pools in real runs.
+		registry := config.MetricsRegistry()
+		if numWorkers > 0 {
+			q.limiter = newBlockRetrievalLimiter(
+				"BlockRetrieval.OnDemand", numWorkers,
+				adaptiveWorkersMaxFactor*numWorkers, config.Clock(),
+				registry)
+			numWorkers *= adaptiveWorkersMaxFactor
+		}
+		if numPrefetchWorkers > 0 {
+			q.prefetchLimiter = newBlockRetrievalLimiter(
+				"BlockRetrieval.Prefetch", numPrefetchWorkers,
+				adaptiveWorkersMaxFactor*numPrefetchWorkers, config.Clock(),
+				registry)
+			numPrefetchWorkers *= adaptiveWorkersMaxFactor
+		}
+	}
 	for i := 0; i < numWorkers; i++ {
 		q.workers = append(q.workers, newBlockRetrievalWorker(
-			config.blockGetter(), q, workerCh))
+			config.blockGetter(), q, workerCh, q.limiter))
 	}
 	for i := 0; i < numPrefetchWorkers; i++ {
 		q.workers = append(q.workers, newBlockRetrievalWorker(
-			config.blockGetter(), q, prefetchWorkerCh))
+			config.blockGetter(), q, prefetchWorkerCh, q.prefetchLimiter))
 	}
 	return q
 }
@@ -197,7 +232,7 @@ func (brq *blockRetrievalQueue) notifyWorker(priority int) {
 	// because there are far more on-demand workers than prefetch workers, this
 	// should never actually happen.
 	workerCh := brq.workerCh
-	if priority < defaultOnDemandRequestPriority {
+	if priority < lowestOnDemandRequestPriority {
 		workerCh = brq.prefetchWorkerCh
 	}
 	select {
@@ -379,8 +414,8 @@ func (brq *blockRetrievalQueue) request(ctx context.Context,
 		// means it's actively being processed).
 		if br.index != -1 {
 			heap.Fix(brq.heap, br.index)
-			if oldPriority < defaultOnDemandRequestPriority &&
-				priority >= defaultOnDemandRequestPriority {
+			if oldPriority < lowestOnDemandRequestPriority &&
+				priority >= lowestOnDemandRequestPriority {
 				// We've crossed the priority threshold for prefetch workers,
 				// so we now need an on-demand worker to pick up the request.
 				// This means that we might have up to two workers "activated"
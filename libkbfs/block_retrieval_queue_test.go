@@ -11,6 +11,7 @@ import (
 	"github.com/keybase/client/go/protocol/keybase1"
 	"github.com/keybase/kbfs/kbfsblock"
 	"github.com/keybase/kbfs/tlf"
+	metrics "github.com/rcrowley/go-metrics"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/net/context"
 )
@@ -23,6 +24,7 @@ type testBlockRetrievalConfig struct {
 	*testDiskBlockCacheGetter
 	*testSyncedTlfGetterSetter
 	initModeGetter
+	clock Clock
 }
 
 func newTestBlockRetrievalConfig(t *testing.T, bg blockGetter,
@@ -35,6 +37,7 @@ func newTestBlockRetrievalConfig(t *testing.T, bg blockGetter,
 		newTestDiskBlockCacheGetter(t, dbc),
 		newTestSyncedTlfGetterSetter(),
 		testInitModeGetter{InitDefault},
+		newTestClockNow(),
 	}
 }
 
@@ -46,6 +49,14 @@ func (c testBlockRetrievalConfig) DataVersion() DataVer {
 	return ChildHolesDataVer
 }
 
+func (c testBlockRetrievalConfig) Clock() Clock {
+	return c.clock
+}
+
+func (c testBlockRetrievalConfig) MetricsRegistry() metrics.Registry {
+	return nil
+}
+
 func (c testBlockRetrievalConfig) blockGetter() blockGetter {
 	return c.bg
 }
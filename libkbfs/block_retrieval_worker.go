@@ -6,6 +6,9 @@ package libkbfs
 
 import (
 	"io"
+	"time"
+
+	"golang.org/x/net/context"
 )
 
 // blockRetrievalWorker processes blockRetrievalQueue requests
@@ -14,6 +17,8 @@ type blockRetrievalWorker struct {
 	stopCh chan struct{}
 	queue  *blockRetrievalQueue
 	workCh <-chan struct{}
+	// limiter is shared by all the workers of the same pool.
+	limiter *blockRetrievalLimiter
 }
 
 // run runs the worker loop until Shutdown is called
@@ -32,12 +37,14 @@ func (brw *blockRetrievalWorker) run() {
 // blockRetrievalQueue, using the passed in blockGetter to obtain blocks for
 // requests.
 func newBlockRetrievalWorker(bg blockGetter, q *blockRetrievalQueue,
-	workCh <-chan struct{}) *blockRetrievalWorker {
+	workCh <-chan struct{},
+	limiter *blockRetrievalLimiter) *blockRetrievalWorker {
 	brw := &blockRetrievalWorker{
 		blockGetter: bg,
 		stopCh:      make(chan struct{}),
 		queue:       q,
 		workCh:      workCh,
+		limiter:     limiter,
 	}
 	go brw.run()
 	return brw
@@ -49,10 +56,19 @@ func newBlockRetrievalWorker(bg blockGetter, q *blockRetrievalQueue,
 // results.
 func (brw *blockRetrievalWorker) HandleRequest() (err error) {
 	var retrieval *blockRetrieval
+	var start time.Time
 	select {
 	case <-brw.workCh:
+		// Wait for the pool to have room before taking the
+		// retrieval out of the heap, so it can still be preempted.
+		var ok bool
+		start, ok = brw.limiter.acquire(brw.stopCh)
+		if !ok {
+			return io.EOF
+		}
 		retrieval = brw.queue.popIfNotEmpty()
 		if retrieval == nil {
+			brw.limiter.release(start, blockFetchSkipped, 0)
 			return nil
 		}
 	case <-brw.stopCh:
@@ -60,7 +76,10 @@ func (brw *blockRetrievalWorker) HandleRequest() (err error) {
 	}
 
 	var block Block
+	outcome := blockFetchSkipped
+	var latency time.Duration
 	defer func() {
+		brw.limiter.release(start, outcome, latency)
 		brw.queue.FinalizeRequest(retrieval, block, err)
 	}()
 
@@ -71,13 +90,24 @@ func (brw *blockRetrievalWorker) HandleRequest() (err error) {
 	default:
 	}
 
+	var isPrefetch bool
 	func() {
 		retrieval.reqMtx.RLock()
 		defer retrieval.reqMtx.RUnlock()
 		block = retrieval.requests[0].block.NewEmpty()
+		isPrefetch = retrieval.priority < lowestOnDemandRequestPriority
 	}()
 
-	return brw.getBlock(retrieval.ctx, retrieval.kmd, retrieval.blockPtr, block)
+	// Prefetches are rate-limited separately from the blocks someone
+	// is waiting on.
+	var ctx context.Context = retrieval.ctx
+	if isPrefetch {
+		ctx = withBandwidthClass(ctx, BandwidthClassPrefetch)
+	}
+	ctx = withBlockFetchLatency(ctx, &latency)
+	err = brw.getBlock(ctx, retrieval.kmd, retrieval.blockPtr, block)
+	outcome = blockFetchOutcomeFromError(err)
+	return err
 }
 
 // Shutdown shuts down the blockRetrievalWorker once its current work is done.
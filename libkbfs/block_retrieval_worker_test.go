@@ -378,3 +378,113 @@ func TestBlockRetrievalWorkerPrefetchedPriorityElevation(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, testBlock1, block1)
 }
+
+// adaptiveBlockRetrievalConfig isn't in test mode, so that the worker
+// pools of a queue using it adapt to their fetches.
+type adaptiveBlockRetrievalConfig struct {
+	*testBlockRetrievalConfig
+}
+
+func (c adaptiveBlockRetrievalConfig) IsTestMode() bool {
+	return false
+}
+
+// rpcLatencyBlockGetter records the given RPC latency for each block
+// fetch, like BlockServerRemote does.
+type rpcLatencyBlockGetter struct {
+	*fakeBlockGetter
+	rpcLatency time.Duration
+}
+
+func (bg *rpcLatencyBlockGetter) getBlock(ctx context.Context,
+	kmd KeyMetadata, blockPtr BlockPointer, block Block) error {
+	err := bg.fakeBlockGetter.getBlock(ctx, kmd, blockPtr, block)
+	recordBlockFetchLatency(ctx, bg.rpcLatency)
+	return err
+}
+
+func TestBlockRetrievalWorkerAdaptiveLimit(t *testing.T) {
+	ctx := context.Background()
+	bg := &rpcLatencyBlockGetter{fakeBlockGetter: newFakeBlockGetter(false)}
+	config := adaptiveBlockRetrievalConfig{
+		newTestBlockRetrievalConfig(t, bg, nil)}
+	clock := config.clock.(*TestClock)
+	q := newBlockRetrievalQueue(1, 0, config)
+	require.NotNil(t, q)
+	defer q.Shutdown()
+	<-q.TogglePrefetcher(false, nil)
+
+	// fetch fetches one block, which takes `latency` according to
+	// the clock, and then returns `err`.
+	fetch := func(latency time.Duration, err error) {
+		ptr := makeRandomBlockPointer(t)
+		startCh, continueCh := bg.setBlockToReturn(
+			ptr, makeFakeFileBlock(t, false))
+		ch := q.Request(ctx, defaultOnDemandRequestPriority, makeKMD(), ptr,
+			&FileBlock{}, NoCacheEntry)
+		<-startCh
+		clock.Add(latency)
+		continueCh <- err
+		require.Equal(t, err, <-ch)
+	}
+
+	t.Log("A fast fetch that uses the whole limit grows it.")
+	require.Equal(t, 1, q.limiter.getLimit())
+	fetch(10*time.Millisecond, nil)
+	require.Equal(t, 2, q.limiter.getLimit())
+
+	t.Log("Only the RPC counts toward the latency of a fetch, not " +
+		"waiting on the bandwidth limiter after it.")
+	bg.rpcLatency = 10 * time.Millisecond
+	fetch(time.Second, nil)
+	require.Equal(t, 2, q.limiter.getLimit())
+	bg.rpcLatency = 0
+
+	t.Log("Canceled fetches don't say anything about the link.")
+	fetch(time.Second, context.Canceled)
+	require.Equal(t, 2, q.limiter.getLimit())
+
+	t.Log("Failed fetches count as congestion.")
+	fetch(10*time.Millisecond, context.DeadlineExceeded)
+	require.Equal(t, 1, q.limiter.getLimit())
+}
+
+// classRecordingBlockGetter records the bandwidth class of the last
+// block fetch.
+type classRecordingBlockGetter struct {
+	*fakeBlockGetter
+	class BandwidthClass
+}
+
+func (bg *classRecordingBlockGetter) getBlock(ctx context.Context,
+	kmd KeyMetadata, blockPtr BlockPointer, block Block) error {
+	bg.class = bandwidthClassFromContext(ctx)
+	return bg.fakeBlockGetter.getBlock(ctx, kmd, blockPtr, block)
+}
+
+func TestBlockRetrievalWorkerReadAheadNotPrefetch(t *testing.T) {
+	ctx := context.Background()
+	bg := &classRecordingBlockGetter{fakeBlockGetter: newFakeBlockGetter(false)}
+	q := newBlockRetrievalQueue(1, 1, newTestBlockRetrievalConfig(t, bg, nil))
+	require.NotNil(t, q)
+	defer q.Shutdown()
+	<-q.TogglePrefetcher(false, nil)
+
+	fetch := func(priority int) BandwidthClass {
+		ptr := makeRandomBlockPointer(t)
+		_, continueCh := bg.setBlockToReturn(ptr, makeFakeFileBlock(t, false))
+		ch := q.Request(ctx, priority, makeKMD(), ptr, &FileBlock{},
+			NoCacheEntry)
+		continueCh <- nil
+		require.NoError(t, <-ch)
+		return bg.class
+	}
+
+	t.Log("Read-ahead blocks aren't throttled as prefetch traffic.")
+	require.Equal(t, BandwidthClassForeground, fetch(readAheadPriority))
+	require.Equal(t, BandwidthClassForeground,
+		fetch(defaultOnDemandRequestPriority))
+
+	t.Log("Even the highest-priority prefetches are.")
+	require.Equal(t, BandwidthClassPrefetch, fetch(highestPrefetchPriority))
+}
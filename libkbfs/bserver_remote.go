@@ -288,6 +288,8 @@ type blockServerRemoteConfig interface {
 	signerGetter
 	currentSessionGetterGetter
 	logMaker
+	bandwidthLimiterGetter
+	clockGetter
 }
 
 // BlockServerRemote implements the BlockServer interface and
@@ -397,8 +399,24 @@ func (b *BlockServerRemote) Get(ctx context.Context, tlfID tlf.ID, id kbfsblock.
 	}()
 
 	arg := kbfsblock.MakeGetBlockArg(tlfID, id, context)
+	rpcStart := b.config.Clock().Now()
 	res, err := b.getConn.getClient().GetBlock(ctx, arg)
-	return kbfsblock.ParseGetBlockRes(res, err)
+	buf, serverHalf, err = kbfsblock.ParseGetBlockRes(res, err)
+	if err != nil {
+		return nil, kbfscrypto.BlockCryptKeyServerHalf{}, err
+	}
+	// Let the block retrieval workers adapt to the latency of the
+	// RPC alone, not the bandwidth limiting below.
+	recordBlockFetchLatency(ctx, b.config.Clock().Now().Sub(rpcStart))
+	// We only know the size of a block once it's been fetched, so
+	// account for it afterward, which holds back the next transfer
+	// of the same class instead.
+	err = b.config.BandwidthLimiter().Wait(
+		ctx, bandwidthClassFromContext(ctx), len(buf))
+	if err != nil {
+		return nil, kbfscrypto.BlockCryptKeyServerHalf{}, err
+	}
+	return buf, serverHalf, nil
 }
 
 // Put implements the BlockServer interface for BlockServerRemote.
@@ -425,6 +443,12 @@ func (b *BlockServerRemote) Put(ctx context.Context, tlfID tlf.ID, id kbfsblock.
 		}
 	}()
 
+	err = b.config.BandwidthLimiter().Wait(
+		ctx, bandwidthClassFromContext(ctx), size)
+	if err != nil {
+		return err
+	}
+
 	arg := kbfsblock.MakePutBlockArg(tlfID, id, bContext, buf, serverHalf)
 	// Handle OverQuota errors at the caller
 	return b.putConn.getClient().PutBlock(ctx, arg)
@@ -453,6 +477,12 @@ func (b *BlockServerRemote) PutAgain(ctx context.Context, tlfID tlf.ID, id kbfsb
 		}
 	}()
 
+	err = b.config.BandwidthLimiter().Wait(
+		ctx, bandwidthClassFromContext(ctx), size)
+	if err != nil {
+		return err
+	}
+
 	arg := kbfsblock.MakePutBlockAgainArg(tlfID, id, bContext, buf, serverHalf)
 	// Handle OverQuota errors at the caller
 	return b.putConn.getClient().PutBlockAgain(ctx, arg)
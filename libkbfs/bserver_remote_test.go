@@ -75,6 +75,14 @@ type testBlockServerRemoteConfig struct {
 
 var _ blockServerRemoteConfig = (*testBlockServerRemoteConfig)(nil)
 
+func (c testBlockServerRemoteConfig) BandwidthLimiter() *BandwidthLimiter {
+	return nil
+}
+
+func (c testBlockServerRemoteConfig) Clock() Clock {
+	return wallClock{}
+}
+
 func (c testBlockServerRemoteConfig) Signer() kbfscrypto.Signer {
 	return c.signer
 }
@@ -7,7 +7,9 @@ package libkbfs
 import (
 	"flag"
 	"os"
+	stdpath "path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -61,6 +63,8 @@ const (
 	keyBundlesCacheCapacityBytes = 10 * cache.MB
 	// folder name for persisted config parameters.
 	syncedTlfConfigFolderName = "synced_tlf_config"
+	// folder name for the persisted list of synced paths within TLFs.
+	syncedPathConfigFolderName = "synced_path_config"
 
 	// By default, this will be the block type given to all blocks
 	// that aren't explicitly some other type.
@@ -77,8 +81,10 @@ type ConfigLocal struct {
 	kcache           KeyCache
 	kbcache          kbfsmd.KeyBundleCache
 	bcache           BlockCache
+	cacheEviction    cache.EvictionPolicy
 	dirtyBcache      DirtyBlockCache
 	diskBlockCache   DiskBlockCache
+	diskMDCache      DiskMDCache
 	codec            kbfscodec.Codec
 	mdops            MDOps
 	kops             KeyOps
@@ -94,12 +100,17 @@ type ConfigLocal struct {
 	clock            Clock
 	kbpki            KBPKI
 	renamer          ConflictRenamer
+	merger           ConflictMerger
 	registry         metrics.Registry
 	loggerFn         func(prefix string) logger.Logger
 	noBGFlush        bool // logic opposite so the default value is the common setting
 	rwpWaitTime      time.Duration
 	diskLimiter      DiskLimiter
+	bandwidthLimiter *BandwidthLimiter
 	syncedTlfs       map[tlf.ID]bool
+	syncedPaths      map[tlf.ID]map[string]bool
+	tlfCacheSettings map[tlf.ID]DiskBlockCacheTlfSettings
+	favoriteTlfs     map[tlf.ID]bool
 	defaultBlockType keybase1.BlockType
 	kbfsService      *KBFSService
 	kbCtx            Context
@@ -110,6 +121,14 @@ type ConfigLocal struct {
 	rekeyQueue    RekeyQueue
 	storageRoot   string
 	diskCacheMode DiskCacheMode
+	// bcacheWarmStartMaxBlocks is the number of hot blocks to save
+	// on shutdown, if positive.
+	bcacheWarmStartMaxBlocks int
+	bcacheWarmStartCancel    context.CancelFunc
+	bcacheWarmStartDoneCh    chan struct{}
+	// diskCacheSocket, if non-empty, is the unix socket of the
+	// DiskBlockCacheServer to use in DiskCacheModeRemote.
+	diskCacheSocket string
 
 	traceLock    sync.RWMutex
 	traceEnabled bool
@@ -379,14 +398,18 @@ func NewConfigLocal(mode InitMode,
 		diskCacheMode: diskCacheMode,
 		kbCtx:         kbCtx,
 	}
+	config.SetCodec(kbfscodec.NewMsgpack())
 	if diskCacheMode == DiskCacheModeLocal {
 		config.loadSyncedTlfsLocked()
+		config.loadSyncedPathsLocked()
+		config.loadTlfCacheSettingsLocked()
 	}
 	config.SetClock(wallClock{})
 	config.SetReporter(NewReporterSimple(config.Clock(), 10))
+	config.bandwidthLimiter = newBandwidthLimiter(config)
 	config.SetConflictRenamer(WriterDeviceDateConflictRenamer{config})
+	config.SetConflictMerger(NewConflictMergeRegistry())
 	config.ResetCaches()
-	config.SetCodec(kbfscodec.NewMsgpack())
 	config.SetKeyOps(&KeyOpsStandard{config})
 	config.SetRekeyQueue(NewRekeyQueueStandard(config))
 
@@ -551,6 +574,13 @@ func (c *ConfigLocal) DiskBlockCache() DiskBlockCache {
 	return c.diskBlockCache
 }
 
+// DiskMDCache implements the Config interface for ConfigLocal.
+func (c *ConfigLocal) DiskMDCache() DiskMDCache {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.diskMDCache
+}
+
 // DiskLimiter implements the Config interface for ConfigLocal.
 func (c *ConfigLocal) DiskLimiter() DiskLimiter {
 	c.lock.RLock()
@@ -558,6 +588,13 @@ func (c *ConfigLocal) DiskLimiter() DiskLimiter {
 	return c.diskLimiter
 }
 
+// BandwidthLimiter implements the Config interface for ConfigLocal.
+func (c *ConfigLocal) BandwidthLimiter() *BandwidthLimiter {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.bandwidthLimiter
+}
+
 // Crypto implements the Config interface for ConfigLocal.
 func (c *ConfigLocal) Crypto() Crypto {
 	c.lock.RLock()
@@ -769,6 +806,20 @@ func (c *ConfigLocal) SetConflictRenamer(cr ConflictRenamer) {
 	c.renamer = cr
 }
 
+// ConflictMerger implements the Config interface for ConfigLocal.
+func (c *ConfigLocal) ConflictMerger() ConflictMerger {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.merger
+}
+
+// SetConflictMerger implements the Config interface for ConfigLocal.
+func (c *ConfigLocal) SetConflictMerger(cm ConflictMerger) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.merger = cm
+}
+
 // MetadataVersion implements the Config interface for ConfigLocal.
 func (c *ConfigLocal) MetadataVersion() kbfsmd.MetadataVer {
 	c.lock.RLock()
@@ -891,11 +942,45 @@ func (c *ConfigLocal) StorageRoot() string {
 	return c.storageRoot
 }
 
+// makeCleanCaches makes new key and clean block caches, using the
+// given eviction policy.
+func makeCleanCaches(policy cache.EvictionPolicy, bcacheCapacity uint64) (
+	KeyCache, BlockCache, error) {
+	kcache, err := NewKeyCacheStandardWithPolicy(
+		policy, defaultMDCacheCapacity)
+	if err != nil {
+		return nil, nil, err
+	}
+	bcache, err := NewBlockCacheStandardWithPolicy(
+		policy, 10000, bcacheCapacity)
+	if err != nil {
+		return nil, nil, err
+	}
+	return kcache, bcache, nil
+}
+
+// SetCacheEvictionPolicy replaces the key cache and the clean block
+// cache with empty ones that use the given eviction policy.  The
+// capacity of the clean block cache stays the same.
+func (c *ConfigLocal) SetCacheEvictionPolicy(
+	policy cache.EvictionPolicy) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	kcache, bcache, err := makeCleanCaches(
+		policy, c.bcache.GetCleanBytesCapacity())
+	if err != nil {
+		return err
+	}
+	c.cacheEviction = policy
+	c.kcache = kcache
+	c.bcache = bcache
+	return nil
+}
+
 func (c *ConfigLocal) resetCachesWithoutShutdown() DirtyBlockCache {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 	c.mdcache = NewMDCacheStandard(defaultMDCacheCapacity)
-	c.kcache = NewKeyCacheStandard(defaultMDCacheCapacity)
 	c.kbcache = kbfsmd.NewKeyBundleCacheLRU(keyBundlesCacheCapacityBytes)
 
 	log := c.MakeLogger("")
@@ -909,7 +994,16 @@ func (c *ConfigLocal) resetCachesWithoutShutdown() DirtyBlockCache {
 		log.Debug("setting clean block cache capacity based on existing value %d",
 			capacity)
 	}
-	c.bcache = NewBlockCacheStandard(10000, capacity)
+	var err error
+	c.kcache, c.bcache, err = makeCleanCaches(c.cacheEviction, capacity)
+	if err != nil {
+		// SetCacheEvictionPolicy already checked that the policy
+		// works, so this shouldn't happen.
+		log.Warning("Couldn't make caches with eviction policy %s, "+
+			"falling back to LRU: %+v", c.cacheEviction, err)
+		c.kcache = NewKeyCacheStandard(defaultMDCacheCapacity)
+		c.bcache = NewBlockCacheStandard(10000, capacity)
+	}
 
 	if !c.Mode().DirtyBlockCacheEnabled() {
 		return nil
@@ -1109,12 +1203,16 @@ func (c *ConfigLocal) Shutdown(ctx context.Context) error {
 	}
 
 	var errorList []error
+	c.shutdownBlockCacheWarmStart(ctx)
 	err := c.KBFSOps().Shutdown(ctx)
 	if err != nil {
 		errorList = append(errorList, err)
 		// Continue with shutdown regardless of err.
 		err = nil
 	}
+	// Let the prefetcher finish its in-flight fetches while the block
+	// retrieval workers are still around, so it doesn't outlive us.
+	<-c.BlockOps().TogglePrefetcher(false)
 	c.BlockOps().Shutdown()
 	c.MDServer().Shutdown()
 	c.KeyServer().Shutdown()
@@ -1133,6 +1231,10 @@ func (c *ConfigLocal) Shutdown(ctx context.Context) error {
 	if dbc != nil {
 		dbc.Shutdown(ctx)
 	}
+	dmc := c.DiskMDCache()
+	if dmc != nil {
+		dmc.Shutdown(ctx)
+	}
 	kbfsServ := c.kbfsService
 	if kbfsServ != nil {
 		kbfsServ.Shutdown()
@@ -1147,6 +1249,77 @@ func (c *ConfigLocal) Shutdown(ctx context.Context) error {
 	return nil
 }
 
+// EnableBlockCacheWarmStart makes this config save the pointers of
+// up to `maxBlocks` hot blocks of the block cache under the storage
+// root on shutdown.  It also starts loading the blocks saved by the
+// previous shutdown, if any, from the disk block cache in the
+// background.
+func (c *ConfigLocal) EnableBlockCacheWarmStart(maxBlocks int) error {
+	if c.storageRoot == "" {
+		return errors.New("empty storageRoot specified for non-test run")
+	}
+	snapshot, err := loadBlockCacheSnapshot(c.Codec(), c.storageRoot)
+	if err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.bcacheWarmStartMaxBlocks > 0 {
+		return errors.New("Block cache warm start already enabled")
+	}
+	c.bcacheWarmStartMaxBlocks = maxBlocks
+	if len(snapshot.Entries) == 0 {
+		return nil
+	}
+
+	log := c.MakeLogger("")
+	ctx, cancel := context.WithCancel(CtxWithRandomIDReplayable(
+		context.Background(), ctxBlockCacheWarmStartIDKey,
+		ctxBlockCacheWarmStartOpID, log))
+	doneCh := make(chan struct{})
+	c.bcacheWarmStartCancel = cancel
+	c.bcacheWarmStartDoneCh = doneCh
+	go func() {
+		defer close(doneCh)
+		numBlocks, err := warmBlockCache(ctx, c, snapshot)
+		log.CDebugf(ctx, "Warmed up the block cache with %d of %d "+
+			"blocks (err=%+v)", numBlocks, len(snapshot.Entries), err)
+	}()
+	return nil
+}
+
+// shutdownBlockCacheWarmStart stops any warm-up of the block cache
+// in progress, and saves the hot blocks of the block cache for the
+// next startup.
+func (c *ConfigLocal) shutdownBlockCacheWarmStart(ctx context.Context) {
+	c.lock.Lock()
+	maxBlocks := c.bcacheWarmStartMaxBlocks
+	cancel := c.bcacheWarmStartCancel
+	doneCh := c.bcacheWarmStartDoneCh
+	c.bcacheWarmStartMaxBlocks = 0
+	c.bcacheWarmStartCancel = nil
+	c.bcacheWarmStartDoneCh = nil
+	c.lock.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-doneCh
+	}
+	if maxBlocks <= 0 {
+		return
+	}
+	log := c.MakeLogger("")
+	numBlocks, err := saveBlockCacheSnapshot(
+		c.Codec(), c.storageRoot, c.BlockCache(), maxBlocks)
+	if err != nil {
+		log.CWarningf(ctx, "Couldn't save the hot blocks of the block "+
+			"cache: %+v", err)
+		return
+	}
+	log.CDebugf(ctx, "Saved %d hot blocks of the block cache", numBlocks)
+}
+
 // CheckStateOnShutdown implements the Config interface for ConfigLocal.
 func (c *ConfigLocal) CheckStateOnShutdown() bool {
 	if md, ok := c.MDServer().(mdServerLocal); ok {
@@ -1306,7 +1479,14 @@ func (c *ConfigLocal) MakeDiskBlockCacheIfNotExists() error {
 	case DiskCacheModeLocal:
 		return c.resetDiskBlockCacheLocked()
 	case DiskCacheModeRemote:
-		dbc, err := NewDiskBlockCacheRemote(c.kbCtx, c)
+		var dbc *DiskBlockCacheRemote
+		var err error
+		if c.diskCacheSocket != "" {
+			dbc, err = NewDiskBlockCacheRemoteFromSocket(
+				c.diskCacheSocket, c.kbCtx.NewRPCLogFactory(), c)
+		} else {
+			dbc, err = NewDiskBlockCacheRemote(c.kbCtx, c)
+		}
 		if err != nil {
 			return err
 		}
@@ -1316,6 +1496,49 @@ func (c *ConfigLocal) MakeDiskBlockCacheIfNotExists() error {
 	return nil
 }
 
+// SetDiskCacheSocket makes a remote disk block cache connect to the
+// DiskBlockCacheServer listening on the given unix socket, instead of
+// to the local KBFS instance.  It must be called before
+// MakeDiskBlockCacheIfNotExists.
+func (c *ConfigLocal) SetDiskCacheSocket(socketPath string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.diskCacheSocket = socketPath
+}
+
+// MakeDiskMDCacheIfNotExists implements the Config interface for
+// ConfigLocal.
+func (c *ConfigLocal) MakeDiskMDCacheIfNotExists() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.diskMDCache != nil {
+		return nil
+	}
+	// MD heads are only cached locally, alongside a local disk block
+	// cache; without the blocks, the MD alone isn't useful offline.
+	if c.diskCacheMode != DiskCacheModeLocal {
+		return nil
+	}
+	if c.IsTestMode() {
+		dmc, err := newDiskMDCacheLocalForTest(c)
+		if err != nil {
+			return err
+		}
+		c.diskMDCache = dmc
+		return nil
+	}
+	if c.storageRoot == "" {
+		return errors.New("empty storageRoot specified for non-test run")
+	}
+	dmc, err := newDiskMDCacheLocal(
+		c, filepath.Join(c.storageRoot, mdCacheFolder))
+	if err != nil {
+		return err
+	}
+	c.diskMDCache = dmc
+	return nil
+}
+
 func (c *ConfigLocal) openConfigLevelDB(configName string) (*levelDb, error) {
 	dbPath := filepath.Join(c.storageRoot, configName)
 	stor, err := storage.OpenFile(dbPath, false)
@@ -1407,6 +1630,278 @@ func (c *ConfigLocal) SetTlfSyncState(tlfID tlf.ID, isSynced bool) error {
 	return nil
 }
 
+// syncedPathKeySeparator separates the TLF ID from the path in the keys of
+// the synced path leveldb. TLF IDs are hex-encoded, so they can never contain
+// it.
+const syncedPathKeySeparator = "/"
+
+func syncedPathKey(tlfID tlf.ID, p string) []byte {
+	return []byte(tlfID.String() + syncedPathKeySeparator + p)
+}
+
+// cleanSyncedPath returns the canonical form of a synced path, which is
+// slash-separated and relative to the root of its TLF.
+func cleanSyncedPath(p string) (string, error) {
+	cleaned := stdpath.Clean("/" + p)
+	if cleaned == "/" {
+		return "", errors.Errorf("invalid synced path %q; enable sync for "+
+			"the whole TLF instead", p)
+	}
+	return cleaned[1:], nil
+}
+
+func (c *ConfigLocal) loadSyncedPathsLocked() (err error) {
+	syncedPaths := make(map[tlf.ID]map[string]bool)
+	if c.IsTestMode() {
+		c.syncedPaths = syncedPaths
+		return nil
+	}
+	if c.storageRoot == "" {
+		return errors.New("empty storageRoot specified for non-test run")
+	}
+	ldb, err := c.openConfigLevelDB(syncedPathConfigFolderName)
+	if err != nil {
+		return err
+	}
+	defer ldb.Close()
+	iter := ldb.NewIterator(nil, nil)
+	defer iter.Release()
+
+	log := c.MakeLogger("")
+	// If there are any un-parseable keys, delete them.
+	deleteBatch := new(leveldb.Batch)
+	for iter.Next() {
+		key := string(iter.Key())
+		parts := strings.SplitN(key, syncedPathKeySeparator, 2)
+		if len(parts) != 2 {
+			log.Debug("deleting key %s from synced path list", key)
+			deleteBatch.Delete(iter.Key())
+			continue
+		}
+		tlfID, err := tlf.ParseID(parts[0])
+		if err != nil {
+			log.Debug("deleting key %s from synced path list", key)
+			deleteBatch.Delete(iter.Key())
+			continue
+		}
+		if syncedPaths[tlfID] == nil {
+			syncedPaths[tlfID] = make(map[string]bool)
+		}
+		syncedPaths[tlfID][parts[1]] = true
+	}
+	c.syncedPaths = syncedPaths
+	return ldb.Write(deleteBatch, nil)
+}
+
+// GetTlfSyncedPaths implements the syncedTlfGetterSetter interface for
+// ConfigLocal.
+func (c *ConfigLocal) GetTlfSyncedPaths(tlfID tlf.ID) []string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	paths := c.syncedPaths[tlfID]
+	if len(paths) == 0 {
+		return nil
+	}
+	ret := make([]string, 0, len(paths))
+	for p := range paths {
+		ret = append(ret, p)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// setTlfPathSyncStateLocked persists and records the sync state of
+// the already-cleaned path `p` within the given TLF.
+func (c *ConfigLocal) setTlfPathSyncStateLocked(
+	tlfID tlf.ID, p string, isSynced bool) error {
+	if !c.IsTestMode() {
+		if c.storageRoot == "" {
+			return errors.New("empty storageRoot specified for non-test run")
+		}
+		ldb, err := c.openConfigLevelDB(syncedPathConfigFolderName)
+		if err != nil {
+			return err
+		}
+		defer ldb.Close()
+		if isSynced {
+			err = ldb.Put(syncedPathKey(tlfID, p), nil, nil)
+		} else {
+			err = ldb.Delete(syncedPathKey(tlfID, p), nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if c.syncedPaths == nil {
+		c.syncedPaths = make(map[tlf.ID]map[string]bool)
+	}
+	if isSynced {
+		if c.syncedPaths[tlfID] == nil {
+			c.syncedPaths[tlfID] = make(map[string]bool)
+		}
+		c.syncedPaths[tlfID][p] = true
+	} else {
+		delete(c.syncedPaths[tlfID], p)
+		if len(c.syncedPaths[tlfID]) == 0 {
+			delete(c.syncedPaths, tlfID)
+		}
+	}
+	return nil
+}
+
+// SetTlfPathSyncState implements the syncedTlfGetterSetter interface for
+// ConfigLocal.
+func (c *ConfigLocal) SetTlfPathSyncState(
+	tlfID tlf.ID, p string, isSynced bool) error {
+	p, err := cleanSyncedPath(p)
+	if err != nil {
+		return err
+	}
+	err = func() error {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		if isSynced {
+			diskCacheWrapped, ok := c.diskBlockCache.(*diskBlockCacheWrapped)
+			if !ok {
+				return errors.Errorf("invalid disk cache type to set path "+
+					"sync state: %T", c.diskBlockCache)
+			}
+			if !diskCacheWrapped.IsSyncCacheEnabled() {
+				return errors.New("sync block cache is not enabled")
+			}
+		}
+		err := c.setTlfPathSyncStateLocked(tlfID, p, isSynced)
+		if err != nil {
+			return err
+		}
+		<-c.bops.TogglePrefetcher(true)
+		return nil
+	}()
+	if err != nil {
+		return err
+	}
+
+	if isSynced || c.IsSyncedTlf(tlfID) {
+		return nil
+	}
+	// We don't know which of the TLF's blocks in the sync cache belong
+	// to the unpinned path, so move all of them back into the working
+	// set cache.  The blocks of the remaining synced paths are moved
+	// into the sync cache again the next time the prefetcher walks
+	// them.  This must be done without holding `c.lock`, since the
+	// disk caches look up the sync state through the config.
+	diskCacheWrapped, ok := c.DiskBlockCache().(*diskBlockCacheWrapped)
+	if !ok {
+		return nil
+	}
+	return diskCacheWrapped.moveTlfToWorkingSetCache(
+		context.Background(), tlfID)
+}
+
+// RenameTlfSyncedPaths implements the syncedTlfGetterSetter interface
+// for ConfigLocal.
+func (c *ConfigLocal) RenameTlfSyncedPaths(
+	tlfID tlf.ID, oldPath, newPath string) error {
+	oldPath, err := cleanSyncedPath(oldPath)
+	if err != nil {
+		return err
+	}
+	newPath, err = cleanSyncedPath(newPath)
+	if err != nil {
+		return err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var renamed []string
+	for p := range c.syncedPaths[tlfID] {
+		if p == oldPath || strings.HasPrefix(p, oldPath+"/") {
+			renamed = append(renamed, p)
+		}
+	}
+	for _, p := range renamed {
+		// Pin the new path before unpinning the old one, so the
+		// TLF never looks unsynced in between.  The blocks stay in
+		// the sync cache, since they haven't changed.
+		err := c.setTlfPathSyncStateLocked(
+			tlfID, newPath+p[len(oldPath):], true)
+		if err != nil {
+			return err
+		}
+		err = c.setTlfPathSyncStateLocked(tlfID, p, false)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *ConfigLocal) loadTlfCacheSettingsLocked() (err error) {
+	if c.IsTestMode() {
+		c.tlfCacheSettings = make(map[tlf.ID]DiskBlockCacheTlfSettings)
+		return nil
+	}
+	settings, err := LoadDiskBlockCacheTlfSettings(c.codec, c.storageRoot)
+	if err != nil {
+		return err
+	}
+	c.tlfCacheSettings = settings
+	return nil
+}
+
+// GetDiskBlockCacheTlfSettings implements the Config interface for
+// ConfigLocal.
+func (c *ConfigLocal) GetDiskBlockCacheTlfSettings(
+	tlfID tlf.ID) DiskBlockCacheTlfSettings {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.tlfCacheSettings[tlfID]
+}
+
+// SetDiskBlockCacheTlfSettings implements the Config interface for
+// ConfigLocal.
+func (c *ConfigLocal) SetDiskBlockCacheTlfSettings(
+	tlfID tlf.ID, settings DiskBlockCacheTlfSettings) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if !c.IsTestMode() {
+		err := StoreDiskBlockCacheTlfSettings(
+			c.codec, c.storageRoot, tlfID, settings)
+		if err != nil {
+			return err
+		}
+	}
+	if settings == (DiskBlockCacheTlfSettings{}) {
+		delete(c.tlfCacheSettings, tlfID)
+		return nil
+	}
+	if c.tlfCacheSettings == nil {
+		c.tlfCacheSettings = make(map[tlf.ID]DiskBlockCacheTlfSettings)
+	}
+	c.tlfCacheSettings[tlfID] = settings
+	return nil
+}
+
+// IsFavoriteTlf implements the Config interface for ConfigLocal.
+func (c *ConfigLocal) IsFavoriteTlf(tlfID tlf.ID) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.favoriteTlfs[tlfID]
+}
+
+// SetTlfFavoriteState implements the Config interface for ConfigLocal.
+func (c *ConfigLocal) SetTlfFavoriteState(tlfID tlf.ID, isFavorite bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if !isFavorite {
+		delete(c.favoriteTlfs, tlfID)
+		return
+	}
+	if c.favoriteTlfs == nil {
+		c.favoriteTlfs = make(map[tlf.ID]bool)
+	}
+	c.favoriteTlfs[tlfID] = true
+}
+
 // PrefetchStatus implements the Config interface for ConfigLocal.
 func (c *ConfigLocal) PrefetchStatus(ctx context.Context, tlfID tlf.ID,
 	ptr BlockPointer) PrefetchStatus {
@@ -15,6 +15,7 @@ import (
 
 type FakeObserver struct {
 	localChange  Node
+	localWrites  []WriteRange
 	batchChanges []NodeChange
 	ctx          context.Context
 }
@@ -22,6 +23,7 @@ type FakeObserver struct {
 func (fn *FakeObserver) LocalChange(ctx context.Context,
 	node Node, write WriteRange) {
 	fn.localChange = node
+	fn.localWrites = append(fn.localWrites, write)
 	fn.ctx = ctx
 }
 
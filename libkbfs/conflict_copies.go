@@ -0,0 +1,217 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libkbfs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/keybase/client/go/protocol/keybase1"
+	"github.com/keybase/go-codec/codec"
+	"github.com/keybase/kbfs/kbfsmd"
+)
+
+// maxDecidedConflictCopies is the number of conflict copies with a
+// recorded decision that are kept in the TLF metadata.
+const maxDecidedConflictCopies = 100
+
+// maxConflictCopies is the total number of conflict copies kept in
+// the TLF metadata.  Conflict copies that are removed or renamed
+// without a decision stay outstanding, so past this the oldest
+// outstanding ones are dropped too.
+const maxConflictCopies = 1000
+
+// ConflictWinner says which version of a conflicted entry a user
+// chose to keep.
+type ConflictWinner string
+
+const (
+	// ConflictWinnerOriginal keeps the entry under its original
+	// name, and deletes the conflict copy.
+	ConflictWinnerOriginal ConflictWinner = "original"
+	// ConflictWinnerCopy moves the conflict copy over the entry
+	// under the original name.
+	ConflictWinnerCopy ConflictWinner = "conflict"
+)
+
+// ParseConflictWinner parses the string form of a ConflictWinner.
+func ParseConflictWinner(s string) (ConflictWinner, error) {
+	switch w := ConflictWinner(strings.TrimSpace(s)); w {
+	case ConflictWinnerOriginal, ConflictWinnerCopy:
+		return w, nil
+	default:
+		return "", fmt.Errorf("Unknown conflict winner %q (want %q or %q)",
+			s, ConflictWinnerOriginal, ConflictWinnerCopy)
+	}
+}
+
+// ConflictDecision records which version of a conflicted entry was
+// kept, by whom, and in which revision.
+type ConflictDecision struct {
+	Winner   ConflictWinner  `codec:"w"`
+	Writer   keybase1.UID    `codec:"u"`
+	Revision kbfsmd.Revision `codec:"r"`
+
+	codec.UnknownFieldSetHandler
+}
+
+// ConflictCopy records an entry that conflict resolution had to
+// rename in order to keep both versions of it.  Paths are relative to
+// the root of the TLF, and are as of the resolution; later renames of
+// their parent directories aren't tracked.
+type ConflictCopy struct {
+	OriginalPath string `codec:"o"`
+	ConflictPath string `codec:"c"`
+	// OriginalWriter wrote the version left under OriginalPath, and
+	// ConflictWriter the version moved to ConflictPath.
+	OriginalWriter keybase1.UID `codec:"ow"`
+	ConflictWriter keybase1.UID `codec:"cw"`
+	// MergedRevision is the merged revision the conflicting changes
+	// were resolved against, and ResolvedRevision is the revision
+	// that made the conflict copy.
+	MergedRevision   kbfsmd.Revision `codec:"mr"`
+	ResolvedRevision kbfsmd.Revision `codec:"rr"`
+
+	// Decision is nil until a user picks a winner.
+	Decision *ConflictDecision `codec:"d,omitempty"`
+
+	codec.UnknownFieldSetHandler
+}
+
+// ConflictState describes whether a conflict copy still needs
+// attention.
+type ConflictState string
+
+const (
+	// ConflictOutstanding means that no one has picked a winner
+	// yet.
+	ConflictOutstanding ConflictState = "outstanding"
+	// ConflictResolved means that a user picked a winner.
+	ConflictResolved ConflictState = "resolved"
+	// ConflictGone means that the conflict copy was removed or
+	// renamed without picking a winner.
+	ConflictGone ConflictState = "gone"
+)
+
+// ConflictSummary describes one conflict copy, in a form that's
+// suitable for encoding directly into JSON.
+type ConflictSummary struct {
+	OriginalPath     string          `json:"original_path"`
+	ConflictPath     string          `json:"conflict_path"`
+	OriginalWriter   string          `json:"original_writer"`
+	ConflictWriter   string          `json:"conflict_writer"`
+	MergedRevision   kbfsmd.Revision `json:"merged_revision"`
+	ResolvedRevision kbfsmd.Revision `json:"resolved_revision"`
+	State            ConflictState   `json:"state"`
+	Winner           ConflictWinner  `json:"winner,omitempty"`
+	DecidedBy        string          `json:"decided_by,omitempty"`
+	DecisionRevision kbfsmd.Revision `json:"decision_revision,omitempty"`
+}
+
+// TlfConflicts lists the conflicts of a TLF, in a form that's
+// suitable for encoding directly into JSON.
+type TlfConflicts struct {
+	// Unmerged is set if this device has local changes that
+	// haven't been resolved against the merged branch yet.
+	Unmerged             bool              `json:"unmerged"`
+	BranchID             string            `json:"branch_id,omitempty"`
+	HeadRevision         kbfsmd.Revision   `json:"head_revision"`
+	LatestMergedRevision kbfsmd.Revision   `json:"latest_merged_revision"`
+	Conflicts            []ConflictSummary `json:"conflicts"`
+}
+
+// setConflictWriters records who wrote each version of a conflicted
+// entry on an action that renames one of them, taking each writer
+// from the conflicting op of its own branch.
+func setConflictWriters(action crAction, unmergedOp, mergedOp op) {
+	mergedWriter := mergedOp.getWriterInfo().uid
+	unmergedWriter := unmergedOp.getWriterInfo().uid
+	switch a := action.(type) {
+	case *renameUnmergedAction:
+		a.mergedWriter, a.unmergedWriter = mergedWriter, unmergedWriter
+	case *renameMergedAction:
+		a.mergedWriter, a.unmergedWriter = mergedWriter, unmergedWriter
+	}
+}
+
+// addConflictCopies records the given conflict copies as having
+// been made by this MD.
+func (md *RootMetadata) addConflictCopies(copies []ConflictCopy) {
+	if len(copies) == 0 {
+		return
+	}
+	for _, cc := range copies {
+		cc.ResolvedRevision = md.Revision()
+		md.data.Conflicts = append(md.data.Conflicts, cc)
+	}
+	md.data.Conflicts = pruneConflicts(md.data.Conflicts)
+}
+
+// decideConflicts records the given decisions, keyed by conflict
+// path, on the matching outstanding conflict copies.
+func (md *RootMetadata) decideConflicts(
+	decisions map[string]ConflictDecision) {
+	if len(decisions) == 0 {
+		return
+	}
+	conflicts := md.data.Conflicts
+	for i := range conflicts {
+		if conflicts[i].Decision != nil {
+			continue
+		}
+		d, ok := decisions[conflicts[i].ConflictPath]
+		if !ok {
+			continue
+		}
+		d.Revision = md.Revision()
+		conflicts[i].Decision = &d
+	}
+	md.data.Conflicts = pruneConflicts(conflicts)
+}
+
+// pruneConflicts drops the oldest decided conflict copies past
+// maxDecidedConflictCopies, and then the oldest ones of any kind past
+// maxConflictCopies.  It reuses the backing array of conflicts.
+func pruneConflicts(conflicts []ConflictCopy) []ConflictCopy {
+	decided := 0
+	for _, cc := range conflicts {
+		if cc.Decision != nil {
+			decided++
+		}
+	}
+	extraDecided := decided - maxDecidedConflictCopies
+	if extraDecided < 0 {
+		extraDecided = 0
+	}
+	extra := len(conflicts) - extraDecided - maxConflictCopies
+	if extra < 0 {
+		extra = 0
+	}
+
+	kept := conflicts[:0]
+	for _, cc := range conflicts {
+		switch {
+		case cc.Decision != nil && extraDecided > 0:
+			extraDecided--
+		case extra > 0:
+			extra--
+		default:
+			kept = append(kept, cc)
+		}
+	}
+	return kept
+}
+
+// findOutstandingConflict returns the outstanding conflict copy at
+// the given path, if any.
+func findOutstandingConflict(
+	conflicts []ConflictCopy, conflictPath string) (ConflictCopy, bool) {
+	for _, cc := range conflicts {
+		if cc.Decision == nil && cc.ConflictPath == conflictPath {
+			return cc, true
+		}
+	}
+	return ConflictCopy{}, false
+}
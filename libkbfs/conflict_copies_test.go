@@ -0,0 +1,49 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libkbfs
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func makeConflictCopiesForTest(n int, decided bool) []ConflictCopy {
+	copies := make([]ConflictCopy, n)
+	for i := range copies {
+		copies[i].ConflictPath = fmt.Sprintf("%t-%d", decided, i)
+		if decided {
+			copies[i].Decision = &ConflictDecision{
+				Winner: ConflictWinnerOriginal,
+			}
+		}
+	}
+	return copies
+}
+
+func TestPruneConflicts(t *testing.T) {
+	t.Log("Nothing is dropped under the limits.")
+	conflicts := append(makeConflictCopiesForTest(10, true),
+		makeConflictCopiesForTest(10, false)...)
+	require.Len(t, pruneConflicts(conflicts), 20)
+
+	t.Log("The oldest decided copies are dropped first.")
+	conflicts = append(makeConflictCopiesForTest(
+		maxDecidedConflictCopies+5, true),
+		makeConflictCopiesForTest(10, false)...)
+	pruned := pruneConflicts(conflicts)
+	require.Len(t, pruned, maxDecidedConflictCopies+10)
+	require.Equal(t, "true-5", pruned[0].ConflictPath)
+
+	t.Log("Outstanding copies that never get a decision are capped too.")
+	conflicts = append(makeConflictCopiesForTest(
+		maxConflictCopies+5, false),
+		makeConflictCopiesForTest(10, true)...)
+	pruned = pruneConflicts(conflicts)
+	require.Len(t, pruned, maxConflictCopies)
+	require.Equal(t, "false-15", pruned[0].ConflictPath)
+	require.Equal(t, "true-9", pruned[len(pruned)-1].ConflictPath)
+}
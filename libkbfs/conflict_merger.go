@@ -0,0 +1,531 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libkbfs
+
+import (
+	"bytes"
+	"encoding/json"
+	stdpath "path"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+// ConflictMergeInput holds the versions of a file that was written
+// in both branches of a conflict.
+type ConflictMergeInput struct {
+	// Path is the path of the file, relative to the root of its TLF.
+	Path string
+	// Base is the contents of the file at the common ancestor of the
+	// two branches, or nil if it isn't available.
+	Base []byte
+	// Merged is the contents of the file in the merged branch, i.e.,
+	// the version already on the server.
+	Merged []byte
+	// Unmerged is the contents of the file in the local, unmerged
+	// branch.
+	Unmerged []byte
+	// MergedMtime and UnmergedMtime are the modification times of
+	// the two versions.
+	MergedMtime   time.Time
+	UnmergedMtime time.Time
+}
+
+// TextMergeStrategy merges text files line by line against their
+// common ancestor, like diff3.  It fails if both branches changed
+// the same lines differently, or if the ancestor isn't available.
+type TextMergeStrategy struct{}
+
+// JSONMergeStrategy merges JSON documents key by key against their
+// common ancestor.  It fails if both branches set the same key to
+// different values.  The merged document is re-indented with two
+// spaces, with its object keys sorted.
+type JSONMergeStrategy struct{}
+
+// LineSetMergeStrategy merges files whose lines form an unordered
+// set, like .gitignore files or lists of hosts: lines added in
+// either branch are kept, and lines removed in either branch are
+// dropped.  It never fails on text files.
+type LineSetMergeStrategy struct{}
+
+// LastWriterWinsMergeStrategy keeps whichever version of the file
+// was modified last, and drops the other one.
+type LastWriterWinsMergeStrategy struct{}
+
+// conflictMergeStrategyNames maps the names accepted by
+// ParseConflictMergeRules to the strategies.
+var conflictMergeStrategyNames = map[string]ConflictMergeStrategy{
+	"text":  TextMergeStrategy{},
+	"json":  JSONMergeStrategy{},
+	"lines": LineSetMergeStrategy{},
+	"lww":   LastWriterWinsMergeStrategy{},
+}
+
+// errConflictNotMergeable is returned by the merge strategies when
+// both branches made incompatible changes.
+var errConflictNotMergeable = errors.New(
+	"Both branches made incompatible changes")
+
+// maxConflictMergeEdits is the largest number of line insertions and
+// deletions between two versions of a text file that are diffed.
+// Larger diffs aren't worth the memory, and would likely not merge
+// cleanly anyway.
+const maxConflictMergeEdits = 2000
+
+type conflictMergeRule struct {
+	pattern  string
+	strategy ConflictMergeStrategy
+}
+
+// ConflictMergeRegistry is a ConflictMerger that picks a strategy by
+// matching the path of a file against patterns, in the order they
+// were registered.  Patterns use the syntax of path.Match; a pattern
+// without a slash is matched against the name of the file only, and
+// one with a slash against its whole path within the TLF.
+type ConflictMergeRegistry struct {
+	lock  sync.RWMutex
+	rules []conflictMergeRule
+}
+
+var _ ConflictMerger = (*ConflictMergeRegistry)(nil)
+
+// NewConflictMergeRegistry returns an empty registry, under which
+// every conflict is resolved by renaming.
+func NewConflictMergeRegistry() *ConflictMergeRegistry {
+	return &ConflictMergeRegistry{}
+}
+
+// ParseConflictMergeRules returns a registry for a comma-separated
+// list of pattern=strategy rules, e.g. "*.txt=text,*.json=json".
+// The known strategies are "text", "json", "lines" and "lww".
+func ParseConflictMergeRules(s string) (*ConflictMergeRegistry, error) {
+	r := NewConflictMergeRegistry()
+	for _, rule := range strings.Split(s, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		i := strings.LastIndex(rule, "=")
+		if i < 0 {
+			return nil, errors.Errorf(
+				"Conflict merge rule %q isn't of the form pattern=strategy",
+				rule)
+		}
+		name := rule[i+1:]
+		strategy, ok := conflictMergeStrategyNames[name]
+		if !ok {
+			return nil, errors.Errorf(
+				"Unknown conflict merge strategy %q", name)
+		}
+		err := r.Register(rule[:i], strategy)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Register makes files matching `pattern` merge with `strategy`,
+// unless they match a pattern registered earlier.
+func (r *ConflictMergeRegistry) Register(
+	pattern string, strategy ConflictMergeStrategy) error {
+	if _, err := stdpath.Match(pattern, ""); err != nil {
+		return errors.Wrapf(err, "Bad conflict merge pattern %q", pattern)
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.rules = append(r.rules, conflictMergeRule{pattern, strategy})
+	return nil
+}
+
+// StrategyForPath implements the ConflictMerger interface for
+// ConflictMergeRegistry.
+func (r *ConflictMergeRegistry) StrategyForPath(
+	p string) ConflictMergeStrategy {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	for _, rule := range r.rules {
+		if matchConflictPathPattern(rule.pattern, p) {
+			return rule.strategy
+		}
+	}
+	return nil
+}
+
+// matchConflictPathPattern returns whether the given path, relative
+// to the root of a TLF, matches the given pattern.  A pattern without
+// a "/" is matched against the last element of the path only.
+func matchConflictPathPattern(pattern, p string) bool {
+	target := p
+	if !strings.Contains(pattern, "/") {
+		target = stdpath.Base(p)
+	}
+	ok, _ := stdpath.Match(pattern, target)
+	return ok
+}
+
+func checkMergeableText(in ConflictMergeInput) error {
+	for _, b := range [][]byte{in.Base, in.Merged, in.Unmerged} {
+		if bytes.IndexByte(b, 0) >= 0 {
+			return errors.Errorf("%s isn't a text file", in.Path)
+		}
+	}
+	return nil
+}
+
+// splitLines splits `b` into lines, keeping their line endings.
+func splitLines(b []byte) []string {
+	var lines []string
+	for len(b) > 0 {
+		i := bytes.IndexByte(b, '\n') + 1
+		if i == 0 {
+			i = len(b)
+		}
+		lines = append(lines, string(b[:i]))
+		b = b[i:]
+	}
+	return lines
+}
+
+// matchLines returns, for each line of `a`, the index of the line of
+// `b` it's matched with in a longest common subsequence of the two,
+// or -1 if it isn't in the subsequence.  It uses Myers' diff
+// algorithm, after skipping the common prefix and suffix.
+func matchLines(a, b []string) ([]int, error) {
+	matches := make([]int, len(a))
+	for i := range matches {
+		matches[i] = -1
+	}
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		matches[pre] = pre
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre &&
+		a[len(a)-1-suf] == b[len(b)-1-suf] {
+		matches[len(a)-1-suf] = len(b) - 1 - suf
+		suf++
+	}
+	a, b = a[pre:len(a)-suf], b[pre:len(b)-suf]
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return matches, nil
+	}
+
+	// v[offset+k] is the furthest x reached on diagonal k.  Only the
+	// diagonals within reach are kept for backtracking, so the
+	// trace takes O(d^2) space for d edits.
+	offset := n + m
+	v := make([]int, 2*offset+2)
+	var trace [][]int
+	found := false
+	for d := 0; d <= n+m && !found; d++ {
+		if d > maxConflictMergeEdits {
+			return nil, errors.New("Too many changes to merge")
+		}
+		trace = append(trace,
+			append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		vd := trace[d]
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && vd[k-1+d] < vd[k+1+d]) {
+			prevK = k + 1
+		}
+		prevX := vd[prevK+d]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			matches[pre+x] = pre + y
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		matches[pre+x] = pre + y
+	}
+	return matches, nil
+}
+
+func linesEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// merge3Lines merges the changes made from `base` to `a` and from
+// `base` to `b`.  It walks through the chunks of `base` that are
+// kept unchanged in both versions, and in between them takes the
+// version of whichever side changed the chunk.
+func merge3Lines(base, a, b []string) ([]string, error) {
+	matchA, err := matchLines(base, a)
+	if err != nil {
+		return nil, err
+	}
+	matchB, err := matchLines(base, b)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []string
+	resolve := func(o, x, y []string) error {
+		switch {
+		case linesEqual(x, o):
+			result = append(result, y...)
+		case linesEqual(y, o), linesEqual(x, y):
+			result = append(result, x...)
+		default:
+			return errConflictNotMergeable
+		}
+		return nil
+	}
+
+	lo, la, lb := 0, 0, 0
+	for lo < len(base) || la < len(a) || lb < len(b) {
+		// Copy the unchanged lines.
+		i := 0
+		for lo+i < len(base) && matchA[lo+i] == la+i &&
+			matchB[lo+i] == lb+i {
+			i++
+		}
+		if i > 0 {
+			result = append(result, base[lo:lo+i]...)
+			lo, la, lb = lo+i, la+i, lb+i
+			continue
+		}
+
+		// Find the next line that both sides kept, and resolve the
+		// changes up to it.
+		o := lo
+		for o < len(base) && (matchA[o] < 0 || matchB[o] < 0) {
+			o++
+		}
+		if o == len(base) {
+			return result, resolve(base[lo:], a[la:], b[lb:])
+		}
+		err := resolve(base[lo:o], a[la:matchA[o]], b[lb:matchB[o]])
+		if err != nil {
+			return nil, err
+		}
+		lo, la, lb = o, matchA[o], matchB[o]
+	}
+	return result, nil
+}
+
+// MergeContents implements the ConflictMergeStrategy interface for
+// TextMergeStrategy.
+func (TextMergeStrategy) MergeContents(
+	_ context.Context, in ConflictMergeInput) ([]byte, error) {
+	if bytes.Equal(in.Merged, in.Unmerged) {
+		return in.Merged, nil
+	}
+	if in.Base == nil {
+		return nil, errors.Errorf(
+			"No common ancestor to merge %s against", in.Path)
+	}
+	if err := checkMergeableText(in); err != nil {
+		return nil, err
+	}
+	lines, err := merge3Lines(splitLines(in.Base), splitLines(in.Merged),
+		splitLines(in.Unmerged))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(strings.Join(lines, "")), nil
+}
+
+func decodeJSON(b []byte) (interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return v, nil
+}
+
+// mergeJSONValues merges the changes made from `base` to `m` and
+// from `base` to `u`; `hasBase` is false if there is no base value.
+func mergeJSONValues(base interface{}, hasBase bool, m, u interface{}) (
+	interface{}, error) {
+	switch {
+	case reflect.DeepEqual(m, u):
+		return m, nil
+	case hasBase && reflect.DeepEqual(base, m):
+		return u, nil
+	case hasBase && reflect.DeepEqual(base, u):
+		return m, nil
+	}
+
+	mMap, mOk := m.(map[string]interface{})
+	uMap, uOk := u.(map[string]interface{})
+	if !mOk || !uOk {
+		return nil, errConflictNotMergeable
+	}
+	baseMap, _ := base.(map[string]interface{})
+
+	result := make(map[string]interface{}, len(mMap))
+	for k, mv := range mMap {
+		bv, bOk := baseMap[k]
+		uv, uOk := uMap[k]
+		switch {
+		case uOk:
+			v, err := mergeJSONValues(bv, bOk, mv, uv)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Key %q", k)
+			}
+			result[k] = v
+		case !bOk:
+			// Added in the merged branch.
+			result[k] = mv
+		case !reflect.DeepEqual(bv, mv):
+			return nil, errors.Wrapf(errConflictNotMergeable,
+				"Key %q changed in the merged branch and removed "+
+					"in the unmerged one", k)
+		}
+	}
+	for k, uv := range uMap {
+		if _, ok := mMap[k]; ok {
+			continue
+		}
+		bv, bOk := baseMap[k]
+		switch {
+		case !bOk:
+			// Added in the unmerged branch.
+			result[k] = uv
+		case !reflect.DeepEqual(bv, uv):
+			return nil, errors.Wrapf(errConflictNotMergeable,
+				"Key %q changed in the unmerged branch and removed "+
+					"in the merged one", k)
+		}
+	}
+	return result, nil
+}
+
+// MergeContents implements the ConflictMergeStrategy interface for
+// JSONMergeStrategy.
+func (JSONMergeStrategy) MergeContents(
+	_ context.Context, in ConflictMergeInput) ([]byte, error) {
+	if bytes.Equal(in.Merged, in.Unmerged) {
+		return in.Merged, nil
+	}
+	m, err := decodeJSON(in.Merged)
+	if err != nil {
+		return nil, err
+	}
+	u, err := decodeJSON(in.Unmerged)
+	if err != nil {
+		return nil, err
+	}
+	var base interface{}
+	hasBase := in.Base != nil
+	if hasBase {
+		base, err = decodeJSON(in.Base)
+		if err != nil {
+			// A broken ancestor is as good as none.
+			hasBase = false
+		}
+	}
+	v, err := mergeJSONValues(base, hasBase, m, u)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	e := json.NewEncoder(&buf)
+	e.SetEscapeHTML(false)
+	e.SetIndent("", "  ")
+	if err := e.Encode(v); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return buf.Bytes(), nil
+}
+
+func lineSet(b []byte) map[string]bool {
+	set := make(map[string]bool)
+	for _, line := range splitLines(b) {
+		set[strings.TrimSuffix(line, "\n")] = true
+	}
+	return set
+}
+
+// MergeContents implements the ConflictMergeStrategy interface for
+// LineSetMergeStrategy.
+func (LineSetMergeStrategy) MergeContents(
+	_ context.Context, in ConflictMergeInput) ([]byte, error) {
+	if err := checkMergeableText(in); err != nil {
+		return nil, err
+	}
+	base := lineSet(in.Base)
+	unmerged := lineSet(in.Unmerged)
+	seen := make(map[string]bool)
+	var lines []string
+	// Keep the order of the merged version, then add the lines only
+	// the unmerged branch added at the end.
+	for _, line := range splitLines(in.Merged) {
+		line = strings.TrimSuffix(line, "\n")
+		if seen[line] || (base[line] && !unmerged[line]) {
+			continue
+		}
+		seen[line] = true
+		lines = append(lines, line)
+	}
+	for _, line := range splitLines(in.Unmerged) {
+		line = strings.TrimSuffix(line, "\n")
+		if seen[line] || base[line] {
+			continue
+		}
+		seen[line] = true
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return []byte{}, nil
+	}
+	return []byte(strings.Join(lines, "\n") + "\n"), nil
+}
+
+// MergeContents implements the ConflictMergeStrategy interface for
+// LastWriterWinsMergeStrategy.  On a tie, the unmerged version wins.
+func (LastWriterWinsMergeStrategy) MergeContents(
+	_ context.Context, in ConflictMergeInput) ([]byte, error) {
+	if in.MergedMtime.After(in.UnmergedMtime) {
+		return in.Merged, nil
+	}
+	return in.Unmerged, nil
+}
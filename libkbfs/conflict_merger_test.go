@@ -0,0 +1,157 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libkbfs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+func testMergeContents(t *testing.T, s ConflictMergeStrategy,
+	base, merged, unmerged string) (string, error) {
+	in := ConflictMergeInput{
+		Path:     "a/b",
+		Merged:   []byte(merged),
+		Unmerged: []byte(unmerged),
+	}
+	if base != "" {
+		in.Base = []byte(base)
+	}
+	b, err := s.MergeContents(context.Background(), in)
+	return string(b), err
+}
+
+func TestTextMergeStrategy(t *testing.T) {
+	s := TextMergeStrategy{}
+	base := "1\n2\n3\n4\n5\n"
+
+	t.Log("Changes to different lines merge.")
+	out, err := testMergeContents(
+		t, s, base, "0\n1\n2\n3\n4\n5\n", "1\n2\nthree\n4\n")
+	require.NoError(t, err)
+	require.Equal(t, "0\n1\n2\nthree\n4\n", out)
+
+	t.Log("The same change on both sides merges.")
+	out, err = testMergeContents(
+		t, s, base, "1\n2\nx\n4\n5\n6\n", "1\n2\nx\n4\n5\n")
+	require.NoError(t, err)
+	require.Equal(t, "1\n2\nx\n4\n5\n6\n", out)
+
+	t.Log("Different changes to the same line don't.")
+	_, err = testMergeContents(
+		t, s, base, "1\n2\nx\n4\n5\n", "1\n2\ny\n4\n5\n")
+	require.Error(t, err)
+
+	t.Log("Nor do different insertions at the same place.")
+	_, err = testMergeContents(t, s, base, base+"x\n", base+"y\n")
+	require.Error(t, err)
+
+	t.Log("Without an ancestor, only identical versions merge.")
+	_, err = testMergeContents(t, s, "", "x\n", "y\n")
+	require.Error(t, err)
+	out, err = testMergeContents(t, s, "", "x\n", "x\n")
+	require.NoError(t, err)
+	require.Equal(t, "x\n", out)
+
+	t.Log("Binary files don't merge.")
+	_, err = testMergeContents(t, s, "a\x00", "b\x00", "a\x00c")
+	require.Error(t, err)
+}
+
+func TestMatchLines(t *testing.T) {
+	a := splitLines([]byte("a\nb\nc\nd\ne\nf\n"))
+	b := splitLines([]byte("x\nb\nc\ny\ne\nf\nz\n"))
+	matches, err := matchLines(a, b)
+	require.NoError(t, err)
+	require.Equal(t, []int{-1, 1, 2, -1, 4, 5}, matches)
+}
+
+func TestJSONMergeStrategy(t *testing.T) {
+	s := JSONMergeStrategy{}
+	base := `{"a": 1, "b": {"c": "x", "d": [1, 2]}, "e": true}`
+
+	t.Log("Changes to different keys merge, at any depth.")
+	out, err := testMergeContents(t, s, base,
+		`{"a": 2, "b": {"c": "x", "d": [1, 2]}, "e": true, "f": null}`,
+		`{"a": 1, "b": {"c": "y", "d": [1, 2]}}`)
+	require.NoError(t, err)
+	require.Equal(t, "{\n  \"a\": 2,\n  \"b\": {\n    \"c\": \"y\",\n"+
+		"    \"d\": [\n      1,\n      2\n    ]\n  },\n  \"f\": null\n}\n",
+		out)
+
+	t.Log("Different values for the same key don't.")
+	_, err = testMergeContents(t, s, base,
+		`{"a": 2, "b": {"c": "x", "d": [1, 2]}, "e": true}`,
+		`{"a": 3, "b": {"c": "x", "d": [1, 2]}, "e": true}`)
+	require.Error(t, err)
+
+	t.Log("Nor does a change to a key removed on the other side.")
+	_, err = testMergeContents(t, s, base,
+		`{"a": 1, "b": {"c": "x", "d": [1, 2]}, "e": false}`,
+		`{"a": 1, "b": {"c": "x", "d": [1, 2]}}`)
+	require.Error(t, err)
+
+	t.Log("Without an ancestor, new keys on both sides merge.")
+	out, err = testMergeContents(t, s, "", `{"a": 1}`, `{"b": 2}`)
+	require.NoError(t, err)
+	require.Equal(t, "{\n  \"a\": 1,\n  \"b\": 2\n}\n", out)
+
+	t.Log("Invalid JSON doesn't merge.")
+	_, err = testMergeContents(t, s, base, `{"a":`, base)
+	require.Error(t, err)
+}
+
+func TestLineSetMergeStrategy(t *testing.T) {
+	s := LineSetMergeStrategy{}
+	out, err := testMergeContents(t, s, "a\nb\nc\n", "b\nc\nd\n", "a\nc\ne")
+	require.NoError(t, err)
+	require.Equal(t, "c\nd\ne\n", out)
+
+	out, err = testMergeContents(t, s, "", "a\nb\n", "b\nc\n")
+	require.NoError(t, err)
+	require.Equal(t, "a\nb\nc\n", out)
+}
+
+func TestLastWriterWinsMergeStrategy(t *testing.T) {
+	s := LastWriterWinsMergeStrategy{}
+	now := time.Now()
+	in := ConflictMergeInput{
+		Merged:        []byte("merged"),
+		Unmerged:      []byte("unmerged"),
+		MergedMtime:   now,
+		UnmergedMtime: now.Add(-time.Second),
+	}
+	out, err := s.MergeContents(context.Background(), in)
+	require.NoError(t, err)
+	require.Equal(t, "merged", string(out))
+
+	in.UnmergedMtime = now
+	out, err = s.MergeContents(context.Background(), in)
+	require.NoError(t, err)
+	require.Equal(t, "unmerged", string(out))
+}
+
+func TestConflictMergeRegistry(t *testing.T) {
+	r, err := ParseConflictMergeRules(
+		"docs/*.json=lww, *.json=json,*.txt=text,.gitignore=lines")
+	require.NoError(t, err)
+	require.Equal(t, LastWriterWinsMergeStrategy{},
+		r.StrategyForPath("docs/a.json"))
+	require.Equal(t, JSONMergeStrategy{}, r.StrategyForPath("a.json"))
+	require.Equal(t, JSONMergeStrategy{}, r.StrategyForPath("x/docs/a.json"))
+	require.Equal(t, TextMergeStrategy{}, r.StrategyForPath("x/y/a.txt"))
+	require.Equal(t, LineSetMergeStrategy{}, r.StrategyForPath("x/.gitignore"))
+	require.Nil(t, r.StrategyForPath("a.bin"))
+
+	_, err = ParseConflictMergeRules("*.txt")
+	require.Error(t, err)
+	_, err = ParseConflictMergeRules("*.txt=magic")
+	require.Error(t, err)
+	_, err = ParseConflictMergeRules("[=text")
+	require.Error(t, err)
+}
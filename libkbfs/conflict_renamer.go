@@ -6,9 +6,12 @@ package libkbfs
 
 import (
 	"fmt"
+	stdpath "path"
+	"strings"
 	"time"
 
 	"github.com/keybase/client/go/protocol/keybase1"
+	"github.com/keybase/go-codec/codec"
 	"github.com/keybase/kbfs/kbfscrypto"
 	"github.com/keybase/kbfs/kbfsmd"
 	"golang.org/x/net/context"
@@ -25,14 +28,23 @@ type WriterDeviceDateConflictRenamer struct {
 func (cr WriterDeviceDateConflictRenamer) ConflictRename(
 	ctx context.Context, op op, original string) (string, error) {
 	now := cr.config.Clock().Now()
-	winfo := op.getWriterInfo()
-	ui, err := cr.config.KeybaseService().LoadUserPlusKeys(ctx, winfo.uid, "")
+	user, deviceName, err := getConflictRenameWriter(ctx, cr.config, op)
 	if err != nil {
 		return "", err
 	}
-	deviceName := ui.KIDNames[winfo.key.KID()]
-	return cr.ConflictRenameHelper(
-		now, string(ui.Name), deviceName, original), nil
+	return cr.ConflictRenameHelper(now, user, deviceName, original), nil
+}
+
+// getConflictRenameWriter returns the user and device names of the
+// writer of the given op.
+func getConflictRenameWriter(ctx context.Context, config Config, op op) (
+	user, device string, err error) {
+	winfo := op.getWriterInfo()
+	ui, err := config.KeybaseService().LoadUserPlusKeys(ctx, winfo.uid, "")
+	if err != nil {
+		return "", "", err
+	}
+	return string(ui.Name), ui.KIDNames[winfo.key.KID()], nil
 }
 
 // ConflictRenameHelper is a helper for ConflictRename especially useful from
@@ -47,6 +59,245 @@ func (WriterDeviceDateConflictRenamer) ConflictRenameHelper(t time.Time, user, d
 		base, user, device, date, ext)
 }
 
+// ConflictRenameRule names the conflict copies of the entries whose
+// paths match Pattern using Template.  A pattern without a "/" is
+// matched against the name of the entry, and otherwise against its
+// path relative to the root of the TLF.
+//
+// A template is the new name with these fields in braces:
+//
+//	{base}     the original name, without its extension
+//	{ext}      the extension of the original name, if any
+//	{user}     the writer of the conflicting change
+//	{device}   the device of the conflicting change
+//	{date}     the date of the resolution, as YYYY-MM-DD
+//	{time}     the time of the resolution, as HHMMSS
+//	{revision} the revision of the conflicting change
+//
+// {base} and {ext} must appear exactly once, in that order, so that
+// different originals never share a conflict copy name; and there
+// must be something besides them, so that a conflict copy never has
+// its original's name.  For example, "{base}.conflict-{user}{ext}".
+type ConflictRenameRule struct {
+	Pattern  string `codec:"p" json:"pattern"`
+	Template string `codec:"t" json:"template"`
+
+	codec.UnknownFieldSetHandler
+}
+
+// ConflictRenaming configures how conflict resolution names the
+// conflict copies in a TLF.  It's stored in the TLF metadata.
+type ConflictRenaming struct {
+	// Rules are tried in order, and the first matching one wins.
+	Rules []ConflictRenameRule `codec:"r" json:"rules,omitempty"`
+	// Template is used when no rule matches.  If empty, the
+	// ConflictRenamer of the Config is used instead.
+	Template string `codec:"t" json:"template,omitempty"`
+
+	codec.UnknownFieldSetHandler
+}
+
+// Validate returns an error if any pattern or template of the given
+// ConflictRenaming is invalid.
+func (r ConflictRenaming) Validate() error {
+	if r.Template != "" {
+		if _, err := parseConflictRenameTemplate(r.Template); err != nil {
+			return err
+		}
+	}
+	for _, rule := range r.Rules {
+		if _, err := stdpath.Match(rule.Pattern, ""); err != nil {
+			return fmt.Errorf("Bad conflict rename pattern %q: %v",
+				rule.Pattern, err)
+		}
+		if _, err := parseConflictRenameTemplate(rule.Template); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// templateForPath returns the template for the conflict copy of the
+// entry at the given path, relative to the root of the TLF, or "" if
+// there is none.
+func (r *ConflictRenaming) templateForPath(p string) string {
+	if r == nil {
+		return ""
+	}
+	for _, rule := range r.Rules {
+		if matchConflictPathPattern(rule.Pattern, p) {
+			return rule.Template
+		}
+	}
+	return r.Template
+}
+
+type conflictRenameSegment struct {
+	literal string
+	field   string // set instead of literal for a field
+}
+
+// parseConflictRenameTemplate splits the given template into literal
+// text and fields, and checks it against the rules documented on
+// ConflictRenameRule.
+func parseConflictRenameTemplate(template string) (
+	segs []conflictRenameSegment, err error) {
+	bases, exts, others := 0, 0, 0
+	rest := template
+	for len(rest) > 0 {
+		i := strings.IndexAny(rest, "{}")
+		if i < 0 {
+			i = len(rest)
+		} else if rest[i] == '}' {
+			return nil, fmt.Errorf(
+				"Unmatched } in conflict rename template %q", template)
+		}
+		if i > 0 {
+			literal := rest[:i]
+			if strings.ContainsAny(literal, "/\\\x00") {
+				return nil, fmt.Errorf("Conflict rename template %q "+
+					"contains a path separator", template)
+			}
+			segs = append(segs, conflictRenameSegment{literal: literal})
+			others++
+			rest = rest[i:]
+			continue
+		}
+
+		end := strings.IndexByte(rest, '}')
+		if end < 0 {
+			return nil, fmt.Errorf(
+				"Unmatched { in conflict rename template %q", template)
+		}
+		field := rest[1:end]
+		switch field {
+		case "base":
+			bases++
+		case "ext":
+			if bases == 0 {
+				return nil, fmt.Errorf("{ext} comes before {base} in "+
+					"conflict rename template %q", template)
+			}
+			exts++
+		case "user", "device", "date", "time", "revision":
+			others++
+		default:
+			return nil, fmt.Errorf("Unknown field {%s} in conflict "+
+				"rename template %q", field, template)
+		}
+		segs = append(segs, conflictRenameSegment{field: field})
+		rest = rest[end+1:]
+	}
+
+	if bases != 1 || exts != 1 {
+		return nil, fmt.Errorf("Conflict rename template %q must contain "+
+			"{base} and {ext} exactly once", template)
+	}
+	if others == 0 {
+		return nil, fmt.Errorf("Conflict rename template %q would keep "+
+			"the original name", template)
+	}
+	if segs[0].field == "" {
+		for _, prefix := range disallowedPrefixes {
+			if strings.HasPrefix(segs[0].literal, prefix) {
+				return nil, fmt.Errorf("Conflict rename template %q "+
+					"starts with the disallowed prefix %s", template, prefix)
+			}
+		}
+	}
+	return segs, nil
+}
+
+// renderConflictRename fills in a parsed template for the given
+// original name.  If uniq is greater than 1, " (uniq)" is inserted
+// just before the extension, to tell apart names that would
+// otherwise collide.
+func renderConflictRename(segs []conflictRenameSegment, original string,
+	t time.Time, user, device string, rev kbfsmd.Revision,
+	uniq int) string {
+	if device == "" {
+		device = "unknown"
+	}
+	base, ext := splitExtension(original)
+	parts := make([]string, 0, len(segs))
+	for _, seg := range segs {
+		var part string
+		switch seg.field {
+		case "":
+			part = seg.literal
+		case "base":
+			part = base
+		case "ext":
+			part = ext
+			if uniq > 1 {
+				part = fmt.Sprintf(" (%d)%s", uniq, ext)
+			}
+		case "user":
+			part = user
+		case "device":
+			part = device
+		case "date":
+			part = t.Format("2006-01-02")
+		case "time":
+			part = t.Format("150405")
+		case "revision":
+			part = rev.String()
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "")
+}
+
+// tlfConflictRenamer names conflict copies in a directory according
+// to the ConflictRenaming of its TLF, and falls back to another
+// ConflictRenamer for entries without a template.
+type tlfConflictRenamer struct {
+	config   Config
+	renaming *ConflictRenaming
+	// dir is the path of the directory relative to the root of
+	// the TLF.
+	dir string
+	// takenNames returns the set of names already used in the
+	// directory, including those handed out earlier in the same
+	// resolution.  Names returned by ConflictRename are added to
+	// it.
+	takenNames func(ctx context.Context) (map[string]bool, error)
+	fallback   ConflictRenamer
+}
+
+// ConflictRename implements the ConflictRenamer interface for
+// tlfConflictRenamer.
+func (cr tlfConflictRenamer) ConflictRename(
+	ctx context.Context, op op, original string) (string, error) {
+	template := cr.renaming.templateForPath(
+		strings.TrimPrefix(cr.dir+"/"+original, "/"))
+	if template == "" {
+		return cr.fallback.ConflictRename(ctx, op, original)
+	}
+	segs, err := parseConflictRenameTemplate(template)
+	if err != nil {
+		return "", err
+	}
+	user, device, err := getConflictRenameWriter(ctx, cr.config, op)
+	if err != nil {
+		return "", err
+	}
+	taken, err := cr.takenNames(ctx)
+	if err != nil {
+		return "", err
+	}
+	now := cr.config.Clock().Now()
+	rev := op.getWriterInfo().revision
+	for uniq := 1; ; uniq++ {
+		name := renderConflictRename(
+			segs, original, now, user, device, rev, uniq)
+		if !taken[name] {
+			taken[name] = true
+			return name, nil
+		}
+	}
+}
+
 // splitExtension splits filename into a base name and the extension.
 func splitExtension(path string) (string, string) {
 	for i := len(path) - 1; i > 0; i-- {
@@ -6,6 +6,9 @@ package libkbfs
 
 import (
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
 )
 
 func testSplitExtension(t *testing.T, s, base, ext string) {
@@ -30,3 +33,65 @@ func TestSplitExtension(t *testing.T) {
 	testSplitExtension(t, "weird. is this?", "weird. is this?", "")
 	testSplitExtension(t, "", "", "")
 }
+
+func TestParseConflictRenameTemplate(t *testing.T) {
+	for _, template := range []string{
+		"{base}.conflict-{user}-{device}-{date}-r{revision}{ext}",
+		"{base}{ext}.conflict-{user}",
+		"{user}-{base}{ext}",
+	} {
+		_, err := parseConflictRenameTemplate(template)
+		require.NoError(t, err, template)
+	}
+
+	for _, template := range []string{
+		"",
+		"{base}{ext}",
+		"{base}-{user}",
+		"{user}{ext}",
+		"{base}-{base}{ext}",
+		"{ext}-{base}",
+		"{base}-{nope}{ext}",
+		"{base}-{user{ext}",
+		"{base}-}{ext}",
+		"{base}/{user}{ext}",
+		".kbfs-{base}{ext}",
+	} {
+		_, err := parseConflictRenameTemplate(template)
+		require.Error(t, err, template)
+	}
+}
+
+func TestRenderConflictRename(t *testing.T) {
+	now := time.Date(2018, 7, 6, 5, 4, 3, 0, time.UTC)
+	segs, err := parseConflictRenameTemplate(
+		"{base}.conflict-{user}-{device}-{date}-{time}-r{revision}{ext}")
+	require.NoError(t, err)
+	require.Equal(t, "a.conflict-u1-unknown-2018-07-06-050403-r7.tar.gz",
+		renderConflictRename(segs, "a.tar.gz", now, "u1", "", 7, 1))
+	require.Equal(t, "Makefile.conflict-u1-dev-2018-07-06-050403-r7",
+		renderConflictRename(segs, "Makefile", now, "u1", "dev", 7, 1))
+	require.Equal(t, "a.conflict-u1-unknown-2018-07-06-050403-r7 (2).tar.gz",
+		renderConflictRename(segs, "a.tar.gz", now, "u1", "", 7, 2))
+}
+
+func TestConflictRenamingTemplateForPath(t *testing.T) {
+	r := &ConflictRenaming{
+		Rules: []ConflictRenameRule{
+			{Pattern: "src/*.go", Template: "{base}_conflict_{user}{ext}"},
+			{Pattern: "*.go", Template: "{base}{ext}.conflict"},
+		},
+		Template: "{base}-{user}{ext}",
+	}
+	require.NoError(t, r.Validate())
+	require.Equal(t, "{base}_conflict_{user}{ext}",
+		r.templateForPath("src/a.go"))
+	require.Equal(t, "{base}{ext}.conflict", r.templateForPath("x/src/a.go"))
+	require.Equal(t, "{base}-{user}{ext}", r.templateForPath("a.txt"))
+
+	var nilRenaming *ConflictRenaming
+	require.Equal(t, "", nilRenaming.templateForPath("a.txt"))
+
+	r.Rules[0].Pattern = "["
+	require.Error(t, r.Validate())
+}
@@ -441,6 +441,27 @@ func (cr *ConflictResolver) makeChains(ctx context.Context,
 		return nil, nil, err
 	}
 
+	// The syncOps of a file that was removed in the unmerged branch
+	// don't survive the resolution, and the rmOp only unreferences
+	// the blocks the file had at the time it was removed.  So make
+	// sure the blocks those syncOps unreferenced are still
+	// unreferenced, unless the merged branch touched the file too.
+	for original, chain := range unmergedChains.byOriginal {
+		if !chain.isFile() || !unmergedChains.isDeleted(original) ||
+			mergedChains.byOriginal[original] != nil ||
+			mergedChains.isDeleted(original) {
+			continue
+		}
+		for _, op := range chain.ops {
+			if _, isSyncOp := op.(*syncOp); !isSyncOp {
+				continue
+			}
+			for _, ptr := range op.Unrefs() {
+				unmergedChains.toUnrefPointers[ptr] = true
+			}
+		}
+	}
+
 	// Make the chain summaries.  Identify using the unmerged chains,
 	// since those are most likely to be able to identify a node in
 	// the cache.
@@ -2001,6 +2022,55 @@ func (cr *ConflictResolver) addMergedRecreates(ctx context.Context,
 	return nil
 }
 
+// conflictRenamerForPath returns the ConflictRenamer for the entries
+// of the directory at the given merged path (or of its parent, if the
+// path is to a file), honoring the ConflictRenaming of the TLF.
+// `taken` holds the names in use in each merged directory, keyed by
+// its tail pointer; it is filled in lazily and shared across the
+// whole resolution, so conflict copies never collide with each other
+// or with the existing entries.
+func (cr *ConflictResolver) conflictRenamerForPath(
+	mergedChains *crChains, mergedPath path, isFile bool,
+	taken map[BlockPointer]map[string]bool) ConflictRenamer {
+	renaming := mergedChains.mostRecentChainMDInfo.conflictRenaming
+	if renaming == nil {
+		return cr.config.ConflictRenamer()
+	}
+	dirPath := mergedPath
+	if isFile {
+		dirPath = *mergedPath.parentPath()
+	}
+	names := make([]string, 0, len(dirPath.path))
+	for _, pn := range dirPath.path[1:] {
+		names = append(names, pn.Name)
+	}
+	takenNames := func(ctx context.Context) (map[string]bool, error) {
+		ptr := dirPath.tailPointer()
+		if dirNames, ok := taken[ptr]; ok {
+			return dirNames, nil
+		}
+		dblock, err := cr.fbo.blocks.GetDirBlockForReading(
+			ctx, makeFBOLockState(), mergedChains.mostRecentChainMDInfo.kmd,
+			ptr, dirPath.Branch, dirPath)
+		if err != nil {
+			return nil, err
+		}
+		dirNames := make(map[string]bool, len(dblock.Children))
+		for name := range dblock.Children {
+			dirNames[name] = true
+		}
+		taken[ptr] = dirNames
+		return dirNames, nil
+	}
+	return tlfConflictRenamer{
+		config:     cr.config,
+		renaming:   renaming,
+		dir:        strings.Join(names, "/"),
+		takenNames: takenNames,
+		fallback:   cr.config.ConflictRenamer(),
+	}
+}
+
 // getActionsToMerge returns the set of actions needed to merge each
 // unmerged chain of operations, in a map keyed by the tail pointer of
 // the corresponding merged path.
@@ -2009,6 +2079,7 @@ func (cr *ConflictResolver) getActionsToMerge(
 	mergedPaths map[BlockPointer]path) (
 	map[BlockPointer]crActionList, error) {
 	actionMap := make(map[BlockPointer]crActionList)
+	takenNames := make(map[BlockPointer]map[string]bool)
 	for unmergedMostRecent, unmergedChain := range unmergedChains.byMostRecent {
 		original := unmergedChain.original
 		// If this is a file that has been deleted in the merged
@@ -2031,12 +2102,16 @@ func (cr *ConflictResolver) getActionsToMerge(
 			continue
 		}
 
+		renamer := cr.conflictRenamerForPath(
+			mergedChains, mergedPath, unmergedChain.isFile(), takenNames)
 		actions, err := unmergedChain.getActionsToMerge(
-			ctx, cr.config.ConflictRenamer(), mergedPath,
-			mergedChain)
+			ctx, renamer, mergedPath, mergedChain)
 		if err != nil {
 			return nil, err
 		}
+		if unmergedChain.isFile() && mergedChain != nil {
+			cr.addFileMerges(ctx, mergedPath, actions)
+		}
 
 		if len(actions) > 0 {
 			actionMap[mergedPath.tailPointer()] = actions
@@ -2046,6 +2121,35 @@ func (cr *ConflictResolver) getActionsToMerge(
 	return actionMap, nil
 }
 
+// addFileMerges marks the file-file conflicts in `actions` as
+// candidates for a content merge, if the ConflictMerger has a
+// strategy for the file at `mergedPath`.
+func (cr *ConflictResolver) addFileMerges(
+	ctx context.Context, mergedPath path, actions crActionList) {
+	merger := cr.config.ConflictMerger()
+	if merger == nil {
+		return
+	}
+	names := make([]string, 0, len(mergedPath.path)-1)
+	for _, node := range mergedPath.path[1:] {
+		names = append(names, node.Name)
+	}
+	p := strings.Join(names, "/")
+	strategy := merger.StrategyForPath(p)
+	if strategy == nil {
+		return
+	}
+	for _, action := range actions {
+		rua, ok := action.(*renameUnmergedAction)
+		if !ok || !rua.unmergedParentMostRecent.IsInitialized() ||
+			rua.symPath != "" {
+			continue
+		}
+		cr.log.CDebugf(ctx, "Will try to merge the contents of %s", p)
+		rua.merge = &crFileMerge{path: p, strategy: strategy}
+	}
+}
+
 // collapseActions combines file updates with their parent directory
 // updates, because conflict resolution only happens within a
 // directory (i.e., files are merged directly, they are just
@@ -2279,6 +2383,137 @@ func (cr *ConflictResolver) makeFileBlockDeepCopy(ctx context.Context,
 	return newPtr, nil
 }
 
+// readFileForMerge returns the contents of the file at `ptr`, named
+// `name` under `parentPath`.  Only files that fit in a single block
+// are merged.
+func (cr *ConflictResolver) readFileForMerge(ctx context.Context,
+	lState *lockState, kmd KeyMetadata, parentPath path, name string,
+	ptr BlockPointer) ([]byte, error) {
+	fblock, err := cr.fbo.blocks.GetFileBlockForReading(ctx, lState, kmd,
+		ptr, parentPath.Branch, parentPath.ChildPath(name, ptr))
+	if err != nil {
+		return nil, err
+	}
+	if fblock.IsInd {
+		return nil, fmt.Errorf("%s is too big to merge", name)
+	}
+	if fblock.Contents == nil {
+		return []byte{}, nil
+	}
+	return fblock.Contents, nil
+}
+
+// mergeFileContents tries to merge the unmerged and merged versions
+// of the file of `rua`, with the strategy picked for it.  On
+// success, it makes a copy of the unmerged file with the merged
+// contents, and records it in `rua.merge`; otherwise, `rua` is left
+// to rename the unmerged copy as usual.  Only files that fit in a
+// single block, in every version and once merged, are merged, and
+// only if both versions descend from the same original file.
+func (cr *ConflictResolver) mergeFileContents(ctx context.Context,
+	lState *lockState, unmergedChains, mergedChains *crChains,
+	rua *renameUnmergedAction, unmergedPath, mergedPath path,
+	unmergedBlock, mergedBlock *DirBlock, newFileBlocks fileBlockMap,
+	dirtyBcache DirtyBlockCache) error {
+	name := rua.fromName
+	unmergedEntry, ok := unmergedBlock.Children[name]
+	if !ok {
+		return NoSuchNameError{name}
+	}
+	mergedEntry, ok := mergedBlock.Children[name]
+	if !ok {
+		return NoSuchNameError{name}
+	}
+	if unmergedEntry.Type == Dir || unmergedEntry.Type == Sym ||
+		mergedEntry.Type == Dir || mergedEntry.Type == Sym {
+		return fmt.Errorf("%s isn't a file in both branches", name)
+	}
+
+	in := ConflictMergeInput{
+		Path:          rua.merge.path,
+		MergedMtime:   time.Unix(0, mergedEntry.Mtime),
+		UnmergedMtime: time.Unix(0, unmergedEntry.Mtime),
+	}
+	var err error
+	in.Unmerged, err = cr.readFileForMerge(ctx, lState,
+		unmergedChains.mostRecentChainMDInfo.kmd, unmergedPath, name,
+		unmergedEntry.BlockPointer)
+	if err != nil {
+		return err
+	}
+	mergedKMD := mergedChains.mostRecentChainMDInfo.kmd
+	in.Merged, err = cr.readFileForMerge(ctx, lState, mergedKMD,
+		mergedPath, name, mergedEntry.BlockPointer)
+	if err != nil {
+		return err
+	}
+	// A file that was created separately in each branch has no
+	// common ancestor, and the two versions have nothing to do with
+	// each other, so keep both.
+	original, err := unmergedChains.originalFromMostRecentOrSame(
+		unmergedEntry.BlockPointer)
+	if err != nil {
+		return err
+	}
+	mergedOriginal, err := mergedChains.originalFromMostRecentOrSame(
+		mergedEntry.BlockPointer)
+	if err != nil {
+		return err
+	}
+	if original != mergedOriginal || unmergedChains.isCreated(original) ||
+		mergedChains.isCreated(mergedOriginal) {
+		return fmt.Errorf("%s has no common ancestor", in.Path)
+	}
+	// The common ancestor may not be around anymore, in which case
+	// only the strategies that don't need it can merge.
+	in.Base, err = cr.readFileForMerge(
+		ctx, lState, mergedKMD, mergedPath, name, original)
+	if err != nil {
+		cr.log.CDebugf(ctx, "Couldn't read the common ancestor of %s: %+v",
+			in.Path, err)
+		in.Base = nil
+	}
+
+	contents, err := rua.merge.strategy.MergeContents(ctx, in)
+	if err != nil {
+		return err
+	}
+	check := NewFileBlock().(*FileBlock)
+	check.Contents = contents
+	if cr.config.BlockSplitter().CheckSplit(check) > 0 {
+		return fmt.Errorf("The merged %s is too big", in.Path)
+	}
+
+	newPtr, err := cr.makeFileBlockDeepCopy(ctx, lState, unmergedChains,
+		mergedPath.tailPointer(), unmergedPath, name,
+		unmergedEntry.BlockPointer, newFileBlocks, dirtyBcache)
+	if err != nil {
+		return err
+	}
+	// Don't share the contents with the blocks that were read.
+	newFileBlocks[mergedPath.tailPointer()][name].Contents =
+		append([]byte(nil), contents...)
+
+	entry := mergedEntry
+	entry.BlockPointer = newPtr
+	entry.Size = uint64(len(contents))
+	if unmergedEntry.Mtime > entry.Mtime {
+		entry.Mtime = unmergedEntry.Mtime
+	}
+	if unmergedEntry.Ctime > entry.Ctime {
+		entry.Ctime = unmergedEntry.Ctime
+	}
+	rua.merge.done = true
+	rua.merge.entry = entry
+	rua.merge.unmergedPtr = unmergedEntry.BlockPointer
+	rua.merge.replacedPtrs = []BlockPointer{mergedEntry.BlockPointer}
+	if unmergedEntry.BlockPointer != mergedEntry.BlockPointer {
+		rua.merge.replacedPtrs = append(
+			rua.merge.replacedPtrs, unmergedEntry.BlockPointer)
+	}
+	return nil
+}
+
 func (cr *ConflictResolver) doActions(ctx context.Context,
 	lState *lockState, unmergedChains, mergedChains *crChains,
 	unmergedPaths []path, mergedPaths map[BlockPointer]path,
@@ -2409,6 +2644,20 @@ func (cr *ConflictResolver) doActions(ctx context.Context,
 					}
 				}
 
+				if rua, ok := action.(*renameUnmergedAction); ok &&
+					rua.merge != nil && !swap {
+					err := cr.mergeFileContents(ctx, lState, unmergedChains,
+						mergedChains, rua, unmergedPath, mergedPath, uBlock,
+						mergedBlock, newFileBlocks, dirtyBcache)
+					if err != nil {
+						cr.log.CDebugf(ctx, "Couldn't merge %s, renaming "+
+							"it instead: %+v", rua.merge.path, err)
+					} else {
+						cr.log.CDebugf(ctx, "Merged the contents of %s",
+							rua.merge.path)
+					}
+				}
+
 				err = action.do(ctx, unmergedFetcher, mergedFetcher, uBlock,
 					mergedBlock)
 				if err != nil {
@@ -2439,6 +2688,76 @@ func (cr *ConflictResolver) doActions(ctx context.Context,
 	return nil
 }
 
+// conflictCopies returns a record of each conflict copy made by the
+// executed actions in actionMap.
+func (cr *ConflictResolver) conflictCopies(
+	mergedPaths map[BlockPointer]path,
+	actionMap map[BlockPointer]crActionList,
+	mergedRev kbfsmd.Revision) []ConflictCopy {
+	dirNames := make(map[BlockPointer]string, len(mergedPaths))
+	for _, p := range mergedPaths {
+		names := make([]string, 0, len(p.path))
+		for _, pn := range p.path[1:] {
+			names = append(names, pn.Name)
+		}
+		dirNames[p.tailPointer()] = strings.Join(names, "/")
+	}
+	join := func(dir, name string) string {
+		if dir == "" {
+			return name
+		}
+		return dir + "/" + name
+	}
+
+	seen := make(map[string]bool)
+	var copies []ConflictCopy
+	for ptr, actions := range actionMap {
+		dir, ok := dirNames[ptr]
+		if !ok {
+			// The actions were never executed.
+			continue
+		}
+		for _, action := range actions {
+			var cc ConflictCopy
+			switch realAction := action.(type) {
+			case *renameUnmergedAction:
+				if realAction.isMerged() ||
+					realAction.fromName == realAction.toName {
+					continue
+				}
+				cc = ConflictCopy{
+					OriginalPath:   join(dir, realAction.fromName),
+					ConflictPath:   join(dir, realAction.toName),
+					OriginalWriter: realAction.mergedWriter,
+					ConflictWriter: realAction.unmergedWriter,
+				}
+			case *renameMergedAction:
+				if realAction.fromName == realAction.toName {
+					continue
+				}
+				cc = ConflictCopy{
+					OriginalPath:   join(dir, realAction.fromName),
+					ConflictPath:   join(dir, realAction.toName),
+					OriginalWriter: realAction.unmergedWriter,
+					ConflictWriter: realAction.mergedWriter,
+				}
+			default:
+				continue
+			}
+			if seen[cc.ConflictPath] {
+				continue
+			}
+			seen[cc.ConflictPath] = true
+			cc.MergedRevision = mergedRev
+			copies = append(copies, cc)
+		}
+	}
+	sort.Slice(copies, func(i, j int) bool {
+		return copies[i].ConflictPath < copies[j].ConflictPath
+	})
+	return copies
+}
+
 type crRenameHelperKey struct {
 	parentOriginal BlockPointer
 	name           string
@@ -3005,13 +3324,14 @@ func (cr *ConflictResolver) completeResolution(ctx context.Context,
 	unmergedPaths []path, mergedPaths map[BlockPointer]path,
 	mostRecentUnmergedMD, mostRecentMergedMD ImmutableRootMetadata,
 	lbc localBcache, newFileBlocks fileBlockMap, dirtyBcache DirtyBlockCache,
-	writerLocked bool) (err error) {
+	conflicts []ConflictCopy, writerLocked bool) (err error) {
 	md, err := cr.createResolvedMD(
 		ctx, lState, unmergedPaths, unmergedChains,
 		mergedChains, mostRecentMergedMD)
 	if err != nil {
 		return err
 	}
+	md.addConflictCopies(conflicts)
 
 	resolvedPaths, err := cr.makePostResolutionPaths(ctx, md, unmergedChains,
 		mergedChains, mergedPaths)
@@ -3284,7 +3604,7 @@ func (cr *ConflictResolver) doResolve(ctx context.Context, ci conflictInput) {
 		err = cr.completeResolution(ctx, lState, unmergedChains,
 			mergedChains, unmergedPaths, mergedPaths,
 			unmergedMDs[len(unmergedMDs)-1], mostRecentMergedMD, lbc,
-			newFileBlocks, nil, doLock)
+			newFileBlocks, nil, nil, doLock)
 		return
 	}
 
@@ -3381,12 +3701,17 @@ func (cr *ConflictResolver) doResolve(ctx context.Context, ci conflictInput) {
 	cr.log.CDebugf(ctx, "Executed all actions, %d updated directory blocks",
 		len(lbc))
 
+	mostRecentUnmergedMD := unmergedMDs[len(unmergedMDs)-1]
+	conflicts := cr.conflictCopies(
+		mergedPaths, actionMap, mostRecentMergedMD.Revision())
+
 	// Step 4: finish up by syncing all the blocks, computing and
 	// putting the final resolved MD, and issuing all the local
 	// notifications.
 	err = cr.completeResolution(ctx, lState, unmergedChains, mergedChains,
-		unmergedPaths, mergedPaths, unmergedMDs[len(unmergedMDs)-1],
-		mostRecentMergedMD, lbc, newFileBlocks, dirtyBcache, doLock)
+		unmergedPaths, mergedPaths, mostRecentUnmergedMD,
+		mostRecentMergedMD, lbc, newFileBlocks, dirtyBcache, conflicts,
+		doLock)
 	if err != nil {
 		return
 	}
@@ -1089,7 +1089,7 @@ func TestCRMergedChainsConflictSimple(t *testing.T) {
 		mergedPathRoot.tailPointer(): {&renameUnmergedAction{
 			"file1",
 			cre.ConflictRenameHelper(now, "u2", "dev1", "file1"),
-			"", 0, false, zeroPtr, zeroPtr}},
+			"", 0, false, zeroPtr, zeroPtr, nil, uid1, uid2}},
 	}
 
 	testCRCheckPathsAndActions(t, cr2, []path{unmergedPathRoot},
@@ -1208,7 +1208,7 @@ func TestCRMergedChainsConflictFileCollapse(t *testing.T) {
 		mergedPathRoot.tailPointer(): {&renameUnmergedAction{
 			"file",
 			cre.ConflictRenameHelper(now, "u2", "dev1", "file"),
-			"", 0, false, zeroPtr, zeroPtr}},
+			"", 0, false, zeroPtr, zeroPtr, nil, uid1, uid2}},
 	}
 
 	testCRCheckPathsAndActions(t, cr2, []path{unmergedPathFile},
@@ -7,6 +7,7 @@ package libkbfs
 import (
 	"fmt"
 
+	"github.com/keybase/client/go/protocol/keybase1"
 	"golang.org/x/net/context"
 )
 
@@ -478,8 +479,29 @@ func (rmea *rmMergedEntryAction) String() string {
 	return fmt.Sprintf("rmMergedEntry: %s", rmea.name)
 }
 
+// crFileMerge describes a file that was written in both branches,
+// and whose contents may be merged with a ConflictMergeStrategy
+// rather than renaming the unmerged copy.
+type crFileMerge struct {
+	path     string
+	strategy ConflictMergeStrategy
+
+	// The fields below are only set once the conflict resolver has
+	// merged the contents successfully.
+	done bool
+	// entry replaces the merged entry of the file.
+	entry DirEntry
+	// unmergedPtr is the most recent unmerged pointer of the file.
+	unmergedPtr BlockPointer
+	// replacedPtrs are the pointers of the merged and unmerged
+	// versions of the file, which are no longer referenced.
+	replacedPtrs []BlockPointer
+}
+
 // renameUnmergedAction says that the unmerged copy of a file needs to
-// be renamed, and the file blocks should be copied.
+// be renamed, and the file blocks should be copied.  If it has a
+// successful merge, the merged contents replace the merged copy of
+// the file instead, and nothing is renamed.
 type renameUnmergedAction struct {
 	fromName     string
 	toName       string
@@ -491,6 +513,13 @@ type renameUnmergedAction struct {
 	// chains need to be updated with new create/rename operations.
 	unmergedParentMostRecent BlockPointer
 	mergedParentMostRecent   BlockPointer
+
+	merge *crFileMerge
+
+	// The writers of the merged and unmerged versions of the entry,
+	// as of the conflicting ops.
+	mergedWriter   keybase1.UID
+	unmergedWriter keybase1.UID
 }
 
 func crActionCopyFile(ctx context.Context, copier fileBlockDeepCopier,
@@ -540,6 +569,41 @@ func crActionCopyFile(ctx context.Context, copier fileBlockDeepCopier,
 	return oldPointer, name, nil
 }
 
+// isMerged returns true if the contents of the file have been merged.
+func (rua *renameUnmergedAction) isMerged() bool {
+	return rua.merge != nil && rua.merge.done
+}
+
+// updateOpsForMerge points all the unmerged file operations at the
+// merged contents, and unreferences both the versions they replace.
+func (rua *renameUnmergedAction) updateOpsForMerge(
+	unmergedChain *crChain) error {
+	newPtr := rua.merge.entry.BlockPointer
+	unrefsAdded := false
+	for _, op := range unmergedChain.ops {
+		switch realOp := op.(type) {
+		case *syncOp:
+			var err error
+			realOp.File, err = makeBlockUpdate(newPtr, newPtr)
+			if err != nil {
+				return err
+			}
+			// The blocks written in the unmerged branch aren't
+			// part of the merged file.
+			realOp.RefBlocks = nil
+			if !unrefsAdded {
+				for _, ptr := range rua.merge.replacedPtrs {
+					realOp.AddUnrefBlock(ptr)
+				}
+				unrefsAdded = true
+			}
+		case *setAttrOp:
+			realOp.File = newPtr
+		}
+	}
+	return nil
+}
+
 func (rua *renameUnmergedAction) swapUnmergedBlock(
 	unmergedChains *crChains, mergedChains *crChains,
 	unmergedBlock *DirBlock) (bool, BlockPointer, error) {
@@ -549,6 +613,12 @@ func (rua *renameUnmergedAction) swapUnmergedBlock(
 func (rua *renameUnmergedAction) do(ctx context.Context,
 	unmergedCopier fileBlockDeepCopier, mergedCopier fileBlockDeepCopier,
 	unmergedBlock *DirBlock, mergedBlock *DirBlock) error {
+	if rua.isMerged() {
+		mergedBlock.Children[rua.fromName] = rua.merge.entry
+		rua.toName = rua.fromName
+		return nil
+	}
+
 	_, name, err := crActionCopyFile(ctx, unmergedCopier, rua.fromName,
 		rua.toName, rua.symPath, unmergedBlock, mergedBlock)
 	if err != nil {
@@ -576,6 +646,15 @@ func (rua *renameUnmergedAction) updateOps(unmergedMostRecent BlockPointer,
 		}
 	}
 
+	if rua.isMerged() {
+		// Only the ops of the merged file change; there's nothing
+		// to rename in its parent.
+		if unmergedMostRecent != rua.merge.unmergedPtr {
+			return nil
+		}
+		return rua.updateOpsForMerge(unmergedChain)
+	}
+
 	// Rename all operations with the old name to the new name.
 	unmergedChain.ops =
 		fixupNamesInOps(rua.fromName, rua.toName, unmergedChain.ops,
@@ -704,6 +783,9 @@ func (rua *renameUnmergedAction) updateOps(unmergedMostRecent BlockPointer,
 }
 
 func (rua *renameUnmergedAction) String() string {
+	if rua.isMerged() {
+		return fmt.Sprintf("renameUnmerged: merged %s", rua.fromName)
+	}
 	return fmt.Sprintf("renameUnmerged: %s -> %s %s", rua.fromName, rua.toName,
 		rua.symPath)
 }
@@ -721,6 +803,11 @@ type renameMergedAction struct {
 	fromName string
 	toName   string
 	symPath  string
+
+	// The writers of the merged and unmerged versions of the entry,
+	// as of the conflicting ops.
+	mergedWriter   keybase1.UID
+	unmergedWriter keybase1.UID
 }
 
 func (rma *renameMergedAction) swapUnmergedBlock(
@@ -84,6 +84,21 @@ func (cc *crChain) collapse(createdOriginals map[BlockPointer]bool,
 			wr = realOp.collapseWriteRange(wr)
 			indicesToRemove[i] = true
 			lastSyncOp = i
+			// Any block ref'd by an earlier syncOp and unref'd by
+			// this one (e.g., an old top block that moved under a
+			// new level of indirection) didn't survive the branch,
+			// so only list its unref in the collapsed op.
+			unrefs := make(map[BlockPointer]bool, len(op.Unrefs()))
+			for _, unref := range op.Unrefs() {
+				unrefs[unref] = true
+			}
+			refs := syncRefs[:0]
+			for _, ref := range syncRefs {
+				if !unrefs[ref] {
+					refs = append(refs, ref)
+				}
+			}
+			syncRefs = refs
 			// The last op will have its refs listed twice in the
 			// collapsed op, but that's harmless.
 			syncRefs = append(syncRefs, op.Refs()...)
@@ -207,6 +222,7 @@ func (cc *crChain) getActionsToMerge(
 				}
 				if action != nil {
 					conflict = true
+					setConflictWriters(action, unmergedOp, mergedOp)
 					actions = append(actions, action)
 				}
 			}
@@ -386,8 +402,9 @@ func (ri renameInfo) String() string {
 // mostRecentChainMetadataInfo contains the subset of information for
 // the most recent chainMetadata that is needed for crChains.
 type mostRecentChainMetadataInfo struct {
-	kmd      KeyMetadata
-	rootInfo BlockInfo
+	kmd              KeyMetadata
+	rootInfo         BlockInfo
+	conflictRenaming *ConflictRenaming
 }
 
 // crChains contains a crChain for every KBFS node affected by the
@@ -932,8 +949,9 @@ func newCRChains(
 	}
 
 	ccs.mostRecentChainMDInfo = mostRecentChainMetadataInfo{
-		kmd:      mostRecentMD,
-		rootInfo: mostRecentMD.Data().Dir.BlockInfo,
+		kmd:              mostRecentMD,
+		rootInfo:         mostRecentMD.Data().Dir.BlockInfo,
+		conflictRenaming: mostRecentMD.Data().ConflictRenaming,
 	}
 
 	return ccs, nil
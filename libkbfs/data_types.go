@@ -699,6 +699,66 @@ func (s PrefetchStatus) String() string {
 	return "Unknown"
 }
 
+// TlfOfflineStatus denotes whether a TLF is being served without the
+// MD server.
+type TlfOfflineStatus int
+
+const (
+	// TlfOnline means the MD server is reachable.
+	TlfOnline TlfOfflineStatus = iota
+	// TlfOffline means the MD server is unreachable, and the TLF is
+	// served from local caches: its MD head is cached on disk, and it
+	// is synced, with its whole block tree in the disk block cache.
+	TlfOffline
+	// TlfOfflineUnavailable means the MD server is unreachable, and
+	// the TLF can't be fully served from local caches, so reading
+	// anything that isn't cached fails.
+	TlfOfflineUnavailable
+)
+
+func (s TlfOfflineStatus) String() string {
+	switch s {
+	case TlfOnline:
+		return "Online"
+	case TlfOffline:
+		return "Offline"
+	case TlfOfflineUnavailable:
+		return "OfflineUnavailable"
+	}
+	return "Unknown"
+}
+
+// PrefetchProgress describes how much of the block tree under a
+// given block has been prefetched so far.  The totals only count
+// blocks that the prefetch has discovered so far, so they are lower
+// bounds that keep growing as more of the tree is fetched.
+type PrefetchProgress struct {
+	BlocksFetched int
+	BlocksTotal   int
+	BytesFetched  uint64
+	BytesTotal    uint64
+	// Start is when the prefetch began.
+	Start time.Time
+	// EndEstimate is when the prefetch is expected to finish, based
+	// on the byte rate observed since Start.  Since BytesTotal can
+	// still grow, the estimate can move later over time.  It's the
+	// zero time when nothing has been fetched yet.
+	EndEstimate time.Time
+}
+
+// NodePrefetchStatus describes the prefetch status of the subtree
+// under a node, e.g. to decide whether a directory can be used
+// offline.
+type NodePrefetchStatus struct {
+	// Status is the string form of the PrefetchStatus of the node's
+	// block.  The subtree is fully synced once it's
+	// "FinishedPrefetch".
+	Status string
+	// Progress is only filled in while a prefetch of the subtree
+	// is in progress.
+	Progress *PrefetchProgress `json:",omitempty"`
+}
+
 // ToProtocol transforms a PrefetchStatus to a kbgitkbfs.PrefetchStatus, while
 // validating its value.
 func (s PrefetchStatus) ToProtocol() kbgitkbfs.PrefetchStatus {
@@ -214,6 +214,9 @@ func (df *dirtyFile) resetSyncingBlocksToDirty() {
 			df.dirtyBcache.UpdateSyncingBytes(df.path.Tlf, -state.syncSize)
 		}
 		if state.sync != blockNotSyncing {
+			// These bytes will be synced again, so they aren't
+			// syncing anymore.
+			df.notYetSyncingBytes += state.syncSize
 			state.copy = blockAlreadyCopied
 			state.sync = blockNotSyncing
 			state.syncSize = 0
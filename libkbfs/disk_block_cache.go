@@ -59,6 +59,9 @@ type DiskBlockCacheLocal struct {
 	// Track the aggregate size of blocks in the cache per TLF and overall.
 	tlfSizes  map[tlf.ID]uint64
 	currBytes uint64
+	// Track the last time any block of each TLF was used, so that
+	// eviction can pick the least recently used TLF.
+	tlfLastUse map[tlf.ID]time.Time
 	// Track the cache hit rate and eviction rate
 	hitMeter         *CountMeter
 	missMeter        *CountMeter
@@ -116,6 +119,19 @@ type DiskBlockCacheStatus struct {
 	SizeEvicted     MeterStatus
 	NumDeleted      MeterStatus
 	SizeDeleted     MeterStatus
+	// TLFs maps the ID of each TLF with blocks in the cache to its
+	// usage of the cache.
+	TLFs map[string]DiskBlockCacheTlfStatus `json:",omitempty"`
+}
+
+// DiskBlockCacheTlfStatus represents the usage of the disk cache by a
+// single TLF.
+type DiskBlockCacheTlfStatus struct {
+	Priority   string
+	NumBlocks  uint64
+	BlockBytes uint64
+	// ByteLimit is the configured cap for the TLF, if any.
+	ByteLimit uint64 `json:",omitempty"`
 }
 
 // newDiskBlockCacheStandardFromStorage creates a new *DiskBlockCacheStandard
@@ -174,6 +190,7 @@ func newDiskBlockCacheStandardFromStorage(
 		cacheType:        cacheType,
 		tlfCounts:        map[tlf.ID]int{},
 		tlfSizes:         map[tlf.ID]uint64{},
+		tlfLastUse:       map[tlf.ID]time.Time{},
 		hitMeter:         NewCountMeter(),
 		missMeter:        NewCountMeter(),
 		putMeter:         NewCountMeter(),
@@ -360,6 +377,7 @@ func (cache *DiskBlockCacheLocal) syncBlockCountsFromDb() error {
 
 	tlfCounts := make(map[tlf.ID]int)
 	tlfSizes := make(map[tlf.ID]uint64)
+	tlfLastUse := make(map[tlf.ID]time.Time)
 	numBlocks := 0
 	totalSize := uint64(0)
 	iter := cache.metaDb.NewIterator(nil, nil)
@@ -373,12 +391,16 @@ func (cache *DiskBlockCacheLocal) syncBlockCountsFromDb() error {
 		size := uint64(metadata.BlockSize)
 		tlfCounts[metadata.TlfID]++
 		tlfSizes[metadata.TlfID] += size
+		if metadata.LRUTime.After(tlfLastUse[metadata.TlfID]) {
+			tlfLastUse[metadata.TlfID] = metadata.LRUTime
+		}
 		numBlocks++
 		totalSize += size
 	}
 	cache.tlfCounts = tlfCounts
 	cache.numBlocks = numBlocks
 	cache.tlfSizes = tlfSizes
+	cache.tlfLastUse = tlfLastUse
 	cache.currBytes = totalSize
 	return nil
 }
@@ -394,6 +416,7 @@ func (*DiskBlockCacheLocal) tlfKey(tlfID tlf.ID, blockKey []byte) []byte {
 func (cache *DiskBlockCacheLocal) updateMetadataLocked(ctx context.Context,
 	blockKey []byte, metadata DiskBlockCacheMetadata) error {
 	metadata.LRUTime = cache.config.Clock().Now()
+	cache.tlfLastUse[metadata.TlfID] = metadata.LRUTime
 	encodedMetadata, err := cache.config.Codec().Encode(&metadata)
 	if err != nil {
 		return err
@@ -590,8 +613,13 @@ func (cache *DiskBlockCacheLocal) Put(ctx context.Context, tlfID tlf.ID,
 		return err
 	}
 	if !hasKey {
+		err = cache.makeRoomInTlfLocked(ctx, tlfID, blockID, encodedLen)
+		if err != nil {
+			return err
+		}
 		if cache.cacheType == syncCacheLimitTrackerType {
-			if !cache.config.IsSyncedTlf(tlfID) {
+			if !cache.config.IsSyncedTlf(tlfID) &&
+				len(cache.config.GetTlfSyncedPaths(tlfID)) == 0 {
 				// TODO: Make better error type
 				return errors.New("Attempted to add a block of an unsynced " +
 					"TLF to the sync disk cache.")
@@ -757,6 +785,11 @@ func (cache *DiskBlockCacheLocal) deleteLocked(ctx context.Context,
 		cache.numBlocks -= v
 		cache.tlfSizes[k] -= removalSizes[k]
 		cache.currBytes -= removalSizes[k]
+		if cache.tlfCounts[k] <= 0 {
+			// Forget TLFs with no blocks left, so the map doesn't
+			// grow with every TLF ever cached.
+			delete(cache.tlfLastUse, k)
+		}
 	}
 	cache.config.DiskLimiter().release(ctx, cache.cacheType,
 		sizeRemoved, 0)
@@ -778,6 +811,31 @@ func (cache *DiskBlockCacheLocal) Delete(ctx context.Context,
 	return cache.deleteLocked(ctx, blockIDs)
 }
 
+// getTlfBlockIDs returns the IDs of all the blocks of the given TLF
+// that are in the cache.
+func (cache *DiskBlockCacheLocal) getTlfBlockIDs(tlfID tlf.ID) (
+	[]kbfsblock.ID, error) {
+	cache.lock.RLock()
+	defer cache.lock.RUnlock()
+	err := cache.checkCacheLocked("getTlfBlockIDs")
+	if err != nil {
+		return nil, err
+	}
+
+	tlfBytes := tlfID.Bytes()
+	iter := cache.tlfDb.NewIterator(util.BytesPrefix(tlfBytes), nil)
+	defer iter.Release()
+	var blockIDs []kbfsblock.ID
+	for iter.Next() {
+		blockID, err := kbfsblock.IDFromBytes(iter.Key()[len(tlfBytes):])
+		if err != nil {
+			return nil, err
+		}
+		blockIDs = append(blockIDs, blockID)
+	}
+	return blockIDs, errors.WithStack(iter.Error())
+}
+
 // getRandomBlockID gives us a pivot block ID for picking a random range of
 // blocks to consider deleting.  We pick a point to start our range based on
 // the proportion of the TLF space taken up by numElements/totalElements. E.g.
@@ -825,8 +883,10 @@ func (cache *DiskBlockCacheLocal) evictSomeBlocks(ctx context.Context,
 // We choose a pivot variable b randomly. Then begin an iterator into
 // cache.tlfDb.Range(tlfID + b, tlfID + MaxBlockID) and iterate from there to
 // get numBlocks * evictionConsiderationFactor block IDs.  We sort the
-// resulting blocks by value (LRU time) and pick the minimum numBlocks. We then
-// call cache.Delete() on that list of block IDs.
+// resulting blocks by value (LRU time) and pick the minimum numBlocks. If the
+// pivot is too close to the end of the TLF's range to find that many block
+// IDs, we wrap around and continue from the start of the range up to the
+// pivot. We then call cache.Delete() on that list of block IDs.
 func (cache *DiskBlockCacheLocal) evictFromTLFLocked(ctx context.Context,
 	tlfID tlf.ID, numBlocks int) (numRemoved int, sizeRemoved int64, err error) {
 	tlfBytes := tlfID.Bytes()
@@ -835,45 +895,110 @@ func (cache *DiskBlockCacheLocal) evictFromTLFLocked(ctx context.Context,
 	if err != nil {
 		return 0, 0, err
 	}
-	rng := &util.Range{
-		Start: append(tlfBytes, blockID.Bytes()...),
-		Limit: append(tlfBytes, cache.maxBlockID...),
-	}
-	iter := cache.tlfDb.NewIterator(rng, nil)
-	defer iter.Release()
+	pivot := append(append([]byte(nil), tlfBytes...), blockID.Bytes()...)
 
 	blockIDs := make(blockIDsByTime, 0, numElements)
+	collect := func(rng *util.Range) {
+		iter := cache.tlfDb.NewIterator(rng, nil)
+		defer iter.Release()
+		for len(blockIDs) < numElements && iter.Next() {
+			key := iter.Key()
 
-	for i := 0; i < numElements; i++ {
-		if !iter.Next() {
-			break
+			blockIDBytes := key[len(tlfBytes):]
+			blockID, err := kbfsblock.IDFromBytes(blockIDBytes)
+			if err != nil {
+				cache.log.CWarningf(ctx, "Error decoding block ID %x",
+					blockIDBytes)
+				continue
+			}
+			lru, err := cache.getLRULocked(blockID)
+			if err != nil {
+				cache.log.CWarningf(ctx, "Error decoding LRU time for block %s",
+					blockID)
+				continue
+			}
+			blockIDs = append(blockIDs, lruEntry{blockID, lru})
 		}
-		key := iter.Key()
+	}
 
-		blockIDBytes := key[len(tlfBytes):]
-		if err != nil {
-			cache.log.CWarningf(ctx, "Error decoding block ID %x", blockIDBytes)
-			continue
+	collect(&util.Range{
+		Start: pivot,
+		Limit: append(append([]byte(nil), tlfBytes...), cache.maxBlockID...),
+	})
+	if len(blockIDs) < numElements {
+		// The pivot landed too close to the end of the TLF's blocks,
+		// so wrap around to the start of its range.
+		collect(&util.Range{
+			Start: tlfBytes,
+			Limit: pivot,
+		})
+	}
+
+	return cache.evictSomeBlocks(ctx, numBlocks, blockIDs)
+}
+
+// tlfPriority returns the priority class of the given TLF.  Synced
+// TLFs are always in the synced class; otherwise a configured class
+// takes precedence over the favorite status of the TLF.
+func (cache *DiskBlockCacheLocal) tlfPriority(
+	tlfID tlf.ID) DiskBlockCachePriority {
+	if cache.config.IsSyncedTlf(tlfID) ||
+		len(cache.config.GetTlfSyncedPaths(tlfID)) > 0 {
+		return DiskBlockCachePrioritySynced
+	}
+	priority := cache.config.GetDiskBlockCacheTlfSettings(tlfID).Priority
+	if priority != DiskBlockCachePriorityDefault {
+		return priority
+	}
+	if cache.config.IsFavoriteTlf(tlfID) {
+		return DiskBlockCachePriorityFavorite
+	}
+	return DiskBlockCachePriorityOther
+}
+
+// makeRoomInTlfLocked makes sure that adding a block of encodedLen
+// bytes for the given TLF doesn't take it over its configured byte
+// cap.  In the working set cache, it evicts blocks of that same TLF
+// to make room, so that a TLF over its cap can't push out the blocks
+// of other TLFs.
+func (cache *DiskBlockCacheLocal) makeRoomInTlfLocked(ctx context.Context,
+	tlfID tlf.ID, blockID kbfsblock.ID, encodedLen int64) error {
+	maxBytes := cache.config.GetDiskBlockCacheTlfSettings(tlfID).MaxBytes
+	if maxBytes == 0 {
+		return nil
+	}
+	if uint64(encodedLen) > maxBytes {
+		return cachePutCacheFullError{blockID}
+	}
+	for i := 0; cache.tlfSizes[tlfID]+uint64(encodedLen) > maxBytes; i++ {
+		if cache.cacheType == syncCacheLimitTrackerType ||
+			i >= maxEvictionsPerPut {
+			return cachePutCacheFullError{blockID}
 		}
-		blockID, err := kbfsblock.IDFromBytes(blockIDBytes)
-		lru, err := cache.getLRULocked(blockID)
+		cache.log.CDebugf(ctx, "TLF %s is at its cap of %d bytes", tlfID,
+			maxBytes)
+		numRemoved, sizeRemoved, err := cache.evictFromTLFLocked(
+			ctx, tlfID, defaultNumBlocksToEvict)
 		if err != nil {
-			cache.log.CWarningf(ctx, "Error decoding LRU time for block %s",
-				blockID)
-			continue
+			return err
+		}
+		if numRemoved == 0 {
+			return cachePutCacheFullError{blockID}
 		}
-		blockIDs = append(blockIDs, lruEntry{blockID, lru})
+		cache.evictCountMeter.Mark(int64(numRemoved))
+		cache.evictSizeMeter.Mark(sizeRemoved)
 	}
-
-	return cache.evictSomeBlocks(ctx, numBlocks, blockIDs)
+	return nil
 }
 
-// evictLocked evicts a number of blocks from the cache.  We choose a pivot
-// variable b randomly. Then begin an iterator into cache.metaDb.Range(b,
-// MaxBlockID) and iterate from there to get numBlocks *
-// evictionConsiderationFactor block IDs.  We sort the resulting blocks by
-// value (LRU time) and pick the minimum numBlocks. We then call cache.Delete()
-// on that list of block IDs.
+// evictLocked evicts a number of blocks from the cache.  We order
+// the TLFs with blocks in the cache by priority class first, lowest
+// class first, and then by the last time any of their blocks were
+// used, least recently used first.  Then we evict blocks from each
+// TLF in that order, picking the blocks with evictFromTLFLocked,
+// until we've evicted enough.  We keep evicting from the TLFs of a
+// class as long as that makes progress, before moving on to the
+// next higher class.
 func (cache *DiskBlockCacheLocal) evictLocked(ctx context.Context,
 	numBlocks int) (numRemoved int, sizeRemoved int64, err error) {
 	defer func() {
@@ -882,39 +1007,62 @@ func (cache *DiskBlockCacheLocal) evictLocked(ctx context.Context,
 			cache.evictSizeMeter.Mark(sizeRemoved)
 		}
 	}()
-	numElements := numBlocks * evictionConsiderationFactor
-	blockID, err := cache.getRandomBlockID(numElements, cache.numBlocks)
-	if err != nil {
-		return 0, 0, err
+	type evictionCandidate struct {
+		tlfID    tlf.ID
+		priority DiskBlockCachePriority
+		lastUse  time.Time
 	}
-	rng := &util.Range{Start: blockID.Bytes(), Limit: cache.maxBlockID}
-	iter := cache.metaDb.NewIterator(rng, nil)
-	defer iter.Release()
-
-	blockIDs := make(blockIDsByTime, 0, numElements)
-
-	for i := 0; i < numElements; i++ {
-		if !iter.Next() {
-			break
-		}
-		key := iter.Key()
-
-		if err != nil {
-			cache.log.CWarningf(ctx, "Error decoding block ID %x", key)
+	candidates := make([]evictionCandidate, 0, len(cache.tlfCounts))
+	for tlfID, count := range cache.tlfCounts {
+		if count <= 0 {
 			continue
 		}
-		blockID, err := kbfsblock.IDFromBytes(key)
-		metadata := DiskBlockCacheMetadata{}
-		err = cache.config.Codec().Decode(iter.Value(), &metadata)
-		if err != nil {
-			cache.log.CWarningf(ctx, "Error decoding metadata for block %s",
-				blockID)
-			continue
-		}
-		blockIDs = append(blockIDs, lruEntry{blockID, metadata.LRUTime})
+		candidates = append(candidates, evictionCandidate{
+			tlfID, cache.tlfPriority(tlfID), cache.tlfLastUse[tlfID]})
 	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].priority != candidates[j].priority {
+			return candidates[i].priority < candidates[j].priority
+		}
+		return candidates[i].lastUse.Before(candidates[j].lastUse)
+	})
+	for start := 0; start < len(candidates) && numRemoved < numBlocks; {
+		// Find the candidates in the same priority class.
+		end := start + 1
+		for end < len(candidates) &&
+			candidates[end].priority == candidates[start].priority {
+			end++
+		}
+		class := candidates[start:end]
+		start = end
 
-	return cache.evictSomeBlocks(ctx, numBlocks, blockIDs)
+		for numRemoved < numBlocks {
+			classRemoved := 0
+			for _, c := range class {
+				if numRemoved >= numBlocks {
+					break
+				}
+				if cache.tlfCounts[c.tlfID] <= 0 {
+					continue
+				}
+				cache.log.CDebugf(ctx, "Evicting from TLF %s (priority=%s, "+
+					"lastUse=%s)", c.tlfID, c.priority, c.lastUse)
+				n, size, err := cache.evictFromTLFLocked(
+					ctx, c.tlfID, numBlocks-numRemoved)
+				if err != nil {
+					return numRemoved, sizeRemoved, err
+				}
+				numRemoved += n
+				sizeRemoved += size
+				classRemoved += n
+			}
+			if classRemoved == 0 {
+				// Nothing more to evict from this class.
+				break
+			}
+		}
+	}
+	return numRemoved, sizeRemoved, nil
 }
 
 // Status implements the DiskBlockCache interface for DiskBlockCacheStandard.
@@ -939,6 +1087,19 @@ func (cache *DiskBlockCacheLocal) Status(
 	}
 	cache.lock.RLock()
 	defer cache.lock.RUnlock()
+	tlfStatuses := make(map[string]DiskBlockCacheTlfStatus)
+	for tlfID, count := range cache.tlfCounts {
+		if count <= 0 {
+			continue
+		}
+		tlfStatuses[tlfID.String()] = DiskBlockCacheTlfStatus{
+			Priority:   cache.tlfPriority(tlfID).String(),
+			NumBlocks:  uint64(count),
+			BlockBytes: cache.tlfSizes[tlfID],
+			ByteLimit: cache.config.GetDiskBlockCacheTlfSettings(
+				tlfID).MaxBytes,
+		}
+	}
 	// The disk cache status doesn't depend on the chargedTo ID, and
 	// we don't have easy access to the UID here, so pass in a dummy.
 	return map[string]DiskBlockCacheStatus{
@@ -954,6 +1115,7 @@ func (cache *DiskBlockCacheLocal) Status(
 			SizeEvicted:     rateMeterToStatus(cache.evictSizeMeter),
 			NumDeleted:      rateMeterToStatus(cache.deleteCountMeter),
 			SizeDeleted:     rateMeterToStatus(cache.deleteSizeMeter),
+			TLFs:            tlfStatuses,
 		},
 	}
 }
@@ -7,25 +7,45 @@ package libkbfs
 import (
 	"context"
 	"net"
+	"sync"
+	"time"
 
+	"github.com/keybase/backoff"
 	"github.com/keybase/client/go/libkb"
 	"github.com/keybase/go-framed-msgpack-rpc/rpc"
 	"github.com/keybase/kbfs/kbfsblock"
 	"github.com/keybase/kbfs/kbfscrypto"
 	kbgitkbfs "github.com/keybase/kbfs/protocol/kbgitkbfs1"
 	"github.com/keybase/kbfs/tlf"
+	"github.com/pkg/errors"
 )
 
 type diskBlockCacheRemoteConfig interface {
 	logMaker
 }
 
+// diskBlockCacheRemoteMaxRedialInterval is the longest a
+// DiskBlockCacheRemote connected to a DiskBlockCacheServer waits
+// between attempts to redial it.
+const diskBlockCacheRemoteMaxRedialInterval = 10 * time.Second
+
 // DiskBlockCacheRemote implements a client to access a remote
 // DiskBlockCacheService. It implements the DiskBlockCache interface.
 type DiskBlockCacheRemote struct {
+	log traceLogger
+
+	// Protects everything below.
+	lock sync.Mutex
+	// redial is set for clients of a DiskBlockCacheServer, and
+	// connects to it again after the connection is lost.
+	redial func() (net.Conn, rpc.Transporter, error)
 	conn   net.Conn
+	xp     rpc.Transporter
 	client kbgitkbfs.DiskBlockCacheClient
-	log    traceLogger
+	// While disconnected, calls fail right away until nextRedial,
+	// which backs off exponentially with each failed redial.
+	redialBackoff backoff.BackOff
+	nextRedial    time.Time
 }
 
 var _ DiskBlockCache = (*DiskBlockCacheRemote)(nil)
@@ -37,15 +57,84 @@ func NewDiskBlockCacheRemote(kbCtx Context, config diskBlockCacheRemoteConfig) (
 	if err != nil {
 		return nil, err
 	}
-	cli := rpc.NewClient(xp, KBFSErrorUnwrapper{},
-		libkb.LogTagsFromContext)
-	client := kbgitkbfs.DiskBlockCacheClient{Cli: cli}
+	dbcr := newDiskBlockCacheRemote(config)
+	dbcr.setConnLocked(conn, xp)
+	return dbcr, nil
+}
+
+// NewDiskBlockCacheRemoteFromSocket creates a new remote disk cache
+// client that connects to a DiskBlockCacheServer listening on the
+// given unix socket, rather than to the local KBFS instance.  If the
+// connection is lost, the client redials the socket, backing off
+// exponentially while the server is unreachable; in the meantime,
+// every call fails right away, as if the cache were empty.
+func NewDiskBlockCacheRemoteFromSocket(socketPath string,
+	logFactory rpc.LogFactory, config diskBlockCacheRemoteConfig) (
+	*DiskBlockCacheRemote, error) {
+	dbcr := newDiskBlockCacheRemote(config)
+	dbcr.redial = func() (net.Conn, rpc.Transporter, error) {
+		conn, err := net.Dial("unix", socketPath)
+		if err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+		return conn, rpc.NewTransport(conn, logFactory, libkb.WrapError), nil
+	}
+	conn, xp, err := dbcr.redial()
+	if err != nil {
+		return nil, err
+	}
+	dbcr.setConnLocked(conn, xp)
+	return dbcr, nil
+}
 
+func newDiskBlockCacheRemote(
+	config diskBlockCacheRemoteConfig) *DiskBlockCacheRemote {
+	redialBackoff := backoff.NewExponentialBackOff()
+	redialBackoff.MaxInterval = diskBlockCacheRemoteMaxRedialInterval
+	redialBackoff.MaxElapsedTime = 0
 	return &DiskBlockCacheRemote{
-		conn:   conn,
-		client: client,
-		log:    traceLogger{config.MakeLogger("DBR")},
-	}, nil
+		log:           traceLogger{config.MakeLogger("DBR")},
+		redialBackoff: redialBackoff,
+	}
+}
+
+func (dbcr *DiskBlockCacheRemote) setConnLocked(
+	conn net.Conn, xp rpc.Transporter) {
+	dbcr.conn = conn
+	dbcr.xp = xp
+	dbcr.client = kbgitkbfs.DiskBlockCacheClient{Cli: rpc.NewClient(
+		xp, KBFSErrorUnwrapper{}, libkb.LogTagsFromContext)}
+}
+
+// getClient returns the client for the current connection, redialing
+// the server first if the connection was lost and it's time to try
+// again.
+func (dbcr *DiskBlockCacheRemote) getClient() (
+	kbgitkbfs.DiskBlockCacheClient, error) {
+	dbcr.lock.Lock()
+	defer dbcr.lock.Unlock()
+	if dbcr.redial == nil || dbcr.xp.IsConnected() {
+		return dbcr.client, nil
+	}
+
+	now := time.Now()
+	if now.Before(dbcr.nextRedial) {
+		return kbgitkbfs.DiskBlockCacheClient{},
+			DiskBlockCacheError{"Disconnected from the disk block cache"}
+	}
+	conn, xp, err := dbcr.redial()
+	if err != nil {
+		wait := dbcr.redialBackoff.NextBackOff()
+		dbcr.nextRedial = now.Add(wait)
+		dbcr.log.Warning("Couldn't redial the disk block cache: %+v; "+
+			"retrying in %s", err, wait)
+		return kbgitkbfs.DiskBlockCacheClient{}, err
+	}
+	dbcr.conn.Close()
+	dbcr.setConnLocked(conn, xp)
+	dbcr.redialBackoff.Reset()
+	dbcr.nextRedial = time.Time{}
+	return dbcr.client, nil
 }
 
 // Get implements the DiskBlockCache interface for DiskBlockCacheRemote.
@@ -58,7 +147,11 @@ func (dbcr *DiskBlockCacheRemote) Get(ctx context.Context, tlfID tlf.ID,
 		dbcr.log.LazyTrace(ctx, "DiskBlockCacheRemote: Get %s done (err=%+v)", blockID, err)
 	}()
 
-	res, err := dbcr.client.GetBlock(ctx, kbgitkbfs.GetBlockArg{
+	client, err := dbcr.getClient()
+	if err != nil {
+		return nil, kbfscrypto.BlockCryptKeyServerHalf{}, NoPrefetch, err
+	}
+	res, err := client.GetBlock(ctx, kbgitkbfs.GetBlockArg{
 		TlfID:   tlfID.Bytes(),
 		BlockID: blockID.Bytes(),
 	})
@@ -84,7 +177,11 @@ func (dbcr *DiskBlockCacheRemote) Put(ctx context.Context, tlfID tlf.ID,
 		dbcr.log.LazyTrace(ctx, "DiskBlockCacheRemote: Put %s done (err=%+v)", blockID, err)
 	}()
 
-	return dbcr.client.PutBlock(ctx, kbgitkbfs.PutBlockArg{
+	client, err := dbcr.getClient()
+	if err != nil {
+		return err
+	}
+	return client.PutBlock(ctx, kbgitkbfs.PutBlockArg{
 		TlfID:      tlfID.Bytes(),
 		BlockID:    blockID.Bytes(),
 		Buf:        buf,
@@ -106,7 +203,11 @@ func (dbcr *DiskBlockCacheRemote) Delete(ctx context.Context,
 	for _, b := range blockIDs {
 		blocks = append(blocks, b.Bytes())
 	}
-	res, err := dbcr.client.DeleteBlocks(ctx, blocks)
+	client, err := dbcr.getClient()
+	if err != nil {
+		return 0, 0, err
+	}
+	res, err := client.DeleteBlocks(ctx, blocks)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -117,7 +218,11 @@ func (dbcr *DiskBlockCacheRemote) Delete(ctx context.Context,
 // DiskBlockCacheRemote.
 func (dbcr *DiskBlockCacheRemote) UpdateMetadata(ctx context.Context,
 	blockID kbfsblock.ID, prefetchStatus PrefetchStatus) error {
-	return dbcr.client.UpdateBlockMetadata(ctx,
+	client, err := dbcr.getClient()
+	if err != nil {
+		return err
+	}
+	return client.UpdateBlockMetadata(ctx,
 		kbgitkbfs.UpdateBlockMetadataArg{
 			BlockID:        blockID.Bytes(),
 			PrefetchStatus: prefetchStatus.ToProtocol(),
@@ -126,12 +231,15 @@ func (dbcr *DiskBlockCacheRemote) UpdateMetadata(ctx context.Context,
 
 // Status implements the DiskBlockCache interface for DiskBlockCacheRemote.
 func (dbcr *DiskBlockCacheRemote) Status(ctx context.Context) map[string]DiskBlockCacheStatus {
-	// We don't return a status because it isn't needed in the contexts
-	// this block cache is used.
-	panic("Status() not implemented in DiskBlockCacheRemote")
+	// The status of the cache belongs to the process serving it, and
+	// isn't exposed over RPC.
+	return nil
 }
 
 // Shutdown implements the DiskBlockCache interface for DiskBlockCacheRemote.
 func (dbcr *DiskBlockCacheRemote) Shutdown(ctx context.Context) {
+	dbcr.lock.Lock()
+	defer dbcr.lock.Unlock()
+	dbcr.redial = nil
 	dbcr.conn.Close()
 }
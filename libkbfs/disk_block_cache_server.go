@@ -0,0 +1,184 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libkbfs
+
+import (
+	"io"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/keybase/client/go/libkb"
+	"github.com/keybase/client/go/logger"
+	"github.com/keybase/go-framed-msgpack-rpc/rpc"
+	kbgitkbfs "github.com/keybase/kbfs/protocol/kbgitkbfs1"
+	"github.com/pkg/errors"
+)
+
+// DefaultDiskBlockCacheSocketMode is the default file mode of the
+// socket of a DiskBlockCacheServer, which only lets processes of the
+// same user connect to it.
+const DefaultDiskBlockCacheSocketMode os.FileMode = 0600
+
+type diskBlockCacheServerConfig interface {
+	diskBlockCacheGetter
+	logMaker
+}
+
+// DiskBlockCacheServer serves the disk block cache of this process
+// over a local unix socket, so that several KBFS processes on the
+// same host (e.g., kbfsfuse, kbpagesd and git-remote-keybase) can
+// share a single warm cache.  Clients connect to it with
+// NewDiskBlockCacheRemoteFromSocket.
+//
+// There is no authentication beyond the file mode of the socket (and
+// of the directories containing it): any process that can open the
+// socket can read the cache.  Blocks written through the socket are
+// only accepted if their IDs match their contents, so that a client
+// can't plant bad data under the ID of a real block.  The server
+// half of a block's key can't be checked that way, though, so if the
+// socket is open to other users, clients may only change the cache
+// if the server was told to trust them.
+type DiskBlockCacheServer struct {
+	config     diskBlockCacheServerConfig
+	log        logger.Logger
+	logFactory rpc.LogFactory
+	socketPath string
+	listener   net.Listener
+	readOnly   bool
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+}
+
+// listenOnDiskBlockCacheSocket binds to the unix socket at
+// `socketPath`, with the given file mode.  A stale socket left behind
+// by a previous server is removed first, but a live one is left
+// alone.
+func listenOnDiskBlockCacheSocket(
+	socketPath string, mode os.FileMode) (net.Listener, error) {
+	fi, err := os.Lstat(socketPath)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, errors.WithStack(err)
+	case fi.Mode()&os.ModeSocket == 0:
+		return nil, errors.Errorf("%s exists and is not a socket", socketPath)
+	default:
+		conn, err := net.Dial("unix", socketPath)
+		if err == nil {
+			conn.Close()
+			return nil, errors.Errorf(
+				"A disk block cache is already being served on %s",
+				socketPath)
+		}
+		err = os.Remove(socketPath)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	return listenUnixWithMode(socketPath, mode)
+}
+
+// NewDiskBlockCacheServer starts serving the disk block cache of
+// `config` on the unix socket at `socketPath`, which is created with
+// the given file mode.  If the mode lets other users connect, the
+// cache is read-only for clients unless `trustClients` is set.
+func NewDiskBlockCacheServer(config diskBlockCacheServerConfig,
+	logFactory rpc.LogFactory, socketPath string, mode os.FileMode,
+	trustClients bool) (*DiskBlockCacheServer, error) {
+	if config.DiskBlockCache() == nil {
+		return nil, errors.New("No disk block cache to serve")
+	}
+	l, err := listenOnDiskBlockCacheSocket(socketPath, mode)
+	if err != nil {
+		return nil, err
+	}
+	s := &DiskBlockCacheServer{
+		config:     config,
+		log:        config.MakeLogger("DBS"),
+		logFactory: logFactory,
+		socketPath: socketPath,
+		listener:   l,
+		readOnly:   !trustClients && mode.Perm()&0077 != 0,
+		stopCh:     make(chan struct{}),
+		doneCh:     make(chan struct{}),
+	}
+	s.log.Debug("Serving the disk block cache on %s (readOnly=%t)",
+		socketPath, s.readOnly)
+	go s.listenLoop()
+	return s, nil
+}
+
+// SocketPath returns the path of the socket this server listens on.
+func (s *DiskBlockCacheServer) SocketPath() string {
+	return s.socketPath
+}
+
+// handle serves the disk block cache on an established connection.
+func (s *DiskBlockCacheServer) handle(c net.Conn) {
+	xp := rpc.NewTransport(c, s.logFactory, libkb.WrapError)
+	server := rpc.NewServer(xp, libkb.WrapError)
+	err := server.Register(
+		kbgitkbfs.DiskBlockCacheProtocol(&DiskBlockCacheService{
+			config:         s.config,
+			verifyBlockIDs: true,
+			readOnly:       s.readOnly,
+		}))
+	if err != nil {
+		s.log.Warning("Register error: %s", err)
+		c.Close()
+		return
+	}
+
+	// Run the server, then wait for it or this server to finish.
+	serverCh := server.Run()
+	go func() {
+		select {
+		case <-s.stopCh:
+		case <-serverCh:
+		}
+		// Close is idempotent, so always close when we're done.
+		c.Close()
+	}()
+	<-serverCh
+
+	// err is always non-nil.
+	err = server.Err()
+	if err != io.EOF && !libkb.IsSocketClosedError(err) {
+		s.log.Warning("Run error: %s", err)
+	}
+}
+
+// listenLoop accepts connections until the server is shut down.
+func (s *DiskBlockCacheServer) listenLoop() {
+	defer close(s.doneCh)
+	go func() {
+		<-s.stopCh
+		s.listener.Close()
+	}()
+	for {
+		c, err := s.listener.Accept()
+		if err != nil {
+			if libkb.IsSocketClosedError(err) {
+				err = nil
+			}
+			s.log.Debug("listenLoop() done, error: %+v", err)
+			return
+		}
+		go s.handle(c)
+	}
+}
+
+// Shutdown stops serving the disk block cache, and closes all the
+// open connections.  It doesn't shut down the cache itself.
+func (s *DiskBlockCacheServer) Shutdown() {
+	s.stopOnce.Do(func() {
+		close(s.stopCh)
+		<-s.doneCh
+	})
+}
@@ -0,0 +1,213 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libkbfs
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/keybase/kbfs/ioutil"
+	"github.com/keybase/kbfs/kbfsblock"
+	"github.com/keybase/kbfs/tlf"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+type testDiskBlockCacheServerConfig struct {
+	*testDiskBlockCacheGetter
+	logMaker
+}
+
+func TestDiskBlockCacheServer(t *testing.T) {
+	t.Parallel()
+	cache, config := initDiskBlockCacheTest(t)
+	defer shutdownDiskBlockCacheTest(cache)
+
+	// Keep the socket path short, since unix socket paths are limited
+	// to around 100 bytes.
+	tempdir, err := ioutil.TempDir("", "dbcs")
+	require.NoError(t, err)
+	defer func() {
+		err := ioutil.RemoveAll(tempdir)
+		require.NoError(t, err)
+	}()
+	socketPath := filepath.Join(tempdir, "cache.sock")
+
+	server, err := NewDiskBlockCacheServer(
+		testDiskBlockCacheServerConfig{
+			newTestDiskBlockCacheGetter(t, cache), config},
+		newTestRPCLogFactory(t), socketPath,
+		DefaultDiskBlockCacheSocketMode, false)
+	require.NoError(t, err)
+	defer server.Shutdown()
+
+	t.Log("The socket only lets the same user connect.")
+	fi, err := os.Stat(socketPath)
+	require.NoError(t, err)
+	require.Equal(t, DefaultDiskBlockCacheSocketMode, fi.Mode().Perm())
+
+	t.Log("A second server can't take over a live socket.")
+	_, err = NewDiskBlockCacheServer(
+		testDiskBlockCacheServerConfig{
+			newTestDiskBlockCacheGetter(t, cache), config},
+		newTestRPCLogFactory(t), socketPath,
+		DefaultDiskBlockCacheSocketMode, false)
+	require.Error(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	client1, err := NewDiskBlockCacheRemoteFromSocket(
+		socketPath, newTestRPCLogFactory(t), config)
+	require.NoError(t, err)
+	defer client1.Shutdown(ctx)
+	client2, err := NewDiskBlockCacheRemoteFromSocket(
+		socketPath, newTestRPCLogFactory(t), config)
+	require.NoError(t, err)
+	defer client2.Shutdown(ctx)
+
+	t.Log("A block can't be put under an ID that doesn't match its " +
+		"contents.")
+	tlfID := tlf.FakeID(1, tlf.Private)
+	ptr, _, blockEncoded, serverHalf := setupBlockForDiskCache(t, config)
+	err = client1.Put(ctx, tlfID, ptr.ID, blockEncoded, serverHalf)
+	require.Error(t, err)
+
+	t.Log("A block put by one client can be read by the other, and is " +
+		"in the served cache.")
+	ptr.ID, err = kbfsblock.MakePermanentID(blockEncoded)
+	require.NoError(t, err)
+	err = client1.Put(ctx, tlfID, ptr.ID, blockEncoded, serverHalf)
+	require.NoError(t, err)
+	buf, gotServerHalf, _, err := client2.Get(ctx, tlfID, ptr.ID)
+	require.NoError(t, err)
+	require.Equal(t, blockEncoded, buf)
+	require.Equal(t, serverHalf, gotServerHalf)
+	buf, _, _, err = cache.Get(ctx, tlfID, ptr.ID)
+	require.NoError(t, err)
+	require.Equal(t, blockEncoded, buf)
+
+	t.Log("Metadata updates and deletes go through too.")
+	err = client2.UpdateMetadata(ctx, ptr.ID, TriggeredPrefetch)
+	require.NoError(t, err)
+	_, _, prefetchStatus, err := client1.Get(ctx, tlfID, ptr.ID)
+	require.NoError(t, err)
+	require.Equal(t, TriggeredPrefetch, prefetchStatus)
+	numRemoved, _, err := client1.Delete(ctx, []kbfsblock.ID{ptr.ID})
+	require.NoError(t, err)
+	require.Equal(t, 1, numRemoved)
+	_, _, _, err = client2.Get(ctx, tlfID, ptr.ID)
+	require.Error(t, err)
+
+	t.Log("After shutdown, the socket is gone and nobody can connect.")
+	server.Shutdown()
+	_, err = os.Stat(socketPath)
+	require.True(t, os.IsNotExist(err))
+	_, err = net.Dial("unix", socketPath)
+	require.Error(t, err)
+	_, _, _, err = client1.Get(ctx, tlfID, ptr.ID)
+	require.Error(t, err)
+
+	t.Log("Clients reconnect once the server is back.")
+	server, err = NewDiskBlockCacheServer(
+		testDiskBlockCacheServerConfig{
+			newTestDiskBlockCacheGetter(t, cache), config},
+		newTestRPCLogFactory(t), socketPath,
+		DefaultDiskBlockCacheSocketMode, false)
+	require.NoError(t, err)
+	defer server.Shutdown()
+	for {
+		err = client1.Put(ctx, tlfID, ptr.ID, blockEncoded, serverHalf)
+		if err == nil {
+			break
+		}
+		select {
+		case <-time.After(10 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatalf("Client never reconnected: %+v", err)
+		}
+	}
+	buf, _, _, err = client1.Get(ctx, tlfID, ptr.ID)
+	require.NoError(t, err)
+	require.Equal(t, blockEncoded, buf)
+}
+
+func TestDiskBlockCacheServerSharedSocket(t *testing.T) {
+	t.Parallel()
+	cache, config := initDiskBlockCacheTest(t)
+	defer shutdownDiskBlockCacheTest(cache)
+
+	tempdir, err := ioutil.TempDir("", "dbcs")
+	require.NoError(t, err)
+	defer func() {
+		err := ioutil.RemoveAll(tempdir)
+		require.NoError(t, err)
+	}()
+	socketPath := filepath.Join(tempdir, "cache.sock")
+
+	t.Log("Clients of a socket shared with the group can only read " +
+		"the cache.")
+	server, err := NewDiskBlockCacheServer(
+		testDiskBlockCacheServerConfig{
+			newTestDiskBlockCacheGetter(t, cache), config},
+		newTestRPCLogFactory(t), socketPath, 0660, false)
+	require.NoError(t, err)
+	defer server.Shutdown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	client, err := NewDiskBlockCacheRemoteFromSocket(
+		socketPath, newTestRPCLogFactory(t), config)
+	require.NoError(t, err)
+	defer client.Shutdown(ctx)
+
+	tlfID := tlf.FakeID(1, tlf.Private)
+	ptr, _, blockEncoded, serverHalf := setupBlockForDiskCache(t, config)
+	ptr.ID, err = kbfsblock.MakePermanentID(blockEncoded)
+	require.NoError(t, err)
+	err = client.Put(ctx, tlfID, ptr.ID, blockEncoded, serverHalf)
+	require.Error(t, err)
+	_, _, _, err = cache.Get(ctx, tlfID, ptr.ID)
+	require.Error(t, err)
+
+	err = cache.Put(ctx, tlfID, ptr.ID, blockEncoded, serverHalf)
+	require.NoError(t, err)
+	buf, gotServerHalf, _, err := client.Get(ctx, tlfID, ptr.ID)
+	require.NoError(t, err)
+	require.Equal(t, blockEncoded, buf)
+	require.Equal(t, serverHalf, gotServerHalf)
+	err = client.UpdateMetadata(ctx, ptr.ID, TriggeredPrefetch)
+	require.Error(t, err)
+	_, _, err = client.Delete(ctx, []kbfsblock.ID{ptr.ID})
+	require.Error(t, err)
+	_, _, _, err = cache.Get(ctx, tlfID, ptr.ID)
+	require.NoError(t, err)
+
+	t.Log("Trusted clients of a shared socket can write to the cache.")
+	server.Shutdown()
+	server, err = NewDiskBlockCacheServer(
+		testDiskBlockCacheServerConfig{
+			newTestDiskBlockCacheGetter(t, cache), config},
+		newTestRPCLogFactory(t), socketPath, 0660, true)
+	require.NoError(t, err)
+	defer server.Shutdown()
+	for {
+		_, _, err = client.Delete(ctx, []kbfsblock.ID{ptr.ID})
+		if err == nil {
+			break
+		}
+		select {
+		case <-time.After(10 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatalf("Client never reconnected: %+v", err)
+		}
+	}
+	_, _, _, err = cache.Get(ctx, tlfID, ptr.ID)
+	require.Error(t, err)
+	err = client.Put(ctx, tlfID, ptr.ID, blockEncoded, serverHalf)
+	require.NoError(t, err)
+}
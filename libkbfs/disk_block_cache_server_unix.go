@@ -0,0 +1,37 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+// +build !windows
+
+package libkbfs
+
+import (
+	"net"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// umaskLock serializes the umask changes made by listenUnixWithMode.
+var umaskLock sync.Mutex
+
+// listenUnixWithMode listens on a new unix socket at `socketPath`,
+// which is created with the given file mode.  The umask is set for
+// the duration of the bind, so that the socket never exists with a
+// looser mode.  Since the umask is process-wide, files created
+// concurrently by other goroutines get the same restricted mode.
+func listenUnixWithMode(socketPath string, mode os.FileMode) (
+	net.Listener, error) {
+	umaskLock.Lock()
+	defer umaskLock.Unlock()
+	oldMask := unix.Umask(int(^mode & os.ModePerm))
+	defer unix.Umask(oldMask)
+	l, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return l, nil
+}
@@ -0,0 +1,23 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libkbfs
+
+import (
+	"net"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// listenUnixWithMode listens on a new unix socket at `socketPath`.
+// Windows has no file modes for sockets, so `mode` is ignored.
+func listenUnixWithMode(socketPath string, mode os.FileMode) (
+	net.Listener, error) {
+	l, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return l, nil
+}
@@ -21,6 +21,12 @@ type diskBlockCacheServiceConfig interface {
 // instance's disk cache.
 type DiskBlockCacheService struct {
 	config diskBlockCacheServiceConfig
+	// verifyBlockIDs makes PutBlock reject blocks whose IDs don't
+	// match their contents.
+	verifyBlockIDs bool
+	// readOnly makes all the calls that change the cache fail, for
+	// clients that aren't trusted to write to it.
+	readOnly bool
 }
 
 var _ kbgitkbfs.DiskBlockCacheInterface = (*DiskBlockCacheService)(nil)
@@ -65,10 +71,20 @@ func (cache *DiskBlockCacheService) GetBlock(ctx context.Context,
 	}, nil
 }
 
+func (cache *DiskBlockCacheService) checkWritable() error {
+	if cache.readOnly {
+		return DiskBlockCacheError{"Disk cache is read-only"}
+	}
+	return nil
+}
+
 // PutBlock implements the DiskBlockCacheInterface interface for
 // DiskBlockCacheService.
 func (cache *DiskBlockCacheService) PutBlock(ctx context.Context,
 	arg kbgitkbfs.PutBlockArg) error {
+	if err := cache.checkWritable(); err != nil {
+		return err
+	}
 	dbc := cache.config.DiskBlockCache()
 	if dbc == nil {
 		return DiskBlockCacheError{"Disk cache is nil"}
@@ -83,6 +99,12 @@ func (cache *DiskBlockCacheService) PutBlock(ctx context.Context,
 	if err != nil {
 		return newDiskBlockCacheError(err)
 	}
+	if cache.verifyBlockIDs {
+		err = kbfsblock.VerifyID(arg.Buf, blockID)
+		if err != nil {
+			return newDiskBlockCacheError(err)
+		}
+	}
 	serverHalf := kbfscrypto.BlockCryptKeyServerHalf{}
 	err = serverHalf.UnmarshalBinary(arg.ServerHalf)
 	if err != nil {
@@ -99,6 +121,9 @@ func (cache *DiskBlockCacheService) PutBlock(ctx context.Context,
 // DiskBlockCacheService.
 func (cache *DiskBlockCacheService) DeleteBlocks(ctx context.Context,
 	blockIDs [][]byte) (kbgitkbfs.DeleteBlocksRes, error) {
+	if err := cache.checkWritable(); err != nil {
+		return kbgitkbfs.DeleteBlocksRes{}, err
+	}
 	dbc := cache.config.DiskBlockCache()
 	if dbc == nil {
 		return kbgitkbfs.DeleteBlocksRes{},
@@ -127,6 +152,9 @@ func (cache *DiskBlockCacheService) DeleteBlocks(ctx context.Context,
 // DiskBlockCacheService.
 func (cache *DiskBlockCacheService) UpdateBlockMetadata(ctx context.Context,
 	arg kbgitkbfs.UpdateBlockMetadataArg) error {
+	if err := cache.checkWritable(); err != nil {
+		return err
+	}
 	dbc := cache.config.DiskBlockCache()
 	if dbc == nil {
 		return DiskBlockCacheError{"Disk cache is nil"}
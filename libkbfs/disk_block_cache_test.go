@@ -29,6 +29,7 @@ type testDiskBlockCacheConfig struct {
 	*testClockGetter
 	limiter DiskLimiter
 	syncedTlfGetterSetter
+	*testDiskBlockCacheTlfSettingsGetterSetter
 	initModeGetter
 }
 
@@ -39,6 +40,7 @@ func newTestDiskBlockCacheConfig(t *testing.T) *testDiskBlockCacheConfig {
 		newTestClockGetter(),
 		nil,
 		newTestSyncedTlfGetterSetter(),
+		newTestDiskBlockCacheTlfSettingsGetterSetter(),
 		testInitModeGetter{InitDefault},
 	}
 }
@@ -235,6 +237,11 @@ func TestDiskBlockCacheDelete(t *testing.T) {
 	require.EqualError(t, err, errors.ErrNotFound.Error())
 	_, err = cache.GetMetadata(ctx, block2Ptr.ID)
 	require.EqualError(t, err, errors.ErrNotFound.Error())
+	t.Log("Verify that the TLF's last use is forgotten with its last block.")
+	require.Contains(t, cache.workingSetCache.tlfLastUse, tlf1)
+	_, _, err = cache.Delete(ctx, []kbfsblock.ID{block3Ptr.ID})
+	require.NoError(t, err)
+	require.NotContains(t, cache.workingSetCache.tlfLastUse, tlf1)
 }
 
 func TestDiskBlockCacheEvictFromTLF(t *testing.T) {
@@ -397,6 +404,165 @@ func TestDiskBlockCacheEvictOverall(t *testing.T) {
 		"Average overall LRU delta from an eviction: %.2f", averageDifference)
 }
 
+func TestDiskBlockCacheEvictByPriority(t *testing.T) {
+	t.Parallel()
+	t.Log("Test that disk cache eviction honors TLF priority classes.")
+	cache, config := initDiskBlockCacheTest(t)
+	standardCache := cache.workingSetCache
+	defer shutdownDiskBlockCacheTest(cache)
+
+	ctx := context.Background()
+	clock := config.TestClock()
+
+	favTlf := tlf.FakeID(1, tlf.Private)
+	otherTlf := tlf.FakeID(2, tlf.Private)
+	pinnedTlf := tlf.FakeID(3, tlf.Private)
+	config.SetTlfFavoriteState(favTlf, true)
+	config.SetTlfFavoriteState(pinnedTlf, true)
+	err := config.SetDiskBlockCacheTlfSettings(pinnedTlf,
+		DiskBlockCacheTlfSettings{Priority: DiskBlockCachePriorityOther})
+	require.NoError(t, err)
+
+	t.Log("Put blocks for the favorite first, so it's the least " +
+		"recently used TLF.")
+	numBlocksPerTlf := 5
+	for _, id := range []tlf.ID{favTlf, otherTlf, pinnedTlf} {
+		for i := 0; i < numBlocksPerTlf; i++ {
+			blockPtr, _, blockEncoded, serverHalf := setupBlockForDiskCache(
+				t, config)
+			err := standardCache.Put(
+				ctx, id, blockPtr.ID, blockEncoded, serverHalf)
+			require.NoError(t, err)
+			clock.Add(time.Second)
+		}
+	}
+	status := standardCache.Status(ctx)[workingSetCacheName]
+	require.Equal(t, "favorite", status.TLFs[favTlf.String()].Priority)
+	require.Equal(t, "other", status.TLFs[otherTlf.String()].Priority)
+	require.Equal(t, "other", status.TLFs[pinnedTlf.String()].Priority)
+
+	t.Log("Evicting all the blocks of the lower class leaves the " +
+		"favorite alone, even though it was used least recently.")
+	for standardCache.tlfCounts[otherTlf] > 0 ||
+		standardCache.tlfCounts[pinnedTlf] > 0 {
+		require.Equal(t, numBlocksPerTlf, standardCache.tlfCounts[favTlf])
+		numRemoved, _, err := standardCache.evictLocked(ctx, 2)
+		require.NoError(t, err)
+		require.NotZero(t, numRemoved)
+	}
+	require.Equal(t, numBlocksPerTlf, standardCache.tlfCounts[favTlf])
+
+	t.Log("Within a class, the least recently used TLF goes first.")
+	require.Zero(t, standardCache.tlfCounts[otherTlf])
+	_, _, err = standardCache.evictLocked(ctx, numBlocksPerTlf)
+	require.NoError(t, err)
+	require.Zero(t, standardCache.tlfCounts[favTlf])
+}
+
+func TestDiskBlockCacheEvictWholeClassFirst(t *testing.T) {
+	t.Parallel()
+	t.Log("Test that disk cache eviction exhausts a lower priority class " +
+		"before touching a higher one, even when the random pivot lands " +
+		"past all the blocks of a TLF.")
+	cache, config := initDiskBlockCacheTest(t)
+	standardCache := cache.workingSetCache
+	defer shutdownDiskBlockCacheTest(cache)
+
+	ctx := context.Background()
+	clock := config.TestClock()
+
+	favTlf := tlf.FakeID(1, tlf.Private)
+	otherTlf := tlf.FakeID(2, tlf.Private)
+	config.SetTlfFavoriteState(favTlf, true)
+
+	numBlocksPerTlf := 20
+	t.Log("Put blocks for the favorite first, so it's the least " +
+		"recently used TLF.")
+	for i := 0; i < numBlocksPerTlf; i++ {
+		blockPtr, _, blockEncoded, serverHalf := setupBlockForDiskCache(
+			t, config)
+		err := standardCache.Put(
+			ctx, favTlf, blockPtr.ID, blockEncoded, serverHalf)
+		require.NoError(t, err)
+		clock.Add(time.Second)
+	}
+	t.Log("Give the other TLF's blocks IDs at the very start of the ID " +
+		"space, so most pivots land after all of them.")
+	for i := 0; i < numBlocksPerTlf; i++ {
+		_, _, blockEncoded, serverHalf := setupBlockForDiskCache(t, config)
+		err := standardCache.Put(ctx, otherTlf,
+			kbfsblock.FakeID(byte(i+1)), blockEncoded, serverHalf)
+		require.NoError(t, err)
+		clock.Add(time.Second)
+	}
+
+	t.Log("Evicting one block at a time empties the lower class before " +
+		"touching the favorite.")
+	for i := numBlocksPerTlf; i > 0; i-- {
+		require.Equal(t, i, standardCache.tlfCounts[otherTlf])
+		numRemoved, _, err := standardCache.evictLocked(ctx, 1)
+		require.NoError(t, err)
+		require.Equal(t, 1, numRemoved)
+		require.Equal(t, numBlocksPerTlf, standardCache.tlfCounts[favTlf])
+	}
+	require.Zero(t, standardCache.tlfCounts[otherTlf])
+}
+
+func TestDiskBlockCacheTlfByteCap(t *testing.T) {
+	t.Parallel()
+	t.Log("Test that a TLF can't grow past its byte cap.")
+	cache, config := initDiskBlockCacheTest(t)
+	standardCache := cache.workingSetCache
+	defer shutdownDiskBlockCacheTest(cache)
+
+	ctx := context.Background()
+	clock := config.TestClock()
+
+	cappedTlf := tlf.FakeID(1, tlf.Private)
+	otherTlf := tlf.FakeID(2, tlf.Private)
+	putBlock := func(id tlf.ID) error {
+		blockPtr, _, blockEncoded, serverHalf := setupBlockForDiskCache(
+			t, config)
+		clock.Add(time.Second)
+		return standardCache.Put(
+			ctx, id, blockPtr.ID, blockEncoded, serverHalf)
+	}
+	require.NoError(t, putBlock(otherTlf))
+	require.NoError(t, putBlock(cappedTlf))
+	blockSize := standardCache.tlfSizes[cappedTlf]
+
+	numBlocksAllowed := uint64(3)
+	err := config.SetDiskBlockCacheTlfSettings(cappedTlf,
+		DiskBlockCacheTlfSettings{MaxBytes: numBlocksAllowed * blockSize})
+	require.NoError(t, err)
+
+	t.Log("Keep putting blocks; the TLF only evicts its own blocks.")
+	for i := 0; i < 20; i++ {
+		require.NoError(t, putBlock(cappedTlf))
+		require.True(t,
+			standardCache.tlfSizes[cappedTlf] <= numBlocksAllowed*blockSize)
+	}
+	require.Equal(t, 1, standardCache.tlfCounts[otherTlf])
+	status := standardCache.Status(ctx)[workingSetCacheName]
+	require.Equal(t, numBlocksAllowed*blockSize,
+		status.TLFs[cappedTlf.String()].ByteLimit)
+
+	t.Log("The sync cache refuses blocks over the cap instead.")
+	err = config.SetTlfSyncState(cappedTlf, true)
+	require.NoError(t, err)
+	for i := uint64(0); i < numBlocksAllowed; i++ {
+		blockPtr, _, blockEncoded, serverHalf := setupBlockForDiskCache(
+			t, config)
+		err := cache.syncCache.Put(
+			ctx, cappedTlf, blockPtr.ID, blockEncoded, serverHalf)
+		require.NoError(t, err)
+	}
+	blockPtr, _, blockEncoded, serverHalf := setupBlockForDiskCache(t, config)
+	err = cache.syncCache.Put(
+		ctx, cappedTlf, blockPtr.ID, blockEncoded, serverHalf)
+	require.IsType(t, cachePutCacheFullError{}, err)
+}
+
 func TestDiskBlockCacheStaticLimit(t *testing.T) {
 	t.Parallel()
 	t.Log("Test that disk cache eviction works when we hit the static limit.")
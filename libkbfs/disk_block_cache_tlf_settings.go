@@ -0,0 +1,143 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libkbfs
+
+import (
+	"path/filepath"
+
+	"github.com/keybase/kbfs/kbfscodec"
+	"github.com/keybase/kbfs/tlf"
+	"github.com/pkg/errors"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+)
+
+const diskBlockCacheTlfSettingsFolderName = "disk_cache_tlf_config"
+
+// DiskBlockCachePriority is the priority class of a TLF in the disk
+// block cache.  When the cache needs to make room, blocks of TLFs in
+// lower classes are evicted first.
+type DiskBlockCachePriority int
+
+const (
+	// DiskBlockCachePriorityDefault means that no class was configured
+	// for the TLF, so its class depends on whether it's a favorite.
+	DiskBlockCachePriorityDefault DiskBlockCachePriority = iota
+	// DiskBlockCachePriorityOther is the class of TLFs that are
+	// neither synced nor favorites.  They're evicted first.
+	DiskBlockCachePriorityOther
+	// DiskBlockCachePriorityFavorite is the class of the current
+	// user's favorite TLFs.
+	DiskBlockCachePriorityFavorite
+	// DiskBlockCachePrioritySynced is the class of synced TLFs, and
+	// of TLFs with synced paths.  They're evicted last.
+	DiskBlockCachePrioritySynced
+)
+
+func (p DiskBlockCachePriority) String() string {
+	switch p {
+	case DiskBlockCachePriorityDefault:
+		return "default"
+	case DiskBlockCachePriorityOther:
+		return "other"
+	case DiskBlockCachePriorityFavorite:
+		return "favorite"
+	case DiskBlockCachePrioritySynced:
+		return "synced"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseDiskBlockCachePriority parses the string form of a
+// DiskBlockCachePriority, as returned by its String method.
+func ParseDiskBlockCachePriority(s string) (DiskBlockCachePriority, error) {
+	for p := DiskBlockCachePriorityDefault; p <= DiskBlockCachePrioritySynced; p++ {
+		if p.String() == s {
+			return p, nil
+		}
+	}
+	return DiskBlockCachePriorityDefault, errors.Errorf(
+		"unknown disk block cache priority %q", s)
+}
+
+// DiskBlockCacheTlfSettings are the tunable per-TLF settings of the
+// disk block cache.  The zero value means the TLF uses the defaults.
+type DiskBlockCacheTlfSettings struct {
+	// Priority overrides the priority class of the TLF, unless it's
+	// synced, in which case it's always in the synced class.
+	Priority DiskBlockCachePriority `codec:"p,omitempty"`
+	// MaxBytes caps the number of bytes the TLF can take up in each
+	// disk block cache.  Zero means no cap.
+	MaxBytes uint64 `codec:"m,omitempty"`
+}
+
+func openDiskBlockCacheTlfSettingsDb(storageRoot string) (*levelDb, error) {
+	if storageRoot == "" {
+		return nil, errors.New("empty storageRoot specified for non-test run")
+	}
+	dbPath := filepath.Join(storageRoot, diskBlockCacheTlfSettingsFolderName)
+	stor, err := storage.OpenFile(dbPath, false)
+	if err != nil {
+		return nil, err
+	}
+	return openLevelDB(stor)
+}
+
+// LoadDiskBlockCacheTlfSettings loads the per-TLF disk block cache
+// settings stored under the given storage root.  KBFS only loads
+// them on startup, so changes made by another process take effect
+// the next time KBFS starts.
+func LoadDiskBlockCacheTlfSettings(
+	codec kbfscodec.Codec, storageRoot string) (
+	map[tlf.ID]DiskBlockCacheTlfSettings, error) {
+	ldb, err := openDiskBlockCacheTlfSettingsDb(storageRoot)
+	if err != nil {
+		return nil, err
+	}
+	defer ldb.Close()
+	iter := ldb.NewIterator(nil, nil)
+	defer iter.Release()
+
+	allSettings := make(map[tlf.ID]DiskBlockCacheTlfSettings)
+	for iter.Next() {
+		var tlfID tlf.ID
+		err = tlfID.UnmarshalText(iter.Key())
+		if err != nil {
+			return nil, err
+		}
+		var settings DiskBlockCacheTlfSettings
+		err = codec.Decode(iter.Value(), &settings)
+		if err != nil {
+			return nil, err
+		}
+		allSettings[tlfID] = settings
+	}
+	return allSettings, iter.Error()
+}
+
+// StoreDiskBlockCacheTlfSettings stores the disk block cache settings
+// of the given TLF under the given storage root.  Storing the zero
+// value removes any settings for the TLF.
+func StoreDiskBlockCacheTlfSettings(codec kbfscodec.Codec,
+	storageRoot string, tlfID tlf.ID,
+	settings DiskBlockCacheTlfSettings) error {
+	ldb, err := openDiskBlockCacheTlfSettingsDb(storageRoot)
+	if err != nil {
+		return err
+	}
+	defer ldb.Close()
+	tlfBytes, err := tlfID.MarshalText()
+	if err != nil {
+		return err
+	}
+	if settings == (DiskBlockCacheTlfSettings{}) {
+		return ldb.Delete(tlfBytes, nil)
+	}
+	buf, err := codec.Encode(settings)
+	if err != nil {
+		return err
+	}
+	return ldb.Put(tlfBytes, buf, nil)
+}
@@ -30,6 +30,7 @@ type diskBlockCacheConfig interface {
 	clockGetter
 	diskLimiterGetter
 	syncedTlfGetterSetter
+	diskBlockCacheTlfSettingsGetterSetter
 	initModeGetter
 }
 
@@ -175,6 +176,90 @@ func (cache *diskBlockCacheWrapped) Put(ctx context.Context, tlfID tlf.ID,
 	return cache.workingSetCache.Put(ctx, tlfID, blockID, buf, serverHalf)
 }
 
+// moveToSyncCache moves a block from the working set cache into the sync
+// cache. It's used for blocks within a synced path of a TLF that isn't
+// synced as a whole, since those blocks are put into the working set cache
+// when they're first fetched.
+func (cache *diskBlockCacheWrapped) moveToSyncCache(ctx context.Context,
+	tlfID tlf.ID, blockID kbfsblock.ID) error {
+	// This is a write operation but we are only reading the pointers to the
+	// caches. So we use a read lock.
+	cache.mtx.RLock()
+	defer cache.mtx.RUnlock()
+	if cache.syncCache == nil {
+		return errors.New("sync block cache is not enabled")
+	}
+	buf, serverHalf, prefetchStatus, err := cache.workingSetCache.Get(
+		ctx, tlfID, blockID)
+	if _, isNoSuchBlockError := err.(NoSuchBlockError); isNoSuchBlockError {
+		// Either the block is already in the sync cache, or it isn't
+		// cached at all.
+		return nil
+	} else if err != nil {
+		return err
+	}
+	err = cache.syncCache.Put(ctx, tlfID, blockID, buf, serverHalf)
+	if err != nil {
+		return err
+	}
+	err = cache.syncCache.UpdateMetadata(ctx, blockID, prefetchStatus)
+	if err != nil {
+		return err
+	}
+	_, _, err = cache.workingSetCache.Delete(ctx, []kbfsblock.ID{blockID})
+	return err
+}
+
+// isInWorkingSetCache returns true if the given block is in the
+// working set cache.
+func (cache *diskBlockCacheWrapped) isInWorkingSetCache(
+	ctx context.Context, blockID kbfsblock.ID) bool {
+	cache.mtx.RLock()
+	defer cache.mtx.RUnlock()
+	_, err := cache.workingSetCache.GetMetadata(ctx, blockID)
+	return err == nil
+}
+
+// moveTlfToWorkingSetCache moves all the blocks of the given TLF from
+// the sync cache back into the working set cache, where they're
+// subject to eviction again.  It's used when a synced path of a TLF
+// that isn't synced as a whole is unpinned; the blocks of any
+// remaining synced paths get moved back into the sync cache the next
+// time the prefetcher walks them.
+func (cache *diskBlockCacheWrapped) moveTlfToWorkingSetCache(
+	ctx context.Context, tlfID tlf.ID) error {
+	// This is a write operation but we are only reading the pointers to the
+	// caches. So we use a read lock.
+	cache.mtx.RLock()
+	defer cache.mtx.RUnlock()
+	if cache.syncCache == nil {
+		return nil
+	}
+	blockIDs, err := cache.syncCache.getTlfBlockIDs(tlfID)
+	if err != nil {
+		return err
+	}
+	for _, blockID := range blockIDs {
+		buf, serverHalf, prefetchStatus, err := cache.syncCache.Get(
+			ctx, tlfID, blockID)
+		if _, isNoSuchBlockError := err.(NoSuchBlockError); isNoSuchBlockError {
+			continue
+		} else if err != nil {
+			return err
+		}
+		err = cache.workingSetCache.Put(ctx, tlfID, blockID, buf, serverHalf)
+		if err != nil {
+			return err
+		}
+		err = cache.workingSetCache.UpdateMetadata(ctx, blockID, prefetchStatus)
+		if err != nil {
+			return err
+		}
+	}
+	_, _, err = cache.syncCache.Delete(ctx, blockIDs)
+	return err
+}
+
 // Delete implements the DiskBlockCache interface for diskBlockCacheWrapped.
 func (cache *diskBlockCacheWrapped) Delete(ctx context.Context,
 	blockIDs []kbfsblock.ID) (numRemoved int, sizeRemoved int64, err error) {
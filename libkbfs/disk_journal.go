@@ -51,8 +51,9 @@ func (o journalOrdinal) String() string {
 // dir/0...fff
 //
 // Each file in dir is named with an ordinal and contains a generic
-// serializable entry object. The files EARLIEST and LATEST point to
-// the earliest and latest valid ordinal, respectively.
+// serializable entry object, encrypted by crypter if it's
+// non-nil. The files EARLIEST and LATEST point to the earliest and
+// latest valid ordinal, respectively.
 //
 // This class is not goroutine-safe; it assumes that all
 // synchronization is done at a higher level.
@@ -63,6 +64,7 @@ func (o journalOrdinal) String() string {
 // TODO: Make IO ops cancellable.
 type diskJournal struct {
 	codec     kbfscodec.Codec
+	crypter   *journalCrypter
 	dir       string
 	entryType reflect.Type
 
@@ -78,10 +80,11 @@ type diskJournal struct {
 
 // makeDiskJournal returns a new diskJournal for the given directory.
 func makeDiskJournal(
-	codec kbfscodec.Codec, dir string, entryType reflect.Type) (
-	*diskJournal, error) {
+	codec kbfscodec.Codec, crypter *journalCrypter, dir string,
+	entryType reflect.Type) (*diskJournal, error) {
 	j := &diskJournal{
 		codec:     codec,
+		crypter:   crypter,
 		dir:       dir,
 		entryType: entryType,
 	}
@@ -214,7 +217,7 @@ func (j *diskJournal) clear() error {
 	j.latest = journalOrdinal(0)
 
 	// j.dir will be recreated on the next call to
-	// writeJournalEntry (via kbfscodec.SerializeToFile), which
+	// writeJournalEntry (via journalCrypter.serializeToFile), which
 	// must always come before any ordinal write.
 	return ioutil.RemoveAll(j.dir)
 }
@@ -260,7 +263,7 @@ func (j *diskJournal) removeEarliest() (empty bool, err error) {
 func (j diskJournal) readJournalEntry(o journalOrdinal) (interface{}, error) {
 	p := j.journalEntryPath(o)
 	entry := reflect.New(j.entryType)
-	err := kbfscodec.DeserializeFromFile(j.codec, p, entry)
+	err := j.crypter.deserializeFromFile(j.codec, p, entry)
 	if err != nil {
 		return nil, err
 	}
@@ -276,7 +279,22 @@ func (j *diskJournal) writeJournalEntry(
 			j.entryType, entryType))
 	}
 
-	return kbfscodec.SerializeToFile(j.codec, entry, j.journalEntryPath(o))
+	return j.crypter.serializeToFile(j.codec, entry, j.journalEntryPath(o))
+}
+
+// migrateToEncrypted encrypts any plaintext entries in the journal in
+// place.
+func (j diskJournal) migrateToEncrypted() error {
+	if j.empty() {
+		return nil
+	}
+	for o := j.earliest; o <= j.latest; o++ {
+		err := j.crypter.migrateFile(j.journalEntryPath(o))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // appendJournalEntry appends the given entry to the journal. If o is
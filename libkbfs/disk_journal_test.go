@@ -42,7 +42,7 @@ func TestDiskJournalOrdinals(t *testing.T) {
 
 	codec := kbfscodec.NewMsgpack()
 	j, err := makeDiskJournal(
-		codec, tempdir, reflect.TypeOf(testJournalEntry{}))
+		codec, nil, tempdir, reflect.TypeOf(testJournalEntry{}))
 	require.NoError(t, err)
 
 	readEarliest := func() (journalOrdinal, error) {
@@ -113,7 +113,7 @@ func TestDiskJournalClear(t *testing.T) {
 
 	codec := kbfscodec.NewMsgpack()
 	j, err := makeDiskJournal(
-		codec, tempdir, reflect.TypeOf(testJournalEntry{}))
+		codec, nil, tempdir, reflect.TypeOf(testJournalEntry{}))
 	require.NoError(t, err)
 
 	o, err := j.appendJournalEntry(nil, testJournalEntry{1})
@@ -144,7 +144,7 @@ func TestDiskJournalMoveEmpty(t *testing.T) {
 
 	codec := kbfscodec.NewMsgpack()
 	j, err := makeDiskJournal(
-		codec, oldDir, reflect.TypeOf(testJournalEntry{}))
+		codec, nil, oldDir, reflect.TypeOf(testJournalEntry{}))
 	require.NoError(t, err)
 	require.Equal(t, oldDir, j.dir)
 
@@ -167,7 +167,7 @@ func TestDiskJournalMove(t *testing.T) {
 
 	codec := kbfscodec.NewMsgpack()
 	j, err := makeDiskJournal(
-		codec, oldDir, reflect.TypeOf(testJournalEntry{}))
+		codec, nil, oldDir, reflect.TypeOf(testJournalEntry{}))
 	require.NoError(t, err)
 	require.Equal(t, oldDir, j.dir)
 
@@ -0,0 +1,254 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libkbfs
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/keybase/client/go/logger"
+	"github.com/keybase/kbfs/kbfsmd"
+	"github.com/keybase/kbfs/tlf"
+	"github.com/pkg/errors"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+	"golang.org/x/net/context"
+)
+
+const (
+	headsDbFilename string = "diskCacheMDHeads.leveldb"
+	mdCacheFolder   string = "kbfs_md_cache"
+	// handleKeyPrefix prefixes the keys that map a TLF's canonical
+	// path to its ID.  Those keys share the heads db with the MD
+	// heads, which are keyed by the (shorter) raw TLF ID bytes.
+	handleKeyPrefix string = "handle:"
+)
+
+// diskMDCacheConfig is the subset of Config needed by DiskMDCacheLocal.
+type diskMDCacheConfig interface {
+	codecGetter
+	logMaker
+}
+
+// diskMDCacheEntry is the on-disk representation of a cached MD head.
+type diskMDCacheEntry struct {
+	Revision  kbfsmd.Revision
+	Version   kbfsmd.MetadataVer
+	Timestamp time.Time
+	// Buf is the encoded kbfsmd.RootMetadataSigned.
+	Buf []byte
+	// The key bundles are only set for MDs with segregated key
+	// bundles (i.e., v3 and above), since the server may not be
+	// around to give them to us when this entry is used.
+	WriterKeyBundle *kbfsmd.TLFWriterKeyBundleV3 `codec:",omitempty"`
+	ReaderKeyBundle *kbfsmd.TLFReaderKeyBundleV3 `codec:",omitempty"`
+}
+
+// DiskMDCacheLocal is the standard implementation for DiskMDCache.
+// It stores the latest known merged MD head of each TLF in a local
+// leveldb.
+type DiskMDCacheLocal struct {
+	config diskMDCacheConfig
+	log    logger.Logger
+
+	// Protect the leveldb from being shutdown while it's being
+	// accessed.
+	lock    sync.RWMutex
+	headsDb *levelDb
+}
+
+var _ DiskMDCache = (*DiskMDCacheLocal)(nil)
+
+func newDiskMDCacheLocalFromStorage(
+	config diskMDCacheConfig, headsStorage storage.Storage) (
+	*DiskMDCacheLocal, error) {
+	headsDb, err := openLevelDB(headsStorage)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &DiskMDCacheLocal{
+		config:  config,
+		log:     config.MakeLogger("DMC"),
+		headsDb: headsDb,
+	}, nil
+}
+
+// newDiskMDCacheLocal creates a new *DiskMDCacheLocal with a specified
+// directory on the filesystem as storage.
+func newDiskMDCacheLocal(config diskMDCacheConfig, dirPath string) (
+	*DiskMDCacheLocal, error) {
+	headsDbPath := filepath.Join(dirPath, headsDbFilename)
+	headsStorage, err := storage.OpenFile(headsDbPath, false)
+	if err != nil {
+		return nil, err
+	}
+	return newDiskMDCacheLocalFromStorage(config, headsStorage)
+}
+
+// newDiskMDCacheLocalForTest creates a new *DiskMDCacheLocal backed by
+// in-memory storage.
+func newDiskMDCacheLocalForTest(config diskMDCacheConfig) (
+	*DiskMDCacheLocal, error) {
+	return newDiskMDCacheLocalFromStorage(config, storage.NewMemStorage())
+}
+
+func (cache *DiskMDCacheLocal) getEntryLocked(tlfID tlf.ID) (
+	entry diskMDCacheEntry, ok bool, err error) {
+	buf, err := cache.headsDb.Get(tlfID.Bytes(), nil)
+	if err == leveldb.ErrNotFound {
+		return diskMDCacheEntry{}, false, nil
+	} else if err != nil {
+		return diskMDCacheEntry{}, false, errors.WithStack(err)
+	}
+	err = cache.config.Codec().Decode(buf, &entry)
+	if err != nil {
+		return diskMDCacheEntry{}, false, err
+	}
+	return entry, true, nil
+}
+
+// Get implements the DiskMDCache interface for DiskMDCacheLocal.
+func (cache *DiskMDCacheLocal) Get(ctx context.Context, tlfID tlf.ID) (
+	rmds *RootMetadataSigned, extra kbfsmd.ExtraMetadata, err error) {
+	cache.lock.RLock()
+	defer cache.lock.RUnlock()
+	if cache.headsDb == nil {
+		return nil, nil, errors.WithStack(DiskCacheClosedError{"Get"})
+	}
+
+	entry, ok, err := cache.getEntryLocked(tlfID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, NoSuchMDError{tlfID, kbfsmd.RevisionUninitialized,
+			kbfsmd.NullBranchID}
+	}
+
+	rmds, err = DecodeRootMetadataSigned(
+		cache.config.Codec(), tlfID, entry.Version, entry.Version, entry.Buf,
+		entry.Timestamp)
+	if err != nil {
+		return nil, nil, err
+	}
+	if entry.WriterKeyBundle != nil && entry.ReaderKeyBundle != nil {
+		extra = kbfsmd.NewExtraMetadataV3(
+			*entry.WriterKeyBundle, *entry.ReaderKeyBundle, false, false)
+	}
+	cache.log.CDebugf(ctx, "Loaded cached MD head for TLF %s, revision %d",
+		tlfID, entry.Revision)
+	return rmds, extra, nil
+}
+
+// Put implements the DiskMDCache interface for DiskMDCacheLocal.
+func (cache *DiskMDCacheLocal) Put(ctx context.Context,
+	rmds *RootMetadataSigned, extra kbfsmd.ExtraMetadata) error {
+	tlfID := rmds.MD.TlfID()
+	rev := rmds.MD.RevisionNumber()
+	buf, err := kbfsmd.EncodeRootMetadataSigned(
+		cache.config.Codec(), &rmds.RootMetadataSigned)
+	if err != nil {
+		return err
+	}
+	newEntry := diskMDCacheEntry{
+		Revision:  rev,
+		Version:   rmds.Version(),
+		Timestamp: rmds.untrustedServerTimestamp,
+		Buf:       buf,
+	}
+	if extraV3, ok := extra.(*kbfsmd.ExtraMetadataV3); ok {
+		wkb := extraV3.GetWriterKeyBundle()
+		rkb := extraV3.GetReaderKeyBundle()
+		newEntry.WriterKeyBundle = &wkb
+		newEntry.ReaderKeyBundle = &rkb
+	}
+	encodedEntry, err := cache.config.Codec().Encode(newEntry)
+	if err != nil {
+		return err
+	}
+
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	if cache.headsDb == nil {
+		return errors.WithStack(DiskCacheClosedError{"Put"})
+	}
+	// Never replace a head with an older one, since puts can race
+	// with each other.
+	oldEntry, ok, err := cache.getEntryLocked(tlfID)
+	if err != nil {
+		return err
+	}
+	if ok && oldEntry.Revision >= rev {
+		return nil
+	}
+	err = cache.headsDb.Put(tlfID.Bytes(), encodedEntry, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	cache.log.CDebugf(ctx, "Cached MD head for TLF %s, revision %d",
+		tlfID, rev)
+	return nil
+}
+
+func handleKey(handle *TlfHandle) []byte {
+	return []byte(handleKeyPrefix + string(handle.GetCanonicalPath()))
+}
+
+// GetIDForHandle implements the DiskMDCache interface for
+// DiskMDCacheLocal.
+func (cache *DiskMDCacheLocal) GetIDForHandle(
+	ctx context.Context, handle *TlfHandle) (tlf.ID, error) {
+	cache.lock.RLock()
+	defer cache.lock.RUnlock()
+	if cache.headsDb == nil {
+		return tlf.NullID, errors.WithStack(
+			DiskCacheClosedError{"GetIDForHandle"})
+	}
+
+	buf, err := cache.headsDb.Get(handleKey(handle), nil)
+	if err == leveldb.ErrNotFound {
+		return tlf.NullID, NoSuchTlfIDError{handle}
+	} else if err != nil {
+		return tlf.NullID, errors.WithStack(err)
+	}
+	var id tlf.ID
+	err = id.UnmarshalBinary(buf)
+	if err != nil {
+		return tlf.NullID, err
+	}
+	return id, nil
+}
+
+// PutIDForHandle implements the DiskMDCache interface for
+// DiskMDCacheLocal.
+func (cache *DiskMDCacheLocal) PutIDForHandle(
+	ctx context.Context, handle *TlfHandle, id tlf.ID) error {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	if cache.headsDb == nil {
+		return errors.WithStack(DiskCacheClosedError{"PutIDForHandle"})
+	}
+	err := cache.headsDb.Put(handleKey(handle), id.Bytes(), nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// Shutdown implements the DiskMDCache interface for DiskMDCacheLocal.
+func (cache *DiskMDCacheLocal) Shutdown(ctx context.Context) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	if cache.headsDb == nil {
+		cache.log.CWarningf(ctx, "Shutdown called more than once")
+		return
+	}
+	err := cache.headsDb.Close()
+	if err != nil {
+		cache.log.CWarningf(ctx, "Error closing MD heads db: %+v", err)
+	}
+	cache.headsDb = nil
+}
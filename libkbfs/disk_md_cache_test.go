@@ -0,0 +1,71 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libkbfs
+
+import (
+	"testing"
+
+	"github.com/keybase/client/go/protocol/keybase1"
+	"github.com/keybase/kbfs/kbfsmd"
+	"github.com/keybase/kbfs/tlf"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+func TestDiskMDCachePutGet(t *testing.T) {
+	ctx := context.Background()
+	config := MakeTestConfigOrBust(t, "test_user")
+	defer CheckConfigAndShutdown(ctx, t, config)
+
+	cache, err := newDiskMDCacheLocalForTest(config)
+	require.NoError(t, err)
+
+	session, err := config.KBPKI().GetCurrentSession(ctx)
+	require.NoError(t, err)
+	uid := session.UID
+	h, err := tlf.MakeHandle(
+		[]keybase1.UserOrTeamID{uid.AsUserOrTeam()}, nil, nil, nil, nil)
+	require.NoError(t, err)
+	id := tlf.FakeID(1, tlf.Private)
+
+	t.Log("Nothing is cached for a new TLF.")
+	_, _, err = cache.Get(ctx, id)
+	require.IsType(t, NoSuchMDError{}, errors.Cause(err))
+
+	makeRMDS := func(rev kbfsmd.Revision) *RootMetadataSigned {
+		brmd := makeBRMDForTest(
+			t, config.Codec(), id, h, rev, uid, kbfsmd.FakeID(1))
+		return signRMDSForTest(t, config.Codec(), config.Crypto(), brmd)
+	}
+
+	t.Log("Cache revision 2 and read it back.")
+	err = cache.Put(ctx, makeRMDS(2), nil)
+	require.NoError(t, err)
+	rmds, extra, err := cache.Get(ctx, id)
+	require.NoError(t, err)
+	require.Nil(t, extra)
+	require.Equal(t, id, rmds.MD.TlfID())
+	require.Equal(t, kbfsmd.Revision(2), rmds.MD.RevisionNumber())
+
+	t.Log("An older revision doesn't replace the cached one.")
+	err = cache.Put(ctx, makeRMDS(1), nil)
+	require.NoError(t, err)
+	rmds, _, err = cache.Get(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, kbfsmd.Revision(2), rmds.MD.RevisionNumber())
+
+	t.Log("A newer revision does.")
+	err = cache.Put(ctx, makeRMDS(3), nil)
+	require.NoError(t, err)
+	rmds, _, err = cache.Get(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, kbfsmd.Revision(3), rmds.MD.RevisionNumber())
+
+	t.Log("The cache can't be used after shutdown.")
+	cache.Shutdown(ctx)
+	_, _, err = cache.Get(ctx, id)
+	require.IsType(t, DiskCacheClosedError{}, errors.Cause(err))
+}
@@ -1191,3 +1191,25 @@ func (e MDWrittenAfterRevokeError) Error() string {
 		"last valid revision would have been %d",
 		e.revBad, e.tlfID, e.verifyingKey, e.revLimit)
 }
+
+// NoSuchPrefetchError indicates that the prefetcher isn't currently
+// prefetching the subtree of the given block.
+type NoSuchPrefetchError struct {
+	ptr BlockPointer
+}
+
+// Error implements the Error interface for NoSuchPrefetchError.
+func (e NoSuchPrefetchError) Error() string {
+	return fmt.Sprintf("No prefetch in progress for block %v", e.ptr)
+}
+
+// NoSuchConflictError indicates that there's no outstanding conflict
+// copy at the given path.
+type NoSuchConflictError struct {
+	conflictPath string
+}
+
+// Error implements the Error interface for NoSuchConflictError.
+func (e NoSuchConflictError) Error() string {
+	return fmt.Sprintf("No outstanding conflict copy at %s", e.conflictPath)
+}
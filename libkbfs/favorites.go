@@ -6,6 +6,7 @@ package libkbfs
 
 import (
 	"sync"
+	"time"
 
 	"github.com/keybase/client/go/protocol/keybase1"
 	"github.com/keybase/kbfs/kbfssync"
@@ -13,6 +14,20 @@ import (
 	"golang.org/x/net/context"
 )
 
+const (
+	// favoritesSeedTimeout bounds how long seeding the favorite state
+	// of the TLFs in the favorites list may take.
+	favoritesSeedTimeout = 5 * time.Minute
+)
+
+type ctxFavoritesTagKey int
+
+const (
+	ctxFavoritesIDKey ctxFavoritesTagKey = iota
+)
+
+const ctxFavoritesOpID = "FAVID"
+
 type favToAdd struct {
 	Favorite
 
@@ -65,6 +80,11 @@ type Favorites struct {
 	inFlightLock sync.Mutex
 	inFlightAdds map[favToAdd]*favReq
 
+	// seedCtx is canceled on shutdown, to stop any seeding of TLF
+	// favorite states.
+	seedCtx    context.Context
+	seedCancel context.CancelFunc
+
 	muShutdown sync.RWMutex
 	shutdown   bool
 }
@@ -75,6 +95,7 @@ func newFavoritesWithChan(config Config, reqChan chan *favReq) *Favorites {
 		reqChan:      reqChan,
 		inFlightAdds: make(map[favToAdd]*favReq),
 	}
+	f.seedCtx, f.seedCancel = context.WithCancel(context.Background())
 	go f.loop()
 	return f
 }
@@ -108,6 +129,11 @@ func (f *Favorites) handleReq(req *favReq) (err error) {
 		if err != nil {
 			return err
 		}
+		if f.cache == nil {
+			// This is the first list since startup, so let the
+			// disk block cache know which TLFs are favorites.
+			defer f.seedTlfFavoriteStates()
+		}
 
 		f.cache = make(map[Favorite]bool)
 		for _, folder := range folders {
@@ -156,6 +182,60 @@ func (f *Favorites) handleReq(req *favReq) (err error) {
 	return nil
 }
 
+// seedTlfFavoriteStates records the favorite state of every TLF in
+// the cached favorites list in the config, so that the disk block
+// cache keeps the blocks of favorites ahead of those of other TLFs
+// even before they're accessed.  It looks up the TLF IDs in the
+// background, since that may take a while.
+func (f *Favorites) seedTlfFavoriteStates() {
+	if f.config.DiskBlockCache() == nil {
+		return
+	}
+	favs := make([]Favorite, 0, len(f.cache))
+	for fav := range f.cache {
+		favs = append(favs, fav)
+	}
+
+	log := f.config.MakeLogger("")
+	ctx, cancel := context.WithTimeout(CtxWithRandomIDReplayable(
+		f.seedCtx, ctxFavoritesIDKey, ctxFavoritesOpID, log),
+		favoritesSeedTimeout)
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		defer cancel()
+		numFavs := 0
+		for _, fav := range favs {
+			if ctx.Err() != nil {
+				break
+			}
+			h, err := GetHandleFromFolderNameAndType(
+				ctx, f.config.KBPKI(), f.config.MDOps(), fav.Name, fav.Type)
+			if err != nil {
+				log.CDebugf(ctx, "Couldn't get a handle for favorite %v: %+v",
+					fav, err)
+				continue
+			}
+			tlfID := h.TlfID()
+			if tlfID == tlf.NullID {
+				tlfID, err = f.config.MDOps().GetIDForHandle(ctx, h)
+				if err != nil {
+					log.CDebugf(ctx, "Couldn't get the ID of favorite %v: "+
+						"%+v", fav, err)
+					continue
+				}
+			}
+			if tlfID == tlf.NullID {
+				continue
+			}
+			f.config.SetTlfFavoriteState(tlfID, true)
+			numFavs++
+		}
+		log.CDebugf(ctx, "Seeded the favorite state of %d/%d TLFs",
+			numFavs, len(favs))
+	}()
+}
+
 func (f *Favorites) loop() {
 	for req := range f.reqChan {
 		f.handleReq(req)
@@ -168,6 +248,7 @@ func (f *Favorites) Shutdown() error {
 	f.muShutdown.Lock()
 	defer f.muShutdown.Unlock()
 	f.shutdown = true
+	f.seedCancel()
 	close(f.reqChan)
 	return f.wg.Wait(context.Background())
 }
@@ -190,3 +190,43 @@ func TestFavoritesListFailsDuringAddAsync(t *testing.T) {
 	f.AddAsync(ctx, fav1) // should work
 	<-c
 }
+
+func TestFavoritesSeedTlfFavoriteStates(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	config := MakeTestConfigOrBust(t, "u1")
+	ctx := context.Background()
+	defer CheckConfigAndShutdown(ctx, t, config)
+
+	t.Log("Make a TLF for the user, so it has an ID.")
+	h, err := GetHandleFromFolderNameAndType(
+		ctx, config.KBPKI(), config.MDOps(), "u1", tlf.Private)
+	if err != nil {
+		t.Fatalf("Couldn't get handle: %+v", err)
+	}
+	tlfID, err := config.MDOps().GetIDForHandle(ctx, h)
+	if err != nil {
+		t.Fatalf("Couldn't get TLF ID: %+v", err)
+	}
+	if config.IsFavoriteTlf(tlfID) {
+		t.Fatalf("TLF %s is a favorite before the first list", tlfID)
+	}
+
+	t.Log("The first list seeds the favorite states, when there's a " +
+		"disk block cache to use them.")
+	config.diskBlockCache = NewMockDiskBlockCache(mockCtrl)
+	defer func() { config.diskBlockCache = nil }()
+	f := NewFavorites(config)
+	if _, err := f.Get(ctx); err != nil {
+		t.Fatalf("Couldn't get favorites: %+v", err)
+	}
+	if err := f.wg.Wait(ctx); err != nil {
+		t.Fatalf("Couldn't wait for favorites: %+v", err)
+	}
+	if !config.IsFavoriteTlf(tlfID) {
+		t.Errorf("TLF %s wasn't seeded as a favorite", tlfID)
+	}
+	if err := f.Shutdown(); err != nil {
+		t.Errorf("Couldn't shut down favorites: %v", err)
+	}
+}
@@ -465,6 +465,43 @@ func (fd *fileData) getIndirectBlocksForOffsetRange(ctx context.Context,
 	return pfr, nil
 }
 
+// getLeafPtrsForReadAhead returns the pointers of up to `numBlocks`
+// leaf blocks of the file, starting with the one containing
+// `startOff`.  It also returns the offset up to which the returned
+// blocks cover the file.  It only fetches indirect blocks, never
+// leaf blocks.
+func (fd *fileData) getLeafPtrsForReadAhead(ctx context.Context,
+	startOff int64, numBlocks int) (
+	ptrs []BlockPointer, coveredEndOff int64, err error) {
+	topBlock, _, err := fd.getter(ctx, fd.kmd, fd.rootBlockPointer(),
+		fd.file, blockRead)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !topBlock.IsInd {
+		// The only leaf block is the one being read.
+		return nil, startOff, nil
+	}
+
+	endOff := startOff + int64(numBlocks)*int64(readAheadAssumedBlockSize)
+	pfr, err := fd.getIndirectBlocksForOffsetRange(
+		ctx, topBlock, startOff, endOff)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, p := range pfr {
+		if len(p) == 0 {
+			continue
+		}
+		iptr := p[len(p)-1].childIPtr()
+		if len(ptrs) == numBlocks {
+			return ptrs, iptr.Off, nil
+		}
+		ptrs = append(ptrs, iptr.BlockPointer)
+	}
+	return ptrs, endOff, nil
+}
+
 // getByteSlicesInOffsetRange returns an ordered, continuous slice of
 // byte ranges for the data described by the half-inclusive offset
 // range `[startOff, endOff)`.  If `endOff` == -1, it returns data to
@@ -887,6 +924,39 @@ func (fd *fileData) shiftBlocksToFillHole(
 	fd.log.CDebugf(ctx, "Shifting block with offset %d for file %v into "+
 		"position", newBlockStartOff, fd.rootBlockPointer())
 
+	// updateParentOffs makes the indirect pointers leading to the
+	// immediate parent in `parents` match the offset of that parent's
+	// leftmost child, all the way up to the first ancestor that
+	// doesn't reach it through a childIndex of 0.
+	updateParentOffs := func(parents []parentBlockAndChildIndex) error {
+		newLeftOff := parents[len(parents)-1].pblock.IPtrs[0].Off
+		for level := len(parents) - 2; level >= 0; level-- {
+			// Cache the block below this level, which was just
+			// modified.
+			childPtr := parents[level].childIPtr()
+			if err := fd.cacher(childPtr.BlockPointer,
+				parents[level+1].pblock); err != nil {
+				return err
+			}
+			newDirtyPtrs = append(newDirtyPtrs, childPtr.BlockPointer)
+			// Remember the size of the dirtied child.
+			index := parents[level].childIndex
+			if childPtr.EncodedSize != 0 {
+				newUnrefs = append(newUnrefs, childPtr.BlockInfo)
+				parents[level].pblock.IPtrs[index].EncodedSize = 0
+			}
+
+			// If we've reached a level where the child indirect
+			// offset wasn't affected, we're done.  If not, update the
+			// offset at this level and move up the tree.
+			if parents[level+1].childIndex > 0 {
+				break
+			}
+			parents[level].pblock.IPtrs[index].Off = newLeftOff
+		}
+		return nil
+	}
+
 	// Swap left as needed.
 	for {
 		var leftOff int64
@@ -941,6 +1011,15 @@ func (fd *fileData) shiftBlocksToFillHole(
 				immedParent.pblock.IPtrs[currIndex],
 				immedParent.pblock.IPtrs[currIndex-1]
 			currIndex--
+			parents[len(parents)-1].childIndex = currIndex
+			// If the new block is now the leftmost child of its
+			// parent, the offsets pointing to that parent must
+			// change too.
+			if currIndex == 0 {
+				if err := updateParentOffs(parents); err != nil {
+					return nil, nil, 0, err
+				}
+			}
 			continue
 		}
 
@@ -997,39 +1076,23 @@ func (fd *fileData) shiftBlocksToFillHole(
 
 		// Now we need to update the parent offsets on the right side,
 		// all the way up to the common ancestor (which is the one
-		// with the one that doesn't have a childIndex of 0).  (We
-		// don't need to update the left side, since the offset of the
-		// new right-most block on that side doesn't affect the
-		// incoming indirect pointer offset, which already points to
-		// the left side of that branch.)
-		newRightOff := immedParent.pblock.IPtrs[currIndex].Off
-		for level := len(parents) - 2; level >= 0; level-- {
-			// Cache the block below this level, which was just
-			// modified.
-			childPtr := parents[level].childIPtr()
-			if err := fd.cacher(childPtr.BlockPointer,
-				parents[level+1].pblock); err != nil {
-				return nil, nil, 0, err
-			}
-			newDirtyPtrs = append(newDirtyPtrs, childPtr.BlockPointer)
-			// Remember the size of the dirtied child.
-			index := parents[level].childIndex
-			if childPtr.EncodedSize != 0 {
-				newUnrefs = append(newUnrefs, childPtr.BlockInfo)
-				parents[level].pblock.IPtrs[index].EncodedSize = 0
-			}
-
-			// If we've reached a level where the child indirect
-			// offset wasn't affected, we're done.  If not, update the
-			// offset at this level and move up the tree.
-			if parents[level+1].childIndex > 0 {
-				break
-			}
-			parents[level].pblock.IPtrs[index].Off = newRightOff
+		// with the one that doesn't have a childIndex of 0).  We
+		// usually don't need to update the left side, since the
+		// offset of the new right-most block on that side doesn't
+		// affect the incoming indirect pointer offset, which already
+		// points to the left side of that branch -- unless the new
+		// block is the only child on that side.
+		if err := updateParentOffs(parents); err != nil {
+			return nil, nil, 0, err
 		}
 		immedParent = newImmedParent
 		currIndex = newCurrIndex
 		parents = newParents
+		if currIndex == 0 {
+			if err := updateParentOffs(parents); err != nil {
+				return nil, nil, 0, err
+			}
+		}
 	}
 	// The loop above must exit via one of the returns.
 }
@@ -1408,7 +1471,7 @@ func (fd *fileData) truncateShrink(ctx context.Context, size uint64,
 					// unreferenced (unless it's on the left-most edge
 					// of the tree, in which case we keep it around
 					// for now -- see above TODO).
-					if pb.childIndex == 0 && !leftMost {
+					if removeStartingFromIndex == 0 && !leftMost {
 						if parentInfo.EncodedSize != 0 {
 							unrefs = append(unrefs, parentInfo)
 						}
@@ -1733,7 +1796,83 @@ func (fd *fileData) ready(ctx context.Context, id tlf.ID, bcache BlockCache,
 		return nil, nil
 	}
 
-	return fd.readyHelper(ctx, id, bcache, bops, bps, dirtyLeafPaths, df)
+	// Adding a new level of indirection on top of an indirect block
+	// moves the old top block to a new ID, without necessarily
+	// dirtying any of the leaf blocks under it.  So ready any dirty
+	// indirect blocks that aren't on the path to a dirty leaf block
+	// first.
+	onDirtyLeafPath := make(map[BlockPointer]bool)
+	for _, path := range dirtyLeafPaths {
+		for _, pb := range path[:len(path)-1] {
+			onDirtyLeafPath[pb.childIPtr().BlockPointer] = true
+		}
+	}
+	oldPtrs := make(map[BlockInfo]BlockPointer)
+	err := fd.readyDirtyIndirectBlocks(ctx, id, bcache, dirtyBcache, bops,
+		bps, topBlock, onDirtyLeafPath, oldPtrs)
+	if err != nil {
+		return nil, err
+	}
+
+	leafPathPtrs, err := fd.readyHelper(
+		ctx, id, bcache, bops, bps, dirtyLeafPaths, df)
+	if err != nil {
+		return nil, err
+	}
+	for newInfo, oldPtr := range leafPathPtrs {
+		oldPtrs[newInfo] = oldPtr
+	}
+	return oldPtrs, nil
+}
+
+// readyDirtyIndirectBlocks readies all the dirty indirect blocks
+// under `pblock` that aren't in `skip`, deepest first, and updates
+// their block infos in their parent blocks.  It adds the new block
+// info of each readied block to `oldPtrs`, mapped to its old block
+// pointer.
+func (fd *fileData) readyDirtyIndirectBlocks(ctx context.Context,
+	id tlf.ID, bcache BlockCache, dirtyBcache DirtyBlockCache,
+	bops BlockOps, bps *blockPutState, pblock *FileBlock,
+	skip map[BlockPointer]bool, oldPtrs map[BlockInfo]BlockPointer) error {
+	for i, iptr := range pblock.IPtrs {
+		ptr := iptr.BlockPointer
+		if !dirtyBcache.IsDirty(id, ptr, fd.file.Branch) {
+			continue
+		}
+		block, _, err := fd.getter(ctx, fd.kmd, ptr, fd.file, blockWrite)
+		if err != nil {
+			return err
+		}
+		if !block.IsInd {
+			continue
+		}
+
+		err = fd.readyDirtyIndirectBlocks(ctx, id, bcache, dirtyBcache,
+			bops, bps, block, skip, oldPtrs)
+		if err != nil {
+			return err
+		}
+		if skip[ptr] {
+			continue
+		}
+
+		newInfo, _, readyBlockData, err := ReadyBlock(
+			ctx, bcache, bops, fd.crypto, fd.kmd, block, fd.chargedTo,
+			fd.rootBlockPointer().GetBlockType())
+		if err != nil {
+			return err
+		}
+		err = bcache.Put(newInfo.BlockPointer, id, block, PermanentEntry)
+		if err != nil {
+			return err
+		}
+		bps.addNewBlock(newInfo.BlockPointer, block, readyBlockData, nil)
+		bps.saveOldPtr(ptr)
+
+		pblock.IPtrs[i].BlockInfo = newInfo
+		oldPtrs[newInfo] = ptr
+	}
+	return nil
 }
 
 func (fd *fileData) getIndirectFileBlockInfosWithTopBlock(ctx context.Context,
@@ -1810,7 +1949,7 @@ func (fd *fileData) findIPtrsAndClearSize(
 			}
 			infoSeen[parentPtr] = true
 
-			for _, iptr := range pb.pblock.IPtrs {
+			for i, iptr := range pb.pblock.IPtrs {
 				if ptrs[iptr.BlockPointer] {
 					// Mark this pointer, and all parent blocks, as dirty.
 					parentPtr := fd.rootBlockPointer()
@@ -1824,7 +1963,14 @@ func (fd *fileData) findIPtrsAndClearSize(
 						path[i].pblock = pblock
 						parentPtr = path[i].childIPtr().BlockPointer
 					}
-					_, _, err = fd.markParentsDirty(ctx, path[:level+1])
+					// The found pointer isn't necessarily the
+					// child on this path, so dirty the path
+					// leading to it instead.
+					iptrPath := make(
+						[]parentBlockAndChildIndex, level+1)
+					copy(iptrPath, path[:level+1])
+					iptrPath[level].childIndex = i
+					_, _, err = fd.markParentsDirty(ctx, iptrPath)
 					if err != nil {
 						return nil, err
 					}
@@ -604,6 +604,75 @@ func TestFileDataWriteHole(t *testing.T) {
 	}
 }
 
+func testFileDataWriteAcrossHole(t *testing.T, maxBlockSize int64,
+	maxPtrsPerBlock int, fullDataLen int64, hole testFileDataHole,
+	startWrite, endWrite int64) {
+	fd, cleanBcache, _, df := setupFileDataTest(
+		t, maxBlockSize, maxPtrsPerBlock)
+	data := make([]byte, fullDataLen)
+	for i := int64(0); i < fullDataLen; i++ {
+		if i < hole.start || i >= hole.end {
+			data[i] = byte(i)
+		}
+	}
+	topBlock, _ := testFileDataLevelExistingBlocks(
+		t, fd, maxBlockSize, maxPtrsPerBlock, data,
+		[]testFileDataHole{hole}, cleanBcache)
+	de := DirEntry{
+		EntryInfo: EntryInfo{
+			Size: uint64(fullDataLen),
+		},
+	}
+
+	newData := make([]byte, endWrite-startWrite)
+	for i := startWrite; i < endWrite; i++ {
+		newData[i-startWrite] = byte(i + 1)
+	}
+	_, _, _, _, _, err := fd.write(
+		context.Background(), newData, startWrite, topBlock, de, df)
+	require.NoError(t, err)
+	if endWrite > fullDataLen {
+		data = append(data, make([]byte, endWrite-fullDataLen)...)
+	}
+	copy(data[startWrite:endWrite], newData)
+
+	// Make sure we can read back the complete data.
+	gotData := make([]byte, len(data))
+	nRead, err := fd.read(context.Background(), gotData, 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(data)), nRead)
+	require.True(t, bytes.Equal(data, gotData))
+}
+
+// Test that a write that fills a hole in the middle of a file, and
+// continues over the data after it, keeps the indirect pointer
+// offsets consistent as the new blocks shift into the hole.
+func TestFileDataWriteAcrossHole(t *testing.T) {
+	type test struct {
+		name       string
+		fullLen    int64
+		hole       testFileDataHole
+		startWrite int64
+		endWrite   int64
+	}
+
+	tests := []test{
+		{"FromBlockBeforeHole", 12, testFileDataHole{3, 8}, 2, 11},
+		{"FromInsideHole", 12, testFileDataHole{3, 8}, 4, 11},
+		{"ToEnd", 16, testFileDataHole{3, 8}, 2, 16},
+		{"PastEnd", 12, testFileDataHole{1, 9}, 1, 20},
+	}
+
+	for _, test := range tests {
+		// capture range variable.
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			testFileDataWriteAcrossHole(t, 2, 2, test.fullLen, test.hole,
+				test.startWrite, test.endWrite)
+		})
+	}
+}
+
 func testFileDataCheckTruncateExtend(t *testing.T, fd *fileData,
 	dirtyBcache DirtyBlockCache, df *dirtyFile, size uint64,
 	topBlock *FileBlock, oldDe DirEntry, expectedTopLevel testFileDataLevel) {
@@ -771,6 +840,7 @@ func TestFileDataTruncateShrink(t *testing.T) {
 		{"WithinBlock", 6, 5},
 		{"WithinLevel", 8, 5},
 		{"ToZero", 8, 0},
+		{"WithinRightSubtree", 16, 9},
 	}
 
 	for _, test := range tests {
@@ -269,6 +269,10 @@ type folderBlockOps struct {
 	// call PathFromNode() only under blockLock (see nodeCache
 	// comments in folder_branch_ops.go).
 	nodeCache NodeCache
+
+	// readAhead is goroutine-safe, and tracks which files are being
+	// read sequentially.
+	readAhead *readAheadTracker
 }
 
 // Only exported methods of folderBlockOps should be used outside of this
@@ -730,6 +734,43 @@ func (fbo *folderBlockOps) GetIndirectFileBlockInfos(ctx context.Context,
 	return fd.getIndirectFileBlockInfos(ctx)
 }
 
+// GetCleanIndirectFileBlockInfos is like GetIndirectFileBlockInfos,
+// but ignores any dirty blocks of the file, and so only returns the
+// BlockInfos of the last synced version of the file.
+func (fbo *folderBlockOps) GetCleanIndirectFileBlockInfos(
+	ctx context.Context, lState *lockState, kmd KeyMetadata, file path) (
+	[]BlockInfo, error) {
+	fbo.blockLock.RLock(lState)
+	defer fbo.blockLock.RUnlock(lState)
+	var id keybase1.UserOrTeamID // Data reads don't depend on the id.
+	fd := newFileData(file, id, fbo.config.Crypto(),
+		fbo.config.BlockSplitter(), kmd,
+		func(ctx context.Context, kmd KeyMetadata, ptr BlockPointer,
+			file path, rtype blockReqType) (*FileBlock, bool, error) {
+			if block, err := fbo.config.BlockCache().Get(ptr); err == nil {
+				fblock, ok := block.(*FileBlock)
+				if !ok {
+					return nil, false, NotFileBlockError{ptr, file.Branch, file}
+				}
+				return fblock, false, nil
+			}
+			fblock := NewFileBlock().(*FileBlock)
+			var err error
+			fbo.blockLock.DoRUnlockedIfPossible(lState, func(*lockState) {
+				err = fbo.config.BlockOps().Get(
+					ctx, kmd, ptr, fblock, TransientEntry)
+			})
+			if err != nil {
+				return nil, false, err
+			}
+			return fblock, false, nil
+		},
+		func(ptr BlockPointer, block Block) error {
+			return errors.New("Can't cache a clean block for writing")
+		}, fbo.log)
+	return fd.getIndirectFileBlockInfos(ctx)
+}
+
 // GetIndirectFileBlockInfosWithTopBlock returns a list of BlockInfos
 // for all indirect blocks of the given file, starting from the given
 // top-most block. If the returned error is a recoverable one (as
@@ -1815,7 +1856,96 @@ func (fbo *folderBlockOps) Read(
 
 	var id keybase1.UserOrTeamID // Data reads don't depend on the id.
 	fd := fbo.newFileData(lState, filePath, id, kmd)
-	return fd.read(ctx, dest, off)
+	n, err := fd.read(ctx, dest, off)
+	if err != nil {
+		return 0, err
+	}
+	fbo.readAheadLocked(ctx, lState, kmd, file, filePath, off, n)
+	return n, nil
+}
+
+// readAheadLocked requests the blocks of `file` that are likely to
+// be read next, if the read of `n` bytes at `off` looks like part of
+// a sequential scan of the file.  Finding the blocks might mean
+// fetching indirect blocks, so that's done in the background, and
+// neither this read nor any writers wait on it.
+func (fbo *folderBlockOps) readAheadLocked(
+	ctx context.Context, lState *lockState, kmd KeyMetadata, file Node,
+	filePath path, off, n int64) {
+	fbo.blockLock.AssertRLocked(lState)
+	if fbo.readAhead == nil || fbo.config.Mode().PrefetchWorkers() == 0 {
+		return
+	}
+	id := file.GetID()
+	startOff, numBlocks, ok := fbo.readAhead.observeRead(id, off, n)
+	if !ok {
+		return
+	}
+	// The blocks of a dirty file might not be on the server yet.
+	if _, ok := fbo.dirtyFiles[filePath.tailPointer()]; ok {
+		fbo.readAhead.setRequested(id, 0)
+		return
+	}
+
+	// Use a fresh context, since the read-ahead blocks should still
+	// be fetched after this read returns.
+	readAheadCtx := CtxWithRandomIDReplayable(context.Background(),
+		ctxReadAheadIDKey, ctxReadAheadOpID, fbo.log)
+	fbo.log.CDebugf(ctx, "Reading ahead %d blocks at offset %d (%s=%v)",
+		numBlocks, startOff, ctxReadAheadOpID,
+		readAheadCtx.Value(ctxReadAheadIDKey))
+	fd := fbo.newFileDataForReadAhead(filePath, kmd)
+	go fbo.requestReadAhead(readAheadCtx, kmd, fd, id, startOff, numBlocks)
+}
+
+// newFileDataForReadAhead returns a fileData that gets blocks
+// without holding `blockLock`, and can't dirty any.  Its blocks
+// might be out of date by the time they're fetched, which only means
+// that some read-ahead requests end up being wasted.
+func (fbo *folderBlockOps) newFileDataForReadAhead(
+	file path, kmd KeyMetadata) *fileData {
+	var id keybase1.UserOrTeamID // Data reads don't depend on the id.
+	return newFileData(file, id, fbo.config.Crypto(),
+		fbo.config.BlockSplitter(), kmd,
+		func(ctx context.Context, kmd KeyMetadata, ptr BlockPointer,
+			file path, _ blockReqType) (*FileBlock, bool, error) {
+			fblock, err := fbo.getFileBlockHelperLocked(
+				ctx, nil, kmd, ptr, file.Branch, file, blockReadParallel)
+			return fblock, false, err
+		},
+		func(ptr BlockPointer, _ Block) error {
+			return errors.Errorf("Can't dirty block %v while reading ahead",
+				ptr)
+		}, fbo.log)
+}
+
+// requestReadAhead looks up the pointers of up to `numBlocks` blocks of a
+// file, starting at `startOff`, and requests them.  It must be called
+// without holding `blockLock`.
+func (fbo *folderBlockOps) requestReadAhead(
+	ctx context.Context, kmd KeyMetadata, fd *fileData, id NodeID,
+	startOff int64, numBlocks int) {
+	var requestedEnd int64
+	defer func() {
+		fbo.readAhead.setRequested(id, requestedEnd)
+	}()
+
+	lookupCtx, cancel := context.WithTimeout(ctx, readAheadLookupTimeout)
+	defer cancel()
+	ptrs, coveredEnd, err := fd.getLeafPtrsForReadAhead(
+		lookupCtx, startOff, numBlocks)
+	if err != nil {
+		fbo.log.CDebugf(ctx, "Couldn't get read-ahead blocks: %+v", err)
+		return
+	}
+	if len(ptrs) > 0 {
+		fbo.log.CDebugf(ctx, "Requesting %d read-ahead blocks", len(ptrs))
+	}
+	for _, ptr := range ptrs {
+		_ = fbo.config.BlockOps().BlockRetriever().Request(ctx,
+			readAheadPriority, kmd, ptr, NewFileBlock(), TransientEntry)
+	}
+	requestedEnd = coveredEnd
 }
 
 func (fbo *folderBlockOps) maybeWaitOnDeferredWrites(
@@ -2151,11 +2281,13 @@ func (fbo *folderBlockOps) truncateExtendLocked(
 	return latestWrite, dirtyPtrs, nil
 }
 
-// Returns the set of newly-ID'd blocks created during this truncate
-// that might need to be cleaned up if the truncate is deferred.
+// Returns the write ranges of the truncate, which might be more than
+// one if the new size falls in a hole, and the set of newly-ID'd
+// blocks created during this truncate that might need to be cleaned
+// up if the truncate is deferred.
 func (fbo *folderBlockOps) truncateLocked(
 	ctx context.Context, lState *lockState, kmd KeyMetadata,
-	file path, size uint64) (*WriteRange, []BlockPointer, int64, error) {
+	file path, size uint64) ([]WriteRange, []BlockPointer, int64, error) {
 	if jServer, err := GetJournalServer(fbo.config); err == nil {
 		jServer.dirtyOpStart(fbo.id())
 		defer jServer.dirtyOpEnd(fbo.id())
@@ -2163,13 +2295,13 @@ func (fbo *folderBlockOps) truncateLocked(
 
 	fblock, err := fbo.writeGetFileLocked(ctx, lState, kmd, file)
 	if err != nil {
-		return &WriteRange{}, nil, 0, err
+		return nil, nil, 0, err
 	}
 
 	chargedTo, err := chargedToForTLF(
 		ctx, fbo.config.KBPKI(), fbo.config.KBPKI(), kmd.GetTlfHandle())
 	if err != nil {
-		return &WriteRange{}, nil, 0, err
+		return nil, nil, 0, err
 	}
 
 	fd := fbo.newFileData(lState, file, chargedTo, kmd)
@@ -2179,26 +2311,41 @@ func (fbo *folderBlockOps) truncateLocked(
 	_, parentBlocks, block, nextBlockOff, startOff, _, err :=
 		fd.getFileBlockAtOffset(ctx, fblock, iSize, blockWrite)
 	if err != nil {
-		return &WriteRange{}, nil, 0, err
+		return nil, nil, 0, err
 	}
 
 	currLen := int64(startOff) + int64(len(block.Contents))
-	if currLen+truncateExtendCutoffPoint < iSize {
+	if nextBlockOff > 0 && currLen < iSize {
+		// The new size falls in a hole in the middle of the file, so
+		// first cut off everything after the data before the hole,
+		// and then extend the file back out to the new size.
+		shrinkWrites, dirtyPtrs, newlyDirtiedChildBytes, err :=
+			fbo.truncateLocked(ctx, lState, kmd, file, uint64(currLen))
+		if err != nil {
+			return shrinkWrites, dirtyPtrs, newlyDirtiedChildBytes, err
+		}
+		extendWrites, moreDirtyPtrs, moreDirtiedChildBytes, err :=
+			fbo.truncateLocked(ctx, lState, kmd, file, size)
+		return append(shrinkWrites, extendWrites...),
+			append(dirtyPtrs, moreDirtyPtrs...),
+			newlyDirtiedChildBytes + moreDirtiedChildBytes, err
+	} else if currLen+truncateExtendCutoffPoint < iSize {
 		latestWrite, dirtyPtrs, err := fbo.truncateExtendLocked(
 			ctx, lState, kmd, file, uint64(iSize), parentBlocks)
 		if err != nil {
-			return &latestWrite, dirtyPtrs, 0, err
+			return nil, dirtyPtrs, 0, err
 		}
-		return &latestWrite, dirtyPtrs, 0, err
+		return []WriteRange{latestWrite}, dirtyPtrs, 0, nil
 	} else if currLen < iSize {
 		moreNeeded := iSize - currLen
 		latestWrite, dirtyPtrs, newlyDirtiedChildBytes, err :=
 			fbo.writeDataLocked(ctx, lState, kmd, file,
 				make([]byte, moreNeeded, moreNeeded), currLen)
 		if err != nil {
-			return &latestWrite, dirtyPtrs, newlyDirtiedChildBytes, err
+			return nil, dirtyPtrs, newlyDirtiedChildBytes, err
 		}
-		return &latestWrite, dirtyPtrs, newlyDirtiedChildBytes, err
+		return []WriteRange{latestWrite}, dirtyPtrs,
+			newlyDirtiedChildBytes, nil
 	} else if currLen == iSize && nextBlockOff < 0 {
 		// same size!
 		return nil, nil, 0, nil
@@ -2236,7 +2383,7 @@ func (fbo *folderBlockOps) truncateLocked(
 	cacheEntry.dirEntry = newDe
 	fbo.deCache[file.tailRef()] = cacheEntry
 
-	return &latestWrite, dirtyPtrs, newlyDirtiedChildBytes, nil
+	return []WriteRange{latestWrite}, dirtyPtrs, newlyDirtiedChildBytes, nil
 }
 
 // Truncate truncates or extends the given file to the given size.
@@ -2276,14 +2423,14 @@ func (fbo *folderBlockOps) Truncate(
 		fbo.doDeferWrite = false
 	}()
 
-	latestWrite, dirtyPtrs, newlyDirtiedChildBytes, err := fbo.truncateLocked(
+	writes, dirtyPtrs, newlyDirtiedChildBytes, err := fbo.truncateLocked(
 		ctx, lState, kmd, filePath, size)
 	if err != nil {
 		return err
 	}
 
-	if latestWrite != nil {
-		fbo.observers.localChange(ctx, file, *latestWrite)
+	for _, w := range writes {
+		fbo.observers.localChange(ctx, file, w)
 	}
 
 	if fbo.doDeferWrite {
@@ -2426,6 +2573,12 @@ func ReadyBlock(ctx context.Context, bcache BlockCache, bops BlockOps,
 		if err != nil {
 			return
 		}
+		// Usage is tracked separately for each block type (e.g.,
+		// unembedded MD block changes vs. file data), so only reuse
+		// blocks of the same type.
+		if ptr.GetBlockType() != bType {
+			ptr = BlockPointer{}
+		}
 	} else if dBlock, ok := block.(*DirBlock); ok {
 		if dBlock.IsInd {
 			panic("Indirect directory blocks aren't supported yet")
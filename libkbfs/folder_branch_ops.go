@@ -248,6 +248,9 @@ type folderBranchOps struct {
 	// should only be taken in the following order to avoid deadlock:
 	mdWriterLock leveledMutex // taken by any method making MD modifications
 	dirOps       []cachedDirOp
+	// conflict decisions to record in the next synced MD, keyed by
+	// conflict path; protected by mdWriterLock.
+	conflictDecisions map[string]ConflictDecision
 
 	// protects access to head, headStatus, latestMergedRevision,
 	// and hasBeenCleared.
@@ -404,6 +407,7 @@ func newFolderBranchOps(ctx context.Context, config Config, fb FolderBranch,
 			unrefCache: make(map[BlockRef]*syncInfo),
 			deCache:    make(map[BlockRef]deCacheEntry),
 			nodeCache:  nodeCache,
+			readAhead:  newReadAheadTracker(config.Clock()),
 		},
 		nodeCache:       nodeCache,
 		log:             traceLogger{log},
@@ -548,7 +552,20 @@ func (fbo *folderBranchOps) deleteFromFavorites(ctx context.Context,
 }
 
 func (fbo *folderBranchOps) doFavoritesOp(ctx context.Context,
-	favs *Favorites, fop FavoritesOp, handle *TlfHandle) error {
+	favs *Favorites, fop FavoritesOp, handle *TlfHandle) (err error) {
+	defer func() {
+		// Let the disk block cache know about favorites, so it can
+		// prefer to keep their blocks.
+		if err != nil {
+			return
+		}
+		switch fop {
+		case FavoritesOpAdd, FavoritesOpAddNewlyCreated:
+			fbo.config.SetTlfFavoriteState(fbo.id(), true)
+		case FavoritesOpRemove:
+			fbo.config.SetTlfFavoriteState(fbo.id(), false)
+		}
+	}()
 	switch fop {
 	case FavoritesOpNoChange:
 		return nil
@@ -2112,6 +2129,45 @@ func (fbo *folderBranchOps) GetNodeMetadata(ctx context.Context, node Node) (
 	return res, nil
 }
 
+func (fbo *folderBranchOps) GetPrefetchStatus(
+	ctx context.Context, node Node) (res NodePrefetchStatus, err error) {
+	fbo.log.CDebugf(ctx, "GetPrefetchStatus %s", getNodeIDStr(node))
+	defer func() {
+		fbo.deferLog.CDebugf(ctx, "GetPrefetchStatus %s done: %+v",
+			getNodeIDStr(node), err)
+	}()
+
+	var de DirEntry
+	err = runUnlessCanceled(ctx, func() error {
+		de, err = fbo.statEntry(ctx, node)
+		return err
+	})
+	if err != nil {
+		return res, err
+	}
+
+	// The cached status of the block says whether the subtree was
+	// completely prefetched, even across restarts when the block is
+	// in the disk cache.  If a prefetch is in flight, the prefetcher
+	// knows how far along it is.
+	prefetchStatus := fbo.config.PrefetchStatus(ctx, fbo.id(),
+		de.BlockPointer)
+	res.Status = prefetchStatus.String()
+	if prefetchStatus == FinishedPrefetch {
+		return res, nil
+	}
+	progress, err := fbo.config.BlockOps().Prefetcher().Status(
+		ctx, de.BlockPointer)
+	switch errors.Cause(err).(type) {
+	case nil:
+		res.Progress = &progress
+	case NoSuchPrefetchError:
+	default:
+		return res, err
+	}
+	return res, nil
+}
+
 // blockPutState is an internal structure to track data when putting blocks
 type blockPutState struct {
 	blockStates []blockState
@@ -3287,8 +3343,15 @@ func (fbo *folderBranchOps) unrefEntryLocked(ctx context.Context,
 	// removed, so no need to check for indirect directory blocks
 	// here.
 	if de.Type == File || de.Type == Exec {
-		blockInfos, err := fbo.blocks.GetIndirectFileBlockInfos(
-			ctx, lState, kmd, childPath)
+		getInfos := fbo.blocks.GetIndirectFileBlockInfos
+		if fbo.config.DirtyBlockCache().IsDirty(
+			fbo.id(), de.BlockPointer, fbo.branch()) {
+			// A removed file never gets synced, so none of its
+			// dirty blocks will ever make it to the server.  Only
+			// unreference the blocks of its last synced version.
+			getInfos = fbo.blocks.GetCleanIndirectFileBlockInfos
+		}
+		blockInfos, err := getInfos(ctx, lState, kmd, childPath)
 		if isRecoverableBlockErrorForRemoval(err) {
 			msg := fmt.Sprintf("Recoverable block error encountered for unrefEntry(%v); continuing", childPath)
 			fbo.log.CWarningf(ctx, "%s", msg)
@@ -4038,6 +4101,7 @@ func (fbo *folderBranchOps) syncAllLocked(
 	if err != nil {
 		return err
 	}
+	md.decideConflicts(fbo.conflictDecisions)
 
 	bps := newBlockPutState(0)
 	resolvedPaths := make(map[BlockPointer]path)
@@ -4085,9 +4149,10 @@ func (fbo *folderBranchOps) syncAllLocked(
 	}
 	defer func() {
 		// If the sync is successful, we can clear out all buffered
-		// directory operations.
+		// directory operations and conflict decisions.
 		if err == nil {
 			fbo.dirOps = nil
+			fbo.conflictDecisions = nil
 		}
 	}()
 
@@ -4320,7 +4385,7 @@ func (fbo *folderBranchOps) syncAllLocked(
 	head, _ := fbo.getHead(lState)
 	dummyHeadChains := newCRChainsEmpty()
 	dummyHeadChains.mostRecentChainMDInfo = mostRecentChainMetadataInfo{
-		head, head.Data().Dir.BlockInfo}
+		head, head.Data().Dir.BlockInfo, head.Data().ConflictRenaming}
 
 	// Squash the batch of updates together into a set of blocks and
 	// ready `md` for putting to the server.
@@ -4600,6 +4665,34 @@ func (fbo *folderBranchOps) getUnlinkPathBeforeUpdatingPointers(
 	return childPath, de, true, nil
 }
 
+// syncedPathOfChild returns the path of the entry `name` in `dir`,
+// relative to the root of the TLF, in the form used for synced paths.
+func (fbo *folderBranchOps) syncedPathOfChild(dir Node, name string) string {
+	dirPath := fbo.nodeCache.PathFromNode(dir)
+	names := make([]string, 0, len(dirPath.path))
+	for _, pn := range dirPath.path[1:] {
+		names = append(names, pn.Name)
+	}
+	return strings.Join(append(names, name), "/")
+}
+
+// renameSyncedPaths moves any synced paths of this TLF at or under
+// the renamed entry along with it, so that renaming a pinned
+// directory doesn't unpin it.
+func (fbo *folderBranchOps) renameSyncedPaths(ctx context.Context,
+	oldDir Node, oldName string, newDir Node, newName string) {
+	if len(fbo.config.GetTlfSyncedPaths(fbo.id())) == 0 {
+		return
+	}
+	oldPath := fbo.syncedPathOfChild(oldDir, oldName)
+	newPath := fbo.syncedPathOfChild(newDir, newName)
+	err := fbo.config.RenameTlfSyncedPaths(fbo.id(), oldPath, newPath)
+	if err != nil {
+		fbo.log.CWarningf(ctx, "Couldn't move synced paths from %s to %s: "+
+			"%+v", oldPath, newPath, err)
+	}
+}
+
 func (fbo *folderBranchOps) notifyOneOpLocked(ctx context.Context,
 	lState *lockState, op op, md ReadOnlyRootMetadata,
 	shouldPrefetch bool) error {
@@ -4714,6 +4807,8 @@ func (fbo *folderBranchOps) notifyOneOpLocked(ctx context.Context,
 			}
 
 			if newNode != nil {
+				fbo.renameSyncedPaths(ctx, oldNode, realOp.OldName,
+					newNode, realOp.NewName)
 				if toUnlink {
 					_ = fbo.nodeCache.Unlink(
 						unlinkDe.Ref(), unlinkPath, unlinkDe)
@@ -5505,9 +5600,22 @@ func (fbo *folderBranchOps) SyncFromServer(ctx context.Context,
 
 	lState := makeFBOLockState()
 
-	// Make sure everything outstanding syncs to disk at least.
-	if err := fbo.syncAllUnlocked(ctx, lState); err != nil {
-		return err
+	// Make sure everything outstanding syncs to disk at least.  Only
+	// retry block errors here; the other retriable errors need to
+	// wait on CR.  (CR calls `syncAllUnlocked` too, and handles its
+	// own errors, so don't retry inside `syncAllUnlocked` itself.)
+	for i := 0; ; i++ {
+		err := fbo.syncAllUnlocked(ctx, lState)
+		if err == nil {
+			break
+		} else if !isRecoverableBlockError(err) ||
+			i >= maxRetriesOnRecoverableErrors {
+			return err
+		}
+		// The failed block puts already dropped the bad blocks from
+		// the block cache, so the next attempt will make new ones.
+		fbo.log.CDebugf(ctx, "Trying again after recoverable block "+
+			"error: %v", err)
 	}
 
 	// A journal flush before CR, if needed.
@@ -6582,6 +6690,301 @@ func (fbo *folderBranchOps) GetUpdateHistory(ctx context.Context,
 	return history, nil
 }
 
+// lookupConflictPath returns the node of the parent directory of the
+// given path, which is relative to the root of the TLF, along with
+// the last element of the path.
+func (fbo *folderBranchOps) lookupConflictPath(
+	ctx context.Context, p string) (dir Node, name string, err error) {
+	dir, _, _, err = fbo.getRootNode(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	names := strings.Split(p, "/")
+	for _, name := range names[:len(names)-1] {
+		dir, _, err = fbo.lookup(ctx, dir, name)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	return dir, names[len(names)-1], nil
+}
+
+// conflictPathExists returns whether there's an entry at the given
+// path, which is relative to the root of the TLF.
+func (fbo *folderBranchOps) conflictPathExists(
+	ctx context.Context, p string) (bool, error) {
+	dir, name, err := fbo.lookupConflictPath(ctx, p)
+	if err == nil {
+		_, _, err = fbo.lookup(ctx, dir, name)
+	}
+	if _, isMiss := errors.Cause(err).(NoSuchNameError); isMiss {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetConflicts implements the KBFSOps interface for folderBranchOps.
+func (fbo *folderBranchOps) GetConflicts(ctx context.Context,
+	folderBranch FolderBranch) (conflicts TlfConflicts, err error) {
+	fbo.log.CDebugf(ctx, "GetConflicts")
+	defer func() {
+		fbo.deferLog.CDebugf(ctx, "GetConflicts done: %+v", err)
+	}()
+
+	if folderBranch != fbo.folderBranch {
+		return TlfConflicts{}, WrongOpsError{fbo.folderBranch, folderBranch}
+	}
+
+	lState := makeFBOLockState()
+	md, err := fbo.getMDForReadNeedIdentify(ctx, lState)
+	if err != nil {
+		return TlfConflicts{}, err
+	}
+
+	conflicts.HeadRevision = md.Revision()
+	conflicts.LatestMergedRevision = fbo.getLatestMergedRevision(lState)
+	if md.MergedStatus() == kbfsmd.Unmerged {
+		conflicts.Unmerged = true
+		conflicts.BranchID = md.BID().String()
+	}
+
+	writerNames := make(map[keybase1.UID]string)
+	getWriterName := func(uid keybase1.UID) (string, error) {
+		if name, ok := writerNames[uid]; ok {
+			return name, nil
+		}
+		name, err := fbo.config.KBPKI().GetNormalizedUsername(
+			ctx, uid.AsUserOrTeam())
+		if err != nil {
+			return "", err
+		}
+		writerNames[uid] = string(name)
+		return string(name), nil
+	}
+
+	conflicts.Conflicts = make([]ConflictSummary, 0, len(md.data.Conflicts))
+	for _, cc := range md.data.Conflicts {
+		summary := ConflictSummary{
+			OriginalPath:     cc.OriginalPath,
+			ConflictPath:     cc.ConflictPath,
+			MergedRevision:   cc.MergedRevision,
+			ResolvedRevision: cc.ResolvedRevision,
+			State:            ConflictOutstanding,
+		}
+		summary.OriginalWriter, err = getWriterName(cc.OriginalWriter)
+		if err != nil {
+			return TlfConflicts{}, err
+		}
+		summary.ConflictWriter, err = getWriterName(cc.ConflictWriter)
+		if err != nil {
+			return TlfConflicts{}, err
+		}
+
+		if cc.Decision != nil {
+			summary.State = ConflictResolved
+			summary.Winner = cc.Decision.Winner
+			summary.DecisionRevision = cc.Decision.Revision
+			summary.DecidedBy, err = getWriterName(cc.Decision.Writer)
+			if err != nil {
+				return TlfConflicts{}, err
+			}
+		} else {
+			exists, err := fbo.conflictPathExists(ctx, cc.ConflictPath)
+			if err != nil {
+				return TlfConflicts{}, err
+			}
+			if !exists {
+				summary.State = ConflictGone
+			}
+		}
+		conflicts.Conflicts = append(conflicts.Conflicts, summary)
+	}
+	return conflicts, nil
+}
+
+// ResolveConflict implements the KBFSOps interface for folderBranchOps.
+func (fbo *folderBranchOps) ResolveConflict(ctx context.Context,
+	folderBranch FolderBranch, conflictPath string,
+	winner ConflictWinner) (err error) {
+	fbo.log.CDebugf(ctx, "ResolveConflict %s %s", conflictPath, winner)
+	defer func() {
+		fbo.deferLog.CDebugf(ctx, "ResolveConflict %s %s done: %+v",
+			conflictPath, winner, err)
+	}()
+
+	if folderBranch != fbo.folderBranch {
+		return WrongOpsError{fbo.folderBranch, folderBranch}
+	}
+	if _, err := ParseConflictWinner(string(winner)); err != nil {
+		return err
+	}
+
+	lState := makeFBOLockState()
+	md, err := fbo.getMDForReadNeedIdentify(ctx, lState)
+	if err != nil {
+		return err
+	}
+	cc, ok := findOutstandingConflict(md.data.Conflicts, conflictPath)
+	if !ok {
+		return NoSuchConflictError{conflictPath}
+	}
+
+	// Find all the nodes needed before taking the lock.
+	copyDir, copyName, err := fbo.lookupConflictPath(ctx, cc.ConflictPath)
+	if err != nil {
+		return err
+	}
+	_, copyDe, err := fbo.lookup(ctx, copyDir, copyName)
+	if err != nil {
+		return err
+	}
+	err = fbo.checkNodeForWrite(ctx, copyDir)
+	if err != nil {
+		return err
+	}
+	var origDir Node
+	var origName string
+	if winner == ConflictWinnerCopy {
+		origDir, origName, err = fbo.lookupConflictPath(ctx, cc.OriginalPath)
+		if err != nil {
+			return err
+		}
+		err = fbo.checkNodeForWrite(ctx, origDir)
+		if err != nil {
+			return err
+		}
+	}
+
+	session, err := fbo.config.KBPKI().GetCurrentSession(ctx)
+	if err != nil {
+		return err
+	}
+
+	return fbo.doMDWriteWithRetryUnlessCanceled(ctx,
+		func(lState *lockState) error {
+			md, err := fbo.getMDForWriteLockedForFilename(ctx, lState, "")
+			if err != nil {
+				return err
+			}
+			// Decisions made on an unmerged branch would be lost
+			// by conflict resolution.
+			if md.MergedStatus() == kbfsmd.Unmerged {
+				return UnmergedError{}
+			}
+			if _, ok := findOutstandingConflict(
+				md.data.Conflicts, conflictPath); !ok {
+				return NoSuchConflictError{conflictPath}
+			}
+
+			// The decision is recorded by the sync that carries the
+			// removal of the loser.
+			if fbo.conflictDecisions == nil {
+				fbo.conflictDecisions = make(map[string]ConflictDecision)
+			}
+			fbo.conflictDecisions[conflictPath] = ConflictDecision{
+				Winner: winner,
+				Writer: session.UID,
+			}
+
+			switch {
+			case winner == ConflictWinnerCopy:
+				err = fbo.renameLocked(
+					ctx, lState, copyDir, copyName, origDir, origName)
+			case copyDe.Type == Dir:
+				err = fbo.removeDirLocked(ctx, lState, copyDir, copyName)
+			default:
+				var dirPath path
+				dirPath, err = fbo.pathFromNodeForMDWriteLocked(
+					lState, copyDir)
+				if err == nil {
+					err = fbo.removeEntryLocked(ctx, lState,
+						md.ReadOnly(), copyDir, dirPath, copyName)
+				}
+			}
+			if err != nil {
+				delete(fbo.conflictDecisions, conflictPath)
+				return err
+			}
+
+			return fbo.syncAllLocked(ctx, lState, NoExcl)
+		})
+}
+
+// GetConflictRenaming implements the KBFSOps interface for
+// folderBranchOps.
+func (fbo *folderBranchOps) GetConflictRenaming(ctx context.Context,
+	folderBranch FolderBranch) (renaming ConflictRenaming, err error) {
+	fbo.log.CDebugf(ctx, "GetConflictRenaming")
+	defer func() {
+		fbo.deferLog.CDebugf(ctx, "GetConflictRenaming done: %+v", err)
+	}()
+
+	if folderBranch != fbo.folderBranch {
+		return ConflictRenaming{},
+			WrongOpsError{fbo.folderBranch, folderBranch}
+	}
+
+	lState := makeFBOLockState()
+	md, err := fbo.getMDForReadNeedIdentify(ctx, lState)
+	if err != nil {
+		return ConflictRenaming{}, err
+	}
+	if md.data.ConflictRenaming == nil {
+		return ConflictRenaming{}, nil
+	}
+	return *md.data.ConflictRenaming, nil
+}
+
+// SetConflictRenaming implements the KBFSOps interface for
+// folderBranchOps.
+func (fbo *folderBranchOps) SetConflictRenaming(ctx context.Context,
+	folderBranch FolderBranch, renaming ConflictRenaming) (err error) {
+	fbo.log.CDebugf(ctx, "SetConflictRenaming %+v", renaming)
+	defer func() {
+		fbo.deferLog.CDebugf(ctx, "SetConflictRenaming done: %+v", err)
+	}()
+
+	if folderBranch != fbo.folderBranch {
+		return WrongOpsError{fbo.folderBranch, folderBranch}
+	}
+	err = renaming.Validate()
+	if err != nil {
+		return err
+	}
+
+	return fbo.doMDWriteWithRetryUnlessCanceled(ctx,
+		func(lState *lockState) error {
+			md, err := fbo.getSuccessorMDForWriteLocked(ctx, lState)
+			if err != nil {
+				return err
+			}
+			// Conflict resolution only replays ops, so a setting
+			// made on an unmerged branch would be lost.
+			if md.MergedStatus() == kbfsmd.Unmerged {
+				return UnmergedError{}
+			}
+
+			if len(renaming.Rules) == 0 && renaming.Template == "" {
+				md.data.ConflictRenaming = nil
+			} else {
+				md.data.ConflictRenaming = &renaming
+			}
+			md.AddOp(newResolutionOp())
+
+			bps, err := fbo.maybeUnembedAndPutBlocks(ctx, md)
+			if err != nil {
+				return err
+			}
+
+			return fbo.finalizeMDWriteLocked(ctx, lState, md, bps, NoExcl,
+				func(md ImmutableRootMetadata) error {
+					return fbo.notifyBatchLocked(ctx, lState, md)
+				})
+		})
+}
+
 // GetEditHistory implements the KBFSOps interface for folderBranchOps
 func (fbo *folderBranchOps) GetEditHistory(ctx context.Context,
 	folderBranch FolderBranch) (edits TlfWriterEdits, err error) {
@@ -30,12 +30,19 @@ type FolderBranchStatus struct {
 	MDVersion           kbfsmd.MetadataVer
 	RootBlockID         string
 	SyncEnabled         bool
+	SyncedPaths         []string `json:",omitempty"`
 	PrefetchStatus      string
 	UsageBytes          int64
 	LimitBytes          int64
 	GitUsageBytes       int64
 	GitLimitBytes       int64
 
+	// OfflineStatus is the string form of the TlfOfflineStatus of
+	// the TLF.  While the MD server is unreachable, a TLF that can
+	// be served offline has its reads served from local caches, and
+	// its writes only journaled until the connection comes back.
+	OfflineStatus string
+
 	// DirtyPaths are files that have been written, but not flushed.
 	// They do not represent unstaged changes in your local instance.
 	DirtyPaths []string
@@ -61,8 +68,20 @@ type KBFSStatus struct {
 	GitUsageBytes   int64
 	GitLimitBytes   int64
 	FailingServices map[string]error
+	// OfflineTLFs lists the open TLFs that are being served from
+	// local caches, because the MD server is unreachable.
+	OfflineTLFs []string `json:",omitempty"`
+	// UnavailableTLFs lists the open TLFs that can't be fully
+	// served from local caches while the MD server is unreachable.
+	UnavailableTLFs []string                        `json:",omitempty"`
 	JournalServer   *JournalServerStatus            `json:",omitempty"`
 	DiskCacheStatus map[string]DiskBlockCacheStatus `json:",omitempty"`
+	// BandwidthLimits is the schedule of bandwidth limits for block
+	// transfers, if any limits are set.
+	BandwidthLimits *BandwidthSchedule `json:",omitempty"`
+	// LocalStorage is a consolidated view of the local disk space
+	// used by the journals and the disk caches.
+	LocalStorage *LocalStorageStatus `json:",omitempty"`
 }
 
 // StatusUpdate is a dummy type used to indicate status has been updated.
@@ -181,6 +200,41 @@ func (fbsk *folderBranchStatusKeeper) convertNodesToPathsLocked(
 	return ret
 }
 
+// canServeTlfOffline returns whether the TLF with the given head can
+// be served entirely from local caches, i.e. whether its MD head is
+// cached on disk, so it can be loaded without the MD server, and
+// whether it is synced and its whole block tree has been prefetched
+// into the disk block cache.
+func canServeTlfOffline(
+	ctx context.Context, config Config, md ImmutableRootMetadata) bool {
+	dmc := config.DiskMDCache()
+	if dmc == nil {
+		return false
+	}
+	if _, _, err := dmc.Get(ctx, md.TlfID()); err != nil {
+		return false
+	}
+	if !config.IsSyncedTlf(md.TlfID()) {
+		return false
+	}
+	return config.PrefetchStatus(ctx, md.TlfID(),
+		md.Data().Dir.BlockPointer) == FinishedPrefetch
+}
+
+// getTlfOfflineStatus returns the offline status of the TLF with the
+// given head.
+func getTlfOfflineStatus(
+	ctx context.Context, config Config,
+	md ImmutableRootMetadata) TlfOfflineStatus {
+	if config.MDServer().IsConnected() {
+		return TlfOnline
+	}
+	if canServeTlfOffline(ctx, config, md) {
+		return TlfOffline
+	}
+	return TlfOfflineUnavailable
+}
+
 func (fbsk *folderBranchStatusKeeper) getStatusWithoutJournaling(
 	ctx context.Context) (
 	FolderBranchStatus, <-chan StatusUpdate, tlf.ID, error) {
@@ -209,10 +263,13 @@ func (fbsk *folderBranchStatusKeeper) getStatusWithoutJournaling(
 		fbs.Revision = fbsk.md.Revision()
 		fbs.MDVersion = fbsk.md.Version()
 		fbs.SyncEnabled = fbsk.config.IsSyncedTlf(fbsk.md.TlfID())
+		fbs.SyncedPaths = fbsk.config.GetTlfSyncedPaths(fbsk.md.TlfID())
 		prefetchStatus := fbsk.config.PrefetchStatus(ctx, fbsk.md.TlfID(),
 			fbsk.md.Data().Dir.BlockPointer)
 		fbs.PrefetchStatus = prefetchStatus.String()
 		fbs.RootBlockID = fbsk.md.Data().Dir.BlockPointer.ID.String()
+		fbs.OfflineStatus = getTlfOfflineStatus(
+			ctx, fbsk.config, fbsk.md).String()
 
 		if fbsk.quotaUsage == nil {
 			loggerSuffix := fmt.Sprintf("status-%s", fbsk.md.TlfID())
@@ -140,6 +140,7 @@ func TestFBStatusAllFields(t *testing.T) {
 	fbsk.addDirtyNode(n2)
 
 	config.mockRekeyQueue.EXPECT().IsRekeyPending(id)
+	config.mockMdserv.EXPECT().IsConnected().Return(true)
 	config.mockBcache.EXPECT().GetWithPrefetch(gomock.Any()).
 		Return(nil, NoPrefetch, NoCacheEntry, nil)
 
@@ -175,4 +176,52 @@ func TestFBStatusAllFields(t *testing.T) {
 	require.Equal(t, int64(1000), status.LimitBytes)
 	require.Equal(t, int64(20), status.GitUsageBytes)
 	require.Equal(t, int64(2000), status.GitLimitBytes)
+	require.Equal(t, TlfOnline.String(), status.OfflineStatus)
+}
+
+func TestTlfOfflineStatus(t *testing.T) {
+	mockCtrl, config, _, _ := fbStatusTestInit(t)
+	defer fbStatusTestShutdown(mockCtrl, config)
+	ctx := context.Background()
+
+	id := tlf.FakeID(1, tlf.Private)
+	h := parseTlfHandleOrBust(t, config, "alice", tlf.Private, id)
+	rmd, err := makeInitialRootMetadata(config.MetadataVersion(), id, h)
+	require.NoError(t, err)
+	signingKey := kbfscrypto.MakeFakeSigningKeyOrBust("fake seed")
+	md := MakeImmutableRootMetadata(rmd, signingKey.GetVerifyingKey(),
+		kbfsmd.FakeID(1), time.Now(), true)
+	dmc := NewMockDiskMDCache(mockCtrl)
+	config.diskMDCache = dmc
+
+	t.Log("A TLF is online while the MD server is connected.")
+	config.mockMdserv.EXPECT().IsConnected().Return(true)
+	require.Equal(t, TlfOnline, getTlfOfflineStatus(ctx, config, md))
+
+	t.Log("Without a head cached on disk, it can't be served offline.")
+	config.mockMdserv.EXPECT().IsConnected().AnyTimes().Return(false)
+	dmc.EXPECT().Get(gomock.Any(), id).Return(
+		nil, nil, NoSuchMDError{id, kbfsmd.RevisionUninitialized,
+			kbfsmd.NullBranchID})
+	require.Equal(t, TlfOfflineUnavailable,
+		getTlfOfflineStatus(ctx, config, md))
+
+	t.Log("Nor if it isn't synced.")
+	dmc.EXPECT().Get(gomock.Any(), id).AnyTimes().Return(
+		&RootMetadataSigned{}, nil, nil)
+	require.Equal(t, TlfOfflineUnavailable,
+		getTlfOfflineStatus(ctx, config, md))
+
+	t.Log("Nor if it's synced, but not all its blocks have been " +
+		"prefetched yet.")
+	config.syncedTlfs = map[tlf.ID]bool{id: true}
+	config.mockBcache.EXPECT().GetWithPrefetch(gomock.Any()).
+		Return(nil, TriggeredPrefetch, NoCacheEntry, nil)
+	require.Equal(t, TlfOfflineUnavailable,
+		getTlfOfflineStatus(ctx, config, md))
+
+	t.Log("Once the whole TLF is in the caches, it's served offline.")
+	config.mockBcache.EXPECT().GetWithPrefetch(gomock.Any()).
+		Return(nil, FinishedPrefetch, NoCacheEntry, nil)
+	require.Equal(t, TlfOffline, getTlfOfflineStatus(ctx, config, md))
 }
@@ -570,7 +570,9 @@ func (fup *folderUpdatePrepper) updateResolutionUsageLockedCache(
 			continue
 		}
 
-		if info, ok := fup.cachedInfos[ptr]; ok {
+		// A cached info without a size comes from a block that was
+		// dirtied before it was unreferenced, so look up its size.
+		if info, ok := fup.cachedInfos[ptr]; ok && info.EncodedSize != 0 {
 			unrefSum += uint64(info.EncodedSize)
 		} else {
 			unrefPtrsToFetch = append(unrefPtrsToFetch, ptr)
@@ -678,6 +680,15 @@ func (fup *folderUpdatePrepper) updateResolutionUsageAndPointersLockedCache(
 			}
 		}
 	}
+	// The pointers unreferenced by the final resolutionOp below count
+	// towards the usage too, if they weren't created in the unmerged
+	// branch.
+	for ptr := range unmergedChains.toUnrefPointers {
+		if ptr != zeroPtr && !unmergedChains.doNotUnrefPointers[ptr] {
+			unrefs[ptr] = true
+			delete(refs, ptr)
+		}
+	}
 
 	if isLocalSquash {
 		unmergedUsage := mostRecentUnmergedMD.DiskUsage()
@@ -1220,13 +1231,20 @@ func (fup *folderUpdatePrepper) prepUpdateForPaths(ctx context.Context,
 				chain, isMostRecent := unmergedChains.byMostRecent[update.Ref]
 				isDeleted := false
 				alreadyUpdated := false
+				isInTree := false
 				if isMostRecent {
 					isDeleted = unmergedChains.isDeleted(chain.original) ||
 						unmergedChains.toUnrefPointers[update.Ref]
 					_, alreadyUpdated = updates[chain.original]
+					// Unless this is a squash, the resolved tree
+					// starts from the merged branch, and only picks
+					// up unmerged blocks from chains that still have
+					// ops to resolve.
+					isInTree = isSquash || len(chain.ops) > 0
 				}
 				if newBlocks[update.Ref] ||
-					(isMostRecent && !isDeleted && !alreadyUpdated) {
+					(isMostRecent && !isDeleted && !alreadyUpdated &&
+						isInTree) {
 					fup.log.CDebugf(ctx, "Including update from old resOp: "+
 						"%v -> %v", update.Unref, update.Ref)
 					resOp.AddUpdate(update.Unref, update.Ref)
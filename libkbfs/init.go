@@ -19,6 +19,7 @@ import (
 	"github.com/keybase/client/go/logger"
 	"github.com/keybase/client/go/protocol/keybase1"
 	"github.com/keybase/go-framed-msgpack-rpc/rpc"
+	"github.com/keybase/kbfs/cache"
 	"github.com/keybase/kbfs/kbfsmd"
 )
 
@@ -59,6 +60,22 @@ type InitParams struct {
 	// zero, the capacity is set using getDefaultBlockCacheCapacity().
 	CleanBlockCacheCapacity uint64
 
+	// BlockCacheWarmStartMaxBlocks, if positive, makes KBFS save the
+	// pointers of up to this many hot blocks of the clean block
+	// cache on shutdown, and load them back from the disk block
+	// cache in the background on the next startup.
+	BlockCacheWarmStartMaxBlocks int
+
+	// CacheEvictionPolicy is the eviction policy of the in-memory
+	// clean block cache and key cache ("lru" or "arc").
+	CacheEvictionPolicy string
+
+	// BandwidthLimits, if non-empty, is a JSON-encoded
+	// BandwidthSchedule that limits the bandwidth of block
+	// transfers.  It can be changed at runtime by writing to the
+	// .kbfs_bandwidth_limits file.
+	BandwidthLimits string
+
 	// Fake local user name.
 	LocalUser string
 
@@ -101,6 +118,19 @@ type InitParams struct {
 	// DiskCacheMode specifies which mode to start the disk cache.
 	DiskCacheMode DiskCacheMode
 
+	// ConflictMergeRules, if non-empty, is a comma-separated list of
+	// pattern=strategy rules that pick how conflict resolution
+	// merges files written on two devices (see
+	// libkbfs.ParseConflictMergeRules).  Files that match no rule,
+	// are bigger than a single block, or fail to merge, are renamed
+	// as usual.
+	ConflictMergeRules string
+
+	// DiskCacheSocket, if non-empty, is the unix socket of a
+	// DiskBlockCacheServer to use as the disk cache when
+	// DiskCacheMode is DiskCacheModeRemote.
+	DiskCacheSocket string
+
 	// StorageRoot, if non-empty, points to a local directory to put its local
 	// databases for things like the journal or disk cache.
 	StorageRoot string
@@ -195,6 +225,7 @@ func DefaultInitParams(ctx Context) InitParams {
 		EnableJournal:                  BoolForString(journalEnv),
 		DiskCacheMode:                  DiskCacheModeLocal,
 		Mode:                           InitDefaultString,
+		CacheEvictionPolicy:            cache.EvictionPolicyLRU.String(),
 	}
 }
 
@@ -240,6 +271,20 @@ func AddFlagsWithDefaults(
 		defaultParams.CleanBlockCacheCapacity,
 		"If non-zero, specify the capacity of clean block cache. If zero, "+
 			"the capacity is set based on system RAM.")
+	flags.IntVar(&params.BlockCacheWarmStartMaxBlocks, "bcache-warm-start",
+		defaultParams.BlockCacheWarmStartMaxBlocks,
+		"If non-zero, the maximum number of hot blocks of the clean block "+
+			"cache to remember on shutdown, and to reload from the disk "+
+			"cache on the next startup.")
+	flags.StringVar(&params.CacheEvictionPolicy, "cache-eviction-policy",
+		defaultParams.CacheEvictionPolicy,
+		fmt.Sprintf("Eviction policy of the in-memory block and key "+
+			"caches (%s or %s).", cache.EvictionPolicyLRU,
+			cache.EvictionPolicyARC))
+	flags.StringVar(&params.BandwidthLimits, "bandwidth-limits",
+		defaultParams.BandwidthLimits,
+		"JSON-encoded schedule of bandwidth limits for block transfers "+
+			"(see libkbfs.BandwidthSchedule).")
 	flags.StringVar(&params.StorageRoot, "storage-root",
 		defaultParams.StorageRoot, "Specifies where Keybase will store its "+
 			"local databases for the journal and disk cache.")
@@ -249,6 +294,16 @@ func AddFlagsWithDefaults(
 			"subdirectory of -storage-root to store the cache. If 'remote', "+
 			"then it connects to the local KBFS instance and delegates disk "+
 			"cache operations to it.")
+	flags.StringVar(&params.ConflictMergeRules, "conflict-merge",
+		defaultParams.ConflictMergeRules, "Comma-separated pattern=strategy "+
+			"rules for merging files written on two devices, instead of "+
+			"keeping a conflicted copy, e.g. '*.txt=text,*.json=json'. "+
+			"The strategies are 'text', 'json', 'lines' and 'lww'.")
+	flags.StringVar(&params.DiskCacheSocket, "disk-cache-socket",
+		defaultParams.DiskCacheSocket, "If set along with "+
+			"-disk-cache-mode=remote, delegates disk cache operations to "+
+			"the cache served on this unix socket (e.g., by 'kbfstool "+
+			"disk-cache serve') instead of the local KBFS instance.")
 	flags.BoolVar(&params.EnableJournal, "enable-journal",
 		defaultParams.EnableJournal, "Enables write journaling for TLFs.")
 
@@ -590,6 +645,41 @@ func doInit(
 			return lg
 		}, params.StorageRoot, params.DiskCacheMode, kbCtx)
 
+	if params.CacheEvictionPolicy != "" {
+		policy, err := cache.ParseEvictionPolicy(params.CacheEvictionPolicy)
+		if err != nil {
+			return nil, err
+		}
+		if policy != cache.EvictionPolicyLRU {
+			log.CDebugf(ctx, "Using the %s cache eviction policy", policy)
+			err = config.SetCacheEvictionPolicy(policy)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if params.BandwidthLimits != "" {
+		schedule, err := ParseBandwidthSchedule([]byte(params.BandwidthLimits))
+		if err != nil {
+			return nil, err
+		}
+		log.CDebugf(ctx, "Limiting bandwidth: %+v", schedule)
+		config.BandwidthLimiter().SetSchedule(schedule)
+	}
+
+	if params.ConflictMergeRules != "" {
+		merger, err := ParseConflictMergeRules(params.ConflictMergeRules)
+		if err != nil {
+			return nil, err
+		}
+		config.SetConflictMerger(merger)
+	}
+
+	if params.DiskCacheSocket != "" {
+		config.SetDiskCacheSocket(params.DiskCacheSocket)
+	}
+
 	if params.CleanBlockCacheCapacity > 0 {
 		log.CDebugf(
 			ctx, "overriding default clean block cache capacity from %d to %d",
@@ -703,6 +793,12 @@ func doInit(
 			params.DiskCacheMode.String())
 	}
 
+	err = config.MakeDiskMDCacheIfNotExists()
+	if err != nil {
+		// Without the MD cache, TLFs just won't be available offline.
+		log.CWarningf(ctx, "Could not initialize disk MD cache: %+v", err)
+	}
+
 	if config.Mode().KBFSServiceEnabled() {
 		// Initialize kbfsService only when we run a full KBFS process.
 		// This requires the disk block cache to have been initialized, if it
@@ -736,6 +832,15 @@ func doInit(
 		log.CDebugf(ctx, "Journaling enabled")
 	}
 
+	if params.BlockCacheWarmStartMaxBlocks > 0 {
+		err = config.EnableBlockCacheWarmStart(
+			params.BlockCacheWarmStartMaxBlocks)
+		if err != nil {
+			// A cold block cache is only slower.
+			log.CWarningf(ctx, "Could not warm up the block cache: %+v", err)
+		}
+	}
+
 	if params.BGFlushDirOpBatchSize < 1 {
 		return nil, fmt.Errorf(
 			"Illegal sync batch size: %d", params.BGFlushDirOpBatchSize)
@@ -5,6 +5,7 @@
 package libkbfs
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/keybase/client/go/logger"
@@ -54,15 +55,52 @@ func (cg *testClockGetter) TestClock() *TestClock {
 	return cg.clock
 }
 
+type testDiskBlockCacheTlfSettingsGetterSetter struct {
+	settings     map[tlf.ID]DiskBlockCacheTlfSettings
+	favoriteTlfs map[tlf.ID]bool
+}
+
+var _ diskBlockCacheTlfSettingsGetterSetter = (*testDiskBlockCacheTlfSettingsGetterSetter)(nil)
+
+func newTestDiskBlockCacheTlfSettingsGetterSetter() *testDiskBlockCacheTlfSettingsGetterSetter {
+	return &testDiskBlockCacheTlfSettingsGetterSetter{
+		settings:     make(map[tlf.ID]DiskBlockCacheTlfSettings),
+		favoriteTlfs: make(map[tlf.ID]bool),
+	}
+}
+
+func (t *testDiskBlockCacheTlfSettingsGetterSetter) GetDiskBlockCacheTlfSettings(
+	tlfID tlf.ID) DiskBlockCacheTlfSettings {
+	return t.settings[tlfID]
+}
+
+func (t *testDiskBlockCacheTlfSettingsGetterSetter) SetDiskBlockCacheTlfSettings(
+	tlfID tlf.ID, settings DiskBlockCacheTlfSettings) error {
+	t.settings[tlfID] = settings
+	return nil
+}
+
+func (t *testDiskBlockCacheTlfSettingsGetterSetter) IsFavoriteTlf(
+	tlfID tlf.ID) bool {
+	return t.favoriteTlfs[tlfID]
+}
+
+func (t *testDiskBlockCacheTlfSettingsGetterSetter) SetTlfFavoriteState(
+	tlfID tlf.ID, isFavorite bool) {
+	t.favoriteTlfs[tlfID] = isFavorite
+}
+
 type testSyncedTlfGetterSetter struct {
-	syncedTlfs map[tlf.ID]bool
+	syncedTlfs  map[tlf.ID]bool
+	syncedPaths map[tlf.ID][]string
 }
 
 var _ syncedTlfGetterSetter = (*testSyncedTlfGetterSetter)(nil)
 
 func newTestSyncedTlfGetterSetter() *testSyncedTlfGetterSetter {
 	return &testSyncedTlfGetterSetter{
-		syncedTlfs: make(map[tlf.ID]bool),
+		syncedTlfs:  make(map[tlf.ID]bool),
+		syncedPaths: make(map[tlf.ID][]string),
 	}
 }
 
@@ -76,6 +114,36 @@ func (t *testSyncedTlfGetterSetter) SetTlfSyncState(tlfID tlf.ID,
 	return nil
 }
 
+func (t *testSyncedTlfGetterSetter) GetTlfSyncedPaths(tlfID tlf.ID) []string {
+	return t.syncedPaths[tlfID]
+}
+
+func (t *testSyncedTlfGetterSetter) SetTlfPathSyncState(tlfID tlf.ID,
+	path string, isSynced bool) error {
+	var paths []string
+	for _, p := range t.syncedPaths[tlfID] {
+		if p != path {
+			paths = append(paths, p)
+		}
+	}
+	if isSynced {
+		paths = append(paths, path)
+	}
+	t.syncedPaths[tlfID] = paths
+	return nil
+}
+
+func (t *testSyncedTlfGetterSetter) RenameTlfSyncedPaths(tlfID tlf.ID,
+	oldPath, newPath string) error {
+	paths := t.syncedPaths[tlfID]
+	for i, p := range paths {
+		if p == oldPath || strings.HasPrefix(p, oldPath+"/") {
+			paths[i] = newPath + p[len(oldPath):]
+		}
+	}
+	return nil
+}
+
 type testInitModeGetter struct {
 	mode InitModeType
 }
@@ -68,17 +68,60 @@ type diskBlockCacheSetter interface {
 	MakeDiskBlockCacheIfNotExists() error
 }
 
+type diskMDCacheGetter interface {
+	DiskMDCache() DiskMDCache
+}
+
+type diskMDCacheSetter interface {
+	MakeDiskMDCacheIfNotExists() error
+}
+
 type clockGetter interface {
 	Clock() Clock
 }
 
+type metricsRegistryGetter interface {
+	MetricsRegistry() metrics.Registry
+}
+
 type diskLimiterGetter interface {
 	DiskLimiter() DiskLimiter
 }
 
+type bandwidthLimiterGetter interface {
+	BandwidthLimiter() *BandwidthLimiter
+}
+
 type syncedTlfGetterSetter interface {
 	IsSyncedTlf(tlfID tlf.ID) bool
 	SetTlfSyncState(tlfID tlf.ID, isSynced bool) error
+	// GetTlfSyncedPaths returns the directories within the given TLF,
+	// relative to its root, that are synced even though the TLF as a
+	// whole may not be.
+	GetTlfSyncedPaths(tlfID tlf.ID) []string
+	// SetTlfPathSyncState pins or unpins the directory at the given path,
+	// relative to the root of the given TLF, for offline availability.
+	SetTlfPathSyncState(tlfID tlf.ID, path string, isSynced bool) error
+	// RenameTlfSyncedPaths moves the synced paths of the given TLF
+	// that are at or under oldPath to newPath, after the directory at
+	// oldPath has been renamed.
+	RenameTlfSyncedPaths(tlfID tlf.ID, oldPath, newPath string) error
+}
+
+type diskBlockCacheTlfSettingsGetterSetter interface {
+	// GetDiskBlockCacheTlfSettings returns the disk block cache
+	// settings of the given TLF.
+	GetDiskBlockCacheTlfSettings(tlfID tlf.ID) DiskBlockCacheTlfSettings
+	// SetDiskBlockCacheTlfSettings sets, and persists, the disk block
+	// cache settings of the given TLF.
+	SetDiskBlockCacheTlfSettings(
+		tlfID tlf.ID, settings DiskBlockCacheTlfSettings) error
+	// IsFavoriteTlf returns whether the given TLF is known to be one
+	// of the current user's favorites.
+	IsFavoriteTlf(tlfID tlf.ID) bool
+	// SetTlfFavoriteState records whether the given TLF is one of the
+	// current user's favorites.
+	SetTlfFavoriteState(tlfID tlf.ID, isFavorite bool)
 }
 
 type blockRetrieverGetter interface {
@@ -390,9 +433,36 @@ type KBFSOps interface {
 	// for the folder.
 	GetEditHistory(ctx context.Context, folderBranch FolderBranch) (
 		edits TlfWriterEdits, err error)
+	// GetConflicts returns every conflict copy that conflict
+	// resolution has made in the given folder, along with any
+	// decision about which version to keep, in a data structure
+	// that's suitable for encoding directly into JSON.
+	GetConflicts(ctx context.Context, folderBranch FolderBranch) (
+		conflicts TlfConflicts, err error)
+	// ResolveConflict keeps the given winner of the outstanding
+	// conflict copy at conflictPath (relative to the root of the
+	// folder), deletes the other version, and records the decision
+	// in the folder's history.
+	ResolveConflict(ctx context.Context, folderBranch FolderBranch,
+		conflictPath string, winner ConflictWinner) error
+	// GetConflictRenaming returns how conflict resolution names the
+	// conflict copies in the given folder.  A zero ConflictRenaming
+	// means that the ConflictRenamer of the Config is used.
+	GetConflictRenaming(ctx context.Context, folderBranch FolderBranch) (
+		renaming ConflictRenaming, err error)
+	// SetConflictRenaming validates the given ConflictRenaming, and
+	// stores it in the given folder, so that conflict resolution on
+	// any device names the conflict copies in it accordingly.
+	SetConflictRenaming(ctx context.Context, folderBranch FolderBranch,
+		renaming ConflictRenaming) error
 
 	// GetNodeMetadata gets metadata associated with a Node.
 	GetNodeMetadata(ctx context.Context, node Node) (NodeMetadata, error)
+	// GetPrefetchStatus returns the prefetch status of the subtree
+	// under the given Node, including the progress of any prefetch
+	// that's currently in flight for it.
+	GetPrefetchStatus(ctx context.Context, node Node) (
+		NodePrefetchStatus, error)
 
 	// Shutdown is called to clean up any resources associated with
 	// this KBFSOps instance.
@@ -1075,6 +1145,31 @@ type DiskBlockCache interface {
 	Shutdown(ctx context.Context)
 }
 
+// DiskMDCache caches the latest known merged MD head of each TLF on
+// local disk, so that TLFs can still be read while the MD server is
+// unreachable.
+type DiskMDCache interface {
+	// Get gets the cached MD head for the given TLF, along with its
+	// extra metadata if the MD has segregated key bundles.  It
+	// returns a NoSuchMDError if nothing is cached for the TLF.
+	Get(ctx context.Context, tlfID tlf.ID) (
+		rmds *RootMetadataSigned, extra kbfsmd.ExtraMetadata, err error)
+	// Put caches rmds as the MD head of its TLF, unless a head with
+	// the same or a newer revision is already cached.
+	Put(ctx context.Context, rmds *RootMetadataSigned,
+		extra kbfsmd.ExtraMetadata) error
+	// GetIDForHandle returns the ID of the TLF with the given
+	// handle, as recorded by PutIDForHandle.  It returns a
+	// NoSuchTlfIDError if the ID isn't known.
+	GetIDForHandle(ctx context.Context, handle *TlfHandle) (tlf.ID, error)
+	// PutIDForHandle records the ID of the TLF with the given
+	// handle, so it can be looked up while the MD server is
+	// unreachable.
+	PutIDForHandle(ctx context.Context, handle *TlfHandle, id tlf.ID) error
+	// Shutdown cleanly shuts down the disk MD cache.
+	Shutdown(ctx context.Context)
+}
+
 // cryptoPure contains all methods of Crypto that don't depend on
 // implicit state, i.e. they're pure functions of the input.
 type cryptoPure interface {
@@ -1304,6 +1399,10 @@ type Prefetcher interface {
 	// CancelPrefetch notifies the prefetcher that a prefetch should be
 	// canceled.
 	CancelPrefetch(kbfsblock.ID)
+	// Status returns the progress of the prefetch of the subtree
+	// under the given block.  It returns a NoSuchPrefetchError if
+	// that subtree isn't currently being prefetched.
+	Status(ctx context.Context, ptr BlockPointer) (PrefetchProgress, error)
 	// Shutdown shuts down the prefetcher idempotently. Future calls to
 	// the various Prefetch* methods will return io.EOF. The returned channel
 	// allows upstream components to block until all pending prefetches are
@@ -1770,6 +1869,29 @@ type ConflictRenamer interface {
 		string, error)
 }
 
+// ConflictMergeStrategy merges the contents of a file that was
+// written in both branches of a conflict.
+type ConflictMergeStrategy interface {
+	// MergeContents returns the merged contents of the file, or an
+	// error if the two versions can't be merged, in which case the
+	// unmerged version is kept under a name given by the
+	// ConflictRenamer instead.
+	MergeContents(ctx context.Context, in ConflictMergeInput) (
+		[]byte, error)
+}
+
+// ConflictMerger picks how to merge files that were written in both
+// branches of a conflict.  Only files that fit in a single block are
+// ever merged, since merging is meant for small text files like
+// configs and notes; bigger files, and files that were created
+// separately in both branches, always get a conflict copy.
+type ConflictMerger interface {
+	// StrategyForPath returns the strategy to merge the file at the
+	// given path, relative to the root of its TLF, or nil if the
+	// file shouldn't be merged.
+	StrategyForPath(p string) ConflictMergeStrategy
+}
+
 // Tracer maybe adds traces to contexts.
 type Tracer interface {
 	// MaybeStartTrace, if tracing is on, returns a new context
@@ -1860,9 +1982,13 @@ type Config interface {
 	currentSessionGetterGetter
 	diskBlockCacheGetter
 	diskBlockCacheSetter
+	diskMDCacheGetter
+	diskMDCacheSetter
 	clockGetter
 	diskLimiterGetter
+	bandwidthLimiterGetter
 	syncedTlfGetterSetter
+	diskBlockCacheTlfSettingsGetterSetter
 	initModeGetter
 	Tracer
 	KBFSOps() KBFSOps
@@ -1904,6 +2030,8 @@ type Config interface {
 	SetClock(Clock)
 	ConflictRenamer() ConflictRenamer
 	SetConflictRenamer(ConflictRenamer)
+	ConflictMerger() ConflictMerger
+	SetConflictMerger(ConflictMerger)
 	MetadataVersion() kbfsmd.MetadataVer
 	SetMetadataVersion(kbfsmd.MetadataVer)
 	DefaultBlockType() keybase1.BlockType
@@ -0,0 +1,382 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libkbfs
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/keybase/client/go/protocol/keybase1"
+	"github.com/keybase/go-codec/codec"
+	"github.com/keybase/kbfs/ioutil"
+	"github.com/keybase/kbfs/kbfsblock"
+	"github.com/keybase/kbfs/kbfscrypto"
+	"github.com/keybase/kbfs/kbfsmd"
+	"github.com/keybase/kbfs/tlf"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+// journalArchiveVersion is the version of the journal archive
+// format written by ExportTLFJournal.
+const journalArchiveVersion = 1
+
+// journalArchiveBlockOp is a block journal operation, along with the
+// block data and server half for blockPutOps.
+type journalArchiveBlockOp struct {
+	Op         blockOpType                        `codec:"o"`
+	Contexts   kbfsblock.ContextMap               `codec:"c"`
+	Data       []byte                             `codec:"d,omitempty"`
+	ServerHalf kbfscrypto.BlockCryptKeyServerHalf `codec:"s"`
+
+	codec.UnknownFieldSetHandler
+}
+
+// journalArchiveMD is an unflushed MD from the journal, along with
+// the block operations that came before its revision marker in the
+// block journal.
+type journalArchiveMD struct {
+	BlockOps []journalArchiveBlockOp `codec:"b"`
+	Version  kbfsmd.MetadataVer      `codec:"v"`
+	// MD is the encoded bare MD, as it's stored in the journal.
+	MD []byte `codec:"md"`
+	// The key bundles are only set for v3 MDs.
+	WriterKeyBundle *kbfsmd.TLFWriterKeyBundleV3 `codec:"wkb,omitempty"`
+	ReaderKeyBundle *kbfsmd.TLFReaderKeyBundleV3 `codec:"rkb,omitempty"`
+
+	codec.UnknownFieldSetHandler
+}
+
+// journalArchive holds the unflushed contents of a TLF journal, in
+// revision order.
+type journalArchive struct {
+	Version int                `codec:"v"`
+	UID     keybase1.UID       `codec:"u"`
+	TlfID   tlf.ID             `codec:"t"`
+	MDs     []journalArchiveMD `codec:"m"`
+
+	codec.UnknownFieldSetHandler
+}
+
+// signedJournalArchive is an encoded journalArchive, signed by the
+// device that exported it.
+type signedJournalArchive struct {
+	Archive []byte                   `codec:"a"`
+	SigInfo kbfscrypto.SignatureInfo `codec:"s"`
+
+	codec.UnknownFieldSetHandler
+}
+
+func (op journalArchiveBlockOp) replay(
+	ctx context.Context, tj *tlfJournal) error {
+	switch op.Op {
+	case blockPutOp, addRefOp:
+		entry := blockJournalEntry{Op: op.Op, Contexts: op.Contexts}
+		id, context, err := entry.getSingleContext()
+		if err != nil {
+			return err
+		}
+		if op.Op == blockPutOp {
+			return tj.putBlockData(ctx, id, context, op.Data, op.ServerHalf)
+		}
+		return tj.addBlockReference(ctx, id, context)
+	case archiveRefsOp:
+		return tj.archiveBlockReferences(ctx, op.Contexts)
+	default:
+		return errors.Errorf("Can't import block op %s", op.Op)
+	}
+}
+
+// ExportTLFJournal writes the unflushed revisions in the journal for
+// the given TLF, along with the blocks they need, to w as an archive
+// signed by this device.  The archive can be imported with
+// ImportTLFJournal by another device of the same user.  It returns
+// the number of exported revisions.
+func (j *JournalServer) ExportTLFJournal(
+	ctx context.Context, tlfID tlf.ID, w io.Writer) (
+	numExported int, err error) {
+	j.log.CDebugf(ctx, "Exporting journal for %s", tlfID)
+	defer func() {
+		if err != nil {
+			j.deferLog.CDebugf(ctx,
+				"Error when exporting journal for %s: %+v", tlfID, err)
+		}
+	}()
+
+	tj, ok := j.getTLFJournal(tlfID, nil)
+	if !ok {
+		return 0, errors.Errorf("Journal not enabled for %s", tlfID)
+	}
+
+	archive, err := tj.getArchive(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	buf, err := j.config.Codec().Encode(archive)
+	if err != nil {
+		return 0, err
+	}
+	sigInfo, err := j.config.Crypto().Sign(ctx, buf)
+	if err != nil {
+		return 0, err
+	}
+	signedBuf, err := j.config.Codec().Encode(signedJournalArchive{
+		Archive: buf,
+		SigInfo: sigInfo,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = w.Write(signedBuf)
+	if err != nil {
+		return 0, err
+	}
+	return len(archive.MDs), nil
+}
+
+// readJournalArchive reads and verifies a journal archive, and
+// returns it along with the verifying key of the device that
+// exported it.
+func (j *JournalServer) readJournalArchive(
+	ctx context.Context, r io.Reader) (
+	journalArchive, kbfscrypto.VerifyingKey, error) {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return journalArchive{}, kbfscrypto.VerifyingKey{}, err
+	}
+	var signed signedJournalArchive
+	err = j.config.Codec().Decode(buf, &signed)
+	if err != nil {
+		return journalArchive{}, kbfscrypto.VerifyingKey{}, err
+	}
+	err = kbfscrypto.Verify(signed.Archive, signed.SigInfo)
+	if err != nil {
+		return journalArchive{}, kbfscrypto.VerifyingKey{}, err
+	}
+
+	var archive journalArchive
+	err = j.config.Codec().Decode(signed.Archive, &archive)
+	if err != nil {
+		return journalArchive{}, kbfscrypto.VerifyingKey{}, err
+	}
+	if archive.Version != journalArchiveVersion {
+		return journalArchive{}, kbfscrypto.VerifyingKey{}, errors.Errorf(
+			"Unsupported journal archive version %d", archive.Version)
+	}
+
+	// Only another device of the current user can contribute
+	// changes through this device's journal.
+	session, err := j.config.KBPKI().GetCurrentSession(ctx)
+	if err != nil {
+		return journalArchive{}, kbfscrypto.VerifyingKey{}, err
+	}
+	key := signed.SigInfo.VerifyingKey
+	if archive.UID != session.UID {
+		return journalArchive{}, kbfscrypto.VerifyingKey{}, errors.Errorf(
+			"The archive was exported by %s, not by %s",
+			archive.UID, session.UID)
+	}
+	if key == session.VerifyingKey {
+		return journalArchive{}, kbfscrypto.VerifyingKey{}, errors.New(
+			"The archive was exported by this device")
+	}
+	err = j.config.KBPKI().HasVerifyingKey(
+		ctx, session.UID, key, j.config.Clock().Now())
+	if err != nil {
+		return journalArchive{}, kbfscrypto.VerifyingKey{}, err
+	}
+	return archive, key, nil
+}
+
+type journalArchiveImportMD struct {
+	brmd     kbfsmd.MutableRootMetadata
+	extra    kbfsmd.ExtraMetadata
+	blockOps []journalArchiveBlockOp
+}
+
+// decodeJournalArchiveMDs decodes the MDs in the archive, and checks
+// that they're signed by the given key and form a chain.
+func (j *JournalServer) decodeJournalArchiveMDs(ctx context.Context,
+	archive journalArchive, key kbfscrypto.VerifyingKey) (
+	[]journalArchiveImportMD, error) {
+	codec := j.config.Codec()
+	mds := make([]journalArchiveImportMD, 0, len(archive.MDs))
+	for i, amd := range archive.MDs {
+		brmd, err := kbfsmd.DecodeRootMetadata(codec, archive.TlfID,
+			amd.Version, j.config.MetadataVersion(), amd.MD)
+		if err != nil {
+			return nil, err
+		}
+		var extra kbfsmd.ExtraMetadata
+		if amd.WriterKeyBundle != nil && amd.ReaderKeyBundle != nil {
+			extra = kbfsmd.NewExtraMetadataV3(
+				*amd.WriterKeyBundle, *amd.ReaderKeyBundle, false, false)
+		}
+
+		rev := brmd.RevisionNumber()
+		if brmd.TlfID() != archive.TlfID {
+			return nil, errors.Errorf("Revision %d is for %s, not %s",
+				rev, brmd.TlfID(), archive.TlfID)
+		}
+		if brmd.MergedStatus() != kbfsmd.Merged ||
+			brmd.IsWriterMetadataCopiedSet() {
+			return nil, errors.Errorf("Can't import revision %d; only "+
+				"merged, non-rekey revisions can be imported", rev)
+		}
+		err = brmd.IsLastModifiedBy(archive.UID, key)
+		if err != nil {
+			return nil, err
+		}
+		err = brmd.IsValidAndSigned(
+			ctx, codec, j.config.KBPKI(), extra, key)
+		if err != nil {
+			return nil, err
+		}
+
+		if i > 0 {
+			prev := mds[i-1].brmd
+			prevID, err := kbfsmd.MakeID(codec, prev)
+			if err != nil {
+				return nil, err
+			}
+			err = prev.CheckValidSuccessor(prevID, brmd)
+			if err != nil {
+				return nil, errors.Errorf("Revision %d doesn't follow "+
+					"revision %d in the archive: %+v",
+					rev, prev.RevisionNumber(), err)
+			}
+		}
+
+		mds = append(mds, journalArchiveImportMD{brmd, extra, amd.BlockOps})
+	}
+	if len(mds) == 0 {
+		return nil, errors.New("The archive has no revisions")
+	}
+	return mds, nil
+}
+
+// ImportTLFJournal replays an archive written by ExportTLFJournal on
+// another device of the same user into the journal of this device,
+// so that its revisions are flushed from here.  The revisions must
+// continue from the current head of the TLF on the server, and the
+// journal of this device for the TLF must not have any unflushed
+// changes of its own.  Revisions that were already imported and
+// flushed by an earlier import of the same (or an older) archive are
+// skipped, so the archive of a device that keeps on writing offline
+// can be imported over and over again.  If the journal for the TLF
+// isn't enabled yet, it's enabled with the given background work
+// status.  It returns the TLF of the archive and the number of
+// imported revisions.
+func (j *JournalServer) ImportTLFJournal(ctx context.Context, r io.Reader,
+	bws TLFJournalBackgroundWorkStatus) (
+	tlfID tlf.ID, numImported int, err error) {
+	j.log.CDebugf(ctx, "Importing a journal archive")
+	defer func() {
+		if err != nil {
+			j.deferLog.CDebugf(ctx,
+				"Error when importing a journal archive: %+v", err)
+		}
+	}()
+
+	archive, key, err := j.readJournalArchive(ctx, r)
+	if err != nil {
+		return tlf.ID{}, 0, err
+	}
+	tlfID = archive.TlfID
+	mds, err := j.decodeJournalArchiveMDs(ctx, archive, key)
+	if err != nil {
+		return tlf.ID{}, 0, err
+	}
+
+	head, err := j.delegateMDOps.GetForTLF(ctx, tlfID, nil)
+	if err != nil {
+		return tlf.ID{}, 0, err
+	}
+	if head == (ImmutableRootMetadata{}) {
+		return tlf.ID{}, 0, errors.Errorf(
+			"%s doesn't have any revisions on the server yet", tlfID)
+	}
+
+	// Skip the revisions that are already on the server.  Since
+	// importing only re-signs the writer metadata, the encrypted
+	// private metadata of those stays the same.
+	first := 0
+	firstRev := mds[0].brmd.RevisionNumber()
+	if firstRev <= head.Revision() {
+		lastRev := mds[len(mds)-1].brmd.RevisionNumber()
+		if lastRev > head.Revision() {
+			lastRev = head.Revision()
+		}
+		irmds, err := j.delegateMDOps.GetRange(
+			ctx, tlfID, firstRev, lastRev, nil)
+		if err != nil {
+			return tlf.ID{}, 0, err
+		}
+		if len(irmds) != int(lastRev-firstRev)+1 {
+			return tlf.ID{}, 0, errors.Errorf(
+				"Couldn't get revisions %d to %d of %s from the server",
+				firstRev, lastRev, tlfID)
+		}
+		for i, irmd := range irmds {
+			if !bytes.Equal(irmd.GetSerializedPrivateMetadata(),
+				mds[i].brmd.GetSerializedPrivateMetadata()) {
+				return tlf.ID{}, 0, errors.Errorf(
+					"Revision %d in the archive conflicts with the one "+
+						"on the server", irmd.Revision())
+			}
+		}
+		first = len(irmds)
+	}
+	if first == len(mds) {
+		j.log.CDebugf(ctx, "All revisions were already imported")
+		return tlfID, 0, nil
+	}
+
+	next := mds[first].brmd
+	if next.RevisionNumber() != head.Revision()+1 ||
+		(first == 0 && next.GetPrevRoot() != head.MdID()) {
+		return tlf.ID{}, 0, errors.Errorf(
+			"The archive continues from revision %d, but %s is at "+
+				"revision %d (%s) on the server",
+			next.RevisionNumber()-1, tlfID, head.Revision(), head.MdID())
+	}
+
+	err = j.Enable(ctx, tlfID, head.GetTlfHandle(), bws)
+	if err != nil {
+		return tlf.ID{}, 0, err
+	}
+	tj, ok := j.getTLFJournal(tlfID, nil)
+	if !ok {
+		return tlf.ID{}, 0, errors.Errorf("Journal not enabled for %s", tlfID)
+	}
+	status, err := tj.getJournalStatus()
+	if err != nil {
+		return tlf.ID{}, 0, err
+	}
+	if status.RevisionEnd != kbfsmd.RevisionUninitialized ||
+		status.BlockOpCount != 0 {
+		return tlf.ID{}, 0, errors.Errorf(
+			"The journal for %s has unflushed changes; flush them "+
+				"before importing", tlfID)
+	}
+
+	prevRoot := head.MdID()
+	for _, md := range mds[first:] {
+		for _, op := range md.blockOps {
+			err = op.replay(ctx, tj)
+			if err != nil {
+				return tlf.ID{}, 0, err
+			}
+		}
+		prevRoot, err = tj.importMD(ctx, md.brmd, md.extra, prevRoot)
+		if err != nil {
+			return tlf.ID{}, 0, err
+		}
+		numImported++
+	}
+	return tlfID, numImported, nil
+}
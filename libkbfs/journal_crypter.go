@@ -0,0 +1,262 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libkbfs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/keybase/kbfs/ioutil"
+	"github.com/keybase/kbfs/kbfscodec"
+	"github.com/keybase/kbfs/kbfscrypto"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+// journalCryptKeyLabel is the message signed by the device key to
+// derive the key for the journal metadata files of the device.  It
+// must never change, or existing journals become unreadable.
+const journalCryptKeyLabel = "Derive KBFS journal metadata crypt key v1"
+
+// makeJournalCryptKey derives the key used to encrypt the journal
+// metadata files of the current device.  Signatures made by the
+// device key are deterministic, and this message is never signed for
+// anything else, so the same key comes out every time without ever
+// having to be stored.
+func makeJournalCryptKey(ctx context.Context, signer kbfscrypto.Signer) (
+	kbfscrypto.JournalCryptKey, error) {
+	sigInfo, err := signer.SignForKBFS(ctx, []byte(journalCryptKeyLabel))
+	if err != nil {
+		return kbfscrypto.JournalCryptKey{}, err
+	}
+	if len(sigInfo.Signature) == 0 {
+		return kbfscrypto.JournalCryptKey{}, errors.New(
+			"Empty signature when deriving journal crypt key")
+	}
+	return kbfscrypto.MakeJournalCryptKey(sha256.Sum256(sigInfo.Signature)), nil
+}
+
+// journalCrypterMagic starts every encrypted journal metadata file.
+// 0xc1 is never used in msgpack, and it can't start a JSON document
+// either, so an encrypted file can't be mistaken for a plaintext one
+// (and vice versa).
+var journalCrypterMagic = []byte("\xc1kbfsjournal")
+
+// journalCrypter reads and writes the metadata files of a TLF journal
+// (the diskJournal entries, the MDs and key bundles of the mdJournal,
+// and the journal info file), encrypting them at rest with a key
+// local to the device.  Block data is already encrypted, so it
+// doesn't go through here.
+//
+// A nil *journalCrypter reads and writes plaintext files, and fails
+// to read encrypted ones.
+//
+// allowPlaintext is only changed while the journal is being opened,
+// before it's shared with any other goroutine.
+type journalCrypter struct {
+	codec kbfscodec.Codec
+	key   kbfscrypto.JournalCryptKey
+	// allowPlaintext is set while plaintext files from before
+	// encryption was turned on are being migrated, which is only
+	// done for journals that haven't recorded that they're
+	// encrypted (see isTLFJournalEncrypted).  Otherwise plaintext
+	// files are rejected, so that they can't be planted by someone
+	// with access to the disk.
+	allowPlaintext bool
+}
+
+func newJournalCrypter(
+	codec kbfscodec.Codec, key kbfscrypto.JournalCryptKey) *journalCrypter {
+	if key == (kbfscrypto.JournalCryptKey{}) {
+		return nil
+	}
+	return &journalCrypter{codec: codec, key: key}
+}
+
+func isEncryptedJournalFile(buf []byte) bool {
+	return bytes.HasPrefix(buf, journalCrypterMagic)
+}
+
+func (c *journalCrypter) seal(data []byte) ([]byte, error) {
+	if c == nil {
+		return data, nil
+	}
+	encryptedData, err := kbfscrypto.EncryptJournalData(data, c.key)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := c.codec.Encode(encryptedData)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte(nil), journalCrypterMagic...), encoded...), nil
+}
+
+func (c *journalCrypter) open(path string, buf []byte) ([]byte, error) {
+	if !isEncryptedJournalFile(buf) {
+		if c != nil && !c.allowPlaintext {
+			return nil, errors.Errorf(
+				"Journal file %q is unexpectedly not encrypted", path)
+		}
+		return buf, nil
+	}
+	if c == nil {
+		return nil, errors.Errorf(
+			"Journal file %q is encrypted, but there's no key", path)
+	}
+	var encryptedData kbfscrypto.EncryptedJournalData
+	err := c.codec.Decode(buf[len(journalCrypterMagic):], &encryptedData)
+	if err != nil {
+		return nil, err
+	}
+	data, err := kbfscrypto.DecryptJournalData(encryptedData, c.key)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decrypt %q", path)
+	}
+	return data, nil
+}
+
+// readFile reads the given file, decrypting it if necessary.  It may
+// return an error for which ioutil.IsNotExist() returns true.
+func (c *journalCrypter) readFile(path string) ([]byte, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return c.open(path, buf)
+}
+
+// writeFile encrypts the given data if necessary, and writes it to
+// the given file, making its parent directory first if necessary.
+func (c *journalCrypter) writeFile(path string, data []byte) error {
+	err := ioutil.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+
+	buf, err := c.seal(data)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteSerializedFile(path, buf, 0600)
+}
+
+// serializeToFile is like kbfscodec.SerializeToFile, but encrypts the
+// file if necessary.
+func (c *journalCrypter) serializeToFile(
+	codec kbfscodec.Codec, obj interface{}, path string) error {
+	buf, err := codec.Encode(obj)
+	if err != nil {
+		return err
+	}
+
+	return c.writeFile(path, buf)
+}
+
+// serializeToFileIfNotExist is like
+// kbfscodec.SerializeToFileIfNotExist, but encrypts the file if
+// necessary.
+func (c *journalCrypter) serializeToFileIfNotExist(
+	codec kbfscodec.Codec, obj interface{}, path string) error {
+	_, err := ioutil.Stat(path)
+	if ioutil.IsExist(err) {
+		return nil
+	} else if ioutil.IsNotExist(err) {
+		// Continue.
+	} else if err != nil {
+		return err
+	}
+
+	return c.serializeToFile(codec, obj, path)
+}
+
+// deserializeFromFile is like kbfscodec.DeserializeFromFile, but
+// decrypts the file if necessary.
+func (c *journalCrypter) deserializeFromFile(
+	codec kbfscodec.Codec, path string, objPtr interface{}) error {
+	data, err := c.readFile(path)
+	if err != nil {
+		return err
+	}
+
+	return codec.Decode(data, objPtr)
+}
+
+// serializeToJSONFile is like ioutil.SerializeToJSONFile, but
+// encrypts the file if necessary.
+func (c *journalCrypter) serializeToJSONFile(
+	obj interface{}, path string) error {
+	buf, err := json.Marshal(obj)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal %q as JSON", path)
+	}
+
+	return c.writeFile(path, buf)
+}
+
+// deserializeFromJSONFile is like ioutil.DeserializeFromJSONFile, but
+// decrypts the file if necessary.
+func (c *journalCrypter) deserializeFromJSONFile(
+	path string, objPtr interface{}) error {
+	data, err := c.readFile(path)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(data, objPtr)
+	if err != nil {
+		return errors.Wrapf(err, "failed to unmarshal %q as JSON", path)
+	}
+
+	return nil
+}
+
+// migrateFile encrypts the given file in place, if it isn't already.
+// It does nothing if the file doesn't exist.
+func (c *journalCrypter) migrateFile(path string) error {
+	if c == nil {
+		return nil
+	}
+
+	buf, err := ioutil.ReadFile(path)
+	if ioutil.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if isEncryptedJournalFile(buf) {
+		return nil
+	}
+
+	return c.writeFile(path, buf)
+}
+
+// migrateDir encrypts every file under the given directory in place,
+// if it isn't already.  It does nothing if the directory doesn't
+// exist.
+func (c *journalCrypter) migrateDir(dir string) error {
+	if c == nil {
+		return nil
+	}
+
+	err := filepath.Walk(dir, func(
+		path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		return c.migrateFile(path)
+	})
+	if ioutil.IsNotExist(err) {
+		return nil
+	}
+	return errors.WithStack(err)
+}
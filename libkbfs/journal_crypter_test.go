@@ -0,0 +1,336 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libkbfs
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/keybase/client/go/protocol/keybase1"
+	"github.com/keybase/kbfs/ioutil"
+	"github.com/keybase/kbfs/kbfsblock"
+	"github.com/keybase/kbfs/kbfscodec"
+	"github.com/keybase/kbfs/kbfscrypto"
+	"github.com/keybase/kbfs/kbfsmd"
+	"github.com/keybase/kbfs/tlf"
+	"github.com/stretchr/testify/require"
+)
+
+// testJournalCryptKeys are the keys that the journal tests are run
+// with: an empty one, which leaves the metadata files in plaintext,
+// and a real one.
+var testJournalCryptKeys = []struct {
+	name string
+	key  kbfscrypto.JournalCryptKey
+}{
+	{"Plaintext", kbfscrypto.JournalCryptKey{}},
+	{"Encrypted", kbfscrypto.MakeJournalCryptKey([32]byte{0x3})},
+}
+
+// runJournalTestsOverMetadataVers is like runTestsOverMetadataVers,
+// but also runs each test with every key in testJournalCryptKeys.
+func runJournalTestsOverMetadataVers(t *testing.T, prefix string,
+	fs []func(t *testing.T, ver kbfsmd.MetadataVer,
+		cryptKey kbfscrypto.JournalCryptKey)) {
+	for _, f := range fs {
+		f := f // capture range variable.
+		t.Run(testFuncSubtestName(f, prefix), func(t *testing.T) {
+			for _, k := range testJournalCryptKeys {
+				k := k // capture range variable.
+				t.Run(k.name, func(t *testing.T) {
+					runTestOverMetadataVers(t,
+						func(t *testing.T, ver kbfsmd.MetadataVer) {
+							f(t, ver, k.key)
+						})
+				})
+			}
+		})
+	}
+}
+
+type testJournalCrypterEntry struct {
+	Name string
+}
+
+func TestJournalCrypter(t *testing.T) {
+	tempdir, err := ioutil.TempDir(os.TempDir(), "journal_crypter")
+	require.NoError(t, err)
+	defer func() {
+		err := ioutil.RemoveAll(tempdir)
+		require.NoError(t, err)
+	}()
+
+	codec := kbfscodec.NewMsgpack()
+	crypter := newJournalCrypter(
+		codec, kbfscrypto.MakeJournalCryptKey([32]byte{0x1}))
+	entry := testJournalCrypterEntry{"secret_file_name.txt"}
+
+	p := filepath.Join(tempdir, "dir", "entry")
+	err = crypter.serializeToFile(codec, entry, p)
+	require.NoError(t, err)
+	buf, err := ioutil.ReadFile(p)
+	require.NoError(t, err)
+	require.True(t, isEncryptedJournalFile(buf))
+	require.False(t, bytes.Contains(buf, []byte(entry.Name)))
+
+	var readEntry testJournalCrypterEntry
+	err = crypter.deserializeFromFile(codec, p, &readEntry)
+	require.NoError(t, err)
+	require.Equal(t, entry, readEntry)
+
+	t.Log("Encrypted files can't be read without the key, " +
+		"or with the wrong key.")
+	var nilCrypter *journalCrypter
+	err = nilCrypter.deserializeFromFile(codec, p, &readEntry)
+	require.Error(t, err)
+	otherCrypter := newJournalCrypter(
+		codec, kbfscrypto.MakeJournalCryptKey([32]byte{0x2}))
+	err = otherCrypter.deserializeFromFile(codec, p, &readEntry)
+	require.Error(t, err)
+
+	t.Log("Plaintext files are only read while migrating.")
+	jsonPath := filepath.Join(tempdir, "info.json")
+	err = nilCrypter.serializeToJSONFile(entry, jsonPath)
+	require.NoError(t, err)
+	err = crypter.deserializeFromJSONFile(jsonPath, &readEntry)
+	require.Error(t, err)
+	crypter.allowPlaintext = true
+	readEntry = testJournalCrypterEntry{}
+	err = crypter.deserializeFromJSONFile(jsonPath, &readEntry)
+	require.NoError(t, err)
+	require.Equal(t, entry, readEntry)
+
+	err = crypter.migrateDir(tempdir)
+	require.NoError(t, err)
+	crypter.allowPlaintext = false
+	buf, err = ioutil.ReadFile(jsonPath)
+	require.NoError(t, err)
+	require.True(t, isEncryptedJournalFile(buf))
+	readEntry = testJournalCrypterEntry{}
+	err = crypter.deserializeFromJSONFile(jsonPath, &readEntry)
+	require.NoError(t, err)
+	require.Equal(t, entry, readEntry)
+}
+
+// journalMetadataFiles returns the files in the given TLF journal
+// directory that should be encrypted.
+func journalMetadataFiles(t *testing.T, dir string) []string {
+	files := []string{getTLFJournalInfoFilePath(dir)}
+	for _, subdir := range []string{
+		blockJournalDir(dir), deferredGCBlockJournalDir(dir),
+		mdJournalPath(dir), filepath.Join(dir, "mds"),
+		filepath.Join(dir, "wkbv3"), filepath.Join(dir, "rkbv3"),
+	} {
+		err := filepath.Walk(subdir, func(
+			path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.Mode().IsRegular() || info.Name() == "EARLIEST" ||
+				info.Name() == "LATEST" {
+				return nil
+			}
+			files = append(files, path)
+			return nil
+		})
+		if !ioutil.IsNotExist(err) {
+			require.NoError(t, err)
+		}
+	}
+	return files
+}
+
+// testJournalServerEncryptedMetadata checks that a journal from
+// before metadata files were encrypted gets migrated when it's
+// opened.  If crashedMidMigration is set, the journal is left like
+// one where an earlier migration crashed partway through, with only
+// some of its metadata files encrypted.
+func testJournalServerEncryptedMetadata(
+	t *testing.T, crashedMidMigration bool) {
+	tempdir, ctx, cancel, config, _, jServer := setupJournalServerTest(t)
+	defer teardownJournalServerTest(t, tempdir, ctx, cancel, config)
+
+	// Use a shutdown-only BlockServer so that it errors if the
+	// journal tries to access it.
+	jServer.delegateBlockServer = shutdownOnlyBlockServer{}
+
+	tlfID := tlf.FakeID(2, tlf.Private)
+	err := jServer.Enable(ctx, tlfID, nil, TLFJournalBackgroundWorkPaused)
+	require.NoError(t, err)
+
+	blockServer := config.BlockServer()
+	mdOps := config.MDOps()
+
+	h, err := ParseTlfHandle(
+		ctx, config.KBPKI(), config.MDOps(), "test_user1", tlf.Private)
+	require.NoError(t, err)
+	id := h.ResolvedWriters()[0]
+
+	bCtx := kbfsblock.MakeFirstContext(id, keybase1.BlockType_DATA)
+	data := []byte{1, 2, 3, 4}
+	bID, err := kbfsblock.MakePermanentID(data)
+	require.NoError(t, err)
+	serverHalf, err := kbfscrypto.MakeRandomBlockCryptKeyServerHalf()
+	require.NoError(t, err)
+	err = blockServer.Put(ctx, tlfID, bID, bCtx, data, serverHalf)
+	require.NoError(t, err)
+
+	rmd, err := makeInitialRootMetadata(config.MetadataVersion(), tlfID, h)
+	require.NoError(t, err)
+	rekeyDone, _, err := config.KeyManager().Rekey(ctx, rmd, false)
+	require.NoError(t, err)
+	require.True(t, rekeyDone)
+
+	session, err := config.KBPKI().GetCurrentSession(ctx)
+	require.NoError(t, err)
+
+	_, err = mdOps.Put(ctx, rmd, session.VerifyingKey,
+		nil, keybase1.MDPriorityNormal)
+	require.NoError(t, err)
+
+	requireEncrypted := func(dir string) {
+		files := journalMetadataFiles(t, dir)
+		// The info file, a block entry, an MD entry, and the
+		// MD data and info.
+		require.True(t, len(files) >= 5, "files=%v", files)
+		for _, p := range files {
+			buf, err := ioutil.ReadFile(p)
+			require.NoError(t, err)
+			require.True(t, isEncryptedJournalFile(buf),
+				"%s isn't encrypted", p)
+		}
+	}
+
+	dir := jServer.tlfJournalPathLocked(tlfID)
+	requireEncrypted(dir)
+
+	// Turn the journal back into one from before metadata files
+	// were encrypted.  The info file is always migrated last, so it's
+	// in plaintext even if the migration crashed, and the marker
+	// saying that the journal is encrypted is only written after
+	// that.
+	crypter := newJournalCrypter(config.Codec(), jServer.currentCryptKey)
+	jServer.shutdownExistingJournals(ctx)
+	markerPath := getTLFJournalEncryptedMarkerPath(dir)
+	err = ioutil.RemoveAll(markerPath)
+	require.NoError(t, err)
+	for i, p := range journalMetadataFiles(t, dir) {
+		if crashedMidMigration && i%2 == 1 {
+			continue
+		}
+		buf, err := ioutil.ReadFile(p)
+		require.NoError(t, err)
+		plaintext, err := crypter.open(p, buf)
+		require.NoError(t, err)
+		err = ioutil.WriteSerializedFile(p, plaintext, 0600)
+		require.NoError(t, err)
+	}
+
+	// Restart, which should encrypt everything again.
+
+	jServer = makeJournalServer(
+		config, jServer.log, tempdir, jServer.delegateBlockCache,
+		jServer.delegateDirtyBlockCache,
+		jServer.delegateBlockServer, jServer.delegateMDOps, nil, nil)
+	err = jServer.EnableExistingJournals(
+		ctx, session.UID, session.VerifyingKey, TLFJournalBackgroundWorkPaused)
+	require.NoError(t, err)
+	config.SetBlockCache(jServer.blockCache())
+	config.SetBlockServer(jServer.blockServer())
+	config.SetMDOps(jServer.mdOps())
+
+	requireEncrypted(dir)
+	buf, err := ioutil.ReadFile(markerPath)
+	require.NoError(t, err)
+	require.True(t, isEncryptedJournalFile(buf))
+
+	blockServer = config.BlockServer()
+	mdOps = config.MDOps()
+	buf, key, err := blockServer.Get(ctx, tlfID, bID, bCtx)
+	require.NoError(t, err)
+	require.Equal(t, data, buf)
+	require.Equal(t, serverHalf, key)
+
+	head, err := mdOps.GetForTLF(ctx, tlfID, nil)
+	require.NoError(t, err)
+	require.Equal(t, rmd.Revision(), head.Revision())
+}
+
+func TestJournalServerEncryptedMetadata(t *testing.T) {
+	testJournalServerEncryptedMetadata(t, false)
+}
+
+func TestJournalServerEncryptedMetadataCrashMidMigration(t *testing.T) {
+	testJournalServerEncryptedMetadata(t, true)
+}
+
+// TestJournalServerRejectsPlaintextAfterMigration checks that once a
+// journal has recorded that its metadata files are encrypted, a
+// plaintext info file planted in it isn't accepted, and so can't put
+// the journal back into migration.
+func TestJournalServerRejectsPlaintextAfterMigration(t *testing.T) {
+	tempdir, ctx, cancel, config, _, jServer := setupJournalServerTest(t)
+	defer teardownJournalServerTest(t, tempdir, ctx, cancel, config)
+
+	tlfID := tlf.FakeID(2, tlf.Private)
+	err := jServer.Enable(ctx, tlfID, nil, TLFJournalBackgroundWorkPaused)
+	require.NoError(t, err)
+
+	session, err := config.KBPKI().GetCurrentSession(ctx)
+	require.NoError(t, err)
+
+	dir := jServer.tlfJournalPathLocked(tlfID)
+	crypter := newJournalCrypter(config.Codec(), jServer.currentCryptKey)
+	markerPath := getTLFJournalEncryptedMarkerPath(dir)
+	buf, err := ioutil.ReadFile(markerPath)
+	require.NoError(t, err)
+	require.True(t, isEncryptedJournalFile(buf))
+	encrypted, err := isTLFJournalEncrypted(crypter, dir)
+	require.NoError(t, err)
+	require.True(t, encrypted)
+
+	t.Log("Plant a plaintext info file, like one from before metadata " +
+		"files were encrypted.")
+	jServer.shutdownExistingJournals(ctx)
+	err = writeTLFJournalInfoFile(nil, dir, session.UID,
+		session.VerifyingKey, tlfID, session.UID.AsUserOrTeam())
+	require.NoError(t, err)
+
+	needsEncryption, err := tlfJournalNeedsEncryption(crypter, dir)
+	require.NoError(t, err)
+	require.False(t, needsEncryption)
+	_, _, _, _, err = readTLFJournalInfoFile(crypter, dir)
+	require.Error(t, err)
+
+	t.Log("A plaintext marker isn't accepted either.")
+	err = ioutil.RemoveAll(markerPath)
+	require.NoError(t, err)
+	err = (*journalCrypter)(nil).serializeToJSONFile(
+		tlfJournalEncryptedMarker{tlfID}, markerPath)
+	require.NoError(t, err)
+	_, err = isTLFJournalEncrypted(crypter, dir)
+	require.Error(t, err)
+	_, err = tlfJournalNeedsEncryption(crypter, dir)
+	require.Error(t, err)
+	err = ioutil.WriteSerializedFile(markerPath, buf, 0600)
+	require.NoError(t, err)
+
+	t.Log("The journal with the planted info file isn't enabled " +
+		"on restart.")
+	jServer = makeJournalServer(
+		config, jServer.log, tempdir, jServer.delegateBlockCache,
+		jServer.delegateDirtyBlockCache,
+		jServer.delegateBlockServer, jServer.delegateMDOps, nil, nil)
+	err = jServer.EnableExistingJournals(
+		ctx, session.UID, session.VerifyingKey, TLFJournalBackgroundWorkPaused)
+	require.NoError(t, err)
+	config.SetBlockCache(jServer.blockCache())
+	config.SetBlockServer(jServer.blockServer())
+	config.SetMDOps(jServer.mdOps())
+	_, err = jServer.JournalStatus(tlfID)
+	require.Error(t, err)
+}
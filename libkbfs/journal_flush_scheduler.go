@@ -0,0 +1,392 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libkbfs
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/keybase/kbfs/tlf"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+// JournalFlushPriority is the priority of the journal of a TLF when
+// it competes with the journals of other TLFs for flush bandwidth.
+// The zero value is JournalFlushPriorityNormal.
+type JournalFlushPriority int
+
+const (
+	// JournalFlushPriorityLow is for TLFs whose changes can wait,
+	// like big bulk copies.
+	JournalFlushPriorityLow JournalFlushPriority = -1
+	// JournalFlushPriorityNormal is the default priority.
+	JournalFlushPriorityNormal JournalFlushPriority = 0
+	// JournalFlushPriorityHigh is for TLFs whose changes should
+	// reach the server as soon as possible.
+	JournalFlushPriorityHigh JournalFlushPriority = 1
+)
+
+func (p JournalFlushPriority) String() string {
+	switch p {
+	case JournalFlushPriorityLow:
+		return "low"
+	case JournalFlushPriorityNormal:
+		return "normal"
+	case JournalFlushPriorityHigh:
+		return "high"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseJournalFlushPriority parses the string form of a
+// JournalFlushPriority.
+func ParseJournalFlushPriority(s string) (JournalFlushPriority, error) {
+	s = strings.TrimSpace(s)
+	for _, p := range []JournalFlushPriority{
+		JournalFlushPriorityLow, JournalFlushPriorityNormal,
+		JournalFlushPriorityHigh,
+	} {
+		if p.String() == s {
+			return p, nil
+		}
+	}
+	return 0, errors.Errorf(
+		"Unknown journal flush priority %q (want low, normal or high)", s)
+}
+
+// MarshalText implements the encoding.TextMarshaler interface for
+// JournalFlushPriority.
+func (p JournalFlushPriority) MarshalText() ([]byte, error) {
+	if p < JournalFlushPriorityLow || p > JournalFlushPriorityHigh {
+		return nil, errors.Errorf("Unknown journal flush priority %d", int(p))
+	}
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface for
+// JournalFlushPriority.
+func (p *JournalFlushPriority) UnmarshalText(text []byte) error {
+	parsed, err := ParseJournalFlushPriority(string(text))
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
+
+// weight returns the share of the flush bandwidth that a journal with
+// this priority gets, relative to the other backlogged journals.
+func (p JournalFlushPriority) weight() float64 {
+	switch p {
+	case JournalFlushPriorityLow:
+		return 1
+	case JournalFlushPriorityHigh:
+		return 16
+	default:
+		return 4
+	}
+}
+
+// journalFlushSlots is the number of journals that may flush a batch
+// at the same time.  Each batch already puts its blocks in parallel,
+// so this is kept small to let the priorities matter, but it's more
+// than one so that a journal that's slow to finish a batch doesn't
+// hold up all the others.
+const journalFlushSlots = 4
+
+// JournalFlushQueueEntry describes the state of one TLF journal in
+// the flush scheduler, for display in diagnostics.  It is suitable
+// for encoding directly as JSON.
+type JournalFlushQueueEntry struct {
+	TlfID    tlf.ID
+	Priority JournalFlushPriority
+	// FlushNext is set if someone asked for this journal to be
+	// flushed before all the others.
+	FlushNext bool
+	// Flushing is set if the journal is flushing a batch right now,
+	// and Waiting if it's waiting for its turn to flush one.
+	Flushing bool
+	Waiting  bool
+	// FlushedBytes is the number of block bytes the journal has
+	// flushed since startup.
+	FlushedBytes int64
+}
+
+type journalFlushWaiter struct {
+	tlfID   tlf.ID
+	granted chan struct{}
+}
+
+type journalFlushTLFState struct {
+	priority  JournalFlushPriority
+	flushNext bool
+	// active is set while the journal is in its flush loop, even
+	// between its turns.
+	active       bool
+	flushing     int
+	flushedBytes int64
+	// virtualBytes is the number of bytes the journal has flushed,
+	// divided by its weight.  The waiting journal with the fewest
+	// virtual bytes gets the next turn.
+	virtualBytes float64
+}
+
+// journalFlushScheduler decides which TLF journals get to flush their
+// next batches, when more of them are waiting than there are free
+// slots.  Journals marked to flush next go first, in the order they
+// were marked.  Otherwise, the turns are shared between the waiting
+// journals in proportion to the weights of their priorities, by the
+// number of bytes they flush (start-time fair queueing).  A journal
+// that's between turns doesn't hold up the others, but since it keeps
+// its virtual bytes while it's active, it's first in line again once
+// it comes back.  A nil *journalFlushScheduler lets every journal
+// flush whenever it wants.  It's goroutine-safe.
+type journalFlushScheduler struct {
+	lock    sync.Mutex
+	slots   int
+	tlfs    map[tlf.ID]*journalFlushTLFState
+	waiters []journalFlushWaiter
+	// flushNext holds the TLFs to flush next, in order.
+	flushNext []tlf.ID
+	// virtualTime is the number of virtual bytes of the journal
+	// that got the most recent turn.
+	virtualTime float64
+}
+
+func newJournalFlushScheduler(slots int) *journalFlushScheduler {
+	return &journalFlushScheduler{
+		slots: slots,
+		tlfs:  make(map[tlf.ID]*journalFlushTLFState),
+	}
+}
+
+func (s *journalFlushScheduler) getStateLocked(
+	tlfID tlf.ID) *journalFlushTLFState {
+	state, ok := s.tlfs[tlfID]
+	if !ok {
+		state = &journalFlushTLFState{}
+		s.tlfs[tlfID] = state
+	}
+	return state
+}
+
+func (s *journalFlushScheduler) flushingLocked() (flushing int) {
+	for _, state := range s.tlfs {
+		flushing += state.flushing
+	}
+	return flushing
+}
+
+// nextWaiterLocked returns the index of the waiter that should get
+// the next turn, among the waiters for TLFs that aren't flushing
+// right now.
+func (s *journalFlushScheduler) nextWaiterLocked() (next int, ok bool) {
+	flushNextIndex := func(tlfID tlf.ID) int {
+		for i, id := range s.flushNext {
+			if id == tlfID {
+				return i
+			}
+		}
+		return len(s.flushNext)
+	}
+	next = -1
+	var nextIndex int
+	var nextState *journalFlushTLFState
+	for i, w := range s.waiters {
+		state := s.tlfs[w.tlfID]
+		if state.flushing > 0 {
+			continue
+		}
+		index := flushNextIndex(w.tlfID)
+		// Go by the flush-next order, then by virtual bytes, and
+		// then by arrival order.
+		if next < 0 || index < nextIndex ||
+			(index == nextIndex &&
+				state.virtualBytes < nextState.virtualBytes) {
+			next, nextIndex, nextState = i, index, state
+		}
+	}
+	return next, next >= 0
+}
+
+func (s *journalFlushScheduler) dispatchLocked() {
+	for len(s.waiters) > 0 && s.flushingLocked() < s.slots {
+		i, ok := s.nextWaiterLocked()
+		if !ok {
+			return
+		}
+		w := s.waiters[i]
+		s.waiters = append(s.waiters[:i], s.waiters[i+1:]...)
+		state := s.tlfs[w.tlfID]
+		state.flushing++
+		if state.virtualBytes > s.virtualTime {
+			s.virtualTime = state.virtualBytes
+		}
+		close(w.granted)
+	}
+}
+
+// acquire blocks until the journal for the given TLF may flush its
+// next batch, or until the context is canceled.  Every successful
+// call must be followed by a call to release.
+func (s *journalFlushScheduler) acquire(
+	ctx context.Context, tlfID tlf.ID) error {
+	if s == nil {
+		return nil
+	}
+	w := journalFlushWaiter{tlfID, make(chan struct{})}
+	func() {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		// A journal that was idle doesn't get to make up for the
+		// turns it didn't need.
+		state := s.getStateLocked(tlfID)
+		if !state.active && state.virtualBytes < s.virtualTime {
+			state.virtualBytes = s.virtualTime
+		}
+		state.active = true
+		s.waiters = append(s.waiters, w)
+		s.dispatchLocked()
+	}()
+
+	select {
+	case <-w.granted:
+		return nil
+	case <-ctx.Done():
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		for i, other := range s.waiters {
+			if other.granted == w.granted {
+				s.waiters = append(s.waiters[:i], s.waiters[i+1:]...)
+				return errors.WithStack(ctx.Err())
+			}
+		}
+		// We got the turn after all, so hand it on.
+		s.tlfs[tlfID].flushing--
+		s.dispatchLocked()
+		return errors.WithStack(ctx.Err())
+	}
+}
+
+// release ends the turn of the journal for the given TLF, which
+// flushed the given number of block bytes during it.
+func (s *journalFlushScheduler) release(tlfID tlf.ID, flushedBytes int64) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	state := s.tlfs[tlfID]
+	state.flushing--
+	state.flushedBytes += flushedBytes
+	state.virtualBytes += float64(flushedBytes) / state.priority.weight()
+	s.dispatchLocked()
+}
+
+// finished tells the scheduler that the journal for the given TLF has
+// left its flush loop, and whether that's because it had nothing left
+// to flush.
+func (s *journalFlushScheduler) finished(tlfID tlf.ID, empty bool) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	state, ok := s.tlfs[tlfID]
+	if !ok {
+		return
+	}
+	state.active = false
+	if empty && state.flushNext {
+		state.flushNext = false
+		for i, id := range s.flushNext {
+			if id == tlfID {
+				s.flushNext = append(s.flushNext[:i], s.flushNext[i+1:]...)
+				break
+			}
+		}
+	}
+	s.dispatchLocked()
+}
+
+func (s *journalFlushScheduler) setPriority(
+	tlfID tlf.ID, priority JournalFlushPriority) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.getStateLocked(tlfID).priority = priority
+}
+
+func (s *journalFlushScheduler) getPriority(
+	tlfID tlf.ID) JournalFlushPriority {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if state, ok := s.tlfs[tlfID]; ok {
+		return state.priority
+	}
+	return JournalFlushPriorityNormal
+}
+
+// setFlushNext makes the journal for the given TLF go ahead of all
+// the other waiting journals (except the TLFs that were marked before
+// it) until it has nothing left to flush.
+func (s *journalFlushScheduler) setFlushNext(tlfID tlf.ID) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	state := s.getStateLocked(tlfID)
+	if state.flushNext {
+		return
+	}
+	state.flushNext = true
+	s.flushNext = append(s.flushNext, tlfID)
+	s.dispatchLocked()
+}
+
+// status returns the state of the given TLFs, in the order they'd
+// get their next turns if they were all waiting.
+func (s *journalFlushScheduler) status(
+	tlfIDs []tlf.ID) []JournalFlushQueueEntry {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	waiting := make(map[tlf.ID]bool, len(s.waiters))
+	for _, w := range s.waiters {
+		waiting[w.tlfID] = true
+	}
+	flushNextIndex := make(map[tlf.ID]int, len(s.flushNext))
+	for i, tlfID := range s.flushNext {
+		flushNextIndex[tlfID] = i
+	}
+
+	entries := make([]JournalFlushQueueEntry, 0, len(tlfIDs))
+	virtualBytes := make(map[tlf.ID]float64, len(tlfIDs))
+	for _, tlfID := range tlfIDs {
+		var state journalFlushTLFState
+		if p, ok := s.tlfs[tlfID]; ok {
+			state = *p
+		}
+		virtualBytes[tlfID] = state.virtualBytes
+		entries = append(entries, JournalFlushQueueEntry{
+			TlfID:        tlfID,
+			Priority:     state.priority,
+			FlushNext:    state.flushNext,
+			Flushing:     state.flushing > 0,
+			Waiting:      waiting[tlfID],
+			FlushedBytes: state.flushedBytes,
+		})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.FlushNext != b.FlushNext {
+			return a.FlushNext
+		}
+		if a.FlushNext {
+			return flushNextIndex[a.TlfID] < flushNextIndex[b.TlfID]
+		}
+		return virtualBytes[a.TlfID] < virtualBytes[b.TlfID]
+	})
+	return entries
+}
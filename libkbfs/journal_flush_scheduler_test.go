@@ -0,0 +1,231 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libkbfs
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/keybase/kbfs/tlf"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+func TestParseJournalFlushPriority(t *testing.T) {
+	p, err := ParseJournalFlushPriority("high\n")
+	require.NoError(t, err)
+	require.Equal(t, JournalFlushPriorityHigh, p)
+	p, err = ParseJournalFlushPriority("low")
+	require.NoError(t, err)
+	require.Equal(t, JournalFlushPriorityLow, p)
+	_, err = ParseJournalFlushPriority("urgent")
+	require.Error(t, err)
+}
+
+// waitForJournalFlushTurn starts waiting for a turn for the given TLF
+// in the background, and returns once it's queued.  The TLF is sent
+// to granted once it gets the turn.
+func waitForJournalFlushTurn(
+	t *testing.T, s *journalFlushScheduler, tlfID tlf.ID,
+	granted chan<- tlf.ID) {
+	go func() {
+		err := s.acquire(context.Background(), tlfID)
+		require.NoError(t, err)
+		granted <- tlfID
+	}()
+	for !s.status([]tlf.ID{tlfID})[0].Waiting {
+		runtime.Gosched()
+	}
+}
+
+func TestJournalFlushSchedulerShares(t *testing.T) {
+	ctx := context.Background()
+	s := newJournalFlushScheduler(1)
+	low := tlf.FakeID(1, tlf.Private)
+	high := tlf.FakeID(2, tlf.Private)
+	blocker := tlf.FakeID(3, tlf.Private)
+	s.setPriority(low, JournalFlushPriorityLow)
+	s.setPriority(high, JournalFlushPriorityHigh)
+
+	// Hold the only turn until both journals are waiting.
+	err := s.acquire(ctx, blocker)
+	require.NoError(t, err)
+	granted := make(chan tlf.ID, 2)
+	waitForJournalFlushTurn(t, s, low, granted)
+	waitForJournalFlushTurn(t, s, high, granted)
+	s.release(blocker, 0)
+	s.finished(blocker, true)
+
+	// Keep both journals backlogged by queueing each one up again
+	// before it ends its turn.
+	var turns []tlf.ID
+	for i := 0; i < 17; i++ {
+		tlfID := <-granted
+		turns = append(turns, tlfID)
+		waitForJournalFlushTurn(t, s, tlfID, granted)
+		s.release(tlfID, 100)
+	}
+
+	// While both are flushing, the high priority journal gets 16
+	// turns for every turn of the low priority one.
+	highTurns := 0
+	for _, tlfID := range turns {
+		if tlfID == high {
+			highTurns++
+		}
+	}
+	require.True(t, highTurns >= 15, "high priority got %d turns", highTurns)
+}
+
+func TestJournalFlushSchedulerFlushNext(t *testing.T) {
+	ctx := context.Background()
+	s := newJournalFlushScheduler(1)
+	tlfID1 := tlf.FakeID(1, tlf.Private)
+	tlfID2 := tlf.FakeID(2, tlf.Private)
+	tlfID3 := tlf.FakeID(3, tlf.Private)
+
+	// Hold the only turn, and queue up the others behind it.
+	err := s.acquire(ctx, tlfID1)
+	require.NoError(t, err)
+	granted := make(chan tlf.ID, 3)
+	waitForJournalFlushTurn(t, s, tlfID2, granted)
+	waitForJournalFlushTurn(t, s, tlfID3, granted)
+
+	s.setFlushNext(tlfID3)
+	entries := s.status([]tlf.ID{tlfID1, tlfID2, tlfID3})
+	require.Equal(t, tlfID3, entries[0].TlfID)
+	require.True(t, entries[0].FlushNext)
+
+	s.release(tlfID1, 100)
+	require.Equal(t, tlfID3, <-granted)
+
+	t.Log("The marked journal doesn't hold the turn between its " +
+		"batches, but it goes first whenever it's waiting.")
+	waitForJournalFlushTurn(t, s, tlfID1, granted)
+	s.release(tlfID3, 100)
+	require.Equal(t, tlfID2, <-granted)
+	waitForJournalFlushTurn(t, s, tlfID3, granted)
+	s.release(tlfID2, 100)
+	require.Equal(t, tlfID3, <-granted)
+
+	t.Log("Once the marked journal is empty, the others get their turns.")
+	s.release(tlfID3, 100)
+	s.finished(tlfID3, true)
+	require.Equal(t, tlfID1, <-granted)
+	require.False(t, s.status([]tlf.ID{tlfID3})[0].FlushNext)
+	s.release(tlfID1, 100)
+	s.finished(tlfID1, true)
+	s.finished(tlfID2, true)
+
+	t.Log("A canceled wait doesn't hold up the others.")
+	err = s.acquire(ctx, tlfID2)
+	require.NoError(t, err)
+	cancelCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	err = s.acquire(cancelCtx, tlfID1)
+	require.Error(t, err)
+	s.finished(tlfID1, false)
+	s.release(tlfID2, 100)
+	s.finished(tlfID2, true)
+	err = s.acquire(ctx, tlfID1)
+	require.NoError(t, err)
+	s.release(tlfID1, 0)
+}
+
+func TestJournalFlushSchedulerNoHeldTurns(t *testing.T) {
+	ctx := context.Background()
+	s := newJournalFlushScheduler(1)
+	tlfID1 := tlf.FakeID(1, tlf.Private)
+	tlfID2 := tlf.FakeID(2, tlf.Private)
+
+	t.Log("Both journals are active, and tlfID1 has flushed less.")
+	err := s.acquire(ctx, tlfID2)
+	require.NoError(t, err)
+	s.release(tlfID2, 100)
+	err = s.acquire(ctx, tlfID1)
+	require.NoError(t, err)
+	s.release(tlfID1, 0)
+
+	t.Log("tlfID1 is between batches, so tlfID2 gets the turn " +
+		"right away instead of waiting for it.")
+	err = s.acquire(ctx, tlfID2)
+	require.NoError(t, err)
+
+	t.Log("Once tlfID1 comes back, it goes next.")
+	granted := make(chan tlf.ID, 2)
+	waitForJournalFlushTurn(t, s, tlfID1, granted)
+	s.release(tlfID2, 100)
+	require.Equal(t, tlfID1, <-granted)
+	s.release(tlfID1, 100)
+	s.finished(tlfID1, true)
+	s.finished(tlfID2, true)
+}
+
+func TestJournalFlushSchedulerThroughput(t *testing.T) {
+	ctx := context.Background()
+	s := newJournalFlushScheduler(journalFlushSlots)
+	const numTLFs = 2 * journalFlushSlots
+	const numBatches = 10
+
+	var lock sync.Mutex
+	flushing := 0
+	maxFlushing := 0
+	var wg sync.WaitGroup
+	for i := 0; i < numTLFs; i++ {
+		tlfID := tlf.FakeID(byte(i+1), tlf.Private)
+		if i%2 == 0 {
+			s.setPriority(tlfID, JournalFlushPriorityHigh)
+		}
+		wg.Add(1)
+		go func(tlfID tlf.ID) {
+			defer wg.Done()
+			defer s.finished(tlfID, true)
+			for j := 0; j < numBatches; j++ {
+				err := s.acquire(ctx, tlfID)
+				require.NoError(t, err)
+				lock.Lock()
+				flushing++
+				if flushing > maxFlushing {
+					maxFlushing = flushing
+				}
+				lock.Unlock()
+
+				// Pretend to put some blocks.
+				time.Sleep(time.Millisecond)
+
+				lock.Lock()
+				flushing--
+				lock.Unlock()
+				s.release(tlfID, 100)
+
+				// Pretend to flush some MDs between batches.
+				time.Sleep(time.Millisecond)
+			}
+		}(tlfID)
+	}
+	wg.Wait()
+
+	// With more backlogged journals than slots, all the slots get
+	// used, and never more than that.
+	require.Equal(t, journalFlushSlots, maxFlushing)
+	for i := 0; i < numTLFs; i++ {
+		tlfID := tlf.FakeID(byte(i+1), tlf.Private)
+		entry := s.status([]tlf.ID{tlfID})[0]
+		require.Equal(t, int64(100*numBatches), entry.FlushedBytes)
+		require.False(t, entry.Flushing)
+		require.False(t, entry.Waiting)
+	}
+}
+
+func TestJournalFlushSchedulerNil(t *testing.T) {
+	var s *journalFlushScheduler
+	tlfID := tlf.FakeID(1, tlf.Private)
+	err := s.acquire(context.Background(), tlfID)
+	require.NoError(t, err)
+	s.release(tlfID, 100)
+	s.finished(tlfID, true)
+}
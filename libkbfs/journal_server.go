@@ -32,6 +32,10 @@ type journalServerConfig struct {
 	// EnableAutoSetByUser means the user has explicitly set the
 	// value of EnableAuto (after this field was added).
 	EnableAutoSetByUser bool
+
+	// FlushPriorities holds the flush priorities of the TLFs that
+	// don't have the normal one.
+	FlushPriorities map[tlf.ID]JournalFlushPriority `json:",omitempty"`
 }
 
 func (jsc journalServerConfig) getEnableAuto(currentUID keybase1.UID) (
@@ -72,6 +76,9 @@ type JournalServerStatus struct {
 	UnflushedBytes    int64
 	UnflushedPaths    []string
 	DiskLimiterStatus interface{}
+	// FlushQueue lists the journals in the order they'll get their
+	// next turns to flush blocks.
+	FlushQueue []JournalFlushQueueEntry
 }
 
 // branchChangeListener describes a caller that will get updates via
@@ -122,6 +129,7 @@ type JournalServer struct {
 	delegateMDOps           MDOps
 	onBranchChange          branchChangeListener
 	onMDFlush               mdFlushListener
+	flushScheduler          *journalFlushScheduler
 
 	// Just protects lastQuotaError.
 	lastQuotaErrorLock sync.Mutex
@@ -135,10 +143,13 @@ type JournalServer struct {
 	lock                sync.RWMutex
 	currentUID          keybase1.UID
 	currentVerifyingKey kbfscrypto.VerifyingKey
-	tlfJournals         map[tlf.ID]*tlfJournal
-	dirtyOps            map[tlf.ID]uint
-	dirtyOpsDone        *sync.Cond
-	serverConfig        journalServerConfig
+	// currentCryptKey encrypts the metadata files of the journals
+	// of the current device; see journalCrypter.
+	currentCryptKey kbfscrypto.JournalCryptKey
+	tlfJournals     map[tlf.ID]*tlfJournal
+	dirtyOps        map[tlf.ID]uint
+	dirtyOpsDone    *sync.Cond
+	serverConfig    journalServerConfig
 }
 
 func makeJournalServer(
@@ -160,6 +171,7 @@ func makeJournalServer(
 		delegateMDOps:           mdOps,
 		onBranchChange:          onBranchChange,
 		onMDFlush:               onMDFlush,
+		flushScheduler:          newJournalFlushScheduler(journalFlushSlots),
 		tlfJournals:             make(map[tlf.ID]*tlfJournal),
 		dirtyOps:                make(map[tlf.ID]uint),
 	}
@@ -176,7 +188,14 @@ func (j *JournalServer) configPath() string {
 }
 
 func (j *JournalServer) readConfig() error {
-	return ioutil.DeserializeFromJSONFile(j.configPath(), &j.serverConfig)
+	err := ioutil.DeserializeFromJSONFile(j.configPath(), &j.serverConfig)
+	if err != nil {
+		return err
+	}
+	for tlfID, priority := range j.serverConfig.FlushPriorities {
+		j.flushScheduler.setPriority(tlfID, priority)
+	}
+	return nil
 }
 
 func (j *JournalServer) writeConfig() error {
@@ -306,6 +325,13 @@ func (j *JournalServer) EnableExistingJournals(
 		return errors.New("Current verifying key is empty")
 	}
 
+	// Derive the key before taking the lock, since it needs a
+	// signature from the device key.
+	currentCryptKey, err := makeJournalCryptKey(ctx, j.config.Crypto())
+	if err != nil {
+		return err
+	}
+
 	// TODO: We should also look up journals from other
 	// users/devices so that we can take into account their
 	// journal usage.
@@ -342,6 +368,7 @@ func (j *JournalServer) EnableExistingJournals(
 	// enableLocked depend on it.
 	j.currentUID = currentUID
 	j.currentVerifyingKey = currentVerifyingKey
+	j.currentCryptKey = currentCryptKey
 
 	enableSucceeded := false
 	defer func() {
@@ -377,7 +404,8 @@ func (j *JournalServer) EnableExistingJournals(
 			}
 
 			dir := filepath.Join(j.rootPath(), name)
-			uid, key, tlfID, chargedTo, err := readTLFJournalInfoFile(dir)
+			uid, key, tlfID, chargedTo, err := readTLFJournalInfoFile(
+				newJournalCrypter(j.config.Codec(), currentCryptKey), dir)
 			if err != nil {
 				j.log.CDebugf(
 					groupCtx, "Skipping non-TLF dir %q: %+v", name, err)
@@ -529,10 +557,11 @@ func (j *JournalServer) enableLocked(
 
 	tlfDir := j.tlfJournalPathLocked(tlfID)
 	tj, err = makeTLFJournal(
-		ctx, j.currentUID, j.currentVerifyingKey, tlfDir,
-		tlfID, chargedTo, tlfJournalConfigAdapter{j.config},
+		ctx, j.currentUID, j.currentVerifyingKey, j.currentCryptKey,
+		tlfDir, tlfID, chargedTo, tlfJournalConfigAdapter{j.config},
 		j.delegateBlockServer,
-		bws, nil, j.onBranchChange, j.onMDFlush, j.config.DiskLimiter())
+		bws, nil, j.onBranchChange, j.onMDFlush, j.flushScheduler,
+		j.config.DiskLimiter())
 	if err != nil {
 		return nil, err
 	}
@@ -668,6 +697,56 @@ func (j *JournalServer) Flush(ctx context.Context, tlfID tlf.ID) (err error) {
 	return nil
 }
 
+// SetFlushPriority sets the priority of the journal for the given TLF
+// when it competes with other journals for flush bandwidth, and
+// persists it.
+func (j *JournalServer) SetFlushPriority(ctx context.Context,
+	tlfID tlf.ID, priority JournalFlushPriority) error {
+	if _, err := priority.MarshalText(); err != nil {
+		return err
+	}
+	j.log.CDebugf(ctx, "Setting the flush priority of %s to %s",
+		tlfID, priority)
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if priority == JournalFlushPriorityNormal {
+		delete(j.serverConfig.FlushPriorities, tlfID)
+	} else {
+		if j.serverConfig.FlushPriorities == nil {
+			j.serverConfig.FlushPriorities =
+				make(map[tlf.ID]JournalFlushPriority)
+		}
+		j.serverConfig.FlushPriorities[tlfID] = priority
+	}
+	err := j.writeConfig()
+	if err != nil {
+		return err
+	}
+	j.flushScheduler.setPriority(tlfID, priority)
+	return nil
+}
+
+// FlushPriority returns the flush priority of the journal for the
+// given TLF.
+func (j *JournalServer) FlushPriority(tlfID tlf.ID) JournalFlushPriority {
+	return j.flushScheduler.getPriority(tlfID)
+}
+
+// FlushNext makes the journal for the given TLF flush before all the
+// other journals, until it has nothing left to flush.  It doesn't
+// resume paused background work.
+func (j *JournalServer) FlushNext(ctx context.Context, tlfID tlf.ID) error {
+	j.log.CDebugf(ctx, "Flushing journal for %s next", tlfID)
+	tlfJournal, ok := j.getTLFJournal(tlfID, nil)
+	if !ok {
+		return errors.Errorf("Journal not enabled for %s", tlfID)
+	}
+	j.flushScheduler.setFlushNext(tlfID)
+	tlfJournal.signalWork()
+	return nil
+}
+
 // Wait blocks until the write journal has finished flushing
 // everything.  It is essentially the same as Flush() when the journal
 // is enabled and unpaused, except that it is safe to cancel the
@@ -854,9 +933,38 @@ func (j *JournalServer) Status(
 		UnflushedBytes:      totalUnflushedBytes,
 		DiskLimiterStatus: j.config.DiskLimiter().getStatus(
 			ctx, j.currentUID.AsUserOrTeam()),
+		FlushQueue: j.flushScheduler.status(tlfIDs),
 	}, tlfIDs
 }
 
+// tlfJournalByteCounts holds the local disk usage of one TLF journal.
+type tlfJournalByteCounts struct {
+	storedBytes    int64
+	storedFiles    int64
+	unflushedBytes int64
+}
+
+// byteCountsByTLF returns the local disk usage of each enabled TLF
+// journal.
+func (j *JournalServer) byteCountsByTLF(
+	ctx context.Context) map[tlf.ID]tlfJournalByteCounts {
+	j.lock.RLock()
+	defer j.lock.RUnlock()
+	counts := make(map[tlf.ID]tlfJournalByteCounts, len(j.tlfJournals))
+	for tlfID, tlfJournal := range j.tlfJournals {
+		storedBytes, storedFiles, unflushedBytes, err :=
+			tlfJournal.getByteCounts()
+		if err != nil {
+			j.log.CWarningf(ctx,
+				"Couldn't calculate stored bytes/stored files/unflushed bytes for %s: %+v",
+				tlfID, err)
+		}
+		counts[tlfID] = tlfJournalByteCounts{
+			storedBytes, storedFiles, unflushedBytes}
+	}
+	return counts
+}
+
 // JournalStatus returns a TLFServerStatus object for the given TLF
 // suitable for diagnostics.
 func (j *JournalServer) JournalStatus(tlfID tlf.ID) (
@@ -906,6 +1014,7 @@ func (j *JournalServer) shutdownExistingJournalsLocked(ctx context.Context) {
 	j.tlfJournals = make(map[tlf.ID]*tlfJournal)
 	j.currentUID = keybase1.UID("")
 	j.currentVerifyingKey = kbfscrypto.VerifyingKey{}
+	j.currentCryptKey = kbfscrypto.JournalCryptKey{}
 }
 
 // shutdownExistingJournals shuts down all write journals, sets the
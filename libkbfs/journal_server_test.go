@@ -5,6 +5,7 @@
 package libkbfs
 
 import (
+	"bytes"
 	"math"
 	"os"
 	"sync"
@@ -998,3 +999,127 @@ func TestJournalQuotaStatusForGitBlocks(t *testing.T) {
 	require.Equal(
 		t, int64(2000), bs.JournalTrackerStatus.QuotaStatus.QuotaBytes)
 }
+
+func TestJournalServerExportImport(t *testing.T) {
+	tempdir, ctx, cancel, config, _, jServer := setupJournalServerTest(t)
+	defer teardownJournalServerTest(t, tempdir, ctx, cancel, config)
+
+	// Syncing needs a context that can delay cancellation.
+	ctx, err := NewContextWithCancellationDelayer(NewContextReplayable(
+		ctx, func(c context.Context) context.Context {
+			return c
+		}))
+	require.NoError(t, err)
+
+	session, err := config.KBPKI().GetCurrentSession(ctx)
+	require.NoError(t, err)
+
+	// Make a second device for the same user, with its own journal.
+	config2 := ConfigAsUser(config, "test_user1")
+	tempdir2, err := ioutil.TempDir(os.TempDir(), "journal_server")
+	require.NoError(t, err)
+	defer teardownJournalServerTest(t, tempdir2, ctx, cancel, config2)
+	AddDeviceForLocalUserOrBust(t, config, session.UID)
+	devIndex := AddDeviceForLocalUserOrBust(t, config2, session.UID)
+	SwitchDeviceForLocalUserOrBust(t, config2, devIndex)
+	err = config2.EnableDiskLimiter(tempdir2)
+	require.NoError(t, err)
+	err = config2.EnableJournaling(
+		ctx, tempdir2, TLFJournalBackgroundWorkPaused)
+	require.NoError(t, err)
+	jServer2, err := GetJournalServer(config2)
+	require.NoError(t, err)
+
+	// Create the TLF on the server, then make some changes that
+	// stay in the journal of the first device.
+	name := "test_user1"
+	rootNode := GetRootNodeOrBust(ctx, t, config, name, tlf.Private)
+	tlfID := rootNode.GetFolderBranch().Tlf
+	jServer.PauseBackgroundWork(ctx, tlfID)
+
+	kbfsOps := config.KBFSOps()
+	fileNode, _, err := kbfsOps.CreateFile(
+		ctx, rootNode, "a", false, NoExcl)
+	require.NoError(t, err)
+	err = kbfsOps.Write(ctx, fileNode, []byte("hello"), 0)
+	require.NoError(t, err)
+	err = kbfsOps.SyncAll(ctx, rootNode.GetFolderBranch())
+	require.NoError(t, err)
+	err = kbfsOps.Write(ctx, fileNode, []byte("world"), 5)
+	require.NoError(t, err)
+	err = kbfsOps.SyncAll(ctx, rootNode.GetFolderBranch())
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	numExported, err := jServer.ExportTLFJournal(ctx, tlfID, &buf)
+	require.NoError(t, err)
+	require.Equal(t, 2, numExported)
+	archive := buf.Bytes()
+
+	t.Log("A corrupted archive can't be imported.")
+	corrupted := append([]byte(nil), archive...)
+	corrupted[len(corrupted)/2] ^= 0xff
+	_, _, err = jServer2.ImportTLFJournal(ctx,
+		bytes.NewReader(corrupted), TLFJournalBackgroundWorkPaused)
+	require.Error(t, err)
+
+	t.Log("The exporting device can't import its own archive.")
+	_, _, err = jServer.ImportTLFJournal(ctx,
+		bytes.NewReader(archive), TLFJournalBackgroundWorkPaused)
+	require.Error(t, err)
+
+	importedID, numImported, err := jServer2.ImportTLFJournal(ctx,
+		bytes.NewReader(archive), TLFJournalBackgroundWorkPaused)
+	require.NoError(t, err)
+	require.Equal(t, tlfID, importedID)
+	require.Equal(t, 2, numImported)
+	err = jServer2.Flush(ctx, tlfID)
+	require.NoError(t, err)
+
+	rootNode2 := GetRootNodeOrBust(ctx, t, config2, name, tlf.Private)
+	fileNode2, _, err := config2.KBFSOps().Lookup(ctx, rootNode2, "a")
+	require.NoError(t, err)
+	data := make([]byte, 10)
+	n, err := config2.KBFSOps().Read(ctx, fileNode2, data, 0)
+	require.NoError(t, err)
+	require.Equal(t, "helloworld", string(data[:n]))
+
+	t.Log("Importing the same archive again is a no-op.")
+	_, numImported, err = jServer2.ImportTLFJournal(ctx,
+		bytes.NewReader(archive), TLFJournalBackgroundWorkPaused)
+	require.NoError(t, err)
+	require.Equal(t, 0, numImported)
+}
+
+func TestJournalServerFlushPriority(t *testing.T) {
+	tempdir, ctx, cancel, config, _, jServer := setupJournalServerTest(t)
+	defer teardownJournalServerTest(t, tempdir, ctx, cancel, config)
+
+	tlfID := tlf.FakeID(2, tlf.Private)
+	err := jServer.Enable(ctx, tlfID, nil, TLFJournalBackgroundWorkPaused)
+	require.NoError(t, err)
+
+	require.Equal(t, JournalFlushPriorityNormal, jServer.FlushPriority(tlfID))
+	err = jServer.SetFlushPriority(ctx, tlfID, JournalFlushPriorityHigh)
+	require.NoError(t, err)
+	err = jServer.FlushNext(ctx, tlfID)
+	require.NoError(t, err)
+
+	// The journal is paused, so it stays marked to flush next.
+	status, _ := jServer.Status(ctx)
+	require.Equal(t, []JournalFlushQueueEntry{{
+		TlfID:     tlfID,
+		Priority:  JournalFlushPriorityHigh,
+		FlushNext: true,
+	}}, status.FlushQueue)
+
+	// The priority survives a restart.
+	jServer.flushScheduler = newJournalFlushScheduler(journalFlushSlots)
+	err = jServer.readConfig()
+	require.NoError(t, err)
+	require.Equal(t, JournalFlushPriorityHigh, jServer.FlushPriority(tlfID))
+
+	err = jServer.SetFlushPriority(ctx, tlfID, JournalFlushPriorityNormal)
+	require.NoError(t, err)
+	require.Empty(t, jServer.serverConfig.FlushPriorities)
+}
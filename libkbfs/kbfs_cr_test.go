@@ -770,6 +770,538 @@ func TestBasicCRFileConflict(t *testing.T) {
 	require.Equal(t, children1, children2)
 }
 
+func testCRFileConflictMerge(t *testing.T, data1, data2 []byte,
+	expectedData []byte) {
+	// simulate two users
+	var userName1, userName2 libkb.NormalizedUsername = "u1", "u2"
+	config1, _, ctx, cancel := kbfsOpsConcurInit(t, userName1, userName2)
+	defer kbfsConcurTestShutdown(t, config1, ctx, cancel)
+
+	config2 := ConfigAsUser(config1, userName2)
+	defer CheckConfigAndShutdown(ctx, t, config2)
+
+	clock, now := newTestClockAndTimeNow()
+	config2.SetClock(clock)
+	merger, err := ParseConflictMergeRules("*.txt=text")
+	require.NoError(t, err)
+	config2.SetConflictMerger(merger)
+
+	name := userName1.String() + "," + userName2.String()
+
+	// user1 creates a text file in a shared dir
+	rootNode1 := GetRootNodeOrBust(ctx, t, config1, name, tlf.Private)
+
+	kbfsOps1 := config1.KBFSOps()
+	dirA1, _, err := kbfsOps1.CreateDir(ctx, rootNode1, "a")
+	require.NoError(t, err)
+	fileB1, _, err := kbfsOps1.CreateFile(ctx, dirA1, "b.txt", false, NoExcl)
+	require.NoError(t, err)
+	base := []byte("one\ntwo\nthree\n")
+	err = kbfsOps1.Write(ctx, fileB1, base, 0)
+	require.NoError(t, err)
+	err = kbfsOps1.SyncAll(ctx, rootNode1.GetFolderBranch())
+	require.NoError(t, err)
+
+	// look it up on user2
+	rootNode2 := GetRootNodeOrBust(ctx, t, config2, name, tlf.Private)
+
+	kbfsOps2 := config2.KBFSOps()
+	dirA2, _, err := kbfsOps2.Lookup(ctx, rootNode2, "a")
+	require.NoError(t, err)
+	fileB2, _, err := kbfsOps2.Lookup(ctx, dirA2, "b.txt")
+	require.NoError(t, err)
+
+	// disable updates on user 2
+	c, err := DisableUpdatesForTesting(config2, rootNode2.GetFolderBranch())
+	require.NoError(t, err)
+	err = DisableCRForTesting(config2, rootNode2.GetFolderBranch())
+	require.NoError(t, err)
+
+	// Both users rewrite the file.
+	err = kbfsOps1.Truncate(ctx, fileB1, 0)
+	require.NoError(t, err)
+	err = kbfsOps1.Write(ctx, fileB1, data1, 0)
+	require.NoError(t, err)
+	err = kbfsOps1.SyncAll(ctx, fileB1.GetFolderBranch())
+	require.NoError(t, err)
+
+	err = kbfsOps2.Truncate(ctx, fileB2, 0)
+	require.NoError(t, err)
+	err = kbfsOps2.Write(ctx, fileB2, data2, 0)
+	require.NoError(t, err)
+	err = kbfsOps2.SyncAll(ctx, fileB2.GetFolderBranch())
+	require.NoError(t, err)
+
+	// re-enable updates, and wait for CR to complete
+	c <- struct{}{}
+	err = RestartCRForTesting(
+		BackgroundContextWithCancellationDelayer(), config2,
+		rootNode2.GetFolderBranch())
+	require.NoError(t, err)
+	err = kbfsOps2.SyncFromServer(ctx,
+		rootNode2.GetFolderBranch(), nil)
+	require.NoError(t, err)
+
+	err = kbfsOps1.SyncFromServer(ctx,
+		rootNode1.GetFolderBranch(), nil)
+	require.NoError(t, err)
+
+	expectedChildren := map[string][]byte{"b.txt": expectedData}
+	if expectedData == nil {
+		// The merge failed, so the unmerged copy was renamed.
+		cre := WriterDeviceDateConflictRenamer{}
+		expectedChildren = map[string][]byte{
+			"b.txt": data1,
+			cre.ConflictRenameHelper(now, "u2", "dev1", "b.txt"): data2,
+		}
+	}
+	for _, u := range []struct {
+		kbfsOps KBFSOps
+		dir     Node
+	}{{kbfsOps1, dirA1}, {kbfsOps2, dirA2}} {
+		children, err := u.kbfsOps.GetDirChildren(ctx, u.dir)
+		require.NoError(t, err)
+		require.Len(t, children, len(expectedChildren))
+		for child, data := range expectedChildren {
+			require.Contains(t, children, child)
+			n, _, err := u.kbfsOps.Lookup(ctx, u.dir, child)
+			require.NoError(t, err)
+			buf := make([]byte, len(data)+1)
+			nr, err := u.kbfsOps.Read(ctx, n, buf, 0)
+			require.NoError(t, err)
+			require.Equal(t, string(data), string(buf[:nr]))
+		}
+	}
+}
+
+// Tests that CR merges the contents of a text file written by two
+// users, when they changed different lines.
+func TestCRFileConflictMerged(t *testing.T) {
+	testCRFileConflictMerge(t, []byte("ONE\ntwo\nthree\n"),
+		[]byte("one\ntwo\nTHREE\nfour\n"),
+		[]byte("ONE\ntwo\nTHREE\nfour\n"))
+}
+
+// Tests that CR falls back to renaming the unmerged copy of a text
+// file, when the two users changed the same line.
+func TestCRFileConflictMergeFails(t *testing.T) {
+	testCRFileConflictMerge(t, []byte("one\nTWO\nthree\n"),
+		[]byte("one\n2\nthree\n"), nil)
+}
+
+// Tests that CR doesn't merge two files that were created separately
+// under the same name, even with a strategy that doesn't need a
+// common ancestor.
+func TestCRFileCreatedInBothNotMerged(t *testing.T) {
+	// simulate two users
+	var userName1, userName2 libkb.NormalizedUsername = "u1", "u2"
+	config1, _, ctx, cancel := kbfsOpsConcurInit(t, userName1, userName2)
+	defer kbfsConcurTestShutdown(t, config1, ctx, cancel)
+
+	config2 := ConfigAsUser(config1, userName2)
+	defer CheckConfigAndShutdown(ctx, t, config2)
+
+	clock, now := newTestClockAndTimeNow()
+	config2.SetClock(clock)
+	merger, err := ParseConflictMergeRules("*.txt=lines")
+	require.NoError(t, err)
+	config2.SetConflictMerger(merger)
+
+	name := userName1.String() + "," + userName2.String()
+
+	rootNode1 := GetRootNodeOrBust(ctx, t, config1, name, tlf.Private)
+	kbfsOps1 := config1.KBFSOps()
+	dirA1, _, err := kbfsOps1.CreateDir(ctx, rootNode1, "a")
+	require.NoError(t, err)
+	err = kbfsOps1.SyncAll(ctx, rootNode1.GetFolderBranch())
+	require.NoError(t, err)
+
+	rootNode2 := GetRootNodeOrBust(ctx, t, config2, name, tlf.Private)
+	kbfsOps2 := config2.KBFSOps()
+	dirA2, _, err := kbfsOps2.Lookup(ctx, rootNode2, "a")
+	require.NoError(t, err)
+
+	// disable updates on user 2
+	c, err := DisableUpdatesForTesting(config2, rootNode2.GetFolderBranch())
+	require.NoError(t, err)
+	err = DisableCRForTesting(config2, rootNode2.GetFolderBranch())
+	require.NoError(t, err)
+
+	// Both users create the same file.
+	data1 := []byte("one\ntwo\n")
+	data2 := []byte("three\nfour\n")
+	for _, u := range []struct {
+		kbfsOps KBFSOps
+		dir     Node
+		data    []byte
+	}{{kbfsOps1, dirA1, data1}, {kbfsOps2, dirA2, data2}} {
+		fileB, _, err := u.kbfsOps.CreateFile(
+			ctx, u.dir, "b.txt", false, NoExcl)
+		require.NoError(t, err)
+		err = u.kbfsOps.Write(ctx, fileB, u.data, 0)
+		require.NoError(t, err)
+		err = u.kbfsOps.SyncAll(ctx, fileB.GetFolderBranch())
+		require.NoError(t, err)
+	}
+
+	// re-enable updates, and wait for CR to complete
+	c <- struct{}{}
+	err = RestartCRForTesting(
+		BackgroundContextWithCancellationDelayer(), config2,
+		rootNode2.GetFolderBranch())
+	require.NoError(t, err)
+	err = kbfsOps2.SyncFromServer(ctx,
+		rootNode2.GetFolderBranch(), nil)
+	require.NoError(t, err)
+	err = kbfsOps1.SyncFromServer(ctx,
+		rootNode1.GetFolderBranch(), nil)
+	require.NoError(t, err)
+
+	cre := WriterDeviceDateConflictRenamer{}
+	expectedChildren := map[string][]byte{
+		"b.txt": data1,
+		cre.ConflictRenameHelper(now, "u2", "dev1", "b.txt"): data2,
+	}
+	for _, u := range []struct {
+		kbfsOps KBFSOps
+		dir     Node
+	}{{kbfsOps1, dirA1}, {kbfsOps2, dirA2}} {
+		children, err := u.kbfsOps.GetDirChildren(ctx, u.dir)
+		require.NoError(t, err)
+		require.Len(t, children, len(expectedChildren))
+		for child, data := range expectedChildren {
+			n, _, err := u.kbfsOps.Lookup(ctx, u.dir, child)
+			require.NoError(t, err)
+			buf := make([]byte, len(data)+1)
+			nr, err := u.kbfsOps.Read(ctx, n, buf, 0)
+			require.NoError(t, err)
+			require.Equal(t, string(data), string(buf[:nr]))
+		}
+	}
+}
+
+func testCRConflictInventory(t *testing.T, winner ConflictWinner) {
+	// simulate two users
+	var userName1, userName2 libkb.NormalizedUsername = "u1", "u2"
+	config1, _, ctx, cancel := kbfsOpsConcurInit(t, userName1, userName2)
+	defer kbfsConcurTestShutdown(t, config1, ctx, cancel)
+
+	config2 := ConfigAsUser(config1, userName2)
+	defer CheckConfigAndShutdown(ctx, t, config2)
+
+	clock, now := newTestClockAndTimeNow()
+	config2.SetClock(clock)
+
+	name := userName1.String() + "," + userName2.String()
+
+	// user1 creates a file in a shared dir
+	rootNode1 := GetRootNodeOrBust(ctx, t, config1, name, tlf.Private)
+
+	kbfsOps1 := config1.KBFSOps()
+	dirA1, _, err := kbfsOps1.CreateDir(ctx, rootNode1, "a")
+	require.NoError(t, err)
+	fileB1, _, err := kbfsOps1.CreateFile(ctx, dirA1, "b", false, NoExcl)
+	require.NoError(t, err)
+	err = kbfsOps1.SyncAll(ctx, rootNode1.GetFolderBranch())
+	require.NoError(t, err)
+
+	// look it up on user2
+	rootNode2 := GetRootNodeOrBust(ctx, t, config2, name, tlf.Private)
+	fb := rootNode2.GetFolderBranch()
+
+	kbfsOps2 := config2.KBFSOps()
+	dirA2, _, err := kbfsOps2.Lookup(ctx, rootNode2, "a")
+	require.NoError(t, err)
+	fileB2, _, err := kbfsOps2.Lookup(ctx, dirA2, "b")
+	require.NoError(t, err)
+
+	conflicts, err := kbfsOps2.GetConflicts(ctx, fb)
+	require.NoError(t, err)
+	require.Len(t, conflicts.Conflicts, 0)
+
+	// disable updates and CR on user 2
+	c, err := DisableUpdatesForTesting(config2, fb)
+	require.NoError(t, err)
+	err = DisableCRForTesting(config2, fb)
+	require.NoError(t, err)
+
+	// Both users write the file.
+	data1 := []byte{1, 2, 3}
+	err = kbfsOps1.Write(ctx, fileB1, data1, 0)
+	require.NoError(t, err)
+	err = kbfsOps1.SyncAll(ctx, fb)
+	require.NoError(t, err)
+
+	data2 := []byte{4, 5, 6}
+	err = kbfsOps2.Write(ctx, fileB2, data2, 0)
+	require.NoError(t, err)
+	err = kbfsOps2.SyncAll(ctx, fb)
+	require.NoError(t, err)
+
+	t.Log("User 2 sees its own unmerged branch.")
+	conflicts, err = kbfsOps2.GetConflicts(ctx, fb)
+	require.NoError(t, err)
+	require.True(t, conflicts.Unmerged)
+	require.NotEmpty(t, conflicts.BranchID)
+	err = kbfsOps2.ResolveConflict(ctx, fb, "a/b", winner)
+	require.IsType(t, NoSuchConflictError{}, err)
+
+	// re-enable updates, and wait for CR to complete
+	c <- struct{}{}
+	err = RestartCRForTesting(
+		BackgroundContextWithCancellationDelayer(), config2, fb)
+	require.NoError(t, err)
+	err = kbfsOps2.SyncFromServer(ctx, fb, nil)
+	require.NoError(t, err)
+	err = kbfsOps1.SyncFromServer(ctx, fb, nil)
+	require.NoError(t, err)
+
+	t.Log("Both users see the conflict copy made by CR.")
+	cre := WriterDeviceDateConflictRenamer{}
+	copyName := cre.ConflictRenameHelper(now, "u2", "dev1", "b")
+	for _, kbfsOps := range []KBFSOps{kbfsOps1, kbfsOps2} {
+		conflicts, err = kbfsOps.GetConflicts(ctx, fb)
+		require.NoError(t, err)
+		require.False(t, conflicts.Unmerged)
+		require.Len(t, conflicts.Conflicts, 1)
+		cs := conflicts.Conflicts[0]
+		require.Equal(t, "a/b", cs.OriginalPath)
+		require.Equal(t, "a/"+copyName, cs.ConflictPath)
+		require.Equal(t, "u1", cs.OriginalWriter)
+		require.Equal(t, "u2", cs.ConflictWriter)
+		require.Equal(t, ConflictOutstanding, cs.State)
+		require.True(t, cs.ResolvedRevision > cs.MergedRevision)
+	}
+
+	t.Log("User 1 picks the winner.")
+	err = kbfsOps1.ResolveConflict(ctx, fb, "a/"+copyName, winner)
+	require.NoError(t, err)
+	err = kbfsOps2.SyncFromServer(ctx, fb, nil)
+	require.NoError(t, err)
+
+	expectedData := data1
+	if winner == ConflictWinnerCopy {
+		expectedData = data2
+	}
+	for _, u := range []struct {
+		kbfsOps KBFSOps
+		dir     Node
+	}{{kbfsOps1, dirA1}, {kbfsOps2, dirA2}} {
+		children, err := u.kbfsOps.GetDirChildren(ctx, u.dir)
+		require.NoError(t, err)
+		require.Len(t, children, 1)
+		n, _, err := u.kbfsOps.Lookup(ctx, u.dir, "b")
+		require.NoError(t, err)
+		buf := make([]byte, 4)
+		nr, err := u.kbfsOps.Read(ctx, n, buf, 0)
+		require.NoError(t, err)
+		require.Equal(t, expectedData, buf[:nr])
+
+		conflicts, err = u.kbfsOps.GetConflicts(ctx, fb)
+		require.NoError(t, err)
+		require.Len(t, conflicts.Conflicts, 1)
+		cs := conflicts.Conflicts[0]
+		require.Equal(t, ConflictResolved, cs.State)
+		require.Equal(t, winner, cs.Winner)
+		require.Equal(t, "u1", cs.DecidedBy)
+		require.Equal(t, conflicts.HeadRevision, cs.DecisionRevision)
+	}
+
+	t.Log("A conflict can only be resolved once.")
+	err = kbfsOps2.ResolveConflict(ctx, fb, "a/"+copyName, winner)
+	require.IsType(t, NoSuchConflictError{}, err)
+}
+
+// Tests that CR records the conflict copies it makes, and that a
+// user can keep the original version of a conflicted file.
+func TestCRConflictInventoryKeepOriginal(t *testing.T) {
+	testCRConflictInventory(t, ConflictWinnerOriginal)
+}
+
+// Tests that a user can keep the conflict copy of a conflicted file
+// instead of the original version.
+func TestCRConflictInventoryKeepCopy(t *testing.T) {
+	testCRConflictInventory(t, ConflictWinnerCopy)
+}
+
+// Tests that CR records the writer of the merged version of a
+// conflicted file, rather than whoever wrote the latest merged
+// revision.
+func TestCRConflictInventoryWriters(t *testing.T) {
+	var userName1, userName2, userName3 libkb.NormalizedUsername = "u1",
+		"u2", "u3"
+	config1, _, ctx, cancel := kbfsOpsConcurInit(
+		t, userName1, userName2, userName3)
+	defer kbfsConcurTestShutdown(t, config1, ctx, cancel)
+
+	config2 := ConfigAsUser(config1, userName2)
+	defer CheckConfigAndShutdown(ctx, t, config2)
+	config3 := ConfigAsUser(config1, userName3)
+	defer CheckConfigAndShutdown(ctx, t, config3)
+
+	name := userName1.String() + "," + userName2.String() + "," +
+		userName3.String()
+
+	rootNode1 := GetRootNodeOrBust(ctx, t, config1, name, tlf.Private)
+	fb := rootNode1.GetFolderBranch()
+	kbfsOps1 := config1.KBFSOps()
+	fileB1, _, err := kbfsOps1.CreateFile(ctx, rootNode1, "b", false, NoExcl)
+	require.NoError(t, err)
+	err = kbfsOps1.SyncAll(ctx, fb)
+	require.NoError(t, err)
+
+	rootNode2 := GetRootNodeOrBust(ctx, t, config2, name, tlf.Private)
+	kbfsOps2 := config2.KBFSOps()
+	fileB2, _, err := kbfsOps2.Lookup(ctx, rootNode2, "b")
+	require.NoError(t, err)
+	rootNode3 := GetRootNodeOrBust(ctx, t, config3, name, tlf.Private)
+	kbfsOps3 := config3.KBFSOps()
+
+	c, err := DisableUpdatesForTesting(config2, fb)
+	require.NoError(t, err)
+	err = DisableCRForTesting(config2, fb)
+	require.NoError(t, err)
+
+	t.Log("User 1 writes the file, and then user 3 writes another one.")
+	err = kbfsOps1.Write(ctx, fileB1, []byte{1, 2, 3}, 0)
+	require.NoError(t, err)
+	err = kbfsOps1.SyncAll(ctx, fb)
+	require.NoError(t, err)
+	err = kbfsOps3.SyncFromServer(ctx, fb, nil)
+	require.NoError(t, err)
+	_, _, err = kbfsOps3.CreateFile(ctx, rootNode3, "c", false, NoExcl)
+	require.NoError(t, err)
+	err = kbfsOps3.SyncAll(ctx, fb)
+	require.NoError(t, err)
+
+	t.Log("User 2 writes the file on an unmerged branch.")
+	err = kbfsOps2.Write(ctx, fileB2, []byte{4, 5, 6}, 0)
+	require.NoError(t, err)
+	err = kbfsOps2.SyncAll(ctx, fb)
+	require.NoError(t, err)
+
+	c <- struct{}{}
+	err = RestartCRForTesting(
+		BackgroundContextWithCancellationDelayer(), config2, fb)
+	require.NoError(t, err)
+	err = kbfsOps2.SyncFromServer(ctx, fb, nil)
+	require.NoError(t, err)
+
+	conflicts, err := kbfsOps2.GetConflicts(ctx, fb)
+	require.NoError(t, err)
+	require.Len(t, conflicts.Conflicts, 1)
+	cs := conflicts.Conflicts[0]
+	require.Equal(t, "b", cs.OriginalPath)
+	require.Equal(t, "u1", cs.OriginalWriter)
+	require.Equal(t, "u2", cs.ConflictWriter)
+}
+
+// Tests that CR names conflict copies according to the
+// ConflictRenaming stored in the TLF.
+func TestCRFileConflictRenamingTemplate(t *testing.T) {
+	// simulate two users
+	var userName1, userName2 libkb.NormalizedUsername = "u1", "u2"
+	config1, _, ctx, cancel := kbfsOpsConcurInit(t, userName1, userName2)
+	defer kbfsConcurTestShutdown(t, config1, ctx, cancel)
+
+	config2 := ConfigAsUser(config1, userName2)
+	defer CheckConfigAndShutdown(ctx, t, config2)
+
+	name := userName1.String() + "," + userName2.String()
+
+	// user1 creates two files in a shared dir, and sets up the
+	// renaming scheme
+	rootNode1 := GetRootNodeOrBust(ctx, t, config1, name, tlf.Private)
+	fb := rootNode1.GetFolderBranch()
+
+	kbfsOps1 := config1.KBFSOps()
+	dirA1, _, err := kbfsOps1.CreateDir(ctx, rootNode1, "a")
+	require.NoError(t, err)
+	fileB1, _, err := kbfsOps1.CreateFile(ctx, dirA1, "b.txt", false, NoExcl)
+	require.NoError(t, err)
+	fileC1, _, err := kbfsOps1.CreateFile(ctx, dirA1, "c.dat", false, NoExcl)
+	require.NoError(t, err)
+	// This one is in the way of the conflict copy of c.dat.
+	_, _, err = kbfsOps1.CreateFile(
+		ctx, dirA1, "c.dat.conflict-u2", false, NoExcl)
+	require.NoError(t, err)
+	err = kbfsOps1.SyncAll(ctx, fb)
+	require.NoError(t, err)
+
+	err = kbfsOps1.SetConflictRenaming(ctx, fb, ConflictRenaming{
+		Template: "{base}{ext}"})
+	require.Error(t, err)
+	renaming := ConflictRenaming{
+		Rules: []ConflictRenameRule{{
+			Pattern:  "a/*.txt",
+			Template: "{base}.conflict-{user}-{device}{ext}",
+		}},
+		Template: "{base}{ext}.conflict-{user}",
+	}
+	err = kbfsOps1.SetConflictRenaming(ctx, fb, renaming)
+	require.NoError(t, err)
+
+	// look them up on user2
+	rootNode2 := GetRootNodeOrBust(ctx, t, config2, name, tlf.Private)
+
+	kbfsOps2 := config2.KBFSOps()
+	renaming2, err := kbfsOps2.GetConflictRenaming(ctx, fb)
+	require.NoError(t, err)
+	require.Equal(t, renaming.Rules[0].Template, renaming2.Rules[0].Template)
+	require.Equal(t, renaming.Template, renaming2.Template)
+	dirA2, _, err := kbfsOps2.Lookup(ctx, rootNode2, "a")
+	require.NoError(t, err)
+	fileB2, _, err := kbfsOps2.Lookup(ctx, dirA2, "b.txt")
+	require.NoError(t, err)
+	fileC2, _, err := kbfsOps2.Lookup(ctx, dirA2, "c.dat")
+	require.NoError(t, err)
+
+	// disable updates and CR on user 2
+	c, err := DisableUpdatesForTesting(config2, fb)
+	require.NoError(t, err)
+	err = DisableCRForTesting(config2, fb)
+	require.NoError(t, err)
+
+	// Both users write both files.
+	for _, f := range []Node{fileB1, fileC1} {
+		err = kbfsOps1.Write(ctx, f, []byte{1}, 0)
+		require.NoError(t, err)
+	}
+	err = kbfsOps1.SyncAll(ctx, fb)
+	require.NoError(t, err)
+	for _, f := range []Node{fileB2, fileC2} {
+		err = kbfsOps2.Write(ctx, f, []byte{2}, 0)
+		require.NoError(t, err)
+	}
+	err = kbfsOps2.SyncAll(ctx, fb)
+	require.NoError(t, err)
+
+	// re-enable updates, and wait for CR to complete
+	c <- struct{}{}
+	err = RestartCRForTesting(
+		BackgroundContextWithCancellationDelayer(), config2, fb)
+	require.NoError(t, err)
+	err = kbfsOps2.SyncFromServer(ctx, fb, nil)
+	require.NoError(t, err)
+	err = kbfsOps1.SyncFromServer(ctx, fb, nil)
+	require.NoError(t, err)
+
+	for _, u := range []struct {
+		kbfsOps KBFSOps
+		dir     Node
+	}{{kbfsOps1, dirA1}, {kbfsOps2, dirA2}} {
+		children, err := u.kbfsOps.GetDirChildren(ctx, u.dir)
+		require.NoError(t, err)
+		require.Len(t, children, 5)
+		for _, child := range []string{
+			"b.txt", "b.conflict-u2-dev1.txt", "c.dat", "c.dat.conflict-u2",
+			"c (2).dat.conflict-u2",
+		} {
+			require.Contains(t, children, child)
+		}
+	}
+}
+
 // Tests that two users can create the same file simultaneously, and
 // the unmerged user can write to it, and they will be merged into a
 // single file.
@@ -6,6 +6,7 @@ package libkbfs
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -56,8 +57,8 @@ func NewKBFSOpsStandard(config Config) *KBFSOpsStandard {
 		ops:                   make(map[FolderBranch]*folderBranchOps),
 		opsByFav:              make(map[Favorite]*folderBranchOps),
 		reIdentifyControlChan: make(chan chan<- struct{}),
-		favs:       NewFavorites(config),
-		quotaUsage: NewEventuallyConsistentQuotaUsage(config, "KBFSOps"),
+		favs:                  NewFavorites(config),
+		quotaUsage:            NewEventuallyConsistentQuotaUsage(config, "KBFSOps"),
 		longOperationDebugDumper: NewImpatientDebugDumper(
 			config, longOperationDebugDumpDuration),
 	}
@@ -830,6 +831,45 @@ func (fs *KBFSOpsStandard) FolderStatus(
 	return ops.FolderStatus(ctx, folderBranch)
 }
 
+// getOpenTLFHeads returns the heads of all the TLFs that have been
+// initialized in this process.
+func (fs *KBFSOpsStandard) getOpenTLFHeads() []ImmutableRootMetadata {
+	fs.opsLock.RLock()
+	defer fs.opsLock.RUnlock()
+	var heads []ImmutableRootMetadata
+	for fb, fbo := range fs.ops {
+		if fb.Branch != MasterBranch {
+			continue
+		}
+		lState := makeFBOLockState()
+		md, _ := fbo.getHead(lState)
+		if md == (ImmutableRootMetadata{}) {
+			continue
+		}
+		heads = append(heads, md)
+	}
+	return heads
+}
+
+// getOfflineTLFNames returns the sorted canonical paths of all the
+// TLFs that have been initialized in this process, split into those
+// that can be served from local caches while the MD server is
+// unreachable, and those that can't.
+func (fs *KBFSOpsStandard) getOfflineTLFNames(ctx context.Context) (
+	offline, unavailable []string) {
+	for _, md := range fs.getOpenTLFHeads() {
+		name := md.GetTlfHandle().GetCanonicalPath()
+		if canServeTlfOffline(ctx, fs.config, md) {
+			offline = append(offline, name)
+		} else {
+			unavailable = append(unavailable, name)
+		}
+	}
+	sort.Strings(offline)
+	sort.Strings(unavailable)
+	return offline, unavailable
+}
+
 // Status implements the KBFSOps interface for KBFSOpsStandard
 func (fs *KBFSOpsStandard) Status(ctx context.Context) (
 	KBFSStatus, <-chan StatusUpdate, error) {
@@ -878,16 +918,40 @@ func (fs *KBFSOpsStandard) Status(ctx context.Context) (
 		dbcStatus = dbc.Status(ctx)
 	}
 
+	var bandwidthLimits *BandwidthSchedule
+	if bl := fs.config.BandwidthLimiter(); bl != nil {
+		schedule := bl.Schedule()
+		if len(schedule.Limits) > 0 || len(schedule.Schedule) > 0 {
+			bandwidthLimits = &schedule
+		}
+	}
+
+	var chargedTo keybase1.UserOrTeamID
+	if err == nil {
+		chargedTo = session.UID.AsUserOrTeam()
+	}
+	localStorage := getLocalStorageStatus(ctx, fs.config, chargedTo)
+
+	isConnected := fs.config.MDServer().IsConnected()
+	var offlineTLFs, unavailableTLFs []string
+	if !isConnected {
+		offlineTLFs, unavailableTLFs = fs.getOfflineTLFNames(ctx)
+	}
+
 	return KBFSStatus{
 		CurrentUser:     session.Name.String(),
-		IsConnected:     fs.config.MDServer().IsConnected(),
+		IsConnected:     isConnected,
 		UsageBytes:      usageBytes,
 		LimitBytes:      limitBytes,
 		GitUsageBytes:   gitUsageBytes,
 		GitLimitBytes:   gitLimitBytes,
 		FailingServices: failures,
+		OfflineTLFs:     offlineTLFs,
+		UnavailableTLFs: unavailableTLFs,
 		JournalServer:   jServerStatus,
 		DiskCacheStatus: dbcStatus,
+		BandwidthLimits: bandwidthLimits,
+		LocalStorage:    &localStorage,
 	}, ch, err
 }
 
@@ -943,6 +1007,49 @@ func (fs *KBFSOpsStandard) GetEditHistory(ctx context.Context,
 	return ops.GetEditHistory(ctx, folderBranch)
 }
 
+// GetConflicts implements the KBFSOps interface for KBFSOpsStandard
+func (fs *KBFSOpsStandard) GetConflicts(ctx context.Context,
+	folderBranch FolderBranch) (conflicts TlfConflicts, err error) {
+	timeTrackerDone := fs.longOperationDebugDumper.Begin(ctx)
+	defer timeTrackerDone()
+
+	ops := fs.getOps(ctx, folderBranch, FavoritesOpAdd)
+	return ops.GetConflicts(ctx, folderBranch)
+}
+
+// ResolveConflict implements the KBFSOps interface for KBFSOpsStandard
+func (fs *KBFSOpsStandard) ResolveConflict(ctx context.Context,
+	folderBranch FolderBranch, conflictPath string,
+	winner ConflictWinner) error {
+	timeTrackerDone := fs.longOperationDebugDumper.Begin(ctx)
+	defer timeTrackerDone()
+
+	ops := fs.getOps(ctx, folderBranch, FavoritesOpAdd)
+	return ops.ResolveConflict(ctx, folderBranch, conflictPath, winner)
+}
+
+// GetConflictRenaming implements the KBFSOps interface for
+// KBFSOpsStandard
+func (fs *KBFSOpsStandard) GetConflictRenaming(ctx context.Context,
+	folderBranch FolderBranch) (renaming ConflictRenaming, err error) {
+	timeTrackerDone := fs.longOperationDebugDumper.Begin(ctx)
+	defer timeTrackerDone()
+
+	ops := fs.getOps(ctx, folderBranch, FavoritesOpAdd)
+	return ops.GetConflictRenaming(ctx, folderBranch)
+}
+
+// SetConflictRenaming implements the KBFSOps interface for
+// KBFSOpsStandard
+func (fs *KBFSOpsStandard) SetConflictRenaming(ctx context.Context,
+	folderBranch FolderBranch, renaming ConflictRenaming) error {
+	timeTrackerDone := fs.longOperationDebugDumper.Begin(ctx)
+	defer timeTrackerDone()
+
+	ops := fs.getOps(ctx, folderBranch, FavoritesOpAdd)
+	return ops.SetConflictRenaming(ctx, folderBranch, renaming)
+}
+
 // GetNodeMetadata implements the KBFSOps interface for KBFSOpsStandard
 func (fs *KBFSOpsStandard) GetNodeMetadata(ctx context.Context, node Node) (
 	NodeMetadata, error) {
@@ -953,6 +1060,17 @@ func (fs *KBFSOpsStandard) GetNodeMetadata(ctx context.Context, node Node) (
 	return ops.GetNodeMetadata(ctx, node)
 }
 
+// GetPrefetchStatus implements the KBFSOps interface for
+// KBFSOpsStandard.
+func (fs *KBFSOpsStandard) GetPrefetchStatus(ctx context.Context, node Node) (
+	NodePrefetchStatus, error) {
+	timeTrackerDone := fs.longOperationDebugDumper.Begin(ctx)
+	defer timeTrackerDone()
+
+	ops := fs.getOpsByNode(ctx, node)
+	return ops.GetPrefetchStatus(ctx, node)
+}
+
 func (fs *KBFSOpsStandard) findTeamByID(
 	ctx context.Context, tid keybase1.TeamID) *folderBranchOps {
 	fs.opsLock.Lock()
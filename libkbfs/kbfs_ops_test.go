@@ -112,7 +112,8 @@ func kbfsOpsInit(t *testing.T) (mockCtrl *gomock.Controller,
 	// Ignore BlockRetriever calls
 	brc := &testBlockRetrievalConfig{nil, newTestLogMaker(t),
 		config.BlockCache(), nil, newTestDiskBlockCacheGetter(t, nil),
-		newTestSyncedTlfGetterSetter(), testInitModeGetter{InitDefault}}
+		newTestSyncedTlfGetterSetter(), testInitModeGetter{InitDefault},
+		config.Clock()}
 	brq := newBlockRetrievalQueue(0, 0, brc)
 	config.mockBops.EXPECT().BlockRetriever().AnyTimes().Return(brq)
 	// Ignore Prefetcher calls
@@ -2681,6 +2682,69 @@ func TestKBFSOpsTruncateRemovesABlock(t *testing.T) {
 		})
 }
 
+func TestKBFSOpsTruncateIntoHole(t *testing.T) {
+	mockCtrl, config, ctx, cancel := kbfsOpsInit(t)
+	defer kbfsTestShutdown(mockCtrl, config, ctx, cancel)
+
+	uid, id, rmd := injectNewRMD(t, config)
+
+	rootID := kbfsblock.FakeID(42)
+	fileID := kbfsblock.FakeID(43)
+	id1 := kbfsblock.FakeID(44)
+	id2 := kbfsblock.FakeID(45)
+	rootBlock := NewDirBlock().(*DirBlock)
+	fileInfo := makeBIFromID(fileID, uid)
+	rootBlock.Children["f"] = DirEntry{
+		BlockInfo: fileInfo,
+		EntryInfo: EntryInfo{
+			Size: 15,
+		},
+	}
+	// There's a hole between offsets 5 and 10.
+	fileBlock := NewFileBlock().(*FileBlock)
+	fileBlock.IsInd = true
+	fileBlock.IPtrs = []IndirectFilePtr{
+		makeIFP(id1, rmd, config, uid, 5, 0),
+		makeIFP(id2, rmd, config, uid, 6, 10),
+	}
+	block1 := NewFileBlock().(*FileBlock)
+	block1.Contents = []byte{5, 4, 3, 2, 1}
+	block2 := NewFileBlock().(*FileBlock)
+	block2.Contents = []byte{10, 9, 8, 7, 6}
+	node := pathNode{makeBP(rootID, rmd, config, uid), "p"}
+	fileNode := pathNode{makeBP(fileID, rmd, config, uid), "f"}
+	p := path{FolderBranch{Tlf: id}, []pathNode{node, fileNode}}
+	ops := getOps(config, id)
+	n := nodeFromPath(t, ops, p)
+	so, err := newSyncOp(fileInfo.BlockPointer)
+	require.NoError(t, err)
+	rmd.AddOp(so)
+
+	testPutBlockInCache(t, config, node.BlockPointer, id, rootBlock)
+	testPutBlockInCache(t, config, fileNode.BlockPointer, id, fileBlock)
+	testPutBlockInCache(t, config, fileBlock.IPtrs[0].BlockPointer, id, block1)
+	testPutBlockInCache(t, config, fileBlock.IPtrs[1].BlockPointer, id, block2)
+	config.mockBsplit.EXPECT().CopyUntilSplit(
+		gomock.Any(), gomock.Any(), []byte{0, 0}, int64(5)).
+		Do(func(block *FileBlock, lb bool, data []byte, off int64) {
+			block.Contents = append(block.Contents, data...)
+		}).Return(int64(2))
+
+	err = config.KBFSOps().Truncate(ctx, n, 7)
+	require.NoError(t, err)
+
+	// The file is first cut off at the start of the hole, and then
+	// extended back out, and observers must hear about both.
+	require.Equal(t, []WriteRange{{Off: 5, Len: 0}, {Off: 5, Len: 2}},
+		config.observer.localWrites)
+
+	data := make([]byte, 7)
+	nr, err := config.KBFSOps().Read(ctx, n, data, 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(7), nr)
+	require.Equal(t, []byte{5, 4, 3, 2, 1, 0, 0}, data)
+}
+
 func TestKBFSOpsTruncateBiggerSuccess(t *testing.T) {
 	mockCtrl, config, ctx, cancel := kbfsOpsInit(t)
 	defer kbfsTestShutdown(mockCtrl, config, ctx, cancel)
@@ -3107,6 +3171,48 @@ func TestKBFSOpsWriteRenameStat(t *testing.T) {
 	}
 }
 
+func TestKBFSOpsRenameSyncedPath(t *testing.T) {
+	config, _, ctx, cancel := kbfsOpsInitNoMocks(t, "test_user")
+	defer kbfsTestShutdownNoMocks(t, config, ctx, cancel)
+	dbc, _ := initDiskBlockCacheTest(t)
+	config.diskBlockCache = dbc
+
+	t.Log("Create a/aa and pin it.")
+	rootNode := GetRootNodeOrBust(ctx, t, config, "test_user", tlf.Private)
+	tlfID := rootNode.GetFolderBranch().Tlf
+	kbfsOps := config.KBFSOps()
+	aNode, _, err := kbfsOps.CreateDir(ctx, rootNode, "a")
+	require.NoError(t, err)
+	aaNode, _, err := kbfsOps.CreateDir(ctx, aNode, "aa")
+	require.NoError(t, err)
+	_, _, err = kbfsOps.CreateDir(ctx, rootNode, "b")
+	require.NoError(t, err)
+	err = config.SetTlfPathSyncState(tlfID, "a/aa", true)
+	require.NoError(t, err)
+
+	t.Log("Renaming an unrelated directory doesn't change the pin.")
+	err = kbfsOps.Rename(ctx, rootNode, "b", aaNode, "b")
+	require.NoError(t, err)
+	require.Equal(t, []string{"a/aa"}, config.GetTlfSyncedPaths(tlfID))
+
+	t.Log("Renaming a parent of the synced path moves the pin.")
+	err = kbfsOps.Rename(ctx, rootNode, "a", rootNode, "c")
+	require.NoError(t, err)
+	require.Equal(t, []string{"c/aa"}, config.GetTlfSyncedPaths(tlfID))
+
+	t.Log("So does renaming the synced path itself.")
+	err = kbfsOps.Rename(ctx, aNode, "aa", rootNode, "d")
+	require.NoError(t, err)
+	require.Equal(t, []string{"d"}, config.GetTlfSyncedPaths(tlfID))
+
+	t.Log("Unpin it.")
+	err = config.SetTlfPathSyncState(tlfID, "d", false)
+	require.NoError(t, err)
+	require.Len(t, config.GetTlfSyncedPaths(tlfID), 0)
+	err = kbfsOps.SyncAll(ctx, rootNode.GetFolderBranch())
+	require.NoError(t, err)
+}
+
 func TestKBFSOpsWriteRenameGetDirChildren(t *testing.T) {
 	config, _, ctx, cancel := kbfsOpsInitNoMocks(t, "test_user")
 	// TODO: Use kbfsTestShutdownNoMocks.
@@ -3793,3 +3899,105 @@ func TestKBFSOpsMigratePublicV2ToImplicitTeam(t *testing.T) {
 	testKBFSOpsMigrateToImplicitTeam(
 		t, tlf.Public, kbfsmd.InitialExtraMetadataVer)
 }
+
+// readAheadStallingBlockServer stalls the block gets made on behalf
+// of read-aheads, until unstall is closed.
+type readAheadStallingBlockServer struct {
+	BlockServer
+	stalled chan<- struct{}
+	unstall <-chan struct{}
+}
+
+func (bs readAheadStallingBlockServer) Get(
+	ctx context.Context, tlfID tlf.ID, id kbfsblock.ID,
+	context kbfsblock.Context) (
+	[]byte, kbfscrypto.BlockCryptKeyServerHalf, error) {
+	if ctx.Value(ctxReadAheadIDKey) != nil {
+		select {
+		case bs.stalled <- struct{}{}:
+		default:
+		}
+		select {
+		case <-bs.unstall:
+		case <-ctx.Done():
+			return nil, kbfscrypto.BlockCryptKeyServerHalf{}, ctx.Err()
+		}
+	}
+	return bs.BlockServer.Get(ctx, tlfID, id, context)
+}
+
+func TestKBFSOpsReadAheadInBackground(t *testing.T) {
+	config, _, ctx, cancel := kbfsOpsInitNoMocks(t, "test_user")
+	defer kbfsTestShutdownNoMocks(t, config, ctx, cancel)
+
+	// Make the blocks small, with multiple levels of indirection.
+	bsplit := &BlockSplitterSimple{10, 2, 100 * 1024}
+	config.SetBlockSplitter(bsplit)
+
+	rootNode := GetRootNodeOrBust(ctx, t, config, "test_user", tlf.Private)
+	kbfsOps := config.KBFSOps()
+	fileNode, _, err := kbfsOps.CreateFile(ctx, rootNode, "a", false, NoExcl)
+	require.NoError(t, err)
+	data := make([]byte, 200)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	err = kbfsOps.Write(ctx, fileNode, data, 0)
+	require.NoError(t, err)
+	err = kbfsOps.SyncAll(ctx, fileNode.GetFolderBranch())
+	require.NoError(t, err)
+
+	t.Log("Read the file from another device with an empty cache, " +
+		"while stalling the read-ahead.")
+	config2 := ConfigAsUser(config, "test_user")
+	defer CheckConfigAndShutdown(ctx, t, config2)
+	stalled := make(chan struct{}, 1)
+	unstall := make(chan struct{})
+	bserv2 := config2.BlockServer()
+	config2.SetBlockServer(readAheadStallingBlockServer{
+		bserv2, stalled, unstall})
+	rootNode2 := GetRootNodeOrBust(
+		ctx, t, config2, "test_user", tlf.Private)
+	kbfsOps2 := config2.KBFSOps()
+	fileNode2, _, err := kbfsOps2.Lookup(ctx, rootNode2, "a")
+	require.NoError(t, err)
+	buf := make([]byte, 10)
+	n, err := kbfsOps2.Read(ctx, fileNode2, buf, 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(10), n)
+	require.Equal(t, data[:10], buf)
+
+	t.Log("The read-ahead fetches its indirect blocks in the " +
+		"background, so the file can be written while it's stalled.")
+	select {
+	case <-stalled:
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	}
+	err = kbfsOps2.Write(ctx, fileNode2, []byte{1}, 0)
+	require.NoError(t, err)
+
+	t.Log("Once unstalled, the read-ahead requests the next blocks.")
+	close(unstall)
+	rat := getOps(config2, rootNode2.GetFolderBranch().Tlf).blocks.readAhead
+	for {
+		rat.lock.Lock()
+		tmp, _ := rat.files.Peek(fileNode2.GetID())
+		state := *tmp.(*readAheadState)
+		rat.lock.Unlock()
+		if !state.lookingUp {
+			require.Equal(t, int64(10+readAheadMinWindow*10),
+				state.requestedEnd)
+			break
+		}
+		select {
+		case <-time.After(10 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatal(ctx.Err())
+		}
+	}
+	err = kbfsOps2.SyncAll(ctx, fileNode2.GetFolderBranch())
+	require.NoError(t, err)
+	// The state checker needs the unwrapped block server.
+	config2.SetBlockServer(bserv2)
+}
@@ -5,15 +5,18 @@
 package libkbfs
 
 import (
-	lru "github.com/hashicorp/golang-lru"
+	"reflect"
+
+	"github.com/keybase/kbfs/cache"
 	"github.com/keybase/kbfs/kbfscrypto"
 	"github.com/keybase/kbfs/kbfsmd"
 	"github.com/keybase/kbfs/tlf"
 )
 
-// KeyCacheStandard is an LRU-based implementation of the KeyCache interface.
+// KeyCacheStandard is an implementation of the KeyCache interface,
+// using any of the eviction policies of the cache package.
 type KeyCacheStandard struct {
-	lru *lru.Cache
+	cache cache.Cache
 }
 
 type keyCacheKey struct {
@@ -21,25 +24,59 @@ type keyCacheKey struct {
 	keyGen kbfsmd.KeyGen
 }
 
+var keyCacheKeySize = int(reflect.TypeOf(keyCacheKey{}).Size())
+
+// Size implements the cache.Measurable interface for keyCacheKey.
+func (keyCacheKey) Size() int {
+	return keyCacheKeySize
+}
+
+// keyCacheEntry makes a kbfscrypto.TLFCryptKey measurable.
+type keyCacheEntry struct {
+	key kbfscrypto.TLFCryptKey
+}
+
+var keyCacheEntrySize = int(reflect.TypeOf(keyCacheEntry{}).Size())
+
+// Size implements the cache.Measurable interface for keyCacheEntry.
+func (keyCacheEntry) Size() int {
+	return keyCacheEntrySize
+}
+
 var _ KeyCache = (*KeyCacheStandard)(nil)
 
-// NewKeyCacheStandard constructs a new KeyCacheStandard with the given
-// cache capacity.
+// NewKeyCacheStandardWithPolicy constructs a new KeyCacheStandard
+// that holds up to the given number of keys, and evicts them using
+// the given policy.
+func NewKeyCacheStandardWithPolicy(
+	policy cache.EvictionPolicy, capacity int) (*KeyCacheStandard, error) {
+	// All entries are the same size, so a byte capacity is the
+	// same as a capacity in entries.
+	c, err := cache.NewCache(
+		policy, capacity*(keyCacheKeySize+keyCacheEntrySize))
+	if err != nil {
+		return nil, err
+	}
+	return &KeyCacheStandard{c}, nil
+}
+
+// NewKeyCacheStandard constructs a new LRU KeyCacheStandard with the
+// given cache capacity.
 func NewKeyCacheStandard(capacity int) *KeyCacheStandard {
-	head, err := lru.New(capacity)
+	k, err := NewKeyCacheStandardWithPolicy(cache.EvictionPolicyLRU, capacity)
 	if err != nil {
 		panic(err.Error())
 	}
-	return &KeyCacheStandard{head}
+	return k
 }
 
 // GetTLFCryptKey implements the KeyCache interface for KeyCacheStandard.
 func (k *KeyCacheStandard) GetTLFCryptKey(tlf tlf.ID, keyGen kbfsmd.KeyGen) (
 	kbfscrypto.TLFCryptKey, error) {
 	cacheKey := keyCacheKey{tlf, keyGen}
-	if entry, ok := k.lru.Get(cacheKey); ok {
-		if key, ok := entry.(kbfscrypto.TLFCryptKey); ok {
-			return key, nil
+	if entry, ok := k.cache.Get(cacheKey); ok {
+		if e, ok := entry.(keyCacheEntry); ok {
+			return e.key, nil
 		}
 		// shouldn't really be possible
 		return kbfscrypto.TLFCryptKey{}, KeyCacheHitError{tlf, keyGen}
@@ -51,6 +88,6 @@ func (k *KeyCacheStandard) GetTLFCryptKey(tlf tlf.ID, keyGen kbfsmd.KeyGen) (
 func (k *KeyCacheStandard) PutTLFCryptKey(
 	tlf tlf.ID, keyGen kbfsmd.KeyGen, key kbfscrypto.TLFCryptKey) error {
 	cacheKey := keyCacheKey{tlf, keyGen}
-	k.lru.Add(cacheKey, key)
+	k.cache.Add(cacheKey, keyCacheEntry{key})
 	return nil
 }
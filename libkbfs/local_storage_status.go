@@ -0,0 +1,204 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libkbfs
+
+import (
+	"sort"
+
+	"github.com/keybase/client/go/protocol/keybase1"
+	"github.com/keybase/kbfs/tlf"
+	"golang.org/x/net/context"
+)
+
+// LocalStorageStatus is a consolidated view of what's using local
+// disk space on this device: the TLF journals, the disk block caches,
+// and the disk limiter that keeps them within bounds.  It is suitable
+// for encoding directly as JSON.
+type LocalStorageStatus struct {
+	StorageRoot string
+	// FreeBytes and FreeFiles are what's free on the disk holding
+	// StorageRoot, or -1 if that's unknown.
+	FreeBytes int64
+	FreeFiles int64
+	// UsedBytes is JournalBytes plus CacheBytes.
+	UsedBytes             int64
+	JournalBytes          int64
+	JournalFiles          int64
+	JournalUnflushedBytes int64
+	CacheBytes            int64
+	// TLFs lists the local disk usage of each TLF with a journal or
+	// cached blocks, biggest first.
+	TLFs []LocalStorageTLFStatus `json:",omitempty"`
+	// DiskCaches maps the name of each disk block cache to its
+	// usage.
+	DiskCaches map[string]LocalStorageCacheStatus `json:",omitempty"`
+	// Limiter is set if the disk limiter applies backpressure.
+	Limiter *LocalStorageLimiterStatus `json:",omitempty"`
+}
+
+// LocalStorageTLFStatus is the local disk usage of a single TLF.
+type LocalStorageTLFStatus struct {
+	TlfID tlf.ID
+	// TotalBytes is JournalBytes plus the bytes in CacheBytes.
+	TotalBytes            int64
+	JournalBytes          int64 `json:",omitempty"`
+	JournalFiles          int64 `json:",omitempty"`
+	JournalUnflushedBytes int64 `json:",omitempty"`
+	// CacheBytes maps the name of each disk block cache holding
+	// blocks of the TLF to the number of bytes they take up.
+	CacheBytes map[string]uint64 `json:",omitempty"`
+}
+
+// LocalStorageCacheStatus is the local disk usage of a single disk
+// block cache.
+type LocalStorageCacheStatus struct {
+	IsStarting bool `json:",omitempty"`
+	NumBlocks  uint64
+	BlockBytes uint64
+	ByteLimit  uint64
+}
+
+// LocalStorageThresholds describes the usage of one resource tracked
+// by the disk limiter.  Backpressure starts once UsedFrac reaches
+// MinThreshold, and reaches its maximum delay at MaxThreshold.
+type LocalStorageThresholds struct {
+	Used         int64
+	Limit        int64
+	UsedFrac     float64
+	MinThreshold float64
+	MaxThreshold float64
+}
+
+func makeLocalStorageThresholds(
+	s backpressureTrackerStatus) LocalStorageThresholds {
+	return LocalStorageThresholds{
+		Used:         s.Used,
+		Limit:        s.Max,
+		UsedFrac:     s.UsedFrac,
+		MinThreshold: s.MinThreshold,
+		MaxThreshold: s.MaxThreshold,
+	}
+}
+
+// LocalStorageLimiterStatus describes the thresholds of the disk
+// limiter, and how close the journals and caches are to them.
+type LocalStorageLimiterStatus struct {
+	// CurrentDelaySec is the backpressure delay currently applied
+	// to each block put to a journal.
+	CurrentDelaySec      float64
+	JournalBytes         LocalStorageThresholds
+	JournalFiles         LocalStorageThresholds
+	WorkingSetCacheBytes LocalStorageThresholds
+	SyncCacheBytes       LocalStorageThresholds
+}
+
+// getLocalStorageStatus gathers the local disk usage of the journals
+// and disk block caches of the given config.  chargedTo is used to
+// compute the current backpressure delay, which also depends on the
+// quota of whoever the journal writes are charged to.
+func getLocalStorageStatus(ctx context.Context, config Config,
+	chargedTo keybase1.UserOrTeamID) LocalStorageStatus {
+	log := config.MakeLogger("")
+	status := LocalStorageStatus{
+		StorageRoot: config.StorageRoot(),
+		FreeBytes:   -1,
+		FreeFiles:   -1,
+	}
+	if status.StorageRoot != "" {
+		freeBytes, freeFiles, err :=
+			defaultGetFreeBytesAndFiles(status.StorageRoot)
+		if err != nil {
+			log.CDebugf(ctx, "Couldn't get the free space of %s: %+v",
+				status.StorageRoot, err)
+		} else {
+			status.FreeBytes = freeBytes
+			status.FreeFiles = freeFiles
+		}
+	}
+
+	tlfs := make(map[tlf.ID]*LocalStorageTLFStatus)
+	getTLF := func(tlfID tlf.ID) *LocalStorageTLFStatus {
+		tlfStatus, ok := tlfs[tlfID]
+		if !ok {
+			tlfStatus = &LocalStorageTLFStatus{TlfID: tlfID}
+			tlfs[tlfID] = tlfStatus
+		}
+		return tlfStatus
+	}
+
+	if jServer, err := GetJournalServer(config); err == nil {
+		for tlfID, counts := range jServer.byteCountsByTLF(ctx) {
+			tlfStatus := getTLF(tlfID)
+			tlfStatus.JournalBytes = counts.storedBytes
+			tlfStatus.JournalFiles = counts.storedFiles
+			tlfStatus.JournalUnflushedBytes = counts.unflushedBytes
+			tlfStatus.TotalBytes += counts.storedBytes
+			status.JournalBytes += counts.storedBytes
+			status.JournalFiles += counts.storedFiles
+			status.JournalUnflushedBytes += counts.unflushedBytes
+		}
+	}
+
+	if dbc := config.DiskBlockCache(); dbc != nil {
+		cacheStatuses := dbc.Status(ctx)
+		status.DiskCaches = make(
+			map[string]LocalStorageCacheStatus, len(cacheStatuses))
+		for name, cacheStatus := range cacheStatuses {
+			status.DiskCaches[name] = LocalStorageCacheStatus{
+				IsStarting: cacheStatus.IsStarting,
+				NumBlocks:  cacheStatus.NumBlocks,
+				BlockBytes: cacheStatus.BlockBytes,
+				ByteLimit:  cacheStatus.CurrByteLimit,
+			}
+			status.CacheBytes += int64(cacheStatus.BlockBytes)
+			for tlfIDStr, tlfCacheStatus := range cacheStatus.TLFs {
+				tlfID, err := tlf.ParseID(tlfIDStr)
+				if err != nil {
+					log.CDebugf(ctx, "Skipping unparseable TLF ID %q "+
+						"in the status of %s: %+v", tlfIDStr, name, err)
+					continue
+				}
+				tlfStatus := getTLF(tlfID)
+				if tlfStatus.CacheBytes == nil {
+					tlfStatus.CacheBytes = make(map[string]uint64)
+				}
+				tlfStatus.CacheBytes[name] = tlfCacheStatus.BlockBytes
+				tlfStatus.TotalBytes += int64(tlfCacheStatus.BlockBytes)
+			}
+		}
+	}
+	status.UsedBytes = status.JournalBytes + status.CacheBytes
+
+	status.TLFs = make([]LocalStorageTLFStatus, 0, len(tlfs))
+	for _, tlfStatus := range tlfs {
+		status.TLFs = append(status.TLFs, *tlfStatus)
+	}
+	sort.Slice(status.TLFs, func(i, j int) bool {
+		a, b := status.TLFs[i], status.TLFs[j]
+		if a.TotalBytes != b.TotalBytes {
+			return a.TotalBytes > b.TotalBytes
+		}
+		return a.TlfID.String() < b.TlfID.String()
+	})
+
+	if dl := config.DiskLimiter(); dl != nil {
+		// Only the backpressure limiter has thresholds to show.
+		if bdlStatus, ok := dl.getStatus(
+			ctx, chargedTo).(backpressureDiskLimiterStatus); ok {
+			jStatus := bdlStatus.JournalTrackerStatus
+			status.Limiter = &LocalStorageLimiterStatus{
+				CurrentDelaySec: bdlStatus.CurrentDelaySec,
+				JournalBytes:    makeLocalStorageThresholds(jStatus.ByteStatus),
+				JournalFiles:    makeLocalStorageThresholds(jStatus.FileStatus),
+				WorkingSetCacheBytes: makeLocalStorageThresholds(
+					bdlStatus.DiskCacheByteStatus),
+				SyncCacheBytes: makeLocalStorageThresholds(
+					bdlStatus.SyncCacheByteStatus),
+			}
+		}
+	}
+
+	return status
+}
@@ -0,0 +1,102 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libkbfs
+
+import (
+	"testing"
+
+	"github.com/keybase/client/go/protocol/keybase1"
+	"github.com/keybase/kbfs/kbfsblock"
+	"github.com/keybase/kbfs/kbfscrypto"
+	"github.com/keybase/kbfs/tlf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStorageStatus(t *testing.T) {
+	tempdir, ctx, cancel, config, _, jServer := setupJournalServerTest(t)
+	defer teardownJournalServerTest(t, tempdir, ctx, cancel, config)
+
+	cache, cacheConfig := initDiskBlockCacheTest(t)
+	defer shutdownDiskBlockCacheTest(cache)
+	config.lock.Lock()
+	config.diskBlockCache = cache
+	config.lock.Unlock()
+	defer func() {
+		config.lock.Lock()
+		defer config.lock.Unlock()
+		config.diskBlockCache = nil
+	}()
+
+	// Use a shutdown-only BlockServer so that it errors if the
+	// journal tries to access it.
+	jServer.delegateBlockServer = shutdownOnlyBlockServer{}
+
+	journalTlfID := tlf.FakeID(2, tlf.Private)
+	err := jServer.Enable(
+		ctx, journalTlfID, nil, TLFJournalBackgroundWorkPaused)
+	require.NoError(t, err)
+
+	session, err := config.KBPKI().GetCurrentSession(ctx)
+	require.NoError(t, err)
+
+	t.Log("Put a block in the journal of one TLF, and in the cache " +
+		"of another.")
+	bCtx := kbfsblock.MakeFirstContext(
+		session.UID.AsUserOrTeam(), keybase1.BlockType_DATA)
+	data := []byte{1, 2, 3, 4}
+	bID, err := kbfsblock.MakePermanentID(data)
+	require.NoError(t, err)
+	serverHalf, err := kbfscrypto.MakeRandomBlockCryptKeyServerHalf()
+	require.NoError(t, err)
+	err = config.BlockServer().Put(
+		ctx, journalTlfID, bID, bCtx, data, serverHalf)
+	require.NoError(t, err)
+
+	cacheTlfID := tlf.FakeID(3, tlf.Private)
+	ptr, _, blockEncoded, cacheServerHalf := setupBlockForDiskCache(
+		t, cacheConfig)
+	err = cache.Put(ctx, cacheTlfID, ptr.ID, blockEncoded, cacheServerHalf)
+	require.NoError(t, err)
+
+	status := getLocalStorageStatus(
+		ctx, config, session.UID.AsUserOrTeam())
+
+	jStatus, _ := jServer.Status(ctx)
+	require.Equal(t, jStatus.StoredBytes, status.JournalBytes)
+	require.Equal(t, jStatus.StoredFiles, status.JournalFiles)
+	require.Equal(t, jStatus.UnflushedBytes, status.JournalUnflushedBytes)
+	require.Equal(t, int64(len(data)), status.JournalUnflushedBytes)
+	require.True(t, status.CacheBytes >= int64(len(blockEncoded)))
+	require.Equal(t, status.JournalBytes+status.CacheBytes, status.UsedBytes)
+
+	require.Len(t, status.DiskCaches, 2)
+	require.Equal(t, uint64(1),
+		status.DiskCaches[workingSetCacheName].NumBlocks)
+	require.Equal(t, uint64(status.CacheBytes),
+		status.DiskCaches[workingSetCacheName].BlockBytes)
+	require.Equal(t, uint64(0), status.DiskCaches[syncCacheName].NumBlocks)
+
+	require.Len(t, status.TLFs, 2)
+	tlfStatuses := make(map[tlf.ID]LocalStorageTLFStatus)
+	for _, tlfStatus := range status.TLFs {
+		tlfStatuses[tlfStatus.TlfID] = tlfStatus
+	}
+	journalTlfStatus := tlfStatuses[journalTlfID]
+	require.Equal(t, status.JournalBytes, journalTlfStatus.JournalBytes)
+	require.Equal(t, status.JournalBytes, journalTlfStatus.TotalBytes)
+	require.Nil(t, journalTlfStatus.CacheBytes)
+	cacheTlfStatus := tlfStatuses[cacheTlfID]
+	require.Equal(t, int64(0), cacheTlfStatus.JournalBytes)
+	require.Equal(t, map[string]uint64{
+		workingSetCacheName: uint64(status.CacheBytes),
+	}, cacheTlfStatus.CacheBytes)
+	require.Equal(t, status.CacheBytes, cacheTlfStatus.TotalBytes)
+	require.True(t, status.TLFs[0].TotalBytes >= status.TLFs[1].TotalBytes)
+
+	require.NotNil(t, status.Limiter)
+	require.Equal(t, status.JournalBytes, status.Limiter.JournalBytes.Used)
+	require.Equal(t, status.JournalFiles, status.Limiter.JournalFiles.Used)
+	require.Equal(t, float64(0), status.Limiter.CurrentDelaySec)
+}
@@ -52,9 +52,11 @@ type mdIDJournalEntry struct {
 	codec.UnknownFieldSetHandler
 }
 
-func makeMdIDJournal(codec kbfscodec.Codec, dir string) (mdIDJournal, error) {
-	j, err :=
-		makeDiskJournal(codec, dir, reflect.TypeOf(mdIDJournalEntry{}))
+func makeMdIDJournal(
+	codec kbfscodec.Codec, crypter *journalCrypter, dir string) (
+	mdIDJournal, error) {
+	j, err := makeDiskJournal(
+		codec, crypter, dir, reflect.TypeOf(mdIDJournalEntry{}))
 	if err != nil {
 		return mdIDJournal{}, err
 	}
@@ -128,6 +128,7 @@ type mdJournal struct {
 	key kbfscrypto.VerifyingKey
 
 	codec          kbfscodec.Codec
+	crypter        *journalCrypter
 	crypto         cryptoPure
 	clock          Clock
 	teamMemChecker kbfsmd.TeamMembershipChecker
@@ -159,8 +160,8 @@ type mdJournal struct {
 
 func makeMDJournalWithIDJournal(
 	ctx context.Context, uid keybase1.UID, key kbfscrypto.VerifyingKey,
-	codec kbfscodec.Codec, crypto cryptoPure, clock Clock,
-	teamMemChecker kbfsmd.TeamMembershipChecker, tlfID tlf.ID,
+	codec kbfscodec.Codec, crypter *journalCrypter, crypto cryptoPure,
+	clock Clock, teamMemChecker kbfsmd.TeamMembershipChecker, tlfID tlf.ID,
 	mdVer kbfsmd.MetadataVer, dir string, idJournal mdIDJournal,
 	log logger.Logger) (*mdJournal, error) {
 	if uid == keybase1.UID("") {
@@ -175,6 +176,7 @@ func makeMDJournalWithIDJournal(
 		uid:            uid,
 		key:            key,
 		codec:          codec,
+		crypter:        crypter,
 		crypto:         crypto,
 		clock:          clock,
 		teamMemChecker: teamMemChecker,
@@ -223,18 +225,18 @@ func mdJournalPath(dir string) string {
 
 func makeMDJournal(
 	ctx context.Context, uid keybase1.UID, key kbfscrypto.VerifyingKey,
-	codec kbfscodec.Codec, crypto cryptoPure, clock Clock,
-	teamMemChecker kbfsmd.TeamMembershipChecker, tlfID tlf.ID,
+	codec kbfscodec.Codec, crypter *journalCrypter, crypto cryptoPure,
+	clock Clock, teamMemChecker kbfsmd.TeamMembershipChecker, tlfID tlf.ID,
 	mdVer kbfsmd.MetadataVer, dir string,
 	log logger.Logger) (*mdJournal, error) {
 	journalDir := mdJournalPath(dir)
-	idJournal, err := makeMdIDJournal(codec, journalDir)
+	idJournal, err := makeMdIDJournal(codec, crypter, journalDir)
 	if err != nil {
 		return nil, err
 	}
 	return makeMDJournalWithIDJournal(
-		ctx, uid, key, codec, crypto, clock, teamMemChecker, tlfID, mdVer, dir,
-		idJournal, log)
+		ctx, uid, key, codec, crypter, crypto, clock, teamMemChecker, tlfID,
+		mdVer, dir, idJournal, log)
 }
 
 // The functions below are for building various paths.
@@ -277,6 +279,25 @@ func (j mdJournal) mdJournalDirs() []string {
 	}
 }
 
+// migrateToEncrypted encrypts any plaintext files in the journal in
+// place, i.e. the ID journal entries and everything under the MD and
+// key bundle directories.
+func (j mdJournal) migrateToEncrypted() error {
+	err := j.j.j.migrateToEncrypted()
+	if err != nil {
+		return err
+	}
+	for _, dir := range []string{
+		j.mdsPath(), j.writerKeyBundlesV3Path(), j.readerKeyBundlesV3Path(),
+	} {
+		err := j.crypter.migrateDir(dir)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (j mdJournal) mdPath(id kbfsmd.ID) string {
 	idStr := id.String()
 	return filepath.Join(j.mdsPath(), idStr[:4], idStr[4:34])
@@ -301,7 +322,7 @@ type mdInfo struct {
 
 func (j mdJournal) getMDInfo(id kbfsmd.ID) (time.Time, kbfsmd.MetadataVer, error) {
 	var info mdInfo
-	err := ioutil.DeserializeFromJSONFile(j.mdInfoPath(id), &info)
+	err := j.crypter.deserializeFromJSONFile(j.mdInfoPath(id), &info)
 	if err != nil {
 		return time.Time{}, kbfsmd.MetadataVer(-1), err
 	}
@@ -314,7 +335,7 @@ func (j mdJournal) getMDInfo(id kbfsmd.ID) (time.Time, kbfsmd.MetadataVer, error
 func (j mdJournal) putMDInfo(
 	id kbfsmd.ID, timestamp time.Time, version kbfsmd.MetadataVer) error {
 	info := mdInfo{timestamp, version}
-	return ioutil.SerializeToJSONFile(info, j.mdInfoPath(id))
+	return j.crypter.serializeToJSONFile(info, j.mdInfoPath(id))
 }
 
 // getExtraMetadata gets the extra metadata corresponding to the given
@@ -334,8 +355,11 @@ func (j mdJournal) getExtraMetadata(
 		return nil, nil
 	}
 
-	wkb, err := kbfsmd.DeserializeTLFWriterKeyBundleV3(
-		j.codec, j.writerKeyBundleV3Path(wkbID))
+	buf, err := j.crypter.readFile(j.writerKeyBundleV3Path(wkbID))
+	if err != nil {
+		return nil, err
+	}
+	wkb, err := kbfsmd.DecodeTLFWriterKeyBundleV3(j.codec, buf)
 	if err != nil {
 		return nil, err
 	}
@@ -345,8 +369,11 @@ func (j mdJournal) getExtraMetadata(
 		return nil, err
 	}
 
-	rkb, err := kbfsmd.DeserializeTLFReaderKeyBundleV3(
-		j.codec, j.readerKeyBundleV3Path(rkbID))
+	buf, err = j.crypter.readFile(j.readerKeyBundleV3Path(rkbID))
+	if err != nil {
+		return nil, err
+	}
+	rkb, err := kbfsmd.DecodeTLFReaderKeyBundleV3(j.codec, buf)
 	if err != nil {
 		return nil, err
 	}
@@ -401,13 +428,13 @@ func (j mdJournal) putExtraMetadata(rmd kbfsmd.RootMetadata, extra kbfsmd.ExtraM
 		return false, false, err
 	}
 
-	err = kbfscodec.SerializeToFileIfNotExist(
+	err = j.crypter.serializeToFileIfNotExist(
 		j.codec, extraV3.GetWriterKeyBundle(), j.writerKeyBundleV3Path(wkbID))
 	if err != nil {
 		return false, false, err
 	}
 
-	err = kbfscodec.SerializeToFileIfNotExist(
+	err = j.crypter.serializeToFileIfNotExist(
 		j.codec, extraV3.GetReaderKeyBundle(), j.readerKeyBundleV3Path(rkbID))
 	if err != nil {
 		return false, false, err
@@ -438,7 +465,7 @@ func (j mdJournal) getMDAndExtra(ctx context.Context, entry mdIDJournalEntry,
 	// Read data.
 
 	p := j.mdDataPath(entry.ID)
-	data, err := ioutil.ReadFile(p)
+	data, err := j.crypter.readFile(p)
 	if err != nil {
 		return nil, nil, time.Time{}, err
 	}
@@ -516,7 +543,7 @@ func (j mdJournal) putMD(rmd kbfsmd.RootMetadata) (kbfsmd.ID, error) {
 		return id, nil
 	}
 
-	err = kbfscodec.SerializeToFileIfNotExist(
+	err = j.crypter.serializeToFileIfNotExist(
 		j.codec, rmd, j.mdDataPath(id))
 	if err != nil {
 		return kbfsmd.ID{}, err
@@ -675,7 +702,7 @@ func (j *mdJournal) convertToBranch(
 		}
 	}()
 
-	tempJournal, err := makeMdIDJournal(j.codec, journalTempDir)
+	tempJournal, err := makeMdIDJournal(j.codec, j.crypter, journalTempDir)
 	if err != nil {
 		return err
 	}
@@ -1045,6 +1072,35 @@ func (j mdJournal) atLeastNNonLocalSquashes(
 	return !entry.IsLocalSquash, nil
 }
 
+// numNonLocalSquashes returns the number of entries in the journal
+// that aren't local squashes.
+func (j mdJournal) numNonLocalSquashes() (uint64, error) {
+	earliestRev, err := j.readEarliestRevision()
+	if err != nil {
+		return 0, err
+	}
+	if earliestRev == kbfsmd.RevisionUninitialized {
+		return 0, nil
+	}
+	latestRev, err := j.readLatestRevision()
+	if err != nil {
+		return 0, err
+	}
+
+	// The IsLocalSquash entries are a prefix of the journal, so
+	// everything after the first non-local-squash entry counts.
+	for rev := earliestRev; rev <= latestRev; rev++ {
+		entry, err := j.j.readJournalEntry(rev)
+		if err != nil {
+			return 0, err
+		}
+		if !entry.IsLocalSquash {
+			return uint64(latestRev-rev) + 1, nil
+		}
+	}
+	return 0, nil
+}
+
 func (j mdJournal) end() (kbfsmd.Revision, error) {
 	return j.j.end()
 }
@@ -1389,6 +1445,65 @@ func (j *mdJournal) put(
 	return id, nil
 }
 
+// putEncrypted appends the given merged MD, whose private data is
+// already encrypted and whose writer metadata is already signed by
+// this device, e.g. one imported from the journal of another device
+// of the same user.
+func (j *mdJournal) putEncrypted(ctx context.Context,
+	brmd kbfsmd.RootMetadata, extra kbfsmd.ExtraMetadata) (
+	mdID kbfsmd.ID, err error) {
+	j.log.CDebugf(ctx, "Putting encrypted MD for TLF=%s with rev=%s",
+		brmd.TlfID(), brmd.RevisionNumber())
+
+	if brmd.MergedStatus() != kbfsmd.Merged ||
+		j.branchID != kbfsmd.NullBranchID {
+		return kbfsmd.ID{}, errors.Errorf(
+			"Can't put an encrypted MD with bid=%s onto a journal "+
+				"with bid=%s", brmd.BID(), j.branchID)
+	}
+
+	head, err := j.getLatest(ctx, true)
+	if err != nil {
+		return kbfsmd.ID{}, err
+	}
+	if head != (ImmutableBareRootMetadata{}) {
+		err = head.CheckValidSuccessorForServer(head.mdID, brmd)
+		if err != nil {
+			return kbfsmd.ID{}, err
+		}
+	}
+
+	err = brmd.IsValidAndSigned(
+		ctx, j.codec, j.teamMemChecker, extra, j.key)
+	if err != nil {
+		return kbfsmd.ID{}, err
+	}
+
+	id, err := j.putMD(brmd)
+	if err != nil {
+		return kbfsmd.ID{}, err
+	}
+
+	wkbNew, rkbNew, err := j.putExtraMetadata(brmd, extra)
+	if err != nil {
+		return kbfsmd.ID{}, err
+	}
+
+	err = j.j.append(brmd.RevisionNumber(), mdIDJournalEntry{
+		ID:     id,
+		WKBNew: wkbNew,
+		RKBNew: rkbNew,
+	})
+	if err != nil {
+		return kbfsmd.ID{}, err
+	}
+
+	// Since the journal is now non-empty, clear lastMdID.
+	j.lastMdID = kbfsmd.ID{}
+
+	return id, nil
+}
+
 // clear removes all the journal entries, and deletes the
 // corresponding MD updates.  If the branch is a pending local squash,
 // it preserves the MD updates corresponding to the prefix of existing
@@ -1471,7 +1586,7 @@ func (j *mdJournal) resolveAndClear(
 	// be cleaned up whenever the entire journal goes empty.
 
 	j.log.CDebugf(ctx, "Using temp dir %s for new IDs", idJournalTempDir)
-	otherIDJournal, err := makeMdIDJournal(j.codec, idJournalTempDir)
+	otherIDJournal, err := makeMdIDJournal(j.codec, j.crypter, idJournalTempDir)
 	if err != nil {
 		return kbfsmd.ID{}, err
 	}
@@ -1486,8 +1601,8 @@ func (j *mdJournal) resolveAndClear(
 	}()
 
 	otherJournal, err := makeMDJournalWithIDJournal(
-		ctx, j.uid, j.key, j.codec, j.crypto, j.clock, j.teamMemChecker,
-		j.tlfID, j.mdVer, j.dir, otherIDJournal, j.log)
+		ctx, j.uid, j.key, j.codec, j.crypter, j.crypto, j.clock,
+		j.teamMemChecker, j.tlfID, j.mdVer, j.dir, otherIDJournal, j.log)
 	if err != nil {
 		return kbfsmd.ID{}, err
 	}
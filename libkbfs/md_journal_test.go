@@ -39,7 +39,8 @@ func (g singleEncryptionKeyGetter) GetTLFCryptKeyForMDDecryption(
 	return g.k, nil
 }
 
-func setupMDJournalTest(t testing.TB, ver kbfsmd.MetadataVer) (
+func setupMDJournalTest(t testing.TB, ver kbfsmd.MetadataVer,
+	cryptKey kbfscrypto.JournalCryptKey) (
 	codec kbfscodec.Codec, crypto CryptoCommon, tlfID tlf.ID,
 	signer kbfscrypto.Signer, ekg singleEncryptionKeyGetter,
 	bsplit BlockSplitter, tempdir string, j *mdJournal) {
@@ -71,7 +72,8 @@ func setupMDJournalTest(t testing.TB, ver kbfsmd.MetadataVer) (
 	log := logger.NewTestLogger(t)
 	ctx := context.Background()
 	j, err = makeMDJournal(
-		ctx, uid, verifyingKey, codec, crypto, wallClock{}, nil,
+		ctx, uid, verifyingKey, codec, newJournalCrypter(codec, cryptKey),
+		crypto, wallClock{}, nil,
 		tlfID, ver, tempdir, log)
 	require.NoError(t, err)
 
@@ -207,7 +209,8 @@ func benchmarkMDJournalBasicBody(b *testing.B, ver kbfsmd.MetadataVer, mdCount i
 	b.StopTimer()
 
 	_, _, id, signer, ekg, bsplit, tempdir, j :=
-		setupMDJournalTest(noLogTB{b}, ver)
+		setupMDJournalTest(
+			noLogTB{b}, ver, kbfscrypto.JournalCryptKey{})
 	defer teardownMDJournalTest(b, tempdir)
 
 	putMDRangeHelper(b, ver, id, signer, kbfsmd.Revision(10),
@@ -233,9 +236,10 @@ func benchmarkMDJournalBasic(b *testing.B, ver kbfsmd.MetadataVer) {
 	}
 }
 
-func testMDJournalBasic(t *testing.T, ver kbfsmd.MetadataVer) {
+func testMDJournalBasic(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
 	codec, _, id, signer, ekg, bsplit, tempdir, j :=
-		setupMDJournalTest(t, ver)
+		setupMDJournalTest(t, ver, cryptKey)
 	defer teardownMDJournalTest(t, tempdir)
 
 	// Should start off as empty.
@@ -276,8 +280,9 @@ func testMDJournalBasic(t *testing.T, ver kbfsmd.MetadataVer) {
 	}
 }
 
-func testMDJournalGetNextEntry(t *testing.T, ver kbfsmd.MetadataVer) {
-	_, _, id, signer, ekg, bsplit, tempdir, j := setupMDJournalTest(t, ver)
+func testMDJournalGetNextEntry(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
+	_, _, id, signer, ekg, bsplit, tempdir, j := setupMDJournalTest(t, ver, cryptKey)
 	defer teardownMDJournalTest(t, tempdir)
 
 	ctx := context.Background()
@@ -304,8 +309,9 @@ func testMDJournalGetNextEntry(t *testing.T, ver kbfsmd.MetadataVer) {
 
 // Putting the same md twice should return the same MD ID.  Regression
 // for KBFS-1955.
-func testMDJournalPutEntryTwice(t *testing.T, ver kbfsmd.MetadataVer) {
-	_, _, id, signer, ekg, bsplit, tempdir, j := setupMDJournalTest(t, ver)
+func testMDJournalPutEntryTwice(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
+	_, _, id, signer, ekg, bsplit, tempdir, j := setupMDJournalTest(t, ver, cryptKey)
 	defer teardownMDJournalTest(t, tempdir)
 
 	ctx := context.Background()
@@ -320,8 +326,9 @@ func testMDJournalPutEntryTwice(t *testing.T, ver kbfsmd.MetadataVer) {
 	require.Equal(t, id1, id2)
 }
 
-func testMDJournalPutCase1Empty(t *testing.T, ver kbfsmd.MetadataVer) {
-	_, _, id, signer, ekg, bsplit, tempdir, j := setupMDJournalTest(t, ver)
+func testMDJournalPutCase1Empty(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
+	_, _, id, signer, ekg, bsplit, tempdir, j := setupMDJournalTest(t, ver, cryptKey)
 	defer teardownMDJournalTest(t, tempdir)
 
 	ctx := context.Background()
@@ -335,8 +342,9 @@ func testMDJournalPutCase1Empty(t *testing.T, ver kbfsmd.MetadataVer) {
 	require.Equal(t, md.extra, head.extra)
 }
 
-func testMDJournalPutCase1Conflict(t *testing.T, ver kbfsmd.MetadataVer) {
-	_, _, id, signer, ekg, bsplit, tempdir, j := setupMDJournalTest(t, ver)
+func testMDJournalPutCase1Conflict(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
+	_, _, id, signer, ekg, bsplit, tempdir, j := setupMDJournalTest(t, ver, cryptKey)
 	defer teardownMDJournalTest(t, tempdir)
 
 	ctx := context.Background()
@@ -355,8 +363,9 @@ func testMDJournalPutCase1Conflict(t *testing.T, ver kbfsmd.MetadataVer) {
 
 // The append portion of case 1 is covered by TestMDJournalBasic.
 
-func testMDJournalPutCase1ReplaceHead(t *testing.T, ver kbfsmd.MetadataVer) {
-	_, _, id, signer, ekg, bsplit, tempdir, j := setupMDJournalTest(t, ver)
+func testMDJournalPutCase1ReplaceHead(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
+	_, _, id, signer, ekg, bsplit, tempdir, j := setupMDJournalTest(t, ver, cryptKey)
 	defer teardownMDJournalTest(t, tempdir)
 
 	// Push some new metadata blocks.
@@ -384,8 +393,9 @@ func testMDJournalPutCase1ReplaceHead(t *testing.T, ver kbfsmd.MetadataVer) {
 	require.Equal(t, md.extra, head.extra)
 }
 
-func testMDJournalPutCase2NonEmptyReplace(t *testing.T, ver kbfsmd.MetadataVer) {
-	_, _, id, signer, ekg, bsplit, tempdir, j := setupMDJournalTest(t, ver)
+func testMDJournalPutCase2NonEmptyReplace(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
+	_, _, id, signer, ekg, bsplit, tempdir, j := setupMDJournalTest(t, ver, cryptKey)
 	defer teardownMDJournalTest(t, tempdir)
 
 	ctx := context.Background()
@@ -403,8 +413,9 @@ func testMDJournalPutCase2NonEmptyReplace(t *testing.T, ver kbfsmd.MetadataVer)
 	require.NoError(t, err)
 }
 
-func testMDJournalPutCase2NonEmptyAppend(t *testing.T, ver kbfsmd.MetadataVer) {
-	_, _, id, signer, ekg, bsplit, tempdir, j := setupMDJournalTest(t, ver)
+func testMDJournalPutCase2NonEmptyAppend(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
+	_, _, id, signer, ekg, bsplit, tempdir, j := setupMDJournalTest(t, ver, cryptKey)
 	defer teardownMDJournalTest(t, tempdir)
 
 	ctx := context.Background()
@@ -423,8 +434,9 @@ func testMDJournalPutCase2NonEmptyAppend(t *testing.T, ver kbfsmd.MetadataVer) {
 	require.NoError(t, err)
 }
 
-func testMDJournalPutCase2Empty(t *testing.T, ver kbfsmd.MetadataVer) {
-	_, _, id, signer, ekg, bsplit, tempdir, j := setupMDJournalTest(t, ver)
+func testMDJournalPutCase2Empty(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
+	_, _, id, signer, ekg, bsplit, tempdir, j := setupMDJournalTest(t, ver, cryptKey)
 	defer teardownMDJournalTest(t, tempdir)
 
 	ctx := context.Background()
@@ -449,8 +461,9 @@ func testMDJournalPutCase2Empty(t *testing.T, ver kbfsmd.MetadataVer) {
 	require.NoError(t, err)
 }
 
-func testMDJournalPutCase3NonEmptyAppend(t *testing.T, ver kbfsmd.MetadataVer) {
-	_, _, id, signer, ekg, bsplit, tempdir, j := setupMDJournalTest(t, ver)
+func testMDJournalPutCase3NonEmptyAppend(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
+	_, _, id, signer, ekg, bsplit, tempdir, j := setupMDJournalTest(t, ver, cryptKey)
 	defer teardownMDJournalTest(t, tempdir)
 
 	ctx := context.Background()
@@ -475,8 +488,9 @@ func testMDJournalPutCase3NonEmptyAppend(t *testing.T, ver kbfsmd.MetadataVer) {
 	require.NoError(t, err)
 }
 
-func testMDJournalPutCase3NonEmptyReplace(t *testing.T, ver kbfsmd.MetadataVer) {
-	_, _, id, signer, ekg, bsplit, tempdir, j := setupMDJournalTest(t, ver)
+func testMDJournalPutCase3NonEmptyReplace(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
+	_, _, id, signer, ekg, bsplit, tempdir, j := setupMDJournalTest(t, ver, cryptKey)
 	defer teardownMDJournalTest(t, tempdir)
 
 	ctx := context.Background()
@@ -500,8 +514,9 @@ func testMDJournalPutCase3NonEmptyReplace(t *testing.T, ver kbfsmd.MetadataVer)
 	require.NoError(t, err)
 }
 
-func testMDJournalPutCase3EmptyAppend(t *testing.T, ver kbfsmd.MetadataVer) {
-	_, _, id, signer, ekg, bsplit, tempdir, j := setupMDJournalTest(t, ver)
+func testMDJournalPutCase3EmptyAppend(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
+	_, _, id, signer, ekg, bsplit, tempdir, j := setupMDJournalTest(t, ver, cryptKey)
 	defer teardownMDJournalTest(t, tempdir)
 
 	ctx := context.Background()
@@ -527,8 +542,9 @@ func testMDJournalPutCase3EmptyAppend(t *testing.T, ver kbfsmd.MetadataVer) {
 	require.NoError(t, err)
 }
 
-func testMDJournalPutCase4(t *testing.T, ver kbfsmd.MetadataVer) {
-	_, _, id, signer, ekg, bsplit, tempdir, j := setupMDJournalTest(t, ver)
+func testMDJournalPutCase4(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
+	_, _, id, signer, ekg, bsplit, tempdir, j := setupMDJournalTest(t, ver, cryptKey)
 	defer teardownMDJournalTest(t, tempdir)
 
 	ctx := context.Background()
@@ -584,8 +600,9 @@ func getMDJournalNames(ver kbfsmd.MetadataVer) []string {
 	return expectedNames
 }
 
-func testMDJournalFlushAll(t *testing.T, ver kbfsmd.MetadataVer) {
-	_, _, id, signer, ekg, bsplit, tempdir, j := setupMDJournalTest(t, ver)
+func testMDJournalFlushAll(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
+	_, _, id, signer, ekg, bsplit, tempdir, j := setupMDJournalTest(t, ver, cryptKey)
 	defer teardownMDJournalTest(t, tempdir)
 
 	firstRevision := kbfsmd.Revision(10)
@@ -609,9 +626,10 @@ func testMDJournalFlushAll(t *testing.T, ver kbfsmd.MetadataVer) {
 	require.Equal(t, []string{"extra_file"}, names)
 }
 
-func testMDJournalBranchConversion(t *testing.T, ver kbfsmd.MetadataVer) {
+func testMDJournalBranchConversion(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
 	codec, _, id, signer, ekg, bsplit, tempdir, j :=
-		setupMDJournalTest(t, ver)
+		setupMDJournalTest(t, ver, cryptKey)
 	defer teardownMDJournalTest(t, tempdir)
 
 	firstRevision := kbfsmd.Revision(10)
@@ -668,9 +686,10 @@ func testMDJournalBranchConversion(t *testing.T, ver kbfsmd.MetadataVer) {
 	require.Error(t, err)
 }
 
-func testMDJournalResolveAndClear(t *testing.T, ver kbfsmd.MetadataVer, bid kbfsmd.BranchID) {
+func testMDJournalResolveAndClear(t *testing.T, ver kbfsmd.MetadataVer,
+	cryptKey kbfscrypto.JournalCryptKey, bid kbfsmd.BranchID) {
 	_, _, id, signer, ekg, bsplit, tempdir, j :=
-		setupMDJournalTest(t, ver)
+		setupMDJournalTest(t, ver, cryptKey)
 	defer teardownMDJournalTest(t, tempdir)
 
 	firstRevision := kbfsmd.Revision(10)
@@ -723,16 +742,18 @@ func testMDJournalResolveAndClear(t *testing.T, ver kbfsmd.MetadataVer, bid kbfs
 	flushAllMDs(t, ctx, signer, j)
 }
 
-func testMDJournalResolveAndClearRemoteBranch(t *testing.T, ver kbfsmd.MetadataVer) {
+func testMDJournalResolveAndClearRemoteBranch(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
 	codec := kbfscodec.NewMsgpack()
 	crypto := MakeCryptoCommon(codec)
 	bid, err := crypto.MakeRandomBranchID()
 	require.NoError(t, err)
-	testMDJournalResolveAndClear(t, ver, bid)
+	testMDJournalResolveAndClear(t, ver, cryptKey, bid)
 }
 
-func testMDJournalResolveAndClearLocalSquash(t *testing.T, ver kbfsmd.MetadataVer) {
-	testMDJournalResolveAndClear(t, ver, kbfsmd.PendingLocalSquashBranchID)
+func testMDJournalResolveAndClearLocalSquash(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
+	testMDJournalResolveAndClear(t, ver, cryptKey, kbfsmd.PendingLocalSquashBranchID)
 }
 
 type limitedCryptoSigner struct {
@@ -755,7 +776,7 @@ func TestMDJournalBranchConversionAtomic(t *testing.T) {
 	ver := kbfsmd.InitialExtraMetadataVer
 
 	codec, _, id, signer, ekg, bsplit, tempdir, j :=
-		setupMDJournalTest(t, ver)
+		setupMDJournalTest(t, ver, kbfscrypto.JournalCryptKey{})
 	defer teardownMDJournalTest(t, tempdir)
 
 	firstRevision := kbfsmd.Revision(10)
@@ -799,8 +820,9 @@ type mdIDJournalEntryExtra struct {
 	Extra int
 }
 
-func testMDJournalBranchConversionPreservesUnknownFields(t *testing.T, ver kbfsmd.MetadataVer) {
-	codec, _, id, signer, ekg, bsplit, tempdir, j := setupMDJournalTest(t, ver)
+func testMDJournalBranchConversionPreservesUnknownFields(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
+	codec, _, id, signer, ekg, bsplit, tempdir, j := setupMDJournalTest(t, ver, cryptKey)
 	defer teardownMDJournalTest(t, tempdir)
 
 	var expectedEntries []mdIDJournalEntry
@@ -856,8 +878,9 @@ func testMDJournalBranchConversionPreservesUnknownFields(t *testing.T, ver kbfsm
 	flushAllMDs(t, ctx, signer, j)
 }
 
-func testMDJournalClear(t *testing.T, ver kbfsmd.MetadataVer) {
-	_, _, id, signer, ekg, bsplit, tempdir, j := setupMDJournalTest(t, ver)
+func testMDJournalClear(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
+	_, _, id, signer, ekg, bsplit, tempdir, j := setupMDJournalTest(t, ver, cryptKey)
 	defer teardownMDJournalTest(t, tempdir)
 
 	firstRevision := kbfsmd.Revision(10)
@@ -932,8 +955,9 @@ func testMDJournalClear(t *testing.T, ver kbfsmd.MetadataVer) {
 	flushAllMDs(t, ctx, signer, j)
 }
 
-func testMDJournalClearPendingWithMaster(t *testing.T, ver kbfsmd.MetadataVer) {
-	_, _, id, signer, ekg, bsplit, tempdir, j := setupMDJournalTest(t, ver)
+func testMDJournalClearPendingWithMaster(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
+	_, _, id, signer, ekg, bsplit, tempdir, j := setupMDJournalTest(t, ver, cryptKey)
 	defer teardownMDJournalTest(t, tempdir)
 
 	firstRevision := kbfsmd.Revision(10)
@@ -979,9 +1003,10 @@ func testMDJournalClearPendingWithMaster(t *testing.T, ver kbfsmd.MetadataVer) {
 	require.Equal(t, kbfsmd.NullBranchID, head.BID())
 }
 
-func testMDJournalRestart(t *testing.T, ver kbfsmd.MetadataVer) {
+func testMDJournalRestart(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
 	codec, crypto, id, signer, ekg,
-		bsplit, tempdir, j := setupMDJournalTest(t, ver)
+		bsplit, tempdir, j := setupMDJournalTest(t, ver, cryptKey)
 	defer teardownMDJournalTest(t, tempdir)
 
 	// Push some new metadata blocks.
@@ -994,7 +1019,7 @@ func testMDJournalRestart(t *testing.T, ver kbfsmd.MetadataVer) {
 
 	// Restart journal.
 	ctx := context.Background()
-	j, err := makeMDJournal(ctx, j.uid, j.key, codec, crypto, j.clock,
+	j, err := makeMDJournal(ctx, j.uid, j.key, codec, j.crypter, crypto, j.clock,
 		j.teamMemChecker, j.tlfID, j.mdVer, j.dir, j.log)
 	require.NoError(t, err)
 
@@ -1011,9 +1036,10 @@ func testMDJournalRestart(t *testing.T, ver kbfsmd.MetadataVer) {
 	flushAllMDs(t, context.Background(), signer, j)
 }
 
-func testMDJournalRestartAfterBranchConversion(t *testing.T, ver kbfsmd.MetadataVer) {
+func testMDJournalRestartAfterBranchConversion(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
 	codec, crypto, id, signer, ekg, bsplit, tempdir, j :=
-		setupMDJournalTest(t, ver)
+		setupMDJournalTest(t, ver, cryptKey)
 	defer teardownMDJournalTest(t, tempdir)
 
 	// Push some new metadata blocks.
@@ -1036,7 +1062,7 @@ func testMDJournalRestartAfterBranchConversion(t *testing.T, ver kbfsmd.Metadata
 
 	// Restart journal.
 
-	j, err = makeMDJournal(ctx, j.uid, j.key, codec, crypto, j.clock,
+	j, err = makeMDJournal(ctx, j.uid, j.key, codec, j.crypter, crypto, j.clock,
 		j.teamMemChecker, j.tlfID, j.mdVer, j.dir, j.log)
 	require.NoError(t, err)
 
@@ -1054,7 +1080,7 @@ func testMDJournalRestartAfterBranchConversion(t *testing.T, ver kbfsmd.Metadata
 }
 
 func TestMDJournal(t *testing.T) {
-	tests := []func(*testing.T, kbfsmd.MetadataVer){
+	tests := []func(*testing.T, kbfsmd.MetadataVer, kbfscrypto.JournalCryptKey){
 		testMDJournalBasic,
 		testMDJournalGetNextEntry,
 		testMDJournalPutEntryTwice,
@@ -1078,5 +1104,5 @@ func TestMDJournal(t *testing.T) {
 		testMDJournalRestart,
 		testMDJournalRestartAfterBranchConversion,
 	}
-	runTestsOverMetadataVers(t, "testMDJournal", tests)
+	runJournalTestsOverMetadataVers(t, "testMDJournal", tests)
 }
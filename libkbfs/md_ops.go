@@ -601,9 +601,18 @@ func (md *MDOpsStandard) getForHandle(ctx context.Context, handle *TlfHandle,
 		return tlf.ID{}, ImmutableRootMetadata{}, err
 	}
 
+	fromDiskCache := false
+	var extra kbfsmd.ExtraMetadata
 	id, rmds, err := mdserv.GetForHandle(ctx, bh, mStatus, lockBeforeGet)
 	if err != nil {
-		return tlf.ID{}, ImmutableRootMetadata{}, err
+		id, rmds, extra, err = md.getForHandleFromDiskCache(
+			ctx, handle, mStatus, lockBeforeGet, err)
+		if err != nil {
+			return tlf.ID{}, ImmutableRootMetadata{}, err
+		}
+		fromDiskCache = true
+	} else if mStatus == kbfsmd.Merged && id != tlf.NullID {
+		md.putIDToDiskMDCache(ctx, handle, id)
 	}
 
 	if rmds == nil {
@@ -615,9 +624,11 @@ func (md *MDOpsStandard) getForHandle(ctx context.Context, handle *TlfHandle,
 		return id, ImmutableRootMetadata{}, nil
 	}
 
-	extra, err := md.getExtraMD(ctx, rmds.MD)
-	if err != nil {
-		return tlf.ID{}, ImmutableRootMetadata{}, err
+	if !fromDiskCache {
+		extra, err = md.getExtraMD(ctx, rmds.MD)
+		if err != nil {
+			return tlf.ID{}, ImmutableRootMetadata{}, err
+		}
 	}
 
 	bareMdHandle, err := rmds.MD.MakeBareTlfHandle(extra)
@@ -645,10 +656,16 @@ func (md *MDOpsStandard) getForHandle(ctx context.Context, handle *TlfHandle,
 	// consistency. In the future, we'd want to eventually notify
 	// the upper layers of the new name, either directly, or
 	// through a rekey.
+	//
+	// Processing clears out rmds, so keep a copy for the disk cache.
+	rmdsToCache := *rmds
 	rmd, err = md.processMetadata(ctx, mdHandle, rmds, extra, nil)
 	if err != nil {
 		return tlf.ID{}, ImmutableRootMetadata{}, err
 	}
+	if mStatus == kbfsmd.Merged && !fromDiskCache {
+		md.putToDiskMDCache(ctx, &rmdsToCache, extra)
+	}
 
 	return id, rmd, nil
 }
@@ -714,21 +731,125 @@ func (c constIDGetter) GetIDForHandle(_ context.Context, _ *TlfHandle) (
 	return c.id, nil
 }
 
+// putToDiskMDCache caches rmds as the merged head of its TLF on disk,
+// if there's a disk MD cache.  Errors are logged rather than
+// returned, since the cache is only needed when offline.
+func (md *MDOpsStandard) putToDiskMDCache(ctx context.Context,
+	rmds *RootMetadataSigned, extra kbfsmd.ExtraMetadata) {
+	dmc := md.config.DiskMDCache()
+	if dmc == nil {
+		return
+	}
+	err := dmc.Put(ctx, rmds, extra)
+	if err != nil {
+		md.log.CDebugf(ctx, "Couldn't cache MD for TLF %s on disk: %+v",
+			rmds.MD.TlfID(), err)
+	}
+}
+
+// getForTLFFromDiskCache is called when getting the head of a TLF
+// from the MD server failed with serverErr.  If the MD server is
+// disconnected, it returns the head cached on disk instead, if any.
+// Otherwise it returns serverErr.
+func (md *MDOpsStandard) getForTLFFromDiskCache(ctx context.Context,
+	id tlf.ID, mStatus kbfsmd.MergeStatus, lockBeforeGet *keybase1.LockID,
+	serverErr error) (*RootMetadataSigned, kbfsmd.ExtraMetadata, error) {
+	dmc := md.config.DiskMDCache()
+	if dmc == nil || lockBeforeGet != nil ||
+		md.config.MDServer().IsConnected() {
+		return nil, nil, serverErr
+	}
+	if mStatus == kbfsmd.Unmerged {
+		// Only merged heads are cached.  Any local unmerged branch
+		// lives in the journal, so there's nothing to return here.
+		return nil, nil, nil
+	}
+	rmds, extra, err := dmc.Get(ctx, id)
+	if err != nil {
+		md.log.CDebugf(ctx, "Couldn't get cached MD for TLF %s: %+v", id, err)
+		return nil, nil, serverErr
+	}
+	md.log.CDebugf(ctx, "MD server unreachable (%v); using cached MD for "+
+		"TLF %s at revision %d", serverErr, id, rmds.MD.RevisionNumber())
+	if extraV3, ok := extra.(*kbfsmd.ExtraMetadataV3); ok {
+		// Seed the key bundle cache, so later lookups of the same
+		// bundles don't need the server either.
+		kbcache := md.config.KeyBundleCache()
+		kbcache.PutTLFWriterKeyBundle(
+			rmds.MD.GetTLFWriterKeyBundleID(), extraV3.GetWriterKeyBundle())
+		kbcache.PutTLFReaderKeyBundle(
+			rmds.MD.GetTLFReaderKeyBundleID(), extraV3.GetReaderKeyBundle())
+	}
+	return rmds, extra, nil
+}
+
+// putIDToDiskMDCache records the ID of the TLF for handle on disk,
+// if there's a disk MD cache, so that getForHandle can find the
+// TLF's cached head while offline.
+func (md *MDOpsStandard) putIDToDiskMDCache(ctx context.Context,
+	handle *TlfHandle, id tlf.ID) {
+	dmc := md.config.DiskMDCache()
+	if dmc == nil {
+		return
+	}
+	err := dmc.PutIDForHandle(ctx, handle, id)
+	if err != nil {
+		md.log.CDebugf(ctx, "Couldn't cache the ID of %s on disk: %+v",
+			handle.GetCanonicalPath(), err)
+	}
+}
+
+// getForHandleFromDiskCache is the getForHandle counterpart of
+// getForTLFFromDiskCache.  If the MD server is disconnected, it looks
+// up the TLF ID of handle on disk, and returns that along with the
+// TLF's cached head.  Otherwise it returns serverErr.
+func (md *MDOpsStandard) getForHandleFromDiskCache(ctx context.Context,
+	handle *TlfHandle, mStatus kbfsmd.MergeStatus,
+	lockBeforeGet *keybase1.LockID, serverErr error) (
+	tlf.ID, *RootMetadataSigned, kbfsmd.ExtraMetadata, error) {
+	dmc := md.config.DiskMDCache()
+	if dmc == nil || lockBeforeGet != nil ||
+		md.config.MDServer().IsConnected() {
+		return tlf.NullID, nil, nil, serverErr
+	}
+	id, err := dmc.GetIDForHandle(ctx, handle)
+	if err != nil {
+		md.log.CDebugf(ctx, "Couldn't get cached ID for %s: %+v",
+			handle.GetCanonicalPath(), err)
+		return tlf.NullID, nil, nil, serverErr
+	}
+	rmds, extra, err := md.getForTLFFromDiskCache(
+		ctx, id, mStatus, lockBeforeGet, serverErr)
+	if err != nil {
+		return tlf.NullID, nil, nil, err
+	}
+	return id, rmds, extra, nil
+}
+
 func (md *MDOpsStandard) getForTLF(ctx context.Context, id tlf.ID,
 	bid kbfsmd.BranchID, mStatus kbfsmd.MergeStatus, lockBeforeGet *keybase1.LockID) (
 	ImmutableRootMetadata, error) {
+	fromDiskCache := false
+	var extra kbfsmd.ExtraMetadata
 	rmds, err := md.config.MDServer().GetForTLF(
 		ctx, id, bid, mStatus, lockBeforeGet)
 	if err != nil {
-		return ImmutableRootMetadata{}, err
+		rmds, extra, err = md.getForTLFFromDiskCache(
+			ctx, id, mStatus, lockBeforeGet, err)
+		if err != nil {
+			return ImmutableRootMetadata{}, err
+		}
+		fromDiskCache = true
 	}
 	if rmds == nil {
 		// Possible if mStatus is kbfsmd.Unmerged
 		return ImmutableRootMetadata{}, nil
 	}
-	extra, err := md.getExtraMD(ctx, rmds.MD)
-	if err != nil {
-		return ImmutableRootMetadata{}, err
+	if !fromDiskCache {
+		extra, err = md.getExtraMD(ctx, rmds.MD)
+		if err != nil {
+			return ImmutableRootMetadata{}, err
+		}
 	}
 	bareHandle, err := rmds.MD.MakeBareTlfHandle(extra)
 	if err != nil {
@@ -740,10 +861,15 @@ func (md *MDOpsStandard) getForTLF(ctx context.Context, id tlf.ID,
 	if err != nil {
 		return ImmutableRootMetadata{}, err
 	}
+	// Processing clears out rmds, so keep a copy for the disk cache.
+	rmdsToCache := *rmds
 	rmd, err := md.processMetadataWithID(ctx, id, bid, handle, rmds, extra, nil)
 	if err != nil {
 		return ImmutableRootMetadata{}, err
 	}
+	if mStatus == kbfsmd.Merged && !fromDiskCache {
+		md.putToDiskMDCache(ctx, &rmdsToCache, extra)
+	}
 	return rmd, nil
 }
 
@@ -875,10 +1001,27 @@ func (md *MDOpsStandard) getRange(ctx context.Context, id tlf.ID,
 	if err != nil {
 		return nil, err
 	}
+	// Processing clears out each rmds, so keep a copy of the latest
+	// one for the disk cache.
+	var rmdsToCache *RootMetadataSigned
+	if mStatus == kbfsmd.Merged && len(rmds) > 0 {
+		latest := *rmds[len(rmds)-1]
+		rmdsToCache = &latest
+	}
 	rmd, err := md.processRange(ctx, id, bid, rmds)
 	if err != nil {
 		return nil, err
 	}
+	if rmdsToCache != nil && md.config.DiskMDCache() != nil {
+		// The key bundles were just fetched while processing the
+		// range, so this should hit the key bundle cache.
+		extra, err := md.getExtraMD(ctx, rmdsToCache.MD)
+		if err != nil {
+			md.log.CDebugf(ctx, "Couldn't get extra MD to cache: %+v", err)
+		} else {
+			md.putToDiskMDCache(ctx, rmdsToCache, extra)
+		}
+	}
 	return rmd, nil
 }
 
@@ -946,6 +1089,10 @@ func (md *MDOpsStandard) put(ctx context.Context, rmd *RootMetadata,
 		return ImmutableRootMetadata{}, err
 	}
 	md.log.CDebugf(ctx, "Put MD rev=%d id=%s", rmd.Revision(), mdID)
+	if rmd.MergedStatus() == kbfsmd.Merged {
+		md.putToDiskMDCache(ctx, makeRootMetadataSigned(
+			&rmds.RootMetadataSigned, irmd.localTimestamp), rmd.extra)
+	}
 
 	return irmd, nil
 }
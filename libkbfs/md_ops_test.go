@@ -465,6 +465,61 @@ func testMDOpsGetIDForHandleFailGet(t *testing.T, ver kbfsmd.MetadataVer) {
 	}
 }
 
+func testMDOpsGetIDForHandleOffline(t *testing.T, ver kbfsmd.MetadataVer) {
+	mockCtrl, config, ctx := mdOpsInit(t, ver)
+	defer mdOpsShutdown(mockCtrl, config)
+	dmc, err := newDiskMDCacheLocalForTest(config)
+	require.NoError(t, err)
+	defer dmc.Shutdown(ctx)
+	config.diskMDCache = dmc
+
+	id := tlf.FakeID(1, tlf.Public)
+	h := parseTlfHandleOrBust(t, config, "alice,bob", tlf.Public, id)
+	rmds, _ := newRMDS(t, config, h)
+	rev := rmds.MD.RevisionNumber()
+	h.tlfID = tlf.NullID
+	serverErr := errors.New("Fake fail")
+
+	t.Log("Nothing is cached yet, so the server error is returned.")
+	config.mockMdserv.EXPECT().IsConnected().Return(false)
+	config.mockMdserv.EXPECT().GetForHandle(ctx, h.ToBareHandleOrBust(),
+		kbfsmd.Merged, nil).Return(tlf.NullID, nil, serverErr)
+	_, err = config.MDOps().GetIDForHandle(ctx, h)
+	require.Equal(t, serverErr, err)
+
+	t.Log("A successful lookup caches the ID and head on disk.")
+	verifyMDForPublic(config, rmds, nil)
+	config.mockMdserv.EXPECT().GetForHandle(ctx, h.ToBareHandleOrBust(),
+		kbfsmd.Merged, nil).Return(id, rmds, nil)
+	id2, err := config.MDOps().GetIDForHandle(ctx, h)
+	require.NoError(t, err)
+	require.Equal(t, id, id2)
+
+	t.Log("The cache isn't used while the MD server is connected.")
+	config.mockMdserv.EXPECT().IsConnected().Return(true)
+	config.mockMdserv.EXPECT().GetForHandle(ctx, h.ToBareHandleOrBust(),
+		kbfsmd.Merged, nil).Return(tlf.NullID, nil, serverErr)
+	_, err = config.MDOps().GetIDForHandle(ctx, h)
+	require.Equal(t, serverErr, err)
+
+	t.Log("Once the MD server is disconnected, the cached head is used.")
+	config.mockMdserv.EXPECT().IsConnected().AnyTimes().Return(false)
+	config.mockMdcache.EXPECT().Put(gomock.Any()).Times(2)
+	config.mockMdserv.EXPECT().GetForHandle(ctx, h.ToBareHandleOrBust(),
+		kbfsmd.Merged, nil).Return(tlf.NullID, nil, serverErr)
+	id2, err = config.MDOps().GetIDForHandle(ctx, h)
+	require.NoError(t, err)
+	require.Equal(t, id, id2)
+
+	config.mockMdserv.EXPECT().GetForHandle(ctx, h.ToBareHandleOrBust(),
+		kbfsmd.Merged, nil).Return(tlf.NullID, nil, serverErr)
+	mdOps := config.MDOps().(*MDOpsStandard)
+	id2, rmd, err := mdOps.getForHandle(ctx, h, kbfsmd.Merged, nil)
+	require.NoError(t, err)
+	require.Equal(t, id, id2)
+	require.Equal(t, rev, rmd.Revision())
+}
+
 func testMDOpsGetIDForHandleFailHandleCheck(
 	t *testing.T, ver kbfsmd.MetadataVer) {
 	mockCtrl, config, ctx := mdOpsInit(t, ver)
@@ -1273,6 +1328,7 @@ func TestMDOps(t *testing.T) {
 		testMDOpsGetIDForHandlePublicFailVerify,
 		testMDOpsGetIDForHandleFailGet,
 		testMDOpsGetIDForHandleFailHandleCheck,
+		testMDOpsGetIDForHandleOffline,
 		testMDOpsGetSuccess,
 		testMDOpsGetBlankSigFailure,
 		testMDOpsGetFailGet,
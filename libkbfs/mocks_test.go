@@ -440,6 +440,76 @@ func (mr *MockdiskBlockCacheSetterMockRecorder) MakeDiskBlockCacheIfNotExists()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakeDiskBlockCacheIfNotExists", reflect.TypeOf((*MockdiskBlockCacheSetter)(nil).MakeDiskBlockCacheIfNotExists))
 }
 
+// MockdiskMDCacheGetter is a mock of diskMDCacheGetter interface
+type MockdiskMDCacheGetter struct {
+	ctrl     *gomock.Controller
+	recorder *MockdiskMDCacheGetterMockRecorder
+}
+
+// MockdiskMDCacheGetterMockRecorder is the mock recorder for MockdiskMDCacheGetter
+type MockdiskMDCacheGetterMockRecorder struct {
+	mock *MockdiskMDCacheGetter
+}
+
+// NewMockdiskMDCacheGetter creates a new mock instance
+func NewMockdiskMDCacheGetter(ctrl *gomock.Controller) *MockdiskMDCacheGetter {
+	mock := &MockdiskMDCacheGetter{ctrl: ctrl}
+	mock.recorder = &MockdiskMDCacheGetterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockdiskMDCacheGetter) EXPECT() *MockdiskMDCacheGetterMockRecorder {
+	return m.recorder
+}
+
+// DiskMDCache mocks base method
+func (m *MockdiskMDCacheGetter) DiskMDCache() DiskMDCache {
+	ret := m.ctrl.Call(m, "DiskMDCache")
+	ret0, _ := ret[0].(DiskMDCache)
+	return ret0
+}
+
+// DiskMDCache indicates an expected call of DiskMDCache
+func (mr *MockdiskMDCacheGetterMockRecorder) DiskMDCache() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DiskMDCache", reflect.TypeOf((*MockdiskMDCacheGetter)(nil).DiskMDCache))
+}
+
+// MockdiskMDCacheSetter is a mock of diskMDCacheSetter interface
+type MockdiskMDCacheSetter struct {
+	ctrl     *gomock.Controller
+	recorder *MockdiskMDCacheSetterMockRecorder
+}
+
+// MockdiskMDCacheSetterMockRecorder is the mock recorder for MockdiskMDCacheSetter
+type MockdiskMDCacheSetterMockRecorder struct {
+	mock *MockdiskMDCacheSetter
+}
+
+// NewMockdiskMDCacheSetter creates a new mock instance
+func NewMockdiskMDCacheSetter(ctrl *gomock.Controller) *MockdiskMDCacheSetter {
+	mock := &MockdiskMDCacheSetter{ctrl: ctrl}
+	mock.recorder = &MockdiskMDCacheSetterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockdiskMDCacheSetter) EXPECT() *MockdiskMDCacheSetterMockRecorder {
+	return m.recorder
+}
+
+// MakeDiskMDCacheIfNotExists mocks base method
+func (m *MockdiskMDCacheSetter) MakeDiskMDCacheIfNotExists() error {
+	ret := m.ctrl.Call(m, "MakeDiskMDCacheIfNotExists")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MakeDiskMDCacheIfNotExists indicates an expected call of MakeDiskMDCacheIfNotExists
+func (mr *MockdiskMDCacheSetterMockRecorder) MakeDiskMDCacheIfNotExists() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakeDiskMDCacheIfNotExists", reflect.TypeOf((*MockdiskMDCacheSetter)(nil).MakeDiskMDCacheIfNotExists))
+}
+
 // MockclockGetter is a mock of clockGetter interface
 type MockclockGetter struct {
 	ctrl     *gomock.Controller
@@ -557,6 +627,111 @@ func (mr *MocksyncedTlfGetterSetterMockRecorder) SetTlfSyncState(tlfID, isSynced
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTlfSyncState", reflect.TypeOf((*MocksyncedTlfGetterSetter)(nil).SetTlfSyncState), tlfID, isSynced)
 }
 
+// GetTlfSyncedPaths mocks base method
+func (m *MocksyncedTlfGetterSetter) GetTlfSyncedPaths(tlfID tlf.ID) []string {
+	ret := m.ctrl.Call(m, "GetTlfSyncedPaths", tlfID)
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// GetTlfSyncedPaths indicates an expected call of GetTlfSyncedPaths
+func (mr *MocksyncedTlfGetterSetterMockRecorder) GetTlfSyncedPaths(tlfID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTlfSyncedPaths", reflect.TypeOf((*MocksyncedTlfGetterSetter)(nil).GetTlfSyncedPaths), tlfID)
+}
+
+// SetTlfPathSyncState mocks base method
+func (m *MocksyncedTlfGetterSetter) SetTlfPathSyncState(tlfID tlf.ID, path string, isSynced bool) error {
+	ret := m.ctrl.Call(m, "SetTlfPathSyncState", tlfID, path, isSynced)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetTlfPathSyncState indicates an expected call of SetTlfPathSyncState
+func (mr *MocksyncedTlfGetterSetterMockRecorder) SetTlfPathSyncState(tlfID, path, isSynced interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTlfPathSyncState", reflect.TypeOf((*MocksyncedTlfGetterSetter)(nil).SetTlfPathSyncState), tlfID, path, isSynced)
+}
+
+// RenameTlfSyncedPaths mocks base method
+func (m *MocksyncedTlfGetterSetter) RenameTlfSyncedPaths(tlfID tlf.ID, oldPath, newPath string) error {
+	ret := m.ctrl.Call(m, "RenameTlfSyncedPaths", tlfID, oldPath, newPath)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RenameTlfSyncedPaths indicates an expected call of RenameTlfSyncedPaths
+func (mr *MocksyncedTlfGetterSetterMockRecorder) RenameTlfSyncedPaths(tlfID, oldPath, newPath interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RenameTlfSyncedPaths", reflect.TypeOf((*MocksyncedTlfGetterSetter)(nil).RenameTlfSyncedPaths), tlfID, oldPath, newPath)
+}
+
+// MockdiskBlockCacheTlfSettingsGetterSetter is a mock of diskBlockCacheTlfSettingsGetterSetter interface
+type MockdiskBlockCacheTlfSettingsGetterSetter struct {
+	ctrl     *gomock.Controller
+	recorder *MockdiskBlockCacheTlfSettingsGetterSetterMockRecorder
+}
+
+// MockdiskBlockCacheTlfSettingsGetterSetterMockRecorder is the mock recorder for MockdiskBlockCacheTlfSettingsGetterSetter
+type MockdiskBlockCacheTlfSettingsGetterSetterMockRecorder struct {
+	mock *MockdiskBlockCacheTlfSettingsGetterSetter
+}
+
+// NewMockdiskBlockCacheTlfSettingsGetterSetter creates a new mock instance
+func NewMockdiskBlockCacheTlfSettingsGetterSetter(ctrl *gomock.Controller) *MockdiskBlockCacheTlfSettingsGetterSetter {
+	mock := &MockdiskBlockCacheTlfSettingsGetterSetter{ctrl: ctrl}
+	mock.recorder = &MockdiskBlockCacheTlfSettingsGetterSetterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockdiskBlockCacheTlfSettingsGetterSetter) EXPECT() *MockdiskBlockCacheTlfSettingsGetterSetterMockRecorder {
+	return m.recorder
+}
+
+// GetDiskBlockCacheTlfSettings mocks base method
+func (m *MockdiskBlockCacheTlfSettingsGetterSetter) GetDiskBlockCacheTlfSettings(tlfID tlf.ID) DiskBlockCacheTlfSettings {
+	ret := m.ctrl.Call(m, "GetDiskBlockCacheTlfSettings", tlfID)
+	ret0, _ := ret[0].(DiskBlockCacheTlfSettings)
+	return ret0
+}
+
+// GetDiskBlockCacheTlfSettings indicates an expected call of GetDiskBlockCacheTlfSettings
+func (mr *MockdiskBlockCacheTlfSettingsGetterSetterMockRecorder) GetDiskBlockCacheTlfSettings(tlfID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDiskBlockCacheTlfSettings", reflect.TypeOf((*MockdiskBlockCacheTlfSettingsGetterSetter)(nil).GetDiskBlockCacheTlfSettings), tlfID)
+}
+
+// SetDiskBlockCacheTlfSettings mocks base method
+func (m *MockdiskBlockCacheTlfSettingsGetterSetter) SetDiskBlockCacheTlfSettings(tlfID tlf.ID, settings DiskBlockCacheTlfSettings) error {
+	ret := m.ctrl.Call(m, "SetDiskBlockCacheTlfSettings", tlfID, settings)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetDiskBlockCacheTlfSettings indicates an expected call of SetDiskBlockCacheTlfSettings
+func (mr *MockdiskBlockCacheTlfSettingsGetterSetterMockRecorder) SetDiskBlockCacheTlfSettings(tlfID, settings interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetDiskBlockCacheTlfSettings", reflect.TypeOf((*MockdiskBlockCacheTlfSettingsGetterSetter)(nil).SetDiskBlockCacheTlfSettings), tlfID, settings)
+}
+
+// IsFavoriteTlf mocks base method
+func (m *MockdiskBlockCacheTlfSettingsGetterSetter) IsFavoriteTlf(tlfID tlf.ID) bool {
+	ret := m.ctrl.Call(m, "IsFavoriteTlf", tlfID)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// IsFavoriteTlf indicates an expected call of IsFavoriteTlf
+func (mr *MockdiskBlockCacheTlfSettingsGetterSetterMockRecorder) IsFavoriteTlf(tlfID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsFavoriteTlf", reflect.TypeOf((*MockdiskBlockCacheTlfSettingsGetterSetter)(nil).IsFavoriteTlf), tlfID)
+}
+
+// SetTlfFavoriteState mocks base method
+func (m *MockdiskBlockCacheTlfSettingsGetterSetter) SetTlfFavoriteState(tlfID tlf.ID, isFavorite bool) {
+	m.ctrl.Call(m, "SetTlfFavoriteState", tlfID, isFavorite)
+}
+
+// SetTlfFavoriteState indicates an expected call of SetTlfFavoriteState
+func (mr *MockdiskBlockCacheTlfSettingsGetterSetterMockRecorder) SetTlfFavoriteState(tlfID, isFavorite interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTlfFavoriteState", reflect.TypeOf((*MockdiskBlockCacheTlfSettingsGetterSetter)(nil).SetTlfFavoriteState), tlfID, isFavorite)
+}
+
 // MockblockRetrieverGetter is a mock of blockRetrieverGetter interface
 type MockblockRetrieverGetter struct {
 	ctrl     *gomock.Controller
@@ -1269,6 +1444,56 @@ func (mr *MockKBFSOpsMockRecorder) GetEditHistory(ctx, folderBranch interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEditHistory", reflect.TypeOf((*MockKBFSOps)(nil).GetEditHistory), ctx, folderBranch)
 }
 
+// GetConflicts mocks base method
+func (m *MockKBFSOps) GetConflicts(ctx context.Context, folderBranch FolderBranch) (TlfConflicts, error) {
+	ret := m.ctrl.Call(m, "GetConflicts", ctx, folderBranch)
+	ret0, _ := ret[0].(TlfConflicts)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetConflicts indicates an expected call of GetConflicts
+func (mr *MockKBFSOpsMockRecorder) GetConflicts(ctx, folderBranch interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetConflicts", reflect.TypeOf((*MockKBFSOps)(nil).GetConflicts), ctx, folderBranch)
+}
+
+// ResolveConflict mocks base method
+func (m *MockKBFSOps) ResolveConflict(ctx context.Context, folderBranch FolderBranch, conflictPath string, winner ConflictWinner) error {
+	ret := m.ctrl.Call(m, "ResolveConflict", ctx, folderBranch, conflictPath, winner)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResolveConflict indicates an expected call of ResolveConflict
+func (mr *MockKBFSOpsMockRecorder) ResolveConflict(ctx, folderBranch, conflictPath, winner interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResolveConflict", reflect.TypeOf((*MockKBFSOps)(nil).ResolveConflict), ctx, folderBranch, conflictPath, winner)
+}
+
+// GetConflictRenaming mocks base method
+func (m *MockKBFSOps) GetConflictRenaming(ctx context.Context, folderBranch FolderBranch) (ConflictRenaming, error) {
+	ret := m.ctrl.Call(m, "GetConflictRenaming", ctx, folderBranch)
+	ret0, _ := ret[0].(ConflictRenaming)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetConflictRenaming indicates an expected call of GetConflictRenaming
+func (mr *MockKBFSOpsMockRecorder) GetConflictRenaming(ctx, folderBranch interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetConflictRenaming", reflect.TypeOf((*MockKBFSOps)(nil).GetConflictRenaming), ctx, folderBranch)
+}
+
+// SetConflictRenaming mocks base method
+func (m *MockKBFSOps) SetConflictRenaming(ctx context.Context, folderBranch FolderBranch, renaming ConflictRenaming) error {
+	ret := m.ctrl.Call(m, "SetConflictRenaming", ctx, folderBranch, renaming)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetConflictRenaming indicates an expected call of SetConflictRenaming
+func (mr *MockKBFSOpsMockRecorder) SetConflictRenaming(ctx, folderBranch, renaming interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetConflictRenaming", reflect.TypeOf((*MockKBFSOps)(nil).SetConflictRenaming), ctx, folderBranch, renaming)
+}
+
 // GetNodeMetadata mocks base method
 func (m *MockKBFSOps) GetNodeMetadata(ctx context.Context, node Node) (NodeMetadata, error) {
 	ret := m.ctrl.Call(m, "GetNodeMetadata", ctx, node)
@@ -1282,6 +1507,19 @@ func (mr *MockKBFSOpsMockRecorder) GetNodeMetadata(ctx, node interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNodeMetadata", reflect.TypeOf((*MockKBFSOps)(nil).GetNodeMetadata), ctx, node)
 }
 
+// GetPrefetchStatus mocks base method
+func (m *MockKBFSOps) GetPrefetchStatus(ctx context.Context, node Node) (NodePrefetchStatus, error) {
+	ret := m.ctrl.Call(m, "GetPrefetchStatus", ctx, node)
+	ret0, _ := ret[0].(NodePrefetchStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPrefetchStatus indicates an expected call of GetPrefetchStatus
+func (mr *MockKBFSOpsMockRecorder) GetPrefetchStatus(ctx, node interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPrefetchStatus", reflect.TypeOf((*MockKBFSOps)(nil).GetPrefetchStatus), ctx, node)
+}
+
 // Shutdown mocks base method
 func (m *MockKBFSOps) Shutdown(ctx context.Context) error {
 	ret := m.ctrl.Call(m, "Shutdown", ctx)
@@ -3474,6 +3712,90 @@ func (mr *MockDiskBlockCacheMockRecorder) Shutdown(ctx interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Shutdown", reflect.TypeOf((*MockDiskBlockCache)(nil).Shutdown), ctx)
 }
 
+// MockDiskMDCache is a mock of DiskMDCache interface
+type MockDiskMDCache struct {
+	ctrl     *gomock.Controller
+	recorder *MockDiskMDCacheMockRecorder
+}
+
+// MockDiskMDCacheMockRecorder is the mock recorder for MockDiskMDCache
+type MockDiskMDCacheMockRecorder struct {
+	mock *MockDiskMDCache
+}
+
+// NewMockDiskMDCache creates a new mock instance
+func NewMockDiskMDCache(ctrl *gomock.Controller) *MockDiskMDCache {
+	mock := &MockDiskMDCache{ctrl: ctrl}
+	mock.recorder = &MockDiskMDCacheMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockDiskMDCache) EXPECT() *MockDiskMDCacheMockRecorder {
+	return m.recorder
+}
+
+// Get mocks base method
+func (m *MockDiskMDCache) Get(ctx context.Context, tlfID tlf.ID) (*RootMetadataSigned, kbfsmd.ExtraMetadata, error) {
+	ret := m.ctrl.Call(m, "Get", ctx, tlfID)
+	ret0, _ := ret[0].(*RootMetadataSigned)
+	ret1, _ := ret[1].(kbfsmd.ExtraMetadata)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Get indicates an expected call of Get
+func (mr *MockDiskMDCacheMockRecorder) Get(ctx, tlfID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockDiskMDCache)(nil).Get), ctx, tlfID)
+}
+
+// Put mocks base method
+func (m *MockDiskMDCache) Put(ctx context.Context, rmds *RootMetadataSigned, extra kbfsmd.ExtraMetadata) error {
+	ret := m.ctrl.Call(m, "Put", ctx, rmds, extra)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Put indicates an expected call of Put
+func (mr *MockDiskMDCacheMockRecorder) Put(ctx, rmds, extra interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Put", reflect.TypeOf((*MockDiskMDCache)(nil).Put), ctx, rmds, extra)
+}
+
+// GetIDForHandle mocks base method
+func (m *MockDiskMDCache) GetIDForHandle(ctx context.Context, handle *TlfHandle) (tlf.ID, error) {
+	ret := m.ctrl.Call(m, "GetIDForHandle", ctx, handle)
+	ret0, _ := ret[0].(tlf.ID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIDForHandle indicates an expected call of GetIDForHandle
+func (mr *MockDiskMDCacheMockRecorder) GetIDForHandle(ctx, handle interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIDForHandle", reflect.TypeOf((*MockDiskMDCache)(nil).GetIDForHandle), ctx, handle)
+}
+
+// PutIDForHandle mocks base method
+func (m *MockDiskMDCache) PutIDForHandle(ctx context.Context, handle *TlfHandle, id tlf.ID) error {
+	ret := m.ctrl.Call(m, "PutIDForHandle", ctx, handle, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PutIDForHandle indicates an expected call of PutIDForHandle
+func (mr *MockDiskMDCacheMockRecorder) PutIDForHandle(ctx, handle, id interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutIDForHandle", reflect.TypeOf((*MockDiskMDCache)(nil).PutIDForHandle), ctx, handle, id)
+}
+
+// Shutdown mocks base method
+func (m *MockDiskMDCache) Shutdown(ctx context.Context) {
+	m.ctrl.Call(m, "Shutdown", ctx)
+}
+
+// Shutdown indicates an expected call of Shutdown
+func (mr *MockDiskMDCacheMockRecorder) Shutdown(ctx interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Shutdown", reflect.TypeOf((*MockDiskMDCache)(nil).Shutdown), ctx)
+}
+
 // MockcryptoPure is a mock of cryptoPure interface
 type MockcryptoPure struct {
 	ctrl     *gomock.Controller
@@ -4192,6 +4514,19 @@ func (mr *MockPrefetcherMockRecorder) CancelPrefetch(arg0 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelPrefetch", reflect.TypeOf((*MockPrefetcher)(nil).CancelPrefetch), arg0)
 }
 
+// Status mocks base method
+func (m *MockPrefetcher) Status(ctx context.Context, ptr BlockPointer) (PrefetchProgress, error) {
+	ret := m.ctrl.Call(m, "Status", ctx, ptr)
+	ret0, _ := ret[0].(PrefetchProgress)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Status indicates an expected call of Status
+func (mr *MockPrefetcherMockRecorder) Status(ctx, ptr interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Status", reflect.TypeOf((*MockPrefetcher)(nil).Status), ctx, ptr)
+}
+
 // Shutdown mocks base method
 func (m *MockPrefetcher) Shutdown() <-chan struct{} {
 	ret := m.ctrl.Call(m, "Shutdown")
@@ -5673,6 +6008,77 @@ func (mr *MockConflictRenamerMockRecorder) ConflictRename(ctx, op, original inte
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConflictRename", reflect.TypeOf((*MockConflictRenamer)(nil).ConflictRename), ctx, op, original)
 }
 
+// MockConflictMergeStrategy is a mock of ConflictMergeStrategy interface
+type MockConflictMergeStrategy struct {
+	ctrl     *gomock.Controller
+	recorder *MockConflictMergeStrategyMockRecorder
+}
+
+// MockConflictMergeStrategyMockRecorder is the mock recorder for MockConflictMergeStrategy
+type MockConflictMergeStrategyMockRecorder struct {
+	mock *MockConflictMergeStrategy
+}
+
+// NewMockConflictMergeStrategy creates a new mock instance
+func NewMockConflictMergeStrategy(ctrl *gomock.Controller) *MockConflictMergeStrategy {
+	mock := &MockConflictMergeStrategy{ctrl: ctrl}
+	mock.recorder = &MockConflictMergeStrategyMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockConflictMergeStrategy) EXPECT() *MockConflictMergeStrategyMockRecorder {
+	return m.recorder
+}
+
+// MergeContents mocks base method
+func (m *MockConflictMergeStrategy) MergeContents(ctx context.Context, in ConflictMergeInput) ([]byte, error) {
+	ret := m.ctrl.Call(m, "MergeContents", ctx, in)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MergeContents indicates an expected call of MergeContents
+func (mr *MockConflictMergeStrategyMockRecorder) MergeContents(ctx, in interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MergeContents", reflect.TypeOf((*MockConflictMergeStrategy)(nil).MergeContents), ctx, in)
+}
+
+// MockConflictMerger is a mock of ConflictMerger interface
+type MockConflictMerger struct {
+	ctrl     *gomock.Controller
+	recorder *MockConflictMergerMockRecorder
+}
+
+// MockConflictMergerMockRecorder is the mock recorder for MockConflictMerger
+type MockConflictMergerMockRecorder struct {
+	mock *MockConflictMerger
+}
+
+// NewMockConflictMerger creates a new mock instance
+func NewMockConflictMerger(ctrl *gomock.Controller) *MockConflictMerger {
+	mock := &MockConflictMerger{ctrl: ctrl}
+	mock.recorder = &MockConflictMergerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockConflictMerger) EXPECT() *MockConflictMergerMockRecorder {
+	return m.recorder
+}
+
+// StrategyForPath mocks base method
+func (m *MockConflictMerger) StrategyForPath(p string) ConflictMergeStrategy {
+	ret := m.ctrl.Call(m, "StrategyForPath", p)
+	ret0, _ := ret[0].(ConflictMergeStrategy)
+	return ret0
+}
+
+// StrategyForPath indicates an expected call of StrategyForPath
+func (mr *MockConflictMergerMockRecorder) StrategyForPath(p interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StrategyForPath", reflect.TypeOf((*MockConflictMerger)(nil).StrategyForPath), p)
+}
+
 // MockTracer is a mock of Tracer interface
 type MockTracer struct {
 	ctrl     *gomock.Controller
@@ -6183,6 +6589,30 @@ func (mr *MockConfigMockRecorder) MakeDiskBlockCacheIfNotExists() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakeDiskBlockCacheIfNotExists", reflect.TypeOf((*MockConfig)(nil).MakeDiskBlockCacheIfNotExists))
 }
 
+// DiskMDCache mocks base method
+func (m *MockConfig) DiskMDCache() DiskMDCache {
+	ret := m.ctrl.Call(m, "DiskMDCache")
+	ret0, _ := ret[0].(DiskMDCache)
+	return ret0
+}
+
+// DiskMDCache indicates an expected call of DiskMDCache
+func (mr *MockConfigMockRecorder) DiskMDCache() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DiskMDCache", reflect.TypeOf((*MockConfig)(nil).DiskMDCache))
+}
+
+// MakeDiskMDCacheIfNotExists mocks base method
+func (m *MockConfig) MakeDiskMDCacheIfNotExists() error {
+	ret := m.ctrl.Call(m, "MakeDiskMDCacheIfNotExists")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MakeDiskMDCacheIfNotExists indicates an expected call of MakeDiskMDCacheIfNotExists
+func (mr *MockConfigMockRecorder) MakeDiskMDCacheIfNotExists() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakeDiskMDCacheIfNotExists", reflect.TypeOf((*MockConfig)(nil).MakeDiskMDCacheIfNotExists))
+}
+
 // Clock mocks base method
 func (m *MockConfig) Clock() Clock {
 	ret := m.ctrl.Call(m, "Clock")
@@ -6231,6 +6661,88 @@ func (mr *MockConfigMockRecorder) SetTlfSyncState(tlfID, isSynced interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTlfSyncState", reflect.TypeOf((*MockConfig)(nil).SetTlfSyncState), tlfID, isSynced)
 }
 
+// GetDiskBlockCacheTlfSettings mocks base method
+func (m *MockConfig) GetDiskBlockCacheTlfSettings(tlfID tlf.ID) DiskBlockCacheTlfSettings {
+	ret := m.ctrl.Call(m, "GetDiskBlockCacheTlfSettings", tlfID)
+	ret0, _ := ret[0].(DiskBlockCacheTlfSettings)
+	return ret0
+}
+
+// GetDiskBlockCacheTlfSettings indicates an expected call of GetDiskBlockCacheTlfSettings
+func (mr *MockConfigMockRecorder) GetDiskBlockCacheTlfSettings(tlfID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDiskBlockCacheTlfSettings", reflect.TypeOf((*MockConfig)(nil).GetDiskBlockCacheTlfSettings), tlfID)
+}
+
+// SetDiskBlockCacheTlfSettings mocks base method
+func (m *MockConfig) SetDiskBlockCacheTlfSettings(tlfID tlf.ID, settings DiskBlockCacheTlfSettings) error {
+	ret := m.ctrl.Call(m, "SetDiskBlockCacheTlfSettings", tlfID, settings)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetDiskBlockCacheTlfSettings indicates an expected call of SetDiskBlockCacheTlfSettings
+func (mr *MockConfigMockRecorder) SetDiskBlockCacheTlfSettings(tlfID, settings interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetDiskBlockCacheTlfSettings", reflect.TypeOf((*MockConfig)(nil).SetDiskBlockCacheTlfSettings), tlfID, settings)
+}
+
+// IsFavoriteTlf mocks base method
+func (m *MockConfig) IsFavoriteTlf(tlfID tlf.ID) bool {
+	ret := m.ctrl.Call(m, "IsFavoriteTlf", tlfID)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// IsFavoriteTlf indicates an expected call of IsFavoriteTlf
+func (mr *MockConfigMockRecorder) IsFavoriteTlf(tlfID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsFavoriteTlf", reflect.TypeOf((*MockConfig)(nil).IsFavoriteTlf), tlfID)
+}
+
+// SetTlfFavoriteState mocks base method
+func (m *MockConfig) SetTlfFavoriteState(tlfID tlf.ID, isFavorite bool) {
+	m.ctrl.Call(m, "SetTlfFavoriteState", tlfID, isFavorite)
+}
+
+// SetTlfFavoriteState indicates an expected call of SetTlfFavoriteState
+func (mr *MockConfigMockRecorder) SetTlfFavoriteState(tlfID, isFavorite interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTlfFavoriteState", reflect.TypeOf((*MockConfig)(nil).SetTlfFavoriteState), tlfID, isFavorite)
+}
+
+// GetTlfSyncedPaths mocks base method
+func (m *MockConfig) GetTlfSyncedPaths(tlfID tlf.ID) []string {
+	ret := m.ctrl.Call(m, "GetTlfSyncedPaths", tlfID)
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// GetTlfSyncedPaths indicates an expected call of GetTlfSyncedPaths
+func (mr *MockConfigMockRecorder) GetTlfSyncedPaths(tlfID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTlfSyncedPaths", reflect.TypeOf((*MockConfig)(nil).GetTlfSyncedPaths), tlfID)
+}
+
+// SetTlfPathSyncState mocks base method
+func (m *MockConfig) SetTlfPathSyncState(tlfID tlf.ID, path string, isSynced bool) error {
+	ret := m.ctrl.Call(m, "SetTlfPathSyncState", tlfID, path, isSynced)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetTlfPathSyncState indicates an expected call of SetTlfPathSyncState
+func (mr *MockConfigMockRecorder) SetTlfPathSyncState(tlfID, path, isSynced interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTlfPathSyncState", reflect.TypeOf((*MockConfig)(nil).SetTlfPathSyncState), tlfID, path, isSynced)
+}
+
+// RenameTlfSyncedPaths mocks base method
+func (m *MockConfig) RenameTlfSyncedPaths(tlfID tlf.ID, oldPath, newPath string) error {
+	ret := m.ctrl.Call(m, "RenameTlfSyncedPaths", tlfID, oldPath, newPath)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RenameTlfSyncedPaths indicates an expected call of RenameTlfSyncedPaths
+func (mr *MockConfigMockRecorder) RenameTlfSyncedPaths(tlfID, oldPath, newPath interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RenameTlfSyncedPaths", reflect.TypeOf((*MockConfig)(nil).RenameTlfSyncedPaths), tlfID, oldPath, newPath)
+}
+
 // Mode mocks base method
 func (m *MockConfig) Mode() InitMode {
 	ret := m.ctrl.Call(m, "Mode")
@@ -6701,6 +7213,28 @@ func (mr *MockConfigMockRecorder) SetConflictRenamer(arg0 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetConflictRenamer", reflect.TypeOf((*MockConfig)(nil).SetConflictRenamer), arg0)
 }
 
+// ConflictMerger mocks base method
+func (m *MockConfig) ConflictMerger() ConflictMerger {
+	ret := m.ctrl.Call(m, "ConflictMerger")
+	ret0, _ := ret[0].(ConflictMerger)
+	return ret0
+}
+
+// ConflictMerger indicates an expected call of ConflictMerger
+func (mr *MockConfigMockRecorder) ConflictMerger() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConflictMerger", reflect.TypeOf((*MockConfig)(nil).ConflictMerger))
+}
+
+// SetConflictMerger mocks base method
+func (m *MockConfig) SetConflictMerger(arg0 ConflictMerger) {
+	m.ctrl.Call(m, "SetConflictMerger", arg0)
+}
+
+// SetConflictMerger indicates an expected call of SetConflictMerger
+func (mr *MockConfigMockRecorder) SetConflictMerger(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetConflictMerger", reflect.TypeOf((*MockConfig)(nil).SetConflictMerger), arg0)
+}
+
 // MetadataVersion mocks base method
 func (m *MockConfig) MetadataVersion() kbfsmd.MetadataVer {
 	ret := m.ctrl.Call(m, "MetadataVersion")
@@ -7,6 +7,7 @@ package libkbfs
 import (
 	"errors"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -32,6 +33,7 @@ type prefetcherConfig interface {
 	logMaker
 	blockCacher
 	diskBlockCacheGetter
+	clockGetter
 }
 
 type prefetchRequest struct {
@@ -42,6 +44,26 @@ type prefetchRequest struct {
 	lifetime       BlockCacheLifetime
 	prefetchStatus PrefetchStatus
 	isDeepSync     bool
+	// syncPaths are the synced paths, relative to this block's
+	// directory, that need to be deep-synced within a TLF that isn't
+	// synced as a whole.
+	syncPaths []string
+}
+
+// privateMetadataGetter is implemented by MD objects that can return the
+// root directory entry of their TLF.
+type privateMetadataGetter interface {
+	Data() *PrivateMetadata
+}
+
+type prefetchStatusResponse struct {
+	progress PrefetchProgress
+	ok       bool
+}
+
+type prefetchStatusRequest struct {
+	blockID kbfsblock.ID
+	ch      chan<- prefetchStatusResponse
 }
 
 type ctxPrefetcherTagKey int
@@ -61,6 +83,32 @@ type prefetch struct {
 	parents           map[kbfsblock.ID]bool
 	ctx               context.Context
 	cancel            context.CancelFunc
+
+	// The fields below only track the progress of the prefetch for
+	// status reporting.  `subtreeBlockCount` remains the source of
+	// truth for when a prefetch is complete.
+	//
+	// encodedSize is the encoded size of this block, if known.
+	encodedSize uint32
+	// subtreeBlocksFetched is the number of blocks in this subtree
+	// that were fetched since the prefetch began.  The total number
+	// of blocks is `subtreeBlocksFetched + subtreeBlockCount`.
+	subtreeBlocksFetched int
+	// subtreeBytesFetched and subtreeBytesTotal are the encoded
+	// bytes of the subtree fetched so far, and in total.
+	subtreeBytesFetched uint64
+	subtreeBytesTotal   uint64
+	// start is when the prefetch began.
+	start time.Time
+}
+
+// pendingBytes returns the number of bytes in the subtree of `p` that
+// haven't been fetched yet.
+func (p *prefetch) pendingBytes() uint64 {
+	if p.subtreeBytesFetched > p.subtreeBytesTotal {
+		return 0
+	}
+	return p.subtreeBytesTotal - p.subtreeBytesFetched
 }
 
 func (p *prefetch) Close() {
@@ -77,6 +125,8 @@ type blockPrefetcher struct {
 	prefetchRequestCh channels.Channel
 	// channel to cancel prefetches
 	prefetchCancelCh channels.Channel
+	// channel to query the progress of prefetches
+	prefetchStatusCh channels.Channel
 	// channel to allow synchronization on completion
 	inFlightFetches channels.Channel
 	// protects shutdownCh
@@ -102,6 +152,7 @@ func newBlockPrefetcher(retriever BlockRetriever,
 		retriever:         retriever,
 		prefetchRequestCh: NewInfiniteChannelWrapper(),
 		prefetchCancelCh:  NewInfiniteChannelWrapper(),
+		prefetchStatusCh:  NewInfiniteChannelWrapper(),
 		inFlightFetches:   NewInfiniteChannelWrapper(),
 		shutdownCh:        make(chan struct{}),
 		almostDoneCh:      make(chan struct{}, 1),
@@ -127,8 +178,8 @@ func newBlockPrefetcher(retriever BlockRetriever,
 	return p
 }
 
-func (p *blockPrefetcher) newPrefetch(count int, triggered bool,
-	req *prefetchRequest) *prefetch {
+func (p *blockPrefetcher) newPrefetch(count int, bytes uint64,
+	triggered bool, req *prefetchRequest) *prefetch {
 	ctx, cancel := context.WithTimeout(p.ctx, prefetchTimeout)
 	ctx = CtxWithRandomIDReplayable(
 		ctx, ctxPrefetchIDKey, ctxPrefetchID, p.log)
@@ -139,6 +190,9 @@ func (p *blockPrefetcher) newPrefetch(count int, triggered bool,
 		parents:           make(map[kbfsblock.ID]bool),
 		ctx:               ctx,
 		cancel:            cancel,
+		encodedSize:       uint32(bytes),
+		subtreeBytesTotal: bytes,
+		start:             p.config.Clock().Now(),
 	}
 }
 
@@ -168,13 +222,16 @@ func (p *blockPrefetcher) applyToParentsRecursive(
 	f(blockID, pre)
 }
 
-// Walk up the block tree decrementing each node by `numBlocks`. Any zeroes we
-// hit get marked complete and deleted.
+// Walk up the block tree decrementing each node by `numBlocks`, which
+// together make up `numBytes` encoded bytes. Any zeroes we hit get
+// marked complete and deleted.
 // TODO: If we ever hit a lower number than the child, panic.
 func (p *blockPrefetcher) completePrefetch(
-	numBlocks int) func(kbfsblock.ID, *prefetch) {
+	numBlocks int, numBytes uint64) func(kbfsblock.ID, *prefetch) {
 	return func(blockID kbfsblock.ID, pp *prefetch) {
 		pp.subtreeBlockCount -= numBlocks
+		pp.subtreeBlocksFetched += numBlocks
+		pp.subtreeBytesFetched += numBytes
 		if pp.subtreeBlockCount < 0 {
 			// Both log and panic so that we get the PFID in the log.
 			p.log.CErrorf(pp.ctx, "panic: completePrefetch overstepped its "+
@@ -211,12 +268,20 @@ func (p *blockPrefetcher) completePrefetch(
 	}
 }
 
-func (p *blockPrefetcher) decrementPrefetch(_ kbfsblock.ID, pp *prefetch) {
-	pp.subtreeBlockCount--
-	if pp.subtreeBlockCount < 0 {
-		// Both log and panic so that we get the PFID in the log.
-		p.log.CErrorf(pp.ctx, "panic: decrementPrefetch overstepped its bounds")
-		panic("decrementPrefetch overstepped its bounds")
+// Walk up the block tree decrementing each node by the one block that
+// was just fetched, of size `numBytes`.
+func (p *blockPrefetcher) decrementPrefetch(
+	numBytes uint64) func(kbfsblock.ID, *prefetch) {
+	return func(_ kbfsblock.ID, pp *prefetch) {
+		pp.subtreeBlockCount--
+		pp.subtreeBlocksFetched++
+		pp.subtreeBytesFetched += numBytes
+		if pp.subtreeBlockCount < 0 {
+			// Both log and panic so that we get the PFID in the log.
+			p.log.CErrorf(pp.ctx,
+				"panic: decrementPrefetch overstepped its bounds")
+			panic("decrementPrefetch overstepped its bounds")
+		}
 	}
 }
 
@@ -255,21 +320,25 @@ top:
 }
 
 // calculatePriority returns either a base priority for an unsynced TLF or a
-// high priority for a synced TLF.
+// high priority for a synced TLF or synced path.
 func (p *blockPrefetcher) calculatePriority(basePriority int,
-	tlfID tlf.ID) int {
-	if p.config.IsSyncedTlf(tlfID) {
-		return defaultOnDemandRequestPriority - 1
+	tlfID tlf.ID, isSyncedPath bool) int {
+	if isSyncedPath || p.config.IsSyncedTlf(tlfID) {
+		return highestPrefetchPriority
 	}
 	return basePriority
 }
 
 // request maps the parent->child block relationship in the prefetcher, and it
-// triggers child prefetches that aren't already in progress.
+// triggers child prefetches that aren't already in progress.  It returns the
+// number of blocks, and their encoded bytes, that need to be added to the
+// parent's subtree.
 func (p *blockPrefetcher) request(ctx context.Context, priority int,
-	kmd KeyMetadata, ptr BlockPointer, block Block,
+	kmd KeyMetadata, info BlockInfo, block Block,
 	lifetime BlockCacheLifetime, parentBlockID kbfsblock.ID,
-	isParentNew, isDeepSync bool) (numBlocks int) {
+	isParentNew, isDeepSync bool, syncPaths []string) (
+	numBlocks int, numBytes uint64) {
+	ptr := info.BlockPointer
 	// If the prefetch is already waiting, don't make it wait again.
 	// Add the parent, however.
 	pre, isPrefetchWaiting := p.prefetches[ptr.ID]
@@ -277,8 +346,8 @@ func (p *blockPrefetcher) request(ctx context.Context, priority int,
 		// If the block isn't in the tree, we add it with a block count of 1 (a
 		// later TriggerPrefetch will come in and decrement it).
 		req := &prefetchRequest{ptr, block, kmd, priority, lifetime,
-			NoPrefetch, isDeepSync}
-		pre = p.newPrefetch(1, false, req)
+			NoPrefetch, isDeepSync, syncPaths}
+		pre = p.newPrefetch(1, uint64(info.EncodedSize), false, req)
 		p.prefetches[ptr.ID] = pre
 		ch := p.retriever.Request(pre.ctx, priority, kmd, ptr, block, lifetime)
 		p.inFlightFetches.In() <- ch
@@ -300,54 +369,81 @@ func (p *blockPrefetcher) request(ctx context.Context, priority int,
 		// 2. The parent is newly created but the child _did_ know about it,
 		// like when the parent previously had a prefetch but was canceled.
 		pre.parents[parentBlockID] = true
-		return pre.subtreeBlockCount
+		return pre.subtreeBlockCount, pre.pendingBytes()
 	}
-	return 0
+	return 0, 0
 }
 
 func (p *blockPrefetcher) prefetchIndirectFileBlock(ctx context.Context,
 	parentBlockID kbfsblock.ID, b *FileBlock, kmd KeyMetadata,
 	lifetime BlockCacheLifetime, isPrefetchNew, isDeepSync bool) (numBlocks int,
-	isTail bool) {
+	numBytes uint64, isTail bool) {
 	// Prefetch indirect block pointers.
-	startingPriority :=
-		p.calculatePriority(fileIndirectBlockPrefetchPriority, kmd.TlfID())
+	startingPriority := p.calculatePriority(
+		fileIndirectBlockPrefetchPriority, kmd.TlfID(), isDeepSync)
 	for i, ptr := range b.IPtrs {
-		numBlocks += p.request(ctx, startingPriority-i, kmd,
-			ptr.BlockPointer, b.NewEmpty(), lifetime,
-			parentBlockID, isPrefetchNew, isDeepSync)
+		blocks, bytes := p.request(ctx, startingPriority-i, kmd,
+			ptr.BlockInfo, b.NewEmpty(), lifetime,
+			parentBlockID, isPrefetchNew, isDeepSync, nil)
+		numBlocks += blocks
+		numBytes += bytes
 	}
-	return numBlocks, len(b.IPtrs) == 0
+	return numBlocks, numBytes, len(b.IPtrs) == 0
 }
 
 func (p *blockPrefetcher) prefetchIndirectDirBlock(ctx context.Context,
 	parentBlockID kbfsblock.ID, b *DirBlock, kmd KeyMetadata,
-	lifetime BlockCacheLifetime, isPrefetchNew, isDeepSync bool) (numBlocks int,
-	isTail bool) {
-	// Prefetch indirect block pointers.
-	startingPriority :=
-		p.calculatePriority(fileIndirectBlockPrefetchPriority, kmd.TlfID())
+	lifetime BlockCacheLifetime, isPrefetchNew, isDeepSync bool,
+	syncPaths []string) (numBlocks int, numBytes uint64, isTail bool) {
+	// Prefetch indirect block pointers. They all belong to the same
+	// directory, so they share its synced paths.
+	startingPriority := p.calculatePriority(fileIndirectBlockPrefetchPriority,
+		kmd.TlfID(), isDeepSync || len(syncPaths) > 0)
 	for i, ptr := range b.IPtrs {
-		numBlocks += p.request(ctx, startingPriority-i, kmd,
-			ptr.BlockPointer, b.NewEmpty(), lifetime,
-			parentBlockID, isPrefetchNew, isDeepSync)
+		blocks, bytes := p.request(ctx, startingPriority-i, kmd,
+			ptr.BlockInfo, b.NewEmpty(), lifetime,
+			parentBlockID, isPrefetchNew, isDeepSync, syncPaths)
+		numBlocks += blocks
+		numBytes += bytes
 	}
-	return numBlocks, len(b.IPtrs) == 0
+	return numBlocks, numBytes, len(b.IPtrs) == 0
+}
+
+// childSyncPaths returns the synced paths relative to the directory entry
+// `name`, given the synced paths relative to its parent directory. It also
+// returns whether the entry itself is a synced path, in which case its whole
+// subtree needs to be deep-synced.
+func childSyncPaths(syncPaths []string, name string) (
+	childPaths []string, isSyncedPath bool) {
+	prefix := name + "/"
+	for _, p := range syncPaths {
+		if p == name {
+			isSyncedPath = true
+		} else if strings.HasPrefix(p, prefix) {
+			childPaths = append(childPaths, p[len(prefix):])
+		}
+	}
+	return childPaths, isSyncedPath
 }
 
 func (p *blockPrefetcher) prefetchDirectDirBlock(ctx context.Context,
 	parentBlockID kbfsblock.ID, b *DirBlock, kmd KeyMetadata,
-	lifetime BlockCacheLifetime, isPrefetchNew, isDeepSync bool) (
-	numBlocks int, isTail bool) {
+	lifetime BlockCacheLifetime, isPrefetchNew, isDeepSync bool,
+	syncPaths []string) (numBlocks int, numBytes uint64, isTail bool) {
 	// Prefetch all DirEntry root blocks.
 	dirEntries := dirEntriesBySizeAsc{dirEntryMapToDirEntries(b.Children)}
 	sort.Sort(dirEntries)
-	startingPriority :=
-		p.calculatePriority(dirEntryPrefetchPriority, kmd.TlfID())
 	totalChildEntries := 0
 	for i, entry := range dirEntries.dirEntries {
+		childIsDeepSync := isDeepSync
+		var childPaths []string
+		if !isDeepSync && len(syncPaths) > 0 {
+			childPaths, childIsDeepSync = childSyncPaths(
+				syncPaths, entry.entryName)
+		}
 		// Prioritize small files
-		priority := startingPriority - i
+		priority := p.calculatePriority(dirEntryPrefetchPriority,
+			kmd.TlfID(), childIsDeepSync || len(childPaths) > 0) - i
 		var block Block
 		switch entry.Type {
 		case Dir:
@@ -365,13 +461,16 @@ func (p *blockPrefetcher) prefetchDirectDirBlock(ctx context.Context,
 			continue
 		}
 		totalChildEntries++
-		numBlocks += p.request(ctx, priority, kmd, entry.BlockPointer,
-			block, lifetime, parentBlockID, isPrefetchNew, isDeepSync)
+		blocks, bytes := p.request(ctx, priority, kmd, entry.BlockInfo,
+			block, lifetime, parentBlockID, isPrefetchNew, childIsDeepSync,
+			childPaths)
+		numBlocks += blocks
+		numBytes += bytes
 	}
 	if totalChildEntries == 0 {
 		isTail = true
 	}
-	return numBlocks, isTail
+	return numBlocks, numBytes, isTail
 }
 
 // handlePrefetch allows the prefetcher to trigger prefetches. `run` calls this
@@ -379,9 +478,10 @@ func (p *blockPrefetcher) prefetchDirectDirBlock(ctx context.Context,
 // initiate a prefetch for this block's children.
 // Returns `numBlocks` which indicates how many additional blocks (blocks not
 // currently in the prefetch tree) with a parent of `pre.req.ptr.ID` must be
-// added to the tree.
+// added to the tree, and `numBytes`, their total encoded size.
 func (p *blockPrefetcher) handlePrefetch(pre *prefetch, isPrefetchNew,
-	isDeepSync bool) (numBlocks int, isTail bool, err error) {
+	isDeepSync bool, syncPaths []string) (
+	numBlocks int, numBytes uint64, isTail bool, err error) {
 	req := pre.req
 	b := req.block.NewEmpty()
 	// TODO: after we split out priority from whether to prefetch, make this a
@@ -391,12 +491,15 @@ func (p *blockPrefetcher) handlePrefetch(pre *prefetch, isPrefetchNew,
 	if err != nil {
 		p.log.CDebugf(pre.ctx, "failed to retrieve block %s to handle its "+
 			"prefetch: %+v", req.ptr.ID, err)
-		return 0, false, err
+		return 0, 0, false, err
+	}
+	if isDeepSync || len(syncPaths) > 0 {
+		p.moveToSyncCacheIfNeeded(pre.ctx, req)
 	}
 	switch b := b.(type) {
 	case *FileBlock:
 		if b.IsInd {
-			numBlocks, isTail = p.prefetchIndirectFileBlock(pre.ctx,
+			numBlocks, numBytes, isTail = p.prefetchIndirectFileBlock(pre.ctx,
 				req.ptr.ID, b, req.kmd, req.lifetime, isPrefetchNew,
 				isDeepSync)
 		} else {
@@ -404,17 +507,17 @@ func (p *blockPrefetcher) handlePrefetch(pre *prefetch, isPrefetchNew,
 		}
 	case *DirBlock:
 		if b.IsInd {
-			numBlocks, isTail = p.prefetchIndirectDirBlock(pre.ctx, req.ptr.ID,
-				b, req.kmd, req.lifetime, isPrefetchNew, isDeepSync)
+			numBlocks, numBytes, isTail = p.prefetchIndirectDirBlock(pre.ctx, req.ptr.ID,
+				b, req.kmd, req.lifetime, isPrefetchNew, isDeepSync, syncPaths)
 		} else {
-			numBlocks, isTail = p.prefetchDirectDirBlock(pre.ctx, req.ptr.ID,
-				b, req.kmd, req.lifetime, isPrefetchNew, isDeepSync)
+			numBlocks, numBytes, isTail = p.prefetchDirectDirBlock(pre.ctx, req.ptr.ID,
+				b, req.kmd, req.lifetime, isPrefetchNew, isDeepSync, syncPaths)
 		}
 	default:
 		// Skipping prefetch for block of unknown type (likely CommonBlock)
-		return 0, false, errors.New("unknown block type")
+		return 0, 0, false, errors.New("unknown block type")
 	}
-	return numBlocks, isTail, nil
+	return numBlocks, numBytes, isTail, nil
 }
 
 // run prefetches blocks.
@@ -458,6 +561,7 @@ func (p *blockPrefetcher) run(testSyncCh <-chan struct{}) {
 		close(p.doneCh)
 		p.prefetchRequestCh.Close()
 		p.prefetchCancelCh.Close()
+		p.prefetchStatusCh.Close()
 		p.inFlightFetches.Close()
 	}()
 	isShuttingDown := false
@@ -466,7 +570,8 @@ func (p *blockPrefetcher) run(testSyncCh <-chan struct{}) {
 		if isShuttingDown {
 			if p.inFlightFetches.Len() == 0 &&
 				p.prefetchRequestCh.Len() == 0 &&
-				p.prefetchCancelCh.Len() == 0 {
+				p.prefetchCancelCh.Len() == 0 &&
+				p.prefetchStatusCh.Len() == 0 {
 				return
 			}
 		} else if testSyncCh != nil {
@@ -488,6 +593,14 @@ func (p *blockPrefetcher) run(testSyncCh <-chan struct{}) {
 			p.log.Debug("canceling prefetch for block %s", blockID)
 			// Walk up the block tree and delete every parent.
 			p.applyToParentsRecursive(p.cancelPrefetch, blockID, pre)
+		case reqInt := <-p.prefetchStatusCh.Out():
+			req := reqInt.(*prefetchStatusRequest)
+			pre, ok := p.prefetches[req.blockID]
+			if !ok {
+				req.ch <- prefetchStatusResponse{ok: false}
+				continue
+			}
+			req.ch <- prefetchStatusResponse{p.progress(pre), true}
 		case reqInt := <-p.prefetchRequestCh.Out():
 			req := reqInt.(*prefetchRequest)
 			pre, isPrefetchWaiting := p.prefetches[req.ptr.ID]
@@ -496,10 +609,29 @@ func (p *blockPrefetcher) run(testSyncCh <-chan struct{}) {
 				// has a req associated with it.
 				pre.req = req
 			}
+			if isPrefetchWaiting && pre.req != req && !req.isDeepSync &&
+				len(req.syncPaths) == 0 {
+				// A block within a synced path of an otherwise unsynced
+				// TLF only knows that it's synced through the parent
+				// that requested it.
+				req.isDeepSync = pre.req.isDeepSync
+				req.syncPaths = pre.req.syncPaths
+			}
 			ctx := context.TODO()
 			if isPrefetchWaiting {
 				ctx = pre.ctx
 			}
+			if req.prefetchStatus == FinishedPrefetch &&
+				(req.isDeepSync || len(req.syncPaths) > 0) &&
+				p.isInWorkingSetCache(ctx, req) {
+				// The block finished prefetching before it became part
+				// of a synced path, so it and its subtree may still be
+				// in the working set cache.  Walk the subtree again, as
+				// if it had only been triggered, so that its blocks get
+				// moved into the sync cache.  Blocks that were already
+				// moved stop the walk.
+				req.prefetchStatus = TriggeredPrefetch
+			}
 			if req.prefetchStatus == FinishedPrefetch {
 				// First we handle finished prefetches.
 				if isPrefetchWaiting {
@@ -516,8 +648,12 @@ func (p *blockPrefetcher) run(testSyncCh <-chan struct{}) {
 					// prefetcher.
 					p.log.CDebugf(ctx, "finishing prefetch for block %s",
 						req.ptr.ID)
+					if req.isDeepSync || len(req.syncPaths) > 0 {
+						p.moveToSyncCacheIfNeeded(ctx, req)
+					}
 					p.applyToParentsRecursive(
-						p.completePrefetch(pre.subtreeBlockCount),
+						p.completePrefetch(
+							pre.subtreeBlockCount, pre.pendingBytes()),
 						req.ptr.ID, pre)
 				} else {
 					p.log.CDebugf(ctx, "skipping prefetch for finished block "+
@@ -531,7 +667,8 @@ func (p *blockPrefetcher) run(testSyncCh <-chan struct{}) {
 					req.ptr.ID)
 				continue
 			}
-			if req.prefetchStatus == TriggeredPrefetch && !req.isDeepSync {
+			if req.prefetchStatus == TriggeredPrefetch && !req.isDeepSync &&
+				len(req.syncPaths) == 0 {
 				p.log.CDebugf(ctx, "prefetch already triggered for block ID "+
 					"%s", req.ptr.ID)
 				continue
@@ -556,6 +693,10 @@ func (p *blockPrefetcher) run(testSyncCh <-chan struct{}) {
 						// The prefetcher doesn't know about a deep sync but
 						// now one has been created.
 						pre.req.isDeepSync = true
+					} else if len(pre.req.syncPaths) == 0 &&
+						len(req.syncPaths) > 0 {
+						// Same as above, but for synced paths.
+						pre.req.syncPaths = req.syncPaths
 					} else {
 						// Short circuit prefetches if the subtree was already
 						// triggered, unless, as in the above case, we've
@@ -574,8 +715,9 @@ func (p *blockPrefetcher) run(testSyncCh <-chan struct{}) {
 						panic("prefetch was in the tree, wasn't triggered, " +
 							"but had a block count of 0")
 					}
-					p.applyToParentsRecursive(p.decrementPrefetch, req.ptr.ID,
-						pre)
+					p.applyToParentsRecursive(
+						p.decrementPrefetch(uint64(pre.encodedSize)),
+						req.ptr.ID, pre)
 					pre.subtreeTriggered = true
 				}
 			} else {
@@ -583,7 +725,7 @@ func (p *blockPrefetcher) run(testSyncCh <-chan struct{}) {
 				// If the prefetch is to be tracked, then the 0
 				// `subtreeBlockCount` will be incremented by `numBlocks`
 				// below, once we've ensured that `numBlocks` is not 0.
-				pre = p.newPrefetch(0, true, req)
+				pre = p.newPrefetch(0, 0, true, req)
 				p.prefetches[req.ptr.ID] = pre
 				ctx = pre.ctx
 				p.log.CDebugf(ctx, "created new prefetch for block %s",
@@ -599,8 +741,8 @@ func (p *blockPrefetcher) run(testSyncCh <-chan struct{}) {
 			//
 			// `numBlocks` now represents only the number of blocks to add
 			// to the tree from `pre` to its roots, inclusive.
-			numBlocks, isTail, err := p.handlePrefetch(pre, !isPrefetchWaiting,
-				req.isDeepSync)
+			numBlocks, numBytes, isTail, err := p.handlePrefetch(pre, !isPrefetchWaiting,
+				req.isDeepSync, req.syncPaths)
 			if err != nil {
 				p.log.CWarningf(ctx, "error handling prefetch for block %s: "+
 					"%+v", req.ptr.ID, err)
@@ -625,7 +767,7 @@ func (p *blockPrefetcher) run(testSyncCh <-chan struct{}) {
 				// only walk up the tree once. We'd track a `numBlocks` and
 				// complete or decrement as appropriate.
 				p.applyToParentsRecursive(
-					p.completePrefetch(0), req.ptr.ID, pre)
+					p.completePrefetch(0, 0), req.ptr.ID, pre)
 				continue
 			}
 			// This is not a tail block.
@@ -655,6 +797,7 @@ func (p *blockPrefetcher) run(testSyncCh <-chan struct{}) {
 			// starting with this block.
 			p.applyToParentsRecursive(func(_ kbfsblock.ID, pp *prefetch) {
 				pp.subtreeBlockCount += numBlocks
+				pp.subtreeBytesTotal += numBytes
 			}, req.ptr.ID, pre)
 		case <-p.almostDoneCh:
 			p.log.CDebugf(p.ctx, "starting shutdown")
@@ -687,13 +830,68 @@ func (p *blockPrefetcher) cacheOrCancelPrefetch(ctx context.Context,
 	return err
 }
 
+// moveToSyncCacheIfNeeded moves the block for `req` into the sync cache if
+// it's part of a synced path in a TLF that isn't synced as a whole. Blocks
+// of synced TLFs are put directly into the sync cache instead.
+func (p *blockPrefetcher) moveToSyncCacheIfNeeded(
+	ctx context.Context, req *prefetchRequest) {
+	tlfID := req.kmd.TlfID()
+	if p.config.IsSyncedTlf(tlfID) {
+		return
+	}
+	wrappedCache, ok := p.config.DiskBlockCache().(*diskBlockCacheWrapped)
+	if !ok {
+		return
+	}
+	err := wrappedCache.moveToSyncCache(ctx, tlfID, req.ptr.ID)
+	if err != nil {
+		p.log.CDebugf(ctx, "couldn't move block %s of a synced path into "+
+			"the sync cache: %+v", req.ptr.ID, err)
+	}
+}
+
+// isInWorkingSetCache returns true if the block for `req` belongs to a
+// TLF that isn't synced as a whole, and is in the working set cache,
+// i.e., it would need to be moved into the sync cache if it's part of
+// a synced path.
+func (p *blockPrefetcher) isInWorkingSetCache(
+	ctx context.Context, req *prefetchRequest) bool {
+	if p.config.IsSyncedTlf(req.kmd.TlfID()) {
+		return false
+	}
+	wrappedCache, ok := p.config.DiskBlockCache().(*diskBlockCacheWrapped)
+	if !ok {
+		return false
+	}
+	return wrappedCache.isInWorkingSetCache(ctx, req.ptr.ID)
+}
+
+// rootSyncPaths returns the synced paths of the TLF if `ptr` is the root
+// directory block in `kmd`, and the TLF isn't already synced as a whole.
+func (p *blockPrefetcher) rootSyncPaths(
+	ptr BlockPointer, kmd KeyMetadata) []string {
+	syncPaths := p.config.GetTlfSyncedPaths(kmd.TlfID())
+	if len(syncPaths) == 0 {
+		return nil
+	}
+	md, ok := kmd.(privateMetadataGetter)
+	if !ok || md.Data().Dir.BlockPointer.ID != ptr.ID {
+		return nil
+	}
+	return syncPaths
+}
+
 // ProcessBlockForPrefetch triggers a prefetch if appropriate.
 func (p *blockPrefetcher) ProcessBlockForPrefetch(ctx context.Context,
 	ptr BlockPointer, block Block, kmd KeyMetadata, priority int,
 	lifetime BlockCacheLifetime, prefetchStatus PrefetchStatus) {
 	isDeepSync := p.config.IsSyncedTlf(kmd.TlfID())
+	var syncPaths []string
+	if !isDeepSync {
+		syncPaths = p.rootSyncPaths(ptr, kmd)
+	}
 	req := &prefetchRequest{ptr, block.NewEmpty(), kmd, priority, lifetime,
-		prefetchStatus, isDeepSync}
+		prefetchStatus, isDeepSync, syncPaths}
 	if prefetchStatus == FinishedPrefetch {
 		// Finished prefetches can always be short circuited.
 		// If we're here, then FinishedPrefetch is already cached.
@@ -735,6 +933,63 @@ func (p *blockPrefetcher) CancelPrefetch(blockID kbfsblock.ID) {
 	}
 }
 
+// progress returns the progress of the prefetch of the subtree of
+// `pre`, including an estimate of when it will be done based on the
+// byte rate so far.  The totals only include the blocks discovered so
+// far.
+func (p *blockPrefetcher) progress(pre *prefetch) PrefetchProgress {
+	progress := PrefetchProgress{
+		BlocksFetched: pre.subtreeBlocksFetched,
+		BlocksTotal:   pre.subtreeBlocksFetched + pre.subtreeBlockCount,
+		BytesFetched:  pre.subtreeBytesFetched,
+		BytesTotal:    pre.subtreeBytesFetched + pre.pendingBytes(),
+		Start:         pre.start,
+	}
+	now := p.config.Clock().Now()
+	elapsed := now.Sub(pre.start)
+	if progress.BytesFetched == 0 || elapsed <= 0 {
+		// No way to estimate the byte rate yet.
+		return progress
+	}
+	bytesPerSec := float64(progress.BytesFetched) / elapsed.Seconds()
+	remaining := time.Duration(
+		float64(pre.pendingBytes()) / bytesPerSec * float64(time.Second))
+	progress.EndEstimate = now.Add(remaining)
+	return progress
+}
+
+// Status implements the Prefetcher interface for blockPrefetcher.
+func (p *blockPrefetcher) Status(ctx context.Context, ptr BlockPointer) (
+	PrefetchProgress, error) {
+	ch := make(chan prefetchStatusResponse, 1)
+	select {
+	case p.prefetchStatusCh.In() <- &prefetchStatusRequest{ptr.ID, ch}:
+	case <-p.shutdownCh:
+		return PrefetchProgress{}, errors.New("prefetcher is shutdown")
+	case <-ctx.Done():
+		return PrefetchProgress{}, ctx.Err()
+	}
+
+	var resp prefetchStatusResponse
+	select {
+	case resp = <-ch:
+	case <-p.doneCh:
+		// The request might have been answered right before `run`
+		// exited.
+		select {
+		case resp = <-ch:
+		default:
+			return PrefetchProgress{}, errors.New("prefetcher is shutdown")
+		}
+	case <-ctx.Done():
+		return PrefetchProgress{}, ctx.Err()
+	}
+	if !resp.ok {
+		return PrefetchProgress{}, NoSuchPrefetchError{ptr}
+	}
+	return resp.progress, nil
+}
+
 // Shutdown implements the Prefetcher interface for blockPrefetcher.
 func (p *blockPrefetcher) Shutdown() <-chan struct{} {
 	p.shutdownOnce.Do(func() {
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/keybase/go-codec/codec"
+	"github.com/keybase/kbfs/tlf"
 	"github.com/stretchr/testify/require"
 )
 
@@ -1475,3 +1476,299 @@ func TestPrefetcherUnsyncedPrefetchParentCanceled(t *testing.T) {
 	// Then we wait for the pending prefetches to complete.
 	waitForPrefetchOrBust(t, q.Prefetcher().Shutdown())
 }
+
+// rootDirKMD is a KeyMetadata that also knows the root directory block of
+// its TLF, like a real MD does.
+type rootDirKMD struct {
+	emptyKeyMetadata
+	rootPtr BlockPointer
+}
+
+func (kmd rootDirKMD) Data() *PrivateMetadata {
+	return &PrivateMetadata{
+		Dir: DirEntry{BlockInfo: BlockInfo{BlockPointer: kmd.rootPtr}},
+	}
+}
+
+func testPrefetcherCheckSyncCache(t *testing.T, dbc *diskBlockCacheWrapped,
+	tlfID tlf.ID, ptr BlockPointer, expectedInSyncCache bool) {
+	t.Helper()
+	ctx := context.Background()
+	_, _, _, err := dbc.syncCache.Get(ctx, tlfID, ptr.ID)
+	if expectedInSyncCache {
+		require.NoError(t, err)
+	} else {
+		require.IsType(t, NoSuchBlockError{}, err)
+	}
+	_, _, _, err = dbc.workingSetCache.Get(ctx, tlfID, ptr.ID)
+	if expectedInSyncCache {
+		require.IsType(t, NoSuchBlockError{}, err)
+	} else {
+		require.NoError(t, err)
+	}
+}
+
+func waitForSyncCacheOrBust(t *testing.T, dbc *diskBlockCacheWrapped,
+	tlfID tlf.ID, ptr BlockPointer) {
+	t.Helper()
+	start := time.Now()
+	for {
+		_, _, _, err := dbc.syncCache.Get(context.Background(), tlfID, ptr.ID)
+		if err == nil {
+			return
+		}
+		require.True(t, time.Since(start) < 5*time.Second,
+			"Timed out waiting for the sync cache. Stack:\n"+getStack())
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPrefetcherSyncedPath(t *testing.T) {
+	t.Log("Test prefetching a synced path within an unsynced TLF.")
+	dbc, dbcConfig := initDiskBlockCacheTest(t)
+	q, bg, config := initPrefetcherTestWithDiskCache(t, dbc)
+	defer shutdownPrefetcherTest(q)
+	ctx := context.Background()
+
+	t.Log("Initialize a folder tree with structure: " +
+		"root -> {b, a -> {ab, aa -> {aab, aaa}}}")
+	rootPtr := makeRandomBlockPointer(t)
+	root := &DirBlock{Children: map[string]DirEntry{
+		"a": makeRandomDirEntry(t, Dir, 10, "a"),
+		"b": makeRandomDirEntry(t, File, 20, "b"),
+	}}
+	aPtr := root.Children["a"].BlockPointer
+	a := &DirBlock{Children: map[string]DirEntry{
+		"aa": makeRandomDirEntry(t, Dir, 30, "aa"),
+		"ab": makeRandomDirEntry(t, File, 40, "ab"),
+	}}
+	bPtr := root.Children["b"].BlockPointer
+	b := makeFakeFileBlock(t, true)
+	aaPtr := a.Children["aa"].BlockPointer
+	aa := &DirBlock{Children: map[string]DirEntry{
+		"aaa": makeRandomDirEntry(t, File, 50, "aaa"),
+		"aab": makeRandomDirEntry(t, File, 60, "aab"),
+	}}
+	abPtr := a.Children["ab"].BlockPointer
+	ab := makeFakeFileBlock(t, true)
+	aaaPtr := aa.Children["aaa"].BlockPointer
+	aaa := makeFakeFileBlock(t, true)
+	aabPtr := aa.Children["aab"].BlockPointer
+	aab := makeFakeFileBlock(t, true)
+
+	kmd := rootDirKMD{emptyKeyMetadata{makeKMD().TlfID(), 1}, rootPtr}
+	tlfID := kmd.TlfID()
+	ptrs := []BlockPointer{rootPtr, aPtr, bPtr, aaPtr, abPtr, aaaPtr, aabPtr}
+	blocks := []Block{root, a, b, aa, ab, aaa, aab}
+	for i, ptr := range ptrs {
+		_, _ = bg.setBlockToReturn(ptr, blocks[i])
+		t.Logf("Put block %s in the working set cache.", ptr.ID)
+		enc, serverHalf :=
+			setupRealBlockForDiskCache(t, ptr, blocks[i], dbcConfig)
+		err := dbc.workingSetCache.Put(
+			ctx, tlfID, ptr.ID, enc, serverHalf)
+		require.NoError(t, err)
+	}
+
+	setSyncedPath := func(isSynced bool) {
+		err := config.SetTlfPathSyncState(tlfID, "a/aa", isSynced)
+		require.NoError(t, err)
+		err = dbcConfig.SetTlfPathSyncState(tlfID, "a/aa", isSynced)
+		require.NoError(t, err)
+		// Like ConfigLocal, restart the prefetcher after the change.
+		<-q.TogglePrefetcher(true, nil)
+	}
+	setSyncedPath(true)
+
+	fetchRoot := func() {
+		block := &DirBlock{}
+		ch := q.Request(ctx, defaultOnDemandRequestPriority, kmd,
+			rootPtr, block, TransientEntry)
+		err := <-ch
+		require.NoError(t, err)
+	}
+
+	t.Log("Fetch dir root.")
+	fetchRoot()
+
+	t.Log("Wait for the synced path to finish prefetching.")
+	start := time.Now()
+	for {
+		_, prefetchStatus, _, err := config.BlockCache().GetWithPrefetch(
+			aaPtr)
+		if err == nil && prefetchStatus == FinishedPrefetch {
+			break
+		}
+		require.True(t, time.Since(start) < 5*time.Second,
+			"Timed out waiting for prefetch. Stack:\n"+getStack())
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Log("Ensure that only the synced path was deep-synced.")
+	testPrefetcherCheckGet(t, config.BlockCache(), rootPtr, root,
+		TriggeredPrefetch, TransientEntry)
+	testPrefetcherCheckGet(t, config.BlockCache(), aPtr, a,
+		TriggeredPrefetch, TransientEntry)
+	testPrefetcherCheckGet(t, config.BlockCache(), bPtr, b,
+		NoPrefetch, TransientEntry)
+	testPrefetcherCheckGet(t, config.BlockCache(), abPtr, ab,
+		NoPrefetch, TransientEntry)
+	testPrefetcherCheckGet(t, config.BlockCache(), aaPtr, aa,
+		FinishedPrefetch, TransientEntry)
+	testPrefetcherCheckGet(t, config.BlockCache(), aaaPtr, aaa,
+		FinishedPrefetch, TransientEntry)
+	testPrefetcherCheckGet(t, config.BlockCache(), aabPtr, aab,
+		FinishedPrefetch, TransientEntry)
+
+	t.Log("Ensure that the synced path and its parents were moved into " +
+		"the sync cache, and nothing else.")
+	checkDiskCaches := func() {
+		t.Helper()
+		testPrefetcherCheckSyncCache(t, dbc, tlfID, rootPtr, true)
+		testPrefetcherCheckSyncCache(t, dbc, tlfID, aPtr, true)
+		testPrefetcherCheckSyncCache(t, dbc, tlfID, bPtr, false)
+		testPrefetcherCheckSyncCache(t, dbc, tlfID, abPtr, false)
+		testPrefetcherCheckSyncCache(t, dbc, tlfID, aaPtr, true)
+		testPrefetcherCheckSyncCache(t, dbc, tlfID, aaaPtr, true)
+		testPrefetcherCheckSyncCache(t, dbc, tlfID, aabPtr, true)
+	}
+	checkDiskCaches()
+
+	t.Log("Unpin the path, which moves its blocks back to the working " +
+		"set cache.")
+	setSyncedPath(false)
+	err := dbc.moveTlfToWorkingSetCache(ctx, tlfID)
+	require.NoError(t, err)
+	for _, ptr := range ptrs {
+		testPrefetcherCheckSyncCache(t, dbc, tlfID, ptr, false)
+	}
+
+	t.Log("Pin it again.  Its blocks already finished prefetching, but " +
+		"they must still be moved into the sync cache.")
+	setSyncedPath(true)
+	fetchRoot()
+	waitForSyncCacheOrBust(t, dbc, tlfID, aaaPtr)
+	waitForSyncCacheOrBust(t, dbc, tlfID, aabPtr)
+	waitForPrefetchOrBust(t, q.Prefetcher().Shutdown())
+	checkDiskCaches()
+}
+
+func testPrefetcherStatus(t *testing.T, q *blockRetrievalQueue,
+	prefetchSyncCh chan<- struct{}, ptr BlockPointer) (
+	PrefetchProgress, error) {
+	t.Helper()
+	type result struct {
+		progress PrefetchProgress
+		err      error
+	}
+	resCh := make(chan result, 1)
+	go func() {
+		progress, err := q.Prefetcher().Status(context.Background(), ptr)
+		resCh <- result{progress, err}
+	}()
+	// The status request is handled by the prefetch loop.
+	notifySyncCh(t, prefetchSyncCh)
+	select {
+	case res := <-resCh:
+		return res.progress, res.err
+	case <-time.After(time.Second):
+		t.Fatal("Failed to get prefetch status. Stack:\n" + getStack())
+	}
+	return PrefetchProgress{}, nil
+}
+
+func TestPrefetcherStatus(t *testing.T) {
+	t.Log("Test the progress reported for a synced TLF prefetch.")
+	q, bg, config := initPrefetcherTest(t)
+	defer shutdownPrefetcherTest(q)
+	prefetchSyncCh := make(chan struct{})
+	q.TogglePrefetcher(true, prefetchSyncCh)
+	notifySyncCh(t, prefetchSyncCh)
+	clock := config.Clock().(*TestClock)
+
+	kmd := makeKMD()
+	config.SetTlfSyncState(kmd.TlfID(), true)
+
+	t.Log("Initialize a direct dir block with entries pointing to 2 files.")
+	fileA := makeFakeFileBlock(t, true)
+	fileB := makeFakeFileBlock(t, true)
+	rootPtr := makeRandomBlockPointer(t)
+	rootDir := &DirBlock{Children: map[string]DirEntry{
+		"a": makeRandomDirEntry(t, File, 100, "a"),
+		"b": makeRandomDirEntry(t, File, 60, "b"),
+	}}
+	_, continueChRootDir := bg.setBlockToReturn(rootPtr, rootDir)
+	_, continueChFileA :=
+		bg.setBlockToReturn(rootDir.Children["a"].BlockPointer, fileA)
+	_, continueChFileB :=
+		bg.setBlockToReturn(rootDir.Children["b"].BlockPointer, fileB)
+
+	_, err := testPrefetcherStatus(t, q, prefetchSyncCh, rootPtr)
+	require.IsType(t, NoSuchPrefetchError{}, err)
+
+	var block Block = &DirBlock{}
+	ch := q.Request(context.Background(),
+		defaultOnDemandRequestPriority, kmd, rootPtr, block, TransientEntry)
+	continueChRootDir <- nil
+	err = <-ch
+	require.NoError(t, err)
+	// Release after prefetching rootDir
+	notifySyncCh(t, prefetchSyncCh)
+
+	t.Log("Nothing has been fetched yet, so there's no estimate.")
+	progress, err := testPrefetcherStatus(t, q, prefetchSyncCh, rootPtr)
+	require.NoError(t, err)
+	entrySize := uint64(rootDir.Children["a"].EncodedSize)
+	require.Equal(t, PrefetchProgress{
+		BlocksFetched: 0,
+		BlocksTotal:   2,
+		BytesFetched:  0,
+		BytesTotal:    2 * entrySize,
+		Start:         clock.Now(),
+	}, progress)
+
+	t.Log("Fetch the smaller file first; at the same rate, the other " +
+		"one should take as long.")
+	start := clock.Now()
+	clock.Add(time.Second)
+	continueChFileB <- nil
+	// Release after prefetching fileB
+	notifySyncCh(t, prefetchSyncCh)
+	progress, err = testPrefetcherStatus(t, q, prefetchSyncCh, rootPtr)
+	require.NoError(t, err)
+	require.Equal(t, PrefetchProgress{
+		BlocksFetched: 1,
+		BlocksTotal:   2,
+		BytesFetched:  entrySize,
+		BytesTotal:    2 * entrySize,
+		Start:         start,
+		EndEstimate:   clock.Now().Add(time.Second),
+	}, progress)
+
+	t.Log("Once the prefetch is done, the prefetcher forgets about it.")
+	continueChFileA <- nil
+	// Release after prefetching fileA
+	notifySyncCh(t, prefetchSyncCh)
+	_, err = testPrefetcherStatus(t, q, prefetchSyncCh, rootPtr)
+	require.IsType(t, NoSuchPrefetchError{}, err)
+
+	waitForPrefetchOrBust(t, q.Prefetcher().Shutdown())
+	testPrefetcherCheckGet(t, config.BlockCache(), rootPtr, rootDir,
+		FinishedPrefetch, TransientEntry)
+}
+
+func TestPrefetcherDoneBeforeConfigShutdown(t *testing.T) {
+	config := MakeTestConfigOrBust(t, "alice")
+	pre := config.BlockOps().Prefetcher()
+
+	ctx := context.Background()
+	CheckConfigAndShutdown(ctx, t, config)
+
+	t.Log("The prefetcher must not outlive the config, or it might log " +
+		"after the test is done.")
+	select {
+	case <-pre.Shutdown():
+	default:
+		t.Fatal("Prefetcher still running after config shutdown")
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libkbfs
+
+import (
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+	// readAheadMinWindow is the number of blocks requested ahead of a
+	// file read once the file looks like it's being read
+	// sequentially.
+	readAheadMinWindow = 2
+	// readAheadMaxWindow caps the number of blocks requested ahead of
+	// a sequential reader, however fast it's reading.
+	readAheadMaxWindow = 32
+	// readAheadLeadTime is how far ahead of a sequential reader we
+	// try to stay, in terms of how long it takes the reader to get
+	// through the data.
+	readAheadLeadTime = 2 * time.Second
+	// readAheadMaxTrackedFiles is the number of files per TLF whose
+	// access patterns we remember.
+	readAheadMaxTrackedFiles = 64
+	// readAheadPriority is the retrieval priority for read-ahead
+	// blocks.  It's higher than all prefetches and, unlike them, isn't
+	// throttled as prefetch traffic, since a reader is likely to need
+	// these blocks soon, but it's lower than the blocks readers are
+	// actually waiting on.
+	readAheadPriority = lowestOnDemandRequestPriority
+	// readAheadAssumedBlockSize is the size we assume a leaf block
+	// has when turning a window in blocks into a byte range.
+	readAheadAssumedBlockSize = MaxBlockSizeBytesDefault
+	// readAheadLookupTimeout bounds how long looking up the
+	// pointers of read-ahead blocks may take.
+	readAheadLookupTimeout = 1 * time.Minute
+)
+
+type ctxReadAheadTagKey int
+
+const (
+	// ctxReadAheadIDKey is the type of the tag for the unique ID of
+	// a read-ahead.
+	ctxReadAheadIDKey ctxReadAheadTagKey = iota
+)
+
+// ctxReadAheadOpID is the display name for the unique ID of a
+// read-ahead.
+const ctxReadAheadOpID = "RAID"
+
+// readAheadState tracks the reads of a single file.
+type readAheadState struct {
+	// nextOff is where the next read starts if it's sequential.
+	nextOff int64
+	// requestedEnd is the end of the range of the file for which
+	// read-ahead blocks have already been requested.
+	requestedEnd int64
+	// lookingUp is set while the pointers of the next read-ahead
+	// blocks are being looked up, until setRequested is called.
+	lookingUp bool
+	// window is the current read-ahead window, in blocks.
+	window int
+	// lastRead is the time of the previous read.
+	lastRead time.Time
+	// throughput is a moving average of the read throughput of a
+	// sequential reader, in bytes per second.
+	throughput float64
+}
+
+// readAheadTracker detects sequential reads of files, and decides
+// which parts of them to read ahead.  It's goroutine-safe.
+type readAheadTracker struct {
+	clock Clock
+
+	lock  sync.Mutex
+	files *lru.Cache // NodeID -> *readAheadState
+}
+
+func newReadAheadTracker(clock Clock) *readAheadTracker {
+	files, err := lru.New(readAheadMaxTrackedFiles)
+	if err != nil {
+		panic(err.Error())
+	}
+	return &readAheadTracker{
+		clock: clock,
+		files: files,
+	}
+}
+
+// nextReadAheadWindow returns the number of blocks to read ahead of
+// a reader with the given throughput, given the current window.  The
+// window can at most double each time, so one fast read doesn't
+// trigger a flood of requests.
+func nextReadAheadWindow(window int, throughput float64) int {
+	want := int(throughput * readAheadLeadTime.Seconds() /
+		float64(readAheadAssumedBlockSize))
+	if want > 2*window {
+		want = 2 * window
+	}
+	if want < readAheadMinWindow {
+		want = readAheadMinWindow
+	}
+	if want > readAheadMaxWindow {
+		want = readAheadMaxWindow
+	}
+	return want
+}
+
+// observeRead records a read of n bytes at offset off of the given
+// file.  If the file is being read sequentially, it returns the
+// offset at which read-ahead should start, and the number of blocks
+// to read ahead.  In that case, the caller must call setRequested
+// once it has requested the blocks (or given up), and until then no
+// more read-ahead is started for the file.
+func (rat *readAheadTracker) observeRead(
+	id NodeID, off, n int64) (startOff int64, numBlocks int, ok bool) {
+	rat.lock.Lock()
+	defer rat.lock.Unlock()
+	now := rat.clock.Now()
+	end := off + n
+
+	var state *readAheadState
+	if tmp, ok := rat.files.Get(id); ok {
+		state = tmp.(*readAheadState)
+	}
+	if state == nil || state.nextOff != off || n <= 0 {
+		// Only a read starting at the beginning of a new file, or
+		// exactly where the last read ended, counts as sequential.
+		ok := state == nil && off == 0 && n > 0
+		rat.files.Add(id, &readAheadState{
+			nextOff:      end,
+			requestedEnd: end,
+			lookingUp:    ok,
+			window:       readAheadMinWindow,
+			lastRead:     now,
+		})
+		if !ok {
+			return 0, 0, false
+		}
+		return end, readAheadMinWindow, true
+	}
+
+	if elapsed := now.Sub(state.lastRead); elapsed > 0 {
+		current := float64(n) / elapsed.Seconds()
+		if state.throughput == 0 {
+			state.throughput = current
+		} else {
+			state.throughput = (state.throughput + current) / 2
+		}
+	}
+	state.window = nextReadAheadWindow(state.window, state.throughput)
+	state.nextOff = end
+	state.lastRead = now
+
+	startOff = end
+	if state.requestedEnd > startOff {
+		startOff = state.requestedEnd
+	}
+	windowEnd := end + int64(state.window)*int64(readAheadAssumedBlockSize)
+	if startOff >= windowEnd || state.lookingUp {
+		// We're still far enough ahead of the reader, or we'll
+		// know how far ahead we are once the current lookup is
+		// done.
+		return 0, 0, false
+	}
+	numBlocks = int((windowEnd - startOff + int64(
+		readAheadAssumedBlockSize) - 1) / int64(readAheadAssumedBlockSize))
+	state.lookingUp = true
+	return startOff, numBlocks, true
+}
+
+// setRequested records that read-ahead blocks of the given file
+// have been requested up to the given offset, and that the lookup
+// started by the last successful observeRead is done.
+func (rat *readAheadTracker) setRequested(id NodeID, requestedEnd int64) {
+	rat.lock.Lock()
+	defer rat.lock.Unlock()
+	tmp, ok := rat.files.Peek(id)
+	if !ok {
+		return
+	}
+	state := tmp.(*readAheadState)
+	state.lookingUp = false
+	if requestedEnd > state.requestedEnd {
+		state.requestedEnd = requestedEnd
+	}
+}
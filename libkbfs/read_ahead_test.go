@@ -0,0 +1,112 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libkbfs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testReadAheadNodeID struct{}
+
+func (*testReadAheadNodeID) ParentID() NodeID {
+	return nil
+}
+
+func TestReadAheadTrackerRandomReads(t *testing.T) {
+	rat := newReadAheadTracker(newTestClockNow())
+	id := &testReadAheadNodeID{}
+
+	t.Log("A first read in the middle of a file isn't sequential.")
+	_, _, ok := rat.observeRead(id, 100, 10)
+	require.False(t, ok)
+
+	t.Log("Neither is a read that skips ahead.")
+	_, _, ok = rat.observeRead(id, 200, 10)
+	require.False(t, ok)
+
+	t.Log("But one that picks up where the last one left off is.")
+	startOff, numBlocks, ok := rat.observeRead(id, 210, 10)
+	require.True(t, ok)
+	require.Equal(t, int64(220), startOff)
+	require.Equal(t, readAheadMinWindow, numBlocks)
+}
+
+func TestReadAheadTrackerSequentialReads(t *testing.T) {
+	clock := newTestClockNow()
+	rat := newReadAheadTracker(clock)
+	id := &testReadAheadNodeID{}
+	const readSize = int64(readAheadAssumedBlockSize)
+
+	t.Log("Reading a new file from the start triggers read-ahead.")
+	startOff, numBlocks, ok := rat.observeRead(id, 0, readSize)
+	require.True(t, ok)
+	require.Equal(t, readSize, startOff)
+	require.Equal(t, readAheadMinWindow, numBlocks)
+	requestedEnd := startOff + int64(numBlocks)*readSize
+	rat.setRequested(id, requestedEnd)
+
+	t.Log("A fast reader makes the window grow, but at most " +
+		"doubling each time.  Only blocks past the ones already " +
+		"requested are requested.")
+	off := readSize
+	window := readAheadMinWindow
+	for window < readAheadMaxWindow {
+		clock.Add(time.Millisecond)
+		startOff, numBlocks, ok = rat.observeRead(id, off, readSize)
+		require.True(t, ok)
+		off += readSize
+		window *= 2
+		if window > readAheadMaxWindow {
+			window = readAheadMaxWindow
+		}
+		require.Equal(t, requestedEnd, startOff)
+		require.Equal(t, off+int64(window)*readSize, startOff+
+			int64(numBlocks)*readSize)
+		requestedEnd = startOff + int64(numBlocks)*readSize
+		rat.setRequested(id, requestedEnd)
+	}
+
+	t.Log("A slow reader shrinks the window again.")
+	for i := 0; i < 20; i++ {
+		clock.Add(time.Hour)
+		startOff, numBlocks, ok = rat.observeRead(id, off, readSize)
+		off += readSize
+		if ok {
+			rat.setRequested(id, startOff+int64(numBlocks)*readSize)
+		}
+	}
+	rat.lock.Lock()
+	defer rat.lock.Unlock()
+	tmp, _ := rat.files.Peek(id)
+	require.Equal(t, readAheadMinWindow, tmp.(*readAheadState).window)
+}
+
+func TestReadAheadTrackerOneLookupAtATime(t *testing.T) {
+	clock := newTestClockNow()
+	rat := newReadAheadTracker(clock)
+	id := &testReadAheadNodeID{}
+	const readSize = int64(readAheadAssumedBlockSize)
+
+	startOff, numBlocks, ok := rat.observeRead(id, 0, readSize)
+	require.True(t, ok)
+
+	t.Log("No more read-ahead starts while the blocks are being " +
+		"looked up.")
+	clock.Add(time.Millisecond)
+	_, _, ok = rat.observeRead(id, readSize, readSize)
+	require.False(t, ok)
+
+	t.Log("Once the lookup is done, read-ahead picks up where it " +
+		"left off.")
+	requestedEnd := startOff + int64(numBlocks)*readSize
+	rat.setRequested(id, requestedEnd)
+	clock.Add(time.Millisecond)
+	startOff, _, ok = rat.observeRead(id, 2*readSize, readSize)
+	require.True(t, ok)
+	require.Equal(t, requestedEnd, startOff)
+}
@@ -36,6 +36,14 @@ type PrivateMetadata struct {
 	// was performed on this TLF.
 	LastGCRevision kbfsmd.Revision `codec:"lgc"`
 
+	// The conflict copies made by conflict resolution, along with
+	// any decisions about which version to keep.
+	Conflicts []ConflictCopy `codec:"cf,omitempty"`
+
+	// How conflict resolution names conflict copies in this TLF, if
+	// different from the default.
+	ConflictRenaming *ConflictRenaming `codec:"crn,omitempty"`
+
 	codec.UnknownFieldSetHandler
 
 	// When the above Changes field gets unembedded into its own
@@ -71,20 +71,26 @@ func runTestsOverMetadataVers(t *testing.T, prefix string,
 	fs []func(t *testing.T, ver kbfsmd.MetadataVer)) {
 	for _, f := range fs {
 		f := f // capture range variable.
-		name := runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
-		i := strings.LastIndex(name, prefix)
-		if i >= 0 {
-			i += len(prefix)
-		} else {
-			i = 0
-		}
-		name = name[i:]
+		name := testFuncSubtestName(f, prefix)
 		t.Run(name, func(t *testing.T) {
 			runTestOverMetadataVers(t, f)
 		})
 	}
 }
 
+// testFuncSubtestName returns the name of the given test function,
+// with everything up to and including prefix stripped.
+func testFuncSubtestName(f interface{}, prefix string) string {
+	name := runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+	i := strings.LastIndex(name, prefix)
+	if i >= 0 {
+		i += len(prefix)
+	} else {
+		i = 0
+	}
+	return name[i:]
+}
+
 // runBenchmarkOverMetadataVers runs the given benchmark function over
 // all metadata versions to test. Example use:
 //
@@ -163,6 +169,8 @@ func makeFakePrivateMetadataFuture(t *testing.T) privateMetadataFuture {
 				0,
 			},
 			0,
+			nil,
+			nil,
 			codec.UnknownFieldSetHandler{},
 			BlockChanges{},
 		},
@@ -0,0 +1,696 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libkbfs
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/keybase/client/go/protocol/keybase1"
+	"github.com/keybase/kbfs/kbfsblock"
+	"github.com/keybase/kbfs/kbfscrypto"
+	"github.com/keybase/kbfs/kbfsmd"
+	"github.com/keybase/kbfs/tlf"
+	"golang.org/x/net/context"
+)
+
+// FaultableServer names a server (or cache) whose operations a
+// FaultInjector can make fail.
+type FaultableServer string
+
+// Servers whose operations can fail.
+const (
+	FaultableBlockServer    FaultableServer = "BlockServer"
+	FaultableMDServer       FaultableServer = "MDServer"
+	FaultableKeyServer      FaultableServer = "KeyServer"
+	FaultableDiskBlockCache FaultableServer = "DiskBlockCache"
+)
+
+// FaultableOp names an operation that a FaultInjector can make
+// fail.  It is prefixed by the FaultableServer it belongs to.
+type FaultableOp string
+
+// Operations that can fail.  FaultableBlockPut covers both Put and
+// PutAgain.
+const (
+	FaultableBlockGet               FaultableOp = "BlockServer.Get"
+	FaultableBlockPut               FaultableOp = "BlockServer.Put"
+	FaultableBlockAddReference      FaultableOp = "BlockServer.AddBlockReference"
+	FaultableBlockRemoveReferences  FaultableOp = "BlockServer.RemoveBlockReferences"
+	FaultableBlockArchiveReferences FaultableOp = "BlockServer.ArchiveBlockReferences"
+
+	FaultableMDGetForHandle      FaultableOp = "MDServer.GetForHandle"
+	FaultableMDGetForTLF         FaultableOp = "MDServer.GetForTLF"
+	FaultableMDGetRange          FaultableOp = "MDServer.GetRange"
+	FaultableMDPut               FaultableOp = "MDServer.Put"
+	FaultableMDPruneBranch       FaultableOp = "MDServer.PruneBranch"
+	FaultableMDRegisterForUpdate FaultableOp = "MDServer.RegisterForUpdate"
+	FaultableMDGetKeyBundles     FaultableOp = "MDServer.GetKeyBundles"
+
+	FaultableKeyGetServerHalf    FaultableOp = "KeyServer.GetTLFCryptKeyServerHalf"
+	FaultableKeyPutServerHalves  FaultableOp = "KeyServer.PutTLFCryptKeyServerHalves"
+	FaultableKeyDeleteServerHalf FaultableOp = "KeyServer.DeleteTLFCryptKeyServerHalf"
+
+	FaultableDiskCacheGet FaultableOp = "DiskBlockCache.Get"
+	FaultableDiskCachePut FaultableOp = "DiskBlockCache.Put"
+)
+
+// Server returns the server that op belongs to.
+func (op FaultableOp) Server() FaultableServer {
+	return FaultableServer(strings.SplitN(string(op), ".", 2)[0])
+}
+
+// FaultKind is the kind of fault injected into an operation.
+type FaultKind int
+
+const (
+	// FaultError fails the operation without running it.
+	FaultError FaultKind = iota + 1
+	// FaultLatency delays the operation by FaultRule.Latency, or
+	// until its context is canceled, and then runs it.
+	FaultLatency
+	// FaultPartial runs the operation, and then fails it anyway,
+	// as if the reply from the server was lost.
+	FaultPartial
+	// FaultDisconnect fails the operation without running it, and
+	// disconnects its server, so that all of the server's
+	// operations fail until FaultInjector.Reconnect is called.
+	FaultDisconnect
+)
+
+func (k FaultKind) String() string {
+	switch k {
+	case FaultError:
+		return "error"
+	case FaultLatency:
+		return "latency"
+	case FaultPartial:
+		return "partial failure"
+	case FaultDisconnect:
+		return "disconnect"
+	default:
+		return fmt.Sprintf("FaultKind(%d)", int(k))
+	}
+}
+
+// FaultRule describes when to inject a fault into an operation.
+type FaultRule struct {
+	Op   FaultableOp
+	Kind FaultKind
+	// Skip is the number of calls to Op to let through before the
+	// rule applies.
+	Skip int
+	// Times is the maximum number of faults the rule injects, or 0
+	// for no limit.
+	Times int
+	// Probability is the chance that the rule injects a fault into
+	// each call it applies to, drawn from the seeded schedule.  If
+	// it's zero, every call gets a fault.
+	Probability float64
+	// Latency is how long FaultLatency delays the operation.
+	Latency time.Duration
+}
+
+func (r FaultRule) String() string {
+	s := fmt.Sprintf("%s on %s", r.Kind, r.Op)
+	if r.Skip > 0 {
+		s += fmt.Sprintf(" after %d calls", r.Skip)
+	}
+	if r.Times > 0 {
+		s += fmt.Sprintf(" %d times", r.Times)
+	}
+	if r.Probability > 0 {
+		s += fmt.Sprintf(" with probability %g", r.Probability)
+	}
+	if r.Kind == FaultLatency {
+		s += fmt.Sprintf(" for %s", r.Latency)
+	}
+	return s
+}
+
+// FaultInjectedError is returned by operations that failed because
+// of a FaultInjector.
+type FaultInjectedError struct {
+	Op   FaultableOp
+	Kind FaultKind
+}
+
+// Error implements the error interface for FaultInjectedError.
+func (e FaultInjectedError) Error() string {
+	return fmt.Sprintf("Injected fault (%s) on %s", e.Kind, e.Op)
+}
+
+type faultRuleState struct {
+	FaultRule
+	calls    int
+	injected int
+}
+
+// FaultInjector injects faults into the operations of the
+// BlockServer, MDServer, KeyServer and DiskBlockCache of a config,
+// following a schedule of FaultRules.  Whether a rule with a
+// Probability fires is drawn from a random source seeded by the
+// schedule's seed and the name of the operation, so each operation
+// sees the same faults in the same order whenever the schedule is
+// replayed, regardless of how calls to different operations
+// interleave.
+//
+// If journaling is enabled for the config, the BlockServer underneath
+// the journal server is wrapped as soon as the FaultInjector is made,
+// so that every TLF journal enabled after that flushes its blocks
+// through the wrapper.  It passes everything through while there's no
+// schedule.  The other servers of the config are only wrapped once
+// InjectFaults or Disconnect is first called, and stay wrapped until
+// UndoInjectFaults.
+type FaultInjector struct {
+	config Config
+
+	mu             sync.Mutex
+	installed      bool
+	seed           int64
+	rules          []*faultRuleState
+	rands          map[FaultableOp]*rand.Rand
+	disconnected   map[FaultableServer]bool
+	injected       map[FaultableOp]int
+	wrappedJServer bool
+	oldBServer     BlockServer
+	oldMDServer    MDServer
+	oldKeyServer   KeyServer
+	oldDiskCache   DiskBlockCache
+	wrappedDCache  bool
+}
+
+// NewFaultInjector returns a new FaultInjector for config.  If config
+// uses journaling, it should be called before any TLF journals are
+// enabled, so that they all see the block server faults.
+func NewFaultInjector(config Config) *FaultInjector {
+	fi := &FaultInjector{
+		config:       config,
+		rands:        make(map[FaultableOp]*rand.Rand),
+		disconnected: make(map[FaultableServer]bool),
+		injected:     make(map[FaultableOp]int),
+	}
+	fi.wrapJournalBlockServer()
+	return fi
+}
+
+// wrapJournalBlockServer wraps the block server underneath the journal
+// server of fi.config, if there is one, for good.
+func (fi *FaultInjector) wrapJournalBlockServer() {
+	jServer, err := GetJournalServer(fi.config)
+	if err != nil {
+		return
+	}
+	// Take the journal server lock, since TLF journals pick up the
+	// delegate block server when they're enabled.
+	jServer.lock.Lock()
+	defer jServer.lock.Unlock()
+	jServer.delegateBlockServer = &faultyBlockServer{
+		BlockServer: jServer.delegateBlockServer,
+		fi:          fi,
+	}
+	// The config's journalBlockServer keeps its own copy of the
+	// delegate for TLFs without a journal, so refresh it too.
+	fi.config.SetBlockServer(jServer.blockServer())
+	fi.wrappedJServer = true
+}
+
+func setDiskBlockCacheForTest(config Config, dbc DiskBlockCache) {
+	c, ok := config.(*ConfigLocal)
+	if !ok {
+		panic(fmt.Sprintf("Can't set the disk block cache of a %T", config))
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.diskBlockCache = dbc
+}
+
+func (fi *FaultInjector) installLocked() {
+	if fi.installed {
+		return
+	}
+
+	// With journaling, the block server underneath the journal is
+	// already wrapped, and the config's block server has to stay a
+	// journalBlockServer for the journal's type checks to work.
+	if !fi.wrappedJServer {
+		fi.oldBServer = fi.config.BlockServer()
+		fi.config.SetBlockServer(&faultyBlockServer{
+			BlockServer: fi.oldBServer,
+			fi:          fi,
+		})
+	}
+
+	fi.oldMDServer = fi.config.MDServer()
+	fi.config.SetMDServer(&faultyMDServer{
+		MDServer: fi.oldMDServer,
+		fi:       fi,
+	})
+
+	fi.oldKeyServer = fi.config.KeyServer()
+	fi.config.SetKeyServer(&faultyKeyServer{
+		KeyServer: fi.oldKeyServer,
+		fi:        fi,
+	})
+
+	fi.oldDiskCache = fi.config.DiskBlockCache()
+	if fi.oldDiskCache != nil {
+		setDiskBlockCacheForTest(fi.config, &faultyDiskBlockCache{
+			DiskBlockCache: fi.oldDiskCache,
+			fi:             fi,
+		})
+		fi.wrappedDCache = true
+	}
+
+	fi.installed = true
+}
+
+// InjectFaults replaces the schedule of fi with the given rules,
+// using seed for the rules with a Probability.  The fault counts
+// returned by NumInjected start over.
+func (fi *FaultInjector) InjectFaults(seed int64, rules ...FaultRule) {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	fi.installLocked()
+	fi.seed = seed
+	fi.rules = make([]*faultRuleState, 0, len(rules))
+	for _, r := range rules {
+		if r.Op.Server() != FaultableBlockServer &&
+			r.Op.Server() != FaultableMDServer &&
+			r.Op.Server() != FaultableKeyServer &&
+			r.Op.Server() != FaultableDiskBlockCache {
+			panic(fmt.Sprintf("Unknown faultable op %s", r.Op))
+		}
+		fi.rules = append(fi.rules, &faultRuleState{FaultRule: r})
+	}
+	fi.rands = make(map[FaultableOp]*rand.Rand)
+	fi.injected = make(map[FaultableOp]int)
+}
+
+// Disconnect makes all the operations of server fail until Reconnect
+// is called.
+func (fi *FaultInjector) Disconnect(server FaultableServer) {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	fi.installLocked()
+	fi.disconnected[server] = true
+}
+
+// Reconnect undoes Disconnect, or a FaultDisconnect on one of the
+// operations of server.
+func (fi *FaultInjector) Reconnect(server FaultableServer) {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	delete(fi.disconnected, server)
+}
+
+// NumInjected returns the number of calls to op that have had a fault
+// injected into them (including the ones that failed because their
+// server was disconnected) since the schedule was last set.
+func (fi *FaultInjector) NumInjected(op FaultableOp) int {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	return fi.injected[op]
+}
+
+// UndoInjectFaults clears the schedule, reconnects all the servers,
+// and puts the original servers back into the config.  The block
+// server underneath the journal server stays wrapped, and, like any
+// other wrapped servers that are still held elsewhere, passes
+// everything through from now on.
+func (fi *FaultInjector) UndoInjectFaults() {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	fi.rules = nil
+	fi.disconnected = make(map[FaultableServer]bool)
+	if !fi.installed {
+		return
+	}
+
+	if fi.oldBServer != nil {
+		fi.config.SetBlockServer(fi.oldBServer)
+	}
+	fi.config.SetMDServer(fi.oldMDServer)
+	fi.config.SetKeyServer(fi.oldKeyServer)
+	if fi.wrappedDCache {
+		setDiskBlockCacheForTest(fi.config, fi.oldDiskCache)
+	}
+
+	fi.oldBServer = nil
+	fi.oldMDServer = nil
+	fi.oldKeyServer = nil
+	fi.oldDiskCache = nil
+	fi.wrappedDCache = false
+	fi.installed = false
+}
+
+func (fi *FaultInjector) randForOpLocked(op FaultableOp) *rand.Rand {
+	r, ok := fi.rands[op]
+	if !ok {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(op))
+		r = rand.New(rand.NewSource(fi.seed ^ int64(h.Sum64())))
+		fi.rands[op] = r
+	}
+	return r
+}
+
+// nextFault returns the rule to apply to the current call to op, or
+// nil if there isn't one.
+func (fi *FaultInjector) nextFault(op FaultableOp) *FaultRule {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	if fi.disconnected[op.Server()] {
+		fi.injected[op]++
+		return &FaultRule{Op: op, Kind: FaultDisconnect}
+	}
+
+	var fault *FaultRule
+	for _, r := range fi.rules {
+		if r.Op != op {
+			continue
+		}
+		r.calls++
+		if fault != nil || r.calls <= r.Skip ||
+			(r.Times > 0 && r.injected >= r.Times) {
+			continue
+		}
+		if r.Probability > 0 &&
+			fi.randForOpLocked(op).Float64() >= r.Probability {
+			continue
+		}
+		r.injected++
+		rule := r.FaultRule
+		fault = &rule
+	}
+	if fault == nil {
+		return nil
+	}
+
+	fi.injected[op]++
+	if fault.Kind == FaultDisconnect {
+		fi.disconnected[op.Server()] = true
+	}
+	return fault
+}
+
+func (fi *FaultInjector) isConnected(server FaultableServer) bool {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	return !fi.disconnected[server]
+}
+
+// run runs action as op, unless the schedule says otherwise.
+func (fi *FaultInjector) run(
+	ctx context.Context, op FaultableOp, action func() error) error {
+	fault := fi.nextFault(op)
+	if fault == nil {
+		return action()
+	}
+
+	switch fault.Kind {
+	case FaultLatency:
+		select {
+		case <-time.After(fault.Latency):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		return action()
+	case FaultPartial:
+		err := action()
+		if err != nil {
+			return err
+		}
+		return FaultInjectedError{op, fault.Kind}
+	default:
+		return FaultInjectedError{op, fault.Kind}
+	}
+}
+
+// faultyBlockServer is a BlockServer whose operations can fail
+// according to the schedule of a FaultInjector.
+type faultyBlockServer struct {
+	BlockServer
+	fi *FaultInjector
+}
+
+var _ blockServerLocal = (*faultyBlockServer)(nil)
+
+func (b *faultyBlockServer) Get(
+	ctx context.Context, tlfID tlf.ID, id kbfsblock.ID,
+	bctx kbfsblock.Context) (
+	[]byte, kbfscrypto.BlockCryptKeyServerHalf, error) {
+	var buf []byte
+	var serverHalf kbfscrypto.BlockCryptKeyServerHalf
+	err := b.fi.run(ctx, FaultableBlockGet, func() (err error) {
+		buf, serverHalf, err = b.BlockServer.Get(ctx, tlfID, id, bctx)
+		return err
+	})
+	if err != nil {
+		return nil, kbfscrypto.BlockCryptKeyServerHalf{}, err
+	}
+	return buf, serverHalf, nil
+}
+
+func (b *faultyBlockServer) Put(
+	ctx context.Context, tlfID tlf.ID, id kbfsblock.ID,
+	bctx kbfsblock.Context, buf []byte,
+	serverHalf kbfscrypto.BlockCryptKeyServerHalf) error {
+	return b.fi.run(ctx, FaultableBlockPut, func() error {
+		return b.BlockServer.Put(ctx, tlfID, id, bctx, buf, serverHalf)
+	})
+}
+
+func (b *faultyBlockServer) PutAgain(
+	ctx context.Context, tlfID tlf.ID, id kbfsblock.ID,
+	bctx kbfsblock.Context, buf []byte,
+	serverHalf kbfscrypto.BlockCryptKeyServerHalf) error {
+	return b.fi.run(ctx, FaultableBlockPut, func() error {
+		return b.BlockServer.PutAgain(ctx, tlfID, id, bctx, buf, serverHalf)
+	})
+}
+
+func (b *faultyBlockServer) AddBlockReference(
+	ctx context.Context, tlfID tlf.ID, id kbfsblock.ID,
+	bctx kbfsblock.Context) error {
+	return b.fi.run(ctx, FaultableBlockAddReference, func() error {
+		return b.BlockServer.AddBlockReference(ctx, tlfID, id, bctx)
+	})
+}
+
+func (b *faultyBlockServer) RemoveBlockReferences(
+	ctx context.Context, tlfID tlf.ID, contexts kbfsblock.ContextMap) (
+	map[kbfsblock.ID]int, error) {
+	var liveCounts map[kbfsblock.ID]int
+	err := b.fi.run(ctx, FaultableBlockRemoveReferences, func() (err error) {
+		liveCounts, err = b.BlockServer.RemoveBlockReferences(
+			ctx, tlfID, contexts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return liveCounts, nil
+}
+
+func (b *faultyBlockServer) ArchiveBlockReferences(
+	ctx context.Context, tlfID tlf.ID, contexts kbfsblock.ContextMap) error {
+	return b.fi.run(ctx, FaultableBlockArchiveReferences, func() error {
+		return b.BlockServer.ArchiveBlockReferences(ctx, tlfID, contexts)
+	})
+}
+
+// getAllRefsForTest implements the blockServerLocal interface for
+// faultyBlockServer, so that state checks can see through it.
+func (b *faultyBlockServer) getAllRefsForTest(
+	ctx context.Context, tlfID tlf.ID) (map[kbfsblock.ID]blockRefMap, error) {
+	bserverLocal, ok := b.BlockServer.(blockServerLocal)
+	if !ok {
+		return nil, fmt.Errorf("Block server %T isn't local", b.BlockServer)
+	}
+	return bserverLocal.getAllRefsForTest(ctx, tlfID)
+}
+
+// faultyMDServer is an MDServer whose operations can fail according
+// to the schedule of a FaultInjector.
+type faultyMDServer struct {
+	MDServer
+	fi *FaultInjector
+}
+
+var _ MDServer = (*faultyMDServer)(nil)
+
+func (md *faultyMDServer) GetForHandle(ctx context.Context,
+	handle tlf.Handle, mStatus kbfsmd.MergeStatus,
+	lockBeforeGet *keybase1.LockID) (tlf.ID, *RootMetadataSigned, error) {
+	var id tlf.ID
+	var rmds *RootMetadataSigned
+	err := md.fi.run(ctx, FaultableMDGetForHandle, func() (err error) {
+		id, rmds, err = md.MDServer.GetForHandle(
+			ctx, handle, mStatus, lockBeforeGet)
+		return err
+	})
+	if err != nil {
+		return tlf.NullID, nil, err
+	}
+	return id, rmds, nil
+}
+
+func (md *faultyMDServer) GetForTLF(ctx context.Context, id tlf.ID,
+	bid kbfsmd.BranchID, mStatus kbfsmd.MergeStatus,
+	lockBeforeGet *keybase1.LockID) (*RootMetadataSigned, error) {
+	var rmds *RootMetadataSigned
+	err := md.fi.run(ctx, FaultableMDGetForTLF, func() (err error) {
+		rmds, err = md.MDServer.GetForTLF(
+			ctx, id, bid, mStatus, lockBeforeGet)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rmds, nil
+}
+
+func (md *faultyMDServer) GetRange(ctx context.Context, id tlf.ID,
+	bid kbfsmd.BranchID, mStatus kbfsmd.MergeStatus,
+	start, stop kbfsmd.Revision, lockBeforeGet *keybase1.LockID) (
+	[]*RootMetadataSigned, error) {
+	var rmdses []*RootMetadataSigned
+	err := md.fi.run(ctx, FaultableMDGetRange, func() (err error) {
+		rmdses, err = md.MDServer.GetRange(
+			ctx, id, bid, mStatus, start, stop, lockBeforeGet)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rmdses, nil
+}
+
+func (md *faultyMDServer) Put(ctx context.Context, rmds *RootMetadataSigned,
+	extra kbfsmd.ExtraMetadata, lockContext *keybase1.LockContext,
+	priority keybase1.MDPriority) error {
+	return md.fi.run(ctx, FaultableMDPut, func() error {
+		return md.MDServer.Put(ctx, rmds, extra, lockContext, priority)
+	})
+}
+
+func (md *faultyMDServer) PruneBranch(
+	ctx context.Context, id tlf.ID, bid kbfsmd.BranchID) error {
+	return md.fi.run(ctx, FaultableMDPruneBranch, func() error {
+		return md.MDServer.PruneBranch(ctx, id, bid)
+	})
+}
+
+func (md *faultyMDServer) RegisterForUpdate(ctx context.Context, id tlf.ID,
+	currHead kbfsmd.Revision) (<-chan error, error) {
+	var c <-chan error
+	err := md.fi.run(ctx, FaultableMDRegisterForUpdate, func() (err error) {
+		c, err = md.MDServer.RegisterForUpdate(ctx, id, currHead)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (md *faultyMDServer) GetKeyBundles(ctx context.Context, tlfID tlf.ID,
+	wkbID kbfsmd.TLFWriterKeyBundleID, rkbID kbfsmd.TLFReaderKeyBundleID) (
+	*kbfsmd.TLFWriterKeyBundleV3, *kbfsmd.TLFReaderKeyBundleV3, error) {
+	var wkb *kbfsmd.TLFWriterKeyBundleV3
+	var rkb *kbfsmd.TLFReaderKeyBundleV3
+	err := md.fi.run(ctx, FaultableMDGetKeyBundles, func() (err error) {
+		wkb, rkb, err = md.MDServer.GetKeyBundles(ctx, tlfID, wkbID, rkbID)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return wkb, rkb, nil
+}
+
+func (md *faultyMDServer) IsConnected() bool {
+	return md.fi.isConnected(FaultableMDServer) && md.MDServer.IsConnected()
+}
+
+// faultyKeyServer is a KeyServer whose operations can fail according
+// to the schedule of a FaultInjector.
+type faultyKeyServer struct {
+	KeyServer
+	fi *FaultInjector
+}
+
+var _ KeyServer = (*faultyKeyServer)(nil)
+
+func (ks *faultyKeyServer) GetTLFCryptKeyServerHalf(ctx context.Context,
+	serverHalfID kbfscrypto.TLFCryptKeyServerHalfID,
+	cryptPublicKey kbfscrypto.CryptPublicKey) (
+	kbfscrypto.TLFCryptKeyServerHalf, error) {
+	var serverHalf kbfscrypto.TLFCryptKeyServerHalf
+	err := ks.fi.run(ctx, FaultableKeyGetServerHalf, func() (err error) {
+		serverHalf, err = ks.KeyServer.GetTLFCryptKeyServerHalf(
+			ctx, serverHalfID, cryptPublicKey)
+		return err
+	})
+	if err != nil {
+		return kbfscrypto.TLFCryptKeyServerHalf{}, err
+	}
+	return serverHalf, nil
+}
+
+func (ks *faultyKeyServer) PutTLFCryptKeyServerHalves(ctx context.Context,
+	keyServerHalves kbfsmd.UserDeviceKeyServerHalves) error {
+	return ks.fi.run(ctx, FaultableKeyPutServerHalves, func() error {
+		return ks.KeyServer.PutTLFCryptKeyServerHalves(ctx, keyServerHalves)
+	})
+}
+
+func (ks *faultyKeyServer) DeleteTLFCryptKeyServerHalf(ctx context.Context,
+	uid keybase1.UID, key kbfscrypto.CryptPublicKey,
+	serverHalfID kbfscrypto.TLFCryptKeyServerHalfID) error {
+	return ks.fi.run(ctx, FaultableKeyDeleteServerHalf, func() error {
+		return ks.KeyServer.DeleteTLFCryptKeyServerHalf(
+			ctx, uid, key, serverHalfID)
+	})
+}
+
+// faultyDiskBlockCache is a DiskBlockCache whose operations can fail
+// according to the schedule of a FaultInjector.
+type faultyDiskBlockCache struct {
+	DiskBlockCache
+	fi *FaultInjector
+}
+
+var _ DiskBlockCache = (*faultyDiskBlockCache)(nil)
+
+func (c *faultyDiskBlockCache) Get(
+	ctx context.Context, tlfID tlf.ID, blockID kbfsblock.ID) (
+	[]byte, kbfscrypto.BlockCryptKeyServerHalf, PrefetchStatus, error) {
+	var buf []byte
+	var serverHalf kbfscrypto.BlockCryptKeyServerHalf
+	var prefetchStatus PrefetchStatus
+	err := c.fi.run(ctx, FaultableDiskCacheGet, func() (err error) {
+		buf, serverHalf, prefetchStatus, err = c.DiskBlockCache.Get(
+			ctx, tlfID, blockID)
+		return err
+	})
+	if err != nil {
+		return nil, kbfscrypto.BlockCryptKeyServerHalf{}, NoPrefetch, err
+	}
+	return buf, serverHalf, prefetchStatus, nil
+}
+
+func (c *faultyDiskBlockCache) Put(
+	ctx context.Context, tlfID tlf.ID, blockID kbfsblock.ID, buf []byte,
+	serverHalf kbfscrypto.BlockCryptKeyServerHalf) error {
+	return c.fi.run(ctx, FaultableDiskCachePut, func() error {
+		return c.DiskBlockCache.Put(ctx, tlfID, blockID, buf, serverHalf)
+	})
+}
@@ -0,0 +1,169 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libkbfs
+
+import (
+	"testing"
+
+	"github.com/keybase/client/go/protocol/keybase1"
+	"github.com/keybase/kbfs/kbfsblock"
+	"github.com/keybase/kbfs/kbfscrypto"
+	"github.com/keybase/kbfs/tlf"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+func TestFaultInjectorSchedule(t *testing.T) {
+	config := MakeTestConfigOrBust(t, "alice")
+	defer CheckConfigAndShutdown(context.Background(), t, config)
+	fi := NewFaultInjector(config)
+	defer fi.UndoInjectFaults()
+
+	faultSequence := func(seed int64, interleave bool) (gets, puts []bool) {
+		fi.InjectFaults(seed,
+			FaultRule{
+				Op:          FaultableBlockGet,
+				Kind:        FaultError,
+				Probability: 0.5,
+			},
+			FaultRule{
+				Op:          FaultableBlockPut,
+				Kind:        FaultError,
+				Probability: 0.5,
+			})
+		for i := 0; i < 50; i++ {
+			gets = append(gets, fi.nextFault(FaultableBlockGet) != nil)
+			if interleave {
+				puts = append(puts, fi.nextFault(FaultableBlockPut) != nil)
+			}
+		}
+		for i := 0; !interleave && i < 50; i++ {
+			puts = append(puts, fi.nextFault(FaultableBlockPut) != nil)
+		}
+		return gets, puts
+	}
+
+	t.Log("The same seed gives each op the same faults, however the " +
+		"calls to different ops interleave.")
+	gets1, puts1 := faultSequence(1, false)
+	gets2, puts2 := faultSequence(1, true)
+	require.Equal(t, gets1, gets2)
+	require.Equal(t, puts1, puts2)
+	require.Contains(t, gets1, true)
+	require.Contains(t, gets1, false)
+	gets3, _ := faultSequence(2, false)
+	require.NotEqual(t, gets1, gets3)
+
+	t.Log("Rules apply after Skip calls, at most Times times.")
+	fi.InjectFaults(1, FaultRule{
+		Op:    FaultableMDPut,
+		Kind:  FaultError,
+		Skip:  2,
+		Times: 3,
+	})
+	var faults []bool
+	for i := 0; i < 7; i++ {
+		faults = append(faults, fi.nextFault(FaultableMDPut) != nil)
+	}
+	require.Equal(t,
+		[]bool{false, false, true, true, true, false, false}, faults)
+	require.Equal(t, 3, fi.NumInjected(FaultableMDPut))
+	require.Equal(t, 0, fi.NumInjected(FaultableBlockGet))
+}
+
+func TestFaultInjectorServers(t *testing.T) {
+	ctx := context.Background()
+	config := MakeTestConfigOrBust(t, "alice")
+	defer CheckConfigAndShutdown(ctx, t, config)
+
+	cache, _ := initDiskBlockCacheTest(t)
+	defer shutdownDiskBlockCacheTest(cache)
+	setDiskBlockCacheForTest(config, cache)
+	defer setDiskBlockCacheForTest(config, nil)
+
+	fi := NewFaultInjector(config)
+	defer fi.UndoInjectFaults()
+	bserver := config.BlockServer()
+	mdserver := config.MDServer()
+
+	tlfID := tlf.FakeID(1, tlf.Private)
+	id := kbfsblock.FakeID(1)
+	fi.InjectFaults(1, FaultRule{
+		Op:    FaultableDiskCachePut,
+		Kind:  FaultError,
+		Times: 1,
+	})
+	err := config.DiskBlockCache().Put(
+		ctx, tlfID, id, []byte{1}, kbfscrypto.BlockCryptKeyServerHalf{})
+	require.Equal(t, FaultInjectedError{FaultableDiskCachePut, FaultError},
+		errors.Cause(err))
+
+	t.Log("A disconnect fails every op of its server until it's " +
+		"reconnected.")
+	fi.InjectFaults(1, FaultRule{
+		Op:   FaultableBlockAddReference,
+		Kind: FaultDisconnect,
+	})
+	bCtx := kbfsblock.MakeFirstContext(
+		keybase1.MakeTestUID(1).AsUserOrTeam(), keybase1.BlockType_DATA)
+	err = config.BlockServer().AddBlockReference(ctx, tlfID, id, bCtx)
+	require.Equal(t,
+		FaultInjectedError{FaultableBlockAddReference, FaultDisconnect}, err)
+	_, _, err = config.BlockServer().Get(ctx, tlfID, id, bCtx)
+	require.Equal(t,
+		FaultInjectedError{FaultableBlockGet, FaultDisconnect}, err)
+	fi.Reconnect(FaultableBlockServer)
+	_, _, err = config.BlockServer().Get(ctx, tlfID, id, bCtx)
+	require.IsType(t, kbfsblock.ServerErrorBlockNonExistent{}, err)
+
+	fi.Disconnect(FaultableMDServer)
+	require.False(t, config.MDServer().IsConnected())
+	fi.Reconnect(FaultableMDServer)
+	require.True(t, config.MDServer().IsConnected())
+
+	fi.UndoInjectFaults()
+	require.Equal(t, bserver, config.BlockServer())
+	require.Equal(t, mdserver, config.MDServer())
+	require.Equal(t, cache, config.DiskBlockCache())
+}
+
+func TestFaultInjectorJournalBlockServer(t *testing.T) {
+	tempdir, ctx, cancel, config, jServer := setupJournalBlockServerTest(t)
+	defer teardownJournalBlockServerTest(t, tempdir, ctx, cancel, config)
+
+	t.Log("A journal enabled before any faults are injected still " +
+		"flushes its blocks through the fault injector.")
+	fi := NewFaultInjector(config)
+	defer fi.UndoInjectFaults()
+	tlfID := tlf.FakeID(2, tlf.Private)
+	err := jServer.Enable(ctx, tlfID, nil, TLFJournalBackgroundWorkPaused)
+	require.NoError(t, err)
+
+	bCtx := kbfsblock.MakeFirstContext(
+		keybase1.MakeTestUID(1).AsUserOrTeam(), keybase1.BlockType_DATA)
+	data := []byte{1, 2, 3, 4}
+	bID, err := kbfsblock.MakePermanentID(data)
+	require.NoError(t, err)
+	serverHalf, err := kbfscrypto.MakeRandomBlockCryptKeyServerHalf()
+	require.NoError(t, err)
+	err = config.BlockServer().Put(ctx, tlfID, bID, bCtx, data, serverHalf)
+	require.NoError(t, err)
+
+	fi.InjectFaults(1, FaultRule{
+		Op:    FaultableBlockPut,
+		Kind:  FaultError,
+		Times: 1,
+	})
+	err = jServer.Flush(ctx, tlfID)
+	require.Equal(t, FaultInjectedError{FaultableBlockPut, FaultError},
+		errors.Cause(err))
+	require.Equal(t, 1, fi.NumInjected(FaultableBlockPut))
+
+	err = jServer.Flush(ctx, tlfID)
+	require.NoError(t, err)
+	_, _, err = jServer.delegateBlockServer.Get(ctx, tlfID, bID, bCtx)
+	require.NoError(t, err)
+}
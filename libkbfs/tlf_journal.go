@@ -121,7 +121,15 @@ type TLFJournalStatus struct {
 	UnflushedPaths  []string
 	QuotaUsedBytes  int64
 	QuotaLimitBytes int64
-	LastFlushErr    string `json:",omitempty"`
+	// SquashCount is the number of times since startup that the
+	// journal has collapsed its unflushed MDs into a single local
+	// squash before flushing them, and SquashedRevisions is the
+	// total number of MD revisions that went into those squashes.
+	// Both are only kept in memory, so they start over from zero
+	// whenever the journal is restarted.
+	SquashCount       uint64
+	SquashedRevisions uint64
+	LastFlushErr      string `json:",omitempty"`
 }
 
 // TLFJournalBackgroundWorkStatus indicates whether a journal should
@@ -239,7 +247,9 @@ type tlfJournal struct {
 	deferLog            traceLogger
 	onBranchChange      branchChangeListener
 	onMDFlush           mdFlushListener
+	flushScheduler      *journalFlushScheduler
 	forcedSquashByBytes uint64
+	forcedSquashByRevs  uint64
 
 	// Invariant: this tlfJournal acquires exactly
 	// blockJournal.getStoredBytes() and
@@ -292,6 +302,11 @@ type tlfJournal struct {
 	// squash.
 	unsquashedBytes uint64
 	flushingBlocks  map[kbfsblock.ID]bool
+	// Counts of the squashes done by
+	// convertMDsToBranchIfOverThreshold, for TLFJournalStatus.
+	// These aren't persisted.
+	squashCount       uint64
+	squashedRevisions uint64
 
 	bwDelegate tlfJournalBWDelegate
 }
@@ -309,11 +324,23 @@ type tlfJournalInfo struct {
 	ChargedTo    keybase1.UserOrTeamID
 }
 
-func readTLFJournalInfoFile(dir string) (
+func readTLFJournalInfoFile(crypter *journalCrypter, dir string) (
 	keybase1.UID, kbfscrypto.VerifyingKey, tlf.ID,
 	keybase1.UserOrTeamID, error) {
+	if crypter != nil {
+		encrypted, err := isTLFJournalEncrypted(crypter, dir)
+		if err != nil {
+			return keybase1.UID(""), kbfscrypto.VerifyingKey{}, tlf.ID{},
+				keybase1.UserOrTeamID(""), err
+		}
+		// The info file of a journal that hasn't been migrated
+		// to encrypted metadata yet is still in plaintext.
+		c := *crypter
+		c.allowPlaintext = !encrypted
+		crypter = &c
+	}
 	var info tlfJournalInfo
-	err := ioutil.DeserializeFromJSONFile(
+	err := crypter.deserializeFromJSONFile(
 		getTLFJournalInfoFilePath(dir), &info)
 	if err != nil {
 		return keybase1.UID(""), kbfscrypto.VerifyingKey{}, tlf.ID{},
@@ -328,20 +355,93 @@ func readTLFJournalInfoFile(dir string) (
 	return info.UID, info.VerifyingKey, info.TlfID, chargedTo, nil
 }
 
-func writeTLFJournalInfoFile(dir string, uid keybase1.UID,
-	key kbfscrypto.VerifyingKey, tlfID tlf.ID,
+func writeTLFJournalInfoFile(crypter *journalCrypter, dir string,
+	uid keybase1.UID, key kbfscrypto.VerifyingKey, tlfID tlf.ID,
 	chargedTo keybase1.UserOrTeamID) error {
 	info := tlfJournalInfo{uid, key, tlfID, chargedTo}
-	return ioutil.SerializeToJSONFile(info, getTLFJournalInfoFilePath(dir))
+	return crypter.serializeToJSONFile(info, getTLFJournalInfoFilePath(dir))
+}
+
+func getTLFJournalEncryptedMarkerPath(dir string) string {
+	return filepath.Join(dir, "encrypted.json")
+}
+
+// tlfJournalEncryptedMarker is the structure stored, always
+// encrypted, in getTLFJournalEncryptedMarkerPath(dir) once all the
+// metadata files of the journal in dir are encrypted.
+type tlfJournalEncryptedMarker struct {
+	TlfID tlf.ID
+}
+
+// isTLFJournalEncrypted returns whether the journal in the given
+// directory has recorded that all its metadata files are encrypted.
+// From then on, plaintext metadata files are never accepted again,
+// not even the info file, so that the journal can't be put back into
+// migration by someone planting a plaintext info file.
+func isTLFJournalEncrypted(crypter *journalCrypter, dir string) (
+	bool, error) {
+	if crypter == nil {
+		return false, nil
+	}
+	// The marker itself is never accepted in plaintext.
+	c := *crypter
+	c.allowPlaintext = false
+	var marker tlfJournalEncryptedMarker
+	err := c.deserializeFromJSONFile(
+		getTLFJournalEncryptedMarkerPath(dir), &marker)
+	if ioutil.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// writeTLFJournalEncryptedMarker records that all the metadata files
+// of the journal in the given directory are encrypted.  It must only
+// be called once that's true.
+func writeTLFJournalEncryptedMarker(
+	crypter *journalCrypter, dir string, tlfID tlf.ID) error {
+	return crypter.serializeToJSONFile(tlfJournalEncryptedMarker{tlfID},
+		getTLFJournalEncryptedMarkerPath(dir))
+}
+
+// tlfJournalNeedsEncryption returns whether the journal in the given
+// directory was written before its metadata files were encrypted,
+// i.e. whether its info file is still in plaintext and it hasn't
+// recorded that it's encrypted.  The info file is always rewritten
+// last, so it stays in plaintext until all the other metadata files
+// have been encrypted.
+func tlfJournalNeedsEncryption(
+	crypter *journalCrypter, dir string) (bool, error) {
+	if crypter == nil {
+		return false, nil
+	}
+	encrypted, err := isTLFJournalEncrypted(crypter, dir)
+	if err != nil {
+		return false, err
+	}
+	if encrypted {
+		return false, nil
+	}
+	buf, err := ioutil.ReadFile(getTLFJournalInfoFilePath(dir))
+	if ioutil.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return !isEncryptedJournalFile(buf), nil
 }
 
 func makeTLFJournal(
 	ctx context.Context, uid keybase1.UID, key kbfscrypto.VerifyingKey,
-	dir string, tlfID tlf.ID, chargedTo keybase1.UserOrTeamID,
+	cryptKey kbfscrypto.JournalCryptKey, dir string, tlfID tlf.ID,
+	chargedTo keybase1.UserOrTeamID,
 	config tlfJournalConfig, delegateBlockServer BlockServer,
 	bws TLFJournalBackgroundWorkStatus, bwDelegate tlfJournalBWDelegate,
 	onBranchChange branchChangeListener, onMDFlush mdFlushListener,
-	diskLimiter DiskLimiter) (*tlfJournal, error) {
+	flushScheduler *journalFlushScheduler, diskLimiter DiskLimiter) (
+	*tlfJournal, error) {
 	if uid == keybase1.UID("") {
 		return nil, errors.New("Empty user")
 	}
@@ -358,12 +458,24 @@ func makeTLFJournal(
 		return nil, errors.New("User ID required for non-team TLF")
 	}
 
+	// If cryptKey is empty, crypter is nil and the metadata files
+	// are left in plaintext.
+	crypter := newJournalCrypter(config.Codec(), cryptKey)
+	needsEncryption, err := tlfJournalNeedsEncryption(crypter, dir)
+	if err != nil {
+		return nil, err
+	}
+	if needsEncryption {
+		crypter.allowPlaintext = true
+	}
+
 	readUID, readKey, readTlfID, readChargedTo, err :=
-		readTLFJournalInfoFile(dir)
+		readTLFJournalInfoFile(crypter, dir)
 	switch {
 	case ioutil.IsNotExist(err):
 		// Info file doesn't exist, so write it.
-		err := writeTLFJournalInfoFile(dir, uid, key, tlfID, chargedTo)
+		err := writeTLFJournalInfoFile(
+			crypter, dir, uid, key, tlfID, chargedTo)
 		if err != nil {
 			return nil, err
 		}
@@ -398,19 +510,55 @@ func makeTLFJournal(
 
 	log := config.MakeLogger("TLFJ")
 
-	blockJournal, err := makeBlockJournal(ctx, config.Codec(), dir, log)
+	blockJournal, err := makeBlockJournal(
+		ctx, config.Codec(), crypter, dir, log)
 	if err != nil {
 		return nil, err
 	}
 
 	mdJournal, err := makeMDJournal(
-		ctx, uid, key, config.Codec(), config.Crypto(), config.Clock(),
-		config.teamMembershipChecker(), tlfID, config.MetadataVersion(), dir,
-		log)
+		ctx, uid, key, config.Codec(), crypter, config.Crypto(),
+		config.Clock(), config.teamMembershipChecker(), tlfID,
+		config.MetadataVersion(), dir, log)
 	if err != nil {
 		return nil, err
 	}
 
+	if needsEncryption {
+		log.CDebugf(ctx, "Encrypting the metadata files of the journal")
+		err = blockJournal.migrateToEncrypted()
+		if err != nil {
+			return nil, err
+		}
+		err = mdJournal.migrateToEncrypted()
+		if err != nil {
+			return nil, err
+		}
+		err = writeTLFJournalInfoFile(
+			crypter, dir, uid, key, tlfID, chargedTo)
+		if err != nil {
+			return nil, err
+		}
+		crypter.allowPlaintext = false
+	}
+
+	if crypter != nil {
+		// Everything is encrypted by now, either because the
+		// journal is new, or because it was just migrated (or
+		// was migrated before, and then shut down before the
+		// marker could be written).
+		encrypted, err := isTLFJournalEncrypted(crypter, dir)
+		if err != nil {
+			return nil, err
+		}
+		if !encrypted {
+			err = writeTLFJournalEncryptedMarker(crypter, dir, tlfID)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	// TODO(KBFS-2217): if this is a team TLF, transform the given
 	// disk limiter into one that checks the team's quota, not the
 	// user's.
@@ -427,7 +575,9 @@ func makeTLFJournal(
 		deferLog:             traceLogger{log.CloneWithAddedDepth(1)},
 		onBranchChange:       onBranchChange,
 		onMDFlush:            onMDFlush,
+		flushScheduler:       flushScheduler,
 		forcedSquashByBytes:  ForcedBranchSquashBytesThresholdDefault,
+		forcedSquashByRevs:   ForcedBranchSquashRevThreshold,
 		diskLimiter:          diskLimiter,
 		hasWorkCh:            make(chan struct{}, 1),
 		needPauseCh:          make(chan struct{}, 1),
@@ -807,6 +957,12 @@ func (j *tlfJournal) flush(ctx context.Context) (err error) {
 	j.flushLock.Lock()
 	defer j.flushLock.Unlock()
 
+	// Let other journals have our turns once we're done.
+	empty := false
+	defer func() {
+		j.flushScheduler.finished(j.tlfID, empty)
+	}()
+
 	flushedBlockEntries := 0
 	flushedMDEntries := 0
 	defer func() {
@@ -871,6 +1027,7 @@ func (j *tlfJournal) flush(ctx context.Context) (err error) {
 			(mdEnd == kbfsmd.RevisionUninitialized ||
 				j.singleOpMode == singleOpRunning) {
 			j.log.CDebugf(ctx, "Nothing else to flush")
+			empty = true
 			if j.singleOpMode == singleOpFinished {
 				j.log.CDebugf(ctx, "Resetting single op mode")
 				j.singleOpMode = singleOpRunning
@@ -1065,6 +1222,17 @@ func (j *tlfJournal) flushBlockEntries(
 		return 0, maxMDRevToFlush, false, nil
 	}
 
+	// Wait for this journal's turn to use the flush bandwidth,
+	// since other journals might need it more.
+	err = j.flushScheduler.acquire(ctx, j.tlfID)
+	if err != nil {
+		return 0, kbfsmd.RevisionUninitialized, false, err
+	}
+	var flushedBytes int64
+	defer func() {
+		j.flushScheduler.release(j.tlfID, flushedBytes)
+	}()
+
 	j.log.CDebugf(ctx, "Flushing %d blocks, up to rev %d",
 		len(entries.puts.blockStates), maxMDRevToFlush)
 
@@ -1103,7 +1271,8 @@ func (j *tlfJournal) flushBlockEntries(
 	// end, and we need to make sure `maxMDRevToFlush` is still valid.
 	eg.Go(func() error {
 		defer convertCancel()
-		return flushBlockEntries(groupCtx, j.log, j.deferLog,
+		flushCtx := withBandwidthClass(groupCtx, BandwidthClassJournalFlush)
+		return flushBlockEntries(flushCtx, j.log, j.deferLog,
 			j.delegateBlockServer, j.config.BlockCache(), j.config.Reporter(),
 			j.tlfID, tlfName, entries)
 	})
@@ -1137,6 +1306,7 @@ func (j *tlfJournal) flushBlockEntries(
 	if err != nil {
 		return 0, kbfsmd.RevisionUninitialized, false, err
 	}
+	flushedBytes = entries.putBytes()
 
 	err = j.clearFlushingBlockIDs(entries)
 	cleared = true
@@ -1247,7 +1417,7 @@ func (j *tlfJournal) convertMDsToBranchIfOverThreshold(ctx context.Context,
 		j.unsquashedBytes = 0
 	} else if j.config.BGFlushDirOpBatchSize() == 1 {
 		squashByRev, err =
-			j.mdJournal.atLeastNNonLocalSquashes(ForcedBranchSquashRevThreshold)
+			j.mdJournal.atLeastNNonLocalSquashes(j.forcedSquashByRevs)
 		if err != nil {
 			return false, err
 		}
@@ -1255,6 +1425,21 @@ func (j *tlfJournal) convertMDsToBranchIfOverThreshold(ctx context.Context,
 		// Squashing is already done in folderBranchOps, so just mark
 		// this revision as squashed, so simply turn it off here.
 		j.unsquashedBytes = 0
+
+		// Each batch still gets its own MD though, so a journal
+		// that's been unable to flush for a while can build up lots
+		// of revisions that rewrite the same files.  Only in that
+		// case, collapse them into a single MD before flushing, so
+		// CR can merge the syncOps, drop create/rm pairs, and unref
+		// the intermediate blocks.  A journal that's flushing fine
+		// keeps its revisions as they are.
+		if j.lastFlushErr != nil || !j.config.MDServer().IsConnected() {
+			squashByRev, err =
+				j.mdJournal.atLeastNNonLocalSquashes(j.forcedSquashByRevs)
+			if err != nil {
+				return false, err
+			}
+		}
 	}
 
 	// Note that j.unsquashedBytes is just an estimate -- it doesn't
@@ -1298,10 +1483,17 @@ func (j *tlfJournal) convertMDsToBranchIfOverThreshold(ctx context.Context,
 		}
 	}
 
+	numRevs, err := j.mdJournal.numNonLocalSquashes()
+	if err != nil {
+		return false, err
+	}
+
 	err = j.convertMDsToBranchLocked(ctx, kbfsmd.PendingLocalSquashBranchID, doSignal)
 	if err != nil {
 		return false, err
 	}
+	j.squashCount++
+	j.squashedRevisions += numRevs
 	return true, nil
 }
 
@@ -1570,17 +1762,19 @@ func (j *tlfJournal) getJournalStatusLocked() (TLFJournalStatus, error) {
 	unflushedBytes := j.blockJournal.getUnflushedBytes()
 	quotaUsed, quotaLimit := j.diskLimiter.getQuotaInfo(j.chargedTo)
 	return TLFJournalStatus{
-		Dir:             j.dir,
-		BranchID:        j.mdJournal.getBranchID().String(),
-		RevisionStart:   earliestRevision,
-		RevisionEnd:     latestRevision,
-		BlockOpCount:    blockEntryCount,
-		StoredBytes:     storedBytes,
-		StoredFiles:     storedFiles,
-		QuotaUsedBytes:  quotaUsed,
-		QuotaLimitBytes: quotaLimit,
-		UnflushedBytes:  unflushedBytes,
-		LastFlushErr:    lastFlushErr,
+		Dir:               j.dir,
+		BranchID:          j.mdJournal.getBranchID().String(),
+		RevisionStart:     earliestRevision,
+		RevisionEnd:       latestRevision,
+		BlockOpCount:      blockEntryCount,
+		StoredBytes:       storedBytes,
+		StoredFiles:       storedFiles,
+		QuotaUsedBytes:    quotaUsed,
+		QuotaLimitBytes:   quotaLimit,
+		UnflushedBytes:    unflushedBytes,
+		SquashCount:       j.squashCount,
+		SquashedRevisions: j.squashedRevisions,
+		LastFlushErr:      lastFlushErr,
 	}, nil
 }
 
@@ -2429,3 +2623,153 @@ func (j *tlfJournal) finishSingleOp(ctx context.Context,
 
 	return j.waitForCompleteFlush(ctx)
 }
+
+// getArchive returns the unflushed MDs in the journal, along with the
+// block operations that go with each of them, so that they can be
+// replayed into the journal of another device of the same user.
+func (j *tlfJournal) getArchive(ctx context.Context) (
+	journalArchive, error) {
+	j.journalLock.RLock()
+	defer j.journalLock.RUnlock()
+	if err := j.checkEnabledLocked(); err != nil {
+		return journalArchive{}, err
+	}
+
+	if bid := j.mdJournal.getBranchID(); bid != kbfsmd.NullBranchID {
+		return journalArchive{}, errors.Errorf(
+			"Can't export the journal for %s while it's on branch %s",
+			j.tlfID, bid)
+	}
+
+	earliestRevision, err := j.mdJournal.readEarliestRevision()
+	if err != nil {
+		return journalArchive{}, err
+	}
+	latestRevision, err := j.mdJournal.readLatestRevision()
+	if err != nil {
+		return journalArchive{}, err
+	}
+	if earliestRevision == kbfsmd.RevisionUninitialized {
+		return journalArchive{}, errors.Errorf(
+			"No unflushed revisions to export for %s", j.tlfID)
+	}
+	ibrmds, err := j.mdJournal.getRange(
+		ctx, kbfsmd.NullBranchID, earliestRevision, latestRevision)
+	if err != nil {
+		return journalArchive{}, err
+	}
+
+	// The block operations for a revision are the ones between its
+	// MD revision marker and the previous one.
+	entries, err := j.blockJournal.getAllEntries()
+	if err != nil {
+		return journalArchive{}, err
+	}
+	opsByRev := make(map[kbfsmd.Revision][]journalArchiveBlockOp)
+	var ops []journalArchiveBlockOp
+	for _, entry := range entries {
+		if entry.Ignore {
+			continue
+		}
+		switch entry.Op {
+		case mdRevMarkerOp:
+			opsByRev[entry.Revision] = append(
+				opsByRev[entry.Revision], ops...)
+			ops = nil
+		case blockPutOp:
+			id, context, err := entry.getSingleContext()
+			if err != nil {
+				return journalArchive{}, err
+			}
+			data, serverHalf, err := j.blockJournal.getDataWithContext(
+				id, context)
+			if err != nil {
+				return journalArchive{}, err
+			}
+			ops = append(ops, journalArchiveBlockOp{
+				Op:         entry.Op,
+				Contexts:   entry.Contexts,
+				Data:       data,
+				ServerHalf: serverHalf,
+			})
+		case addRefOp, archiveRefsOp:
+			ops = append(ops, journalArchiveBlockOp{
+				Op:       entry.Op,
+				Contexts: entry.Contexts,
+			})
+		default:
+			return journalArchive{}, errors.Errorf(
+				"Can't export block op %s", entry.Op)
+		}
+	}
+	if len(ops) > 0 {
+		j.log.CDebugf(ctx, "Not exporting %d block ops that don't "+
+			"belong to any revision yet", len(ops))
+	}
+
+	archive := journalArchive{
+		Version: journalArchiveVersion,
+		UID:     j.uid,
+		TlfID:   j.tlfID,
+		MDs:     make([]journalArchiveMD, 0, len(ibrmds)),
+	}
+	for _, ibrmd := range ibrmds {
+		buf, err := j.config.Codec().Encode(ibrmd.RootMetadata)
+		if err != nil {
+			return journalArchive{}, err
+		}
+		amd := journalArchiveMD{
+			BlockOps: opsByRev[ibrmd.RevisionNumber()],
+			Version:  ibrmd.Version(),
+			MD:       buf,
+		}
+		if extra, ok := ibrmd.extra.(*kbfsmd.ExtraMetadataV3); ok {
+			if extra.IsWriterKeyBundleNew() ||
+				extra.IsReaderKeyBundleNew() {
+				return journalArchive{}, errors.Errorf(
+					"Can't export revision %d of %s, which has new "+
+						"key bundles", ibrmd.RevisionNumber(), j.tlfID)
+			}
+			wkb := extra.GetWriterKeyBundle()
+			rkb := extra.GetReaderKeyBundle()
+			amd.WriterKeyBundle = &wkb
+			amd.ReaderKeyBundle = &rkb
+		}
+		archive.MDs = append(archive.MDs, amd)
+	}
+	return archive, nil
+}
+
+// importMD re-signs the given MD, exported from the journal of
+// another device of the same user, as this device, and puts it in
+// the journal with the given previous root.
+func (j *tlfJournal) importMD(ctx context.Context,
+	brmd kbfsmd.MutableRootMetadata, extra kbfsmd.ExtraMetadata,
+	prevRoot kbfsmd.ID) (kbfsmd.ID, error) {
+	j.journalLock.Lock()
+	defer j.journalLock.Unlock()
+	if err := j.checkEnabledLocked(); err != nil {
+		return kbfsmd.ID{}, err
+	}
+
+	brmd.SetPrevRoot(prevRoot)
+	err := brmd.SignWriterMetadataInternally(
+		ctx, j.config.Codec(), j.config.Crypto())
+	if err != nil {
+		return kbfsmd.ID{}, err
+	}
+
+	mdID, err := j.mdJournal.putEncrypted(ctx, brmd, extra)
+	if err != nil {
+		return kbfsmd.ID{}, err
+	}
+
+	err = j.blockJournal.markMDRevision(ctx, brmd.RevisionNumber(), false)
+	if err != nil {
+		return kbfsmd.ID{}, err
+	}
+
+	j.log.CDebugf(ctx, "Imported rev=%d id=%s", brmd.RevisionNumber(), mdID)
+	j.signalWork()
+	return mdID, nil
+}
@@ -89,6 +89,8 @@ type testTLFJournalConfig struct {
 	nug          normalizedUsernameGetter
 	mdserver     MDServer
 	dlTimeout    time.Duration
+	// If zero, BGFlushDirOpBatchSize returns 1.
+	bgFlushDirOpBatchSize int
 }
 
 func (c testTLFJournalConfig) BlockSplitter() BlockSplitter {
@@ -157,7 +159,10 @@ func (c testTLFJournalConfig) diskLimitTimeout() time.Duration {
 }
 
 func (c testTLFJournalConfig) BGFlushDirOpBatchSize() int {
-	return 1
+	if c.bgFlushDirOpBatchSize == 0 {
+		return 1
+	}
+	return c.bgFlushDirOpBatchSize
 }
 
 func (c testTLFJournalConfig) makeBlock(data []byte) (
@@ -209,7 +214,8 @@ func (c testTLFJournalConfig) checkRange(rmdses []rmdsWithExtra,
 }
 
 func setupTLFJournalTest(
-	t *testing.T, ver kbfsmd.MetadataVer, bwStatus TLFJournalBackgroundWorkStatus) (
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey,
+	bwStatus TLFJournalBackgroundWorkStatus) (
 	tempdir string, config *testTLFJournalConfig, ctx context.Context,
 	cancel context.CancelFunc, tlfJournal *tlfJournal,
 	delegate testBWDelegate) {
@@ -239,7 +245,7 @@ func setupTLFJournalTest(
 		tlf.FakeID(1, tlf.Private), bsplitter, crypto,
 		nil, nil, NewMDCacheStandard(10), ver,
 		NewReporterSimple(newTestClockNow(), 10), uid, verifyingKey, ekg, nil,
-		mdserver, defaultDiskLimitMaxDelay + time.Second,
+		mdserver, defaultDiskLimitMaxDelay + time.Second, 0,
 	}
 
 	ctx, cancel = context.WithTimeout(
@@ -277,8 +283,8 @@ func setupTLFJournalTest(
 	diskLimitSemaphore := newSemaphoreDiskLimiter(
 		math.MaxInt64, math.MaxInt64, math.MaxInt64)
 	tlfJournal, err = makeTLFJournal(ctx, uid, verifyingKey,
-		tempdir, config.tlfID, uid.AsUserOrTeam(), config, delegateBlockServer,
-		bwStatus, delegate, nil, nil, diskLimitSemaphore)
+		cryptKey, tempdir, config.tlfID, uid.AsUserOrTeam(), config, delegateBlockServer,
+		bwStatus, delegate, nil, nil, nil, diskLimitSemaphore)
 	require.NoError(t, err)
 
 	switch bwStatus {
@@ -341,9 +347,10 @@ func putOneMD(ctx context.Context, config *testTLFJournalConfig,
 // The tests below primarily test the background work thread's
 // behavior.
 
-func testTLFJournalBasic(t *testing.T, ver kbfsmd.MetadataVer) {
+func testTLFJournalBasic(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
 	tempdir, config, ctx, cancel, tlfJournal, delegate :=
-		setupTLFJournalTest(t, ver, TLFJournalBackgroundWorkEnabled)
+		setupTLFJournalTest(t, ver, cryptKey, TLFJournalBackgroundWorkEnabled)
 	defer teardownTLFJournalTest(
 		tempdir, config, ctx, cancel, tlfJournal, delegate)
 
@@ -355,9 +362,10 @@ func testTLFJournalBasic(t *testing.T, ver kbfsmd.MetadataVer) {
 	delegate.requireNextState(ctx, bwIdle)
 }
 
-func testTLFJournalPauseResume(t *testing.T, ver kbfsmd.MetadataVer) {
+func testTLFJournalPauseResume(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
 	tempdir, config, ctx, cancel, tlfJournal, delegate :=
-		setupTLFJournalTest(t, ver, TLFJournalBackgroundWorkEnabled)
+		setupTLFJournalTest(t, ver, cryptKey, TLFJournalBackgroundWorkEnabled)
 	defer teardownTLFJournalTest(
 		tempdir, config, ctx, cancel, tlfJournal, delegate)
 
@@ -374,9 +382,10 @@ func testTLFJournalPauseResume(t *testing.T, ver kbfsmd.MetadataVer) {
 	delegate.requireNextState(ctx, bwIdle)
 }
 
-func testTLFJournalPauseShutdown(t *testing.T, ver kbfsmd.MetadataVer) {
+func testTLFJournalPauseShutdown(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
 	tempdir, config, ctx, cancel, tlfJournal, delegate :=
-		setupTLFJournalTest(t, ver, TLFJournalBackgroundWorkEnabled)
+		setupTLFJournalTest(t, ver, cryptKey, TLFJournalBackgroundWorkEnabled)
 	defer teardownTLFJournalTest(
 		tempdir, config, ctx, cancel, tlfJournal, delegate)
 
@@ -419,9 +428,10 @@ func putBlock(ctx context.Context,
 	require.NoError(t, err)
 }
 
-func testTLFJournalBlockOpBasic(t *testing.T, ver kbfsmd.MetadataVer) {
+func testTLFJournalBlockOpBasic(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
 	tempdir, config, ctx, cancel, tlfJournal, delegate :=
-		setupTLFJournalTest(t, ver, TLFJournalBackgroundWorkPaused)
+		setupTLFJournalTest(t, ver, cryptKey, TLFJournalBackgroundWorkPaused)
 	defer teardownTLFJournalTest(
 		tempdir, config, ctx, cancel, tlfJournal, delegate)
 
@@ -434,9 +444,10 @@ func testTLFJournalBlockOpBasic(t *testing.T, ver kbfsmd.MetadataVer) {
 	require.False(t, converted)
 }
 
-func testTLFJournalBlockOpBusyPause(t *testing.T, ver kbfsmd.MetadataVer) {
+func testTLFJournalBlockOpBusyPause(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
 	tempdir, config, ctx, cancel, tlfJournal, delegate :=
-		setupTLFJournalTest(t, ver, TLFJournalBackgroundWorkEnabled)
+		setupTLFJournalTest(t, ver, cryptKey, TLFJournalBackgroundWorkEnabled)
 	defer teardownTLFJournalTest(
 		tempdir, config, ctx, cancel, tlfJournal, delegate)
 
@@ -455,9 +466,10 @@ func testTLFJournalBlockOpBusyPause(t *testing.T, ver kbfsmd.MetadataVer) {
 	delegate.requireNextState(ctx, bwPaused)
 }
 
-func testTLFJournalBlockOpBusyShutdown(t *testing.T, ver kbfsmd.MetadataVer) {
+func testTLFJournalBlockOpBusyShutdown(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
 	tempdir, config, ctx, cancel, tlfJournal, delegate :=
-		setupTLFJournalTest(t, ver, TLFJournalBackgroundWorkEnabled)
+		setupTLFJournalTest(t, ver, cryptKey, TLFJournalBackgroundWorkEnabled)
 	defer teardownTLFJournalTest(
 		tempdir, config, ctx, cancel, tlfJournal, delegate)
 
@@ -473,9 +485,10 @@ func testTLFJournalBlockOpBusyShutdown(t *testing.T, ver kbfsmd.MetadataVer) {
 	// Should still be able to shut down while busy.
 }
 
-func testTLFJournalSecondBlockOpWhileBusy(t *testing.T, ver kbfsmd.MetadataVer) {
+func testTLFJournalSecondBlockOpWhileBusy(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
 	tempdir, config, ctx, cancel, tlfJournal, delegate :=
-		setupTLFJournalTest(t, ver, TLFJournalBackgroundWorkEnabled)
+		setupTLFJournalTest(t, ver, cryptKey, TLFJournalBackgroundWorkEnabled)
 	defer teardownTLFJournalTest(
 		tempdir, config, ctx, cancel, tlfJournal, delegate)
 
@@ -492,9 +505,10 @@ func testTLFJournalSecondBlockOpWhileBusy(t *testing.T, ver kbfsmd.MetadataVer)
 	putBlock(ctx, t, config, tlfJournal, []byte{1, 2, 3, 4, 5})
 }
 
-func testTLFJournalBlockOpDiskByteLimit(t *testing.T, ver kbfsmd.MetadataVer) {
+func testTLFJournalBlockOpDiskByteLimit(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
 	tempdir, config, ctx, cancel, tlfJournal, delegate :=
-		setupTLFJournalTest(t, ver, TLFJournalBackgroundWorkPaused)
+		setupTLFJournalTest(t, ver, cryptKey, TLFJournalBackgroundWorkPaused)
 	defer teardownTLFJournalTest(
 		tempdir, config, ctx, cancel, tlfJournal, delegate)
 
@@ -531,9 +545,10 @@ func testTLFJournalBlockOpDiskByteLimit(t *testing.T, ver kbfsmd.MetadataVer) {
 	}
 }
 
-func testTLFJournalBlockOpDiskFileLimit(t *testing.T, ver kbfsmd.MetadataVer) {
+func testTLFJournalBlockOpDiskFileLimit(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
 	tempdir, config, ctx, cancel, tlfJournal, delegate :=
-		setupTLFJournalTest(t, ver, TLFJournalBackgroundWorkPaused)
+		setupTLFJournalTest(t, ver, cryptKey, TLFJournalBackgroundWorkPaused)
 	defer teardownTLFJournalTest(
 		tempdir, config, ctx, cancel, tlfJournal, delegate)
 
@@ -571,9 +586,10 @@ func testTLFJournalBlockOpDiskFileLimit(t *testing.T, ver kbfsmd.MetadataVer) {
 	}
 }
 
-func testTLFJournalBlockOpDiskQuotaLimit(t *testing.T, ver kbfsmd.MetadataVer) {
+func testTLFJournalBlockOpDiskQuotaLimit(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
 	tempdir, config, ctx, cancel, tlfJournal, delegate :=
-		setupTLFJournalTest(t, ver, TLFJournalBackgroundWorkPaused)
+		setupTLFJournalTest(t, ver, cryptKey, TLFJournalBackgroundWorkPaused)
 	defer teardownTLFJournalTest(
 		tempdir, config, ctx, cancel, tlfJournal, delegate)
 
@@ -618,9 +634,10 @@ func testTLFJournalBlockOpDiskQuotaLimit(t *testing.T, ver kbfsmd.MetadataVer) {
 	require.Equal(t, int64(math.MaxInt64), quotaBytes)
 }
 
-func testTLFJournalBlockOpDiskQuotaLimitResolve(t *testing.T, ver kbfsmd.MetadataVer) {
+func testTLFJournalBlockOpDiskQuotaLimitResolve(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
 	tempdir, config, ctx, cancel, tlfJournal, delegate :=
-		setupTLFJournalTest(t, ver, TLFJournalBackgroundWorkPaused)
+		setupTLFJournalTest(t, ver, cryptKey, TLFJournalBackgroundWorkPaused)
 	defer teardownTLFJournalTest(
 		tempdir, config, ctx, cancel, tlfJournal, delegate)
 
@@ -679,9 +696,10 @@ func testTLFJournalBlockOpDiskQuotaLimitResolve(t *testing.T, ver kbfsmd.Metadat
 	require.Equal(t, int64(math.MaxInt64), quotaBytes)
 }
 
-func testTLFJournalBlockOpDiskLimitDuplicate(t *testing.T, ver kbfsmd.MetadataVer) {
+func testTLFJournalBlockOpDiskLimitDuplicate(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
 	tempdir, config, ctx, cancel, tlfJournal, delegate :=
-		setupTLFJournalTest(t, ver, TLFJournalBackgroundWorkPaused)
+		setupTLFJournalTest(t, ver, cryptKey, TLFJournalBackgroundWorkPaused)
 	defer teardownTLFJournalTest(
 		tempdir, config, ctx, cancel, tlfJournal, delegate)
 
@@ -705,9 +723,10 @@ func testTLFJournalBlockOpDiskLimitDuplicate(t *testing.T, ver kbfsmd.MetadataVe
 	require.NoError(t, err)
 }
 
-func testTLFJournalBlockOpDiskLimitCancel(t *testing.T, ver kbfsmd.MetadataVer) {
+func testTLFJournalBlockOpDiskLimitCancel(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
 	tempdir, config, ctx, cancel, tlfJournal, delegate :=
-		setupTLFJournalTest(t, ver, TLFJournalBackgroundWorkPaused)
+		setupTLFJournalTest(t, ver, cryptKey, TLFJournalBackgroundWorkPaused)
 	defer teardownTLFJournalTest(
 		tempdir, config, ctx, cancel, tlfJournal, delegate)
 
@@ -723,9 +742,10 @@ func testTLFJournalBlockOpDiskLimitCancel(t *testing.T, ver kbfsmd.MetadataVer)
 	require.Equal(t, context.Canceled, errors.Cause(err))
 }
 
-func testTLFJournalBlockOpDiskLimitTimeout(t *testing.T, ver kbfsmd.MetadataVer) {
+func testTLFJournalBlockOpDiskLimitTimeout(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
 	tempdir, config, ctx, cancel, tlfJournal, delegate :=
-		setupTLFJournalTest(t, ver, TLFJournalBackgroundWorkPaused)
+		setupTLFJournalTest(t, ver, cryptKey, TLFJournalBackgroundWorkPaused)
 	defer teardownTLFJournalTest(
 		tempdir, config, ctx, cancel, tlfJournal, delegate)
 
@@ -746,9 +766,10 @@ func testTLFJournalBlockOpDiskLimitTimeout(t *testing.T, ver kbfsmd.MetadataVer)
 	}, *timeoutErr)
 }
 
-func testTLFJournalBlockOpDiskLimitPutFailure(t *testing.T, ver kbfsmd.MetadataVer) {
+func testTLFJournalBlockOpDiskLimitPutFailure(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
 	tempdir, config, ctx, cancel, tlfJournal, delegate :=
-		setupTLFJournalTest(t, ver, TLFJournalBackgroundWorkPaused)
+		setupTLFJournalTest(t, ver, cryptKey, TLFJournalBackgroundWorkPaused)
 	defer teardownTLFJournalTest(
 		tempdir, config, ctx, cancel, tlfJournal, delegate)
 
@@ -789,9 +810,10 @@ func (md hangingMDServer) waitForPut(ctx context.Context, t *testing.T) {
 	}
 }
 
-func testTLFJournalMDServerBusyPause(t *testing.T, ver kbfsmd.MetadataVer) {
+func testTLFJournalMDServerBusyPause(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
 	tempdir, config, ctx, cancel, tlfJournal, delegate :=
-		setupTLFJournalTest(t, ver, TLFJournalBackgroundWorkEnabled)
+		setupTLFJournalTest(t, ver, cryptKey, TLFJournalBackgroundWorkEnabled)
 	defer teardownTLFJournalTest(
 		tempdir, config, ctx, cancel, tlfJournal, delegate)
 
@@ -811,9 +833,10 @@ func testTLFJournalMDServerBusyPause(t *testing.T, ver kbfsmd.MetadataVer) {
 	delegate.requireNextState(ctx, bwPaused)
 }
 
-func testTLFJournalMDServerBusyShutdown(t *testing.T, ver kbfsmd.MetadataVer) {
+func testTLFJournalMDServerBusyShutdown(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
 	tempdir, config, ctx, cancel, tlfJournal, delegate :=
-		setupTLFJournalTest(t, ver, TLFJournalBackgroundWorkEnabled)
+		setupTLFJournalTest(t, ver, cryptKey, TLFJournalBackgroundWorkEnabled)
 	defer teardownTLFJournalTest(
 		tempdir, config, ctx, cancel, tlfJournal, delegate)
 
@@ -830,9 +853,10 @@ func testTLFJournalMDServerBusyShutdown(t *testing.T, ver kbfsmd.MetadataVer) {
 	// Should still be able to shutdown while busy.
 }
 
-func testTLFJournalBlockOpWhileBusy(t *testing.T, ver kbfsmd.MetadataVer) {
+func testTLFJournalBlockOpWhileBusy(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
 	tempdir, config, ctx, cancel, tlfJournal, delegate :=
-		setupTLFJournalTest(t, ver, TLFJournalBackgroundWorkEnabled)
+		setupTLFJournalTest(t, ver, cryptKey, TLFJournalBackgroundWorkEnabled)
 	defer teardownTLFJournalTest(
 		tempdir, config, ctx, cancel, tlfJournal, delegate)
 
@@ -861,6 +885,7 @@ type shimMDServer struct {
 	nextGetRange    []*RootMetadataSigned
 	nextErr         error
 	getForTLFCalled bool
+	disconnected    bool
 }
 
 func (s *shimMDServer) GetRange(
@@ -900,7 +925,7 @@ func (s *shimMDServer) GetForTLF(
 }
 
 func (s *shimMDServer) IsConnected() bool {
-	return true
+	return !s.disconnected
 }
 
 func (s *shimMDServer) Shutdown() {
@@ -916,9 +941,10 @@ func requireJournalEntryCounts(t *testing.T, j *tlfJournal,
 
 // The tests below test tlfJournal's MD flushing behavior.
 
-func testTLFJournalFlushMDBasic(t *testing.T, ver kbfsmd.MetadataVer) {
+func testTLFJournalFlushMDBasic(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
 	tempdir, config, ctx, cancel, tlfJournal, delegate :=
-		setupTLFJournalTest(t, ver, TLFJournalBackgroundWorkPaused)
+		setupTLFJournalTest(t, ver, cryptKey, TLFJournalBackgroundWorkPaused)
 	defer teardownTLFJournalTest(
 		tempdir, config, ctx, cancel, tlfJournal, delegate)
 
@@ -961,9 +987,10 @@ func testTLFJournalFlushMDBasic(t *testing.T, ver kbfsmd.MetadataVer) {
 		rmdses, firstRevision, firstPrevRoot, kbfsmd.Merged, kbfsmd.NullBranchID)
 }
 
-func testTLFJournalFlushMDConflict(t *testing.T, ver kbfsmd.MetadataVer) {
+func testTLFJournalFlushMDConflict(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
 	tempdir, config, ctx, cancel, tlfJournal, delegate :=
-		setupTLFJournalTest(t, ver, TLFJournalBackgroundWorkPaused)
+		setupTLFJournalTest(t, ver, cryptKey, TLFJournalBackgroundWorkPaused)
 	defer teardownTLFJournalTest(
 		tempdir, config, ctx, cancel, tlfJournal, delegate)
 
@@ -1092,9 +1119,10 @@ func testTLFJournalGCd(t *testing.T, tlfJournal *tlfJournal) {
 // orderings of blocks and MD ops when flushing, i.e. if a block op
 // was added to the block journal before an MD op was added to the MD
 // journal, then that block op will be flushed before that MD op.
-func testTLFJournalFlushOrdering(t *testing.T, ver kbfsmd.MetadataVer) {
+func testTLFJournalFlushOrdering(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
 	tempdir, config, ctx, cancel, tlfJournal, delegate :=
-		setupTLFJournalTest(t, ver, TLFJournalBackgroundWorkPaused)
+		setupTLFJournalTest(t, ver, cryptKey, TLFJournalBackgroundWorkPaused)
 	defer teardownTLFJournalTest(
 		tempdir, config, ctx, cancel, tlfJournal, delegate)
 
@@ -1180,9 +1208,9 @@ func testTLFJournalFlushOrdering(t *testing.T, ver kbfsmd.MetadataVer) {
 // branch is squashed multiple times, and then hits a conflict, the
 // blocks are flushed completely before the conflict-resolving MD.
 func testTLFJournalFlushOrderingAfterSquashAndCR(
-	t *testing.T, ver kbfsmd.MetadataVer) {
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
 	tempdir, config, ctx, cancel, tlfJournal, delegate :=
-		setupTLFJournalTest(t, ver, TLFJournalBackgroundWorkPaused)
+		setupTLFJournalTest(t, ver, cryptKey, TLFJournalBackgroundWorkPaused)
 	defer teardownTLFJournalTest(
 		tempdir, config, ctx, cancel, tlfJournal, delegate)
 	tlfJournal.forcedSquashByBytes = 20
@@ -1319,9 +1347,10 @@ func testTLFJournalFlushOrderingAfterSquashAndCR(
 // testTLFJournalFlushInterleaving tests that we interleave block and
 // MD ops while respecting the relative orderings of blocks and MD ops
 // when flushing.
-func testTLFJournalFlushInterleaving(t *testing.T, ver kbfsmd.MetadataVer) {
+func testTLFJournalFlushInterleaving(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
 	tempdir, config, ctx, cancel, tlfJournal, delegate :=
-		setupTLFJournalTest(t, ver, TLFJournalBackgroundWorkPaused)
+		setupTLFJournalTest(t, ver, cryptKey, TLFJournalBackgroundWorkPaused)
 	defer teardownTLFJournalTest(
 		tempdir, config, ctx, cancel, tlfJournal, delegate)
 
@@ -1497,9 +1526,10 @@ func testTLFJournalPauseBlocksAndConvertBranch(t *testing.T,
 
 // testTLFJournalConvertWhileFlushing tests that we can do branch
 // conversion while blocks are still flushing.
-func testTLFJournalConvertWhileFlushing(t *testing.T, ver kbfsmd.MetadataVer) {
+func testTLFJournalConvertWhileFlushing(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
 	tempdir, config, ctx, cancel, tlfJournal, delegate :=
-		setupTLFJournalTest(t, ver, TLFJournalBackgroundWorkPaused)
+		setupTLFJournalTest(t, ver, cryptKey, TLFJournalBackgroundWorkPaused)
 	defer teardownTLFJournalTest(
 		tempdir, config, ctx, cancel, tlfJournal, delegate)
 
@@ -1523,9 +1553,10 @@ func testTLFJournalConvertWhileFlushing(t *testing.T, ver kbfsmd.MetadataVer) {
 
 // testTLFJournalSquashWhileFlushing tests that we can do journal
 // coalescing while blocks are still flushing.
-func testTLFJournalSquashWhileFlushing(t *testing.T, ver kbfsmd.MetadataVer) {
+func testTLFJournalSquashWhileFlushing(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
 	tempdir, config, ctx, cancel, tlfJournal, delegate :=
-		setupTLFJournalTest(t, ver, TLFJournalBackgroundWorkPaused)
+		setupTLFJournalTest(t, ver, cryptKey, TLFJournalBackgroundWorkPaused)
 	defer teardownTLFJournalTest(
 		tempdir, config, ctx, cancel, tlfJournal, delegate)
 
@@ -1568,9 +1599,10 @@ func (t *testImmediateBackOff) Reset() {
 	close(t.resetCh)
 }
 
-func testTLFJournalFlushRetry(t *testing.T, ver kbfsmd.MetadataVer) {
+func testTLFJournalFlushRetry(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
 	tempdir, config, ctx, cancel, tlfJournal, delegate :=
-		setupTLFJournalTest(t, ver, TLFJournalBackgroundWorkPaused)
+		setupTLFJournalTest(t, ver, cryptKey, TLFJournalBackgroundWorkPaused)
 	defer teardownTLFJournalTest(
 		tempdir, config, ctx, cancel, tlfJournal, delegate)
 
@@ -1618,9 +1650,10 @@ func testTLFJournalFlushRetry(t *testing.T, ver kbfsmd.MetadataVer) {
 	testTLFJournalGCd(t, tlfJournal)
 }
 
-func testTLFJournalResolveBranch(t *testing.T, ver kbfsmd.MetadataVer) {
+func testTLFJournalResolveBranch(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
 	tempdir, config, ctx, cancel, tlfJournal, delegate :=
-		setupTLFJournalTest(t, ver, TLFJournalBackgroundWorkPaused)
+		setupTLFJournalTest(t, ver, cryptKey, TLFJournalBackgroundWorkPaused)
 	defer teardownTLFJournalTest(
 		tempdir, config, ctx, cancel, tlfJournal, delegate)
 
@@ -1692,9 +1725,10 @@ func testTLFJournalResolveBranch(t *testing.T, ver kbfsmd.MetadataVer) {
 	delegate.requireNextState(ctx, bwBusy)
 }
 
-func testTLFJournalSquashByBytes(t *testing.T, ver kbfsmd.MetadataVer) {
+func testTLFJournalSquashByBytes(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
 	tempdir, config, ctx, cancel, tlfJournal, delegate :=
-		setupTLFJournalTest(t, ver, TLFJournalBackgroundWorkPaused)
+		setupTLFJournalTest(t, ver, cryptKey, TLFJournalBackgroundWorkPaused)
 	defer teardownTLFJournalTest(
 		tempdir, config, ctx, cancel, tlfJournal, delegate)
 	tlfJournal.forcedSquashByBytes = 10
@@ -1725,10 +1759,114 @@ func testTLFJournalSquashByBytes(t *testing.T, ver kbfsmd.MetadataVer) {
 		t, kbfsmd.PendingLocalSquashBranchID, tlfJournal.mdJournal.getBranchID())
 }
 
+// testTLFJournalSquashByRevs tests that, when folderBranchOps
+// batches its directory operations, MDs are collapsed into a local
+// squash before flushing once enough of them build up, but only
+// while the journal is offline or unable to flush.
+func testTLFJournalSquashByRevs(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
+	tempdir, config, ctx, cancel, tlfJournal, delegate :=
+		setupTLFJournalTest(t, ver, cryptKey, TLFJournalBackgroundWorkPaused)
+	defer teardownTLFJournalTest(
+		tempdir, config, ctx, cancel, tlfJournal, delegate)
+	config.bgFlushDirOpBatchSize = 100
+	tlfJournal.forcedSquashByRevs = 3
+
+	var mdserver shimMDServer
+	config.mdserver = &mdserver
+
+	revision := kbfsmd.Revision(10)
+	prevRoot := kbfsmd.FakeID(1)
+	putMDs := func(n int) {
+		for i := 0; i < n; i++ {
+			md := config.makeMD(revision, prevRoot)
+			irmd, err := tlfJournal.putMD(ctx, md, tlfJournal.key)
+			require.NoError(t, err)
+			prevRoot = irmd.mdID
+			revision++
+		}
+	}
+
+	// While the journal flushes fine, the MDs are flushed as they
+	// are, even past the threshold.
+	putMDs(3)
+	err := tlfJournal.flush(ctx)
+	require.NoError(t, err)
+	require.Equal(t, kbfsmd.NullBranchID, tlfJournal.mdJournal.getBranchID())
+	requireJournalEntryCounts(t, tlfJournal, 0, 0)
+	require.Len(t, mdserver.rmdses, 3)
+	status, err := tlfJournal.getJournalStatus()
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), status.SquashCount)
+
+	// Once the last flush failed, they're converted into a local
+	// squash branch instead of being flushed.
+	putMDs(3)
+	tlfJournal.journalLock.Lock()
+	tlfJournal.lastFlushErr = errors.New("fake flush error")
+	tlfJournal.journalLock.Unlock()
+	err = tlfJournal.flush(ctx)
+	require.NoError(t, err)
+	require.Equal(
+		t, kbfsmd.PendingLocalSquashBranchID, tlfJournal.mdJournal.getBranchID())
+	requireJournalEntryCounts(t, tlfJournal, 3, 3)
+	require.Len(t, mdserver.rmdses, 3)
+	status, err = tlfJournal.getJournalStatus()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), status.SquashCount)
+	require.Equal(t, uint64(3), status.SquashedRevisions)
+}
+
+// testTLFJournalSquashByRevsOffline tests that, when folderBranchOps
+// batches its directory operations, MDs that build up while the MD
+// server is disconnected are collapsed into a local squash.
+func testTLFJournalSquashByRevsOffline(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
+	tempdir, config, ctx, cancel, tlfJournal, delegate :=
+		setupTLFJournalTest(t, ver, cryptKey, TLFJournalBackgroundWorkPaused)
+	defer teardownTLFJournalTest(
+		tempdir, config, ctx, cancel, tlfJournal, delegate)
+	config.bgFlushDirOpBatchSize = 100
+	tlfJournal.forcedSquashByRevs = 3
+
+	mdserver := shimMDServer{disconnected: true}
+	config.mdserver = &mdserver
+
+	revision := kbfsmd.Revision(10)
+	prevRoot := kbfsmd.FakeID(1)
+	putMDs := func(n int) {
+		for i := 0; i < n; i++ {
+			md := config.makeMD(revision, prevRoot)
+			irmd, err := tlfJournal.putMD(ctx, md, tlfJournal.key)
+			require.NoError(t, err)
+			prevRoot = irmd.mdID
+			revision++
+		}
+	}
+
+	// Below the threshold, the MDs are flushed as they are.
+	putMDs(2)
+	err := tlfJournal.flush(ctx)
+	require.NoError(t, err)
+	require.Equal(t, kbfsmd.NullBranchID, tlfJournal.mdJournal.getBranchID())
+	requireJournalEntryCounts(t, tlfJournal, 0, 0)
+	require.Len(t, mdserver.rmdses, 2)
+
+	// At the threshold, they're squashed.
+	putMDs(3)
+	err = tlfJournal.flush(ctx)
+	require.NoError(t, err)
+	require.Equal(
+		t, kbfsmd.PendingLocalSquashBranchID, tlfJournal.mdJournal.getBranchID())
+	requireJournalEntryCounts(t, tlfJournal, 3, 3)
+	require.Len(t, mdserver.rmdses, 2)
+}
+
 // Test that the first revision of a TLF doesn't get squashed.
-func testTLFJournalFirstRevNoSquash(t *testing.T, ver kbfsmd.MetadataVer) {
+func testTLFJournalFirstRevNoSquash(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
 	tempdir, config, ctx, cancel, tlfJournal, delegate :=
-		setupTLFJournalTest(t, ver, TLFJournalBackgroundWorkPaused)
+		setupTLFJournalTest(t, ver, cryptKey, TLFJournalBackgroundWorkPaused)
 	defer teardownTLFJournalTest(
 		tempdir, config, ctx, cancel, tlfJournal, delegate)
 	tlfJournal.forcedSquashByBytes = 10
@@ -1776,9 +1914,10 @@ func testTLFJournalFirstRevNoSquash(t *testing.T, ver kbfsmd.MetadataVer) {
 // testTLFJournalSingleOp tests that when the journal is in single op
 // mode, it doesn't flush any MDs until `finishSingleOp()` is called,
 // and then it only flushes one squashed MD.
-func testTLFJournalSingleOp(t *testing.T, ver kbfsmd.MetadataVer) {
+func testTLFJournalSingleOp(
+	t *testing.T, ver kbfsmd.MetadataVer, cryptKey kbfscrypto.JournalCryptKey) {
 	tempdir, config, ctx, cancel, tlfJournal, delegate :=
-		setupTLFJournalTest(t, ver, TLFJournalSingleOpBackgroundWorkEnabled)
+		setupTLFJournalTest(t, ver, cryptKey, TLFJournalSingleOpBackgroundWorkEnabled)
 	defer teardownTLFJournalTest(
 		tempdir, config, ctx, cancel, tlfJournal, delegate)
 
@@ -1853,7 +1992,7 @@ func testTLFJournalSingleOp(t *testing.T, ver kbfsmd.MetadataVer) {
 }
 
 func TestTLFJournal(t *testing.T) {
-	tests := []func(*testing.T, kbfsmd.MetadataVer){
+	tests := []func(*testing.T, kbfsmd.MetadataVer, kbfscrypto.JournalCryptKey){
 		testTLFJournalBasic,
 		testTLFJournalPauseResume,
 		testTLFJournalPauseShutdown,
@@ -1882,8 +2021,10 @@ func TestTLFJournal(t *testing.T) {
 		testTLFJournalFlushRetry,
 		testTLFJournalResolveBranch,
 		testTLFJournalSquashByBytes,
+		testTLFJournalSquashByRevs,
+		testTLFJournalSquashByRevsOffline,
 		testTLFJournalFirstRevNoSquash,
 		testTLFJournalSingleOp,
 	}
-	runTestsOverMetadataVers(t, "testTLFJournal", tests)
+	runJournalTestsOverMetadataVers(t, "testTLFJournal", tests)
 }
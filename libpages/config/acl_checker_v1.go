@@ -25,6 +25,10 @@ type AccessControlV1 struct {
 	// defines a list of additional permissions that authenticated users have
 	// in addition to AnonymousPermissions.
 	WhitelistAdditionalPermissions map[string]string `json:"whitelist_additional_permissions"`
+	// GroupAdditionalPermissions is a map of group name -> permissions that
+	// defines a list of additional permissions that authenticated users in
+	// the group have in addition to AnonymousPermissions.
+	GroupAdditionalPermissions map[string]string `json:"group_additional_permissions,omitempty"`
 	// AnonymousPermissions is the permissions for
 	// unauthenticated/anonymous requests.
 	AnonymousPermissions string `json:"anonymous_permissions"`
@@ -63,7 +67,11 @@ type accessControlV1 struct {
 	// AccessControlV1.WhitelistAdditionalPermissions. See comment of latter
 	// for more details. It's a map of username -> permissionsV1.
 	whitelistAdditional map[string]permissionsV1
-	anonymous           permissionsV1
+	// groupAdditional is the internal version of
+	// AccessControlV1.GroupAdditionalPermissions. It's a map of group name ->
+	// permissionsV1.
+	groupAdditional map[string]permissionsV1
+	anonymous       permissionsV1
 	// maxPermission stores the most permissive permission that either an
 	// anonymous or an authenticated user can get for this path. Note that this
 	// doesn't necessarily mean there's a user able to get exactly this
@@ -79,9 +87,10 @@ type accessControlV1 struct {
 
 // makeAccessControlV1Internal makes an *accessControlV1 out of an
 // *AccessControlV1. The users map is used to check if every username defined
-// in WhitelistAdditionalPermissions is defined.
-func makeAccessControlV1Internal(
-	a *AccessControlV1, users map[string]string, p string) (
+// in WhitelistAdditionalPermissions is defined, and the groups map is used to
+// check if every group in GroupAdditionalPermissions is defined.
+func makeAccessControlV1Internal(a *AccessControlV1,
+	users map[string]string, groups map[string]GroupV1, p string) (
 	ac *accessControlV1, err error) {
 	if a == nil {
 		return nil, errors.New("nil AccessControlV1")
@@ -107,6 +116,21 @@ func makeAccessControlV1Internal(
 		ac.maxPermission.read = ac.maxPermission.read || parsedPermissions.read
 		ac.maxPermission.list = ac.maxPermission.list || parsedPermissions.list
 	}
+	for group, permissions := range a.GroupAdditionalPermissions {
+		if _, ok := groups[group]; !ok {
+			return nil, ErrUndefinedGroup{group: group}
+		}
+		if ac.groupAdditional == nil {
+			ac.groupAdditional = make(map[string]permissionsV1)
+		}
+		parsedPermissions, err := parsePermissionsV1(permissions)
+		if err != nil {
+			return nil, err
+		}
+		ac.groupAdditional[group] = parsedPermissions
+		ac.maxPermission.read = ac.maxPermission.read || parsedPermissions.read
+		ac.maxPermission.list = ac.maxPermission.list || parsedPermissions.list
+	}
 	return ac, nil
 }
 
@@ -202,22 +226,30 @@ func (c *aclCheckerV1) getAccessControl(
 	return effectiveAC
 }
 
-// getPermissions returns the permissions that username has on p. This method
-// should only be called on the root aclCheckerV1.
-func (c *aclCheckerV1) getPermissions(p string, username *string) (
+// getPermissions returns the permissions that username, who is a member of
+// groups, has on p. This method should only be called on the root
+// aclCheckerV1.
+func (c *aclCheckerV1) getPermissions(
+	p string, username *string, groups []string) (
 	permissions permissionsV1, max permissionsV1, effectivePath string) {
 	// This is only called on the root aclCheckerV1, and c.ac is always
 	// populated here. So even if no other path shows up in the ACLs, any path
 	// will get root's *accessControlV1 as the last resort.
 	ac := c.getAccessControl(nil, p)
 	permissions = ac.anonymous
-	if ac.whitelistAdditional == nil || username == nil {
+	if username == nil {
 		return permissions, ac.maxPermission, ac.p
 	}
 	if perms, ok := ac.whitelistAdditional[*username]; ok {
 		permissions.read = perms.read || permissions.read
 		permissions.list = perms.list || permissions.list
 	}
+	for _, group := range groups {
+		if perms, ok := ac.groupAdditional[group]; ok {
+			permissions.read = perms.read || permissions.read
+			permissions.list = perms.list || permissions.list
+		}
+	}
 	return permissions, ac.maxPermission, ac.p
 }
 
@@ -225,7 +257,7 @@ func (c *aclCheckerV1) getPermissions(p string, username *string) (
 // recursively constructs nested *aclCheckerV1 so that each defined path has a
 // corresponding checker, and all intermediate nodes have a checker populated.
 func makeACLCheckerV1(acl map[string]AccessControlV1,
-	users map[string]string) (*aclCheckerV1, error) {
+	users map[string]string, groups map[string]GroupV1) (*aclCheckerV1, error) {
 	root := &aclCheckerV1{ac: emptyAccessControlV1InternalForRoot()}
 	if acl == nil {
 		return root, nil
@@ -254,7 +286,7 @@ func makeACLCheckerV1(acl map[string]AccessControlV1,
 	// Iterate through the cleaned slice, and construct *aclCheckerV1 objects
 	// along each path.
 	for p, a := range cleaned {
-		ac, err := makeAccessControlV1Internal(a, users, p)
+		ac, err := makeAccessControlV1Internal(a, users, groups, p)
 		if err != nil {
 			return nil, err
 		}
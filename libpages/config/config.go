@@ -9,6 +9,9 @@ import (
 	"context"
 	"encoding/json"
 	"io"
+	"net/url"
+
+	"golang.org/x/time/rate"
 )
 
 // DefaultConfigFilename is the default filename for Keybase Pages config file.
@@ -77,6 +80,16 @@ func parseVersion(s string) (Version, error) {
 type Config interface {
 	Version() Version
 	Authenticate(ctx context.Context, username, password string) bool
+	// AuthenticateToken checks if bearerToken is a valid and unexpired bearer
+	// token defined in the config. If so, the username the token is issued
+	// for is returned.
+	AuthenticateToken(ctx context.Context, bearerToken string) (
+		username string, ok bool)
+	// AuthenticateSignedURL checks if query carries a valid and unexpired
+	// signature for urlPath. If so, the username the URL is signed for is
+	// returned.
+	AuthenticateSignedURL(urlPath string, query url.Values) (
+		username string, ok bool)
 	// GetPermissions returns permission info. If username is nil, anonymous
 	// permissions are returned. Otherwise, permissions for *username is
 	// returned. Additionally, "maximum possible permissions" are returned,
@@ -86,6 +99,27 @@ type Config interface {
 		read, list bool,
 		possibleRead, possibleList bool,
 		realm string, err error)
+	// GetPermissionsWithTeamMembership is like GetPermissions, but
+	// additionally uses checker to determine membership of groups that are
+	// tied to the team that owns the site. If checker is nil, it behaves
+	// exactly like GetPermissions.
+	GetPermissionsWithTeamMembership(ctx context.Context,
+		path string, username *string, checker TeamMembershipChecker) (
+		read, list bool,
+		possibleRead, possibleList bool,
+		realm string, err error)
+	// GetRateLimit returns the token-bucket rate limit that should be applied
+	// to all requests made to the site. If no limit is configured, rate.Inf
+	// is returned as limit.
+	GetRateLimit() (limit rate.Limit, burst int, err error)
+	// GetListingTemplatePath returns the path to a user-defined template for
+	// directory listings, or an empty string if the default one should be
+	// used.
+	GetListingTemplatePath() string
+	// HasSigningSecret returns true if the config has a secret for signing
+	// URLs. Such a config must not be readable by anyone who shouldn't be
+	// able to mint signed URLs.
+	HasSigningSecret() bool
 
 	Encode(w io.Writer, prettify bool) error
 }
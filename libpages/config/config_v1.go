@@ -6,8 +6,12 @@ package config
 
 import (
 	"context"
+	"encoding/hex"
 	"encoding/json"
 	"io"
+	"net/url"
+	"path"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -37,6 +41,35 @@ type V1 struct {
 	// paths.
 	ACLs map[string]AccessControlV1 `json:"acls"`
 
+	// Groups is a [group name -> GroupV1] map that defines groups that can be
+	// granted permissions in ACLs.
+	Groups map[string]GroupV1 `json:"groups,omitempty"`
+
+	groups groupsV1
+
+	// RateLimit, if set, limits the rate of requests served for the site.
+	// Requests over the limit are rejected with 429 Too Many Requests.
+	RateLimit *RateLimitV1 `json:"rate_limit,omitempty"`
+
+	// Tokens is a [token ID -> TokenV1] map that defines bearer tokens that
+	// can be used in place of usernames and passwords.
+	Tokens map[string]TokenV1 `json:"tokens,omitempty"`
+
+	tokens map[string]token
+
+	// SigningSecret is a hex encoded secret used to sign and verify signed
+	// URLs. If empty, signed URLs are not accepted. Changing it revokes all
+	// previously signed URLs. Since anyone who can read the config can mint
+	// signed URLs, it's not allowed for sites in public TLFs.
+	SigningSecret string `json:"signing_secret,omitempty"`
+
+	signingSecret []byte
+
+	// ListingTemplate, if set, is the path (relative to the site root) to a
+	// Go html/template file used to render directory listings, instead of
+	// the default listing page. It can't be a .kbp_config file.
+	ListingTemplate string `json:"listing_template,omitempty"`
+
 	initOnce          sync.Once
 	aclChecker        *aclCheckerV1
 	aclCheckerInitErr error
@@ -65,7 +98,27 @@ const bcryptRateLimitInterval = time.Second / 2
 
 func (c *V1) init() {
 	c.bcryptLimiter = rate.NewLimiter(rate.Every(bcryptRateLimitInterval), 1)
-	c.aclChecker, c.aclCheckerInitErr = makeACLCheckerV1(c.ACLs, c.Users)
+	if c.aclCheckerInitErr = c.RateLimit.validate(); c.aclCheckerInitErr != nil {
+		return
+	}
+	c.aclCheckerInitErr = validateListingTemplatePath(c.ListingTemplate)
+	if c.aclCheckerInitErr != nil {
+		return
+	}
+	c.aclChecker, c.aclCheckerInitErr = makeACLCheckerV1(
+		c.ACLs, c.Users, c.Groups)
+	if c.aclCheckerInitErr != nil {
+		return
+	}
+	c.groups, c.aclCheckerInitErr = parseGroupsV1(c.Groups, c.Users)
+	if c.aclCheckerInitErr != nil {
+		return
+	}
+	c.tokens, c.aclCheckerInitErr = parseTokens(c.Tokens, c.Users)
+	if c.aclCheckerInitErr != nil {
+		return
+	}
+	c.signingSecret, c.aclCheckerInitErr = parseSigningSecret(c.SigningSecret)
 	if c.aclCheckerInitErr != nil {
 		return
 	}
@@ -105,6 +158,55 @@ func (c *V1) Authenticate(ctx context.Context, username, cleartextPassword strin
 	return err == nil && match
 }
 
+// AuthenticateToken implements the Config interface.
+func (c *V1) AuthenticateToken(
+	ctx context.Context, bearerToken string) (username string, ok bool) {
+	if c.EnsureInit() != nil {
+		return "", false
+	}
+	return checkToken(ctx, c.tokens, bearerToken, time.Now())
+}
+
+// AuthenticateSignedURL implements the Config interface.
+func (c *V1) AuthenticateSignedURL(
+	urlPath string, query url.Values) (username string, ok bool) {
+	if c.EnsureInit() != nil {
+		return "", false
+	}
+	username = query.Get(SignedURLUsernameParam)
+	if _, ok := c.users[username]; !ok {
+		return "", false
+	}
+	if !checkSignedURL(c.signingSecret, urlPath, username,
+		query.Get(SignedURLExpiresParam), query.Get(SignedURLSignatureParam),
+		time.Now()) {
+		return "", false
+	}
+	return username, true
+}
+
+// SignURL makes the query parameters of a signed URL for urlPath, which
+// authenticates the bearer of the URL as username until expiresAt. An error
+// is returned if c doesn't have a signing secret, or username is not defined.
+func (c *V1) SignURL(urlPath string, username string, expiresAt time.Time) (
+	query url.Values, err error) {
+	if err = c.EnsureInit(); err != nil {
+		return nil, err
+	}
+	if len(c.signingSecret) == 0 {
+		return nil, ErrInvalidSigningSecret{}
+	}
+	if _, ok := c.users[username]; !ok {
+		return nil, ErrUndefinedUsername{username: username}
+	}
+	signature := signURL(c.signingSecret, urlPath, username, expiresAt.Unix())
+	query = url.Values{}
+	query.Set(SignedURLUsernameParam, username)
+	query.Set(SignedURLExpiresParam, strconv.FormatInt(expiresAt.Unix(), 10))
+	query.Set(SignedURLSignatureParam, hex.EncodeToString(signature))
+	return query, nil
+}
+
 // GetPermissions implements the Config interface.
 func (c *V1) GetPermissions(path string, username *string) (
 	read, list bool,
@@ -114,10 +216,67 @@ func (c *V1) GetPermissions(path string, username *string) (
 		return false, false, false, false, "", err
 	}
 
-	perms, maxPerms, realm := c.aclChecker.getPermissions(path, username)
+	return c.GetPermissionsWithTeamMembership(
+		context.Background(), path, username, nil)
+}
+
+// GetPermissionsWithTeamMembership implements the Config interface.
+func (c *V1) GetPermissionsWithTeamMembership(ctx context.Context,
+	path string, username *string, checker TeamMembershipChecker) (
+	read, list bool,
+	possibleRead, possibleList bool,
+	realm string, err error) {
+	if err = c.EnsureInit(); err != nil {
+		return false, false, false, false, "", err
+	}
+
+	var groups []string
+	if username != nil {
+		groups, err = c.groups.getGroups(ctx, *username, checker)
+		if err != nil {
+			return false, false, false, false, "", err
+		}
+	}
+	perms, maxPerms, realm := c.aclChecker.getPermissions(
+		path, username, groups)
 	return perms.read, perms.list, maxPerms.read, maxPerms.list, realm, nil
 }
 
+// GetRateLimit implements the Config interface.
+func (c *V1) GetRateLimit() (limit rate.Limit, burst int, err error) {
+	if err = c.EnsureInit(); err != nil {
+		return 0, 0, err
+	}
+	limit, burst = c.RateLimit.limit()
+	return limit, burst, nil
+}
+
+// IsConfigFilePath reports whether p, relative to the site root, names a
+// config file, which must never be served or used as a template.
+func IsConfigFilePath(p string) bool {
+	return strings.ToLower(path.Base(path.Clean("/"+p))) ==
+		DefaultConfigFilename
+}
+
+// validateListingTemplatePath makes sure the listing template isn't the
+// config file, so that its contents can't be leaked through listings.
+func validateListingTemplatePath(p string) error {
+	if len(p) > 0 && IsConfigFilePath(p) {
+		return ErrInvalidListingTemplate{templatePath: p}
+	}
+	return nil
+}
+
+// GetListingTemplatePath implements the Config interface.
+func (c *V1) GetListingTemplatePath() string {
+	return c.ListingTemplate
+}
+
+// HasSigningSecret implements the Config interface.
+func (c *V1) HasSigningSecret() bool {
+	return len(c.SigningSecret) > 0
+}
+
 // Encode implements the Config interface.
 func (c *V1) Encode(w io.Writer, prettify bool) error {
 	encoder := json.NewEncoder(w)
@@ -138,7 +297,22 @@ func (c *V1) Encode(w io.Writer, prettify bool) error {
 // As a result, unlike other methods on the type, this method is not goroutine
 // safe against changes to the public fields.
 func (c *V1) Validate() error {
-	_, err := makeACLCheckerV1(c.ACLs, c.Users)
+	if err := c.RateLimit.validate(); err != nil {
+		return err
+	}
+	if err := validateListingTemplatePath(c.ListingTemplate); err != nil {
+		return err
+	}
+	if _, err := parseTokens(c.Tokens, c.Users); err != nil {
+		return err
+	}
+	if _, err := parseSigningSecret(c.SigningSecret); err != nil {
+		return err
+	}
+	if _, err := parseGroupsV1(c.Groups, c.Users); err != nil {
+		return err
+	}
+	_, err := makeACLCheckerV1(c.ACLs, c.Users, c.Groups)
 	return err
 }
 
@@ -11,6 +11,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
 )
 
 func TestConfigV1Default(t *testing.T) {
@@ -86,6 +87,26 @@ func TestConfigV1Invalid(t *testing.T) {
 	}).EnsureInit()
 	require.Error(t, err)
 	require.IsType(t, ErrInvalidPermissions{}, err)
+
+	for _, templatePath := range []string{
+		".kbp_config", "/.kbp_config", "foo/../.KBP_CONFIG", "foo/.kbp_config",
+	} {
+		err = (&V1{
+			Common: Common{
+				Version: Version1Str,
+			},
+			ListingTemplate: templatePath,
+		}).EnsureInit()
+		require.Error(t, err, templatePath)
+		require.IsType(t, ErrInvalidListingTemplate{}, err, templatePath)
+	}
+	err = (&V1{
+		Common: Common{
+			Version: Version1Str,
+		},
+		ListingTemplate: "/listing.html",
+	}).Validate()
+	require.NoError(t, err)
 }
 
 func TestConfigV1Full(t *testing.T) {
@@ -338,6 +359,189 @@ func TestConfigV1Full(t *testing.T) {
 	require.Equal(t, "/bob/dir/deep-dir/deep-deep-dir", realm)
 }
 
+type fakeTeamMembershipCheckerForTest struct {
+	writers map[string]bool
+	readers map[string]bool
+}
+
+func (c fakeTeamMembershipCheckerForTest) IsTeamMember(
+	_ context.Context, username string, writer bool) (bool, error) {
+	if writer {
+		return c.writers[username], nil
+	}
+	return c.writers[username] || c.readers[username], nil
+}
+
+func TestConfigV1Groups(t *testing.T) {
+	config := V1{
+		Common: Common{
+			Version: Version1Str,
+		},
+		Users: map[string]string{
+			"alice": generateSHA256PasswordHashForTestOrBust(t, "12345"),
+			"bob":   generateSHA256PasswordHashForTestOrBust(t, "54321"),
+			"carol": generateSHA256PasswordHashForTestOrBust(t, "11111"),
+		},
+		Groups: map[string]GroupV1{
+			"editors": GroupV1{
+				Members: []string{"alice"},
+			},
+			"team-readers": GroupV1{
+				TeamRole: TeamRoleReader,
+			},
+			"team-writers": GroupV1{
+				Members:  []string{"carol"},
+				TeamRole: TeamRoleWriter,
+			},
+		},
+		ACLs: map[string]AccessControlV1{
+			"/": AccessControlV1{
+				GroupAdditionalPermissions: map[string]string{
+					"editors":      PermReadAndList,
+					"team-readers": PermRead,
+				},
+			},
+			"/drafts": AccessControlV1{
+				GroupAdditionalPermissions: map[string]string{
+					"team-writers": PermRead,
+				},
+			},
+		},
+	}
+	checker := fakeTeamMembershipCheckerForTest{
+		writers: map[string]bool{"bob": true},
+	}
+	ctx := context.Background()
+
+	read, list, possibleRead, possibleList,
+		realm, err := config.GetPermissions("/", nil)
+	require.NoError(t, err)
+	require.False(t, read)
+	require.False(t, list)
+	require.True(t, possibleRead)
+	require.True(t, possibleList)
+	require.Equal(t, "/", realm)
+
+	read, list, _, _, _, err = config.GetPermissions("/", stringPtr("alice"))
+	require.NoError(t, err)
+	require.True(t, read)
+	require.True(t, list)
+
+	// Without a checker, team groups have no members other than explicitly
+	// listed ones.
+	read, list, _, _, _, err = config.GetPermissions("/", stringPtr("bob"))
+	require.NoError(t, err)
+	require.False(t, read)
+	require.False(t, list)
+	read, list, _, _, _, err = config.GetPermissions(
+		"/drafts", stringPtr("carol"))
+	require.NoError(t, err)
+	require.True(t, read)
+	require.False(t, list)
+
+	read, list, _, _, _, err = config.GetPermissionsWithTeamMembership(
+		ctx, "/", stringPtr("bob"), checker)
+	require.NoError(t, err)
+	require.True(t, read)
+	require.False(t, list)
+	read, list, _, _, _, err = config.GetPermissionsWithTeamMembership(
+		ctx, "/drafts", stringPtr("bob"), checker)
+	require.NoError(t, err)
+	require.True(t, read)
+	require.False(t, list)
+	read, list, _, _, _, err = config.GetPermissionsWithTeamMembership(
+		ctx, "/drafts", stringPtr("alice"), checker)
+	require.NoError(t, err)
+	require.False(t, read)
+	require.False(t, list)
+
+	err = (&V1{
+		Common: Common{
+			Version: Version1Str,
+		},
+		ACLs: map[string]AccessControlV1{
+			"/": AccessControlV1{
+				GroupAdditionalPermissions: map[string]string{
+					"editors": PermRead,
+				},
+			},
+		},
+	}).EnsureInit()
+	require.Error(t, err)
+	require.IsType(t, ErrUndefinedGroup{}, err)
+
+	err = (&V1{
+		Common: Common{
+			Version: Version1Str,
+		},
+		Groups: map[string]GroupV1{
+			"editors": GroupV1{
+				Members: []string{"alice"},
+			},
+		},
+	}).EnsureInit()
+	require.Error(t, err)
+	require.IsType(t, ErrUndefinedUsername{}, err)
+
+	err = (&V1{
+		Common: Common{
+			Version: Version1Str,
+		},
+		Groups: map[string]GroupV1{
+			"admins": GroupV1{
+				TeamRole: "admin",
+			},
+		},
+	}).Validate()
+	require.Error(t, err)
+	require.IsType(t, ErrInvalidTeamRole{}, err)
+}
+
+func TestConfigV1RateLimit(t *testing.T) {
+	limit, burst, err := DefaultV1().GetRateLimit()
+	require.NoError(t, err)
+	require.Equal(t, rate.Inf, limit)
+	require.Equal(t, 0, burst)
+
+	config := &V1{
+		Common: Common{
+			Version: Version1Str,
+		},
+		RateLimit: &RateLimitV1{
+			RequestsPerSecond: 2.5,
+			Burst:             10,
+		},
+	}
+	limit, burst, err = config.GetRateLimit()
+	require.NoError(t, err)
+	require.Equal(t, rate.Limit(2.5), limit)
+	require.Equal(t, 10, burst)
+
+	err = (&V1{
+		Common: Common{
+			Version: Version1Str,
+		},
+		RateLimit: &RateLimitV1{
+			RequestsPerSecond: 0,
+			Burst:             10,
+		},
+	}).EnsureInit()
+	require.Error(t, err)
+	require.IsType(t, ErrInvalidRateLimit{}, err)
+
+	err = (&V1{
+		Common: Common{
+			Version: Version1Str,
+		},
+		RateLimit: &RateLimitV1{
+			RequestsPerSecond: 1,
+			Burst:             0,
+		},
+	}).Validate()
+	require.Error(t, err)
+	require.IsType(t, ErrInvalidRateLimit{}, err)
+}
+
 func TestV1EncodeObjectKeyOrder(t *testing.T) {
 	// We are relying on an undocumented feature of encoding/json where struct
 	// fields are serialized into json with the same order that they are
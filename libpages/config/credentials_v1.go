@@ -0,0 +1,205 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// SignedURLUsernameParam is the query parameter in a signed URL that
+	// holds the username the URL is signed for.
+	SignedURLUsernameParam = "kbp_user"
+	// SignedURLExpiresParam is the query parameter in a signed URL that holds
+	// the Unix time after which the URL is no longer valid.
+	SignedURLExpiresParam = "kbp_expires"
+	// SignedURLSignatureParam is the query parameter in a signed URL that
+	// holds the hex encoded HMAC-SHA256 signature.
+	SignedURLSignatureParam = "kbp_signature"
+)
+
+const (
+	tokenIDSize       = 8
+	tokenSecretSize   = 24
+	signingSecretSize = 32
+	tokenDivider      = "."
+)
+
+// TokenV1 defines a bearer token that can be used in place of a username and
+// password, using the "Authorization: Bearer <token>" header.
+type TokenV1 struct {
+	// Username is the user that requests bearing this token are authenticated
+	// as. It must be defined in the Users section of the config.
+	Username string `json:"username"`
+	// Hash is the hash of the secret part of the token, in the same format
+	// as password hashes in the Users section of the config.
+	Hash string `json:"hash"`
+	// ExpiresAt, if non-zero, is the Unix time after which the token is no
+	// longer accepted.
+	ExpiresAt int64 `json:"expires_at,omitempty"`
+}
+
+// token is the parsed version of TokenV1.
+type token struct {
+	username  string
+	hash      password
+	expiresAt int64
+}
+
+func (t token) isExpired(now time.Time) bool {
+	return t.expiresAt != 0 && now.Unix() > t.expiresAt
+}
+
+// ErrInvalidSigningSecret is returned when the signing secret in the config is
+// not a valid hex encoded secret.
+type ErrInvalidSigningSecret struct{}
+
+// Error implements the error interface.
+func (ErrInvalidSigningSecret) Error() string {
+	return "invalid signing secret"
+}
+
+func randomHex(size int) (string, error) {
+	b := make([]byte, size)
+	n, err := rand.Read(b)
+	if err != nil || n != size {
+		return "", errors.New("reading random bytes error")
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// GenerateSigningSecret generates a random secret suitable for the
+// SigningSecret field of a V1 config.
+func GenerateSigningSecret() (string, error) {
+	return randomHex(signingSecretSize)
+}
+
+// GenerateToken generates a random bearer token. The id should be used as the
+// key in the Tokens section of a V1 config, hash should be used as the Hash
+// field of the TokenV1, and token is what's given to the user.
+func GenerateToken() (id string, token string, hash string, err error) {
+	if id, err = randomHex(tokenIDSize); err != nil {
+		return "", "", "", err
+	}
+	secret, err := randomHex(tokenSecretSize)
+	if err != nil {
+		return "", "", "", err
+	}
+	if hash, err = GenerateSHA256PasswordHash(secret); err != nil {
+		return "", "", "", err
+	}
+	return id, id + tokenDivider + secret, hash, nil
+}
+
+// splitToken splits a bearer token into an id and a secret.
+func splitToken(bearerToken string) (id string, secret string, ok bool) {
+	parts := strings.SplitN(bearerToken, tokenDivider, 2)
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+func parseTokens(tokens map[string]TokenV1,
+	users map[string]string) (map[string]token, error) {
+	parsed := make(map[string]token, len(tokens))
+	for id, t := range tokens {
+		if _, ok := users[t.Username]; !ok {
+			return nil, ErrUndefinedUsername{username: t.Username}
+		}
+		hash, err := newPassword(t.Hash)
+		if err != nil {
+			return nil, err
+		}
+		if hash.passwordType() != passwordTypeSHA256 {
+			// Tokens are checked without a rate limiter, so only allow fast
+			// hashes here.
+			return nil, InvalidPasswordHash{}
+		}
+		parsed[id] = token{
+			username:  t.Username,
+			hash:      hash,
+			expiresAt: t.ExpiresAt,
+		}
+	}
+	return parsed, nil
+}
+
+func parseSigningSecret(signingSecret string) ([]byte, error) {
+	if len(signingSecret) == 0 {
+		return nil, nil
+	}
+	secret, err := hex.DecodeString(signingSecret)
+	if err != nil || len(secret) < signingSecretSize {
+		return nil, ErrInvalidSigningSecret{}
+	}
+	return secret, nil
+}
+
+// cleanURLPath cleans p so that a signature covers the same path no matter
+// how it's written in the URL.
+func cleanURLPath(p string) string {
+	return path.Clean("/" + p)
+}
+
+func signURL(secret []byte,
+	urlPath string, username string, expiresAt int64) []byte {
+	mac := hmac.New(sha256.New, secret)
+	// Use a separator that can't appear in any of the fields, so that
+	// different combinations of the fields never produce the same message.
+	mac.Write([]byte(cleanURLPath(urlPath)))
+	mac.Write([]byte{0})
+	mac.Write([]byte(username))
+	mac.Write([]byte{0})
+	mac.Write([]byte(strconv.FormatInt(expiresAt, 10)))
+	return mac.Sum(nil)
+}
+
+// checkToken returns the username that bearerToken is issued for, if it's a
+// valid and unexpired token among tokens.
+func checkToken(ctx context.Context, tokens map[string]token,
+	bearerToken string, now time.Time) (username string, ok bool) {
+	id, secret, ok := splitToken(bearerToken)
+	if !ok {
+		return "", false
+	}
+	t, ok := tokens[id]
+	if !ok || t.isExpired(now) {
+		return "", false
+	}
+	match, err := t.hash.check(ctx, nil, secret)
+	if err != nil || !match {
+		return "", false
+	}
+	return t.username, true
+}
+
+// checkSignedURL reports whether signatureStr is a valid signature for
+// urlPath and username, and expiresAtStr hasn't passed yet.
+func checkSignedURL(secret []byte, urlPath string,
+	username string, expiresAtStr string, signatureStr string,
+	now time.Time) (ok bool) {
+	if len(secret) == 0 || len(username) == 0 {
+		return false
+	}
+	expiresAt, err := strconv.ParseInt(expiresAtStr, 10, 64)
+	if err != nil || now.Unix() > expiresAt {
+		return false
+	}
+	signature, err := hex.DecodeString(signatureStr)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(signature, signURL(secret, urlPath, username, expiresAt))
+}
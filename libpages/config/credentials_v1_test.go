@@ -0,0 +1,157 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigV1Tokens(t *testing.T) {
+	id, token, hash, err := GenerateToken()
+	require.NoError(t, err)
+	expiredID, expiredToken, expiredHash, err := GenerateToken()
+	require.NoError(t, err)
+
+	config := &V1{
+		Common: Common{
+			Version: Version1Str,
+		},
+		Users: map[string]string{
+			"alice": generateSHA256PasswordHashForTestOrBust(t, "12345"),
+		},
+		Tokens: map[string]TokenV1{
+			id: TokenV1{
+				Username: "alice",
+				Hash:     hash,
+			},
+			expiredID: TokenV1{
+				Username:  "alice",
+				Hash:      expiredHash,
+				ExpiresAt: time.Now().Add(-time.Hour).Unix(),
+			},
+		},
+	}
+	ctx := context.Background()
+
+	username, ok := config.AuthenticateToken(ctx, token)
+	require.True(t, ok)
+	require.Equal(t, "alice", username)
+
+	_, ok = config.AuthenticateToken(ctx, expiredToken)
+	require.False(t, ok)
+	_, ok = config.AuthenticateToken(ctx, id+tokenDivider+"bad")
+	require.False(t, ok)
+	_, ok = config.AuthenticateToken(ctx, id)
+	require.False(t, ok)
+	_, ok = config.AuthenticateToken(ctx, "")
+	require.False(t, ok)
+
+	err = (&V1{
+		Common: Common{
+			Version: Version1Str,
+		},
+		Tokens: map[string]TokenV1{
+			id: TokenV1{
+				Username: "bob",
+				Hash:     hash,
+			},
+		},
+	}).EnsureInit()
+	require.Error(t, err)
+	require.IsType(t, ErrUndefinedUsername{}, err)
+
+	err = (&V1{
+		Common: Common{
+			Version: Version1Str,
+		},
+		Users: map[string]string{
+			"alice": generateSHA256PasswordHashForTestOrBust(t, "12345"),
+		},
+		Tokens: map[string]TokenV1{
+			id: TokenV1{
+				Username: "alice",
+				Hash:     generateBcryptPasswordHashForTestOrBust(t, "12345"),
+			},
+		},
+	}).Validate()
+	require.Error(t, err)
+	require.IsType(t, InvalidPasswordHash{}, err)
+}
+
+func TestConfigV1SignedURL(t *testing.T) {
+	secret, err := GenerateSigningSecret()
+	require.NoError(t, err)
+	config := &V1{
+		Common: Common{
+			Version: Version1Str,
+		},
+		Users: map[string]string{
+			"alice": generateSHA256PasswordHashForTestOrBust(t, "12345"),
+		},
+		SigningSecret: secret,
+	}
+
+	query, err := config.SignURL("/review/index.html", "alice",
+		time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	username, ok := config.AuthenticateSignedURL("/review/index.html", query)
+	require.True(t, ok)
+	require.Equal(t, "alice", username)
+	// Equivalent paths should be accepted too.
+	_, ok = config.AuthenticateSignedURL("/review//./index.html", query)
+	require.True(t, ok)
+
+	// A different path is not covered by the signature.
+	_, ok = config.AuthenticateSignedURL("/review/other.html", query)
+	require.False(t, ok)
+
+	// Tampering with the username or expiry breaks the signature.
+	tampered, err := config.SignURL("/review/index.html", "alice",
+		time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	tampered.Set(SignedURLExpiresParam, "99999999999")
+	_, ok = config.AuthenticateSignedURL("/review/index.html", tampered)
+	require.False(t, ok)
+
+	// Expired signed URLs are rejected.
+	expired, err := config.SignURL("/review/index.html", "alice",
+		time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	_, ok = config.AuthenticateSignedURL("/review/index.html", expired)
+	require.False(t, ok)
+
+	// Rotating the secret revokes all signed URLs.
+	newSecret, err := GenerateSigningSecret()
+	require.NoError(t, err)
+	rotated := &V1{
+		Common:        config.Common,
+		Users:         config.Users,
+		SigningSecret: newSecret,
+	}
+	_, ok = rotated.AuthenticateSignedURL("/review/index.html", query)
+	require.False(t, ok)
+
+	// No signing secret means no signed URLs.
+	noSecret := &V1{
+		Common: config.Common,
+		Users:  config.Users,
+	}
+	_, ok = noSecret.AuthenticateSignedURL("/review/index.html", query)
+	require.False(t, ok)
+	_, err = noSecret.SignURL("/review/index.html", "alice", time.Now())
+	require.IsType(t, ErrInvalidSigningSecret{}, err)
+
+	err = (&V1{
+		Common: Common{
+			Version: Version1Str,
+		},
+		SigningSecret: "not hex",
+	}).EnsureInit()
+	require.IsType(t, ErrInvalidSigningSecret{}, err)
+}
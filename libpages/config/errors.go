@@ -44,3 +44,49 @@ type ErrUndefinedUsername struct {
 func (e ErrUndefinedUsername) Error() string {
 	return fmt.Sprintf("undefined username %s", e.username)
 }
+
+// ErrInvalidRateLimit is returned when a rate limit defined in the config has
+// a non-positive rate or burst.
+type ErrInvalidRateLimit struct {
+	requestsPerSecond float64
+	burst             int
+}
+
+// Error implements the error interface.
+func (e ErrInvalidRateLimit) Error() string {
+	return fmt.Sprintf("invalid rate limit: requests_per_second=%v burst=%d",
+		e.requestsPerSecond, e.burst)
+}
+
+// ErrInvalidListingTemplate is returned when the listing template defined in
+// the config points at the config file itself.
+type ErrInvalidListingTemplate struct {
+	templatePath string
+}
+
+// Error implements the error interface.
+func (e ErrInvalidListingTemplate) Error() string {
+	return fmt.Sprintf("invalid listing template %s", e.templatePath)
+}
+
+// ErrUndefinedGroup is returned when a group appears in a ACL but it's not
+// defined in the config's Groups section.
+type ErrUndefinedGroup struct {
+	group string
+}
+
+// Error implements the error interface.
+func (e ErrUndefinedGroup) Error() string {
+	return fmt.Sprintf("undefined group %s", e.group)
+}
+
+// ErrInvalidTeamRole is returned when a group in the config has a team role
+// other than "reader" or "writer".
+type ErrInvalidTeamRole struct {
+	teamRole string
+}
+
+// Error implements the error interface.
+func (e ErrInvalidTeamRole) Error() string {
+	return fmt.Sprintf("invalid team role %s", e.teamRole)
+}
@@ -0,0 +1,107 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"context"
+	"sort"
+)
+
+const (
+	// TeamRoleReader makes all readers and writers of the team that owns the
+	// site members of a group.
+	TeamRoleReader = "reader"
+	// TeamRoleWriter makes all writers of the team that owns the site members
+	// of a group.
+	TeamRoleWriter = "writer"
+)
+
+// GroupV1 defines a named group of users, which can be granted permissions
+// in ACLs as a whole.
+type GroupV1 struct {
+	// Members is a list of usernames that are members of the group. Each
+	// username must be defined in the Users section of the config.
+	Members []string `json:"members,omitempty"`
+	// TeamRole, if set, additionally makes a user a member of the group if
+	// its username is a Keybase username with at least this role in the team
+	// that owns the site. It can be either "reader" or "writer". It has no
+	// effect for sites that are not in a team TLF.
+	TeamRole string `json:"team_role,omitempty"`
+}
+
+// TeamMembershipChecker checks membership of the team that owns a site.
+type TeamMembershipChecker interface {
+	// IsTeamMember returns true if username is a Keybase user that's a
+	// member of the team that owns the site. If writer is true, the user
+	// needs to be a writer of the team.
+	IsTeamMember(ctx context.Context, username string, writer bool) (
+		bool, error)
+}
+
+// groupsV1 is the parsed version of the Groups section of the V1 config.
+type groupsV1 struct {
+	// userGroups is a map of username -> names of groups that the user is a
+	// member of through the Members field.
+	userGroups map[string][]string
+	// teamGroups is a map of group name -> whether being a writer of the team
+	// is required to be a member of the group.
+	teamGroups map[string]bool
+}
+
+func parseGroupsV1(groups map[string]GroupV1,
+	users map[string]string) (parsed groupsV1, err error) {
+	parsed.userGroups = make(map[string][]string)
+	parsed.teamGroups = make(map[string]bool)
+	for name, group := range groups {
+		for _, username := range group.Members {
+			if _, ok := users[username]; !ok {
+				return groupsV1{}, ErrUndefinedUsername{username: username}
+			}
+			parsed.userGroups[username] = append(
+				parsed.userGroups[username], name)
+		}
+		switch group.TeamRole {
+		case "":
+		case TeamRoleReader:
+			parsed.teamGroups[name] = false
+		case TeamRoleWriter:
+			parsed.teamGroups[name] = true
+		default:
+			return groupsV1{}, ErrInvalidTeamRole{teamRole: group.TeamRole}
+		}
+	}
+	return parsed, nil
+}
+
+// getGroups returns the names of all groups that username is a member of.
+// Team membership is only checked if checker is not nil.
+func (g groupsV1) getGroups(ctx context.Context,
+	username string, checker TeamMembershipChecker) ([]string, error) {
+	groups := append([]string(nil), g.userGroups[username]...)
+	if checker == nil || len(g.teamGroups) == 0 {
+		return groups, nil
+	}
+	// Only ask about each distinct role once, since it may involve talking
+	// to the Keybase service.
+	var isReader, isWriter *bool
+	for name, needsWriter := range g.teamGroups {
+		membership := &isReader
+		if needsWriter {
+			membership = &isWriter
+		}
+		if *membership == nil {
+			isMember, err := checker.IsTeamMember(ctx, username, needsWriter)
+			if err != nil {
+				return nil, err
+			}
+			*membership = &isMember
+		}
+		if **membership {
+			groups = append(groups, name)
+		}
+	}
+	sort.Strings(groups)
+	return groups, nil
+}
@@ -0,0 +1,40 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package config
+
+import "golang.org/x/time/rate"
+
+// RateLimitV1 defines a token-bucket rate limit for requests made to a site.
+type RateLimitV1 struct {
+	// RequestsPerSecond is the rate at which tokens are added to the bucket,
+	// i.e., the number of requests per second that can be sustained.
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	// Burst is the size of the bucket, i.e., the maximum number of requests
+	// that can be served at once after a period of no requests.
+	Burst int `json:"burst"`
+}
+
+// validate checks if r is a valid rate limit.
+func (r *RateLimitV1) validate() error {
+	if r == nil {
+		return nil
+	}
+	if r.RequestsPerSecond <= 0 || r.Burst <= 0 {
+		return ErrInvalidRateLimit{
+			requestsPerSecond: r.RequestsPerSecond,
+			burst:             r.Burst,
+		}
+	}
+	return nil
+}
+
+// limit returns the rate.Limit and burst that r defines. If r is nil,
+// rate.Inf is returned, which means no limit.
+func (r *RateLimitV1) limit() (limit rate.Limit, burst int) {
+	if r == nil {
+		return rate.Inf, 0
+	}
+	return rate.Limit(r.RequestsPerSecond), r.Burst
+}
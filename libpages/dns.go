@@ -12,16 +12,15 @@ import (
 	"go.uber.org/zap/zapcore"
 )
 
-// RootLoader is the interface for loading a site root. This interface exists
-// for instrumenting tests. In real instances, only the DNSRootLoader should be
-// used.
+// RootLoader is the interface for loading a site root. In most real
+// instances, the DNSRootLoader should be used. FileRootLoader can be used
+// where DNS is not available.
 type RootLoader interface {
 	LoadRoot(domain string) (root Root, err error)
 }
 
 // DNSRootLoader is an implementation of RootLoader loads a root from DNS. This
-// is the RootLoader that should be used in all non-test scenarios. See doc for
-// LoadRoot for more.
+// is the default RootLoader used by the server. See doc for LoadRoot for more.
 type DNSRootLoader struct {
 	log *zap.Logger
 }
@@ -0,0 +1,184 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libpages
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// DefaultFileRootLoaderReloadInterval is the default interval at which a
+// FileRootLoader checks its mapping source for changes.
+const DefaultFileRootLoaderReloadInterval = 10 * time.Second
+
+// FileRootLoader is an implementation of RootLoader that loads roots from a
+// local host -> root mapping, with no DNS dependency. This is useful for
+// testing, and for running kbpagesd on hosts that can't (or shouldn't) rely
+// on DNS TXT records.
+//
+// The mapping is read from path, which can be one of:
+//   1. A JSON file containing an object that maps domains to root strings.
+//      For example:
+//        {
+//          "example.com": "/keybase/public/alice/site",
+//          "git.example.com": "git@keybase:private/alice,kb_bot/site.git"
+//        }
+//   2. A YAML file with a ".yaml" or ".yml" extension, containing the same
+//      kind of mapping. For example:
+//        example.com: /keybase/public/alice/site
+//        git.example.com: git@keybase:private/alice,kb_bot/site.git
+//   3. A directory, where each regular file is named after a domain and
+//      contains a root string, just like the value of a "kbp=" TXT record.
+//      An optional "kbp=" prefix is allowed. Files with names starting with
+//      "." are ignored.
+//
+// The mapping is reloaded periodically. If a reload fails, the previously
+// loaded mapping is kept and the error is logged.
+type FileRootLoader struct {
+	log  *zap.Logger
+	path string
+
+	lock  sync.RWMutex
+	roots map[string]Root
+}
+
+var _ RootLoader = (*FileRootLoader)(nil)
+
+// NewFileRootLoader makes a new FileRootLoader that reads the mapping from
+// path. An error is returned if the initial load fails. If reloadInterval is
+// positive, the mapping is reloaded at that interval until ctx is done.
+func NewFileRootLoader(ctx context.Context, log *zap.Logger,
+	path string, reloadInterval time.Duration) (*FileRootLoader, error) {
+	l := &FileRootLoader{log: log, path: path}
+	roots, err := l.load()
+	if err != nil {
+		return nil, err
+	}
+	l.roots = roots
+	if reloadInterval > 0 {
+		go l.reloadLoop(ctx, reloadInterval)
+	}
+	return l, nil
+}
+
+func parseRootMapping(mapping map[string]string) (map[string]Root, error) {
+	roots := make(map[string]Root, len(mapping))
+	for domain, str := range mapping {
+		root, err := ParseRoot(strings.TrimPrefix(
+			strings.TrimSpace(str), keybasePagesPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("parsing root for %s error: %v", domain, err)
+		}
+		roots[strings.ToLower(strings.TrimSpace(domain))] = root
+	}
+	return roots, nil
+}
+
+func loadRootMappingFromDir(dir string) (map[string]string, error) {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	mapping := make(map[string]string, len(fis))
+	for _, fi := range fis {
+		if !fi.Mode().IsRegular() || strings.HasPrefix(fi.Name(), ".") {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			return nil, err
+		}
+		mapping[fi.Name()] = string(data)
+	}
+	return mapping, nil
+}
+
+func loadRootMappingFromFile(p string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	var mapping map[string]string
+	switch strings.ToLower(filepath.Ext(p)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &mapping)
+	default:
+		err = json.Unmarshal(data, &mapping)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mapping, nil
+}
+
+func (l *FileRootLoader) load() (map[string]Root, error) {
+	fi, err := os.Stat(l.path)
+	if err != nil {
+		return nil, err
+	}
+	var mapping map[string]string
+	if fi.IsDir() {
+		mapping, err = loadRootMappingFromDir(l.path)
+	} else {
+		mapping, err = loadRootMappingFromFile(l.path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseRootMapping(mapping)
+}
+
+func (l *FileRootLoader) reload() {
+	roots, err := l.load()
+	if err != nil {
+		l.log.Warn("FileRootLoader.reload",
+			zap.String("path", l.path), zap.Error(err))
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if reflect.DeepEqual(roots, l.roots) {
+		return
+	}
+	l.roots = roots
+	l.log.Info("FileRootLoader.reload",
+		zap.String("path", l.path), zap.Int("domains", len(roots)))
+}
+
+func (l *FileRootLoader) reloadLoop(
+	ctx context.Context, reloadInterval time.Duration) {
+	ticker := time.NewTicker(reloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.reload()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// LoadRoot implements the RootLoader interface.
+func (l *FileRootLoader) LoadRoot(domain string) (root Root, err error) {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	root, ok := l.roots[strings.ToLower(strings.TrimSpace(domain))]
+	if !ok {
+		return Root{}, ErrKeybasePagesRecordNotFound{}
+	}
+	return root, nil
+}
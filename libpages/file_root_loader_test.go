@@ -0,0 +1,125 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libpages
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/keybase/kbfs/ioutil"
+	"github.com/keybase/kbfs/tlf"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestFileRootLoaderJSONFile(t *testing.T) {
+	tempdir, err := ioutil.TempDir(os.TempDir(), "file_root_loader")
+	require.NoError(t, err)
+	defer ioutil.RemoveAll(tempdir)
+	mappingPath := filepath.Join(tempdir, "roots.json")
+	err = ioutil.WriteFile(mappingPath, []byte(`{
+		"Example.com": "/keybase/public/alice/site",
+		"git.example.com": "git@keybase:private/alice,kb_bot/site.git"
+	}`), 0600)
+	require.NoError(t, err)
+
+	logger, err := zap.NewDevelopment()
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	loader, err := NewFileRootLoader(ctx, logger, mappingPath, 0)
+	require.NoError(t, err)
+
+	root, err := loader.LoadRoot("example.com")
+	require.NoError(t, err)
+	require.Equal(t, KBFSRoot, root.Type)
+	require.Equal(t, tlf.Public, root.TlfType)
+	require.Equal(t, "alice", root.TlfNameUnparsed)
+	require.Equal(t, "site", root.PathUnparsed)
+
+	root, err = loader.LoadRoot("git.example.com")
+	require.NoError(t, err)
+	require.Equal(t, GitRoot, root.Type)
+
+	_, err = loader.LoadRoot("other.example.com")
+	require.IsType(t, ErrKeybasePagesRecordNotFound{}, err)
+
+	// An invalid mapping on reload should keep the old one around.
+	err = ioutil.WriteFile(mappingPath, []byte(`{"example.com": "bad"}`), 0600)
+	require.NoError(t, err)
+	loader.reload()
+	_, err = loader.LoadRoot("git.example.com")
+	require.NoError(t, err)
+
+	err = ioutil.WriteFile(mappingPath,
+		[]byte(`{"new.example.com": "/keybase/team/kb.bots/site"}`), 0600)
+	require.NoError(t, err)
+	loader.reload()
+	_, err = loader.LoadRoot("git.example.com")
+	require.IsType(t, ErrKeybasePagesRecordNotFound{}, err)
+	root, err = loader.LoadRoot("new.example.com")
+	require.NoError(t, err)
+	require.Equal(t, tlf.SingleTeam, root.TlfType)
+}
+
+func TestFileRootLoaderYAMLFile(t *testing.T) {
+	tempdir, err := ioutil.TempDir(os.TempDir(), "file_root_loader")
+	require.NoError(t, err)
+	defer ioutil.RemoveAll(tempdir)
+	mappingPath := filepath.Join(tempdir, "roots.yml")
+	err = ioutil.WriteFile(mappingPath, []byte(`
+Example.com: /keybase/public/alice/site
+git.example.com: "git@keybase:private/alice,kb_bot/site.git"
+`), 0600)
+	require.NoError(t, err)
+
+	logger, err := zap.NewDevelopment()
+	require.NoError(t, err)
+	loader, err := NewFileRootLoader(
+		context.Background(), logger, mappingPath, 0)
+	require.NoError(t, err)
+
+	root, err := loader.LoadRoot("example.com")
+	require.NoError(t, err)
+	require.Equal(t, KBFSRoot, root.Type)
+	require.Equal(t, tlf.Public, root.TlfType)
+	require.Equal(t, "site", root.PathUnparsed)
+
+	root, err = loader.LoadRoot("git.example.com")
+	require.NoError(t, err)
+	require.Equal(t, GitRoot, root.Type)
+	require.Equal(t, "alice,kb_bot", root.TlfNameUnparsed)
+
+	// A file that isn't a mapping fails to load.
+	err = ioutil.WriteFile(mappingPath, []byte("- not a mapping\n"), 0600)
+	require.NoError(t, err)
+	_, err = NewFileRootLoader(context.Background(), logger, mappingPath, 0)
+	require.Error(t, err)
+}
+
+func TestFileRootLoaderDir(t *testing.T) {
+	tempdir, err := ioutil.TempDir(os.TempDir(), "file_root_loader")
+	require.NoError(t, err)
+	defer ioutil.RemoveAll(tempdir)
+	err = ioutil.WriteFile(filepath.Join(tempdir, "example.com"),
+		[]byte("kbp=/keybase/private/alice,kb_bot/site\n"), 0600)
+	require.NoError(t, err)
+	err = ioutil.WriteFile(filepath.Join(tempdir, ".ignored"),
+		[]byte("not a root"), 0600)
+	require.NoError(t, err)
+
+	logger, err := zap.NewDevelopment()
+	require.NoError(t, err)
+	loader, err := NewFileRootLoader(
+		context.Background(), logger, tempdir, 0)
+	require.NoError(t, err)
+
+	root, err := loader.LoadRoot("example.com")
+	require.NoError(t, err)
+	require.Equal(t, tlf.Private, root.TlfType)
+	require.Equal(t, "alice,kb_bot", root.TlfNameUnparsed)
+}
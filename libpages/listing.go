@@ -0,0 +1,338 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libpages
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/keybase/kbfs/libfs"
+	"github.com/keybase/kbfs/libpages/config"
+	"go.uber.org/zap"
+)
+
+// listingEntry describes an entry in a directory listing. It's used both in
+// JSON listings and as data for HTML listing templates.
+type listingEntry struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	IsDir   bool      `json:"is_dir"`
+}
+
+// listing is the data for a directory listing. It's used both as the JSON
+// listing response, and as data for HTML listing templates.
+type listing struct {
+	Path    string         `json:"path"`
+	Entries []listingEntry `json:"entries"`
+	// SortBy and Order are only used by HTML templates, to render the
+	// sortable column headers.
+	SortBy string `json:"-"`
+	Order  string `json:"-"`
+}
+
+const (
+	listingSortByName  = "name"
+	listingSortBySize  = "size"
+	listingSortByMtime = "mtime"
+	listingOrderAsc    = "asc"
+	listingOrderDesc   = "desc"
+
+	listingSortQueryKey  = "sort"
+	listingOrderQueryKey = "order"
+	jsonContentType      = "application/json"
+)
+
+func makeListingEntries(fis []os.FileInfo) []listingEntry {
+	entries := make([]listingEntry, 0, len(fis))
+	for _, fi := range fis {
+		if strings.ToLower(fi.Name()) == config.DefaultConfigFilename {
+			// The config file can't be read directly, so don't list it
+			// either.
+			continue
+		}
+		entries = append(entries, listingEntry{
+			Name:    fi.Name(),
+			Size:    fi.Size(),
+			ModTime: fi.ModTime(),
+			IsDir:   fi.IsDir(),
+		})
+	}
+	return entries
+}
+
+// sortListingEntries sorts entries by sortBy in order. Directories are always
+// listed before files. It returns the sortBy and order actually used, which
+// are the defaults if the given ones are invalid.
+func sortListingEntries(entries []listingEntry, sortBy string, order string) (
+	usedSortBy string, usedOrder string) {
+	var less func(i, j int) bool
+	switch sortBy {
+	case listingSortBySize:
+		less = func(i, j int) bool { return entries[i].Size < entries[j].Size }
+	case listingSortByMtime:
+		less = func(i, j int) bool {
+			return entries[i].ModTime.Before(entries[j].ModTime)
+		}
+	default:
+		sortBy = listingSortByName
+		less = func(i, j int) bool { return entries[i].Name < entries[j].Name }
+	}
+	if order != listingOrderDesc {
+		order = listingOrderAsc
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].IsDir != entries[j].IsDir {
+			return entries[i].IsDir
+		}
+		if order == listingOrderDesc {
+			return less(j, i)
+		}
+		return less(i, j)
+	})
+	return sortBy, order
+}
+
+var listingTemplateFuncs = template.FuncMap{
+	// nextOrder returns the order that clicking on the column header for
+	// sortBy should switch to.
+	"nextOrder": func(l listing, sortBy string) string {
+		if l.SortBy == sortBy && l.Order == listingOrderAsc {
+			return listingOrderDesc
+		}
+		return listingOrderAsc
+	},
+	"formatTime": func(t time.Time) string {
+		return t.UTC().Format("2006-01-02 15:04:05 MST")
+	},
+	// entryURL returns the link to the entry with the given name, relative
+	// to the listed directory. Like http.FileServer, it escapes the name as
+	// a path, so that a ':', '#' or '?' in it isn't taken as part of a
+	// scheme, fragment or query.
+	"entryURL": func(name string) string {
+		u := url.URL{Path: "./" + name}
+		return u.String()
+	},
+}
+
+var defaultListingTemplate = template.Must(template.New("listing").Funcs(
+	listingTemplateFuncs).Parse(`<!DOCTYPE html>
+<html>
+	<head>
+		<meta charset="UTF-8">
+		<meta name="viewport" content="width=device-width, initial-scale=1">
+		<title>Index of {{.Path}}</title>
+		<style>
+			body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #333; }
+			h1 { font-weight: normal; font-size: 1.5em; }
+			table { border-collapse: collapse; width: 100%; max-width: 60em; }
+			th, td { text-align: left; padding: 0.3em 1em 0.3em 0; border-bottom: 1px solid #eee; }
+			th a { color: #333; }
+			td.size, th.size { text-align: right; }
+			a { color: #4c8eff; text-decoration: none; }
+			a:hover { text-decoration: underline; }
+		</style>
+	</head>
+	<body>
+		<h1>Index of {{.Path}}</h1>
+		<table>
+			<tr>
+				<th><a href="?sort=name&amp;order={{nextOrder . "name"}}">Name</a></th>
+				<th class="size"><a href="?sort=size&amp;order={{nextOrder . "size"}}">Size</a></th>
+				<th><a href="?sort=mtime&amp;order={{nextOrder . "mtime"}}">Last Modified</a></th>
+			</tr>
+			{{- if ne .Path "/"}}
+			<tr><td><a href="../">../</a></td><td></td><td></td></tr>
+			{{- end}}
+			{{- range .Entries}}
+			<tr>
+				{{- if .IsDir}}
+				<td><a href="{{entryURL .Name}}/">{{.Name}}/</a></td>
+				<td class="size">-</td>
+				{{- else}}
+				<td><a href="{{entryURL .Name}}">{{.Name}}</a></td>
+				<td class="size">{{.Size}}</td>
+				{{- end}}
+				<td>{{formatTime .ModTime}}</td>
+			</tr>
+			{{- end}}
+		</table>
+	</body>
+</html>
+`))
+
+// parseAcceptQuality returns the media type and the quality value of one
+// element of an Accept header. A missing or invalid q parameter counts as 1.
+func parseAcceptQuality(accept string) (mediaType string, q float64) {
+	params := strings.Split(accept, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(params[0]))
+	q = 1
+	for _, param := range params[1:] {
+		kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+		if len(kv) != 2 || strings.ToLower(strings.TrimSpace(kv[0])) != "q" {
+			continue
+		}
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+		if err == nil && parsed >= 0 && parsed <= 1 {
+			q = parsed
+		}
+	}
+	return mediaType, q
+}
+
+// acceptsJSON reports whether r prefers a JSON listing over an HTML one,
+// according to the quality values in its Accept header. On a tie, a media
+// type named explicitly wins over a wildcard, and HTML wins otherwise.
+func acceptsJSON(r *http.Request) bool {
+	// The quality of HTML comes from the most specific media range that
+	// matches it: 2 for "text/html", 1 for "text/*" and 0 for "*/*".
+	jsonQ, htmlQ, htmlSpecificity := 0.0, 0.0, -1
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, q := parseAcceptQuality(accept)
+		specificity := -1
+		switch mediaType {
+		case jsonContentType:
+			if q > jsonQ {
+				jsonQ = q
+			}
+		case "text/html":
+			specificity = 2
+		case "text/*":
+			specificity = 1
+		case "*/*":
+			specificity = 0
+		}
+		if specificity > htmlSpecificity ||
+			(specificity >= 0 && specificity == htmlSpecificity && q > htmlQ) {
+			htmlQ, htmlSpecificity = q, specificity
+		}
+	}
+	if jsonQ == 0 {
+		return false
+	}
+	return jsonQ > htmlQ || (jsonQ == htmlQ && htmlSpecificity < 2)
+}
+
+// writeListing writes l to w, either as JSON if r prefers it, or as HTML
+// rendered with tmpl. The listing is rendered in full before anything is
+// written to w, so that if rendering fails, the caller can still write an
+// error response.
+func writeListing(w http.ResponseWriter, r *http.Request,
+	l listing, tmpl *template.Template) error {
+	var buf bytes.Buffer
+	contentType := "text/html; charset=utf-8"
+	if acceptsJSON(r) {
+		contentType = jsonContentType
+		if err := json.NewEncoder(&buf).Encode(l); err != nil {
+			return err
+		}
+	} else if err := tmpl.Execute(&buf, l); err != nil {
+		return err
+	}
+	// The response depends on the Accept header, so caches must not serve
+	// one kind of listing to a client asking for the other.
+	w.Header().Add("Vary", "Accept")
+	w.Header().Set("Content-Type", contentType)
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// loadListingTemplate loads a user-defined listing template from
+// templatePath in realFS. If templatePath is empty, or the template can't be
+// loaded, the default template is returned.
+func (s *Server) loadListingTemplate(
+	realFS *libfs.FS, templatePath string) *template.Template {
+	if len(templatePath) == 0 {
+		return defaultListingTemplate
+	}
+	if config.IsConfigFilePath(templatePath) {
+		// Config validation rejects this already, but never render the
+		// config file into a page no matter what.
+		s.config.Logger.Warn("loadListingTemplate: template is the config file",
+			zap.String("template_path", templatePath))
+		return defaultListingTemplate
+	}
+	f, err := realFS.Open(templatePath)
+	if err != nil {
+		s.config.Logger.Warn("loadListingTemplate",
+			zap.String("template_path", templatePath), zap.Error(err))
+		return defaultListingTemplate
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		s.config.Logger.Warn("loadListingTemplate",
+			zap.String("template_path", templatePath), zap.Error(err))
+		return defaultListingTemplate
+	}
+	tmpl, err := template.New("listing").Funcs(
+		listingTemplateFuncs).Parse(string(data))
+	if err != nil {
+		s.config.Logger.Warn("loadListingTemplate",
+			zap.String("template_path", templatePath), zap.Error(err))
+		return defaultListingTemplate
+	}
+	return tmpl
+}
+
+// getListingTemplate returns the listing template of st at templatePath.
+// Like the config, the parsed template is cached in st for configCacheTime,
+// so that it isn't read and parsed again for every listing.
+func (s *Server) getListingTemplate(st *site, realFS *libfs.FS,
+	templatePath string) *template.Template {
+	st.listingTemplateLock.Lock()
+	defer st.listingTemplateLock.Unlock()
+	if st.listingTemplate != nil && st.listingTemplatePath == templatePath &&
+		st.listingTemplateExpiresAt.After(time.Now()) {
+		return st.listingTemplate
+	}
+	tmpl := s.loadListingTemplate(realFS, templatePath)
+	st.listingTemplate = tmpl
+	st.listingTemplatePath = templatePath
+	st.listingTemplateExpiresAt = time.Now().Add(configCacheTime)
+	return tmpl
+}
+
+// serveListing serves a listing of the directory at r.URL.Path, which must
+// be a directory with no index.html.
+func (s *Server) serveListing(w http.ResponseWriter, r *http.Request,
+	st *site, realFS *libfs.FS, cfg config.Config) error {
+	// Just like http.FileServer, redirect to a path with a trailing slash so
+	// that relative links work.
+	if !strings.HasSuffix(r.URL.Path, "/") {
+		target := path.Base(r.URL.Path) + "/"
+		if len(r.URL.RawQuery) > 0 {
+			target += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+		return nil
+	}
+	fis, err := realFS.ReadDir(strings.Trim(path.Clean(r.URL.Path), "/"))
+	if err != nil {
+		return err
+	}
+	l := listing{
+		Path:    path.Clean(r.URL.Path),
+		Entries: makeListingEntries(fis),
+	}
+	if l.Path != "/" {
+		l.Path += "/"
+	}
+	query := r.URL.Query()
+	l.SortBy, l.Order = sortListingEntries(l.Entries,
+		query.Get(listingSortQueryKey), query.Get(listingOrderQueryKey))
+	return writeListing(w, r, l,
+		s.getListingTemplate(st, realFS, cfg.GetListingTemplatePath()))
+}
@@ -0,0 +1,161 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libpages
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeFileInfoForTest struct {
+	name    string
+	size    int64
+	modTime time.Time
+	isDir   bool
+}
+
+func (fi fakeFileInfoForTest) Name() string       { return fi.name }
+func (fi fakeFileInfoForTest) Size() int64        { return fi.size }
+func (fi fakeFileInfoForTest) Mode() os.FileMode  { return 0 }
+func (fi fakeFileInfoForTest) ModTime() time.Time { return fi.modTime }
+func (fi fakeFileInfoForTest) IsDir() bool        { return fi.isDir }
+func (fi fakeFileInfoForTest) Sys() interface{}   { return nil }
+
+func makeListingEntriesForTest() []listingEntry {
+	now := time.Now()
+	return makeListingEntries([]os.FileInfo{
+		fakeFileInfoForTest{name: "b.txt", size: 1, modTime: now},
+		fakeFileInfoForTest{
+			name: "a.txt", size: 3, modTime: now.Add(-time.Hour)},
+		fakeFileInfoForTest{name: "dir", isDir: true, modTime: now},
+		fakeFileInfoForTest{name: ".kbp_config", size: 2, modTime: now},
+	})
+}
+
+func listingEntryNames(entries []listingEntry) (names []string) {
+	for _, e := range entries {
+		names = append(names, e.Name)
+	}
+	return names
+}
+
+func TestListingSort(t *testing.T) {
+	entries := makeListingEntriesForTest()
+	require.Len(t, entries, 3)
+
+	sortBy, order := sortListingEntries(entries, "", "")
+	require.Equal(t, listingSortByName, sortBy)
+	require.Equal(t, listingOrderAsc, order)
+	require.Equal(t, []string{"dir", "a.txt", "b.txt"},
+		listingEntryNames(entries))
+
+	sortListingEntries(entries, listingSortBySize, listingOrderDesc)
+	require.Equal(t, []string{"dir", "a.txt", "b.txt"},
+		listingEntryNames(entries))
+
+	sortListingEntries(entries, listingSortByMtime, listingOrderDesc)
+	require.Equal(t, []string{"dir", "b.txt", "a.txt"},
+		listingEntryNames(entries))
+}
+
+func TestWriteListing(t *testing.T) {
+	l := listing{Path: "/foo/", Entries: makeListingEntriesForTest()}
+	l.SortBy, l.Order = sortListingEntries(l.Entries, "", "")
+
+	r := httptest.NewRequest("GET", "/foo/", nil)
+	r.Header.Set("Accept", "text/html;q=0.8, application/json")
+	w := httptest.NewRecorder()
+	err := writeListing(w, r, l, defaultListingTemplate)
+	require.NoError(t, err)
+	require.Equal(t, jsonContentType, w.Header().Get("Content-Type"))
+	require.Equal(t, "Accept", w.Header().Get("Vary"))
+	var decoded listing
+	err = json.NewDecoder(w.Body).Decode(&decoded)
+	require.NoError(t, err)
+	require.Equal(t, "/foo/", decoded.Path)
+	require.Equal(t, []string{"dir", "a.txt", "b.txt"},
+		listingEntryNames(decoded.Entries))
+	require.True(t, decoded.Entries[0].IsDir)
+	require.Equal(t, int64(3), decoded.Entries[1].Size)
+
+	r = httptest.NewRequest("GET", "/foo/", nil)
+	w = httptest.NewRecorder()
+	err = writeListing(w, r, l, defaultListingTemplate)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(
+		w.Header().Get("Content-Type"), "text/html"))
+	require.Equal(t, "Accept", w.Header().Get("Vary"))
+	body := w.Body.String()
+	require.Contains(t, body, `<a href="./dir/">dir/</a>`)
+	require.Contains(t, body, `<a href="./a.txt">a.txt</a>`)
+	require.Contains(t, body, `<a href="../">../</a>`)
+	require.NotContains(t, body, ".kbp_config")
+	// Since we are sorting by name ascendingly, clicking on "Name" again
+	// should sort descendingly.
+	require.Contains(t, body, `?sort=name&amp;order=desc`)
+}
+
+func TestWriteListingEscapesLinks(t *testing.T) {
+	l := listing{Path: "/", Entries: makeListingEntries([]os.FileInfo{
+		fakeFileInfoForTest{name: "a:b.txt"},
+		fakeFileInfoForTest{name: "c#d?e.txt"},
+		fakeFileInfoForTest{name: "f g", isDir: true},
+	})}
+	l.SortBy, l.Order = sortListingEntries(l.Entries, "", "")
+
+	r := httptest.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	err := writeListing(w, r, l, defaultListingTemplate)
+	require.NoError(t, err)
+	body := w.Body.String()
+	// A colon in a name must not be taken as a URL scheme.
+	require.Contains(t, body, `<a href="./a:b.txt">a:b.txt</a>`)
+	require.NotContains(t, body, "ZgotmplZ")
+	// '#' and '?' in a name must be escaped as part of the path.
+	require.Contains(t, body, `<a href="./c%23d%3Fe.txt">c#d?e.txt</a>`)
+	require.Contains(t, body, `<a href="./f%20g/">f g/</a>`)
+}
+
+func TestWriteListingTemplateError(t *testing.T) {
+	l := listing{Path: "/", Entries: makeListingEntriesForTest()}
+	tmpl := template.Must(template.New("listing").Funcs(
+		listingTemplateFuncs).Parse(`<html>{{.Path}}{{.NoSuchField}}`))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	err := writeListing(w, r, l, tmpl)
+	require.Error(t, err)
+	// Nothing of a failed listing should have been written, so that an
+	// error response can still be sent.
+	require.Equal(t, 0, w.Body.Len())
+	require.False(t, w.Flushed)
+	require.Empty(t, w.Header().Get("Content-Type"))
+}
+
+func TestAcceptsJSON(t *testing.T) {
+	for accept, expected := range map[string]bool{
+		"":                                       false,
+		"application/json":                       true,
+		"application/json;q=0.9, text/html":      false,
+		"text/html;q=0.8, application/json":      true,
+		"application/json, text/plain, */*":      true,
+		"application/json;q=0.5, */*":            false,
+		"text/html, application/json":            false,
+		"text/html;q=0.5, */*, application/json": true,
+		"application/json;q=0":                   false,
+		"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8": false,
+	} {
+		r := httptest.NewRequest("GET", "/foo/", nil)
+		r.Header.Set("Accept", accept)
+		require.Equal(t, expected, acceptsJSON(r), accept)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libpages
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"golang.org/x/time/rate"
+)
+
+// clientRateLimiterCacheSize is the maximum number of client IPs we keep a
+// rate limiter for. When a client is evicted from the cache, it simply gets a
+// full bucket next time it shows up.
+const clientRateLimiterCacheSize = 1 << 14
+
+// clientRateLimiters keeps a token-bucket rate limiter for each client IP.
+type clientRateLimiters struct {
+	limit   rate.Limit
+	burst   int
+	clients *lru.Cache
+}
+
+// ErrInvalidClientBurst is returned when a per-client rate limit is
+// configured with a burst that's less than 1, which would reject every
+// request.
+type ErrInvalidClientBurst struct {
+	burst int
+}
+
+// Error implements the error interface.
+func (e ErrInvalidClientBurst) Error() string {
+	return "invalid per-client burst " + strconv.Itoa(e.burst) +
+		"; it must be at least 1 when a per-client rate limit is set"
+}
+
+func checkClientBurst(burst int) error {
+	if burst < 1 {
+		return ErrInvalidClientBurst{burst: burst}
+	}
+	return nil
+}
+
+func newClientRateLimiters(
+	limit rate.Limit, burst int) (*clientRateLimiters, error) {
+	if err := checkClientBurst(burst); err != nil {
+		return nil, err
+	}
+	clients, err := lru.New(clientRateLimiterCacheSize)
+	if err != nil {
+		return nil, err
+	}
+	return &clientRateLimiters{
+		limit:   limit,
+		burst:   burst,
+		clients: clients,
+	}, nil
+}
+
+// getLimiter returns the rate limiter for clientIP, creating one if needed.
+// It's possible for two concurrent requests from a new client to both create
+// a limiter, in which case one of them wins and the other is dropped. This is
+// fine since it only lets a few more requests through at most.
+func (l *clientRateLimiters) getLimiter(clientIP string) *rate.Limiter {
+	if cached, ok := l.clients.Get(clientIP); ok {
+		if limiter, ok := cached.(*rate.Limiter); ok {
+			return limiter
+		}
+	}
+	limiter := rate.NewLimiter(l.limit, l.burst)
+	l.clients.Add(clientIP, limiter)
+	return limiter
+}
+
+// allow reports whether a request from clientIP should be served now. If
+// not, retryAfter is how long the client should wait before trying again.
+func (l *clientRateLimiters) allow(
+	clientIP string) (ok bool, retryAfter time.Duration) {
+	return reserveFromLimiter(l.getLimiter(clientIP))
+}
+
+// reserveFromLimiter takes a token from limiter if one is available right
+// now. Otherwise, no token is taken, and the duration after which a token
+// would be available is returned.
+func reserveFromLimiter(
+	limiter *rate.Limiter) (ok bool, retryAfter time.Duration) {
+	r := limiter.Reserve()
+	if !r.OK() {
+		// This only happens if burst is 0, in which case no request can ever
+		// be served. Tell the client to come back later anyway.
+		return false, time.Second
+	}
+	if delay := r.Delay(); delay > 0 {
+		r.Cancel()
+		return false, delay
+	}
+	return true, 0
+}
+
+// getClientIP returns the IP address of the client that sent r.
+func getClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// handleRateLimited writes a 429 Too Many Requests response with a
+// Retry-After header derived from retryAfter.
+func (s *Server) handleRateLimited(
+	w http.ResponseWriter, retryAfter time.Duration) {
+	// Retry-After only takes whole seconds, so round up to make sure the
+	// client doesn't come back too early.
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	http.Error(w, http.StatusText(http.StatusTooManyRequests),
+		http.StatusTooManyRequests)
+}
@@ -0,0 +1,92 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libpages
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/keybase/kbfs/libpages/config"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestClientRateLimiters(t *testing.T) {
+	// A tiny rate so the bucket doesn't refill during the test.
+	limiters, err := newClientRateLimiters(rate.Limit(0.001), 2)
+	require.NoError(t, err)
+
+	ok, _ := limiters.allow("1.2.3.4")
+	require.True(t, ok)
+	ok, _ = limiters.allow("1.2.3.4")
+	require.True(t, ok)
+	ok, retryAfter := limiters.allow("1.2.3.4")
+	require.False(t, ok)
+	require.True(t, retryAfter > 0)
+
+	// A different client has its own bucket.
+	ok, _ = limiters.allow("4.3.2.1")
+	require.True(t, ok)
+
+	// A zero burst would reject every request.
+	_, err = newClientRateLimiters(rate.Limit(1), 0)
+	require.IsType(t, ErrInvalidClientBurst{}, err)
+}
+
+func TestSiteRateLimit(t *testing.T) {
+	var st site
+	// Before any config is loaded, everything is allowed.
+	ok, _ := st.checkRateLimit()
+	require.True(t, ok)
+
+	// A tiny rate so the bucket doesn't refill during the test.
+	cfg := &config.V1{
+		Common: config.Common{
+			Version: config.Version1Str,
+		},
+		RateLimit: &config.RateLimitV1{
+			RequestsPerSecond: 0.001,
+			Burst:             1,
+		},
+	}
+	require.NoError(t, st.updateRateLimit(cfg))
+	ok, _ = st.checkRateLimit()
+	require.True(t, ok)
+	ok, retryAfter := st.checkRateLimit()
+	require.False(t, ok)
+	require.True(t, retryAfter > 0)
+
+	// Loading the same limit again keeps the drained bucket.
+	require.NoError(t, st.updateRateLimit(cfg))
+	ok, _ = st.checkRateLimit()
+	require.False(t, ok)
+
+	// Dropping the rate limit from the config lifts it.
+	require.NoError(t, st.updateRateLimit(config.DefaultV1()))
+	ok, _ = st.checkRateLimit()
+	require.True(t, ok)
+}
+
+func TestHandleRateLimited(t *testing.T) {
+	var s Server
+	w := httptest.NewRecorder()
+	s.handleRateLimited(w, 0)
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	w = httptest.NewRecorder()
+	s.handleRateLimited(w, 2500*time.Millisecond)
+	require.Equal(t, "3", w.Header().Get("Retry-After"))
+}
+
+func TestGetClientIP(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "1.2.3.4:5678"
+	require.Equal(t, "1.2.3.4", getClientIP(r))
+	r.RemoteAddr = "[::1]:5678"
+	require.Equal(t, "::1", getClientIP(r))
+}
@@ -27,6 +27,7 @@ import (
 	"go.uber.org/zap"
 	"golang.org/x/crypto/acme"
 	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/time/rate"
 )
 
 // ServerConfig holds configuration parameters for Server.
@@ -42,10 +43,24 @@ type ServerConfig struct {
 	Logger           *zap.Logger
 	UseDiskCertCache bool
 	StatsReporter    StatsReporter
+	// If PerClientRateLimit is non-zero, requests from each client IP are
+	// limited by a token-bucket rate limiter that allows PerClientRateLimit
+	// requests per second, with a bucket size of PerClientBurst, which must
+	// then be at least 1. Requests over the limit are rejected with 429 Too
+	// Many Requests.
+	PerClientRateLimit rate.Limit
+	PerClientBurst     int
+	// RootLoader is used to load the root of a site from its domain. If nil,
+	// a DNSRootLoader is used.
+	RootLoader RootLoader
 
 	domainListsOnce sync.Once
 	domainWhitelist map[string]bool
 	domainBlacklist []string
+
+	clientRateLimitersOnce    sync.Once
+	clientRateLimiters        *clientRateLimiters
+	clientRateLimitersInitErr error
 }
 
 // ErrDomainBlockedInBlacklist is returned when the server is configured
@@ -97,6 +112,25 @@ func (c *ServerConfig) checkDomainLists(domain string) error {
 	return nil
 }
 
+// checkClientRateLimit reports whether a request from clientIP should be
+// served now. If not, retryAfter is how long the client should wait before
+// trying again.
+func (c *ServerConfig) checkClientRateLimit(clientIP string) (
+	ok bool, retryAfter time.Duration, err error) {
+	if c.PerClientRateLimit == 0 {
+		return true, 0, nil
+	}
+	c.clientRateLimitersOnce.Do(func() {
+		c.clientRateLimiters, c.clientRateLimitersInitErr =
+			newClientRateLimiters(c.PerClientRateLimit, c.PerClientBurst)
+	})
+	if c.clientRateLimitersInitErr != nil {
+		return false, 0, c.clientRateLimitersInitErr
+	}
+	ok, retryAfter = c.clientRateLimiters.allow(clientIP)
+	return ok, retryAfter, nil
+}
+
 const fsCacheSize = 2 << 15
 
 // Server handles incoming HTTP requests by creating a Root for each host and
@@ -134,7 +168,8 @@ func (s *Server) getSite(ctx context.Context, root Root) (st *site, err error) {
 			fsShutdown()
 		}
 	}()
-	st = makeSite(fs, tlfID, fsShutdown, root)
+	st = makeSite(fs, tlfID, fsShutdown, root,
+		newTeamMembershipChecker(s.kbfsConfig, root))
 	s.siteCache.Add(root, st)
 	added = true
 	return st, nil
@@ -166,7 +201,11 @@ func (s *Server) handleError(w http.ResponseWriter, err error) {
 		http.Error(w, err.Error(), http.StatusPreconditionFailed)
 		return
 	case config.ErrDuplicateAccessControlPath, config.ErrInvalidPermissions,
-		config.ErrInvalidVersion, config.ErrUndefinedUsername:
+		config.ErrInvalidVersion, config.ErrUndefinedUsername,
+		config.ErrInvalidRateLimit, config.ErrInvalidSigningSecret,
+		config.InvalidPasswordHash, config.ErrUndefinedGroup,
+		config.ErrInvalidTeamRole, config.ErrInvalidListingTemplate,
+		ErrSigningSecretInPublicTLF:
 		http.Error(w, "invalid .kbp_config", http.StatusPreconditionFailed)
 		return
 	default:
@@ -208,6 +247,36 @@ func (s *Server) handleUnauthorized(w http.ResponseWriter,
 	}
 }
 
+const bearerAuthPrefix = "Bearer "
+
+// authenticate checks credentials carried by r against cfg, and returns the
+// authenticated username, or nil if r is not authenticated. Accepted
+// credentials are HTTP Basic auth, bearer tokens in the Authorization
+// header, and signed URLs.
+func (s *Server) authenticate(r *http.Request, cfg config.Config) *string {
+	if user, pass, ok := r.BasicAuth(); ok {
+		if cfg.Authenticate(r.Context(), user, pass) {
+			return &user
+		}
+		return nil
+	}
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(
+		auth, bearerAuthPrefix) {
+		if user, ok := cfg.AuthenticateToken(
+			r.Context(), auth[len(bearerAuthPrefix):]); ok {
+			return &user
+		}
+		return nil
+	}
+	if query := r.URL.Query(); len(query.Get(
+		config.SignedURLSignatureParam)) > 0 {
+		if user, ok := cfg.AuthenticateSignedURL(r.URL.Path, query); ok {
+			return &user
+		}
+	}
+	return nil
+}
+
 func (s *Server) isDirWithNoIndexHTML(
 	realFS *libfs.FS, requestPath string) (bool, error) {
 	fi, err := realFS.Stat(strings.Trim(path.Clean(requestPath), "/"))
@@ -301,7 +370,8 @@ type ServedRequestInfo struct {
 	Host string
 	// Proto is the `Proto` field of http.Request.
 	Proto string
-	// Authenticated means the client set WWW-Authenticate in this request and
+	// Authenticated means the client provided credentials in this request,
+	// either through HTTP Basic auth, a bearer token, or a signed URL, and
 	// authentication using the given credentials has succeeded. It doesn't
 	// necessarily indicate that the authentication is required for this
 	// particular request.
@@ -321,6 +391,12 @@ type ServedRequestInfo struct {
 	// InvalidConfig is set to true if user has a config for the site being
 	// requested, but it's invalid.
 	InvalidConfig bool
+	// ClientRateLimited is set to true if the request was rejected because
+	// the client has exceeded the per-client rate limit.
+	ClientRateLimited bool
+	// SiteRateLimited is set to true if the request was rejected because the
+	// site has exceeded the rate limit defined in its config.
+	SiteRateLimited bool
 }
 
 type statusCodePeekingResponseWriter struct {
@@ -363,6 +439,8 @@ func (s *Server) logRequest(sri *ServedRequestInfo, requestPath string) {
 		zap.Bool("authenticated", sri.Authenticated),
 		zap.Bool("cloning_shown", sri.CloningShown),
 		zap.Bool("invalid_config", sri.InvalidConfig),
+		zap.Bool("client_rate_limited", sri.ClientRateLimited),
+		zap.Bool("site_rate_limited", sri.SiteRateLimited),
 	)
 }
 
@@ -397,6 +475,17 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ok, retryAfter, err := s.config.checkClientRateLimit(getClientIP(r))
+	if err != nil {
+		s.handleError(w, err)
+		return
+	}
+	if !ok {
+		sri.ClientRateLimited = true
+		s.handleRateLimited(w, retryAfter)
+		return
+	}
+
 	s.setCommonResponseHeaders(w)
 
 	// Don't serve the config file itself.
@@ -426,6 +515,14 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	sri.TlfID = st.tlfID
 
+	// Check the site's rate limit before doing any work on its FS.
+	ok, retryAfter = st.checkRateLimit()
+	if !ok {
+		sri.SiteRateLimited = true
+		s.handleRateLimited(w, retryAfter)
+		return
+	}
+
 	realFS, err := st.fs.Use()
 	if err != nil {
 		s.handleError(w, err)
@@ -460,14 +557,16 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var username *string
-	user, pass, ok := r.BasicAuth()
-	if ok && cfg.Authenticate(r.Context(), user, pass) {
-		sri.Authenticated = true
-		username = &user
+	if err = st.updateRateLimit(cfg); err != nil {
+		s.handleError(w, err)
+		return
 	}
+
+	username := s.authenticate(r, cfg)
+	sri.Authenticated = username != nil
 	canRead, canList, possibleRead, possibleList,
-		realm, err := cfg.GetPermissions(r.URL.Path, username)
+		realm, err := cfg.GetPermissionsWithTeamMembership(
+		ctx, r.URL.Path, username, st.teamChecker)
 	if err != nil {
 		s.handleError(w, err)
 		return
@@ -493,6 +592,13 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if isListing {
+		if err = s.serveListing(w, r, st, realFS, cfg); err != nil {
+			s.handleError(w, err)
+		}
+		return
+	}
+
 	http.FileServer(realFS.ToHTTPFileSystem(ctx)).ServeHTTP(w, r)
 }
 
@@ -566,10 +672,19 @@ func ListenAndServe(ctx context.Context,
 
 	libmime.Patch(nil)
 
+	if config.PerClientRateLimit != 0 {
+		if err = checkClientBurst(config.PerClientBurst); err != nil {
+			return err
+		}
+	}
+
 	server := &Server{
 		config:     config,
 		kbfsConfig: kbfsConfig,
-		rootLoader: DNSRootLoader{log: config.Logger},
+		rootLoader: config.RootLoader,
+	}
+	if server.rootLoader == nil {
+		server.rootLoader = DNSRootLoader{log: config.Logger}
 	}
 	server.siteCache, err = lru.NewWithEvict(fsCacheSize, server.siteCacheEvict)
 	if err != nil {
@@ -5,14 +5,19 @@
 package libpages
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
 
 	lru "github.com/hashicorp/golang-lru"
+	"github.com/keybase/client/go/protocol/keybase1"
 	"github.com/keybase/kbfs/ioutil"
+	"github.com/keybase/kbfs/libfs"
 	"github.com/keybase/kbfs/libkbfs"
+	"github.com/keybase/kbfs/libpages/config"
+	"github.com/keybase/kbfs/tlf"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
@@ -75,6 +80,74 @@ func TestServerDefault(t *testing.T) {
 	server.ServeHTTP(w, httptest.NewRequest("GET", "/non-existent", nil))
 	require.Equal(t, http.StatusNotFound, w.Code)
 
+	// The root has no index.html, so a listing is served.
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept", "application/json")
+	server.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
 	// TODO: if we ever add a test that involves bcrypt, remember to swap
 	// DefaultCost out and use MinCost.
 }
+
+func writeSigningSecretConfigForTest(t *testing.T,
+	kbfsConfig libkbfs.Config, tlfName string, tlfType tlf.Type) {
+	ctx := libkbfs.BackgroundContextWithCancellationDelayer()
+	defer libkbfs.CleanupCancellationDelayer(ctx)
+	h, err := libkbfs.GetHandleFromFolderNameAndType(
+		ctx, kbfsConfig.KBPKI(), kbfsConfig.MDOps(), tlfName, tlfType)
+	require.NoError(t, err)
+	fs, err := libfs.NewFS(
+		ctx, kbfsConfig, h, "", "", keybase1.MDPriorityNormal)
+	require.NoError(t, err)
+
+	cfg := config.DefaultV1()
+	cfg.SigningSecret, err = config.GenerateSigningSecret()
+	require.NoError(t, err)
+	f, err := fs.Create(config.DefaultConfigFilename)
+	require.NoError(t, err)
+	err = cfg.Encode(f, true)
+	require.NoError(t, err)
+	err = f.Close()
+	require.NoError(t, err)
+	err = fs.SyncAll()
+	require.NoError(t, err)
+}
+
+func TestServerSigningSecretInPublicTLF(t *testing.T) {
+	kbfsConfig := libkbfs.MakeTestConfigOrBustLoggedInWithMode(
+		t, 0, libkbfs.InitSingleOp, "bot", "user")
+	defer libkbfs.CheckConfigAndShutdown(context.Background(), t, kbfsConfig)
+
+	writeSigningSecretConfigForTest(t, kbfsConfig, "bot", tlf.Public)
+	writeSigningSecretConfigForTest(t, kbfsConfig, "bot", tlf.Private)
+
+	logger, err := zap.NewDevelopment()
+	require.NoError(t, err)
+	server := Server{
+		kbfsConfig: kbfsConfig,
+		config: &ServerConfig{
+			Logger: logger,
+		},
+		rootLoader: TestRootLoader{
+			"public.example.com":  "/keybase/public/bot",
+			"private.example.com": "/keybase/private/bot",
+		},
+	}
+	server.siteCache, err = lru.NewWithEvict(fsCacheSize, server.siteCacheEvict)
+	require.NoError(t, err)
+
+	// Anyone can read the signing secret in a public TLF, so such a config
+	// is rejected.
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w,
+		httptest.NewRequest("GET", "http://public.example.com/", nil))
+	require.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w,
+		httptest.NewRequest("GET", "http://private.example.com/", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+}
@@ -5,35 +5,62 @@
 package libpages
 
 import (
+	"html/template"
 	"os"
 	"sync"
 	"time"
 
 	"github.com/keybase/kbfs/libpages/config"
 	"github.com/keybase/kbfs/tlf"
+	"golang.org/x/time/rate"
 )
 
 const configCacheTime = 2 * time.Minute
 
+// ErrSigningSecretInPublicTLF is returned when the config of a site in a
+// public TLF has a signing secret. Anyone can read the config of such a site,
+// so anyone could mint signed URLs with the secret.
+type ErrSigningSecretInPublicTLF struct{}
+
+// Error implements the error interface.
+func (ErrSigningSecretInPublicTLF) Error() string {
+	return "signing secret is not allowed in the config of a public TLF"
+}
+
 type site struct {
 	// fs should never be changed once it's constructed.
 	fs         CacheableFS
 	tlfID      tlf.ID
 	fsShutdown func()
 	root       Root
+	// teamChecker is nil if the site is not in a team TLF.
+	teamChecker config.TeamMembershipChecker
 
 	// TODO: replace this with a notification mechanism from the FBO.
 	cachedConfigLock      sync.RWMutex
 	cachedConfig          config.Config
 	cachedConfigExpiresAt time.Time
+
+	// The listing template is cached like the config.
+	listingTemplateLock      sync.Mutex
+	listingTemplatePath      string
+	listingTemplate          *template.Template
+	listingTemplateExpiresAt time.Time
+
+	// rateLimiter is created lazily, and kept across config refreshes as long
+	// as the rate limit in the site config doesn't change.
+	rateLimiterLock sync.Mutex
+	rateLimiter     *rate.Limiter
 }
 
-func makeSite(fs CacheableFS, tlfID tlf.ID, fsShutdown func(), root Root) *site {
+func makeSite(fs CacheableFS, tlfID tlf.ID, fsShutdown func(), root Root,
+	teamChecker config.TeamMembershipChecker) *site {
 	return &site{
-		fs:         fs,
-		tlfID:      tlfID,
-		fsShutdown: fsShutdown,
-		root:       root,
+		fs:          fs,
+		tlfID:       tlfID,
+		fsShutdown:  fsShutdown,
+		root:        root,
+		teamChecker: teamChecker,
 	}
 }
 
@@ -76,6 +103,9 @@ func (s *site) fetchConfigAndRefreshCache() (cfg config.Config, err error) {
 	default:
 		return nil, err
 	}
+	if s.root.TlfType == tlf.Public && cfg.HasSigningSecret() {
+		return nil, ErrSigningSecretInPublicTLF{}
+	}
 
 	s.cachedConfig = cfg
 	s.cachedConfigExpiresAt = time.Now().Add(configCacheTime)
@@ -90,3 +120,39 @@ func (s *site) getConfig(forceRefresh bool) (cfg config.Config, err error) {
 	}
 	return s.fetchConfigAndRefreshCache()
 }
+
+// checkRateLimit reports whether a request to the site should be served now,
+// based on the rate limit defined in the config most recently loaded for the
+// site. If not, retryAfter is how long the client should wait before trying
+// again. It doesn't touch the site's FS, so it can be done before any other
+// work for the request. Before the first config is loaded, or if the config
+// doesn't define a rate limit, every request is allowed.
+func (s *site) checkRateLimit() (ok bool, retryAfter time.Duration) {
+	s.rateLimiterLock.Lock()
+	defer s.rateLimiterLock.Unlock()
+	if s.rateLimiter == nil {
+		return true, 0
+	}
+	return reserveFromLimiter(s.rateLimiter)
+}
+
+// updateRateLimit makes future checkRateLimit calls use the rate limit
+// defined in cfg.
+func (s *site) updateRateLimit(cfg config.Config) error {
+	limit, burst, err := cfg.GetRateLimit()
+	if err != nil {
+		return err
+	}
+
+	s.rateLimiterLock.Lock()
+	defer s.rateLimiterLock.Unlock()
+	switch {
+	case limit == rate.Inf:
+		s.rateLimiter = nil
+	case s.rateLimiter == nil || s.rateLimiter.Burst() != burst:
+		s.rateLimiter = rate.NewLimiter(limit, burst)
+	case s.rateLimiter.Limit() != limit:
+		s.rateLimiter.SetLimit(limit)
+	}
+	return nil
+}
@@ -22,6 +22,8 @@ type stathatReporter struct {
 	statNameAuthenticated string
 	statNameCloningShown  string
 	statNameInvalidConfig string
+	statNameClientLimited string
+	statNameSiteLimited   string
 	statPrefixProto       string
 	statPrefixStatus      string
 	statPrefixTlfType     string
@@ -103,6 +105,8 @@ func NewStathatReporter(logger *zap.Logger, prefix string, ezKey string,
 		statNameAuthenticated: prefix + "authenticated",
 		statNameCloningShown:  prefix + "cloningShown",
 		statNameInvalidConfig: prefix + "invalidConfig",
+		statNameClientLimited: prefix + "clientRateLimited",
+		statNameSiteLimited:   prefix + "siteRateLimited",
 		statPrefixProto:       prefix + "proto:",
 		statPrefixStatus:      prefix + "status:",
 		statPrefixTlfType:     prefix + "tlfType:",
@@ -136,6 +140,12 @@ func (s *stathatReporter) ReportServedRequest(sri *ServedRequestInfo) {
 	if sri.InvalidConfig {
 		s.postCountOneOrLog(s.statNameInvalidConfig)
 	}
+	if sri.ClientRateLimited {
+		s.postCountOneOrLog(s.statNameClientLimited)
+	}
+	if sri.SiteRateLimited {
+		s.postCountOneOrLog(s.statNameSiteLimited)
+	}
 	s.postCountOneOrLog(s.statPrefixTlfType + sri.TlfType.String())
 	s.postCountOneOrLog(s.statPrefixRootType + sri.RootType.String())
 
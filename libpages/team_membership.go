@@ -0,0 +1,176 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libpages
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/keybase/client/go/protocol/keybase1"
+	"github.com/keybase/kbfs/libkbfs"
+	"github.com/keybase/kbfs/libpages/config"
+	"github.com/keybase/kbfs/tlf"
+)
+
+const teamMembershipCacheTime = configCacheTime
+
+// teamMembershipUnresolvedCacheTime is how long a username that fails to
+// resolve is cached as not being a member. It's shorter than
+// teamMembershipCacheTime since the failure might be transient.
+const teamMembershipUnresolvedCacheTime = 10 * time.Second
+
+type teamMembershipCacheKey struct {
+	username string
+	writer   bool
+}
+
+type teamMembershipCacheEntry struct {
+	isMember  bool
+	expiresAt time.Time
+}
+
+// teamMembershipLookup is a membership lookup in progress. Concurrent checks
+// of the same key wait for it instead of doing their own lookup.
+type teamMembershipLookup struct {
+	done     chan struct{}
+	isMember bool
+	err      error
+}
+
+// kbpkiTeamMembershipChecker implements config.TeamMembershipChecker for the
+// team that owns a site, using KBPKI. Results are cached for a short time so
+// that we don't need to talk to the service on every request.
+type kbpkiTeamMembershipChecker struct {
+	kbfsConfig libkbfs.Config
+	teamName   string
+
+	// lock protects the fields below. It's never held while talking to
+	// KBPKI, so that a slow lookup doesn't hold up other checks.
+	lock     sync.Mutex
+	teamID   keybase1.TeamID
+	cache    map[teamMembershipCacheKey]teamMembershipCacheEntry
+	inflight map[teamMembershipCacheKey]*teamMembershipLookup
+}
+
+var _ config.TeamMembershipChecker = (*kbpkiTeamMembershipChecker)(nil)
+
+// newTeamMembershipChecker returns a config.TeamMembershipChecker for root,
+// or nil if root is not in a team TLF.
+func newTeamMembershipChecker(
+	kbfsConfig libkbfs.Config, root Root) config.TeamMembershipChecker {
+	if root.TlfType != tlf.SingleTeam {
+		return nil
+	}
+	return &kbpkiTeamMembershipChecker{
+		kbfsConfig: kbfsConfig,
+		teamName:   root.TlfNameUnparsed,
+		cache:      make(map[teamMembershipCacheKey]teamMembershipCacheEntry),
+		inflight:   make(map[teamMembershipCacheKey]*teamMembershipLookup),
+	}
+}
+
+func (c *kbpkiTeamMembershipChecker) getTeamID(
+	ctx context.Context) (keybase1.TeamID, error) {
+	c.lock.Lock()
+	teamID := c.teamID
+	c.lock.Unlock()
+	if teamID.Exists() {
+		return teamID, nil
+	}
+	handle, err := libkbfs.GetHandleFromFolderNameAndType(ctx,
+		c.kbfsConfig.KBPKI(), c.kbfsConfig.MDOps(), c.teamName, tlf.SingleTeam)
+	if err != nil {
+		return "", err
+	}
+	teamID, err = handle.FirstResolvedWriter().AsTeam()
+	if err != nil {
+		return "", err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.teamID = teamID
+	return teamID, nil
+}
+
+// isTeamMember returns whether username is a member of the team, and how
+// long that result can be cached for. It must be called without c.lock
+// held.
+func (c *kbpkiTeamMembershipChecker) isTeamMember(
+	ctx context.Context, username string, writer bool) (
+	isMember bool, cacheTime time.Duration, err error) {
+	teamID, err := c.getTeamID(ctx)
+	if err != nil {
+		return false, 0, err
+	}
+	_, id, err := c.kbfsConfig.KBPKI().Resolve(ctx, username)
+	if err != nil {
+		// Whatever the reason, a user we can't resolve can't be shown to
+		// be a member.
+		return false, teamMembershipUnresolvedCacheTime, nil
+	}
+	uid, err := id.AsUser()
+	if err != nil {
+		// It's a team name, not a username.
+		return false, teamMembershipCacheTime, nil
+	}
+	writers, readers, err := c.kbfsConfig.KBPKI().ListResolvedTeamMembers(
+		ctx, teamID)
+	if err != nil {
+		return false, 0, err
+	}
+	for _, w := range writers {
+		if w == uid {
+			return true, teamMembershipCacheTime, nil
+		}
+	}
+	if writer {
+		return false, teamMembershipCacheTime, nil
+	}
+	for _, r := range readers {
+		if r == uid {
+			return true, teamMembershipCacheTime, nil
+		}
+	}
+	return false, teamMembershipCacheTime, nil
+}
+
+// IsTeamMember implements the config.TeamMembershipChecker interface.
+func (c *kbpkiTeamMembershipChecker) IsTeamMember(
+	ctx context.Context, username string, writer bool) (bool, error) {
+	key := teamMembershipCacheKey{username: username, writer: writer}
+	c.lock.Lock()
+	if entry, ok := c.cache[key]; ok && entry.expiresAt.After(time.Now()) {
+		c.lock.Unlock()
+		return entry.isMember, nil
+	}
+	if lookup, ok := c.inflight[key]; ok {
+		c.lock.Unlock()
+		select {
+		case <-lookup.done:
+			return lookup.isMember, lookup.err
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+	lookup := &teamMembershipLookup{done: make(chan struct{})}
+	c.inflight[key] = lookup
+	c.lock.Unlock()
+
+	isMember, cacheTime, err := c.isTeamMember(ctx, username, writer)
+
+	c.lock.Lock()
+	delete(c.inflight, key)
+	if err == nil {
+		c.cache[key] = teamMembershipCacheEntry{
+			isMember:  isMember,
+			expiresAt: time.Now().Add(cacheTime),
+		}
+	}
+	c.lock.Unlock()
+	lookup.isMember, lookup.err = isMember, err
+	close(lookup.done)
+	return isMember, err
+}
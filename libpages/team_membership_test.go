@@ -0,0 +1,152 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package libpages
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/keybase/client/go/libkb"
+	"github.com/keybase/client/go/protocol/keybase1"
+	"github.com/keybase/kbfs/libkbfs"
+	"github.com/keybase/kbfs/tlf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTeamMembershipChecker(t *testing.T) {
+	kbfsConfig := libkbfs.MakeTestConfigOrBustLoggedInWithMode(
+		t, 0, libkbfs.InitSingleOp, "bot", "user")
+	ctx := context.Background()
+	defer libkbfs.CheckConfigAndShutdown(ctx, t, kbfsConfig)
+
+	teamInfos := libkbfs.AddEmptyTeamsForTestOrBust(t, kbfsConfig, "t1")
+	session, err := kbfsConfig.KBPKI().GetCurrentSession(ctx)
+	require.NoError(t, err)
+	libkbfs.AddTeamWriterForTestOrBust(
+		t, kbfsConfig, teamInfos[0].TID, session.UID)
+
+	checker := newTeamMembershipChecker(kbfsConfig, Root{
+		Type:            KBFSRoot,
+		TlfType:         tlf.SingleTeam,
+		TlfNameUnparsed: "t1",
+	}).(*kbpkiTeamMembershipChecker)
+
+	isMember, err := checker.IsTeamMember(ctx, "bot", true)
+	require.NoError(t, err)
+	require.True(t, isMember)
+	isMember, err = checker.IsTeamMember(ctx, "user", false)
+	require.NoError(t, err)
+	require.False(t, isMember)
+
+	t.Log("A username that doesn't resolve isn't a member, and that's " +
+		"cached for a shorter time.")
+	start := time.Now()
+	isMember, err = checker.IsTeamMember(ctx, "no_such_user", false)
+	require.NoError(t, err)
+	require.False(t, isMember)
+	entry, ok := checker.cache[teamMembershipCacheKey{
+		username: "no_such_user", writer: false}]
+	require.True(t, ok)
+	require.False(t, entry.isMember)
+	require.True(t, entry.expiresAt.Before(
+		start.Add(teamMembershipCacheTime)))
+	require.False(t, entry.expiresAt.Before(
+		start.Add(teamMembershipUnresolvedCacheTime)))
+}
+
+// blockingResolveKBPKI blocks resolving blockedName until unblock is closed.
+type blockingResolveKBPKI struct {
+	libkbfs.KBPKI
+	blockedName string
+	resolves    int32
+	started     chan struct{}
+	unblock     chan struct{}
+}
+
+func (k *blockingResolveKBPKI) Resolve(ctx context.Context, assertion string) (
+	libkb.NormalizedUsername, keybase1.UserOrTeamID, error) {
+	if assertion == k.blockedName {
+		if atomic.AddInt32(&k.resolves, 1) == 1 {
+			close(k.started)
+		}
+		select {
+		case <-k.unblock:
+		case <-ctx.Done():
+			return "", "", ctx.Err()
+		}
+	}
+	return k.KBPKI.Resolve(ctx, assertion)
+}
+
+func TestTeamMembershipCheckerSlowLookup(t *testing.T) {
+	kbfsConfig := libkbfs.MakeTestConfigOrBustLoggedInWithMode(
+		t, 0, libkbfs.InitSingleOp, "bot", "user")
+	ctx := context.Background()
+	defer libkbfs.CheckConfigAndShutdown(ctx, t, kbfsConfig)
+
+	teamInfos := libkbfs.AddEmptyTeamsForTestOrBust(t, kbfsConfig, "t1")
+	session, err := kbfsConfig.KBPKI().GetCurrentSession(ctx)
+	require.NoError(t, err)
+	libkbfs.AddTeamWriterForTestOrBust(
+		t, kbfsConfig, teamInfos[0].TID, session.UID)
+
+	checker := newTeamMembershipChecker(kbfsConfig, Root{
+		Type:            KBFSRoot,
+		TlfType:         tlf.SingleTeam,
+		TlfNameUnparsed: "t1",
+	}).(*kbpkiTeamMembershipChecker)
+	isMember, err := checker.IsTeamMember(ctx, "bot", true)
+	require.NoError(t, err)
+	require.True(t, isMember)
+
+	kbpki := &blockingResolveKBPKI{
+		KBPKI:       kbfsConfig.KBPKI(),
+		blockedName: "user",
+		started:     make(chan struct{}),
+		unblock:     make(chan struct{}),
+	}
+	kbfsConfig.SetKBPKI(kbpki)
+	defer kbfsConfig.SetKBPKI(kbpki.KBPKI)
+
+	// Two concurrent checks of the same user share one lookup.
+	errCh := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			isMember, err := checker.IsTeamMember(ctx, "user", false)
+			if err == nil && isMember {
+				err = errors.New("user shouldn't be a member")
+			}
+			errCh <- err
+		}()
+	}
+	select {
+	case <-kbpki.started:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timed out waiting for the lookup to start")
+	}
+
+	// While that lookup is stuck, a cached result is still returned
+	// right away.
+	cachedCh := make(chan error, 1)
+	go func() {
+		_, err := checker.IsTeamMember(ctx, "bot", true)
+		cachedCh <- err
+	}()
+	select {
+	case err := <-cachedCh:
+		require.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("A cache hit was blocked by a slow lookup")
+	}
+
+	close(kbpki.unblock)
+	for i := 0; i < 2; i++ {
+		require.NoError(t, <-errCh)
+	}
+	require.Equal(t, int32(1), atomic.LoadInt32(&kbpki.resolves))
+}
@@ -320,6 +320,37 @@ func TestCrUnmergedWriteToRemovedFile(t *testing.T) {
 	)
 }
 
+// alice writes to and removes a file that bob already removed, while
+// bob makes an unrelated change
+func TestCrUnmergedWriteAndRemoveOfRemovedFile(t *testing.T) {
+	test(t,
+		users("alice", "bob"),
+		as(alice,
+			mkfile("a/b", "hello"),
+		),
+		as(bob,
+			rm("a/b"),
+		),
+		as(alice,
+			disableUpdates(),
+		),
+		as(bob,
+			mkfile("c/d", "world"),
+		),
+		as(alice, noSync(),
+			write("a/b", "goodbye"),
+			rm("a/b"),
+			reenableUpdates(),
+			lsdir("a/", m{}),
+			read("c/d", "world"),
+		),
+		as(bob,
+			lsdir("a/", m{}),
+			read("c/d", "world"),
+		),
+	)
+}
+
 // bob writes to a file while alice renamed then removes it
 func TestCrUnmergedWriteToRenamedAndRemovedFile(t *testing.T) {
 	test(t,
@@ -1076,3 +1107,110 @@ func TestCrDoubleMergedDeleteAndRecreate(t *testing.T) {
 		),
 	)
 }
+
+// bob truncates and renames files while unstaged, one of them over
+// a file that was already renamed over.  Found by TestRandomOps
+// (seed 65).
+func TestCrUnmergedTruncateRenameOverRenamedFile(t *testing.T) {
+	t.Skip("CR leaves the folder in an inconsistent state on shutdown")
+	test(t,
+		blockSize(20), users("alice", "bob"),
+		as(bob,
+			truncate("bob/d/g", 14),
+		),
+		as(bob,
+			disableUpdates(),
+		),
+		as(alice,
+			pwriteBSSync("alice/d/h", []byte("g"), 2, false),
+		),
+		as(bob, noSync(),
+			truncate("bob/d/f", 7),
+			rename("bob/d/f", "bob/h"),
+			rename("bob/d/g", "bob/d/h"),
+			readAll("bob/d/h", string(make([]byte, 14))),
+			rename("bob/h", "bob/d/h"),
+			readAll("bob/d/h", string(make([]byte, 7))),
+			notExists("bob/h"),
+			reenableUpdates(),
+			lsdir("bob", m{"d$": "DIR"}),
+			lsdir("bob/d", m{"h$": "FILE"}),
+			readAll("alice/d/h", "\x00\x00g"),
+			readAll("bob/d/h", string(make([]byte, 7))),
+		),
+		as(alice,
+			lsdir("bob", m{"d$": "DIR"}),
+			lsdir("bob/d", m{"h$": "FILE"}),
+			readAll("alice/d/h", "\x00\x00g"),
+			readAll("bob/d/h", string(make([]byte, 7))),
+		),
+	)
+}
+
+// bob renames a file into a new directory and extends it, and makes
+// a new file next to it, while unstaged.  Found by TestRandomOps
+// (seed 101).
+func TestCrUnmergedRenameIntoNewDirAndTruncate(t *testing.T) {
+	t.Skip("CR rebuilds the new directory from its original block " +
+		"and drops the new file")
+	test(t,
+		blockSize(20), users("alice", "bob"),
+		as(alice,
+			truncate("bob/g", 12),
+		),
+		as(bob,
+			disableUpdates(),
+		),
+		as(alice,
+			pwriteBSSync("alice/d/h", []byte("u"), 9, true),
+		),
+		as(bob, noSync(),
+			rename("bob/g", "bob/d/f"),
+			truncate("bob/d/f", 67),
+			truncate("bob/d/h", 11),
+			reenableUpdates(),
+			lsdir("bob", m{"d$": "DIR"}),
+			lsdir("bob/d", m{"f$": "FILE", "h$": "FILE"}),
+			readAll("alice/d/h", "\x00\x00\x00\x00\x00\x00\x00\x00\x00u"),
+			readAll("bob/d/f", string(make([]byte, 67))),
+			readAll("bob/d/h", string(make([]byte, 11))),
+		),
+		as(alice,
+			lsdir("bob", m{"d$": "DIR"}),
+			lsdir("bob/d", m{"f$": "FILE", "h$": "FILE"}),
+			readAll("alice/d/h", "\x00\x00\x00\x00\x00\x00\x00\x00\x00u"),
+			readAll("bob/d/f", string(make([]byte, 67))),
+			readAll("bob/d/h", string(make([]byte, 11))),
+		),
+	)
+}
+
+// alice writes a multi-block file and truncates it inside its last
+// block without syncing, while unstaged after removing a file.
+// Found by TestRandomOps (seed 106).
+func TestCrUnmergedWriteTruncateAfterRemove(t *testing.T) {
+	const data = "jpxpdghxxnnzzxqsccyqbirtcpedfqwvrstspmiotcyhshmacodtd"
+	test(t,
+		blockSize(20), users("alice", "bob"),
+		as(bob,
+			truncate("bob/f", 24),
+		),
+		as(alice,
+			rm("bob/f"),
+		),
+		as(alice,
+			disableUpdates(),
+		),
+		as(alice, noSync(),
+			pwriteBSSync("alice/d/g", []byte(data), 16, false),
+			truncate("alice/d/g", 33),
+			reenableUpdates(),
+			lsdir("bob", m{}),
+			readAll("alice/d/g", string(make([]byte, 16))+data[:17]),
+		),
+		as(bob,
+			lsdir("bob", m{}),
+			readAll("alice/d/g", string(make([]byte, 16))+data[:17]),
+		),
+	)
+}
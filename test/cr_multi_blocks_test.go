@@ -265,3 +265,90 @@ func TestCrUnmergedMoveAndDeleteMultiblockFile(t *testing.T) {
 		),
 	)
 }
+
+// alice extends a multi-block file to a new level of indirection, and
+// then writes into a hole in it, while unmerged.
+func TestCrUnmergedExtendAndWriteHoleMultiblockFile(t *testing.T) {
+	test(t,
+		blockSize(20), users("alice", "bob"),
+		as(alice,
+			pwriteBS("a/b", []byte("m"), 95),
+		),
+		as(alice,
+			disableUpdates(),
+		),
+		as(bob,
+			write("c", "foo"),
+		),
+		as(alice, noSync(),
+			truncate("a/b", 114),
+			pwriteBSSync("a/b", []byte("h"), 42, false),
+			reenableUpdates(),
+			preadBS("a/b", []byte("h"), 42),
+			preadBS("a/b", []byte("m"), 95),
+			read("c", "foo"),
+		),
+		as(bob,
+			preadBS("a/b", []byte("h"), 42),
+			preadBS("a/b", []byte("m"), 95),
+			read("c", "foo"),
+		),
+	)
+}
+
+// bob writes into a multi-block file, and then deletes it.
+func TestCrUnmergedWriteAndDeleteMultiblockFile(t *testing.T) {
+	test(t,
+		blockSize(20), users("alice", "bob"),
+		as(alice,
+			write("a/b", ntimesString(15, "0123456789")),
+		),
+		as(bob,
+			disableUpdates(),
+		),
+		as(alice,
+			write("foo", "bar"),
+		),
+		as(bob, noSync(),
+			pwriteBSSync("a/b", []byte("xyz"), 60, true),
+			rm("a/b"),
+			reenableUpdates(),
+			lsdir("a/", m{}),
+			read("foo", "bar"),
+		),
+		as(alice,
+			lsdir("a/", m{}),
+			read("foo", "bar"),
+		),
+	)
+}
+
+// bob creates a multi-block file and a small file, renames the
+// multi-block file, and then renames the small file over it.
+func TestCrUnmergedRenameOverNewMultiblockFile(t *testing.T) {
+	test(t,
+		blockSize(20), users("alice", "bob"),
+		as(bob,
+			disableUpdates(),
+		),
+		as(alice,
+			write("foo", "bar"),
+		),
+		as(bob, noSync(),
+			write("a/b", "hello"),
+			write("a/c", ntimesString(15, "0123456789")),
+			rename("a/b", "d"),
+			rename("a/c", "a/e"),
+			rename("d", "a/e"),
+			reenableUpdates(),
+			lsdir("a/", m{"e": "FILE"}),
+			read("a/e", "hello"),
+			read("foo", "bar"),
+		),
+		as(alice,
+			lsdir("a/", m{"e": "FILE"}),
+			read("a/e", "hello"),
+			read("foo", "bar"),
+		),
+	)
+}
@@ -42,6 +42,7 @@ type opt struct {
 	tlfType                  tlf.Type
 	users                    map[libkb.NormalizedUsername]User
 	stallers                 map[libkb.NormalizedUsername]*libkbfs.NaïveStaller
+	faultInjectors           map[libkb.NormalizedUsername]*libkbfs.FaultInjector
 	tb                       testing.TB
 	initOnce                 sync.Once
 	engine                   Engine
@@ -149,6 +150,12 @@ func (el errorList) Error() string {
 }
 
 func (o *opt) close() {
+	// Put the original servers back before shutting down, so that
+	// the shutdown checks see them.
+	for _, fi := range o.faultInjectors {
+		fi.UndoInjectFaults()
+	}
+
 	var el []error
 	// Make sure Shutdown is called properly for every user, even
 	// if any of the calls fail.
@@ -175,6 +182,7 @@ func (o *opt) runInitOnce() {
 			o.blockChangeSize, o.batchSize, o.bwKBps, o.timeout, o.usernames,
 			o.teams, o.implicitTeams, o.clock, o.journal)
 		o.stallers = o.makeStallers()
+		o.faultInjectors = o.makeFaultInjectors()
 	})
 }
 
@@ -187,6 +195,16 @@ func (o *opt) makeStallers() (
 	return stallers
 }
 
+func (o *opt) makeFaultInjectors() (
+	faultInjectors map[libkb.NormalizedUsername]*libkbfs.FaultInjector) {
+	faultInjectors = make(
+		map[libkb.NormalizedUsername]*libkbfs.FaultInjector)
+	for username, user := range o.users {
+		faultInjectors[username] = o.engine.MakeFaultInjector(user)
+	}
+	return faultInjectors
+}
+
 func ntimesString(n int, s string) string {
 	var bs bytes.Buffer
 	for i := 0; i < n; i++ {
@@ -401,6 +419,7 @@ type ctx struct {
 	rootNode   Node
 	noSyncInit bool
 	staller    *libkbfs.NaïveStaller
+	faults     *libkbfs.FaultInjector
 }
 
 func runFileOpHelper(c *ctx, fop fileOp) (string, error) {
@@ -479,6 +498,7 @@ func as(user username, fops ...fileOp) optionOp {
 			user:     o.users[u],
 			username: u,
 			staller:  o.stallers[u],
+			faults:   o.faultInjectors[u],
 		}
 
 		for _, fop := range fops {
@@ -584,6 +604,27 @@ func preadBS(name string, contents []byte, at int64) fileOp {
 		name, len(contents), at)}
 }
 
+// readAll is like read, but also checks that the file doesn't have
+// anything after the given contents.
+func readAll(name string, contents string) fileOp {
+	return fileOp{func(c *ctx) error {
+		file, _, err := c.getNode(name, noCreate, resolveAllSyms)
+		if err != nil {
+			return err
+		}
+		bs := make([]byte, len(contents)+1)
+		l, err := c.engine.ReadFile(c.user, file, 0, bs)
+		if err != nil {
+			return err
+		}
+		bs = bs[:l]
+		if string(bs) != contents {
+			return fmt.Errorf("Read (name=%s) got=%d, expected=%d bytes: contents=%q differ from expected=%q", name, len(bs), len(contents), bs, contents)
+		}
+		return nil
+	}, Defaults, fmt.Sprintf("readAll(%s, %d bytes)", name, len(contents))}
+}
+
 func exists(filename string) fileOp {
 	return fileOp{func(c *ctx) error {
 		_, _, err := c.getNode(filename, noCreate, resolveAllSyms)
@@ -870,6 +911,46 @@ func undoStallOnMDResolveBranch() fileOp {
 	}, IsInit, "undoStallOnMDResolveBranch()"}
 }
 
+// injectFaults makes the servers of the current user fail according
+// to the given rules, replacing any earlier ones.
+func injectFaults(seed int64, rules ...libkbfs.FaultRule) fileOp {
+	return fileOp{func(c *ctx) error {
+		c.faults.InjectFaults(seed, rules...)
+		return nil
+	}, IsInit, fmt.Sprintf("injectFaults(%d, %v)", seed, rules)}
+}
+
+func undoInjectFaults() fileOp {
+	return fileOp{func(c *ctx) error {
+		c.faults.UndoInjectFaults()
+		return nil
+	}, IsInit, "undoInjectFaults()"}
+}
+
+func disconnect(server libkbfs.FaultableServer) fileOp {
+	return fileOp{func(c *ctx) error {
+		c.faults.Disconnect(server)
+		return nil
+	}, IsInit, fmt.Sprintf("disconnect(%s)", server)}
+}
+
+func reconnect(server libkbfs.FaultableServer) fileOp {
+	return fileOp{func(c *ctx) error {
+		c.faults.Reconnect(server)
+		return nil
+	}, IsInit, fmt.Sprintf("reconnect(%s)", server)}
+}
+
+func checkFaultsInjected(op libkbfs.FaultableOp, expected int) fileOp {
+	return fileOp{func(c *ctx) error {
+		if n := c.faults.NumInjected(op); n != expected {
+			return fmt.Errorf("Expected %d faults injected into %s, got %d",
+				expected, op, n)
+		}
+		return nil
+	}, IsInit, fmt.Sprintf("checkFaultsInjected(%s, %d)", op, expected)}
+}
+
 func reenableUpdates() fileOp {
 	return fileOp{func(c *ctx) error {
 		err := c.engine.ReenableUpdates(c.user, c.tlfName, c.tlfType)
@@ -111,6 +111,9 @@ type Engine interface {
 	//MakeNaïveStaller returns a NaïveStaller associated with user u for
 	//stalling BlockOps or MDOps.
 	MakeNaïveStaller(u User) *libkbfs.NaïveStaller
+	// MakeFaultInjector returns a FaultInjector associated with user
+	// u for injecting faults into its servers and caches.
+	MakeFaultInjector(u User) *libkbfs.FaultInjector
 	// ReenableUpdates is called by the test harness as the given
 	// user to resume updates if previously disabled for testing.
 	ReenableUpdates(u User, tlfName string, t tlf.Type) (err error)
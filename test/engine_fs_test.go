@@ -292,6 +292,11 @@ func (*fsEngine) MakeNaïveStaller(u User) *libkbfs.NaïveStaller {
 	return libkbfs.NewNaïveStaller(u.(*fsUser).config)
 }
 
+// MakeFaultInjector implements the Engine interface.
+func (*fsEngine) MakeFaultInjector(u User) *libkbfs.FaultInjector {
+	return libkbfs.NewFaultInjector(u.(*fsUser).config)
+}
+
 // ReenableUpdatesForTesting is called by the test harness as the given user to resume updates
 // if previously disabled for testing.
 func (*fsEngine) ReenableUpdates(user User, tlfName string, t tlf.Type) (err error) {
@@ -544,6 +544,11 @@ func (*LibKBFS) MakeNaïveStaller(u User) *libkbfs.NaïveStaller {
 	return libkbfs.NewNaïveStaller(u.(*libkbfs.ConfigLocal))
 }
 
+// MakeFaultInjector implements the Engine interface.
+func (*LibKBFS) MakeFaultInjector(u User) *libkbfs.FaultInjector {
+	return libkbfs.NewFaultInjector(u.(*libkbfs.ConfigLocal))
+}
+
 // ReenableUpdates implements the Engine interface.
 func (k *LibKBFS) ReenableUpdates(u User, tlfName string, t tlf.Type) error {
 	config := u.(*libkbfs.ConfigLocal)
@@ -0,0 +1,212 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+// These tests inject faults into the servers of a user, and check
+// that journaling and conflict resolution recover from them.
+
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/keybase/kbfs/libkbfs"
+)
+
+// alice's MD put fails once, and succeeds when retried.
+func TestFaultsMDPutError(t *testing.T) {
+	test(t,
+		users("alice", "bob"),
+		as(alice,
+			mkdir("a"),
+		),
+		as(alice,
+			injectFaults(1, libkbfs.FaultRule{
+				Op:    libkbfs.FaultableMDPut,
+				Kind:  libkbfs.FaultError,
+				Times: 1,
+			}),
+			expectError(mkfile("a/b", "hello"), "Injected fault"),
+			checkFaultsInjected(libkbfs.FaultableMDPut, 1),
+			undoInjectFaults(),
+		),
+		as(bob,
+			lsdir("a/", m{"b$": "FILE"}),
+			read("a/b", "hello"),
+		),
+	)
+}
+
+// bob's journal fails to flush a block once, and then finishes
+// flushing.
+func TestFaultsJournalBlockPutError(t *testing.T) {
+	test(t, journal(),
+		users("alice", "bob"),
+		as(alice,
+			mkdir("a"),
+		),
+		as(bob,
+			enableJournal(),
+			pauseJournal(),
+			mkfile("a/b", "hello"),
+		),
+		as(bob,
+			injectFaults(1, libkbfs.FaultRule{
+				Op:    libkbfs.FaultableBlockPut,
+				Kind:  libkbfs.FaultError,
+				Times: 1,
+			}),
+			expectError(flushJournal(), "Injected fault"),
+			checkFaultsInjected(libkbfs.FaultableBlockPut, 1),
+			flushJournal(),
+			checkUnflushedPaths(nil),
+			undoInjectFaults(),
+		),
+		as(alice,
+			lsdir("a/", m{"b$": "FILE"}),
+			read("a/b", "hello"),
+		),
+	)
+}
+
+// bob's journal puts a block, but doesn't hear back from the block
+// server, so it has to put the block again.
+func TestFaultsJournalBlockPutPartial(t *testing.T) {
+	test(t, journal(),
+		users("alice", "bob"),
+		as(alice,
+			mkdir("a"),
+		),
+		as(bob,
+			enableJournal(),
+			pauseJournal(),
+			mkfile("a/b", "hello"),
+		),
+		as(bob,
+			injectFaults(1, libkbfs.FaultRule{
+				Op:    libkbfs.FaultableBlockPut,
+				Kind:  libkbfs.FaultPartial,
+				Times: 1,
+			}),
+			expectError(flushJournal(), "Injected fault"),
+			flushJournal(),
+			checkUnflushedPaths(nil),
+			undoInjectFaults(),
+		),
+		as(alice,
+			lsdir("a/", m{"b$": "FILE"}),
+			read("a/b", "hello"),
+		),
+	)
+}
+
+// bob's journal puts an MD, but doesn't hear back from the MD server,
+// so it finds its own MD when it tries again.
+func TestFaultsJournalMDPutPartial(t *testing.T) {
+	test(t, journal(),
+		users("alice", "bob"),
+		as(alice,
+			mkdir("a"),
+		),
+		as(bob,
+			enableJournal(),
+			pauseJournal(),
+			mkfile("a/b", "hello"),
+		),
+		as(bob,
+			injectFaults(1, libkbfs.FaultRule{
+				Op:    libkbfs.FaultableMDPut,
+				Kind:  libkbfs.FaultPartial,
+				Times: 1,
+			}),
+			expectError(flushJournal(), "Injected fault"),
+			flushJournal(),
+			checkUnflushedPaths(nil),
+			undoInjectFaults(),
+		),
+		as(alice,
+			lsdir("a/", m{"b$": "FILE"}),
+			read("a/b", "hello"),
+		),
+	)
+}
+
+// bob's MD server disconnects while his journal is flushing, and the
+// journal finishes flushing once it's back.
+func TestFaultsJournalMDServerDisconnect(t *testing.T) {
+	test(t, journal(),
+		users("alice", "bob"),
+		as(alice,
+			mkdir("a"),
+		),
+		as(bob,
+			enableJournal(),
+			pauseJournal(),
+			mkfile("a/b", "hello"),
+		),
+		as(bob,
+			injectFaults(1, libkbfs.FaultRule{
+				Op:   libkbfs.FaultableMDPut,
+				Kind: libkbfs.FaultDisconnect,
+			}),
+			expectError(flushJournal(), "Injected fault"),
+			expectError(flushJournal(), "Injected fault"),
+			checkUnflushedPaths([]string{
+				"alice,bob/a",
+				"alice,bob/a/b",
+			}),
+			injectFaults(1),
+			reconnect(libkbfs.FaultableMDServer),
+			flushJournal(),
+			checkUnflushedPaths(nil),
+			undoInjectFaults(),
+		),
+		as(alice,
+			lsdir("a/", m{"b$": "FILE"}),
+			read("a/b", "hello"),
+		),
+	)
+}
+
+// bob's MD and block servers are randomly slow while he resolves a
+// conflict.
+func TestFaultsCrLatency(t *testing.T) {
+	slow := func(op libkbfs.FaultableOp) libkbfs.FaultRule {
+		return libkbfs.FaultRule{
+			Op:          op,
+			Kind:        libkbfs.FaultLatency,
+			Probability: 0.5,
+			Latency:     10 * time.Millisecond,
+		}
+	}
+	test(t,
+		users("alice", "bob"),
+		as(alice,
+			mkfile("a/b", "hello"),
+		),
+		as(bob,
+			disableUpdates(),
+		),
+		as(alice,
+			write("a/c", "world"),
+		),
+		as(bob, noSync(),
+			write("a/d", "uh oh"),
+			injectFaults(42,
+				slow(libkbfs.FaultableMDGetRange),
+				slow(libkbfs.FaultableMDPut),
+				slow(libkbfs.FaultableBlockGet),
+				slow(libkbfs.FaultableBlockPut)),
+			reenableUpdates(),
+			lsdir("a/", m{"b$": "FILE", "c$": "FILE", "d$": "FILE"}),
+			read("a/c", "world"),
+			read("a/d", "uh oh"),
+			undoInjectFaults(),
+		),
+		as(alice,
+			lsdir("a/", m{"b$": "FILE", "c$": "FILE", "d$": "FILE"}),
+			read("a/d", "uh oh"),
+		),
+	)
+}
@@ -0,0 +1,708 @@
+// Copyright 2018 Keybase Inc. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+// +build !dokan,!fuse
+
+// These tests run random sequences of operations by several users
+// through the libkbfs engine, and check the results against a simple
+// in-memory model of the filesystem.  A failing sequence is shrunk
+// to a minimal one, and printed as a DSL script.
+
+package test
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"math/rand"
+	"path"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/keybase/kbfs/kbfsmd"
+)
+
+var (
+	randomOpsSeed = flag.Int64("random-ops-seed", 0,
+		"If non-zero, TestRandomOps only runs the sequence with this seed")
+	randomOpsRuns = flag.Int("random-ops-runs", 3,
+		"The number of sequences TestRandomOps runs")
+	randomOpsSteps = flag.Int("random-ops-steps", 40,
+		"The number of steps in each sequence run by TestRandomOps")
+)
+
+// knownFailingSeeds maps the seeds whose sequences are known to fail
+// to the skipped regression tests holding their minimal scripts.
+var knownFailingSeeds = map[int64]string{
+	65:  "TestCrUnmergedTruncateRenameOverRenamedFile",
+	101: "TestCrUnmergedRenameIntoNewDirAndTruncate",
+}
+
+// modelBlockSize is small, so that even short files take up several
+// blocks.
+const modelBlockSize = 20
+
+type modelOpKind int
+
+const (
+	modelWrite modelOpKind = iota
+	modelTruncate
+	modelRename
+	modelRemove
+	// modelSync ends the current as() block of the user, which
+	// syncs everything the user has written.
+	modelSync
+	// modelDisconnect stops the user from getting updates, so that
+	// its writes go to a conflict branch until modelReconnect.
+	modelDisconnect
+	modelReconnect
+)
+
+// modelStep is a single operation by one user in a random sequence.
+type modelStep struct {
+	user username
+	kind modelOpKind
+	path string
+	// dst is the new path of a renamed file.
+	dst string
+	// off, data and sync describe a write.
+	off  int64
+	data []byte
+	sync bool
+	// size is the new size of a truncated file.
+	size uint64
+}
+
+// modelFS is the reference model of the TLF shared by the users.
+// It's simple enough to be obviously right: there are no symlinks
+// or exec bits, and directories only exist as parents of files.
+//
+// While any user is disconnected, every user is limited to its own
+// top-level directory, so that conflict resolution never has to
+// rename anything, and the model stays the same for all the users.
+type modelFS struct {
+	files        map[string][]byte
+	dirs         map[string]bool
+	disconnected map[username]bool
+}
+
+func newModelFS() *modelFS {
+	return &modelFS{
+		files:        make(map[string][]byte),
+		dirs:         make(map[string]bool),
+		disconnected: make(map[username]bool),
+	}
+}
+
+func modelParent(p string) string {
+	dir := path.Dir(p)
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
+
+// canCreateFile returns whether p can be a file, i.e. it isn't a
+// directory, and none of its parents are files.
+func (fs *modelFS) canCreateFile(p string) bool {
+	if fs.dirs[p] {
+		return false
+	}
+	for dir := modelParent(p); dir != ""; dir = modelParent(dir) {
+		if _, ok := fs.files[dir]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (fs *modelFS) makeParents(p string) {
+	for dir := modelParent(p); dir != ""; dir = modelParent(dir) {
+		fs.dirs[dir] = true
+	}
+}
+
+// isAllowed returns whether step stays within the directory of its
+// user, if it has to.
+func (fs *modelFS) isAllowed(step modelStep) bool {
+	if len(fs.disconnected) == 0 {
+		return true
+	}
+	prefix := string(step.user) + "/"
+	switch step.kind {
+	case modelWrite, modelTruncate, modelRemove:
+		return strings.HasPrefix(step.path, prefix)
+	case modelRename:
+		return strings.HasPrefix(step.path, prefix) &&
+			strings.HasPrefix(step.dst, prefix)
+	default:
+		return true
+	}
+}
+
+// apply applies step to fs, and returns false without changing fs if
+// the step isn't valid at this point.
+func (fs *modelFS) apply(step modelStep) bool {
+	if !fs.isAllowed(step) {
+		return false
+	}
+
+	switch step.kind {
+	case modelWrite:
+		if !fs.canCreateFile(step.path) {
+			return false
+		}
+		old := fs.files[step.path]
+		size := step.off + int64(len(step.data))
+		if size < int64(len(old)) {
+			size = int64(len(old))
+		}
+		buf := make([]byte, size)
+		copy(buf, old)
+		copy(buf[step.off:], step.data)
+		fs.makeParents(step.path)
+		fs.files[step.path] = buf
+	case modelTruncate:
+		if !fs.canCreateFile(step.path) {
+			return false
+		}
+		buf := make([]byte, step.size)
+		copy(buf, fs.files[step.path])
+		fs.makeParents(step.path)
+		fs.files[step.path] = buf
+	case modelRename:
+		buf, ok := fs.files[step.path]
+		if !ok || step.dst == step.path || !fs.canCreateFile(step.dst) {
+			return false
+		}
+		fs.makeParents(step.dst)
+		fs.files[step.dst] = buf
+		delete(fs.files, step.path)
+	case modelRemove:
+		if _, ok := fs.files[step.path]; !ok {
+			return false
+		}
+		delete(fs.files, step.path)
+	case modelSync:
+	case modelDisconnect:
+		if fs.disconnected[step.user] {
+			return false
+		}
+		fs.disconnected[step.user] = true
+	case modelReconnect:
+		if !fs.disconnected[step.user] {
+			return false
+		}
+		delete(fs.disconnected, step.user)
+	default:
+		panic(fmt.Sprintf("Unknown model op %d", step.kind))
+	}
+	return true
+}
+
+func (fs *modelFS) sortedFiles() []string {
+	files := make([]string, 0, len(fs.files))
+	for p := range fs.files {
+		files = append(files, p)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// validModelSteps drops the steps that aren't valid once the steps
+// before them have been applied.
+func validModelSteps(steps []modelStep) []modelStep {
+	fs := newModelFS()
+	var valid []modelStep
+	for _, step := range steps {
+		if fs.apply(step) {
+			valid = append(valid, step)
+		}
+	}
+	return valid
+}
+
+func randomModelData(r *rand.Rand) []byte {
+	data := make([]byte, 1+r.Intn(3*modelBlockSize))
+	for i := range data {
+		data[i] = byte('a' + r.Intn(26))
+	}
+	return data
+}
+
+// randomModelStep returns a random step by one of users, which is
+// usually, but not always, valid for fs.
+func randomModelStep(
+	r *rand.Rand, fs *modelFS, usernames []username) modelStep {
+	user := usernames[r.Intn(len(usernames))]
+	step := modelStep{user: user}
+
+	dirs := []string{string(user), string(user) + "/d"}
+	if len(fs.disconnected) == 0 {
+		dirs = append(dirs, "", "a", "a/b")
+		for _, u := range usernames {
+			if u != user {
+				dirs = append(dirs, string(u))
+			}
+		}
+	}
+	randomPath := func() string {
+		return path.Join(dirs[r.Intn(len(dirs))], []string{"f", "g", "h"}[r.Intn(3)])
+	}
+	var existing []string
+	for _, p := range fs.sortedFiles() {
+		if fs.isAllowed(modelStep{user: user, kind: modelRemove, path: p}) {
+			existing = append(existing, p)
+		}
+	}
+	existingOrRandomPath := func() string {
+		if len(existing) > 0 && r.Intn(2) == 0 {
+			return existing[r.Intn(len(existing))]
+		}
+		return randomPath()
+	}
+
+	switch n := r.Intn(100); {
+	case n < 35:
+		step.kind = modelWrite
+		step.path = existingOrRandomPath()
+		step.off = r.Int63n(
+			int64(len(fs.files[step.path])) + modelBlockSize + 1)
+		step.data = randomModelData(r)
+		step.sync = r.Intn(2) == 0
+	case n < 45:
+		step.kind = modelTruncate
+		step.path = existingOrRandomPath()
+		step.size = uint64(r.Intn(len(fs.files[step.path]) + modelBlockSize))
+	case n < 57:
+		step.kind = modelRename
+		step.path = existingOrRandomPath()
+		step.dst = randomPath()
+	case n < 65:
+		step.kind = modelRemove
+		step.path = existingOrRandomPath()
+	case n < 80:
+		step.kind = modelSync
+	case n < 88:
+		step.kind = modelDisconnect
+	default:
+		step.kind = modelReconnect
+		for _, u := range usernames {
+			if fs.disconnected[u] {
+				step.user = u
+				break
+			}
+		}
+	}
+	return step
+}
+
+// generateModelSteps returns n random valid steps by users.
+func generateModelSteps(
+	r *rand.Rand, usernames []username, n int) []modelStep {
+	fs := newModelFS()
+	var steps []modelStep
+	for len(steps) < n {
+		step := randomModelStep(r, fs, usernames)
+		if fs.apply(step) {
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+// modelCall is a DSL operation, along with the Go code that makes it.
+type modelCall struct {
+	op  fileOp
+	src string
+}
+
+func modelRead(fs *modelFS, p string) modelCall {
+	return modelCall{readAll(p, string(fs.files[p])),
+		fmt.Sprintf("readAll(%q, %q)", p, fs.files[p])}
+}
+
+func modelNotExists(p string) modelCall {
+	return modelCall{notExists(p), fmt.Sprintf("notExists(%q)", p)}
+}
+
+func modelLsdir(fs *modelFS, dir string) modelCall {
+	contents := make(m)
+	for p := range fs.files {
+		if modelParent(p) == dir {
+			contents["^"+regexp.QuoteMeta(path.Base(p))+"$"] = "FILE"
+		}
+	}
+	for p := range fs.dirs {
+		if modelParent(p) == dir {
+			contents["^"+regexp.QuoteMeta(path.Base(p))+"$"] = "DIR"
+		}
+	}
+	keys := make([]string, 0, len(contents))
+	for k := range contents {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	entries := make([]string, 0, len(keys))
+	for _, k := range keys {
+		entries = append(entries, fmt.Sprintf("%q: %q", k, contents[k]))
+	}
+	return modelCall{lsdir(dir, contents),
+		fmt.Sprintf("lsdir(%q, m{%s})", dir, strings.Join(entries, ", "))}
+}
+
+// modelBlock is a single as() block of DSL operations.
+type modelBlock struct {
+	user   username
+	noSync bool
+	calls  []modelCall
+}
+
+// makeModelBlocks turns the valid ones of steps into as() blocks,
+// checking the results of each step against the model as it goes,
+// and then the whole TLF as seen by every user at the end.
+func makeModelBlocks(
+	usernames []username, steps []modelStep) []modelBlock {
+	fs := newModelFS()
+	var blocks []modelBlock
+	var cur *modelBlock
+	endBlock := func() {
+		if cur != nil && len(cur.calls) > 0 {
+			blocks = append(blocks, *cur)
+		}
+		cur = nil
+	}
+
+	for _, step := range steps {
+		if cur == nil || cur.user != step.user {
+			endBlock()
+			// Disconnected users can't sync from the server.
+			cur = &modelBlock{
+				user:   step.user,
+				noSync: fs.disconnected[step.user],
+			}
+		}
+		if !fs.apply(step) {
+			continue
+		}
+
+		switch step.kind {
+		case modelWrite:
+			cur.calls = append(cur.calls, modelCall{
+				pwriteBSSync(step.path, step.data, step.off, step.sync),
+				fmt.Sprintf("pwriteBSSync(%q, []byte(%q), %d, %t)",
+					step.path, step.data, step.off, step.sync),
+			}, modelRead(fs, step.path))
+		case modelTruncate:
+			cur.calls = append(cur.calls, modelCall{
+				truncate(step.path, step.size),
+				fmt.Sprintf("truncate(%q, %d)", step.path, step.size),
+			}, modelRead(fs, step.path))
+		case modelRename:
+			cur.calls = append(cur.calls, modelCall{
+				rename(step.path, step.dst),
+				fmt.Sprintf("rename(%q, %q)", step.path, step.dst),
+			}, modelRead(fs, step.dst), modelNotExists(step.path))
+		case modelRemove:
+			cur.calls = append(cur.calls, modelCall{
+				rm(step.path), fmt.Sprintf("rm(%q)", step.path),
+			}, modelNotExists(step.path))
+		case modelSync:
+			endBlock()
+		case modelDisconnect:
+			cur.calls = append(cur.calls,
+				modelCall{disableUpdates(), "disableUpdates()"})
+			endBlock()
+		case modelReconnect:
+			cur.calls = append(cur.calls,
+				modelCall{reenableUpdates(), "reenableUpdates()"})
+			endBlock()
+		}
+	}
+	endBlock()
+
+	for _, user := range usernames {
+		if fs.disconnected[user] {
+			blocks = append(blocks, modelBlock{
+				user:   user,
+				noSync: true,
+				calls: []modelCall{
+					{reenableUpdates(), "reenableUpdates()"},
+				},
+			})
+		}
+	}
+
+	dirs := []string{""}
+	for dir := range fs.dirs {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, user := range usernames {
+		block := modelBlock{user: user}
+		for _, dir := range dirs {
+			block.calls = append(block.calls, modelLsdir(fs, dir))
+		}
+		for _, p := range fs.sortedFiles() {
+			block.calls = append(block.calls, modelRead(fs, p))
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+func modelActions(
+	usernames []username, blocks []modelBlock) []optionOp {
+	actions := []optionOp{blockSize(modelBlockSize), users(usernames...)}
+	for _, block := range blocks {
+		var fops []fileOp
+		if block.noSync {
+			fops = append(fops, noSync())
+		}
+		for _, call := range block.calls {
+			fops = append(fops, call.op)
+		}
+		actions = append(actions, as(block.user, fops...))
+	}
+	return actions
+}
+
+// modelScript returns the DSL test that modelActions runs.
+func modelScript(usernames []username, blocks []modelBlock) string {
+	var buf bytes.Buffer
+	names := make([]string, 0, len(usernames))
+	for _, user := range usernames {
+		names = append(names, fmt.Sprintf("%q", user))
+	}
+	fmt.Fprintf(&buf, "test(t,\n\tblockSize(%d), users(%s),\n",
+		modelBlockSize, strings.Join(names, ", "))
+	for _, block := range blocks {
+		fmt.Fprintf(&buf, "\tas(%s,", block.user)
+		if block.noSync {
+			buf.WriteString(" noSync(),")
+		}
+		buf.WriteString("\n")
+		for _, call := range block.calls {
+			fmt.Fprintf(&buf, "\t\t%s,\n", call.src)
+		}
+		buf.WriteString("\t),\n")
+	}
+	buf.WriteString(")\n")
+	return buf.String()
+}
+
+// modelTB records failures instead of reporting them, and drops all
+// logs, so that failing sequences can be run over and over while
+// shrinking them.
+type modelTB struct {
+	testing.TB
+
+	lock     sync.Mutex
+	failures []string
+}
+
+func (tb *modelTB) fail(s string) {
+	tb.lock.Lock()
+	defer tb.lock.Unlock()
+	tb.failures = append(tb.failures, s)
+}
+
+func (tb *modelTB) failure() string {
+	tb.lock.Lock()
+	defer tb.lock.Unlock()
+	if len(tb.failures) == 0 {
+		return ""
+	}
+	return tb.failures[0]
+}
+
+// Log implements the testing.TB interface for modelTB.
+func (tb *modelTB) Log(args ...interface{}) {}
+
+// Logf implements the testing.TB interface for modelTB.
+func (tb *modelTB) Logf(format string, args ...interface{}) {}
+
+// Error implements the testing.TB interface for modelTB.
+func (tb *modelTB) Error(args ...interface{}) {
+	tb.fail(fmt.Sprint(args...))
+}
+
+// Errorf implements the testing.TB interface for modelTB.
+func (tb *modelTB) Errorf(format string, args ...interface{}) {
+	tb.fail(fmt.Sprintf(format, args...))
+}
+
+// Fail implements the testing.TB interface for modelTB.
+func (tb *modelTB) Fail() {
+	tb.fail("Fail")
+}
+
+// FailNow implements the testing.TB interface for modelTB.
+func (tb *modelTB) FailNow() {
+	tb.fail("FailNow")
+	runtime.Goexit()
+}
+
+// Failed implements the testing.TB interface for modelTB.
+func (tb *modelTB) Failed() bool {
+	return tb.failure() != ""
+}
+
+// Fatal implements the testing.TB interface for modelTB.
+func (tb *modelTB) Fatal(args ...interface{}) {
+	tb.fail(fmt.Sprint(args...))
+	runtime.Goexit()
+}
+
+// Fatalf implements the testing.TB interface for modelTB.
+func (tb *modelTB) Fatalf(format string, args ...interface{}) {
+	tb.fail(fmt.Sprintf(format, args...))
+	runtime.Goexit()
+}
+
+// runModelSteps runs steps through the libkbfs engine, and returns
+// the first failure, or "" if there wasn't one.
+func runModelSteps(t *testing.T, ver kbfsmd.MetadataVer,
+	usernames []username, steps []modelStep) string {
+	tb := &modelTB{TB: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runOneTestOrBenchmark(tb, ver, modelActions(
+			usernames, makeModelBlocks(usernames, steps))...)
+	}()
+	<-done
+	return tb.failure()
+}
+
+// shrinkModelSteps returns a minimal subsequence of the valid ones of
+// steps, with the data of each write cut down as far as possible,
+// for which fails still returns true.
+func shrinkModelSteps(
+	steps []modelStep, fails func([]modelStep) bool) []modelStep {
+	steps = validModelSteps(steps)
+	for chunk := len(steps) / 2; chunk > 0; chunk /= 2 {
+		for i := 0; i < len(steps); {
+			end := i + chunk
+			if end > len(steps) {
+				end = len(steps)
+			}
+			candidate := append(append([]modelStep(nil), steps[:i]...),
+				steps[end:]...)
+			candidate = validModelSteps(candidate)
+			if len(candidate) < len(steps) && fails(candidate) {
+				steps = candidate
+				// Try the next chunk at the same position, and go
+				// over everything again with the same chunk size.
+				chunk *= 2
+				break
+			}
+			i += chunk
+		}
+	}
+
+	for i, step := range steps {
+		if step.kind != modelWrite || len(step.data) == 1 {
+			continue
+		}
+		candidate := append([]modelStep(nil), steps...)
+		candidate[i].data = step.data[:1]
+		candidate = validModelSteps(candidate)
+		if len(candidate) == len(steps) && fails(candidate) {
+			steps = candidate
+		}
+	}
+	return steps
+}
+
+func TestRandomOps(t *testing.T) {
+	seeds := []int64{*randomOpsSeed}
+	if *randomOpsSeed == 0 {
+		seeds = nil
+		for i := 1; i <= *randomOpsRuns; i++ {
+			seeds = append(seeds, int64(i))
+		}
+	}
+	usernames := []username{alice, bob}
+
+	runTestOverMetadataVers(t, func(t *testing.T, ver kbfsmd.MetadataVer) {
+		for _, seed := range seeds {
+			seed := seed
+			// Each seed gets its own subtest, since the real run of
+			// a failing sequence below stops the test it's in.
+			t.Run(fmt.Sprintf("Seed%d", seed), func(t *testing.T) {
+				if name, ok := knownFailingSeeds[seed]; ok &&
+					*randomOpsSeed == 0 {
+					t.Skipf("Seed %d is a known failure; see %s", seed, name)
+				}
+				steps := generateModelSteps(rand.New(rand.NewSource(seed)),
+					usernames, *randomOpsSteps)
+				failure := runModelSteps(t, ver, usernames, steps)
+				if failure == "" {
+					return
+				}
+
+				t.Logf("Sequence with seed %d failed with %q; shrinking it",
+					seed, failure)
+				steps = shrinkModelSteps(steps, func(steps []modelStep) bool {
+					return runModelSteps(t, ver, usernames, steps) != ""
+				})
+				blocks := makeModelBlocks(usernames, steps)
+				t.Errorf("Sequence with seed %d failed; minimal test:\n%s",
+					seed, modelScript(usernames, blocks))
+				// Run it for real, to get its logs.
+				runOneTestOrBenchmark(
+					t, ver, modelActions(usernames, blocks)...)
+			})
+		}
+	})
+}
+
+func TestShrinkModelSteps(t *testing.T) {
+	usernames := []username{alice, bob}
+	steps := generateModelSteps(
+		rand.New(rand.NewSource(1)), usernames, 100)
+	steps = append(steps,
+		modelStep{user: alice, kind: modelReconnect},
+		modelStep{user: bob, kind: modelReconnect},
+		modelStep{user: alice, kind: modelWrite, path: "x",
+			data: []byte("xyz")},
+		modelStep{user: bob, kind: modelRename, path: "x", dst: "a/y"})
+	steps = append(steps, generateModelSteps(
+		rand.New(rand.NewSource(2)), usernames, 100)...)
+
+	// Pretend that bob renaming a file to a/y fails.
+	fails := func(steps []modelStep) bool {
+		for _, step := range validModelSteps(steps) {
+			if step.user == bob && step.kind == modelRename &&
+				step.dst == "a/y" {
+				return true
+			}
+		}
+		return false
+	}
+	if !fails(steps) {
+		t.Fatalf("The sequence doesn't fail")
+	}
+
+	steps = shrinkModelSteps(steps, fails)
+	if len(steps) != 2 {
+		t.Fatalf("Expected 2 steps, got %d: %+v", len(steps), steps)
+	}
+	if steps[0].kind != modelWrite || len(steps[0].data) != 1 {
+		t.Fatalf("Expected a 1-byte write first, got %+v", steps[0])
+	}
+	if steps[1].user != bob || steps[1].kind != modelRename {
+		t.Fatalf("Expected a rename by bob second, got %+v", steps[1])
+	}
+
+	script := modelScript(usernames, makeModelBlocks(usernames, steps))
+	if !strings.Contains(script, "\tas(bob,\n\t\trename(\"x\", \"a/y\"),\n") {
+		t.Fatalf("Unexpected script:\n%s", script)
+	}
+}
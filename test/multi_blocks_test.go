@@ -37,6 +37,26 @@ func TestSwitchToMultiblockFile(t *testing.T) {
 	)
 }
 
+// alice writes past the end of a file whose top block is full, which
+// adds a new level of indirection without touching any of the
+// existing blocks, and bob reads it.
+func TestWriteNewLevelPastEndOfMultiblockFile(t *testing.T) {
+	test(t,
+		blockSize(20), users("alice", "bob"),
+		as(alice,
+			// Fill up the two blocks of the top block.
+			write("a/b", ntimesString(6, "0123")),
+		),
+		as(alice,
+			pwriteBS("a/b", []byte("4"), 40),
+		),
+		as(bob,
+			readAll("a/b", ntimesString(6, "0123")+
+				string(make([]byte, 16))+"4"),
+		),
+	)
+}
+
 // alice writes a file, and bob overwrites it with a multi-block file
 func TestOverwriteMultiblockFile(t *testing.T) {
 	test(t,
@@ -162,3 +182,45 @@ func TestReadUnembeddedBlockChanges(t *testing.T) {
 		),
 	)
 }
+
+// bob writes past the end of a multi-block file that alice created,
+// without syncing, and then removes it.
+func TestWriteUnsyncedPastEndAndRemoveMultiblockFile(t *testing.T) {
+	test(t,
+		blockSize(20), users("alice", "bob"),
+		as(alice,
+			pwriteBS("a/b", []byte("y"), 39),
+		),
+		as(bob,
+			pwriteBSSync("a/b", []byte("l"), 94, false),
+			rm("a/b"),
+			notExists("a/b"),
+		),
+		as(alice,
+			lsdir("a", m{}),
+		),
+	)
+}
+
+// bob writes a hole into a new file, matching blocks of another file
+// that alice has since truncated away, and syncs it while updating.
+func TestWriteHoleMatchingArchivedBlocks(t *testing.T) {
+	test(t,
+		blockSize(20), users("alice", "bob"),
+		as(bob,
+			truncate("a/b", 38),
+		),
+		as(alice,
+			truncate("a/b", 8),
+		),
+		as(bob, noSync(),
+			pwriteBSSync("a/c", []byte("j"), 16, false),
+			disableUpdates(),
+			reenableUpdates(),
+			read("a/c", string(make([]byte, 16))+"j"),
+		),
+		as(alice,
+			read("a/c", string(make([]byte, 16))+"j"),
+		),
+	)
+}
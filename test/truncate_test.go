@@ -110,3 +110,61 @@ func TestTruncateLargeThenWriteToSmallerOffsetWithHoles(t *testing.T) {
 	testTruncateLargeThenWriteToSmallerOffset(
 		t, 1024*1024 /* above the holes threshold */)
 }
+
+// Test that removing a file, after extending it with a truncate and
+// writing into its last block, keeps the disk usage consistent.
+func TestTruncateExtendWriteAndRemove(t *testing.T) {
+	test(t,
+		blockSize(20), users("alice", "bob"),
+		as(bob,
+			truncate("bob/f", 23),
+			pwriteBSSync("bob/f", []byte("c"), 22, false),
+			rm("bob/f"),
+			notExists("bob/f"),
+		),
+		as(alice,
+			lsdir("", m{"^bob$": "DIR"}),
+			lsdir("bob", m{}),
+		),
+	)
+}
+
+// Test that extending a multi-block file to a new level of
+// indirection, after overwriting data across its blocks, keeps the
+// disk usage consistent.
+func TestTruncateExtendNewLevelAfterOverwrite(t *testing.T) {
+	test(t,
+		blockSize(20), users("alice", "bob"),
+		as(alice,
+			truncate("a/b", 35),
+		),
+		as(bob,
+			pwriteBS("a/b", []byte("xpxrmqopvsbejxgwcndtpqfrls"), 4),
+		),
+		as(bob,
+			truncate("a/b", 97),
+		),
+		as(alice,
+			preadBS("a/b", []byte("xpxrmqopvsbejxgwcndtpqfrls"), 4),
+			preadBS("a/b", make([]byte, 67), 30),
+		),
+	)
+}
+
+// Test that shrinking a file into the middle of a hole drops all the
+// data after the hole.
+func TestTruncateIntoHole(t *testing.T) {
+	test(t,
+		blockSize(20), users("alice", "bob"),
+		as(alice,
+			pwriteBSSync("file", []byte("k"), 30, true),
+		),
+		as(bob,
+			truncate("file", 15),
+			readAll("file", string(make([]byte, 15))),
+		),
+		as(alice,
+			readAll("file", string(make([]byte, 15))),
+		),
+	)
+}